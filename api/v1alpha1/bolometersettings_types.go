@@ -0,0 +1,115 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SettingsName is the name of the BolometerSettings the operator reads;
+// settings under any other name are ignored
+const SettingsName = "default"
+
+// BolometerSettingsSpec defines the operator-wide defaults and limits
+type BolometerSettingsSpec struct {
+	// DefaultS3Config fills the S3 fields a ProfilingConfig leaves empty
+	// +optional
+	DefaultS3Config *S3Configuration `json:"defaultS3Config,omitempty"`
+
+	// DefaultThresholds fills the thresholds a ProfilingConfig leaves unset
+	// +optional
+	DefaultThresholds *ThresholdDefaults `json:"defaultThresholds,omitempty"`
+
+	// RateLimits overrides the capture limits the operator was started with
+	// +optional
+	RateLimits *RateLimits `json:"rateLimits,omitempty"`
+
+	// RequeueIntervalSeconds is how often every ProfilingConfig is reconciled
+	// to pick up missed pod changes
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	RequeueIntervalSeconds int `json:"requeueIntervalSeconds,omitempty"`
+
+	// FeatureGates enables or disables optional features by name. Known gates
+	// are OnDemandProfiling, NodePressureChecks and AdaptiveCheckInterval, all
+	// enabled by default.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// ThresholdDefaults defines the default thresholds of ProfilingConfigs
+type ThresholdDefaults struct {
+	// CPUThresholdPercent is the default CPU usage percentage threshold (0-100)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	CPUThresholdPercent int `json:"cpuThresholdPercent,omitempty"`
+
+	// MemoryThresholdPercent is the default memory usage percentage threshold (0-100)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MemoryThresholdPercent int `json:"memoryThresholdPercent,omitempty"`
+
+	// CheckIntervalSeconds is the default interval between metrics checks
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	CheckIntervalSeconds int `json:"checkIntervalSeconds,omitempty"`
+
+	// CooldownSeconds is the default cooldown period after capturing a profile
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	CooldownSeconds int `json:"cooldownSeconds,omitempty"`
+}
+
+// RateLimits defines the capture limits shared by all ProfilingConfigs.
+// Unset limits keep the operator's flag values; 0 disables a limit.
+type RateLimits struct {
+	// MaxPortForwards limits the port-forwards open at once
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxPortForwards *int `json:"maxPortForwards,omitempty"`
+
+	// MaxCapturesPerMinute limits the captures started per minute
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCapturesPerMinute *int `json:"maxCapturesPerMinute,omitempty"`
+}
+
+// BolometerSettingsStatus defines the observed state of BolometerSettings
+type BolometerSettingsStatus struct {
+	// ObservedGeneration is the generation of the spec last applied
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the settings were applied
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=bset
+// +kubebuilder:printcolumn:name="Applied",type=string,JSONPath=`.status.conditions[?(@.type=="Applied")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// BolometerSettings is the Schema for the bolometersettings API. The operator
+// reads the cluster-scoped instance named "default".
+type BolometerSettings struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BolometerSettingsSpec   `json:"spec,omitempty"`
+	Status BolometerSettingsStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BolometerSettingsList contains a list of BolometerSettings
+type BolometerSettingsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BolometerSettings `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BolometerSettings{}, &BolometerSettingsList{})
+}
@@ -0,0 +1,183 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConfigNameLabel is the label set on ProfileCaptures to reference their ProfilingConfig
+	ConfigNameLabel = "bolometer.io/config"
+
+	// RequestedLabel marks a ProfileCapture created by a user, e.g. with the
+	// kubectl plugin, as a request for the operator to capture its pod now
+	RequestedLabel = "bolometer.io/requested"
+
+	// RequestedReason is the reason of requested captures
+	RequestedReason = "requested"
+)
+
+// CapturePhase is the lifecycle phase of a ProfileCapture
+type CapturePhase string
+
+const (
+	// CapturePhaseRunning means profiles are being captured or uploaded
+	CapturePhaseRunning CapturePhase = "Running"
+
+	// CapturePhaseSucceeded means all profiles were captured and uploaded
+	CapturePhaseSucceeded CapturePhase = "Succeeded"
+
+	// CapturePhaseFailed means the capture or upload failed
+	CapturePhaseFailed CapturePhase = "Failed"
+)
+
+// ProfileCaptureSpec defines a single profile capture of a pod. ProfileCaptures
+// labeled with RequestedLabel are created by users to request a capture, which
+// the operator runs through ConfigName.
+type ProfileCaptureSpec struct {
+	// ConfigName is the ProfilingConfig that requested the capture
+	// +optional
+	ConfigName string `json:"configName,omitempty"`
+
+	// Cluster is the remote cluster running the pod, empty for the operator's own
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// PodName is the name of the profiled pod
+	PodName string `json:"podName"`
+
+	// PodNamespace is the namespace of the profiled pod
+	PodNamespace string `json:"podNamespace"`
+
+	// ProfileTypes lists the profile types captured. Requested captures
+	// default to the profile types of the config.
+	// +optional
+	ProfileTypes []string `json:"profileTypes,omitempty"`
+
+	// Reason describes why the capture was triggered
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// TriggerMetrics records the metric values and thresholds that caused a capture
+type TriggerMetrics struct {
+	// CPUUsagePercent is the CPU usage as a percentage of requests, formatted with two decimals
+	CPUUsagePercent string `json:"cpuUsagePercent"`
+
+	// MemoryUsagePercent is the memory usage as a percentage of requests, formatted with two decimals
+	MemoryUsagePercent string `json:"memoryUsagePercent"`
+
+	// CPUUsage is the raw CPU usage
+	CPUUsage resource.Quantity `json:"cpuUsage"`
+
+	// MemoryUsage is the raw memory usage
+	MemoryUsage resource.Quantity `json:"memoryUsage"`
+
+	// CPUThresholdPercent is the CPU threshold in effect
+	CPUThresholdPercent int `json:"cpuThresholdPercent"`
+
+	// MemoryThresholdPercent is the memory threshold in effect
+	MemoryThresholdPercent int `json:"memoryThresholdPercent"`
+}
+
+// RegressionResult records how a capture compares to its baseline
+type RegressionResult struct {
+	// Score is the largest growth of a profile over its baseline in percent,
+	// formatted with two decimals
+	Score string `json:"score"`
+
+	// Regressed is true when the score exceeds the regression threshold
+	Regressed bool `json:"regressed"`
+
+	// ReportKey is the S3 key of the comparison report
+	// +optional
+	ReportKey string `json:"reportKey,omitempty"`
+}
+
+// ProfileURL is a presigned download URL of an uploaded profile
+type ProfileURL struct {
+	// Type is the profile type, e.g. heap
+	Type string `json:"type"`
+
+	// URL downloads the profile without bucket credentials until it expires
+	URL string `json:"url"`
+}
+
+// ProfileCaptureStatus defines the observed state of ProfileCapture
+type ProfileCaptureStatus struct {
+	// Phase is the current phase of the capture
+	// +optional
+	Phase CapturePhase `json:"phase,omitempty"`
+
+	// StartTime is when the capture started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the capture finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Trigger holds the metric values that caused the capture, if metric based
+	// +optional
+	Trigger *TriggerMetrics `json:"trigger,omitempty"`
+
+	// Bucket is the S3 bucket the profiles were uploaded to
+	// +optional
+	Bucket string `json:"bucket,omitempty"`
+
+	// ObjectKeys are the S3 keys of the uploaded profiles
+	// +optional
+	ObjectKeys []string `json:"objectKeys,omitempty"`
+
+	// ManifestKey is the S3 key of the capture manifest
+	// +optional
+	ManifestKey string `json:"manifestKey,omitempty"`
+
+	// ProfileURLs are presigned download URLs of the uploaded profiles, when
+	// the config's S3 settings presign them
+	// +optional
+	ProfileURLs []ProfileURL `json:"profileURLs,omitempty"`
+
+	// ProfileURLsExpireTime is when the presigned URLs stop working
+	// +optional
+	ProfileURLsExpireTime *metav1.Time `json:"profileURLsExpireTime,omitempty"`
+
+	// Regression compares the capture to the config's baseline, if any
+	// +optional
+	Regression *RegressionResult `json:"regression,omitempty"`
+
+	// Message is a human readable message about the capture
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,shortName=pcap
+// +kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.spec.podName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.spec.reason`,priority=1
+// +kubebuilder:printcolumn:name="Regressed",type=boolean,JSONPath=`.status.regression.regressed`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ProfileCapture is the Schema for the profilecaptures API
+type ProfileCapture struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProfileCaptureSpec   `json:"spec,omitempty"`
+	Status ProfileCaptureStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProfileCaptureList contains a list of ProfileCapture
+type ProfileCaptureList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProfileCapture `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProfileCapture{}, &ProfileCaptureList{})
+}
@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -9,20 +10,887 @@ type ProfilingConfigSpec struct {
 	// Selector for target pods
 	Selector PodSelector `json:"selector"`
 
-	// Threshold configuration for abnormality detection
+	// Threshold configuration for abnormality detection. Unset thresholds take
+	// the BolometerSettings defaults.
+	// +optional
 	Thresholds ThresholdConfig `json:"thresholds"`
 
+	// TriggerExpression is a CEL expression replacing the thresholds and
+	// conditions: a pod is captured when it evaluates to true. It reads the
+	// pod-wide cpuPercent and memPercent, the restarts of the pod's
+	// containers, the throttledRate of its most throttled container and its
+	// goroutines.
+	// +optional
+	TriggerExpression string `json:"triggerExpression,omitempty"`
+
 	// On-demand profiling configuration
 	// +optional
 	OnDemand *OnDemandConfig `json:"onDemand,omitempty"`
 
-	// S3 configuration for profile uploads
+	// S3 configuration for profile uploads. Empty fields take the
+	// BolometerSettings defaults.
+	// +optional
 	S3Config S3Configuration `json:"s3Config"`
 
 	// ProfileTypes specifies which profile types to capture
 	// Valid values: heap, cpu, goroutine, mutex
 	// +kubebuilder:default={"heap","cpu","goroutine","mutex"}
 	ProfileTypes []string `json:"profileTypes,omitempty"`
+
+	// MetricsSource selects where pod usage is read from. Use kubelet on
+	// clusters without metrics-server, or prometheus for rate calculations
+	// over a longer window.
+	// +kubebuilder:validation:Enum=metrics-server;kubelet;prometheus
+	// +kubebuilder:default=metrics-server
+	// +optional
+	MetricsSource string `json:"metricsSource,omitempty"`
+
+	// MemoryMetric selects the memory usage compared to the memory requests:
+	// the working set, the resident set size excluding the page cache, or the
+	// total usage including it. Only the kubelet and prometheus sources
+	// report rss and usage.
+	// +kubebuilder:validation:Enum=workingSet;rss;usage
+	// +kubebuilder:default=workingSet
+	// +optional
+	MemoryMetric string `json:"memoryMetric,omitempty"`
+
+	// Prometheus configures the Prometheus metrics source
+	// +optional
+	Prometheus *PrometheusConfig `json:"prometheus,omitempty"`
+
+	// CaptureHistoryLimit is the number of finished ProfileCapture records kept
+	// for this config. Pending and running captures are not pruned.
+	// +kubebuilder:default=20
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CaptureHistoryLimit *int32 `json:"captureHistoryLimit,omitempty"`
+
+	// MaxConcurrentCaptures limits how many captures of this config may be queued
+	// or running at once. Captures beyond the limit are retried on the next check.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentCaptures int `json:"maxConcurrentCaptures,omitempty"`
+
+	// MaxPodsPerCapture limits how many pods are profiled on each on-demand tick.
+	// Pods are taken in turn so every pod is profiled over successive ticks.
+	// 0 profiles every tracked pod.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxPodsPerCapture int `json:"maxPodsPerCapture,omitempty"`
+
+	// MaxCapturesPerCheck is the capture budget of a threshold check: at most
+	// this many of the pods over their thresholds are captured, furthest over
+	// first, and the rest are deferred to the next check. 0 disables the limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCapturesPerCheck int `json:"maxCapturesPerCheck,omitempty"`
+
+	// MaxCapturesPerInterval is the former name of MaxCapturesPerCheck, used
+	// when it is not set.
+	// Deprecated: use MaxCapturesPerCheck.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCapturesPerInterval int `json:"maxCapturesPerInterval,omitempty"`
+
+	// SamplingPercent is the percentage of threshold violations that result in a
+	// capture, for fleets too large to profile every violation. Pods whose
+	// violation is not sampled start their cooldown as if captured.
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SamplingPercent int `json:"samplingPercent,omitempty"`
+
+	// NodePressurePolicy controls captures of pods on nodes reporting memory, disk
+	// or PID pressure. Ignore captures regardless, Skip drops the capture and starts
+	// the cooldown, Defer drops the capture and retries on the next check.
+	// +kubebuilder:validation:Enum=Ignore;Skip;Defer
+	// +kubebuilder:default=Ignore
+	// +optional
+	NodePressurePolicy NodePressurePolicy `json:"nodePressurePolicy,omitempty"`
+
+	// StorageBudget caps the bytes the config's captures store. Once exceeded,
+	// threshold, on-demand and trigger source captures are skipped or
+	// downgraded to heap profiles; requested captures still run.
+	// +optional
+	StorageBudget *StorageBudget `json:"storageBudget,omitempty"`
+
+	// Deduplication skips storing a profile identical to the previous profile
+	// of its type captured from the same pod; the manifest of the capture
+	// refers to the earlier object instead
+	// +optional
+	Deduplication *DeduplicationConfig `json:"deduplication,omitempty"`
+
+	// Priority decides which config profiles a pod selected by several configs.
+	// The highest priority wins; ties go to the oldest config, then to the
+	// lowest namespace/name. Captures of higher priority configs also run first
+	// when the capture workers are busy, and a full capture queue sheds lower
+	// priority on-demand captures to make room for them.
+	// +kubebuilder:default=0
+	// +optional
+	Priority int `json:"priority,omitempty"`
+
+	// Cluster profiles pods in another cluster, reached through a kubeconfig
+	// stored in a Secret. Pods in the operator's own cluster are profiled if unset.
+	// +optional
+	Cluster *ClusterTarget `json:"cluster,omitempty"`
+
+	// Notifications configures where capture events are sent
+	// +optional
+	Notifications *NotificationConfig `json:"notifications,omitempty"`
+
+	// Baseline compares every capture against known-good profiles, e.g. from
+	// the previous release, and records a regression score
+	// +optional
+	Baseline *BaselineConfig `json:"baseline,omitempty"`
+
+	// LeakDetection compares the consecutive heap and goroutine profiles of
+	// each pod and reports stacks growing across all of them as probable leaks
+	// +optional
+	LeakDetection *LeakDetectionConfig `json:"leakDetection,omitempty"`
+
+	// Aggregation merges the profiles captured across the replicas of each
+	// service into one profile per type and window
+	// +optional
+	Aggregation *AggregationConfig `json:"aggregation,omitempty"`
+
+	// ExecHooks run commands in the target container around each capture, for
+	// applications that need preparation to produce useful profiles. Only
+	// allowed when selecting pods of the config's namespace, and only run in
+	// pods annotated with bolometer.io/allow-exec-hooks: "true".
+	// +optional
+	ExecHooks *ExecHooksConfig `json:"execHooks,omitempty"`
+
+	// VPADrift captures the pods whose usage drifts away from the
+	// recommendations of the VerticalPodAutoscaler of their workload
+	// +optional
+	VPADrift *VPADriftConfig `json:"vpaDrift,omitempty"`
+
+	// HPAScaleOut captures the pods of a workload when its
+	// HorizontalPodAutoscaler scales it out rapidly or reaches maxReplicas
+	// +optional
+	HPAScaleOut *HPAScaleOutConfig `json:"hpaScaleOut,omitempty"`
+
+	// EventTriggers capture pods when Kubernetes Events with the given
+	// reasons are recorded for them or their nodes
+	// +optional
+	EventTriggers []EventTrigger `json:"eventTriggers,omitempty"`
+
+	// EphemeralStorage captures the pods whose ephemeral-storage usage, read
+	// from the kubelet, approaches their limit
+	// +optional
+	EphemeralStorage *EphemeralStorageConfig `json:"ephemeralStorage,omitempty"`
+
+	// NetworkIO captures the pods whose network receive or transmit rate,
+	// read from the kubelet, exceeds a threshold
+	// +optional
+	NetworkIO *NetworkIOConfig `json:"networkIO,omitempty"`
+
+	// Schedules capture the tracked pods at the times of cron expressions,
+	// e.g. ahead of a nightly batch job or at a daily traffic peak
+	// +optional
+	Schedules []CaptureSchedule `json:"schedules,omitempty"`
+
+	// Webhook captures the pods named by the alerts posted to the config's
+	// webhook on the HTTP API, e.g. by Alertmanager
+	// +optional
+	Webhook *WebhookTriggerConfig `json:"webhook,omitempty"`
+}
+
+// CaptureSchedule captures the tracked pods of a config on a cron schedule
+type CaptureSchedule struct {
+	// Cron is a five-field cron expression (minute, hour, day of month, month
+	// and day of week) evaluated in UTC, e.g. "0 3 * * *" every day at 03:00
+	// +kubebuilder:validation:MinLength=1
+	Cron string `json:"cron"`
+
+	// MaxPods caps the pods captured per run, by pod name. 0 captures every
+	// tracked pod.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxPods int `json:"maxPods,omitempty"`
+}
+
+// WebhookTriggerConfig defines which alerts posted to the webhook of a config
+// capture the pods they name
+type WebhookTriggerConfig struct {
+	// Alerts are the names of the alerts captured, every firing alert if empty
+	// +optional
+	Alerts []string `json:"alerts,omitempty"`
+}
+
+// EventTriggerTarget selects the pods captured for an Event
+type EventTriggerTarget string
+
+const (
+	// EventTargetPod captures the pod the Event is about, or the tracked pods
+	// of the node for Events about a node
+	EventTargetPod EventTriggerTarget = "Pod"
+
+	// EventTargetSiblings captures other tracked pods of the workload of the
+	// pod the Event is about, for pods that can no longer be profiled
+	EventTargetSiblings EventTriggerTarget = "Siblings"
+)
+
+// EventTrigger maps the reason of Kubernetes Events to captures
+type EventTrigger struct {
+	// Reason of the Events, e.g. BackOff, FailedMount or Evicted for pods,
+	// or OOMKilling reported for nodes by the node problem detector
+	// +kubebuilder:validation:MinLength=1
+	Reason string `json:"reason"`
+
+	// Target selects the pods captured. Pod captures the pod the Event is
+	// about, Siblings the other pods of its workload, e.g. for evicted pods.
+	// Events about nodes capture the tracked pods of the node either way.
+	// +kubebuilder:validation:Enum=Pod;Siblings
+	// +kubebuilder:default=Pod
+	// +optional
+	Target EventTriggerTarget `json:"target,omitempty"`
+
+	// MaxPods caps the pods captured per Event
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxPods int `json:"maxPods,omitempty"`
+}
+
+// HPAScaleOutConfig defines which scale-outs of a HorizontalPodAutoscaler
+// trigger a capture of the pods of its workload
+type HPAScaleOutConfig struct {
+	// MinIncreasePercent is how much the desired replicas must grow within
+	// windowSeconds to count as a rapid scale-out, in percent of the fewest
+	// replicas desired in the window. 0 only captures at maxReplicas.
+	// +kubebuilder:default=50
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinIncreasePercent int `json:"minIncreasePercent,omitempty"`
+
+	// WindowSeconds is the window the growth of the replicas is measured over
+	// +kubebuilder:default=300
+	// +kubebuilder:validation:Minimum=30
+	// +optional
+	WindowSeconds int `json:"windowSeconds,omitempty"`
+
+	// OnMaxReplicas captures when the HPA reaches its maxReplicas and can no
+	// longer scale out
+	// +kubebuilder:default=true
+	// +optional
+	OnMaxReplicas *bool `json:"onMaxReplicas,omitempty"`
+
+	// MaxPods is how many tracked pods of the workload are captured per
+	// scale-out, the longest running first, as new replicas are still warming up
+	// +kubebuilder:default=2
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxPods int `json:"maxPods,omitempty"`
+
+	// CheckIntervalSeconds is how often the HPAs are read
+	// +kubebuilder:default=30
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	CheckIntervalSeconds int `json:"checkIntervalSeconds,omitempty"`
+}
+
+// EphemeralStorageConfig defines when the ephemeral-storage usage of a pod
+// counts as approaching its limit
+type EphemeralStorageConfig struct {
+	// ThresholdPercent is the usage, in percent of the pod's ephemeral-storage
+	// limit, above which the pod is captured. Pods without a limit are not
+	// checked.
+	// +kubebuilder:default=80
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ThresholdPercent int `json:"thresholdPercent,omitempty"`
+
+	// CheckIntervalSeconds is how often the usage is read. Pods above the
+	// threshold are captured at most once per cooldown.
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	CheckIntervalSeconds int `json:"checkIntervalSeconds,omitempty"`
+}
+
+// NetworkIOConfig defines the network byte rates above which a pod is captured.
+// At least one of the rates is required.
+type NetworkIOConfig struct {
+	// ReceiveBytesPerSecond is the rate of bytes received by the pod above
+	// which it is captured
+	// +optional
+	ReceiveBytesPerSecond *resource.Quantity `json:"receiveBytesPerSecond,omitempty"`
+
+	// TransmitBytesPerSecond is the rate of bytes transmitted by the pod above
+	// which it is captured
+	// +optional
+	TransmitBytesPerSecond *resource.Quantity `json:"transmitBytesPerSecond,omitempty"`
+
+	// CheckIntervalSeconds is how often the network counters are read. The
+	// rates are averaged between two reads, and pods above a threshold are
+	// captured at most once per cooldown.
+	// +kubebuilder:default=30
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	CheckIntervalSeconds int `json:"checkIntervalSeconds,omitempty"`
+}
+
+// VPADriftConfig defines when usage counts as drifting from the target a
+// VerticalPodAutoscaler recommends for a container
+type VPADriftConfig struct {
+	// FactorPercent is how far usage may diverge from the recommended target
+	// before the pod is captured, in percent: 200 captures a container using
+	// more than twice or less than half its target
+	// +kubebuilder:default=200
+	// +kubebuilder:validation:Minimum=101
+	// +optional
+	FactorPercent int `json:"factorPercent,omitempty"`
+
+	// Resources are the resources compared to their recommendations
+	// +kubebuilder:default={"cpu","memory"}
+	// +optional
+	Resources []VPADriftResource `json:"resources,omitempty"`
+
+	// CheckIntervalSeconds is how often usage is compared to the
+	// recommendations. Pods drifting are captured at most once per cooldown.
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	CheckIntervalSeconds int `json:"checkIntervalSeconds,omitempty"`
+}
+
+// VPADriftResource names a resource compared to its VPA recommendation
+// +kubebuilder:validation:Enum=cpu;memory
+type VPADriftResource string
+
+const (
+	// VPADriftCPU compares CPU usage to the recommended CPU
+	VPADriftCPU VPADriftResource = "cpu"
+
+	// VPADriftMemory compares memory usage to the recommended memory
+	VPADriftMemory VPADriftResource = "memory"
+)
+
+// ExecHooksConfig defines the commands executed in the target container
+// around each capture
+type ExecHooksConfig struct {
+	// PreCapture commands run in order before the profiles are captured, e.g.
+	// to force a garbage collection or enable debug logging
+	// +optional
+	PreCapture []ExecHook `json:"preCapture,omitempty"`
+
+	// PostCapture commands run in order once the profiles are captured, e.g.
+	// to undo what the pre-capture commands changed. They also run when the
+	// capture fails after any pre-capture command ran.
+	// +optional
+	PostCapture []ExecHook `json:"postCapture,omitempty"`
+}
+
+// ExecHook is a command executed in the target container
+type ExecHook struct {
+	// Command is executed without a shell; use ["sh", "-c", "..."] for shell syntax
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+
+	// Container to execute the command in. Defaults to the pod's first container.
+	// +optional
+	Container string `json:"container,omitempty"`
+
+	// TimeoutSeconds bounds how long the command may run
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=300
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// FailurePolicy controls what a failed or timed out command does. Ignore
+	// logs the failure and goes on with the capture, Fail fails the capture.
+	// +kubebuilder:validation:Enum=Ignore;Fail
+	// +kubebuilder:default=Ignore
+	// +optional
+	FailurePolicy ExecHookFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// ExecHookFailurePolicy describes how failed exec hooks are handled
+type ExecHookFailurePolicy string
+
+const (
+	// ExecHookIgnore logs the failure and goes on with the capture
+	ExecHookIgnore ExecHookFailurePolicy = "Ignore"
+
+	// ExecHookFail fails the capture
+	ExecHookFail ExecHookFailurePolicy = "Fail"
+)
+
+// AggregationConfig defines how profiles are merged across replicas
+type AggregationConfig struct {
+	// WindowSeconds is the length of the windows profiles are merged over
+	// +kubebuilder:default=3600
+	// +kubebuilder:validation:Minimum=300
+	// +optional
+	WindowSeconds int `json:"windowSeconds,omitempty"`
+
+	// Daily also merges the windows of each day into a daily rollup
+	// +optional
+	Daily bool `json:"daily,omitempty"`
+
+	// Retention deletes captures and rollups once they are older than the
+	// given number of days
+	// +optional
+	Retention *RetentionConfig `json:"retention,omitempty"`
+}
+
+// RetentionConfig defines how many days captures and rollups are kept for.
+// Zero keeps them forever.
+type RetentionConfig struct {
+	// CaptureDays is how long individual captures are kept
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CaptureDays int `json:"captureDays,omitempty"`
+
+	// WindowDays is how long the profiles merged per window are kept
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WindowDays int `json:"windowDays,omitempty"`
+
+	// DailyDays is how long daily rollups are kept
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DailyDays int `json:"dailyDays,omitempty"`
+}
+
+// LeakDetectionConfig defines when growth across captures is reported as a leak
+type LeakDetectionConfig struct {
+	// Captures is the number of consecutive captures of a pod a stack must grow
+	// across to be reported
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=2
+	// +optional
+	Captures int `json:"captures,omitempty"`
+
+	// MinHeapGrowth is the in-use memory an allocation site must gain over the
+	// captures to be reported
+	// +kubebuilder:default="1Mi"
+	// +optional
+	MinHeapGrowth *resource.Quantity `json:"minHeapGrowth,omitempty"`
+
+	// MinGoroutineGrowth is the number of goroutines a stack must gain over
+	// the captures to be reported
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinGoroutineGrowth int `json:"minGoroutineGrowth,omitempty"`
+}
+
+// BaselineConfig references the known-good profiles captures are compared against
+type BaselineConfig struct {
+	// Bucket holds the baseline profiles. Defaults to the S3 config bucket.
+	// +optional
+	Bucket string `json:"bucket,omitempty"`
+
+	// Profiles are the baseline profiles, at most one per profile type.
+	// Captured profile types without a baseline are not compared.
+	// +kubebuilder:validation:MinItems=1
+	Profiles []BaselineProfile `json:"profiles"`
+
+	// RegressionThresholdPercent is the growth of a profile over its baseline
+	// above which a capture is reported as a regression. CPU profiles are
+	// compared per second of profiling.
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RegressionThresholdPercent int `json:"regressionThresholdPercent,omitempty"`
+}
+
+// BaselineProfile references the baseline profile of a profile type
+type BaselineProfile struct {
+	// Type is the profile type, e.g. cpu
+	Type string `json:"type"`
+
+	// Key is the S3 key of the baseline profile
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
+// NotificationConfig defines the notification sinks of a config
+type NotificationConfig struct {
+	// Slack posts a message for every successful threshold capture
+	// +optional
+	Slack *SlackNotification `json:"slack,omitempty"`
+
+	// Webhooks receive a JSON event for every capture attempt
+	// +optional
+	Webhooks []WebhookNotification `json:"webhooks,omitempty"`
+
+	// SNS publishes an event to a topic for every successful capture
+	// +optional
+	SNS *SNSNotification `json:"sns,omitempty"`
+
+	// SQS sends an event to a queue for every successful capture
+	// +optional
+	SQS *SQSNotification `json:"sqs,omitempty"`
+
+	// Kafka produces an event to a topic for every capture attempt
+	// +optional
+	Kafka *KafkaNotification `json:"kafka,omitempty"`
+
+	// Grafana annotates dashboards with every successful capture
+	// +optional
+	Grafana *GrafanaNotification `json:"grafana,omitempty"`
+
+	// Issues files an issue for successful threshold captures, at most one per
+	// service per interval
+	// +optional
+	Issues *IssueNotification `json:"issues,omitempty"`
+
+	// Email sends a summary of every successful threshold capture
+	// +optional
+	Email *EmailNotification `json:"email,omitempty"`
+
+	// Splunk forwards an event to an HTTP Event Collector for every capture attempt
+	// +optional
+	Splunk *SplunkNotification `json:"splunk,omitempty"`
+}
+
+// SplunkNotification defines a Splunk HTTP Event Collector receiving capture events
+type SplunkNotification struct {
+	// URL is the base URL of the collector, e.g. https://splunk.example.com:8088
+	// +kubebuilder:validation:Pattern=`^https://`
+	URL string `json:"url"`
+
+	// TokenSecretRef references the Secret holding the HEC token. The key
+	// defaults to token.
+	TokenSecretRef SecretKeyRef `json:"tokenSecretRef"`
+
+	// Index the events are stored in, the token's default index if unset
+	// +optional
+	Index string `json:"index,omitempty"`
+
+	// Source of the events
+	// +kubebuilder:default=bolometer
+	// +optional
+	Source string `json:"source,omitempty"`
+
+	// SourceType of the events
+	// +kubebuilder:default="bolometer:capture"
+	// +optional
+	SourceType string `json:"sourceType,omitempty"`
+}
+
+// EmailNotification defines the recipients of capture emails
+type EmailNotification struct {
+	// SMTPSecretName names the Secret holding the SMTP server as host:port
+	// under server, and optionally the username and password under username
+	// and password
+	// +kubebuilder:validation:MinLength=1
+	SMTPSecretName string `json:"smtpSecretName"`
+
+	// From is the sender address
+	From string `json:"from"`
+
+	// To are the recipient addresses
+	// +kubebuilder:validation:MinItems=1
+	To []string `json:"to"`
+}
+
+// IssueTracker names an issue tracker
+type IssueTracker string
+
+const (
+	// IssueTrackerGitHub files GitHub issues
+	IssueTrackerGitHub IssueTracker = "GitHub"
+
+	// IssueTrackerGitLab files GitLab issues
+	IssueTrackerGitLab IssueTracker = "GitLab"
+
+	// IssueTrackerJira files Jira issues
+	IssueTrackerJira IssueTracker = "Jira"
+)
+
+// IssueNotification defines where issues are filed for captures
+type IssueNotification struct {
+	// Tracker is the issue tracker
+	// +kubebuilder:validation:Enum=GitHub;GitLab;Jira
+	Tracker IssueTracker `json:"tracker"`
+
+	// URL is the API base URL of the tracker. Defaults to https://api.github.com
+	// for GitHub and https://gitlab.com for GitLab, required for Jira.
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Project is the owner/repo on GitHub, the project ID or path on GitLab
+	// and the project key on Jira
+	// +kubebuilder:validation:MinLength=1
+	Project string `json:"project"`
+
+	// TokenSecretRef references the Secret holding the API token. Jira Cloud
+	// tokens are given as email:token. The key defaults to token.
+	TokenSecretRef SecretKeyRef `json:"tokenSecretRef"`
+
+	// IssueType is the type of Jira issues
+	// +kubebuilder:default=Bug
+	// +optional
+	IssueType string `json:"issueType,omitempty"`
+
+	// Labels are applied to every issue
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+
+	// MinIntervalSeconds is the minimum time between two issues filed for the
+	// same service
+	// +kubebuilder:default=86400
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	MinIntervalSeconds int `json:"minIntervalSeconds,omitempty"`
+}
+
+// GrafanaNotification defines a Grafana receiving capture annotations
+type GrafanaNotification struct {
+	// URL is the base URL of Grafana
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// TokenSecretRef references the Secret holding a service account token
+	// allowed to write annotations. The key defaults to token.
+	TokenSecretRef SecretKeyRef `json:"tokenSecretRef"`
+
+	// DashboardUID limits the annotations to a dashboard. Annotations show on
+	// every dashboard querying their tags if unset.
+	// +optional
+	DashboardUID string `json:"dashboardUID,omitempty"`
+
+	// Tags are added to the service, namespace and reason tags of every annotation
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+}
+
+// KafkaFormat is the encoding of Kafka message values
+type KafkaFormat string
+
+const (
+	// KafkaFormatJSON encodes events as plain JSON
+	KafkaFormatJSON KafkaFormat = "JSON"
+
+	// KafkaFormatJSONWithSchema wraps events in the schema/payload envelope
+	// read by the Kafka Connect JsonConverter with schemas enabled
+	KafkaFormatJSONWithSchema KafkaFormat = "JSONWithSchema"
+)
+
+// KafkaNotification defines a Kafka topic receiving capture events
+type KafkaNotification struct {
+	// Brokers are the bootstrap brokers, as host:port
+	// +kubebuilder:validation:MinItems=1
+	Brokers []string `json:"brokers"`
+
+	// Topic the events are produced to. Messages are keyed by pod.
+	// +kubebuilder:validation:MinLength=1
+	Topic string `json:"topic"`
+
+	// Format of the message values
+	// +kubebuilder:validation:Enum=JSON;JSONWithSchema
+	// +kubebuilder:default=JSON
+	// +optional
+	Format KafkaFormat `json:"format,omitempty"`
+
+	// TLS connects to the brokers over TLS, verified against the system roots
+	// +optional
+	TLS bool `json:"tls,omitempty"`
+
+	// SASL authenticates to the brokers. No authentication if unset.
+	// +optional
+	SASL *KafkaSASL `json:"sasl,omitempty"`
+}
+
+// KafkaSASL defines the SASL credentials of a Kafka client
+type KafkaSASL struct {
+	// Mechanism is the SASL mechanism
+	// +kubebuilder:validation:Enum=PLAIN;SCRAM-SHA-256;SCRAM-SHA-512
+	// +kubebuilder:default=SCRAM-SHA-512
+	// +optional
+	Mechanism string `json:"mechanism,omitempty"`
+
+	// UsernameSecretRef references the Secret holding the username. The key
+	// defaults to username.
+	UsernameSecretRef SecretKeyRef `json:"usernameSecretRef"`
+
+	// PasswordSecretRef references the Secret holding the password. The key
+	// defaults to password.
+	PasswordSecretRef SecretKeyRef `json:"passwordSecretRef"`
+}
+
+// SNSNotification defines an SNS topic receiving capture events
+type SNSNotification struct {
+	// TopicARN is the ARN of the topic. FIFO topics get the config as message group.
+	// +kubebuilder:validation:Pattern=`^arn:[^:]+:sns:`
+	TopicARN string `json:"topicARN"`
+
+	// Region of the topic, defaults to the S3 region
+	// +optional
+	Region string `json:"region,omitempty"`
+}
+
+// SQSNotification defines an SQS queue receiving capture events
+type SQSNotification struct {
+	// QueueURL is the URL of the queue. FIFO queues get the config as message group.
+	// +kubebuilder:validation:Pattern=`^https://`
+	QueueURL string `json:"queueURL"`
+
+	// Region of the queue, defaults to the S3 region
+	// +optional
+	Region string `json:"region,omitempty"`
+}
+
+// SlackNotification defines a Slack incoming webhook
+type SlackNotification struct {
+	// WebhookSecretRef references the Secret holding the webhook URL. The key
+	// defaults to webhookURL.
+	WebhookSecretRef SecretKeyRef `json:"webhookSecretRef"`
+
+	// PresignExpirySeconds links the profiles with presigned URLs valid for this
+	// long. Profiles are linked in the S3 console if 0.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=604800
+	// +optional
+	PresignExpirySeconds int `json:"presignExpirySeconds,omitempty"`
+}
+
+// WebhookNotification defines an HTTPS endpoint receiving capture events
+type WebhookNotification struct {
+	// URL is the HTTPS endpoint the events are posted to
+	// +kubebuilder:validation:Pattern=`^https://`
+	URL string `json:"url"`
+
+	// SigningSecretRef references the Secret holding the key the events are
+	// signed with, sent as an HMAC-SHA256 in the X-Bolometer-Signature header.
+	// The key defaults to signingKey. Events are unsigned if unset.
+	// +optional
+	SigningSecretRef *SecretKeyRef `json:"signingSecretRef,omitempty"`
+
+	// PresignExpirySeconds links the profiles with presigned URLs valid for this
+	// long. Profiles are linked in the S3 console if 0.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=604800
+	// +optional
+	PresignExpirySeconds int `json:"presignExpirySeconds,omitempty"`
+}
+
+// ClusterTarget defines a remote cluster whose pods are profiled
+type ClusterTarget struct {
+	// Name identifies the cluster in pod keys, status and audit records
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// KubeconfigSecretRef references the Secret, in the config's namespace,
+	// holding the kubeconfig of the cluster. The key defaults to kubeconfig.
+	KubeconfigSecretRef SecretKeyRef `json:"kubeconfigSecretRef"`
+}
+
+// SecretKeyRef references a key of a Secret in the same namespace
+type SecretKeyRef struct {
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// Key of the Secret holding the data, defaulted by each reference
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// NodePressurePolicy describes how captures on pressured nodes are handled
+type NodePressurePolicy string
+
+const (
+	// NodePressureIgnore captures regardless of node conditions
+	NodePressureIgnore NodePressurePolicy = "Ignore"
+
+	// NodePressureSkip drops the capture and starts the cooldown
+	NodePressureSkip NodePressurePolicy = "Skip"
+
+	// NodePressureDefer drops the capture and retries on the next check
+	NodePressureDefer NodePressurePolicy = "Defer"
+)
+
+// StorageBudgetAction describes how captures are handled once the storage
+// budget of a config is exceeded
+type StorageBudgetAction string
+
+const (
+	// StorageBudgetSkip drops the capture and starts the cooldown
+	StorageBudgetSkip StorageBudgetAction = "Skip"
+
+	// StorageBudgetHeapOnly captures the heap profile only, if the capture takes it
+	StorageBudgetHeapOnly StorageBudgetAction = "HeapOnly"
+)
+
+// StorageBudget defines the bytes the captures of a config may store
+type StorageBudget struct {
+	// MaxStorageBytes caps the bytes stored under the part of the prefix shared
+	// by the config's objects. The prefix is measured every hour and uploads
+	// are counted in between; configs sharing the prefix share its usage.
+	// 0 disables the cap.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxStorageBytes int64 `json:"maxStorageBytes,omitempty"`
+
+	// MaxDailyUploadBytes caps the bytes of profiles the config uploads per
+	// UTC day. 0 disables the cap.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDailyUploadBytes int64 `json:"maxDailyUploadBytes,omitempty"`
+
+	// Action is what happens to captures once a cap is reached. Skip drops them
+	// and starts the cooldown, HeapOnly captures the heap profile only, and
+	// drops captures whose profile types do not include heap.
+	// +kubebuilder:validation:Enum=Skip;HeapOnly
+	// +kubebuilder:default=Skip
+	// +optional
+	Action StorageBudgetAction `json:"action,omitempty"`
+}
+
+// DeduplicationMode describes how profiles are compared for deduplication
+type DeduplicationMode string
+
+const (
+	// DeduplicationBytes compares the bytes of the profiles
+	DeduplicationBytes DeduplicationMode = "Bytes"
+
+	// DeduplicationSamples compares the stacks and values of the samples of
+	// the profiles
+	DeduplicationSamples DeduplicationMode = "Samples"
+)
+
+// DeduplicationConfig defines when a profile counts as a duplicate of the
+// previous one of the pod
+type DeduplicationConfig struct {
+	// Mode is how profiles are compared. Bytes only matches byte-identical
+	// profiles. Samples matches profiles with the same stacks and values,
+	// ignoring timestamps, durations and addresses, e.g. the goroutine
+	// profiles of an idle pod; traces and profiles that cannot be parsed are
+	// compared by bytes.
+	// +kubebuilder:validation:Enum=Bytes;Samples
+	// +kubebuilder:default=Bytes
+	// +optional
+	Mode DeduplicationMode `json:"mode,omitempty"`
+
+	// MaxAgeSeconds is how long a stored profile is referred to by its
+	// duplicates. An unchanged profile is stored again once it is older, so
+	// keep it below the retention and lifecycle expiration of the objects.
+	// +kubebuilder:default=21600
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	MaxAgeSeconds int `json:"maxAgeSeconds,omitempty"`
+}
+
+// PrometheusConfig defines how to read usage metrics from Prometheus
+type PrometheusConfig struct {
+	// URL is the base URL of the Prometheus HTTP API
+	URL string `json:"url"`
+
+	// RateWindowSeconds is the range used to compute CPU usage rates
+	// +kubebuilder:default=300
+	// +kubebuilder:validation:Minimum=30
+	// +optional
+	RateWindowSeconds int `json:"rateWindowSeconds,omitempty"`
 }
 
 // PodSelector defines how to select target pods for profiling
@@ -34,34 +902,129 @@ type PodSelector struct {
 	// LabelSelector to filter pods
 	// +optional
 	LabelSelector map[string]string `json:"labelSelector,omitempty"`
+
+	// Service selects the pods serving a Service in the selector's namespace:
+	// those its EndpointSlices list as ready, leaving out pods taken out of
+	// rotation. LabelSelector further narrows them when set.
+	// +optional
+	Service string `json:"service,omitempty"`
+
+	// RequireReady only selects pods whose Ready condition is true. Set it to
+	// false to also profile pods failing their readiness probes.
+	// +kubebuilder:default=true
+	// +optional
+	RequireReady *bool `json:"requireReady,omitempty"`
 }
 
 // ThresholdConfig defines resource thresholds for triggering profiling
 type ThresholdConfig struct {
-	// CPUThresholdPercent is the CPU usage percentage threshold (0-100)
-	// +kubebuilder:default=80
+	// CPUThresholdPercent is the CPU usage percentage threshold (0-100).
+	// Defaults to 80 unless BolometerSettings sets another default.
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=100
 	CPUThresholdPercent int `json:"cpuThresholdPercent,omitempty"`
 
-	// MemoryThresholdPercent is the memory usage percentage threshold (0-100)
-	// +kubebuilder:default=90
+	// MemoryThresholdPercent is the memory usage percentage threshold (0-100).
+	// Defaults to 90 unless BolometerSettings sets another default.
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=100
 	MemoryThresholdPercent int `json:"memoryThresholdPercent,omitempty"`
 
-	// CheckIntervalSeconds is how often to check metrics
-	// +kubebuilder:default=30
+	// CheckIntervalSeconds is how often to check metrics.
+	// Defaults to 30 unless BolometerSettings sets another default.
 	// +kubebuilder:validation:Minimum=10
 	CheckIntervalSeconds int `json:"checkIntervalSeconds,omitempty"`
 
 	// CooldownSeconds is the cooldown period after capturing a profile
-	// to avoid capturing too frequently
-	// +kubebuilder:default=300
+	// to avoid capturing too frequently.
+	// Defaults to 300 unless BolometerSettings sets another default.
 	// +kubebuilder:validation:Minimum=60
 	CooldownSeconds int `json:"cooldownSeconds,omitempty"`
+
+	// PerContainer evaluates thresholds against each container individually
+	// instead of the pod-wide aggregate
+	// +optional
+	PerContainer bool `json:"perContainer,omitempty"`
+
+	// Containers limits per-container evaluation to the named containers.
+	// If empty, all containers are evaluated. Setting this implies perContainer.
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+
+	// AveragingWindowSeconds evaluates thresholds against the average of the
+	// samples collected over this window instead of a single sample.
+	// 0 disables averaging.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	AveragingWindowSeconds int `json:"averagingWindowSeconds,omitempty"`
+
+	// MaxCheckIntervalSeconds enables an adaptive check interval. While all pods
+	// are far below their thresholds the interval backs off up to this value, and
+	// it returns to checkIntervalSeconds as usage approaches the thresholds.
+	// 0 disables adaptation.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCheckIntervalSeconds int `json:"maxCheckIntervalSeconds,omitempty"`
+
+	// Conditions replace the CPU and memory thresholds with combined
+	// conditions: a pod is captured when all the comparisons of any group
+	// hold. Conditions are evaluated against the pod-wide usage.
+	// +optional
+	Conditions []ConditionGroup `json:"conditions,omitempty"`
+}
+
+// ConditionGroup is a set of comparisons that must all hold. Groups are
+// combined with OR, so any condition of ANDs and ORs can be written as a list
+// of groups.
+type ConditionGroup struct {
+	// All are the comparisons of the group
+	// +kubebuilder:validation:MinItems=1
+	All []MetricCondition `json:"all"`
 }
 
+// MetricCondition compares a metric of a pod to a value
+type MetricCondition struct {
+	// Metric is the compared metric: the CPU or memory usage in percent of
+	// the requests, or the number of goroutines, counted through the pod's
+	// pprof endpoint
+	Metric ConditionMetric `json:"metric"`
+
+	// Operator compares the metric to the value
+	// +kubebuilder:default=GreaterThan
+	// +optional
+	Operator ConditionOperator `json:"operator,omitempty"`
+
+	// Value is the value the metric is compared to
+	Value int64 `json:"value"`
+}
+
+// ConditionMetric names a metric compared by a condition
+// +kubebuilder:validation:Enum=cpuPercent;memoryPercent;goroutines
+type ConditionMetric string
+
+const (
+	// ConditionCPUPercent is the CPU usage in percent of the CPU requests
+	ConditionCPUPercent ConditionMetric = "cpuPercent"
+
+	// ConditionMemoryPercent is the memory usage in percent of the memory requests
+	ConditionMemoryPercent ConditionMetric = "memoryPercent"
+
+	// ConditionGoroutines is the number of goroutines of the pod
+	ConditionGoroutines ConditionMetric = "goroutines"
+)
+
+// ConditionOperator compares a metric to a value
+// +kubebuilder:validation:Enum=GreaterThan;LessThan
+type ConditionOperator string
+
+const (
+	// ConditionGreaterThan holds when the metric is above the value
+	ConditionGreaterThan ConditionOperator = "GreaterThan"
+
+	// ConditionLessThan holds when the metric is below the value
+	ConditionLessThan ConditionOperator = "LessThan"
+)
+
 // OnDemandConfig defines on-demand continuous profiling settings
 type OnDemandConfig struct {
 	// Enabled indicates whether on-demand profiling is enabled
@@ -72,27 +1035,256 @@ type OnDemandConfig struct {
 	// +kubebuilder:validation:Minimum=30
 	// +kubebuilder:validation:Maximum=60
 	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+
+	// ReplicasPerService limits how many replicas of each service are profiled
+	// on each tick. Replicas are taken in turn so every replica is profiled over
+	// successive ticks. 0 profiles every replica.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ReplicasPerService int `json:"replicasPerService,omitempty"`
 }
 
 // S3Configuration defines S3 upload settings
 type S3Configuration struct {
 	// Bucket is the S3 bucket name
-	Bucket string `json:"bucket"`
+	// +optional
+	Bucket string `json:"bucket,omitempty"`
 
-	// Prefix is the S3 key prefix for uploaded profiles
+	// Prefix is the S3 key prefix for uploaded profiles. The placeholders
+	// {namespace}, {service}, {cluster} and {configName} are rendered for each
+	// capture, so one convention can keep the profiles of tenants apart.
 	// +optional
 	Prefix string `json:"prefix,omitempty"`
 
 	// Region is the AWS region
-	Region string `json:"region"`
+	// +optional
+	Region string `json:"region,omitempty"`
 
 	// Endpoint is a custom S3 endpoint (for S3-compatible services)
 	// +optional
 	Endpoint string `json:"endpoint,omitempty"`
+
+	// PresignExpirySeconds presigns download URLs of the profiles of every
+	// capture, valid for this many seconds, so they can be fetched without
+	// bucket credentials. The URLs are recorded in the ProfileCapture status
+	// and its events, and linked from notifications that presign none of
+	// their own. Disabled if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=604800
+	// +optional
+	PresignExpirySeconds int `json:"presignExpirySeconds,omitempty"`
+
+	// AccessCheck probes the bucket when the config is reconciled and reports
+	// the result in the StorageReady condition, so a missing bucket or missing
+	// permissions show up before a capture is lost. Read checks the bucket
+	// with HeadBucket, Write also uploads and deletes a small object under the
+	// prefix. Disabled if unset.
+	// +kubebuilder:validation:Enum=Read;Write
+	// +optional
+	AccessCheck StorageAccessCheck `json:"accessCheck,omitempty"`
+
+	// CABundleRef references a ConfigMap key in the config's namespace holding
+	// PEM certificates trusted for the endpoint next to the system roots, for
+	// S3-compatible services behind a private CA. The key defaults to ca.crt.
+	// +optional
+	CABundleRef *ConfigMapKeyRef `json:"caBundleRef,omitempty"`
+
+	// ProxyURL is the HTTP proxy requests to the endpoint go through. Defaults
+	// to the HTTPS_PROXY and NO_PROXY environment of the operator.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// Accelerate sends requests to the Transfer Acceleration endpoint of the
+	// bucket, which must have acceleration enabled. Not supported with a
+	// custom endpoint or path-style addressing.
+	// +optional
+	Accelerate bool `json:"accelerate,omitempty"`
+
+	// DualStack sends requests to the dual-stack IPv4 and IPv6 endpoint of the
+	// region
+	// +optional
+	DualStack bool `json:"dualStack,omitempty"`
+
+	// ForcePathStyle addresses the bucket in the request path rather than the
+	// host name. Defaults to true with a custom endpoint, false on AWS.
+	// +optional
+	ForcePathStyle *bool `json:"forcePathStyle,omitempty"`
+
+	// RetryMode of failed requests. Adaptive also slows the client down while
+	// the service throttles it. Defaults to Standard.
+	// +kubebuilder:validation:Enum=Standard;Adaptive
+	// +optional
+	RetryMode S3RetryMode `json:"retryMode,omitempty"`
+
+	// MaxAttempts is the number of attempts of each request, including the
+	// first. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=20
+	// +optional
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+
+	// Gallery keeps an index.html and index.json in the directory of each
+	// service and day, listing its captures with links to their profiles and
+	// to flamegraph pages stored next to them, so a static website endpoint of
+	// the bucket browses the captures. Links are presigned when
+	// presignExpirySeconds is set.
+	// +optional
+	Gallery bool `json:"gallery,omitempty"`
+
+	// Lifecycle has the operator maintain an S3 lifecycle rule for the
+	// objects under the prefix, moving them to a cheaper storage class and
+	// expiring them after a number of days. Other rules of the bucket are
+	// kept. Removing the block removes the rule.
+	// +optional
+	Lifecycle *LifecycleConfig `json:"lifecycle,omitempty"`
+
+	// WebIdentity has the operator reach the bucket with an IAM role assumed
+	// with a token of a service account in the config's namespace, like IRSA
+	// does for the tenant's own pods, instead of the operator's identity
+	// +optional
+	WebIdentity *WebIdentityConfig `json:"webIdentity,omitempty"`
+
+	// CircuitBreaker stops automatic captures from uploading to a destination
+	// failing repeatedly, probing it at growing intervals until it recovers
+	// +optional
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
+}
+
+// WebIdentityConfig defines the IAM role assumed with the tokens of a service
+// account in the config's namespace
+type WebIdentityConfig struct {
+	// ServiceAccountName is the service account in the config's namespace
+	// whose tokens are exchanged for credentials of the role
+	// +kubebuilder:validation:MinLength=1
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// RoleARN is the IAM role assumed. Defaults to the
+	// eks.amazonaws.com/role-arn annotation of the service account.
+	// +optional
+	RoleARN string `json:"roleARN,omitempty"`
+
+	// Audience of the tokens, which the role's trust policy must accept.
+	// Defaults to sts.amazonaws.com.
+	// +optional
+	Audience string `json:"audience,omitempty"`
+}
+
+// CircuitBreakerAction describes what happens to the automatic captures of a
+// config while the circuit breaker of its destination is open
+type CircuitBreakerAction string
+
+const (
+	// CircuitBreakerPause skips the captures
+	CircuitBreakerPause CircuitBreakerAction = "Pause"
+
+	// CircuitBreakerQueue holds the captures back, the latest of each pod,
+	// and runs them once the destination recovers
+	CircuitBreakerQueue CircuitBreakerAction = "Queue"
+)
+
+// CircuitBreakerConfig defines when uploads to a destination are stopped and
+// how it is probed for recovery. The destination is the bucket and endpoint,
+// shared by the configs uploading there with the same identity.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of uploads in a row that fail before the
+	// circuit opens
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// Action is what happens to automatic captures while the circuit is open.
+	// Captures created as ProfileCaptures always run.
+	// +kubebuilder:validation:Enum=Pause;Queue
+	// +kubebuilder:default=Pause
+	// +optional
+	Action CircuitBreakerAction `json:"action,omitempty"`
+
+	// ProbeIntervalSeconds is how long the circuit stays open before the
+	// destination is probed, doubled after every failed probe
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProbeIntervalSeconds int `json:"probeIntervalSeconds,omitempty"`
+
+	// MaxProbeIntervalSeconds caps the interval between probes
+	// +kubebuilder:default=1800
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxProbeIntervalSeconds int `json:"maxProbeIntervalSeconds,omitempty"`
+
+	// MaxQueuedCaptures caps the captures of the config held back by the
+	// Queue action, dropping the oldest
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxQueuedCaptures int `json:"maxQueuedCaptures,omitempty"`
+}
+
+// LifecycleConfig defines the S3 lifecycle rule of the objects of a config
+type LifecycleConfig struct {
+	// TransitionDays moves objects to TransitionStorageClass this many days
+	// after their upload. Disabled if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TransitionDays int `json:"transitionDays,omitempty"`
+
+	// TransitionStorageClass is the storage class objects move to
+	// +kubebuilder:validation:Enum=STANDARD_IA;ONEZONE_IA;INTELLIGENT_TIERING;GLACIER_IR;GLACIER;DEEP_ARCHIVE
+	// +kubebuilder:default=STANDARD_IA
+	// +optional
+	TransitionStorageClass string `json:"transitionStorageClass,omitempty"`
+
+	// ExpirationDays deletes objects this many days after their upload.
+	// Disabled if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ExpirationDays int `json:"expirationDays,omitempty"`
 }
 
+// S3RetryMode selects how failed S3 requests are retried
+type S3RetryMode string
+
+const (
+	// S3RetryStandard retries with exponential backoff
+	S3RetryStandard S3RetryMode = "Standard"
+
+	// S3RetryAdaptive also rate limits the client while the service throttles
+	S3RetryAdaptive S3RetryMode = "Adaptive"
+)
+
+// ConfigMapKeyRef references a key of a ConfigMap in the same namespace
+type ConfigMapKeyRef struct {
+	// Name of the ConfigMap
+	Name string `json:"name"`
+
+	// Key of the ConfigMap holding the data, defaulted by each reference
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// StorageAccessCheck selects how a bucket is probed before the first capture
+type StorageAccessCheck string
+
+const (
+	// StorageAccessCheckRead checks the bucket exists and can be accessed
+	StorageAccessCheckRead StorageAccessCheck = "Read"
+
+	// StorageAccessCheckWrite also checks objects can be uploaded and deleted
+	StorageAccessCheckWrite StorageAccessCheck = "Write"
+)
+
 // ProfilingConfigStatus defines the observed state of ProfilingConfig
 type ProfilingConfigStatus struct {
+	// ObservedGeneration is the most recent generation acted on by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LifecycleRule is the ID of the S3 lifecycle rule the operator maintains
+	// for the config, kept until the rule is removed from the bucket
+	// +optional
+	LifecycleRule string `json:"lifecycleRule,omitempty"`
+
 	// ActivePods is the number of pods currently being monitored
 	ActivePods int `json:"activePods"`
 
@@ -106,14 +1298,143 @@ type ProfilingConfigStatus struct {
 	// TotalUploads is the total number of successful uploads to S3
 	TotalUploads int64 `json:"totalUploads"`
 
+	// StorageUsage is the usage tracked against the storage budget, with a
+	// budget configured only
+	// +optional
+	StorageUsage *StorageUsage `json:"storageUsage,omitempty"`
+
+	// LastErrorMessage describes the last error met while reconciling or capturing
+	// +optional
+	LastErrorMessage string `json:"lastErrorMessage,omitempty"`
+
+	// LastErrorTime is the time of the last error
+	// +optional
+	LastErrorTime *metav1.Time `json:"lastErrorTime,omitempty"`
+
+	// ProfiledPods lists the pods profiled by this config
+	// +optional
+	ProfiledPods []ProfiledPod `json:"profiledPods,omitempty"`
+
+	// Conflicts lists the pods this config selects together with other configs
+	// +optional
+	Conflicts []PodConflict `json:"conflicts,omitempty"`
+
 	// Conditions represent the latest available observations of the ProfilingConfig's state
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// StorageUsage is the storage a config used, as tracked against its budget
+type StorageUsage struct {
+	// StoredBytes is the size of the objects under the config's prefix when
+	// last measured plus the bytes uploaded since
+	// +optional
+	StoredBytes int64 `json:"storedBytes,omitempty"`
+
+	// MeasureTime is when the objects under the prefix were last measured
+	// +optional
+	MeasureTime *metav1.Time `json:"measureTime,omitempty"`
+
+	// Day is the UTC day DailyUploadBytes counts, as YYYY-MM-DD
+	// +optional
+	Day string `json:"day,omitempty"`
+
+	// DailyUploadBytes is the bytes of profiles uploaded on Day
+	// +optional
+	DailyUploadBytes int64 `json:"dailyUploadBytes,omitempty"`
+}
+
+// ProfiledPod describes the capture state of a pod profiled by a ProfilingConfig
+type ProfiledPod struct {
+	// Pod is the namespace/name of the pod
+	Pod string `json:"pod"`
+
+	// LastCaptureTime is the time of the last successful capture
+	// +optional
+	LastCaptureTime *metav1.Time `json:"lastCaptureTime,omitempty"`
+
+	// LastTriggerReason is the trigger reason of the last capture attempt
+	// +optional
+	LastTriggerReason string `json:"lastTriggerReason,omitempty"`
+
+	// ConsecutiveFailures is the number of capture attempts that failed since the
+	// last successful capture
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+
+	// InCooldown is true while threshold captures of the pod are held back
+	InCooldown bool `json:"inCooldown"`
+
+	// CooldownStartTime is the time of the last capture that started the
+	// pod's cooldown. It is read back to restore the cooldown after a restart.
+	// +optional
+	CooldownStartTime *metav1.Time `json:"cooldownStartTime,omitempty"`
+
+	// RetryAfter is when captures of the pod are attempted again after failed
+	// captures, unset while the pod is not backed off
+	// +optional
+	RetryAfter *metav1.Time `json:"retryAfter,omitempty"`
+
+	// Quarantined is true once the pod failed too many captures in a row. A
+	// quarantined pod is only retried occasionally until a capture succeeds.
+	// +optional
+	Quarantined bool `json:"quarantined,omitempty"`
+
+	// CPUUsagePercent is the latest CPU usage as a percentage of requests, formatted with two decimals
+	// +optional
+	CPUUsagePercent string `json:"cpuUsagePercent,omitempty"`
+
+	// MemoryUsagePercent is the latest memory usage as a percentage of requests, formatted with two decimals
+	// +optional
+	MemoryUsagePercent string `json:"memoryUsagePercent,omitempty"`
+
+	// UsageTime is when the latest usage was sampled
+	// +optional
+	UsageTime *metav1.Time `json:"usageTime,omitempty"`
+
+	// Pprof is the state of the pod's pprof endpoint, probed when the pod is
+	// first tracked. Unset until the probe completes.
+	// +optional
+	Pprof PprofState `json:"pprof,omitempty"`
+
+	// PprofMessage explains why the pod's pprof endpoint is unreachable or
+	// likely not enabled
+	// +optional
+	PprofMessage string `json:"pprofMessage,omitempty"`
+}
+
+// PprofState describes whether the pprof endpoint of a pod can be reached
+type PprofState string
+
+const (
+	// PprofReachable means the pod served its pprof index
+	PprofReachable PprofState = "Reachable"
+
+	// PprofUnreachable means the pod's pprof endpoint could not be reached, so
+	// its captures are bound to fail
+	PprofUnreachable PprofState = "PprofUnreachable"
+
+	// PprofNotEnabled means the pod's pprof endpoint could not be reached and
+	// the pod suggests the application does not serve pprof, rather than a
+	// network or RBAC problem
+	PprofNotEnabled PprofState = "PprofNotEnabled"
+)
+
+// PodConflict describes a pod selected by more than one ProfilingConfig
+type PodConflict struct {
+	// Pod is the namespace/name of the pod
+	Pod string `json:"pod"`
+
+	// Owner is the namespace/name of the config that profiles the pod
+	Owner string `json:"owner"`
+
+	// Configs are the namespace/name of every config selecting the pod
+	Configs []string `json:"configs"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Namespaced,shortName=pc
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 // +kubebuilder:printcolumn:name="Active Pods",type=integer,JSONPath=`.status.activePods`
 // +kubebuilder:printcolumn:name="Total Profiles",type=integer,JSONPath=`.status.totalProfiles`
 // +kubebuilder:printcolumn:name="Total Uploads",type=integer,JSONPath=`.status.totalUploads`
@@ -10,6 +10,615 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggregationConfig) DeepCopyInto(out *AggregationConfig) {
+	*out = *in
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(RetentionConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggregationConfig.
+func (in *AggregationConfig) DeepCopy() *AggregationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AggregationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BaselineConfig) DeepCopyInto(out *BaselineConfig) {
+	*out = *in
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]BaselineProfile, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaselineConfig.
+func (in *BaselineConfig) DeepCopy() *BaselineConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(BaselineConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BaselineProfile) DeepCopyInto(out *BaselineProfile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaselineProfile.
+func (in *BaselineProfile) DeepCopy() *BaselineProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(BaselineProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BolometerSettings) DeepCopyInto(out *BolometerSettings) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BolometerSettings.
+func (in *BolometerSettings) DeepCopy() *BolometerSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(BolometerSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BolometerSettings) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BolometerSettingsList) DeepCopyInto(out *BolometerSettingsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BolometerSettings, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BolometerSettingsList.
+func (in *BolometerSettingsList) DeepCopy() *BolometerSettingsList {
+	if in == nil {
+		return nil
+	}
+	out := new(BolometerSettingsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BolometerSettingsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BolometerSettingsSpec) DeepCopyInto(out *BolometerSettingsSpec) {
+	*out = *in
+	if in.DefaultS3Config != nil {
+		in, out := &in.DefaultS3Config, &out.DefaultS3Config
+		*out = new(S3Configuration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DefaultThresholds != nil {
+		in, out := &in.DefaultThresholds, &out.DefaultThresholds
+		*out = new(ThresholdDefaults)
+		**out = **in
+	}
+	if in.RateLimits != nil {
+		in, out := &in.RateLimits, &out.RateLimits
+		*out = new(RateLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FeatureGates != nil {
+		in, out := &in.FeatureGates, &out.FeatureGates
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BolometerSettingsSpec.
+func (in *BolometerSettingsSpec) DeepCopy() *BolometerSettingsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BolometerSettingsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BolometerSettingsStatus) DeepCopyInto(out *BolometerSettingsStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BolometerSettingsStatus.
+func (in *BolometerSettingsStatus) DeepCopy() *BolometerSettingsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BolometerSettingsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CaptureSchedule) DeepCopyInto(out *CaptureSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CaptureSchedule.
+func (in *CaptureSchedule) DeepCopy() *CaptureSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(CaptureSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreakerConfig) DeepCopyInto(out *CircuitBreakerConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreakerConfig.
+func (in *CircuitBreakerConfig) DeepCopy() *CircuitBreakerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreakerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterTarget) DeepCopyInto(out *ClusterTarget) {
+	*out = *in
+	out.KubeconfigSecretRef = in.KubeconfigSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterTarget.
+func (in *ClusterTarget) DeepCopy() *ClusterTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionGroup) DeepCopyInto(out *ConditionGroup) {
+	*out = *in
+	if in.All != nil {
+		in, out := &in.All, &out.All
+		*out = make([]MetricCondition, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionGroup.
+func (in *ConditionGroup) DeepCopy() *ConditionGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyRef) DeepCopyInto(out *ConfigMapKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyRef.
+func (in *ConfigMapKeyRef) DeepCopy() *ConfigMapKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeduplicationConfig) DeepCopyInto(out *DeduplicationConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeduplicationConfig.
+func (in *DeduplicationConfig) DeepCopy() *DeduplicationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DeduplicationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailNotification) DeepCopyInto(out *EmailNotification) {
+	*out = *in
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailNotification.
+func (in *EmailNotification) DeepCopy() *EmailNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralStorageConfig) DeepCopyInto(out *EphemeralStorageConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralStorageConfig.
+func (in *EphemeralStorageConfig) DeepCopy() *EphemeralStorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralStorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventTrigger) DeepCopyInto(out *EventTrigger) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventTrigger.
+func (in *EventTrigger) DeepCopy() *EventTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(EventTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecHook) DeepCopyInto(out *ExecHook) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecHook.
+func (in *ExecHook) DeepCopy() *ExecHook {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecHooksConfig) DeepCopyInto(out *ExecHooksConfig) {
+	*out = *in
+	if in.PreCapture != nil {
+		in, out := &in.PreCapture, &out.PreCapture
+		*out = make([]ExecHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostCapture != nil {
+		in, out := &in.PostCapture, &out.PostCapture
+		*out = make([]ExecHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecHooksConfig.
+func (in *ExecHooksConfig) DeepCopy() *ExecHooksConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecHooksConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GrafanaNotification) DeepCopyInto(out *GrafanaNotification) {
+	*out = *in
+	out.TokenSecretRef = in.TokenSecretRef
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GrafanaNotification.
+func (in *GrafanaNotification) DeepCopy() *GrafanaNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(GrafanaNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HPAScaleOutConfig) DeepCopyInto(out *HPAScaleOutConfig) {
+	*out = *in
+	if in.OnMaxReplicas != nil {
+		in, out := &in.OnMaxReplicas, &out.OnMaxReplicas
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HPAScaleOutConfig.
+func (in *HPAScaleOutConfig) DeepCopy() *HPAScaleOutConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HPAScaleOutConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssueNotification) DeepCopyInto(out *IssueNotification) {
+	*out = *in
+	out.TokenSecretRef = in.TokenSecretRef
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssueNotification.
+func (in *IssueNotification) DeepCopy() *IssueNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(IssueNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaNotification) DeepCopyInto(out *KafkaNotification) {
+	*out = *in
+	if in.Brokers != nil {
+		in, out := &in.Brokers, &out.Brokers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SASL != nil {
+		in, out := &in.SASL, &out.SASL
+		*out = new(KafkaSASL)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaNotification.
+func (in *KafkaNotification) DeepCopy() *KafkaNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaSASL) DeepCopyInto(out *KafkaSASL) {
+	*out = *in
+	out.UsernameSecretRef = in.UsernameSecretRef
+	out.PasswordSecretRef = in.PasswordSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaSASL.
+func (in *KafkaSASL) DeepCopy() *KafkaSASL {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaSASL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LeakDetectionConfig) DeepCopyInto(out *LeakDetectionConfig) {
+	*out = *in
+	if in.MinHeapGrowth != nil {
+		in, out := &in.MinHeapGrowth, &out.MinHeapGrowth
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LeakDetectionConfig.
+func (in *LeakDetectionConfig) DeepCopy() *LeakDetectionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LeakDetectionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleConfig) DeepCopyInto(out *LifecycleConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleConfig.
+func (in *LifecycleConfig) DeepCopy() *LifecycleConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricCondition) DeepCopyInto(out *MetricCondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricCondition.
+func (in *MetricCondition) DeepCopy() *MetricCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkIOConfig) DeepCopyInto(out *NetworkIOConfig) {
+	*out = *in
+	if in.ReceiveBytesPerSecond != nil {
+		in, out := &in.ReceiveBytesPerSecond, &out.ReceiveBytesPerSecond
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.TransmitBytesPerSecond != nil {
+		in, out := &in.TransmitBytesPerSecond, &out.TransmitBytesPerSecond
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkIOConfig.
+func (in *NetworkIOConfig) DeepCopy() *NetworkIOConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkIOConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationConfig) DeepCopyInto(out *NotificationConfig) {
+	*out = *in
+	if in.Slack != nil {
+		in, out := &in.Slack, &out.Slack
+		*out = new(SlackNotification)
+		**out = **in
+	}
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]WebhookNotification, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SNS != nil {
+		in, out := &in.SNS, &out.SNS
+		*out = new(SNSNotification)
+		**out = **in
+	}
+	if in.SQS != nil {
+		in, out := &in.SQS, &out.SQS
+		*out = new(SQSNotification)
+		**out = **in
+	}
+	if in.Kafka != nil {
+		in, out := &in.Kafka, &out.Kafka
+		*out = new(KafkaNotification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Grafana != nil {
+		in, out := &in.Grafana, &out.Grafana
+		*out = new(GrafanaNotification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Issues != nil {
+		in, out := &in.Issues, &out.Issues
+		*out = new(IssueNotification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(EmailNotification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Splunk != nil {
+		in, out := &in.Splunk, &out.Splunk
+		*out = new(SplunkNotification)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationConfig.
+func (in *NotificationConfig) DeepCopy() *NotificationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OnDemandConfig) DeepCopyInto(out *OnDemandConfig) {
 	*out = *in
@@ -25,6 +634,26 @@ func (in *OnDemandConfig) DeepCopy() *OnDemandConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodConflict) DeepCopyInto(out *PodConflict) {
+	*out = *in
+	if in.Configs != nil {
+		in, out := &in.Configs, &out.Configs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodConflict.
+func (in *PodConflict) DeepCopy() *PodConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(PodConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodSelector) DeepCopyInto(out *PodSelector) {
 	*out = *in
@@ -35,6 +664,11 @@ func (in *PodSelector) DeepCopyInto(out *PodSelector) {
 			(*out)[key] = val
 		}
 	}
+	if in.RequireReady != nil {
+		in, out := &in.RequireReady, &out.RequireReady
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSelector.
@@ -42,7 +676,179 @@ func (in *PodSelector) DeepCopy() *PodSelector {
 	if in == nil {
 		return nil
 	}
-	out := new(PodSelector)
+	out := new(PodSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileCapture) DeepCopyInto(out *ProfileCapture) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileCapture.
+func (in *ProfileCapture) DeepCopy() *ProfileCapture {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileCapture)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProfileCapture) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileCaptureList) DeepCopyInto(out *ProfileCaptureList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ProfileCapture, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileCaptureList.
+func (in *ProfileCaptureList) DeepCopy() *ProfileCaptureList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileCaptureList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProfileCaptureList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileCaptureSpec) DeepCopyInto(out *ProfileCaptureSpec) {
+	*out = *in
+	if in.ProfileTypes != nil {
+		in, out := &in.ProfileTypes, &out.ProfileTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileCaptureSpec.
+func (in *ProfileCaptureSpec) DeepCopy() *ProfileCaptureSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileCaptureSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileCaptureStatus) DeepCopyInto(out *ProfileCaptureStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Trigger != nil {
+		in, out := &in.Trigger, &out.Trigger
+		*out = new(TriggerMetrics)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObjectKeys != nil {
+		in, out := &in.ObjectKeys, &out.ObjectKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProfileURLs != nil {
+		in, out := &in.ProfileURLs, &out.ProfileURLs
+		*out = make([]ProfileURL, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProfileURLsExpireTime != nil {
+		in, out := &in.ProfileURLsExpireTime, &out.ProfileURLsExpireTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Regression != nil {
+		in, out := &in.Regression, &out.Regression
+		*out = new(RegressionResult)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileCaptureStatus.
+func (in *ProfileCaptureStatus) DeepCopy() *ProfileCaptureStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileCaptureStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileURL) DeepCopyInto(out *ProfileURL) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileURL.
+func (in *ProfileURL) DeepCopy() *ProfileURL {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileURL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfiledPod) DeepCopyInto(out *ProfiledPod) {
+	*out = *in
+	if in.LastCaptureTime != nil {
+		in, out := &in.LastCaptureTime, &out.LastCaptureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CooldownStartTime != nil {
+		in, out := &in.CooldownStartTime, &out.CooldownStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RetryAfter != nil {
+		in, out := &in.RetryAfter, &out.RetryAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.UsageTime != nil {
+		in, out := &in.UsageTime, &out.UsageTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfiledPod.
+func (in *ProfiledPod) DeepCopy() *ProfiledPod {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfiledPod)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -110,18 +916,103 @@ func (in *ProfilingConfigList) DeepCopyObject() runtime.Object {
 func (in *ProfilingConfigSpec) DeepCopyInto(out *ProfilingConfigSpec) {
 	*out = *in
 	in.Selector.DeepCopyInto(&out.Selector)
-	out.Thresholds = in.Thresholds
+	in.Thresholds.DeepCopyInto(&out.Thresholds)
 	if in.OnDemand != nil {
 		in, out := &in.OnDemand, &out.OnDemand
 		*out = new(OnDemandConfig)
 		**out = **in
 	}
-	out.S3Config = in.S3Config
+	in.S3Config.DeepCopyInto(&out.S3Config)
 	if in.ProfileTypes != nil {
 		in, out := &in.ProfileTypes, &out.ProfileTypes
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusConfig)
+		**out = **in
+	}
+	if in.CaptureHistoryLimit != nil {
+		in, out := &in.CaptureHistoryLimit, &out.CaptureHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StorageBudget != nil {
+		in, out := &in.StorageBudget, &out.StorageBudget
+		*out = new(StorageBudget)
+		**out = **in
+	}
+	if in.Deduplication != nil {
+		in, out := &in.Deduplication, &out.Deduplication
+		*out = new(DeduplicationConfig)
+		**out = **in
+	}
+	if in.Cluster != nil {
+		in, out := &in.Cluster, &out.Cluster
+		*out = new(ClusterTarget)
+		**out = **in
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(NotificationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Baseline != nil {
+		in, out := &in.Baseline, &out.Baseline
+		*out = new(BaselineConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LeakDetection != nil {
+		in, out := &in.LeakDetection, &out.LeakDetection
+		*out = new(LeakDetectionConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Aggregation != nil {
+		in, out := &in.Aggregation, &out.Aggregation
+		*out = new(AggregationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExecHooks != nil {
+		in, out := &in.ExecHooks, &out.ExecHooks
+		*out = new(ExecHooksConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VPADrift != nil {
+		in, out := &in.VPADrift, &out.VPADrift
+		*out = new(VPADriftConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HPAScaleOut != nil {
+		in, out := &in.HPAScaleOut, &out.HPAScaleOut
+		*out = new(HPAScaleOutConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EventTriggers != nil {
+		in, out := &in.EventTriggers, &out.EventTriggers
+		*out = make([]EventTrigger, len(*in))
+		copy(*out, *in)
+	}
+	if in.EphemeralStorage != nil {
+		in, out := &in.EphemeralStorage, &out.EphemeralStorage
+		*out = new(EphemeralStorageConfig)
+		**out = **in
+	}
+	if in.NetworkIO != nil {
+		in, out := &in.NetworkIO, &out.NetworkIO
+		*out = new(NetworkIOConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make([]CaptureSchedule, len(*in))
+		copy(*out, *in)
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(WebhookTriggerConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfilingConfigSpec.
@@ -141,6 +1032,29 @@ func (in *ProfilingConfigStatus) DeepCopyInto(out *ProfilingConfigStatus) {
 		in, out := &in.LastProfileTime, &out.LastProfileTime
 		*out = (*in).DeepCopy()
 	}
+	if in.StorageUsage != nil {
+		in, out := &in.StorageUsage, &out.StorageUsage
+		*out = new(StorageUsage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastErrorTime != nil {
+		in, out := &in.LastErrorTime, &out.LastErrorTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ProfiledPods != nil {
+		in, out := &in.ProfiledPods, &out.ProfiledPods
+		*out = make([]ProfiledPod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]PodConflict, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -160,9 +1074,104 @@ func (in *ProfilingConfigStatus) DeepCopy() *ProfilingConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusConfig) DeepCopyInto(out *PrometheusConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusConfig.
+func (in *PrometheusConfig) DeepCopy() *PrometheusConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimits) DeepCopyInto(out *RateLimits) {
+	*out = *in
+	if in.MaxPortForwards != nil {
+		in, out := &in.MaxPortForwards, &out.MaxPortForwards
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxCapturesPerMinute != nil {
+		in, out := &in.MaxCapturesPerMinute, &out.MaxCapturesPerMinute
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimits.
+func (in *RateLimits) DeepCopy() *RateLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegressionResult) DeepCopyInto(out *RegressionResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegressionResult.
+func (in *RegressionResult) DeepCopy() *RegressionResult {
+	if in == nil {
+		return nil
+	}
+	out := new(RegressionResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetentionConfig) DeepCopyInto(out *RetentionConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionConfig.
+func (in *RetentionConfig) DeepCopy() *RetentionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetentionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S3Configuration) DeepCopyInto(out *S3Configuration) {
 	*out = *in
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+	if in.ForcePathStyle != nil {
+		in, out := &in.ForcePathStyle, &out.ForcePathStyle
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Lifecycle != nil {
+		in, out := &in.Lifecycle, &out.Lifecycle
+		*out = new(LifecycleConfig)
+		**out = **in
+	}
+	if in.WebIdentity != nil {
+		in, out := &in.WebIdentity, &out.WebIdentity
+		*out = new(WebIdentityConfig)
+		**out = **in
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreakerConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Configuration.
@@ -175,9 +1184,132 @@ func (in *S3Configuration) DeepCopy() *S3Configuration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SNSNotification) DeepCopyInto(out *SNSNotification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SNSNotification.
+func (in *SNSNotification) DeepCopy() *SNSNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(SNSNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SQSNotification) DeepCopyInto(out *SQSNotification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SQSNotification.
+func (in *SQSNotification) DeepCopy() *SQSNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(SQSNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SlackNotification) DeepCopyInto(out *SlackNotification) {
+	*out = *in
+	out.WebhookSecretRef = in.WebhookSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlackNotification.
+func (in *SlackNotification) DeepCopy() *SlackNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(SlackNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkNotification) DeepCopyInto(out *SplunkNotification) {
+	*out = *in
+	out.TokenSecretRef = in.TokenSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkNotification.
+func (in *SplunkNotification) DeepCopy() *SplunkNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(SplunkNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageBudget) DeepCopyInto(out *StorageBudget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageBudget.
+func (in *StorageBudget) DeepCopy() *StorageBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageUsage) DeepCopyInto(out *StorageUsage) {
+	*out = *in
+	if in.MeasureTime != nil {
+		in, out := &in.MeasureTime, &out.MeasureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageUsage.
+func (in *StorageUsage) DeepCopy() *StorageUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ThresholdConfig) DeepCopyInto(out *ThresholdConfig) {
 	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ConditionGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThresholdConfig.
@@ -189,3 +1321,110 @@ func (in *ThresholdConfig) DeepCopy() *ThresholdConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThresholdDefaults) DeepCopyInto(out *ThresholdDefaults) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThresholdDefaults.
+func (in *ThresholdDefaults) DeepCopy() *ThresholdDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(ThresholdDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TriggerMetrics) DeepCopyInto(out *TriggerMetrics) {
+	*out = *in
+	out.CPUUsage = in.CPUUsage.DeepCopy()
+	out.MemoryUsage = in.MemoryUsage.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TriggerMetrics.
+func (in *TriggerMetrics) DeepCopy() *TriggerMetrics {
+	if in == nil {
+		return nil
+	}
+	out := new(TriggerMetrics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VPADriftConfig) DeepCopyInto(out *VPADriftConfig) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]VPADriftResource, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VPADriftConfig.
+func (in *VPADriftConfig) DeepCopy() *VPADriftConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VPADriftConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebIdentityConfig) DeepCopyInto(out *WebIdentityConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebIdentityConfig.
+func (in *WebIdentityConfig) DeepCopy() *WebIdentityConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebIdentityConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookNotification) DeepCopyInto(out *WebhookNotification) {
+	*out = *in
+	if in.SigningSecretRef != nil {
+		in, out := &in.SigningSecretRef, &out.SigningSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookNotification.
+func (in *WebhookNotification) DeepCopy() *WebhookNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookTriggerConfig) DeepCopyInto(out *WebhookTriggerConfig) {
+	*out = *in
+	if in.Alerts != nil {
+		in, out := &in.Alerts, &out.Alerts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookTriggerConfig.
+func (in *WebhookTriggerConfig) DeepCopy() *WebhookTriggerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookTriggerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
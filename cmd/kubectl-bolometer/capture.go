@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/controller"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// capturePollInterval is how often requested captures are checked for completion
+const capturePollInterval = 2 * time.Second
+
+// runCapture requests an immediate capture of a pod, or of every running pod of
+// a deployment, through a ProfilingConfig selecting them, and waits for the
+// operator to finish it
+func runCapture(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s capture (POD | pod/POD | deployment/NAME) [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	var kube kubeFlags
+	kube.bind(fs)
+	configName := fs.String("config", "",
+		"The ProfilingConfig to capture through, as name or namespace/name. Defaults to the config selecting the pods.")
+	profileTypes := fs.String("types", "", "Comma-separated profile types to capture. Defaults to the config's.")
+	noWait := fs.Bool("no-wait", false, "Print the requested ProfileCaptures without waiting for them.")
+	timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for the captures to finish.")
+	download := fs.String("download", "", "Download the captured profiles to this directory with the local AWS credentials.")
+	region := fs.String("region", "", "The AWS region of the bucket, for --download. Defaults to the config's.")
+
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	c, namespace, err := kube.client()
+	if err != nil {
+		return err
+	}
+
+	pods, err := resolvePods(ctx, c, namespace, positional[0])
+	if err != nil {
+		return err
+	}
+	config, err := resolveConfig(ctx, c, *configName, pods)
+	if err != nil {
+		return err
+	}
+
+	var requested []string
+	if *profileTypes != "" {
+		requested = strings.Split(*profileTypes, ",")
+	}
+
+	captures := make([]*profilingv1alpha1.ProfileCapture, 0, len(pods))
+	for _, pod := range pods {
+		capture, err := controller.NewCaptureRequest(config, pod.Namespace+"/"+pod.Name, requested)
+		if err != nil {
+			return err
+		}
+		if err := c.Create(ctx, capture); err != nil {
+			return fmt.Errorf("failed to request capture of pod %s: %w", pod.Name, err)
+		}
+		fmt.Printf("profilecapture/%s requested for pod %s/%s\n", capture.Name, pod.Namespace, pod.Name)
+		captures = append(captures, capture)
+	}
+	if *noWait {
+		return nil
+	}
+
+	if err := waitForCaptures(ctx, c, captures, *timeout); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, capture := range captures {
+		printCapture(capture)
+		if capture.Status.Phase == profilingv1alpha1.CapturePhaseFailed {
+			failed++
+		}
+	}
+
+	if *download != "" {
+		if err := downloadCaptures(ctx, config, captures, *region, *download); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d captures failed", failed, len(captures))
+	}
+	return nil
+}
+
+// resolvePods returns the pods a target names: a pod, or the running pods of a
+// deployment
+func resolvePods(ctx context.Context, c client.Client, namespace, target string) ([]*corev1.Pod, error) {
+	kind, name, found := strings.Cut(target, "/")
+	if !found {
+		kind, name = "pod", target
+	}
+
+	switch strings.ToLower(kind) {
+	case "pod", "pods", "po":
+		pod := &corev1.Pod{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil {
+			return nil, err
+		}
+		return []*corev1.Pod{pod}, nil
+
+	case "deployment", "deployments", "deploy":
+		deployment := &appsv1.Deployment{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, deployment); err != nil {
+			return nil, err
+		}
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		list := &corev1.PodList{}
+		if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		var pods []*corev1.Pod
+		for i := range list.Items {
+			if pod := &list.Items[i]; pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			return nil, fmt.Errorf("deployment %s has no running pods", name)
+		}
+		return pods, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported target kind %q, must be pod or deployment", kind)
+	}
+}
+
+// resolveConfig returns the ProfilingConfig named by the --config flag, or the
+// only config selecting the pods. The pods must be enabled for profiling.
+func resolveConfig(ctx context.Context, c client.Client, name string, pods []*corev1.Pod) (*profilingv1alpha1.ProfilingConfig, error) {
+	for _, pod := range pods {
+		if pod.Annotations[controller.ProfilingEnabledAnnotation] != "true" {
+			return nil, fmt.Errorf("pod %s is not annotated %s=true", pod.Name, controller.ProfilingEnabledAnnotation)
+		}
+	}
+
+	if name != "" {
+		namespace, configName, found := strings.Cut(name, "/")
+		if !found {
+			namespace, configName = pods[0].Namespace, name
+		}
+		config := &profilingv1alpha1.ProfilingConfig{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: configName}, config); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	list := &profilingv1alpha1.ProfilingConfigList{}
+	if err := c.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("failed to list ProfilingConfigs, pass --config: %w", err)
+	}
+	var matching []*profilingv1alpha1.ProfilingConfig
+	for i := range list.Items {
+		if config := &list.Items[i]; selectsPod(config, pods[0]) {
+			matching = append(matching, config)
+		}
+	}
+
+	switch len(matching) {
+	case 0:
+		return nil, fmt.Errorf("no ProfilingConfig selects pod %s", pods[0].Name)
+	case 1:
+		return matching[0], nil
+	default:
+		names := make([]string, 0, len(matching))
+		for _, config := range matching {
+			names = append(names, config.Namespace+"/"+config.Name)
+		}
+		return nil, fmt.Errorf("pod %s is selected by several ProfilingConfigs (%s), pass --config",
+			pods[0].Name, strings.Join(names, ", "))
+	}
+}
+
+// selectsPod reports whether the selector of a local config covers a pod
+func selectsPod(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod) bool {
+	if config.Spec.Cluster != nil {
+		return false
+	}
+
+	namespace := config.Spec.Selector.Namespace
+	if namespace == "" {
+		namespace = config.Namespace
+	}
+	return pod.Namespace == namespace &&
+		labels.SelectorFromSet(config.Spec.Selector.LabelSelector).Matches(labels.Set(pod.Labels))
+}
+
+// waitForCaptures polls the captures until all of them have finished
+func waitForCaptures(ctx context.Context, c client.Client, captures []*profilingv1alpha1.ProfileCapture, timeout time.Duration) error {
+	err := wait.PollUntilContextTimeout(ctx, capturePollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		for _, capture := range captures {
+			if err := c.Get(ctx, client.ObjectKeyFromObject(capture), capture); err != nil {
+				return false, err
+			}
+			if !captureFinished(capture) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if wait.Interrupted(err) {
+		return errors.New("timed out waiting for the captures, check them with kubectl get profilecaptures")
+	}
+	return err
+}
+
+// captureFinished reports whether a capture succeeded or failed
+func captureFinished(capture *profilingv1alpha1.ProfileCapture) bool {
+	return capture.Status.Phase == profilingv1alpha1.CapturePhaseSucceeded ||
+		capture.Status.Phase == profilingv1alpha1.CapturePhaseFailed
+}
+
+// printCapture prints the outcome of a capture and the locations of its objects
+func printCapture(capture *profilingv1alpha1.ProfileCapture) {
+	fmt.Printf("\n%s/%s: %s\n", capture.Spec.PodNamespace, capture.Spec.PodName, capture.Status.Phase)
+	if capture.Status.Message != "" {
+		fmt.Printf("  %s\n", capture.Status.Message)
+	}
+	for _, key := range capture.Status.ObjectKeys {
+		fmt.Printf("  s3://%s/%s\n", capture.Status.Bucket, key)
+	}
+	if capture.Status.ManifestKey != "" {
+		fmt.Printf("  s3://%s/%s\n", capture.Status.Bucket, capture.Status.ManifestKey)
+	}
+}
+
+// downloadCaptures downloads the profiles of the succeeded captures to dir
+func downloadCaptures(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, captures []*profilingv1alpha1.ProfileCapture, region, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if region == "" {
+		region = config.Spec.S3Config.Region
+	}
+
+	for _, capture := range captures {
+		if capture.Status.Phase != profilingv1alpha1.CapturePhaseSucceeded {
+			continue
+		}
+
+		s3Uploader, err := uploader.NewS3Uploader(ctx, uploader.S3Config{
+			Bucket:   capture.Status.Bucket,
+			Region:   region,
+			Endpoint: config.Spec.S3Config.Endpoint,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create S3 client: %w", err)
+		}
+
+		for _, key := range capture.Status.ObjectKeys {
+			data, err := s3Uploader.Download(ctx, capture.Status.Bucket, key)
+			if err != nil {
+				return err
+			}
+			file := filepath.Join(dir, capture.Spec.PodName+"-"+path.Base(key))
+			if err := os.WriteFile(file, data, 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("Downloaded %s\n", file)
+		}
+	}
+	return nil
+}
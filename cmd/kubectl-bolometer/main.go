@@ -0,0 +1,161 @@
+// kubectl-bolometer is a kubectl plugin for the bolometer operator. Installed
+// on the PATH it runs as "kubectl bolometer"; renamed or linked to bolometer
+// it also works on its own.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bolometerclient "github.com/a-kash-singh/bolometer/pkg/client"
+)
+
+// command is a subcommand of the plugin
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"capture", "Capture profiles of a pod or deployment now", runCapture},
+	{"profiles", "List and download uploaded profiles", runProfiles},
+	{"simulate", "Report the captures a ProfilingConfig would trigger on live metrics", runSimulate},
+	{"top", "Show the tracked pods of each config and their capture state", runTop},
+	{"validate", "Validate ProfilingConfig manifests offline", runValidate},
+}
+
+var scheme = bolometerclient.NewScheme()
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(ctx, os.Args[2:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(2)
+			}
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the available commands
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n", progName())
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Run '%s <command> -h' for the flags of a command.\n", progName())
+}
+
+// progName is how the plugin was invoked, for usage messages
+func progName() string {
+	if name := filepath.Base(os.Args[0]); !strings.HasPrefix(name, "kubectl-") {
+		return name
+	}
+	return "kubectl bolometer"
+}
+
+// kubeFlags are the kubectl connection flags shared by all commands
+type kubeFlags struct {
+	kubeconfig string
+	context    string
+	namespace  string
+}
+
+// bind registers the flags on a flag set
+func (f *kubeFlags) bind(fs *flag.FlagSet) {
+	fs.StringVar(&f.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	fs.StringVar(&f.context, "context", "", "The kubeconfig context to use.")
+	fs.StringVar(&f.namespace, "namespace", "", "The namespace of the target. Defaults to the context's namespace.")
+	fs.StringVar(&f.namespace, "n", "", "Shorthand for --namespace.")
+}
+
+// restConfig loads the kubeconfig, returning the connection to the cluster and
+// the namespace to use
+func (f *kubeFlags) restConfig() (*rest.Config, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+		&clientcmd.ConfigOverrides{CurrentContext: f.context})
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	namespace := f.namespace
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, "", err
+		}
+	}
+	return restConfig, namespace, nil
+}
+
+// client connects to the cluster, returning a client and the namespace to use
+func (f *kubeFlags) client() (client.Client, string, error) {
+	restConfig, namespace, err := f.restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create client: %w", err)
+	}
+	return c, namespace, nil
+}
+
+// parseInterleaved parses flags placed before or after positional arguments,
+// as kubectl does, and returns the positional arguments
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
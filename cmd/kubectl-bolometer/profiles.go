@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/index"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// profileSource locates captures: the operator's capture index, or the S3
+// bucket the profiles are stored in
+type profileSource struct {
+	index    string
+	bucket   string
+	prefix   string
+	region   string
+	endpoint string
+
+	// The credentials the capture index is queried with, as for top
+	kube     kubeFlags
+	token    string
+	caFile   string
+	insecure bool
+}
+
+// bind registers the flags on a flag set
+func (s *profileSource) bind(fs *flag.FlagSet) {
+	fs.StringVar(&s.index, "index", "", "URL of the operator's HTTP API serving the capture index, e.g. https://localhost:8443.")
+	fs.StringVar(&s.kube.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	fs.StringVar(&s.kube.context, "context", "", "The kubeconfig context to use.")
+	fs.StringVar(&s.token, "token", "", "Bearer token to query the index with. Defaults to the kubeconfig's credentials.")
+	fs.StringVar(&s.caFile, "certificate-authority", "", "Path to the CA certificate of the API's serving certificate.")
+	fs.BoolVar(&s.insecure, "insecure-skip-tls-verify", false, "Do not verify the API's serving certificate.")
+	fs.StringVar(&s.bucket, "bucket", "", "The S3 bucket to read the profiles from, when there is no index.")
+	fs.StringVar(&s.prefix, "prefix", "", "The key prefix of the profiles in the bucket.")
+	fs.StringVar(&s.region, "region", "", "The AWS region of the bucket.")
+	fs.StringVar(&s.endpoint, "endpoint", "", "Custom S3 endpoint, e.g. for MinIO.")
+}
+
+// uploader creates an S3 client with the local AWS credentials
+func (s *profileSource) uploader(ctx context.Context, bucket string) (*uploader.S3Uploader, error) {
+	s3Uploader, err := uploader.NewS3Uploader(ctx, uploader.S3Config{
+		Bucket:   bucket,
+		Prefix:   s.prefix,
+		Region:   s.region,
+		Endpoint: s.endpoint,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return s3Uploader, nil
+}
+
+// profileFilter selects profiles. Empty fields match every profile.
+type profileFilter struct {
+	namespace   string
+	service     string
+	pod         string
+	reason      string
+	profileType string
+	since       string
+	until       string
+	limit       int
+}
+
+// bind registers the flags on a flag set
+func (f *profileFilter) bind(fs *flag.FlagSet) {
+	fs.StringVar(&f.namespace, "namespace", "", "Only profiles of pods in this namespace.")
+	fs.StringVar(&f.namespace, "n", "", "Shorthand for --namespace.")
+	fs.StringVar(&f.service, "service", "", "Only profiles of this service.")
+	fs.StringVar(&f.pod, "pod", "", "Only profiles of this pod.")
+	fs.StringVar(&f.reason, "reason", "", "Only captures whose reason contains this text, ignoring case.")
+	fs.StringVar(&f.profileType, "type", "", "Only profiles of this type, e.g. heap.")
+	fs.StringVar(&f.since, "since", "24h", "Only captures taken after this RFC 3339 time or duration ago.")
+	fs.StringVar(&f.until, "until", "", "Only captures taken before this RFC 3339 time or duration ago.")
+	fs.IntVar(&f.limit, "limit", 50, "The most captures to return, newest first.")
+}
+
+// profile is a profile uploaded by a capture
+type profile struct {
+	time        time.Time
+	namespace   string
+	pod         string
+	service     string
+	reason      string
+	profileType string
+	bucket      string
+	key         string
+}
+
+// runProfiles lists and downloads uploaded profiles
+func runProfiles(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runProfilesList(ctx, args[1:])
+		case "get":
+			return runProfilesGet(ctx, args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: %s profiles (list | get) [flags]\n", progName())
+	return flag.ErrHelp
+}
+
+// runProfilesList prints the profiles matching the filter flags
+func runProfilesList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("profiles list", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s profiles list (--index URL | --bucket BUCKET) [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	var source profileSource
+	var filter profileFilter
+	source.bind(fs)
+	filter.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	profiles, err := findProfiles(ctx, source, filter)
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintln(os.Stderr, "No profiles found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tNAMESPACE\tPOD\tSERVICE\tTYPE\tREASON\tKEY")
+	for _, p := range profiles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.time.Local().Format(time.DateTime),
+			p.namespace, p.pod, p.service, p.profileType, p.reason, p.key)
+	}
+	return w.Flush()
+}
+
+// runProfilesGet downloads the profiles named by key, or matching the filter
+// flags, keeping their key layout under the output directory
+func runProfilesGet(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("profiles get", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s profiles get [KEY...] (--index URL | --bucket BUCKET) [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	var source profileSource
+	var filter profileFilter
+	source.bind(fs)
+	filter.bind(fs)
+	dir := fs.String("dir", ".", "The directory to download the profiles to.")
+
+	keys, err := parseInterleaved(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var profiles []profile
+	if len(keys) > 0 {
+		if source.bucket == "" {
+			return errors.New("--bucket is required to download keys")
+		}
+		for _, key := range keys {
+			profiles = append(profiles, profile{bucket: source.bucket, key: key})
+		}
+	} else {
+		if profiles, err = findProfiles(ctx, source, filter); err != nil {
+			return err
+		}
+		if len(profiles) == 0 {
+			return errors.New("no profiles match the filters")
+		}
+	}
+
+	clients := make(map[string]*uploader.S3Uploader)
+	for _, p := range profiles {
+		s3Uploader, ok := clients[p.bucket]
+		if !ok {
+			if s3Uploader, err = source.uploader(ctx, p.bucket); err != nil {
+				return err
+			}
+			clients[p.bucket] = s3Uploader
+		}
+
+		data, err := s3Uploader.Download(ctx, p.bucket, p.key)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(*dir, filepath.FromSlash(p.key))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Downloaded %s\n", file)
+	}
+	return nil
+}
+
+// findProfiles returns the profiles matching a filter, newest first
+func findProfiles(ctx context.Context, source profileSource, filter profileFilter) ([]profile, error) {
+	switch {
+	case source.index != "":
+		restConfig, _, err := source.kube.restConfig()
+		if err != nil {
+			return nil, err
+		}
+		httpClient, err := apiClient(restConfig, source.token, source.caFile, source.insecure)
+		if err != nil {
+			return nil, err
+		}
+		return queryIndex(ctx, httpClient, source.index, filter)
+	case source.bucket != "":
+		return listBucket(ctx, source, filter)
+	default:
+		return nil, errors.New("either --index or --bucket is required")
+	}
+}
+
+// queryIndex queries the capture index served by the operator's HTTP API
+func queryIndex(ctx context.Context, httpClient *http.Client, indexURL string, filter profileFilter) ([]profile, error) {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"namespace": filter.namespace,
+		"service":   filter.service,
+		"pod":       filter.pod,
+		"reason":    filter.reason,
+		"type":      filter.profileType,
+		"since":     filter.since,
+		"until":     filter.until,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if filter.limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.limit))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(indexURL, "/")+index.CapturesPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the capture index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("capture index returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var captures []index.Capture
+	if err := json.NewDecoder(resp.Body).Decode(&captures); err != nil {
+		return nil, fmt.Errorf("failed to decode captures: %w", err)
+	}
+
+	var profiles []profile
+	for _, capture := range captures {
+		for _, p := range capture.Profiles {
+			if filter.profileType != "" && p.Type != filter.profileType {
+				continue
+			}
+			profiles = append(profiles, profile{
+				time:        capture.Time,
+				namespace:   capture.Pod.Namespace,
+				pod:         capture.Pod.Name,
+				service:     capture.Service,
+				reason:      capture.Reason,
+				profileType: p.Type,
+				bucket:      capture.Bucket,
+				key:         p.Key,
+			})
+		}
+	}
+	return profiles, nil
+}
+
+// listBucket reads the capture manifests of the S3 layout
+func listBucket(ctx context.Context, source profileSource, filter profileFilter) ([]profile, error) {
+	now := time.Now()
+	since, err := index.ParseTime(filter.since, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --since: %w", err)
+	}
+	if since.IsZero() {
+		return nil, errors.New("--since is required to list a bucket")
+	}
+	until, err := index.ParseTime(filter.until, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --until: %w", err)
+	}
+	if until.IsZero() {
+		until = now
+	}
+
+	s3Uploader, err := source.uploader(ctx, source.bucket)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := s3Uploader.ListManifests(ctx, filter.service, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []profile
+	captures := 0
+	for i := len(manifests) - 1; i >= 0 && (filter.limit <= 0 || captures < filter.limit); i-- {
+		manifest := manifests[i]
+		if (filter.namespace != "" && manifest.PodNamespace != filter.namespace) ||
+			(filter.pod != "" && manifest.PodName != filter.pod) ||
+			!strings.Contains(strings.ToLower(manifest.Reason), strings.ToLower(filter.reason)) {
+			continue
+		}
+
+		matched := false
+		for _, object := range manifest.Objects {
+			if filter.profileType != "" && object.Type != filter.profileType {
+				continue
+			}
+			profiles = append(profiles, profile{
+				time:        manifest.CapturedAt,
+				namespace:   manifest.PodNamespace,
+				pod:         manifest.PodName,
+				service:     manifest.Service,
+				reason:      manifest.Reason,
+				profileType: object.Type,
+				bucket:      source.bucket,
+				key:         object.Key,
+			})
+			matched = true
+		}
+		if matched {
+			captures++
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/index"
+)
+
+// allowNamespaces allows reading the captures of the listed namespaces
+type allowNamespaces map[string]bool
+
+func (a allowNamespaces) CanReadCaptures(_ *http.Request, namespace string) (bool, error) {
+	return a[namespace], nil
+}
+
+func TestQueryIndex(t *testing.T) {
+	ctx := context.Background()
+	captureIndex, err := index.Open(ctx, index.DriverSQLite, filepath.Join(t.TempDir(), "captures.db"))
+	if err != nil {
+		t.Fatalf("Open returned unexpected error: %v", err)
+	}
+	defer captureIndex.Close()
+
+	for _, pod := range []string{"my-app-1", "my-app-2"} {
+		err := captureIndex.Write(ctx, audit.Record{
+			Time:        time.Now().Add(-time.Hour),
+			Config:      "default/my-app",
+			TriggeredBy: "threshold",
+			Reason:      "Memory usage 95.00% exceeds threshold 90%",
+			Pod:         audit.Pod{Namespace: "default", Name: pod},
+			Service:     "my-app",
+			Bucket:      "my-bucket",
+			Outcome:     audit.OutcomeSucceeded,
+			Profiles: []audit.Profile{
+				{Type: "heap", Key: "profiles/" + pod + "-heap.pprof"},
+				{Type: "goroutine", Key: "profiles/" + pod + "-goroutine.pprof"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+
+	server := httptest.NewServer(index.NewHandler(captureIndex, allowNamespaces{"default": true}))
+	defer server.Close()
+
+	profiles, err := queryIndex(ctx, server.Client(), server.URL+"/", profileFilter{
+		pod:         "my-app-2",
+		reason:      "memory",
+		profileType: "heap",
+		since:       "24h",
+	})
+	if err != nil {
+		t.Fatalf("queryIndex returned unexpected error: %v", err)
+	}
+	if len(profiles) != 1 {
+		t.Fatalf("Expected 1 profile, got %+v", profiles)
+	}
+	if p := profiles[0]; p.bucket != "my-bucket" || p.key != "profiles/my-app-2-heap.pprof" || p.service != "my-app" {
+		t.Errorf("Unexpected profile %+v", p)
+	}
+
+	if _, err := queryIndex(ctx, server.Client(), server.URL, profileFilter{since: "yesterday"}); err == nil {
+		t.Error("Expected error for an invalid time")
+	}
+	if _, err := queryIndex(ctx, server.Client(), server.URL, profileFilter{namespace: "payments"}); err == nil {
+		t.Error("Expected error for a namespace the caller cannot read")
+	}
+}
+
+func TestFindProfiles_NoSource(t *testing.T) {
+	if _, err := findProfiles(context.Background(), profileSource{}, profileFilter{}); err == nil {
+		t.Error("Expected error without an index or bucket")
+	}
+}
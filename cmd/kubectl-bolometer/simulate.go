@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/config/crd"
+	"github.com/a-kash-singh/bolometer/internal/controller"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// simulationSummary tallies the captures a simulated config would have
+// triggered
+type simulationSummary struct {
+	checks     int
+	failed     int
+	captures   int
+	inCooldown int
+	deferred   int
+
+	// capturesByPod counts the captures of each pod
+	capturesByPod map[string]int
+}
+
+// runSimulate watches the live metrics of the pods a ProfilingConfig manifest
+// selects and reports the captures it would have triggered, without capturing
+func runSimulate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s simulate -f FILE [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	var kube kubeFlags
+	kube.bind(fs)
+	var file string
+	fs.StringVar(&file, "f", "", "A file holding the ProfilingConfig to simulate, or - for stdin.")
+	fs.StringVar(&file, "filename", "", "Same as -f.")
+	simulated := fs.Duration("duration", time.Hour, "How long to watch the metrics for.")
+	settingsFile := fs.String("settings", "",
+		"A BolometerSettings file whose defaults fill the unset fields of the config. Defaults to the cluster's settings.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if file == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	restConfig, namespace, err := kube.restConfig()
+	if err != nil {
+		return err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	settings, err := loadSettings(*settingsFile)
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		if settings, err = clusterSettings(ctx, c); err != nil {
+			return err
+		}
+	}
+	config, err := loadSimulatedConfig(file, settings)
+	if err != nil {
+		return err
+	}
+	if config.Namespace == "" {
+		config.Namespace = namespace
+	}
+	if config.Spec.Cluster != nil {
+		fmt.Fprintln(os.Stderr, "warning: the config targets a remote cluster, simulating against the kubeconfig's cluster")
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create clientset: %w", err)
+	}
+	metricsClient, err := metricsv.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create metrics client: %w", err)
+	}
+	collector := metrics.NewCollector(metricsClient)
+	collector.RegisterSource(metrics.SourceKubelet, metrics.NewKubeletSource(clientset))
+
+	thresholds := config.Spec.Thresholds
+	fmt.Printf("Simulating ProfilingConfig %s/%s for %s: CPU > %d%%, memory > %d%%, every %ds, cooldown %ds\n",
+		config.Namespace, config.Name, *simulated, thresholds.CPUThresholdPercent,
+		thresholds.MemoryThresholdPercent, thresholds.CheckIntervalSeconds, thresholds.CooldownSeconds)
+
+	summary := &simulationSummary{capturesByPod: make(map[string]int)}
+	controller.NewSimulation(config, c, collector).Run(ctx, *simulated, func(at time.Time, check controller.SimulationCheck, err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s check failed: %v\n", at.Format(time.TimeOnly), err)
+		}
+		summary.add(check, err)
+		for _, capture := range check.Captures {
+			fmt.Printf("%s would capture %s: %s\n", at.Format(time.TimeOnly), capture.Pod, capture.Reason)
+		}
+	})
+
+	summary.print(os.Stdout)
+	return nil
+}
+
+// loadSimulatedConfig reads the single ProfilingConfig of a file, validated
+// and with its unset fields filled from the settings
+func loadSimulatedConfig(file string, settings *profilingv1alpha1.BolometerSettingsSpec) (*profilingv1alpha1.ProfilingConfig, error) {
+	configSchema, err := specSchema(crd.ProfilingConfigs)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := readDocuments(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *profilingv1alpha1.ProfilingConfig
+	for _, document := range documents {
+		name, decoded, errs := decodeConfig(configSchema, settings, document)
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("ProfilingConfig %s is invalid: %w", name, errors.Join(errs...))
+		}
+		if decoded == nil {
+			continue
+		}
+		if config != nil {
+			return nil, fmt.Errorf("%s holds more than one ProfilingConfig", file)
+		}
+		config = decoded
+	}
+	if config == nil {
+		return nil, fmt.Errorf("no ProfilingConfig found in %s", file)
+	}
+	return config, nil
+}
+
+// clusterSettings reads the operator's settings from the cluster, nil if
+// there are none
+func clusterSettings(ctx context.Context, c client.Client) (*profilingv1alpha1.BolometerSettingsSpec, error) {
+	settings := &profilingv1alpha1.BolometerSettings{}
+	if err := c.Get(ctx, client.ObjectKey{Name: profilingv1alpha1.SettingsName}, settings); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read BolometerSettings: %w", err)
+	}
+	return &settings.Spec, nil
+}
+
+// add tallies the outcome of a check
+func (s *simulationSummary) add(check controller.SimulationCheck, err error) {
+	s.checks++
+	if err != nil {
+		s.failed++
+		return
+	}
+	s.captures += len(check.Captures)
+	s.inCooldown += check.InCooldown
+	s.deferred += check.Deferred
+	for _, capture := range check.Captures {
+		s.capturesByPod[capture.Pod]++
+	}
+}
+
+// print writes the summary of a simulation
+func (s *simulationSummary) print(out io.Writer) {
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%d checks (%d failed): %d captures would have been triggered\n", s.checks, s.failed, s.captures)
+	if s.inCooldown > 0 || s.deferred > 0 {
+		fmt.Fprintf(out, "Pods over their thresholds held back: %d in cooldown, %d by maxCapturesPerCheck\n", s.inCooldown, s.deferred)
+	}
+
+	pods := make([]string, 0, len(s.capturesByPod))
+	for pod := range s.capturesByPod {
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if s.capturesByPod[pods[i]] != s.capturesByPod[pods[j]] {
+			return s.capturesByPod[pods[i]] > s.capturesByPod[pods[j]]
+		}
+		return pods[i] < pods[j]
+	})
+	for _, pod := range pods {
+		fmt.Fprintf(out, "  %s: %d\n", pod, s.capturesByPod[pod])
+	}
+}
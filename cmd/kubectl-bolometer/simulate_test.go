@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/a-kash-singh/bolometer/internal/controller"
+)
+
+func TestLoadSimulatedConfig(t *testing.T) {
+	config, err := loadSimulatedConfig(filepath.Join("..", "..", "config", "samples", "profiling_v1alpha1_ondemand.yaml"), nil)
+	if err != nil {
+		t.Fatalf("loadSimulatedConfig returned unexpected error: %v", err)
+	}
+	if config.Name != "ondemand-profiling" || config.Spec.Thresholds.CheckIntervalSeconds == 0 {
+		t.Errorf("Expected the config with its defaults filled, got %+v", config.Spec.Thresholds)
+	}
+
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte(invalidConfig), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := loadSimulatedConfig(invalid, nil); err == nil || !strings.Contains(err.Error(), "production/my-app is invalid") {
+		t.Errorf("Expected the config to be invalid, got %v", err)
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, []byte("apiVersion: v1\nkind: ConfigMap\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := loadSimulatedConfig(empty, nil); err == nil {
+		t.Error("Expected error for a file without a ProfilingConfig")
+	}
+}
+
+func TestSimulationSummary(t *testing.T) {
+	summary := &simulationSummary{capturesByPod: make(map[string]int)}
+	summary.add(controller.SimulationCheck{Captures: []controller.SimulatedCapture{
+		{Pod: "default/my-app-1"}, {Pod: "default/my-app-2"},
+	}}, nil)
+	summary.add(controller.SimulationCheck{Captures: []controller.SimulatedCapture{{Pod: "default/my-app-2"}}, InCooldown: 1}, nil)
+	summary.add(controller.SimulationCheck{}, errors.New("metrics unavailable"))
+
+	var out strings.Builder
+	summary.print(&out)
+	for _, expected := range []string{
+		"3 checks (1 failed): 3 captures would have been triggered",
+		"1 in cooldown, 0 by maxCapturesPerCheck",
+		"  default/my-app-2: 2\n  default/my-app-1: 1\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
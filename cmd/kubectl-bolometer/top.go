@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/rest"
+
+	"github.com/a-kash-singh/bolometer/internal/api"
+)
+
+// runTop shows the pods tracked by each config with their latest usage and
+// capture state, read from the operator's HTTP API
+func runTop(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s top --server URL [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	var kube kubeFlags
+	kube.bind(fs)
+	server := fs.String("server", "", "URL of the operator's HTTP API, e.g. https://localhost:8443.")
+	token := fs.String("token", "", "Bearer token to authenticate with. Defaults to the kubeconfig's credentials.")
+	caFile := fs.String("certificate-authority", "", "Path to the CA certificate of the API's serving certificate.")
+	insecure := fs.Bool("insecure-skip-tls-verify", false, "Do not verify the API's serving certificate.")
+	configName := fs.String("config", "", "Only pods of this ProfilingConfig.")
+	allNamespaces := fs.Bool("all-namespaces", false, "Show the pods of the configs of all namespaces.")
+	fs.BoolVar(allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	watch := fs.Bool("watch", false, "Refresh the view every --interval until interrupted.")
+	fs.BoolVar(watch, "w", false, "Shorthand for --watch.")
+	interval := fs.Duration("interval", 5*time.Second, "How often to refresh the view with --watch.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *server == "" {
+		return errors.New("--server is required")
+	}
+
+	restConfig, namespace, err := kube.restConfig()
+	if err != nil {
+		return err
+	}
+	if *allNamespaces {
+		namespace = ""
+	}
+	httpClient, err := apiClient(restConfig, *token, *caFile, *insecure)
+	if err != nil {
+		return err
+	}
+
+	for {
+		pods, err := fetchPods(ctx, httpClient, *server, namespace, *configName)
+		if err != nil {
+			return err
+		}
+		if *watch {
+			// Clear the terminal before redrawing
+			fmt.Print("\033[H\033[2J")
+		}
+		if err := printPods(os.Stdout, pods, time.Now()); err != nil {
+			return err
+		}
+		if !*watch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// apiClient creates an HTTP client authenticating to the operator's API with
+// the kubeconfig's credentials, or with token if set. The API is served with
+// its own certificate, so the cluster's TLS settings are not used.
+func apiClient(restConfig *rest.Config, token, caFile string, insecure bool) (*http.Client, error) {
+	cfg := rest.CopyConfig(restConfig)
+	cfg.TLSClientConfig = rest.TLSClientConfig{Insecure: insecure, CAFile: caFile}
+	cfg.Username, cfg.Password = "", ""
+	cfg.Impersonate = rest.ImpersonationConfig{}
+	if token != "" {
+		cfg.BearerToken, cfg.BearerTokenFile = token, ""
+		cfg.ExecProvider, cfg.AuthProvider = nil, nil
+	}
+
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+	return httpClient, nil
+}
+
+// fetchPods lists the pods profiled by the configs of a namespace, or of all
+// namespaces
+func fetchPods(ctx context.Context, httpClient *http.Client, server, namespace, config string) ([]api.Pod, error) {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	if config != "" {
+		query.Set("config", config)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(server, "/")+api.PodsPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(body, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(body))
+		}
+		return nil, fmt.Errorf("API returned %s: %s", resp.Status, apiErr.Error)
+	}
+
+	var pods []api.Pod
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, fmt.Errorf("failed to decode pods: %w", err)
+	}
+	return pods, nil
+}
+
+// printPods prints a table of pods, grouped by config
+func printPods(out io.Writer, pods []api.Pod, now time.Time) error {
+	if len(pods) == 0 {
+		fmt.Fprintln(os.Stderr, "No tracked pods found.")
+		return nil
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].Config < pods[j].Config
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONFIG\tPOD\tCPU\tMEMORY\tCOOLDOWN\tLAST CAPTURE\tSTATE\tLAST REASON")
+	for _, pod := range pods {
+		cooldown := "-"
+		if pod.InCooldown {
+			cooldown = "yes"
+		}
+		lastCapture := "-"
+		if pod.LastCaptureTime != nil {
+			lastCapture = duration.HumanDuration(now.Sub(pod.LastCaptureTime.Time)) + " ago"
+		}
+		reason := pod.LastTriggerReason
+		if reason == "" {
+			reason = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", pod.Config, pod.Pod,
+			percent(pod.CPUUsagePercent), percent(pod.MemoryUsagePercent), cooldown, lastCapture, podState(pod, now), reason)
+	}
+	return w.Flush()
+}
+
+// percent formats a usage percentage, "-" if not sampled yet
+func percent(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value + "%"
+}
+
+// podState summarizes the capture health of a pod
+func podState(pod api.Pod, now time.Time) string {
+	switch {
+	case pod.Quarantined:
+		return "Quarantined"
+	case pod.RetryAfter != nil:
+		return fmt.Sprintf("BackingOff(%d failures, retry in %s)",
+			pod.ConsecutiveFailures, duration.HumanDuration(pod.RetryAfter.Sub(now)))
+	case pod.ConsecutiveFailures > 0:
+		return fmt.Sprintf("Failing(%d)", pod.ConsecutiveFailures)
+	default:
+		return "OK"
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/api"
+)
+
+func TestFetchPods(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer my-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid bearer token"}`))
+			return
+		}
+		if r.URL.Path != api.PodsPath || r.URL.Query().Get("namespace") != "default" || r.URL.Query().Get("config") != "my-app" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`[{"config":"default/my-app","pod":"default/my-app-1","consecutiveFailures":0,"inCooldown":true,"cpuUsagePercent":"92.50"}]`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	httpClient, err := apiClient(&rest.Config{Host: "https://cluster.example.com", BearerToken: "kubeconfig-token"}, "my-token", "", false)
+	if err != nil {
+		t.Fatalf("apiClient returned unexpected error: %v", err)
+	}
+	pods, err := fetchPods(ctx, httpClient, server.URL+"/", "default", "my-app")
+	if err != nil {
+		t.Fatalf("fetchPods returned unexpected error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Config != "default/my-app" || pods[0].Pod != "default/my-app-1" || !pods[0].InCooldown || pods[0].CPUUsagePercent != "92.50" {
+		t.Errorf("Unexpected pods %+v", pods)
+	}
+
+	// Without --token the kubeconfig's credentials are sent
+	httpClient, err = apiClient(&rest.Config{BearerToken: "kubeconfig-token"}, "", "", false)
+	if err != nil {
+		t.Fatalf("apiClient returned unexpected error: %v", err)
+	}
+	if _, err := fetchPods(ctx, httpClient, server.URL, "default", "my-app"); err == nil || !strings.Contains(err.Error(), "invalid bearer token") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+}
+
+func TestPrintPods(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	lastCapture := metav1.NewTime(now.Add(-5 * time.Minute))
+	retryAfter := metav1.NewTime(now.Add(30 * time.Second))
+
+	pods := []api.Pod{
+		{Config: "default/my-app", ProfiledPod: profilingv1alpha1.ProfiledPod{
+			Pod:                 "default/my-app-2",
+			ConsecutiveFailures: 2,
+			RetryAfter:          &retryAfter,
+		}},
+		{Config: "default/checkout", ProfiledPod: profilingv1alpha1.ProfiledPod{
+			Pod:                "default/checkout-1",
+			InCooldown:         true,
+			LastCaptureTime:    &lastCapture,
+			LastTriggerReason:  "CPU usage 92.50% exceeds threshold 80%",
+			CPUUsagePercent:    "92.50",
+			MemoryUsagePercent: "40.00",
+		}},
+	}
+
+	var out strings.Builder
+	if err := printPods(&out, pods, now); err != nil {
+		t.Fatalf("printPods returned unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got:\n%s", out.String())
+	}
+	for _, expected := range []string{"default/checkout-1", "92.50%", "40.00%", "yes", "5m ago", "OK", "CPU usage 92.50%"} {
+		if !strings.Contains(lines[1], expected) {
+			t.Errorf("Expected %q in the checkout row %q", expected, lines[1])
+		}
+	}
+	if !strings.Contains(lines[2], "BackingOff(2 failures, retry in 30s)") {
+		t.Errorf("Expected the backoff state in %q", lines[2])
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/config/crd"
+	"github.com/a-kash-singh/bolometer/internal/controller"
+	"github.com/a-kash-singh/bolometer/internal/schema"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runValidate validates ProfilingConfig manifests offline, against the CRD
+// schema and the checks the operator runs before monitoring a config
+func runValidate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s validate -f FILE [-f FILE...] [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	var files stringList
+	fs.Var(&files, "f", "A file of ProfilingConfigs to validate, or - for stdin. May be repeated.")
+	fs.Var(&files, "filename", "Same as -f.")
+	settingsFile := fs.String("settings", "",
+		"A BolometerSettings file whose defaults fill the unset fields of the configs, as in the cluster.")
+	live := fs.Bool("live", false, "Also check the S3 bucket of each config is reachable with the local AWS credentials.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(files) == 0 || fs.NArg() > 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	configSchema, err := specSchema(crd.ProfilingConfigs)
+	if err != nil {
+		return err
+	}
+	settings, err := loadSettings(*settingsFile)
+	if err != nil {
+		return err
+	}
+
+	var configs, invalid int
+	for _, file := range files {
+		documents, err := readDocuments(file)
+		if err != nil {
+			return err
+		}
+		for _, document := range documents {
+			name, errs := validateManifest(ctx, configSchema, settings, document, *live)
+			if name == "" {
+				continue
+			}
+			configs++
+
+			if len(errs) == 0 {
+				fmt.Printf("%s: ProfilingConfig %s is valid\n", file, name)
+				continue
+			}
+			invalid++
+			fmt.Printf("%s: ProfilingConfig %s is invalid:\n", file, name)
+			for _, err := range errs {
+				fmt.Printf("  - %v\n", err)
+			}
+		}
+	}
+
+	switch {
+	case configs == 0:
+		return errors.New("no ProfilingConfigs found")
+	case invalid > 0:
+		return fmt.Errorf("%d of %d ProfilingConfigs are invalid", invalid, configs)
+	}
+	return nil
+}
+
+// validateManifest validates a ProfilingConfig manifest and returns its name
+// and violations. Manifests of other kinds are skipped with an empty name.
+func validateManifest(ctx context.Context, spec *apiextensionsv1.JSONSchemaProps, settings *profilingv1alpha1.BolometerSettingsSpec,
+	data []byte, live bool) (string, []error) {
+	name, config, errs := decodeConfig(spec, settings, data)
+	if config == nil || len(errs) > 0 {
+		return name, errs
+	}
+
+	if live {
+		s3Config := config.Spec.S3Config
+		s3Uploader, err := uploader.NewS3Uploader(ctx, uploader.S3Config{
+			Bucket:         s3Config.Bucket,
+			Prefix:         s3Config.Prefix,
+			Region:         s3Config.Region,
+			Endpoint:       s3Config.Endpoint,
+			ProxyURL:       s3Config.ProxyURL,
+			Accelerate:     s3Config.Accelerate,
+			DualStack:      s3Config.DualStack,
+			ForcePathStyle: s3Config.ForcePathStyle,
+			RetryMode:      string(s3Config.RetryMode),
+			MaxAttempts:    s3Config.MaxAttempts,
+		})
+		if err == nil {
+			err = s3Uploader.CheckBucket(ctx)
+		}
+		if err != nil {
+			return name, []error{err}
+		}
+	}
+	return name, nil
+}
+
+// decodeConfig decodes and validates a ProfilingConfig manifest, returning its
+// name, the config with its unset fields filled from the settings, and its
+// violations. Manifests of other kinds are skipped with an empty name and a nil
+// config.
+func decodeConfig(spec *apiextensionsv1.JSONSchemaProps, settings *profilingv1alpha1.BolometerSettingsSpec,
+	data []byte) (string, *profilingv1alpha1.ProfilingConfig, []error) {
+	var object map[string]interface{}
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		return "<unparsable>", nil, []error{err}
+	}
+	if object["kind"] != "ProfilingConfig" {
+		return "", nil, nil
+	}
+
+	config := &profilingv1alpha1.ProfilingConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return "<unparsable>", nil, []error{err}
+	}
+	name := config.Name
+	if config.Namespace != "" {
+		name = config.Namespace + "/" + config.Name
+	}
+
+	if config.APIVersion != profilingv1alpha1.GroupVersion.String() {
+		return name, nil, []error{fmt.Errorf("apiVersion must be %s", profilingv1alpha1.GroupVersion)}
+	}
+	if config.Name == "" {
+		return "<unnamed>", nil, []error{errors.New("metadata.name is required")}
+	}
+	if errs := schema.Validate(spec, object["spec"], "spec"); len(errs) > 0 {
+		return name, nil, errs
+	}
+	if err := controller.ValidateConfig(config, settings); err != nil {
+		return name, nil, []error{err}
+	}
+	return name, config, nil
+}
+
+// loadSettings reads and validates a BolometerSettings manifest. Without a
+// file the operator's built-in defaults apply.
+func loadSettings(file string) (*profilingv1alpha1.BolometerSettingsSpec, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]interface{}
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	if object["kind"] != "BolometerSettings" {
+		return nil, fmt.Errorf("%s is not a BolometerSettings manifest", file)
+	}
+	settingsSchema, err := specSchema(crd.BolometerSettings)
+	if err != nil {
+		return nil, err
+	}
+	if errs := schema.Validate(settingsSchema, object["spec"], "spec"); len(errs) > 0 {
+		return nil, fmt.Errorf("%s is invalid: %w", file, errors.Join(errs...))
+	}
+
+	settings := &profilingv1alpha1.BolometerSettings{}
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return &settings.Spec, nil
+}
+
+// specSchema returns the schema of the spec of a CustomResourceDefinition
+func specSchema(data []byte) (*apiextensionsv1.JSONSchemaProps, error) {
+	crdSchema, err := schema.Load(data, profilingv1alpha1.GroupVersion.Version)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := crdSchema.Properties["spec"]
+	if !ok {
+		return nil, errors.New("schema has no spec")
+	}
+	return &spec, nil
+}
+
+// readDocuments returns the YAML documents of a file, or of stdin for -
+func readDocuments(file string) ([][]byte, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var documents [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		documents = append(documents, document)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/config/crd"
+)
+
+const invalidConfig = `
+apiVersion: bolometer.io/v1alpha1
+kind: ProfilingConfig
+metadata:
+  name: my-app
+  namespace: production
+spec:
+  selector:
+    labelSelector:
+      app: my-app
+  thresholds:
+    cpuThresholdPercent: 80
+`
+
+func TestValidateManifest(t *testing.T) {
+	ctx := context.Background()
+	spec, err := specSchema(crd.ProfilingConfigs)
+	if err != nil {
+		t.Fatalf("specSchema returned unexpected error: %v", err)
+	}
+
+	documents, err := readDocuments(filepath.Join("..", "..", "config", "samples", "profiling_v1alpha1_ondemand.yaml"))
+	if err != nil {
+		t.Fatalf("readDocuments returned unexpected error: %v", err)
+	}
+	if name, errs := validateManifest(ctx, spec, nil, documents[0], false); name != "default/ondemand-profiling" || len(errs) > 0 {
+		t.Errorf("Expected the sample to be valid, got %s: %v", name, errs)
+	}
+
+	name, errs := validateManifest(ctx, spec, nil, []byte(invalidConfig), false)
+	if name != "production/my-app" || len(errs) != 1 || !strings.Contains(errs[0].Error(), "bucket is required") {
+		t.Errorf("Expected a missing bucket, got %s: %v", name, errs)
+	}
+
+	settings := &profilingv1alpha1.BolometerSettingsSpec{
+		DefaultS3Config: &profilingv1alpha1.S3Configuration{Bucket: "shared-bucket", Region: "us-west-2"},
+	}
+	if _, errs := validateManifest(ctx, spec, settings, []byte(invalidConfig), false); len(errs) > 0 {
+		t.Errorf("Expected the settings to fill the bucket, got %v", errs)
+	}
+
+	if name, _ := validateManifest(ctx, spec, nil, []byte("apiVersion: v1\nkind: ConfigMap\n"), false); name != "" {
+		t.Errorf("Expected other kinds to be skipped, got %s", name)
+	}
+}
+
+func TestReadDocuments(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "configs.yaml")
+	if err := os.WriteFile(file, []byte(invalidConfig+"---\n"+invalidConfig), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	documents, err := readDocuments(file)
+	if err != nil {
+		t.Fatalf("readDocuments returned unexpected error: %v", err)
+	}
+	if len(documents) != 2 {
+		t.Errorf("Expected 2 documents, got %d", len(documents))
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -12,10 +16,17 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/api"
+	"github.com/a-kash-singh/bolometer/internal/audit"
 	"github.com/a-kash-singh/bolometer/internal/controller"
+	"github.com/a-kash-singh/bolometer/internal/index"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/ui"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
 )
 
 var (
@@ -32,12 +43,90 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var historySize int
+	var captureWorkers int
+	var checkWorkers int
+	var maxPortForwards int
+	var maxCapturesPerMinute int
+	var auditLogPath string
+	var auditS3 uploader.S3Config
+	var indexDriver string
+	var indexDSN string
+	var cloudWatchNamespace string
+	var cloudWatchRegion string
+	var cloudWatchInterval time.Duration
+	var pprofAddr string
+	var selfProfilingInterval time.Duration
+	var selfProfilingTypes string
+	var selfProfilingS3 uploader.S3Config
+	var apiOptions api.Options
+	var enableUI bool
+	var uiS3 uploader.S3Config
+	var configTemplatesNamespace string
+	var enableConfigLeases bool
+	var configLeaseDuration time.Duration
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.IntVar(&historySize, "metrics-history-size", metrics.DefaultHistorySize,
+		"The number of usage samples kept per pod and served on the metrics endpoint at "+metrics.HistoryPath+".")
+	flag.IntVar(&captureWorkers, "capture-workers", controller.DefaultCaptureWorkers,
+		"The number of profile captures run concurrently across all ProfilingConfigs.")
+	flag.IntVar(&checkWorkers, "check-workers", controller.DefaultCheckWorkers,
+		"The number of checks of periodic trigger sources run concurrently across all ProfilingConfigs.")
+	flag.IntVar(&maxPortForwards, "max-port-forwards", 10,
+		"The maximum number of port-forwards open at once across all ProfilingConfigs. 0 disables the limit.")
+	flag.IntVar(&maxCapturesPerMinute, "max-captures-per-minute", 30,
+		"The maximum number of profile captures started per minute across all ProfilingConfigs. 0 disables the limit.")
+	flag.StringVar(&auditLogPath, "audit-log-path", "",
+		"Append an audit record of every capture attempt as JSON lines to this file, or to stdout if set to '-'.")
+	flag.StringVar(&auditS3.Bucket, "audit-s3-bucket", "",
+		"Store an audit record of every capture attempt in this S3 bucket.")
+	flag.StringVar(&auditS3.Region, "audit-s3-region", "", "The AWS region of the audit bucket.")
+	flag.StringVar(&auditS3.Prefix, "audit-s3-prefix", "audit", "The key prefix of audit records in the audit bucket.")
+	flag.StringVar(&auditS3.Endpoint, "audit-s3-endpoint", "", "A custom endpoint for an S3-compatible audit store.")
+	flag.StringVar(&indexDriver, "index-driver", index.DriverSQLite,
+		"The database of the capture index, "+index.DriverSQLite+" or "+index.DriverPostgres+".")
+	flag.StringVar(&indexDSN, "index-dsn", "",
+		"Index every capture attempt in this database, a file path for SQLite or a connection string for Postgres, "+
+			"queryable on the HTTP API at "+index.CapturesPath+". Disabled if empty.")
+	flag.BoolVar(&enableUI, "ui", false,
+		"Serve a web UI of the capture index, with flamegraphs of the profiles, on the HTTP API at "+ui.Path+
+			". Requires --index-dsn and --api-bind-address.")
+	flag.StringVar(&uiS3.Region, "ui-s3-region", "", "The AWS region the web UI downloads profiles from.")
+	flag.StringVar(&uiS3.Endpoint, "ui-s3-endpoint", "", "A custom endpoint for an S3-compatible profile store.")
+	flag.StringVar(&cloudWatchNamespace, "cloudwatch-namespace", "",
+		"Publish per-config capture counters and durations as CloudWatch custom metrics in this namespace. Disabled if empty.")
+	flag.StringVar(&cloudWatchRegion, "cloudwatch-region", "", "The AWS region CloudWatch metrics are published to.")
+	flag.DurationVar(&cloudWatchInterval, "cloudwatch-interval", time.Minute,
+		"The interval at which aggregated CloudWatch metrics are published.")
+	flag.StringVar(&pprofAddr, "pprof-bind-address", "",
+		"The address the operator's own pprof endpoint binds to. Disabled if empty.")
+	flag.DurationVar(&selfProfilingInterval, "self-profiling-interval", 0,
+		"Capture the operator's own profiles at this interval through its pprof endpoint. 0 disables self-profiling.")
+	flag.StringVar(&selfProfilingTypes, "self-profiling-types", strings.Join(controller.DefaultSelfProfilingTypes, ","),
+		"Comma-separated profile types captured of the operator.")
+	flag.StringVar(&selfProfilingS3.Bucket, "self-profiling-s3-bucket", "",
+		"The S3 bucket the operator's own profiles are uploaded to, under the "+controller.OperatorProfilePrefix+" prefix.")
+	flag.StringVar(&selfProfilingS3.Region, "self-profiling-s3-region", "", "The AWS region of the self-profiling bucket.")
+	flag.StringVar(&selfProfilingS3.Endpoint, "self-profiling-s3-endpoint", "",
+		"A custom endpoint for an S3-compatible self-profiling store.")
+	flag.StringVar(&apiOptions.BindAddress, "api-bind-address", "",
+		"The address the HTTP API to trigger captures and list tracked pods binds to. Disabled if empty.")
+	flag.StringVar(&apiOptions.CertFile, "api-tls-cert-file", "", "The TLS certificate the HTTP API is served with.")
+	flag.StringVar(&apiOptions.KeyFile, "api-tls-key-file", "", "The TLS key the HTTP API is served with.")
+	flag.StringVar(&configTemplatesNamespace, "config-templates-namespace", "",
+		"The namespace of the ProfilingConfig templates Namespaces apply with the "+
+			controller.DefaultConfigAnnotation+" annotation. Namespace defaults are disabled if empty.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableConfigLeases, "config-leases", false,
+		"Spread the ProfilingConfigs across the replicas of the operator through a Lease per config, "+
+			"taken over by another replica when its holder stops. Implies --leader-elect for the "+
+			"operator-wide tasks, such as namespace defaults.")
+	flag.DurationVar(&configLeaseDuration, "config-lease-duration", controller.DefaultConfigLeaseDuration,
+		"How long a replica holds the Lease of a ProfilingConfig without renewing it.")
 
 	opts := zap.Options{
 		Development: true,
@@ -47,12 +136,81 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	// Replicas holding config Leases all monitor the configs whose Lease they
+	// hold, while a leader is still elected for the operator-wide tasks
+	var leaseIdentity string
+	if enableConfigLeases {
+		enableLeaderElection = true
+		leaseIdentity = os.Getenv("POD_NAME")
+		if leaseIdentity == "" {
+			leaseIdentity, _ = os.Hostname()
+		}
+	}
+
+	auditSink, err := newAuditSink(context.Background(), auditLogPath, auditS3)
+	if err != nil {
+		setupLog.Error(err, "unable to set up audit log")
+		os.Exit(1)
+	}
+
+	// The capture index is fed the audit records of the captures
+	var captureIndex *index.Index
+	if indexDSN != "" {
+		captureIndex, err = index.Open(context.Background(), indexDriver, indexDSN)
+		if err != nil {
+			setupLog.Error(err, "unable to open capture index")
+			os.Exit(1)
+		}
+		defer captureIndex.Close()
+		if auditSink == nil {
+			auditSink = captureIndex
+		} else {
+			auditSink = audit.MultiSink{auditSink, captureIndex}
+		}
+	}
+
+	// CloudWatch metrics are derived from the audit records of the captures
+	var cloudWatchSink *audit.CloudWatchSink
+	if cloudWatchNamespace != "" {
+		cloudWatchSink, err = audit.NewCloudWatchSink(context.Background(), cloudWatchNamespace, cloudWatchRegion, cloudWatchInterval)
+		if err != nil {
+			setupLog.Error(err, "unable to set up CloudWatch metrics")
+			os.Exit(1)
+		}
+		if auditSink == nil {
+			auditSink = cloudWatchSink
+		} else {
+			auditSink = audit.MultiSink{auditSink, cloudWatchSink}
+		}
+	}
+
+	// Per-pod usage history, shared by the reconciler and the metrics endpoint
+	history := metrics.NewHistory(historySize)
+
+	// Per-pod usage gauges, suppressed captures and scheduled checks, served
+	// with the controller metrics
+	usageGauges := metrics.NewUsageGauges()
+	suppressions := metrics.NewSuppressions()
+	schedulerMetrics := metrics.NewSchedulerMetrics()
+	panics := metrics.NewPanics()
+	ctrlmetrics.Registry.MustRegister(usageGauges, suppressions, schedulerMetrics, panics)
+
+	extraHandlers := map[string]http.Handler{
+		metrics.HistoryPath: metrics.NewHistoryHandler(history),
+	}
+	if enableUI && (captureIndex == nil || apiOptions.BindAddress == "") {
+		setupLog.Error(nil, "the web UI requires a capture index and the HTTP API, set --index-dsn and --api-bind-address")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
-			BindAddress: metricsAddr,
+			BindAddress:   metricsAddr,
+			ExtraHandlers: extraHandlers,
 		},
 		HealthProbeBindAddress: probeAddr,
+		PprofBindAddress:       pprofAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "bolometer.bolometer.io",
 	})
@@ -77,17 +235,98 @@ func main() {
 	}
 
 	// Setup reconciler
-	if err = controller.NewProfilingConfigReconciler(
+	reconciler := controller.NewProfilingConfigReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
 		clientset,
 		metricsClient,
 		restConfig,
-	).SetupWithManager(mgr); err != nil {
+		controller.ReconcilerOptions{
+			History:              history,
+			UsageGauges:          usageGauges,
+			Suppressions:         suppressions,
+			CaptureWorkers:       captureWorkers,
+			CheckWorkers:         checkWorkers,
+			SchedulerMetrics:     schedulerMetrics,
+			Panics:               panics,
+			MaxPortForwards:      maxPortForwards,
+			MaxCapturesPerMinute: maxCapturesPerMinute,
+			Audit:                auditSink,
+			LeaseIdentity:        leaseIdentity,
+			LeaseDuration:        configLeaseDuration,
+		},
+	)
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ProfilingConfig")
 		os.Exit(1)
 	}
 
+	// Setup namespace defaults
+	if configTemplatesNamespace != "" {
+		namespaceDefaults := &controller.NamespaceDefaultsReconciler{
+			Client:            mgr.GetClient(),
+			TemplateNamespace: configTemplatesNamespace,
+		}
+		if err = namespaceDefaults.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceDefaults")
+			os.Exit(1)
+		}
+	}
+
+	// Setup self-profiling
+	if selfProfilingInterval > 0 {
+		selfProfiler, err := controller.NewSelfProfiler(controller.SelfProfilerOptions{
+			PprofAddress: pprofAddr,
+			Interval:     selfProfilingInterval,
+			ProfileTypes: strings.Split(selfProfilingTypes, ","),
+			S3Config:     selfProfilingS3,
+			Audit:        auditSink,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to set up self-profiling")
+			os.Exit(1)
+		}
+		if err := mgr.Add(selfProfiler); err != nil {
+			setupLog.Error(err, "unable to add self-profiler")
+			os.Exit(1)
+		}
+	}
+
+	if cloudWatchSink != nil {
+		if err := mgr.Add(cloudWatchSink); err != nil {
+			setupLog.Error(err, "unable to add CloudWatch metrics publisher")
+			os.Exit(1)
+		}
+	}
+
+	if apiOptions.BindAddress != "" {
+		apiServer := api.NewServer(mgr.GetClient(), apiOptions)
+		// The capture index and the web UI are served to the callers that may
+		// get the ProfileCaptures of the namespaces of its captures
+		if captureIndex != nil {
+			apiServer.Handle(index.CapturesPath, index.NewHandler(captureIndex, apiServer))
+		}
+		if enableUI {
+			downloader, err := uploader.NewS3Uploader(context.Background(), uiS3)
+			if err != nil {
+				setupLog.Error(err, "unable to create S3 client for the web UI")
+				os.Exit(1)
+			}
+			uiHandler, err := ui.NewHandler(captureIndex, downloader, apiServer)
+			if err != nil {
+				setupLog.Error(err, "unable to set up the web UI")
+				os.Exit(1)
+			}
+			apiServer.Handle(ui.Path, uiHandler)
+		}
+		if err := mgr.Add(apiServer); err != nil {
+			setupLog.Error(err, "unable to add API server")
+			os.Exit(1)
+		}
+	} else if captureIndex != nil {
+		setupLog.Info("the capture index is not queryable without the HTTP API, set --api-bind-address")
+	}
+
 	// Add health checks
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -97,6 +336,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := reconciler.AddHealthChecks(mgr); err != nil {
+		setupLog.Error(err, "unable to set up controller health checks")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -104,3 +347,33 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newAuditSink builds the audit sink from the audit flags, nil if auditing is disabled
+func newAuditSink(ctx context.Context, logPath string, s3Config uploader.S3Config) (audit.Sink, error) {
+	var sinks audit.MultiSink
+
+	switch logPath {
+	case "":
+	case "-":
+		sinks = append(sinks, audit.NewWriterSink(os.Stdout))
+	default:
+		file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, audit.NewWriterSink(file))
+	}
+
+	if s3Config.Bucket != "" {
+		s3Sink, err := audit.NewS3Sink(ctx, s3Config)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s3Sink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return sinks, nil
+}
@@ -0,0 +1,15 @@
+// Package crd embeds the CustomResourceDefinitions of the operator, so tools
+// can validate manifests against them offline
+package crd
+
+import _ "embed"
+
+// ProfilingConfigs is the ProfilingConfig CustomResourceDefinition
+//
+//go:embed bolometer.io_profilingconfigs.yaml
+var ProfilingConfigs []byte
+
+// BolometerSettings is the BolometerSettings CustomResourceDefinition
+//
+//go:embed bolometer.io_bolometersettings.yaml
+var BolometerSettings []byte
@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/google/pprof/profile"
+)
+
+// FunctionDelta is the change of a function's share of a profile against its
+// baseline
+type FunctionDelta struct {
+	Name string `json:"name"`
+
+	// BaselinePercent and CurrentPercent are the flat shares of the function in
+	// the baseline and current profiles
+	BaselinePercent float64 `json:"baselinePercent"`
+	CurrentPercent  float64 `json:"currentPercent"`
+
+	// DeltaPercent is the growth of the share in percentage points
+	DeltaPercent float64 `json:"deltaPercent"`
+}
+
+// Comparison compares a profile against its baseline
+type Comparison struct {
+	// Type is the profile type, e.g. cpu
+	Type string `json:"type"`
+
+	// Baseline is the S3 key of the baseline profile
+	Baseline string `json:"baseline"`
+
+	// SampleType and Unit describe the compared values
+	SampleType string `json:"sampleType"`
+	Unit       string `json:"unit"`
+
+	// BaselineTotal and CurrentTotal are the sums of the compared values, per
+	// second for profiles covering a duration such as CPU profiles
+	BaselineTotal float64 `json:"baselineTotal"`
+	CurrentTotal  float64 `json:"currentTotal"`
+
+	// ChangePercent is the change of the current total over the baseline total
+	ChangePercent float64 `json:"changePercent"`
+
+	// Regressions are the functions whose share grew the most
+	Regressions []FunctionDelta `json:"regressions,omitempty"`
+}
+
+// ComparisonReport compares the profiles of a capture against a baseline
+type ComparisonReport struct {
+	// Bucket holds the baseline profiles
+	Bucket string `json:"bucket"`
+
+	// ThresholdPercent is the change above which a profile counts as regressed
+	ThresholdPercent float64 `json:"thresholdPercent"`
+
+	// Score is the largest change of a profile over its baseline, in percent
+	Score float64 `json:"score"`
+
+	// Regressed is set when the score exceeds the threshold
+	Regressed bool `json:"regressed"`
+
+	Profiles []Comparison `json:"profiles"`
+
+	// Errors lists the baselines that could not be compared
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Compare parses a baseline and a current pprof profile of the same type and
+// compares their totals and the flat shares of their functions, listing the n
+// functions whose share grew the most
+func Compare(profileType string, baseline, current []byte, n int) (Comparison, error) {
+	base, err := profile.Parse(bytes.NewReader(baseline))
+	if err != nil {
+		return Comparison{}, fmt.Errorf("failed to parse baseline %s profile: %w", profileType, err)
+	}
+	cur, err := profile.Parse(bytes.NewReader(current))
+	if err != nil {
+		return Comparison{}, fmt.Errorf("failed to parse %s profile: %w", profileType, err)
+	}
+
+	baseIndex, err := sampleIndex(base, profileType)
+	if err != nil {
+		return Comparison{}, err
+	}
+	curIndex, err := sampleIndex(cur, profileType)
+	if err != nil {
+		return Comparison{}, err
+	}
+	sampleType := cur.SampleType[curIndex]
+	if base.SampleType[baseIndex].Type != sampleType.Type {
+		return Comparison{}, fmt.Errorf("baseline %s profile has sample type %s, not %s",
+			profileType, base.SampleType[baseIndex].Type, sampleType.Type)
+	}
+
+	baseFlat, baseTotal := flatValues(base, baseIndex)
+	curFlat, curTotal := flatValues(cur, curIndex)
+	if baseTotal == 0 {
+		return Comparison{}, fmt.Errorf("baseline %s profile has no samples", profileType)
+	}
+
+	comparison := Comparison{
+		Type:          profileType,
+		SampleType:    sampleType.Type,
+		Unit:          sampleType.Unit,
+		BaselineTotal: float64(baseTotal),
+		CurrentTotal:  float64(curTotal),
+	}
+	// Profiles covering a duration are compared per second, so a 30s capture
+	// can be compared to a 10s baseline
+	if base.DurationNanos > 0 && cur.DurationNanos > 0 {
+		comparison.BaselineTotal /= float64(base.DurationNanos) / 1e9
+		comparison.CurrentTotal /= float64(cur.DurationNanos) / 1e9
+	}
+	comparison.ChangePercent = math.Round((comparison.CurrentTotal-comparison.BaselineTotal)/comparison.BaselineTotal*10000) / 100
+
+	for name, value := range curFlat {
+		delta := FunctionDelta{Name: name, BaselinePercent: roundPercent(baseFlat[name], baseTotal)}
+		if curTotal != 0 {
+			delta.CurrentPercent = roundPercent(value, curTotal)
+		}
+		delta.DeltaPercent = math.Round((delta.CurrentPercent-delta.BaselinePercent)*100) / 100
+		if delta.DeltaPercent > 0 {
+			comparison.Regressions = append(comparison.Regressions, delta)
+		}
+	}
+	sort.Slice(comparison.Regressions, func(i, j int) bool {
+		a, b := comparison.Regressions[i], comparison.Regressions[j]
+		if a.DeltaPercent != b.DeltaPercent {
+			return a.DeltaPercent > b.DeltaPercent
+		}
+		return a.Name < b.Name
+	})
+	if n > 0 && len(comparison.Regressions) > n {
+		comparison.Regressions = comparison.Regressions[:n]
+	}
+
+	return comparison, nil
+}
+
+// flatValues returns the flat value of every function of a profile and their total
+func flatValues(p *profile.Profile, index int) (map[string]int64, int64) {
+	flat := make(map[string]int64)
+	var total int64
+	for _, sample := range p.Sample {
+		value := sample.Value[index]
+		total += value
+		if value == 0 || len(sample.Location) == 0 || len(sample.Location[0].Line) == 0 {
+			continue
+		}
+		flat[functionName(sample.Location[0].Line[0])] += value
+	}
+	return flat, total
+}
+
+// Add adds a comparison to the report, raising its score to the comparison's change
+func (r *ComparisonReport) Add(comparison Comparison) {
+	if len(r.Profiles) == 0 || comparison.ChangePercent > r.Score {
+		r.Score = comparison.ChangePercent
+	}
+	r.Regressed = r.Score > r.ThresholdPercent
+	r.Profiles = append(r.Profiles, comparison)
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// cpuProfile builds a CPU profile covering seconds with a sample per function
+// holding its CPU time in nanoseconds
+func cpuProfile(t *testing.T, seconds int64, values map[string]int64) []byte {
+	t.Helper()
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		DurationNanos: seconds * 1e9,
+	}
+	for name, value := range values {
+		id := uint64(len(p.Function) + 1)
+		fn := &profile.Function{ID: id, Name: name}
+		location := &profile.Location{ID: id, Line: []profile.Line{{Function: fn}}}
+		p.Function = append(p.Function, fn)
+		p.Location = append(p.Location, location)
+		p.Sample = append(p.Sample, &profile.Sample{Location: []*profile.Location{location}, Value: []int64{1, value}})
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompare(t *testing.T) {
+	// The baseline spends 1s of CPU per second, half of it in main.encode
+	baseline := cpuProfile(t, 10, map[string]int64{"main.encode": 5e9, "main.decode": 5e9})
+	// The capture spends 1.5s per second, two thirds of it in main.encode
+	current := cpuProfile(t, 30, map[string]int64{"main.encode": 30e9, "main.decode": 15e9})
+
+	comparison, err := Compare("cpu", baseline, current, DefaultTopN)
+	if err != nil {
+		t.Fatalf("Compare returned unexpected error: %v", err)
+	}
+
+	if comparison.SampleType != "cpu" || comparison.BaselineTotal != 1e9 || comparison.CurrentTotal != 1.5e9 {
+		t.Errorf("Unexpected comparison: %+v", comparison)
+	}
+	if comparison.ChangePercent != 50 {
+		t.Errorf("Expected a 50%% change, got %v", comparison.ChangePercent)
+	}
+
+	if len(comparison.Regressions) != 1 {
+		t.Fatalf("Expected 1 regressed function, got %+v", comparison.Regressions)
+	}
+	if regression := comparison.Regressions[0]; regression.Name != "main.encode" ||
+		regression.BaselinePercent != 50 || regression.CurrentPercent != 66.67 || regression.DeltaPercent != 16.67 {
+		t.Errorf("Unexpected regression: %+v", regression)
+	}
+}
+
+func TestCompare_EmptyBaseline(t *testing.T) {
+	baseline := cpuProfile(t, 10, nil)
+	current := cpuProfile(t, 10, map[string]int64{"main.encode": 1e9})
+
+	if _, err := Compare("cpu", baseline, current, DefaultTopN); err == nil {
+		t.Error("Expected error for a baseline without samples")
+	}
+}
+
+func TestComparisonReport_Add(t *testing.T) {
+	report := ComparisonReport{ThresholdPercent: 10}
+
+	report.Add(Comparison{Type: "heap", ChangePercent: -20})
+	if report.Score != -20 || report.Regressed {
+		t.Errorf("Expected score -20 without regression, got %+v", report)
+	}
+
+	report.Add(Comparison{Type: "cpu", ChangePercent: 12.5})
+	if report.Score != 12.5 || !report.Regressed {
+		t.Errorf("Expected score 12.5 with regression, got %+v", report)
+	}
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// Fingerprint parses a pprof profile and hashes its samples: the frames of
+// each stack and all of its values. Profiles with the same samples share a
+// fingerprint even if their timestamps, durations, addresses or sample order
+// differ, e.g. two goroutine profiles of an idle pod.
+func Fingerprint(profileType string, data []byte) (string, error) {
+	p, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s profile: %w", profileType, err)
+	}
+
+	samples := make([]string, 0, len(p.Sample))
+	for _, sample := range p.Sample {
+		var b strings.Builder
+		for _, value := range sample.Value {
+			b.WriteString(strconv.FormatInt(value, 10))
+			b.WriteByte(' ')
+		}
+		for _, location := range sample.Location {
+			for _, line := range location.Line {
+				b.WriteString(stackSeparator)
+				b.WriteString(functionName(line))
+			}
+		}
+		samples = append(samples, b.String())
+	}
+	sort.Strings(samples)
+
+	hash := sha256.New()
+	for _, sampleType := range p.SampleType {
+		fmt.Fprintf(hash, "%s/%s\x00", sampleType.Type, sampleType.Unit)
+	}
+	for _, sample := range samples {
+		hash.Write([]byte(sample))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestFingerprint(t *testing.T) {
+	data := testProfile(t)
+	fingerprint, err := Fingerprint("heap", data)
+	if err != nil {
+		t.Fatalf("Fingerprint returned unexpected error: %v", err)
+	}
+
+	// The same samples captured later, in another order
+	p, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	p.TimeNanos += 60e9
+	p.Sample[0], p.Sample[1] = p.Sample[1], p.Sample[0]
+	var later bytes.Buffer
+	if err := p.Write(&later); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if bytes.Equal(later.Bytes(), data) {
+		t.Fatal("Expected the later profile to differ in bytes")
+	}
+	if got, err := Fingerprint("heap", later.Bytes()); err != nil || got != fingerprint {
+		t.Errorf("Expected the fingerprint %s of the same samples, got %s (%v)", fingerprint, got, err)
+	}
+
+	// A sample value changed
+	p.Sample[0].Value[1]++
+	var changed bytes.Buffer
+	if err := p.Write(&changed); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if got, err := Fingerprint("heap", changed.Bytes()); err != nil || got == fingerprint {
+		t.Errorf("Expected another fingerprint for changed samples, got %s (%v)", got, err)
+	}
+}
+
+func TestFingerprint_InvalidProfile(t *testing.T) {
+	if _, err := Fingerprint("heap", []byte("not a profile")); err == nil {
+		t.Error("Expected an error for an invalid profile")
+	}
+}
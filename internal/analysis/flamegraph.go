@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/google/pprof/profile"
+)
+
+// flamegraphMinFraction is the share of the total below which frames are left
+// out of a flamegraph. They would be too narrow to see, and large profiles
+// have tens of thousands of them.
+const flamegraphMinFraction = 0.001
+
+// Frame is a function in a flamegraph, with the value of the samples whose
+// stacks reach it through the same callers
+type Frame struct {
+	Name     string   `json:"name"`
+	Value    int64    `json:"value"`
+	Children []*Frame `json:"children,omitempty"`
+
+	children map[string]*Frame
+}
+
+// Flamegraph is the call tree of a profile, from a root frame holding the
+// total down to the functions samples were taken in
+type Flamegraph struct {
+	// Type is the profile type, e.g. heap
+	Type string `json:"type"`
+
+	// SampleType and Unit describe the frame values, e.g. inuse_space in bytes
+	SampleType string `json:"sampleType"`
+	Unit       string `json:"unit"`
+
+	Root *Frame `json:"root"`
+}
+
+// BuildFlamegraph parses a pprof profile, gzipped or not, into a flamegraph of
+// the sample type summarized for its profile type. Children are ordered by
+// name, and frames under 0.1% of the total are left out.
+func BuildFlamegraph(profileType string, data []byte) (*Flamegraph, error) {
+	p, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s profile: %w", profileType, err)
+	}
+
+	index, err := sampleIndex(p, profileType)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Frame{Name: "root"}
+	for _, sample := range p.Sample {
+		value := sample.Value[index]
+		if value == 0 {
+			continue
+		}
+		root.Value += value
+
+		// Locations go from the leaf to the root, and inlined functions come
+		// first in a location's lines
+		frame := root
+		for i := len(sample.Location) - 1; i >= 0; i-- {
+			lines := sample.Location[i].Line
+			for j := len(lines) - 1; j >= 0; j-- {
+				frame = frame.child(functionName(lines[j]))
+				frame.Value += value
+			}
+		}
+	}
+	root.finish(int64(float64(root.Value) * flamegraphMinFraction))
+
+	sampleType := p.SampleType[index]
+	return &Flamegraph{
+		Type:       profileType,
+		SampleType: sampleType.Type,
+		Unit:       sampleType.Unit,
+		Root:       root,
+	}, nil
+}
+
+// child returns the frame of a function called from f, adding it if needed
+func (f *Frame) child(name string) *Frame {
+	if f.children == nil {
+		f.children = make(map[string]*Frame)
+	}
+	child, ok := f.children[name]
+	if !ok {
+		child = &Frame{Name: name}
+		f.children[name] = child
+	}
+	return child
+}
+
+// finish lists the children of the frames under f by name, leaving out those
+// with a value under minValue
+func (f *Frame) finish(minValue int64) {
+	for _, child := range f.children {
+		if child.Value < minValue || child.Value == 0 {
+			continue
+		}
+		child.finish(minValue)
+		f.Children = append(f.Children, child)
+	}
+	sort.Slice(f.Children, func(i, j int) bool {
+		return f.Children[i].Name < f.Children[j].Name
+	})
+	f.children = nil
+}
@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestBuildFlamegraph(t *testing.T) {
+	flamegraph, err := BuildFlamegraph("heap", testProfile(t))
+	if err != nil {
+		t.Fatalf("BuildFlamegraph returned unexpected error: %v", err)
+	}
+
+	if flamegraph.SampleType != "inuse_space" || flamegraph.Unit != "bytes" {
+		t.Errorf("Unexpected sample type %s in %s", flamegraph.SampleType, flamegraph.Unit)
+	}
+
+	root := flamegraph.Root
+	if root.Name != "root" || root.Value != 4096 || len(root.Children) != 1 {
+		t.Fatalf("Unexpected root %+v", root)
+	}
+	handler := root.Children[0]
+	if handler.Name != "main.handler" || handler.Value != 4096 || len(handler.Children) != 1 {
+		t.Fatalf("Expected main.handler under the root, got %+v", handler)
+	}
+	alloc := handler.Children[0]
+	if alloc.Name != "main.alloc" || alloc.Value != 3072 || len(alloc.Children) != 1 {
+		t.Fatalf("Expected main.alloc under main.handler, got %+v", alloc)
+	}
+	if leaf := alloc.Children[0]; leaf.Name != "runtime.mallocgc" || leaf.Value != 3072 || leaf.Children != nil {
+		t.Errorf("Expected runtime.mallocgc as the leaf, got %+v", leaf)
+	}
+}
+
+func TestBuildFlamegraph_InvalidProfile(t *testing.T) {
+	if _, err := BuildFlamegraph("heap", []byte("not a profile")); err == nil {
+		t.Error("Expected error for an invalid profile")
+	}
+}
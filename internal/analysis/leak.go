@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// stackSeparator joins the frames of a stack in stack keys
+const stackSeparator = "\n"
+
+// Stacks holds the value of each distinct stack of a profile, keyed by its
+// frames from the innermost one, for the sample type summarized for its type
+type Stacks struct {
+	SampleType string
+	Unit       string
+	Values     map[string]int64
+}
+
+// Leak is a stack whose value grew across every one of a series of profiles
+type Leak struct {
+	// Type is the profile type, e.g. heap
+	Type string `json:"type"`
+
+	// SampleType and Unit describe the values, e.g. inuse_space in bytes
+	SampleType string `json:"sampleType"`
+	Unit       string `json:"unit"`
+
+	// Stack holds the frames of the stack, innermost first
+	Stack []string `json:"stack"`
+
+	// Values are the values of the stack in each profile, oldest first
+	Values []int64 `json:"values"`
+
+	// Growth is the growth of the value from the first to the last profile
+	Growth int64 `json:"growth"`
+}
+
+// StackValues parses a pprof profile and sums the values of each of its stacks
+func StackValues(profileType string, data []byte) (Stacks, error) {
+	p, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		return Stacks{}, fmt.Errorf("failed to parse %s profile: %w", profileType, err)
+	}
+
+	index, err := sampleIndex(p, profileType)
+	if err != nil {
+		return Stacks{}, err
+	}
+
+	stacks := Stacks{
+		SampleType: p.SampleType[index].Type,
+		Unit:       p.SampleType[index].Unit,
+		Values:     make(map[string]int64),
+	}
+	for _, sample := range p.Sample {
+		value := sample.Value[index]
+		if value == 0 {
+			continue
+		}
+
+		var frames []string
+		for _, location := range sample.Location {
+			for _, line := range location.Line {
+				frames = append(frames, functionName(line))
+			}
+		}
+		stacks.Values[strings.Join(frames, stackSeparator)] += value
+	}
+	return stacks, nil
+}
+
+// DetectLeaks returns the stacks whose value grew from each profile of a series
+// to the next, oldest first, by at least minGrowth overall. Stacks missing from
+// a profile are not considered growing. Leaks are sorted by growth.
+func DetectLeaks(profileType string, series []Stacks, minGrowth int64) []Leak {
+	if len(series) < 2 {
+		return nil
+	}
+
+	first, last := series[0], series[len(series)-1]
+	var leaks []Leak
+	for stack, value := range last.Values {
+		values := make([]int64, 0, len(series))
+		growing := true
+		for i, stacks := range series {
+			v, ok := stacks.Values[stack]
+			if !ok || (i > 0 && v <= values[i-1]) {
+				growing = false
+				break
+			}
+			values = append(values, v)
+		}
+		if !growing || value-first.Values[stack] < minGrowth {
+			continue
+		}
+
+		leaks = append(leaks, Leak{
+			Type:       profileType,
+			SampleType: last.SampleType,
+			Unit:       last.Unit,
+			Stack:      strings.Split(stack, stackSeparator),
+			Values:     values,
+			Growth:     value - first.Values[stack],
+		})
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Growth != leaks[j].Growth {
+			return leaks[i].Growth > leaks[j].Growth
+		}
+		return strings.Join(leaks[i].Stack, stackSeparator) < strings.Join(leaks[j].Stack, stackSeparator)
+	})
+	return leaks
+}
+
+// FormatGrowth formats the growth of a leak in its unit
+func (l Leak) FormatGrowth() string {
+	return Summary{Unit: l.Unit}.FormatValue(l.Growth)
+}
+
+// Site returns the innermost frame of the leak outside the Go runtime, where
+// the growing memory or goroutines originate
+func (l Leak) Site() string {
+	for _, frame := range l.Stack {
+		if !strings.HasPrefix(frame, "runtime.") {
+			return frame
+		}
+	}
+	if len(l.Stack) == 0 {
+		return ""
+	}
+	return l.Stack[0]
+}
@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestStackValues(t *testing.T) {
+	stacks, err := StackValues("heap", testProfile(t))
+	if err != nil {
+		t.Fatalf("StackValues returned unexpected error: %v", err)
+	}
+
+	if stacks.SampleType != "inuse_space" || stacks.Unit != "bytes" {
+		t.Errorf("Unexpected sample type %s in %s", stacks.SampleType, stacks.Unit)
+	}
+	if value := stacks.Values["runtime.mallocgc\nmain.alloc\nmain.handler"]; value != 3072 {
+		t.Errorf("Expected 3072 bytes allocated through main.alloc, got %d in %v", value, stacks.Values)
+	}
+	if value := stacks.Values["main.handler"]; value != 1024 {
+		t.Errorf("Expected 1024 bytes allocated in main.handler, got %d in %v", value, stacks.Values)
+	}
+}
+
+func TestDetectLeaks(t *testing.T) {
+	series := func(values ...map[string]int64) []Stacks {
+		stacks := make([]Stacks, len(values))
+		for i, v := range values {
+			stacks[i] = Stacks{SampleType: "inuse_space", Unit: "bytes", Values: v}
+		}
+		return stacks
+	}
+
+	leaks := DetectLeaks("heap", series(
+		map[string]int64{"main.cache": 1 << 20, "main.buffer": 4 << 20, "main.session": 1 << 20},
+		map[string]int64{"main.cache": 2 << 20, "main.buffer": 2 << 20, "main.session": 1<<20 + 1},
+		map[string]int64{"main.cache": 4 << 20, "main.buffer": 8 << 20, "main.session": 1<<20 + 2},
+	), 1<<20)
+
+	// main.buffer shrank once and main.session grew by less than the minimum
+	if len(leaks) != 1 {
+		t.Fatalf("Expected 1 leak, got %+v", leaks)
+	}
+	leak := leaks[0]
+	if leak.Stack[0] != "main.cache" || leak.Growth != 3<<20 || len(leak.Values) != 3 {
+		t.Errorf("Unexpected leak %+v", leak)
+	}
+	if growth := leak.FormatGrowth(); growth != "3.0MiB" {
+		t.Errorf("Expected growth of 3.0MiB, got %s", growth)
+	}
+}
+
+func TestDetectLeaks_SingleProfile(t *testing.T) {
+	stacks := []Stacks{{Values: map[string]int64{"main.cache": 1 << 20}}}
+	if leaks := DetectLeaks("heap", stacks, 0); leaks != nil {
+		t.Errorf("Expected no leaks from a single profile, got %+v", leaks)
+	}
+}
@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/pprof/profile"
+)
+
+// Merge combines pprof profiles of the same type, e.g. captured from several
+// replicas of a service, into a single gzipped profile. Samples with identical
+// stacks are summed.
+func Merge(profileType string, profiles [][]byte) ([]byte, error) {
+	parsed := make([]*profile.Profile, 0, len(profiles))
+	for _, data := range profiles {
+		p, err := profile.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s profile: %w", profileType, err)
+		}
+		parsed = append(parsed, p)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no %s profiles to merge", profileType)
+	}
+
+	merged, err := profile.Merge(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge %s profiles: %w", profileType, err)
+	}
+
+	var buf bytes.Buffer
+	if err := merged.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed to encode merged %s profile: %w", profileType, err)
+	}
+	return buf.Bytes(), nil
+}
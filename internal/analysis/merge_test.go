@@ -0,0 +1,32 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	merged, err := Merge("heap", [][]byte{testProfile(t), testProfile(t)})
+	if err != nil {
+		t.Fatalf("Merge returned unexpected error: %v", err)
+	}
+
+	summary, err := Summarize("heap", merged, DefaultTopN)
+	if err != nil {
+		t.Fatalf("Failed to summarize merged profile: %v", err)
+	}
+	if summary.Total != 2*4096 {
+		t.Errorf("Expected the merged profile to sum both profiles, got total %d", summary.Total)
+	}
+	if first := summary.TopFlat[0]; first.Name != "runtime.mallocgc" || first.Flat != 2*3072 {
+		t.Errorf("Unexpected top function of merged profile: %+v", first)
+	}
+}
+
+func TestMerge_InvalidProfile(t *testing.T) {
+	if _, err := Merge("heap", [][]byte{testProfile(t), []byte("not a profile")}); err == nil {
+		t.Error("Expected error for an invalid profile")
+	}
+	if _, err := Merge("heap", nil); err == nil {
+		t.Error("Expected error without profiles")
+	}
+}
@@ -0,0 +1,232 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// DefaultTopN is the number of functions listed per profile in a summary
+const DefaultTopN = 10
+
+// HighlightN is the number of functions carried in capture events
+const HighlightN = 3
+
+// preferredSampleTypes names the sample type summarized for a profile type.
+// Other profile types use their default sample type.
+var preferredSampleTypes = map[string]string{
+	"cpu":    "cpu",
+	"heap":   "inuse_space",
+	"allocs": "alloc_space",
+}
+
+// Function is the share of a function in a profile
+type Function struct {
+	Name        string  `json:"name"`
+	Flat        int64   `json:"flat"`
+	FlatPercent float64 `json:"flatPercent"`
+	Cum         int64   `json:"cum"`
+	CumPercent  float64 `json:"cumPercent"`
+}
+
+// Summary lists the top functions of a profile by flat and cumulative value
+type Summary struct {
+	// Type is the profile type, e.g. heap
+	Type string `json:"type"`
+
+	// SampleType and Unit describe the summarized values, e.g. inuse_space in bytes
+	SampleType string `json:"sampleType"`
+	Unit       string `json:"unit"`
+
+	// Total is the sum of the summarized values over all samples
+	Total int64 `json:"total"`
+
+	TopFlat []Function `json:"topFlat"`
+	TopCum  []Function `json:"topCum,omitempty"`
+}
+
+// Report summarizes the profiles of a capture
+type Report struct {
+	Profiles []Summary `json:"profiles"`
+}
+
+// Summarize parses a pprof profile, gzipped or not, and lists its top n
+// functions by flat and cumulative value
+func Summarize(profileType string, data []byte, n int) (Summary, error) {
+	p, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to parse %s profile: %w", profileType, err)
+	}
+
+	index, err := sampleIndex(p, profileType)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	functions := make(map[string]*Function)
+	var total int64
+	for _, sample := range p.Sample {
+		value := sample.Value[index]
+		if value == 0 {
+			continue
+		}
+		total += value
+
+		seen := make(map[string]bool)
+		for i, location := range sample.Location {
+			// Inlined functions come first in a location's lines
+			for j, line := range location.Line {
+				name := functionName(line)
+				fn, ok := functions[name]
+				if !ok {
+					fn = &Function{Name: name}
+					functions[name] = fn
+				}
+				if i == 0 && j == 0 {
+					fn.Flat += value
+				}
+				if !seen[name] {
+					seen[name] = true
+					fn.Cum += value
+				}
+			}
+		}
+	}
+
+	all := make([]Function, 0, len(functions))
+	for _, fn := range functions {
+		if total != 0 {
+			fn.FlatPercent = roundPercent(fn.Flat, total)
+			fn.CumPercent = roundPercent(fn.Cum, total)
+		}
+		all = append(all, *fn)
+	}
+
+	sampleType := p.SampleType[index]
+	return Summary{
+		Type:       profileType,
+		SampleType: sampleType.Type,
+		Unit:       sampleType.Unit,
+		Total:      total,
+		TopFlat:    top(all, n, func(fn Function) int64 { return fn.Flat }),
+		TopCum:     top(all, n, func(fn Function) int64 { return fn.Cum }),
+	}, nil
+}
+
+// sampleIndex returns the index of the sample type summarized for a profile type
+func sampleIndex(p *profile.Profile, profileType string) (int, error) {
+	if len(p.SampleType) == 0 {
+		return 0, fmt.Errorf("%s profile has no sample types", profileType)
+	}
+
+	wanted := preferredSampleTypes[profileType]
+	if wanted == "" {
+		wanted = p.DefaultSampleType
+	}
+	for i, sampleType := range p.SampleType {
+		if sampleType.Type == wanted {
+			return i, nil
+		}
+	}
+
+	// Profiles default to their last sample type
+	return len(p.SampleType) - 1, nil
+}
+
+// functionName returns the name of the function of a line, <unknown> when
+// the profile is not symbolized
+func functionName(line profile.Line) string {
+	if line.Function == nil || line.Function.Name == "" {
+		return "<unknown>"
+	}
+	return line.Function.Name
+}
+
+// top returns the n functions with the largest non-zero value, by name on ties
+func top(functions []Function, n int, value func(Function) int64) []Function {
+	sorted := make([]Function, 0, len(functions))
+	for _, fn := range functions {
+		if value(fn) != 0 {
+			sorted = append(sorted, fn)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if value(sorted[i]) != value(sorted[j]) {
+			return value(sorted[i]) > value(sorted[j])
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// roundPercent returns value as a percentage of total, to two decimals
+func roundPercent(value, total int64) float64 {
+	return math.Round(float64(value)/float64(total)*10000) / 100
+}
+
+// Highlights returns the summary with only its top n functions by flat value,
+// as carried in capture events
+func (s Summary) Highlights(n int) Summary {
+	highlights := s
+	highlights.TopCum = nil
+	if len(highlights.TopFlat) > n {
+		highlights.TopFlat = highlights.TopFlat[:n]
+	}
+	return highlights
+}
+
+// FormatValue formats a value of the summary in its unit
+func (s Summary) FormatValue(value int64) string {
+	switch s.Unit {
+	case "bytes":
+		return formatBytes(value)
+	case "nanoseconds":
+		return time.Duration(value).Round(time.Millisecond).String()
+	default:
+		return fmt.Sprintf("%d", value)
+	}
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(value int64) string {
+	const unit = 1024
+	if value < unit && value > -unit {
+		return fmt.Sprintf("%dB", value)
+	}
+	div, exp := int64(unit), 0
+	for n := value / unit; n >= unit || n <= -unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(value)/float64(div), "KMGTPE"[exp])
+}
+
+// Text formats the report as pprof-style top tables
+func (r Report) Text() string {
+	var b strings.Builder
+	for i, summary := range r.Profiles {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s (total %s)\n", summary.Type, summary.SampleType, summary.FormatValue(summary.Total))
+
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "flat\tflat%\tcum\tcum%\t\t")
+		for _, fn := range summary.TopFlat {
+			fmt.Fprintf(w, "%s\t%.2f%%\t%s\t%.2f%%\t\t%s\n",
+				summary.FormatValue(fn.Flat), fn.FlatPercent, summary.FormatValue(fn.Cum), fn.CumPercent, fn.Name)
+		}
+		w.Flush()
+	}
+	return b.String()
+}
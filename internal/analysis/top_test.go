@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// testProfile builds a heap profile where main.handler calls main.alloc, which
+// calls runtime.mallocgc, plus a smaller direct allocation in main.handler
+func testProfile(t *testing.T) []byte {
+	t.Helper()
+
+	functions := []*profile.Function{
+		{ID: 1, Name: "runtime.mallocgc"},
+		{ID: 2, Name: "main.alloc"},
+		{ID: 3, Name: "main.handler"},
+	}
+	locations := make([]*profile.Location, len(functions))
+	for i, fn := range functions {
+		locations[i] = &profile.Location{ID: uint64(i + 1), Line: []profile.Line{{Function: fn}}}
+	}
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "alloc_space", Unit: "bytes"},
+			{Type: "inuse_space", Unit: "bytes"},
+		},
+		DefaultSampleType: "alloc_space",
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locations[0], locations[1], locations[2]}, Value: []int64{900, 3072}},
+			{Location: []*profile.Location{locations[2]}, Value: []int64{100, 1024}},
+		},
+		Location: locations,
+		Function: functions,
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSummarize(t *testing.T) {
+	summary, err := Summarize("heap", testProfile(t), 2)
+	if err != nil {
+		t.Fatalf("Summarize returned unexpected error: %v", err)
+	}
+
+	if summary.SampleType != "inuse_space" || summary.Unit != "bytes" || summary.Total != 4096 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	if len(summary.TopFlat) != 2 {
+		t.Fatalf("Expected 2 functions by flat, got %+v", summary.TopFlat)
+	}
+	if first := summary.TopFlat[0]; first.Name != "runtime.mallocgc" || first.Flat != 3072 || first.FlatPercent != 75 {
+		t.Errorf("Unexpected top function by flat: %+v", first)
+	}
+	if second := summary.TopFlat[1]; second.Name != "main.handler" || second.Flat != 1024 || second.Cum != 4096 {
+		t.Errorf("Unexpected second function by flat: %+v", second)
+	}
+
+	if first := summary.TopCum[0]; first.Name != "main.handler" || first.CumPercent != 100 {
+		t.Errorf("Unexpected top function by cum: %+v", first)
+	}
+}
+
+func TestSummarize_InvalidProfile(t *testing.T) {
+	if _, err := Summarize("heap", []byte("not a profile"), DefaultTopN); err == nil {
+		t.Error("Expected error for an invalid profile")
+	}
+}
+
+func TestSummary_Highlights(t *testing.T) {
+	summary, err := Summarize("heap", testProfile(t), DefaultTopN)
+	if err != nil {
+		t.Fatalf("Summarize returned unexpected error: %v", err)
+	}
+
+	highlights := summary.Highlights(1)
+	if len(highlights.TopFlat) != 1 || highlights.TopCum != nil {
+		t.Errorf("Unexpected highlights: %+v", highlights)
+	}
+	if len(summary.TopFlat) != 2 {
+		t.Errorf("Expected the summary to be left intact, got %+v", summary.TopFlat)
+	}
+}
+
+func TestReport_Text(t *testing.T) {
+	summary, err := Summarize("heap", testProfile(t), DefaultTopN)
+	if err != nil {
+		t.Fatalf("Summarize returned unexpected error: %v", err)
+	}
+
+	text := Report{Profiles: []Summary{summary}}.Text()
+	for _, expected := range []string{"heap inuse_space (total 4.0KiB)", "3.0KiB", "75.00%", "runtime.mallocgc"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in report:\n%s", expected, text)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		unit     string
+		value    int64
+		expected string
+	}{
+		{"bytes", 512, "512B"},
+		{"bytes", 1536, "1.5KiB"},
+		{"bytes", 3 * 1024 * 1024, "3.0MiB"},
+		{"nanoseconds", 1_500_000_000, "1.5s"},
+		{"count", 42, "42"},
+	}
+
+	for _, tt := range tests {
+		if got := (Summary{Unit: tt.unit}).FormatValue(tt.value); got != tt.expected {
+			t.Errorf("FormatValue(%d %s) = %q, expected %q", tt.value, tt.unit, got, tt.expected)
+		}
+	}
+}
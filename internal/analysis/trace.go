@@ -0,0 +1,307 @@
+package analysis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/trace"
+)
+
+// TraceProfileType is the profile type of execution traces
+const TraceProfileType = "trace"
+
+// Range names of the runtime's GC activity in execution traces
+const (
+	gcMarkRange   = "GC concurrent mark phase"
+	gcAssistRange = "GC mark assist"
+	stwPrefix     = "stop-the-world"
+)
+
+// ErrUnsupportedTraceVersion is returned for traces of a Go version the trace
+// parser cannot read, such as one newer than it knows
+var ErrUnsupportedTraceVersion = errors.New("unsupported trace version")
+
+// TraceSummary summarizes an execution trace. Durations are in milliseconds.
+type TraceSummary struct {
+	// DurationMs is the time covered by the trace
+	DurationMs float64 `json:"durationMs"`
+
+	// Goroutines is the number of goroutines seen in the trace
+	Goroutines int `json:"goroutines"`
+
+	GC GCStats `json:"gc"`
+
+	// SchedulerLatency is the time goroutines spent runnable before running
+	SchedulerLatency LatencyStats `json:"schedulerLatency"`
+
+	// Blocked is the time goroutines spent waiting, by reason, longest first
+	Blocked []BlockedStats `json:"blocked,omitempty"`
+}
+
+// GCStats summarizes the garbage collections of a trace
+type GCStats struct {
+	// Cycles is the number of mark phases
+	Cycles int `json:"cycles"`
+
+	// MarkTotalMs is the time spent in concurrent mark phases
+	MarkTotalMs float64 `json:"markTotalMs"`
+
+	// Pauses, PauseTotalMs and PauseMaxMs describe the stop-the-world pauses
+	Pauses       int     `json:"pauses"`
+	PauseTotalMs float64 `json:"pauseTotalMs"`
+	PauseMaxMs   float64 `json:"pauseMaxMs"`
+
+	// AssistTotalMs is the time goroutines were drafted into marking
+	AssistTotalMs float64 `json:"assistTotalMs"`
+}
+
+// LatencyStats describes a distribution of latencies
+type LatencyStats struct {
+	Count  int     `json:"count"`
+	MeanMs float64 `json:"meanMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// BlockedStats is the time goroutines spent waiting for a reason
+type BlockedStats struct {
+	// Reason is the runtime's wait reason, e.g. chan receive
+	Reason string `json:"reason"`
+
+	// Count is the number of times goroutines blocked
+	Count int `json:"count"`
+
+	TotalMs float64 `json:"totalMs"`
+	MaxMs   float64 `json:"maxMs"`
+
+	// AtEnd is the number of goroutines still blocked when the trace ended
+	AtEnd int `json:"atEnd,omitempty"`
+}
+
+// rangeKey identifies an ongoing range of a trace
+type rangeKey struct {
+	name  string
+	scope trace.ResourceID
+}
+
+// waitStart is when and why a goroutine started waiting
+type waitStart struct {
+	reason string
+	since  trace.Time
+}
+
+// waits accumulates the waits of goroutines for a reason
+type waits struct {
+	count, atEnd int
+	total, max   time.Duration
+}
+
+// AnalyzeTrace parses a Go execution trace, as served by /debug/pprof/trace,
+// and summarizes its GC pauses, scheduler latency and blocked goroutines. It
+// returns ErrUnsupportedTraceVersion for traces of a Go version the parser does
+// not know.
+func AnalyzeTrace(data []byte) (TraceSummary, error) {
+	reader, err := trace.NewReader(bytes.NewReader(data))
+	if err != nil {
+		// The parser rejects traces of unknown versions on their header
+		if minor, ok := traceVersion(data); ok {
+			return TraceSummary{}, fmt.Errorf("%w go 1.%d: %v", ErrUnsupportedTraceVersion, minor, err)
+		}
+		return TraceSummary{}, fmt.Errorf("failed to parse trace: %w", err)
+	}
+
+	var (
+		summary     TraceSummary
+		first, last trace.Time
+		events      int
+
+		goroutines    = make(map[trace.GoID]struct{})
+		runnableSince = make(map[trace.GoID]trace.Time)
+		waiting       = make(map[trace.GoID]waitStart)
+		ranges        = make(map[rangeKey]trace.Time)
+		blocked       = make(map[string]*waits)
+
+		latencies                       []time.Duration
+		mark, pauses, pauseMax, assists time.Duration
+	)
+	block := func(reason string, d time.Duration) *waits {
+		if reason == "" {
+			reason = "unknown"
+		}
+		w, ok := blocked[reason]
+		if !ok {
+			w = &waits{}
+			blocked[reason] = w
+		}
+		w.count++
+		w.total += d
+		w.max = max(w.max, d)
+		return w
+	}
+
+	for {
+		ev, err := reader.ReadEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return TraceSummary{}, fmt.Errorf("failed to read trace: %w", err)
+		}
+		if events == 0 {
+			first = ev.Time()
+		}
+		events++
+		last = ev.Time()
+
+		switch ev.Kind() {
+		case trace.EventRangeBegin, trace.EventRangeActive:
+			r := ev.Range()
+			ranges[rangeKey{r.Name, r.Scope}] = ev.Time()
+
+		case trace.EventRangeEnd:
+			r := ev.Range()
+			key := rangeKey{r.Name, r.Scope}
+			start, ok := ranges[key]
+			if !ok {
+				continue
+			}
+			delete(ranges, key)
+
+			d := ev.Time().Sub(start)
+			switch {
+			case r.Name == gcMarkRange:
+				summary.GC.Cycles++
+				mark += d
+			case r.Name == gcAssistRange:
+				assists += d
+			case strings.HasPrefix(r.Name, stwPrefix):
+				summary.GC.Pauses++
+				pauses += d
+				pauseMax = max(pauseMax, d)
+			}
+
+		case trace.EventStateTransition:
+			st := ev.StateTransition()
+			if st.Resource.Kind != trace.ResourceGoroutine {
+				continue
+			}
+			id := st.Resource.Goroutine()
+			goroutines[id] = struct{}{}
+
+			from, to := st.Goroutine()
+			if from == trace.GoRunnable {
+				if since, ok := runnableSince[id]; ok && to == trace.GoRunning {
+					latencies = append(latencies, ev.Time().Sub(since))
+				}
+				delete(runnableSince, id)
+			}
+			if from == trace.GoWaiting {
+				if w, ok := waiting[id]; ok {
+					block(w.reason, ev.Time().Sub(w.since))
+				}
+				delete(waiting, id)
+			}
+
+			switch to {
+			case trace.GoRunnable:
+				runnableSince[id] = ev.Time()
+			case trace.GoWaiting:
+				waiting[id] = waitStart{reason: st.Reason, since: ev.Time()}
+			}
+		}
+	}
+	if events == 0 {
+		return TraceSummary{}, errors.New("trace has no events")
+	}
+
+	// Goroutines still blocked count until the end of the trace
+	for _, w := range waiting {
+		block(w.reason, last.Sub(w.since)).atEnd++
+	}
+
+	summary.DurationMs = millis(last.Sub(first))
+	summary.Goroutines = len(goroutines)
+	summary.GC.MarkTotalMs = millis(mark)
+	summary.GC.PauseTotalMs = millis(pauses)
+	summary.GC.PauseMaxMs = millis(pauseMax)
+	summary.GC.AssistTotalMs = millis(assists)
+	summary.SchedulerLatency = latencyStats(latencies)
+
+	for reason, w := range blocked {
+		summary.Blocked = append(summary.Blocked, BlockedStats{
+			Reason:  reason,
+			Count:   w.count,
+			TotalMs: millis(w.total),
+			MaxMs:   millis(w.max),
+			AtEnd:   w.atEnd,
+		})
+	}
+	sort.Slice(summary.Blocked, func(i, j int) bool {
+		a, b := summary.Blocked[i], summary.Blocked[j]
+		if a.TotalMs != b.TotalMs {
+			return a.TotalMs > b.TotalMs
+		}
+		return a.Reason < b.Reason
+	})
+
+	return summary, nil
+}
+
+// latencyStats summarizes latencies
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	percentile := func(p float64) time.Duration {
+		return latencies[int(math.Ceil(p*float64(len(latencies))))-1]
+	}
+
+	return LatencyStats{
+		Count:  len(latencies),
+		MeanMs: millis(total / time.Duration(len(latencies))),
+		P50Ms:  millis(percentile(0.5)),
+		P99Ms:  millis(percentile(0.99)),
+		MaxMs:  millis(latencies[len(latencies)-1]),
+	}
+}
+
+// millis returns a duration in milliseconds, to the microsecond
+func millis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// traceVersion returns the Go minor version in the header of an execution
+// trace, "go 1.N trace" padded to 16 bytes
+func traceVersion(data []byte) (int, bool) {
+	const headerSize = 16
+	if len(data) < headerSize {
+		return 0, false
+	}
+	header, ok := strings.CutPrefix(strings.TrimRight(string(data[:headerSize]), "\x00"), "go 1.")
+	if !ok {
+		return 0, false
+	}
+	version, ok := strings.CutSuffix(header, " trace")
+	if !ok {
+		return 0, false
+	}
+	minor, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTrace records an execution trace of goroutines blocking on a channel
+// while the heap is collected
+func testTrace(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Fatalf("Failed to start trace: %v", err)
+	}
+
+	ch := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ch
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	runtime.GC()
+	close(ch)
+	wg.Wait()
+
+	trace.Stop()
+	return buf.Bytes()
+}
+
+func TestAnalyzeTrace(t *testing.T) {
+	summary, err := AnalyzeTrace(testTrace(t))
+	if err != nil {
+		t.Fatalf("AnalyzeTrace returned unexpected error: %v", err)
+	}
+
+	if summary.DurationMs <= 0 || summary.Goroutines < 5 {
+		t.Errorf("Unexpected duration %vms with %d goroutines", summary.DurationMs, summary.Goroutines)
+	}
+	if summary.GC.Cycles == 0 || summary.GC.Pauses == 0 {
+		t.Errorf("Expected the forced GC to be summarized, got %+v", summary.GC)
+	}
+	if summary.SchedulerLatency.Count == 0 {
+		t.Error("Expected scheduler latencies")
+	}
+
+	var chanReceive *BlockedStats
+	for i := range summary.Blocked {
+		if summary.Blocked[i].Reason == "chan receive" {
+			chanReceive = &summary.Blocked[i]
+		}
+	}
+	if chanReceive == nil || chanReceive.Count < 4 || chanReceive.TotalMs < 40 {
+		t.Errorf("Expected 4 goroutines blocked on the channel for 10ms each, got %+v", summary.Blocked)
+	}
+}
+
+func TestAnalyzeTrace_Invalid(t *testing.T) {
+	_, err := AnalyzeTrace([]byte("not a trace"))
+	if err == nil || errors.Is(err, ErrUnsupportedTraceVersion) {
+		t.Errorf("Expected a parse error for invalid trace data, got %v", err)
+	}
+}
+
+func TestAnalyzeTrace_UnsupportedVersion(t *testing.T) {
+	_, err := AnalyzeTrace([]byte("go 1.99 trace\x00\x00\x00more"))
+	if !errors.Is(err, ErrUnsupportedTraceVersion) {
+		t.Errorf("Expected ErrUnsupportedTraceVersion, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "go 1.99") {
+		t.Errorf("Expected the version in the error, got %v", err)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := latencyStats(latencies)
+	if stats.Count != 100 || stats.MeanMs != 50.5 || stats.P50Ms != 50 || stats.P99Ms != 99 || stats.MaxMs != 100 {
+		t.Errorf("Unexpected latency stats %+v", stats)
+	}
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/controller"
+)
+
+// Paths of the API
+const (
+	PodsPath     = "/api/v1/pods"
+	CapturesPath = "/api/v1/captures"
+	WebhooksPath = "/api/v1/webhooks"
+)
+
+// Limits of the number of captures listed
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// maxRequestBytes bounds the size of request bodies
+const maxRequestBytes = 64 << 10
+
+// maxAlertBytes bounds the size of the alert notifications posted to webhooks,
+// which group many alerts
+const maxAlertBytes = 1 << 20
+
+// Pod is a pod profiled by a config
+type Pod struct {
+	// Config is the namespace/name of the config profiling the pod
+	Config string `json:"config"`
+
+	profilingv1alpha1.ProfiledPod
+}
+
+// Capture is a capture of a pod and its status
+type Capture struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Config    string `json:"config"`
+
+	// Pod is the namespace/name of the captured pod, prefixed with the cluster
+	// for pods of a remote cluster
+	Pod          string      `json:"pod"`
+	Reason       string      `json:"reason"`
+	ProfileTypes []string    `json:"profileTypes,omitempty"`
+	Created      metav1.Time `json:"created"`
+
+	profilingv1alpha1.ProfileCaptureStatus
+}
+
+// CaptureRequest requests an immediate capture of a pod
+type CaptureRequest struct {
+	// Pod is the namespace/name of the pod, prefixed with the cluster for pods
+	// of a remote cluster
+	Pod string `json:"pod"`
+
+	// Config is the namespace/name of the config to capture through. Defaults
+	// to the config profiling the pod.
+	Config string `json:"config,omitempty"`
+
+	// ProfileTypes to capture. Defaults to the config's.
+	ProfileTypes []string `json:"profileTypes,omitempty"`
+}
+
+// AlertNotification is the payload posted by the webhook receivers of
+// Alertmanager, of which the alerts are read
+type AlertNotification struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+// Alert is an alert of an AlertNotification. Firing alerts with a pod label,
+// and a namespace label for pods outside the config's namespace, capture the
+// pod.
+type Alert struct {
+	Status string            `json:"status"`
+	Labels map[string]string `json:"labels"`
+}
+
+// AlertsAccepted reports the alerts of a notification recorded for the
+// config's webhook source
+type AlertsAccepted struct {
+	Alerts int `json:"alerts"`
+}
+
+// listPods serves the pods profiled by the configs of a namespace, or of all
+// namespaces
+func (s *Server) listPods(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	namespace := query.Get("namespace")
+	if !s.authorize(w, req, resourceAttributes("list", "profilingconfigs", namespace, "")) {
+		return
+	}
+
+	configs := &profilingv1alpha1.ProfilingConfigList{}
+	if err := s.client.List(req.Context(), configs, client.InNamespace(namespace)); err != nil {
+		s.serverError(w, req, err)
+		return
+	}
+
+	pods := []Pod{}
+	for _, config := range configs.Items {
+		if name := query.Get("config"); name != "" && config.Name != name {
+			continue
+		}
+		for _, pod := range config.Status.ProfiledPods {
+			pods = append(pods, Pod{Config: config.Namespace + "/" + config.Name, ProfiledPod: pod})
+		}
+	}
+	writeJSON(w, http.StatusOK, pods)
+}
+
+// listCaptures serves the most recent captures of a namespace, or of all
+// namespaces, filtered by config, pod and phase
+func (s *Server) listCaptures(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	namespace := query.Get("namespace")
+
+	limit := DefaultLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", value))
+			return
+		}
+		limit = min(limit, MaxLimit)
+	}
+
+	if !s.authorize(w, req, resourceAttributes("list", "profilecaptures", namespace, "")) {
+		return
+	}
+
+	opts := []client.ListOption{client.InNamespace(namespace)}
+	if config := query.Get("config"); config != "" {
+		opts = append(opts, client.MatchingLabels{profilingv1alpha1.ConfigNameLabel: config})
+	}
+	list := &profilingv1alpha1.ProfileCaptureList{}
+	if err := s.client.List(req.Context(), list, opts...); err != nil {
+		s.serverError(w, req, err)
+		return
+	}
+
+	pod, phase := query.Get("pod"), query.Get("phase")
+	var matching []*profilingv1alpha1.ProfileCapture
+	for i := range list.Items {
+		capture := &list.Items[i]
+		if (pod != "" && capture.Spec.PodName != pod) || (phase != "" && string(capture.Status.Phase) != phase) {
+			continue
+		}
+		matching = append(matching, capture)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		a, b := matching[i].CreationTimestamp, matching[j].CreationTimestamp
+		if !a.Equal(&b) {
+			return b.Before(&a)
+		}
+		return matching[i].Name < matching[j].Name
+	})
+
+	captures := []Capture{}
+	for _, capture := range matching[:min(limit, len(matching))] {
+		captures = append(captures, newCapture(capture))
+	}
+	writeJSON(w, http.StatusOK, captures)
+}
+
+// getCapture serves a capture
+func (s *Server) getCapture(w http.ResponseWriter, req *http.Request) {
+	key := types.NamespacedName{Namespace: req.PathValue("namespace"), Name: req.PathValue("name")}
+	if !s.authorize(w, req, resourceAttributes("get", "profilecaptures", key.Namespace, key.Name)) {
+		return
+	}
+
+	capture := &profilingv1alpha1.ProfileCapture{}
+	if err := s.client.Get(req.Context(), key, capture); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("capture %s not found", key))
+			return
+		}
+		s.serverError(w, req, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newCapture(capture))
+}
+
+// createCapture requests an immediate capture of a pod. The capture runs
+// asynchronously; its status is polled from the returned location.
+func (s *Server) createCapture(w http.ResponseWriter, req *http.Request) {
+	var request CaptureRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if request.Pod == "" {
+		writeError(w, http.StatusBadRequest, "pod is required")
+		return
+	}
+
+	var config *profilingv1alpha1.ProfilingConfig
+	if request.Config != "" {
+		namespace, name, ok := strings.Cut(request.Config, "/")
+		if !ok {
+			writeError(w, http.StatusBadRequest, "config must be namespace/name")
+			return
+		}
+		if !s.authorize(w, req, resourceAttributes("create", "profilecaptures", namespace, "")) {
+			return
+		}
+
+		config = &profilingv1alpha1.ProfilingConfig{}
+		if err := s.client.Get(req.Context(), types.NamespacedName{Namespace: namespace, Name: name}, config); err != nil {
+			if apierrors.IsNotFound(err) {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("ProfilingConfig %s not found", request.Config))
+				return
+			}
+			s.serverError(w, req, err)
+			return
+		}
+		if !profiles(config, request.Pod) {
+			writeError(w, http.StatusNotFound,
+				fmt.Sprintf("pod %s is not profiled by ProfilingConfig %s", request.Pod, request.Config))
+			return
+		}
+	} else {
+		// The configs of every namespace are searched, so callers are authorized
+		// in the pod's namespace first and cannot learn which pods are profiled
+		// outside the namespaces they may capture in
+		parts := strings.Split(request.Pod, "/")
+		if len(parts) != 2 && len(parts) != 3 {
+			writeError(w, http.StatusBadRequest, "pod must be namespace/name or cluster/namespace/name")
+			return
+		}
+		podNamespace := parts[len(parts)-2]
+		if !s.authorize(w, req, resourceAttributes("create", "profilecaptures", podNamespace, "")) {
+			return
+		}
+
+		configs := &profilingv1alpha1.ProfilingConfigList{}
+		if err := s.client.List(req.Context(), configs); err != nil {
+			s.serverError(w, req, err)
+			return
+		}
+		for i := range configs.Items {
+			if profiles(&configs.Items[i], request.Pod) {
+				config = &configs.Items[i]
+				break
+			}
+		}
+		if config == nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("pod %s is not profiled by any ProfilingConfig", request.Pod))
+			return
+		}
+		if config.Namespace != podNamespace &&
+			!s.authorize(w, req, resourceAttributes("create", "profilecaptures", config.Namespace, "")) {
+			return
+		}
+	}
+
+	capture, err := controller.NewCaptureRequest(config, request.Pod, request.ProfileTypes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.client.Create(req.Context(), capture); err != nil {
+		s.serverError(w, req, err)
+		return
+	}
+
+	w.Header().Set("Location", CapturesPath+"/"+capture.Namespace+"/"+capture.Name)
+	writeJSON(w, http.StatusAccepted, newCapture(capture))
+}
+
+// postAlerts records the firing alerts posted to the webhook of a config as
+// Events on the config, for the webhook source of whichever replica monitors
+// it to capture the pods they name
+func (s *Server) postAlerts(w http.ResponseWriter, req *http.Request) {
+	key := types.NamespacedName{Namespace: req.PathValue("namespace"), Name: req.PathValue("name")}
+	if !s.authorize(w, req, resourceAttributes("create", "profilecaptures", key.Namespace, "")) {
+		return
+	}
+
+	var notification AlertNotification
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxAlertBytes)).Decode(&notification); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid alerts: %v", err))
+		return
+	}
+
+	config := &profilingv1alpha1.ProfilingConfig{}
+	if err := s.client.Get(req.Context(), key, config); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("ProfilingConfig %s not found", key))
+			return
+		}
+		s.serverError(w, req, err)
+		return
+	}
+	if config.Spec.Webhook == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("ProfilingConfig %s has no webhook", key))
+		return
+	}
+
+	accepted := AlertsAccepted{}
+	for _, alert := range notification.Alerts {
+		name, pod := alert.Labels["alertname"], alert.Labels["pod"]
+		if alert.Status != "firing" || pod == "" || !controller.WebhookAccepts(config, name) {
+			continue
+		}
+		namespace := alert.Labels["namespace"]
+		if namespace == "" {
+			namespace = config.Namespace
+		}
+		if err := s.client.Create(req.Context(), controller.NewAlertEvent(config, name, namespace, pod)); err != nil {
+			s.serverError(w, req, err)
+			return
+		}
+		accepted.Alerts++
+	}
+	writeJSON(w, http.StatusAccepted, accepted)
+}
+
+// profiles reports whether a config profiles the pod with the given key
+func profiles(config *profilingv1alpha1.ProfilingConfig, key string) bool {
+	for _, pod := range config.Status.ProfiledPods {
+		if pod.Pod == key {
+			return true
+		}
+	}
+	return false
+}
+
+// newCapture builds the API representation of a ProfileCapture
+func newCapture(capture *profilingv1alpha1.ProfileCapture) Capture {
+	pod := capture.Spec.PodNamespace + "/" + capture.Spec.PodName
+	if capture.Spec.Cluster != "" {
+		pod = capture.Spec.Cluster + "/" + pod
+	}
+
+	return Capture{
+		Name:                 capture.Name,
+		Namespace:            capture.Namespace,
+		Config:               capture.Spec.ConfigName,
+		Pod:                  pod,
+		Reason:               capture.Spec.Reason,
+		ProfileTypes:         capture.Spec.ProfileTypes,
+		Created:              capture.CreationTimestamp,
+		ProfileCaptureStatus: capture.Status,
+	}
+}
+
+// resourceAttributes describes an action on bolometer resources
+func resourceAttributes(verb, resource, namespace, name string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Verb:      verb,
+		Group:     profilingv1alpha1.GroupVersion.Group,
+		Version:   profilingv1alpha1.GroupVersion.Version,
+		Resource:  resource,
+		Namespace: namespace,
+		Name:      name,
+	}
+}
+
+// serverError logs an unexpected error and responds with a 500
+func (s *Server) serverError(w http.ResponseWriter, req *http.Request, err error) {
+	log.FromContext(req.Context()).Error(err, "API request failed", "path", req.URL.Path)
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
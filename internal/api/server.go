@@ -0,0 +1,233 @@
+// Package api serves an HTTP API to trigger captures and read the state of the
+// operator, for developer portals and chat-ops bots. Callers authenticate with
+// Kubernetes bearer tokens and are authorized against their RBAC permissions
+// on ProfilingConfigs and ProfileCaptures.
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// shutdownTimeout bounds how long in-flight requests may take once the
+// operator stops
+const shutdownTimeout = 10 * time.Second
+
+// Options configures the API server
+type Options struct {
+	// BindAddress is the address the API listens on
+	BindAddress string
+
+	// CertFile and KeyFile serve the API over TLS when both are set
+	CertFile string
+	KeyFile  string
+}
+
+// Authenticator authenticates bearer tokens
+type Authenticator interface {
+	// Authenticate returns the user a token belongs to, nil if the token is
+	// not valid
+	Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+
+	// Authorize reports whether a user may perform an action on a resource
+	Authorize(ctx context.Context, user *authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (bool, error)
+}
+
+// Server serves the API. It reads and writes through the Kubernetes API only,
+// so every replica of the operator can serve it.
+type Server struct {
+	client  client.Client
+	auth    Authenticator
+	options Options
+
+	// routes are the handlers served next to the API, behind its
+	// authentication
+	routes map[string]http.Handler
+}
+
+// NewServer creates an API server authenticating callers through the
+// Kubernetes API with TokenReviews and SubjectAccessReviews
+func NewServer(c client.Client, options Options) *Server {
+	return &Server{
+		client:  c,
+		auth:    &kubeAuthenticator{client: c},
+		options: options,
+	}
+}
+
+// Handle serves a handler next to the API, for the callers its bearer tokens
+// authenticate. It must be called before the server starts.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	if s.routes == nil {
+		s.routes = make(map[string]http.Handler)
+	}
+	s.routes[pattern] = handler
+}
+
+// CanReadCaptures reports whether the authenticated caller of a request may get
+// the ProfileCaptures of a namespace, or of every namespace when it is empty.
+// It authorizes the handlers served with Handle.
+func (s *Server) CanReadCaptures(req *http.Request, namespace string) (bool, error) {
+	user, _ := req.Context().Value(userKey{}).(*authenticationv1.UserInfo)
+	if user == nil {
+		return false, nil
+	}
+	return s.auth.Authorize(req.Context(), user, resourceAttributes("get", "profilecaptures", namespace, ""))
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("api")
+
+	server := &http.Server{
+		Addr:              s.options.BindAddress,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "Failed to shut down API server")
+		}
+	}()
+
+	logger.Info("Serving API", "address", s.options.BindAddress, "tls", s.options.CertFile != "")
+	var err error
+	if s.options.CertFile != "" && s.options.KeyFile != "" {
+		err = server.ListenAndServeTLS(s.options.CertFile, s.options.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Handler returns the handler serving the API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+PodsPath, s.listPods)
+	mux.HandleFunc("GET "+CapturesPath, s.listCaptures)
+	mux.HandleFunc("POST "+CapturesPath, s.createCapture)
+	mux.HandleFunc("GET "+CapturesPath+"/{namespace}/{name}", s.getCapture)
+	mux.HandleFunc("POST "+WebhooksPath+"/{namespace}/{name}", s.postAlerts)
+	for pattern, handler := range s.routes {
+		mux.Handle(pattern, handler)
+	}
+	return s.authenticate(mux)
+}
+
+// userKey is the context key of the authenticated user
+type userKey struct{}
+
+// authenticate rejects requests without a valid bearer token and passes the
+// user on to the handlers
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeError(w, http.StatusUnauthorized, "a bearer token is required")
+			return
+		}
+
+		user, err := s.auth.Authenticate(req.Context(), token)
+		if err != nil {
+			log.FromContext(req.Context()).Error(err, "Failed to review token")
+			writeError(w, http.StatusInternalServerError, "failed to authenticate")
+			return
+		}
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), userKey{}, user)))
+	})
+}
+
+// authorize checks the caller may perform an action, and writes the error
+// response if not
+func (s *Server) authorize(w http.ResponseWriter, req *http.Request, attributes authorizationv1.ResourceAttributes) bool {
+	user, _ := req.Context().Value(userKey{}).(*authenticationv1.UserInfo)
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return false
+	}
+
+	allowed, err := s.auth.Authorize(req.Context(), user, attributes)
+	if err != nil {
+		log.FromContext(req.Context()).Error(err, "Failed to review access")
+		writeError(w, http.StatusInternalServerError, "failed to authorize")
+		return false
+	}
+	if !allowed {
+		scope := "cluster-wide"
+		if attributes.Namespace != "" {
+			scope = "in namespace " + attributes.Namespace
+		}
+		writeError(w, http.StatusForbidden,
+			"user "+user.Username+" cannot "+attributes.Verb+" "+attributes.Resource+" "+scope)
+		return false
+	}
+	return true
+}
+
+// kubeAuthenticator authenticates and authorizes through the Kubernetes API
+type kubeAuthenticator struct {
+	client client.Client
+}
+
+// Authenticate implements Authenticator with a TokenReview
+func (a *kubeAuthenticator) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := a.client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		return nil, nil
+	}
+	return &review.Status.User, nil
+}
+
+// Authorize implements Authenticator with a SubjectAccessReview
+func (a *kubeAuthenticator) Authorize(ctx context.Context, user *authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               user.Username,
+			Groups:             user.Groups,
+			UID:                user.UID,
+			Extra:              extra,
+		},
+	}
+	if err := a.client.Create(ctx, review); err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/controller"
+)
+
+// fakeAuth accepts the token "valid" and allows the actions listed as
+// "verb resource namespace"
+type fakeAuth struct {
+	allowed map[string]bool
+}
+
+func (a *fakeAuth) Authenticate(_ context.Context, token string) (*authenticationv1.UserInfo, error) {
+	if token != "valid" {
+		return nil, nil
+	}
+	return &authenticationv1.UserInfo{Username: "alice"}, nil
+}
+
+func (a *fakeAuth) Authorize(_ context.Context, _ *authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	return a.allowed[attributes.Verb+" "+attributes.Resource+" "+attributes.Namespace], nil
+}
+
+func setupTestServer(t *testing.T, allowed ...string) (*Server, client.Client) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	_ = profilingv1alpha1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	config := &profilingv1alpha1.ProfilingConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "profiling"},
+		Status: profilingv1alpha1.ProfilingConfigStatus{
+			ProfiledPods: []profilingv1alpha1.ProfiledPod{{Pod: "default/my-app-0"}, {Pod: "default/my-app-1"}},
+		},
+	}
+	created := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+	var objects []client.Object
+	objects = append(objects, config)
+	for i, name := range []string{"my-app-older", "my-app-newer"} {
+		objects = append(objects, &profilingv1alpha1.ProfileCapture{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "profiling",
+				Labels:            map[string]string{profilingv1alpha1.ConfigNameLabel: "my-app"},
+				CreationTimestamp: metav1.NewTime(created.Add(time.Duration(i) * time.Minute)),
+			},
+			Spec: profilingv1alpha1.ProfileCaptureSpec{
+				ConfigName:   "my-app",
+				PodName:      "my-app-0",
+				PodNamespace: "default",
+				Reason:       "CPU usage 95.00% exceeds threshold 80%",
+			},
+			Status: profilingv1alpha1.ProfileCaptureStatus{Phase: profilingv1alpha1.CapturePhaseSucceeded},
+		})
+	}
+
+	c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	auth := &fakeAuth{allowed: make(map[string]bool)}
+	for _, action := range allowed {
+		auth.allowed[action] = true
+	}
+	return &Server{client: c, auth: auth}, c
+}
+
+func serve(server *Server, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Authentication(t *testing.T) {
+	server, _ := setupTestServer(t, "list profilingconfigs ")
+
+	if rec := serve(server, http.MethodGet, PodsPath, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", rec.Code)
+	}
+	if rec := serve(server, http.MethodGet, PodsPath, "stolen", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an invalid token, got %d", rec.Code)
+	}
+	if rec := serve(server, http.MethodGet, PodsPath+"?namespace=profiling", "valid", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 outside the allowed scope, got %d", rec.Code)
+	}
+}
+
+func TestServer_ListPods(t *testing.T) {
+	server, _ := setupTestServer(t, "list profilingconfigs profiling")
+
+	rec := serve(server, http.MethodGet, PodsPath+"?namespace=profiling", "valid", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var pods []Pod
+	if err := json.Unmarshal(rec.Body.Bytes(), &pods); err != nil {
+		t.Fatalf("Failed to decode pods: %v", err)
+	}
+	if len(pods) != 2 || pods[0].Config != "profiling/my-app" || pods[0].Pod != "default/my-app-0" {
+		t.Errorf("Unexpected pods %+v", pods)
+	}
+}
+
+func TestServer_ListCaptures(t *testing.T) {
+	server, _ := setupTestServer(t, "list profilecaptures profiling", "get profilecaptures profiling")
+
+	rec := serve(server, http.MethodGet, CapturesPath+"?namespace=profiling&config=my-app&limit=1", "valid", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var captures []Capture
+	if err := json.Unmarshal(rec.Body.Bytes(), &captures); err != nil {
+		t.Fatalf("Failed to decode captures: %v", err)
+	}
+	if len(captures) != 1 || captures[0].Name != "my-app-newer" || captures[0].Pod != "default/my-app-0" ||
+		captures[0].Phase != profilingv1alpha1.CapturePhaseSucceeded {
+		t.Errorf("Expected the newest capture, got %+v", captures)
+	}
+
+	rec = serve(server, http.MethodGet, CapturesPath+"/profiling/my-app-older", "valid", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"my-app-older"`) {
+		t.Errorf("Expected the capture, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(server, http.MethodGet, CapturesPath+"/profiling/missing", "valid", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing capture, got %d", rec.Code)
+	}
+}
+
+func TestServer_CreateCapture(t *testing.T) {
+	server, c := setupTestServer(t, "create profilecaptures profiling", "create profilecaptures default")
+
+	rec := serve(server, http.MethodPost, CapturesPath, "valid", `{"pod": "default/my-app-1", "profileTypes": ["heap"]}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if location := rec.Header().Get("Location"); !strings.HasPrefix(location, CapturesPath+"/profiling/my-app-") {
+		t.Errorf("Unexpected location %q", location)
+	}
+
+	list := &profilingv1alpha1.ProfileCaptureList{}
+	if err := c.List(context.Background(), list, client.MatchingLabels{profilingv1alpha1.RequestedLabel: "true"}); err != nil {
+		t.Fatalf("Failed to list captures: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Spec.PodName != "my-app-1" || list.Items[0].Spec.ProfileTypes[0] != "heap" {
+		t.Errorf("Expected a requested capture of my-app-1, got %+v", list.Items)
+	}
+
+	if rec := serve(server, http.MethodPost, CapturesPath, "valid", `{"pod": "default/other"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a pod not profiled, got %d", rec.Code)
+	}
+	if rec := serve(server, http.MethodPost, CapturesPath, "valid", `{"pod": "default/my-app-1", "config": "my-app"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a config without namespace, got %d", rec.Code)
+	}
+	if rec := serve(server, http.MethodPost, CapturesPath, "valid", `{"pods": ["default/my-app-1"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown field, got %d", rec.Code)
+	}
+}
+
+func TestServer_CreateCapture_Unauthorized(t *testing.T) {
+	// The caller may capture through the config's namespace, not in the pods'
+	server, _ := setupTestServer(t, "create profilecaptures profiling")
+
+	profiled := serve(server, http.MethodPost, CapturesPath, "valid", `{"pod": "default/my-app-1"}`)
+	unprofiled := serve(server, http.MethodPost, CapturesPath, "valid", `{"pod": "default/other"}`)
+	if profiled.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d: %s", profiled.Code, profiled.Body.String())
+	}
+	if unprofiled.Code != profiled.Code || unprofiled.Body.String() != profiled.Body.String() {
+		t.Errorf("Expected identical responses for profiled and unprofiled pods, got %d %q and %d %q",
+			profiled.Code, profiled.Body.String(), unprofiled.Code, unprofiled.Body.String())
+	}
+
+	// Naming the config captures through it
+	rec := serve(server, http.MethodPost, CapturesPath, "valid", `{"pod": "default/my-app-1", "config": "profiling/my-app"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected 202 through the config, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_Handle(t *testing.T) {
+	server, _ := setupTestServer(t, "get profilecaptures profiling")
+	server.Handle("GET /extra", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, namespace := range []string{"profiling", "default"} {
+			allowed, err := server.CanReadCaptures(req, namespace)
+			if err != nil {
+				t.Errorf("CanReadCaptures returned unexpected error: %v", err)
+			}
+			if allowed {
+				w.Write([]byte(namespace))
+			}
+		}
+	}))
+
+	if rec := serve(server, http.MethodGet, "/extra", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", rec.Code)
+	}
+	rec := serve(server, http.MethodGet, "/extra", "valid", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "profiling" {
+		t.Errorf("Expected only the allowed namespace, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_PostAlerts(t *testing.T) {
+	server, c := setupTestServer(t, "create profilecaptures profiling")
+	path := WebhooksPath + "/profiling/my-app"
+	alerts := `{"status": "firing", "alerts": [
+		{"status": "firing", "labels": {"alertname": "HighLatency", "namespace": "default", "pod": "my-app-0"}},
+		{"status": "resolved", "labels": {"alertname": "HighLatency", "namespace": "default", "pod": "my-app-1"}},
+		{"status": "firing", "labels": {"alertname": "DiskFull", "namespace": "default", "pod": "my-app-1"}},
+		{"status": "firing", "labels": {"alertname": "HighLatency"}}
+	]}`
+
+	if rec := serve(server, http.MethodPost, WebhooksPath+"/default/my-app", "valid", alerts); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 outside the allowed namespace, got %d", rec.Code)
+	}
+	if rec := serve(server, http.MethodPost, path, "valid", alerts); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a config without webhook, got %d", rec.Code)
+	}
+
+	config := &profilingv1alpha1.ProfilingConfig{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "profiling", Name: "my-app"}, config); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	config.Spec.Webhook = &profilingv1alpha1.WebhookTriggerConfig{Alerts: []string{"HighLatency"}}
+	if err := c.Update(context.Background(), config); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	rec := serve(server, http.MethodPost, path, "valid", alerts)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var accepted AlertsAccepted
+	if err := json.Unmarshal(rec.Body.Bytes(), &accepted); err != nil || accepted.Alerts != 1 {
+		t.Errorf("Expected the firing HighLatency alert with a pod accepted, got %s", rec.Body.String())
+	}
+
+	events := &corev1.EventList{}
+	if err := c.List(context.Background(), events, client.InNamespace("profiling")); err != nil {
+		t.Fatalf("Failed to list Events: %v", err)
+	}
+	if len(events.Items) != 1 || events.Items[0].Annotations[controller.AlertPodAnnotation] != "default/my-app-0" ||
+		events.Items[0].InvolvedObject.Name != "my-app" || events.Items[0].Reason != controller.AlertEventReason {
+		t.Errorf("Expected an alert Event on the config for default/my-app-0, got %+v", events.Items)
+	}
+}
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// Outcome is the result of a capture attempt
+type Outcome string
+
+const (
+	// OutcomeSucceeded means the profiles were captured and uploaded
+	OutcomeSucceeded Outcome = "Succeeded"
+
+	// OutcomeFailed means the capture or the upload failed
+	OutcomeFailed Outcome = "Failed"
+
+	// OutcomeSkipped means the profiles were not captured, e.g. because the
+	// storage budget of the config is exceeded
+	OutcomeSkipped Outcome = "Skipped"
+)
+
+// Record is the audit record of a single capture attempt
+type Record struct {
+	Time time.Time `json:"time"`
+
+	// Capture is the name of the ProfileCapture recording the attempt, if any
+	Capture string `json:"capture,omitempty"`
+
+	// Config is the namespace/name of the ProfilingConfig that requested the capture
+	Config string `json:"config"`
+
+	// TriggeredBy is the mechanism that requested the capture, e.g. threshold,
+	// on-demand, request or the name of a plugged-in trigger source
+	TriggeredBy string `json:"triggeredBy"`
+
+	// Reason is the capture reason, e.g. the exceeded threshold
+	Reason string `json:"reason"`
+
+	// Trigger holds the metric values that caused a threshold capture
+	Trigger *uploader.TriggerValues `json:"trigger,omitempty"`
+
+	Pod Pod `json:"pod"`
+
+	// Service is the service the pod belongs to, as named in profile keys
+	Service string `json:"service,omitempty"`
+
+	ProfileTypes []string  `json:"profileTypes"`
+	Profiles     []Profile `json:"profiles,omitempty"`
+
+	// Bucket and Manifest are where the profiles were uploaded
+	Bucket   string `json:"bucket"`
+	Manifest string `json:"manifest,omitempty"`
+
+	// Summary is the key of the top functions report, and TopFunctions the top
+	// functions of each profile
+	Summary      string             `json:"summary,omitempty"`
+	TopFunctions []analysis.Summary `json:"topFunctions,omitempty"`
+
+	// Comparison is the key of the report comparing the capture to its
+	// baseline, RegressionScore its largest growth in percent and Regressed
+	// whether it exceeds the config's threshold
+	Comparison      string   `json:"comparison,omitempty"`
+	RegressionScore *float64 `json:"regressionScore,omitempty"`
+	Regressed       bool     `json:"regressed,omitempty"`
+
+	// Leaks are the stacks that grew across the pod's last captures
+	Leaks []analysis.Leak `json:"leaks,omitempty"`
+
+	Outcome         Outcome `json:"outcome"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// Pod identifies the profiled pod
+type Pod struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid,omitempty"`
+	Node      string `json:"node,omitempty"`
+}
+
+// Profile describes an uploaded profile
+type Profile struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	SizeBytes int    `json:"sizeBytes"`
+
+	// Deduplicated is set when the profile was identical to the one captured
+	// before it, which Key refers to, and was not stored again
+	Deduplicated bool `json:"deduplicated,omitempty"`
+
+	// PresignedURL downloads the profile when the config presigns URLs. Being
+	// a credential, it is left out of audit records.
+	PresignedURL string `json:"-"`
+}
+
+// Sink stores audit records. Records are only ever appended.
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+// Discard is a sink that drops every record
+var Discard Sink = discardSink{}
+
+type discardSink struct{}
+
+func (discardSink) Write(context.Context, Record) error { return nil }
+
+// WriterSink appends records as JSON lines to a writer, such as a file opened
+// for appending or stdout
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing JSON lines to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write appends the record as a single JSON line
+func (s *WriterSink) Write(_ context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// MultiSink writes every record to all of its sinks
+type MultiSink []Sink
+
+// Write writes the record to every sink, returning the joined errors
+func (m MultiSink) Write(ctx context.Context, record Record) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Write(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecord() Record {
+	return Record{
+		Time:         time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Config:       "default/my-app",
+		TriggeredBy:  "threshold",
+		Reason:       "cpu",
+		Pod:          Pod{Namespace: "default", Name: "my-app-1", Node: "node-1"},
+		ProfileTypes: []string{"heap", "cpu"},
+		Profiles:     []Profile{{Type: "heap", Key: "profiles/heap.pprof", SizeBytes: 1024}},
+		Bucket:       "my-bucket",
+		Outcome:      OutcomeSucceeded,
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	first := testRecord()
+	second := testRecord()
+	second.Outcome = OutcomeFailed
+	second.Error = "connection refused"
+
+	for _, record := range []Record{first, second} {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %d", len(lines))
+	}
+
+	var decoded Record
+	if err := json.Unmarshal([]byte(lines[1]), &decoded); err != nil {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+	if decoded.Outcome != OutcomeFailed || decoded.Error != "connection refused" || decoded.Pod.Name != "my-app-1" {
+		t.Errorf("Unexpected decoded record: %+v", decoded)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(context.Context, Record) error { return errors.New("unavailable") }
+
+func TestMultiSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := MultiSink{failingSink{}, NewWriterSink(&buf)}
+
+	err := sink.Write(context.Background(), testRecord())
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("Expected the failing sink's error, got %v", err)
+	}
+
+	// Other sinks still get the record
+	if buf.Len() == 0 {
+		t.Error("Expected the record to be written despite the failing sink")
+	}
+}
+
+func TestS3Sink_ObjectKey(t *testing.T) {
+	sink := &S3Sink{bucket: "audit-bucket", prefix: "_audit"}
+
+	key := sink.objectKey(testRecord())
+	expected := "_audit/2024-01-15/20240115-103000.000000000-default-my-app-1.jsonl"
+	if key != expected {
+		t.Errorf("Expected key %s, got %s", expected, key)
+	}
+}
@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// CloudWatch metric names
+const (
+	MetricCaptures         = "Captures"
+	MetricCaptureFailures  = "CaptureFailures"
+	MetricProfilesUploaded = "ProfilesUploaded"
+	MetricUploadedBytes    = "UploadedBytes"
+	MetricCaptureDuration  = "CaptureDuration"
+)
+
+// configDimension is the dimension metrics are published per config under
+const configDimension = "Config"
+
+// maxMetricDatums is the most metric data PutMetricData accepts per call
+const maxMetricDatums = 1000
+
+// flushTimeout bounds a single flush of the aggregated metrics
+const flushTimeout = 30 * time.Second
+
+// metricPutter is the part of the CloudWatch client used by CloudWatchSink
+type metricPutter interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// configStats aggregates the capture attempts of a config between flushes
+type configStats struct {
+	captures      float64
+	failures      float64
+	profiles      float64
+	uploadedBytes float64
+	durations     types.StatisticSet
+}
+
+// CloudWatchSink turns records into per-config CloudWatch custom metrics. It
+// aggregates records in memory and publishes them every interval once started.
+type CloudWatchSink struct {
+	client    metricPutter
+	namespace string
+	interval  time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*configStats
+}
+
+// NewCloudWatchSink creates a sink publishing to a metric namespace in region
+// every interval
+func NewCloudWatchSink(ctx context.Context, namespace, region string, interval time.Duration) (*CloudWatchSink, error) {
+	// Load AWS config from environment (uses IRSA/IAM roles automatically)
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &CloudWatchSink{
+		client:    cloudwatch.NewFromConfig(awsCfg),
+		namespace: namespace,
+		interval:  interval,
+		stats:     make(map[string]*configStats),
+	}, nil
+}
+
+// Write adds the record to the metrics of its config
+func (s *CloudWatchSink) Write(_ context.Context, record Record) error {
+	// Skipped attempts captured nothing
+	if record.Outcome == OutcomeSkipped {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[record.Config]
+	if !ok {
+		stats = &configStats{}
+		s.stats[record.Config] = stats
+	}
+
+	stats.captures++
+	if record.Outcome == OutcomeFailed {
+		stats.failures++
+	}
+	for _, profile := range record.Profiles {
+		stats.profiles++
+		if !profile.Deduplicated {
+			stats.uploadedBytes += float64(profile.SizeBytes)
+		}
+	}
+
+	duration := record.DurationSeconds
+	durations := &stats.durations
+	if aws.ToFloat64(durations.SampleCount) == 0 {
+		durations.Minimum = aws.Float64(duration)
+		durations.Maximum = aws.Float64(duration)
+	}
+	durations.SampleCount = aws.Float64(aws.ToFloat64(durations.SampleCount) + 1)
+	durations.Sum = aws.Float64(aws.ToFloat64(durations.Sum) + duration)
+	durations.Minimum = aws.Float64(min(aws.ToFloat64(durations.Minimum), duration))
+	durations.Maximum = aws.Float64(max(aws.ToFloat64(durations.Maximum), duration))
+
+	return nil
+}
+
+// Start publishes the aggregated metrics every interval until ctx is done,
+// then publishes what is left
+func (s *CloudWatchSink) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cloudwatch")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.flush(ctx); err != nil {
+				logger.Error(err, "Failed to publish CloudWatch metrics")
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+			defer cancel()
+			if err := s.flush(flushCtx); err != nil {
+				logger.Error(err, "Failed to publish CloudWatch metrics on shutdown")
+			}
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection reports false so every replica publishes the captures it
+// ran; CloudWatch adds up the data points of the replicas. Replicas that run no
+// captures publish nothing.
+func (s *CloudWatchSink) NeedLeaderElection() bool {
+	return false
+}
+
+// flush publishes and resets the aggregated metrics. Metrics that fail to
+// publish are dropped, so a CloudWatch outage does not grow memory.
+func (s *CloudWatchSink) flush(ctx context.Context) error {
+	s.mu.Lock()
+	stats := s.stats
+	s.stats = make(map[string]*configStats)
+	s.mu.Unlock()
+
+	data := metricData(stats, time.Now())
+
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+
+	var errs []error
+	for start := 0; start < len(data); start += maxMetricDatums {
+		end := min(start+maxMetricDatums, len(data))
+		_, err := s.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(s.namespace),
+			MetricData: data[start:end],
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// metricData returns the metric data of the aggregated stats, in config order
+func metricData(stats map[string]*configStats, now time.Time) []types.MetricDatum {
+	configs := make([]string, 0, len(stats))
+	for config := range stats {
+		configs = append(configs, config)
+	}
+	sort.Strings(configs)
+
+	var data []types.MetricDatum
+	for _, config := range configs {
+		stats := stats[config]
+		dimensions := []types.Dimension{{Name: aws.String(configDimension), Value: aws.String(config)}}
+		datum := func(name string, value float64, unit types.StandardUnit) types.MetricDatum {
+			return types.MetricDatum{
+				MetricName: aws.String(name),
+				Dimensions: dimensions,
+				Timestamp:  aws.Time(now),
+				Value:      aws.Float64(value),
+				Unit:       unit,
+			}
+		}
+
+		durations := stats.durations
+		data = append(data,
+			datum(MetricCaptures, stats.captures, types.StandardUnitCount),
+			datum(MetricCaptureFailures, stats.failures, types.StandardUnitCount),
+			datum(MetricProfilesUploaded, stats.profiles, types.StandardUnitCount),
+			datum(MetricUploadedBytes, stats.uploadedBytes, types.StandardUnitBytes),
+			types.MetricDatum{
+				MetricName:      aws.String(MetricCaptureDuration),
+				Dimensions:      dimensions,
+				Timestamp:       aws.Time(now),
+				StatisticValues: &durations,
+				Unit:            types.StandardUnitSeconds,
+			},
+		)
+	}
+	return data
+}
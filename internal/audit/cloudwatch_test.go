@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// fakeCloudWatch records published metric data
+type fakeCloudWatch struct {
+	inputs []*cloudwatch.PutMetricDataInput
+}
+
+func (f *fakeCloudWatch) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestCloudWatchSink_Flush(t *testing.T) {
+	client := &fakeCloudWatch{}
+	sink := &CloudWatchSink{client: client, namespace: "Bolometer", interval: time.Minute, stats: make(map[string]*configStats)}
+
+	succeeded := testRecord()
+	succeeded.DurationSeconds = 10
+	succeeded.Profiles = append(succeeded.Profiles, Profile{Type: "goroutine", Key: "profiles/goroutine.pb.gz", SizeBytes: 512, Deduplicated: true})
+	failed := testRecord()
+	failed.Outcome = OutcomeFailed
+	failed.Profiles = nil
+	failed.DurationSeconds = 30
+	skipped := testRecord()
+	skipped.Outcome = OutcomeSkipped
+	skipped.Profiles = nil
+	for _, record := range []Record{succeeded, failed, skipped} {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+
+	if err := sink.flush(context.Background()); err != nil {
+		t.Fatalf("flush returned unexpected error: %v", err)
+	}
+	if len(client.inputs) != 1 || aws.ToString(client.inputs[0].Namespace) != "Bolometer" {
+		t.Fatalf("Expected 1 call in namespace Bolometer, got %+v", client.inputs)
+	}
+
+	values := make(map[string]float64)
+	for _, datum := range client.inputs[0].MetricData {
+		if dimension := aws.ToString(datum.Dimensions[0].Value); dimension != "default/my-app" {
+			t.Errorf("Unexpected config dimension %q", dimension)
+		}
+		if stats := datum.StatisticValues; stats != nil {
+			if aws.ToFloat64(stats.SampleCount) != 2 || aws.ToFloat64(stats.Sum) != 40 ||
+				aws.ToFloat64(stats.Minimum) != 10 || aws.ToFloat64(stats.Maximum) != 30 {
+				t.Errorf("Unexpected duration statistics: %+v", stats)
+			}
+			continue
+		}
+		values[aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+	}
+
+	expected := map[string]float64{
+		MetricCaptures:         2,
+		MetricCaptureFailures:  1,
+		MetricProfilesUploaded: 2,
+		MetricUploadedBytes:    1024,
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("Expected %s %v, got %v", name, value, values[name])
+		}
+	}
+
+	// Flushed metrics are not published again
+	if err := sink.flush(context.Background()); err != nil {
+		t.Fatalf("flush returned unexpected error: %v", err)
+	}
+	if len(client.inputs) != 1 {
+		t.Errorf("Expected no call without new records, got %d calls", len(client.inputs))
+	}
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// S3Sink stores each record as its own JSON lines object, so records are never
+// rewritten once stored
+type S3Sink struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Sink creates a sink storing records in the given bucket
+func NewS3Sink(ctx context.Context, cfg uploader.S3Config) (*S3Sink, error) {
+	client, err := uploader.NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Sink{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: cfg.Prefix,
+	}, nil
+}
+
+// Write stores the record under a key unique to the attempt
+func (s *S3Sink) Write(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.objectKey(record)),
+		Body:        bytes.NewReader(append(data, '\n')),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload audit record to S3: %w", err)
+	}
+
+	return nil
+}
+
+// objectKey returns the key of a record
+func (s *S3Sink) objectKey(record Record) string {
+	// Format: {prefix}/{date}/{timestamp}-{namespace}-{pod}.jsonl
+	filename := fmt.Sprintf("%s-%s-%s.jsonl",
+		record.Time.UTC().Format("20060102-150405.000000000"), record.Pod.Namespace, record.Pod.Name)
+
+	return filepath.Join(s.prefix, record.Time.UTC().Format("2006-01-02"), filename)
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"slices"
+	"time"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+const (
+	// backoffUtilization is the threshold utilization below which the check interval backs off
+	backoffUtilization = 0.5
+
+	// tightenUtilization is the threshold utilization above which the base check interval is used
+	tightenUtilization = 0.75
+)
+
+// thresholdUtilization returns how close usage is to the configured thresholds, as
+// the highest ratio of usage to threshold. A value of 1 or more means a threshold
+// is exceeded.
+func thresholdUtilization(usage *metrics.PodMetrics, thresholds profilingv1alpha1.ThresholdConfig) float64 {
+	ratio := func(percent float64, threshold int) float64 {
+		if threshold <= 0 {
+			return 0
+		}
+		return percent / float64(threshold)
+	}
+
+	utilization := 0.0
+	observe := func(cpuPercent, memoryPercent float64) {
+		utilization = max(utilization,
+			ratio(cpuPercent, thresholds.CPUThresholdPercent),
+			ratio(memoryPercent, thresholds.MemoryThresholdPercent),
+		)
+	}
+
+	if thresholds.PerContainer || len(thresholds.Containers) > 0 {
+		for _, container := range usage.Containers {
+			if len(thresholds.Containers) > 0 && !slices.Contains(thresholds.Containers, container.Name) {
+				continue
+			}
+			observe(container.CPUUsagePercent, container.MemoryUsagePercent)
+		}
+		return utilization
+	}
+
+	observe(usage.CPUUsagePercent, usage.MemoryUsagePercent)
+	return utilization
+}
+
+// nextCheckInterval returns the interval until the next threshold check. The
+// interval doubles up to maxInterval while utilization is low, halves towards
+// base as it rises and resets to base close to the thresholds.
+func nextCheckInterval(current, base, maxInterval time.Duration, utilization float64) time.Duration {
+	if maxInterval <= base {
+		return base
+	}
+
+	switch {
+	case utilization >= tightenUtilization:
+		return base
+	case utilization >= backoffUtilization:
+		return max(current/2, base)
+	default:
+		return min(current*2, maxInterval)
+	}
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestThresholdUtilization(t *testing.T) {
+	usage := &metrics.PodMetrics{
+		CPUUsagePercent:    40,
+		MemoryUsagePercent: 45,
+		Containers: []metrics.ContainerMetrics{
+			{Name: "app", CPUUsagePercent: 20, MemoryUsagePercent: 30},
+			{Name: "sidecar", CPUUsagePercent: 72, MemoryUsagePercent: 10},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		thresholds profilingv1alpha1.ThresholdConfig
+		expected   float64
+	}{
+		{
+			name:       "pod-wide uses highest ratio",
+			thresholds: profilingv1alpha1.ThresholdConfig{CPUThresholdPercent: 80, MemoryThresholdPercent: 90},
+			expected:   0.5,
+		},
+		{
+			name:       "per container uses busiest container",
+			thresholds: profilingv1alpha1.ThresholdConfig{CPUThresholdPercent: 80, MemoryThresholdPercent: 90, PerContainer: true},
+			expected:   0.9,
+		},
+		{
+			name:       "named containers only",
+			thresholds: profilingv1alpha1.ThresholdConfig{CPUThresholdPercent: 80, MemoryThresholdPercent: 60, Containers: []string{"app"}},
+			expected:   0.5,
+		},
+		{
+			name:       "zero thresholds are ignored",
+			thresholds: profilingv1alpha1.ThresholdConfig{MemoryThresholdPercent: 90},
+			expected:   0.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := thresholdUtilization(usage, tt.thresholds); got != tt.expected {
+				t.Errorf("Expected utilization %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNextCheckInterval(t *testing.T) {
+	base := 30 * time.Second
+	maxInterval := 300 * time.Second
+
+	tests := []struct {
+		name        string
+		current     time.Duration
+		maxInterval time.Duration
+		utilization float64
+		expected    time.Duration
+	}{
+		{"disabled", 30 * time.Second, 0, 0.1, base},
+		{"backs off when idle", 30 * time.Second, maxInterval, 0.1, 60 * time.Second},
+		{"capped at max", 240 * time.Second, maxInterval, 0.1, maxInterval},
+		{"halves when approaching", 240 * time.Second, maxInterval, 0.6, 120 * time.Second},
+		{"never below base", 40 * time.Second, maxInterval, 0.6, base},
+		{"resets near threshold", 240 * time.Second, maxInterval, 0.8, base},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextCheckInterval(tt.current, base, tt.maxInterval, tt.utilization); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
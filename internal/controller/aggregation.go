@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+const (
+	// defaultAggregationWindowSeconds is the window profiles are merged over
+	// when a config sets none
+	defaultAggregationWindowSeconds = 3600
+
+	// aggregationDelay leaves captures running at the end of a window time to
+	// upload before the window is merged
+	aggregationDelay = 2 * time.Minute
+)
+
+// monitorAggregation merges the profiles captured across the replicas of each
+// service of a config once every window has ended, rolls the windows of each
+// day up once it has ended, and deletes the objects past their retention
+func (r *ProfilingConfigReconciler) monitorAggregation(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+	logger := log.FromContext(ctx).WithName("aggregation")
+	window := time.Duration(withDefault(config.Spec.Aggregation.WindowSeconds, defaultAggregationWindowSeconds)) * time.Second
+
+	for {
+		// Wait for the window after the last complete one to end
+		_, end := aggregationWindow(time.Now(), window)
+		timer := time.NewTimer(time.Until(end.Add(window + aggregationDelay)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		start, end := aggregationWindow(time.Now(), window)
+		if err := r.aggregate(ctx, config, start, end); err != nil {
+			logger.Error(err, "Failed to aggregate profiles", "windowStart", start)
+		}
+	}
+}
+
+// aggregationWindow returns the last window of the given length that ended
+// at least aggregationDelay before now
+func aggregationWindow(now time.Time, window time.Duration) (time.Time, time.Time) {
+	end := now.Add(-aggregationDelay).Truncate(window)
+	return end.Add(-window), end
+}
+
+// rollupDay returns the day a window belongs to once it has ended, when the
+// window is the last one of the day
+func rollupDay(start, end time.Time) (time.Time, bool) {
+	day := startOfDay(start)
+	return day, !startOfDay(end).Equal(day)
+}
+
+// startOfDay returns midnight of the day of t
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// aggregate merges the profiles of a config captured within [start, end),
+// rolls the day up when the window is its last one, and applies retention
+func (r *ProfilingConfigReconciler) aggregate(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, start, end time.Time) error {
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	s3Uploader, err := uploader.NewS3Uploader(ctx, s3Config)
+	if err != nil {
+		return fmt.Errorf("failed to create S3 uploader: %w", err)
+	}
+	services := r.configServices(config)
+
+	errs := []error{r.aggregateWindow(ctx, config, s3Uploader, services, "", uploader.AggregatedPrefix, start, end)}
+	if day, ok := rollupDay(start, end); ok && config.Spec.Aggregation.Daily {
+		errs = append(errs, r.aggregateWindow(ctx, config, s3Uploader, services,
+			uploader.AggregatedPrefix, uploader.DailyPrefix, day, day.AddDate(0, 0, 1)))
+	}
+	if retention := config.Spec.Aggregation.Retention; retention != nil {
+		errs = append(errs, applyRetention(ctx, s3Uploader, services, retention, time.Now()))
+	}
+	return errors.Join(errs...)
+}
+
+// aggregateWindow merges the profiles of each service of a config stored under
+// from and captured within [start, end) into one profile per type under to
+func (r *ProfilingConfigReconciler) aggregateWindow(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, s3Uploader *uploader.S3Uploader, services []string, from, to string, start, end time.Time) error {
+	logger := log.FromContext(ctx).WithName("aggregation")
+
+	var errs []error
+	for _, service := range services {
+		profiles, err := s3Uploader.ListProfiles(ctx, from, service, start, end)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for profileType, keys := range profiles {
+			// Execution traces are not pprof profiles and cannot be merged
+			if profileType == analysis.TraceProfileType {
+				continue
+			}
+
+			key, err := mergeProfiles(ctx, s3Uploader, config.Spec.S3Config.Bucket, to, service, profileType, start, keys)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("service %s: %w", service, err))
+				continue
+			}
+			logger.Info("Merged profiles", "service", service, "type", profileType,
+				"profiles", len(keys), "key", key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyRetention deletes the captures, windows and daily rollups of each
+// service that are older than their retention
+func applyRetention(ctx context.Context, s3Uploader *uploader.S3Uploader, services []string, retention *profilingv1alpha1.RetentionConfig, now time.Time) error {
+	logger := log.FromContext(ctx).WithName("aggregation")
+
+	var errs []error
+	for prefix, days := range map[string]int{
+		"":                        retention.CaptureDays,
+		uploader.AggregatedPrefix: retention.WindowDays,
+		uploader.DailyPrefix:      retention.DailyDays,
+	} {
+		if days <= 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -days)
+		for _, service := range services {
+			deleted, err := s3Uploader.DeleteBefore(ctx, prefix, service, before)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("service %s: %w", service, err))
+			}
+			if deleted > 0 {
+				logger.Info("Deleted expired profiles", "service", service, "prefix", prefix,
+					"objects", deleted, "before", before)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mergeProfiles downloads the profiles of a service, merges them and uploads
+// the result under prefix, returning its key
+func mergeProfiles(ctx context.Context, s3Uploader *uploader.S3Uploader, bucket, prefix, service, profileType string, start time.Time, keys []string) (string, error) {
+	profiles := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		data, err := s3Uploader.Download(ctx, bucket, key)
+		if err != nil {
+			return "", err
+		}
+		profiles = append(profiles, data)
+	}
+
+	merged, err := analysis.Merge(profileType, profiles)
+	if err != nil {
+		return "", err
+	}
+	return s3Uploader.UploadAggregate(ctx, prefix, service, profileType, start, merged, len(keys))
+}
+
+// configServices returns the services of the pods tracked for a config
+func (r *ProfilingConfigReconciler) configServices(config *profilingv1alpha1.ProfilingConfig) []string {
+	seen := make(map[string]struct{})
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		seen[uploader.ServiceName(tracked.Pod)] = struct{}{}
+	}
+
+	services := make([]string, 0, len(seen))
+	for service := range seen {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
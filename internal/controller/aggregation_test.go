@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregationWindow(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+
+	// At 10:01 the 09:00-10:00 window is still within the upload delay
+	start, end := aggregationWindow(now, time.Hour)
+	if !start.Equal(time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected window %v - %v", start, end)
+	}
+
+	start, end = aggregationWindow(now.Add(aggregationDelay), time.Hour)
+	if !start.Equal(time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected window %v - %v", start, end)
+	}
+}
+
+func TestRollupDay(t *testing.T) {
+	midnight := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+
+	if _, ok := rollupDay(midnight.Add(-2*time.Hour), midnight.Add(-time.Hour)); ok {
+		t.Error("Expected no rollup before the last window of the day")
+	}
+
+	day, ok := rollupDay(midnight.Add(-time.Hour), midnight)
+	if !ok || !day.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a rollup of 2024-01-15 after its last window, got %v, %v", day, ok)
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// auditTimeout bounds the time spent storing an audit record
+const auditTimeout = 30 * time.Second
+
+// Mechanisms recorded as the originator of a capture
+const (
+	triggeredByThreshold = "threshold"
+	triggeredByOnDemand  = "on-demand"
+	triggeredByRequest   = "request"
+)
+
+// newAuditRecord builds the audit record of a capture attempt
+func newAuditRecord(
+	config *profilingv1alpha1.ProfilingConfig,
+	pod *corev1.Pod,
+	profileTypes []string,
+	trigger metrics.Trigger,
+	capture *profilingv1alpha1.ProfileCapture,
+	manifest *uploader.Manifest,
+	captureErr error,
+	startedAt time.Time,
+) audit.Record {
+	record := audit.Record{
+		Time:        startedAt,
+		Config:      configKeyOf(config),
+		TriggeredBy: triggeredByThreshold,
+		Reason:      trigger.Reason,
+		Trigger:     uploader.NewTriggerValues(trigger),
+		Pod: audit.Pod{
+			Cluster:   podCluster(pod),
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       string(pod.UID),
+			Node:      pod.Spec.NodeName,
+		},
+		Service:         uploader.ServiceName(pod),
+		ProfileTypes:    profileTypes,
+		Bucket:          config.Spec.S3Config.Bucket,
+		Outcome:         audit.OutcomeSucceeded,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+	}
+
+	switch trigger.Reason {
+	case onDemandReason:
+		record.TriggeredBy = triggeredByOnDemand
+	case profilingv1alpha1.RequestedReason:
+		record.TriggeredBy = triggeredByRequest
+	default:
+		if trigger.Source != "" {
+			record.TriggeredBy = trigger.Source
+		}
+	}
+	if capture != nil {
+		record.Capture = capture.Name
+	}
+	if manifest != nil {
+		record.Manifest = manifest.Key
+		record.Summary = manifest.Summary
+		record.TopFunctions = manifest.TopFunctions
+		record.Comparison = manifest.Comparison
+		record.RegressionScore = manifest.RegressionScore
+		record.Regressed = manifest.Regressed
+		for _, object := range manifest.Objects {
+			record.Profiles = append(record.Profiles, audit.Profile{
+				Type:         object.Type,
+				Key:          object.Key,
+				SizeBytes:    object.SizeBytes,
+				Deduplicated: object.Deduplicated,
+				PresignedURL: object.URL,
+			})
+		}
+	}
+	if captureErr != nil {
+		record.Outcome = audit.OutcomeFailed
+		record.Error = captureErr.Error()
+	}
+
+	return record
+}
+
+// auditCapture stores the audit record of a capture attempt. Failures are logged
+// but never fail the capture.
+func (r *ProfilingConfigReconciler) auditCapture(ctx context.Context, record audit.Record) {
+	// Records of captures interrupted by a config deletion are still stored
+	auditCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditTimeout)
+	defer cancel()
+
+	if err := r.audit.Write(auditCtx, record); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to write audit record", "pod", record.Pod.Name, "config", record.Config)
+	}
+}
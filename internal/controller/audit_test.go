@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestNewAuditRecord(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	pod.Spec.NodeName = "node-1"
+	startedAt := time.Now().Add(-2 * time.Second)
+
+	manifest := &uploader.Manifest{
+		Key: "profiles/manifest.json",
+		Objects: []uploader.ManifestEntry{
+			{Type: "heap", Key: "profiles/heap.pprof", SizeBytes: 2048},
+			{Type: "cpu", Key: "profiles/cpu.pprof", SizeBytes: 4096},
+		},
+	}
+
+	record := newAuditRecord(config, pod, []string{"heap", "cpu"}, metrics.Trigger{Reason: "cpu"}, nil, manifest, nil, startedAt)
+
+	if record.Config != "default/test-config" || record.TriggeredBy != triggeredByThreshold || record.Reason != "cpu" {
+		t.Errorf("Unexpected trigger fields: %+v", record)
+	}
+	if record.Pod.Name != "test-pod" || record.Pod.Node != "node-1" {
+		t.Errorf("Unexpected pod: %+v", record.Pod)
+	}
+	if record.Bucket != "test-bucket" || record.Manifest != "profiles/manifest.json" || len(record.Profiles) != 2 {
+		t.Errorf("Unexpected destination: %+v", record)
+	}
+	if record.Profiles[1].SizeBytes != 4096 {
+		t.Errorf("Expected cpu profile size 4096, got %d", record.Profiles[1].SizeBytes)
+	}
+	if record.Outcome != audit.OutcomeSucceeded || record.DurationSeconds < 2 {
+		t.Errorf("Expected a successful outcome lasting at least 2s, got %s after %.1fs", record.Outcome, record.DurationSeconds)
+	}
+
+	failed := newAuditRecord(config, pod, []string{"heap"}, metrics.Trigger{Reason: onDemandReason}, nil, nil, errors.New("connection refused"), startedAt)
+	if failed.TriggeredBy != triggeredByOnDemand || failed.Outcome != audit.OutcomeFailed || failed.Error != "connection refused" {
+		t.Errorf("Unexpected failed record: %+v", failed)
+	}
+
+	plugged := newAuditRecord(config, pod, []string{"heap"}, metrics.Trigger{Reason: "deploy", Source: "rollouts"}, nil, nil, nil, startedAt)
+	if plugged.TriggeredBy != "rollouts" {
+		t.Errorf("Expected the trigger source to be recorded, got %s", plugged.TriggeredBy)
+	}
+}
+
+func TestAuditCapture(t *testing.T) {
+	var buf bytes.Buffer
+	reconciler := setupTestReconciler()
+	reconciler.audit = audit.NewWriterSink(&buf)
+
+	// Records are written even when the capture's context was cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reconciler.auditCapture(ctx, audit.Record{Config: "default/test-config", Outcome: audit.OutcomeFailed})
+
+	var record audit.Record
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON audit record, got %q: %v", buf.String(), err)
+	}
+	if record.Config != "default/test-config" {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// defaultRegressionThresholdPercent is the growth over a baseline reported as a
+// regression when a config sets none
+const defaultRegressionThresholdPercent = 10
+
+// baselineOf returns the baseline captures of a config are compared against,
+// nil if it has none
+func baselineOf(config *profilingv1alpha1.ProfilingConfig) *uploader.Baseline {
+	spec := config.Spec.Baseline
+	if spec == nil || len(spec.Profiles) == 0 {
+		return nil
+	}
+
+	baseline := &uploader.Baseline{
+		Bucket:           withDefault(spec.Bucket, config.Spec.S3Config.Bucket),
+		Keys:             make(map[string]string, len(spec.Profiles)),
+		ThresholdPercent: defaultRegressionThresholdPercent,
+	}
+	if spec.RegressionThresholdPercent > 0 {
+		baseline.ThresholdPercent = float64(spec.RegressionThresholdPercent)
+	}
+	for _, profile := range spec.Profiles {
+		baseline.Keys[profile.Type] = profile.Key
+	}
+	return baseline
+}
+
+// regressionResultOf returns the comparison of a capture to its baseline, nil
+// if the capture was not compared
+func regressionResultOf(manifest *uploader.Manifest) *profilingv1alpha1.RegressionResult {
+	if manifest == nil || manifest.RegressionScore == nil {
+		return nil
+	}
+	return &profilingv1alpha1.RegressionResult{
+		Score:     strconv.FormatFloat(*manifest.RegressionScore, 'f', 2, 64),
+		Regressed: manifest.Regressed,
+		ReportKey: manifest.Comparison,
+	}
+}
+
+// reportRegression records a warning event on the config when a capture of pod
+// regressed over its baseline
+func (r *ProfilingConfigReconciler) reportRegression(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, manifest *uploader.Manifest) {
+	result := regressionResultOf(manifest)
+	if result == nil || !result.Regressed {
+		return
+	}
+	r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonProfileRegression,
+		"Capture of pod %s is %s%% over its baseline, see s3://%s/%s",
+		r.podWatcher.getPodKey(pod), result.Score, manifest.Bucket, result.ReportKey)
+}
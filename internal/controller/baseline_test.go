@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestBaselineOf(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	if baselineOf(config) != nil {
+		t.Error("Expected no baseline for a config without one")
+	}
+
+	config.Spec.Baseline = &profilingv1alpha1.BaselineConfig{
+		Profiles: []profilingv1alpha1.BaselineProfile{{Type: "cpu", Key: "baselines/cpu.pprof"}},
+	}
+	baseline := baselineOf(config)
+	if baseline == nil {
+		t.Fatal("Expected a baseline")
+	}
+	if baseline.Bucket != config.Spec.S3Config.Bucket {
+		t.Errorf("Expected the baseline bucket to default to %q, got %q", config.Spec.S3Config.Bucket, baseline.Bucket)
+	}
+	if baseline.ThresholdPercent != defaultRegressionThresholdPercent {
+		t.Errorf("Expected default threshold, got %v", baseline.ThresholdPercent)
+	}
+	if baseline.Keys["cpu"] != "baselines/cpu.pprof" {
+		t.Errorf("Unexpected baseline keys %v", baseline.Keys)
+	}
+
+	config.Spec.Baseline.Bucket = "baselines"
+	config.Spec.Baseline.RegressionThresholdPercent = 25
+	if baseline := baselineOf(config); baseline.Bucket != "baselines" || baseline.ThresholdPercent != 25 {
+		t.Errorf("Unexpected baseline %+v", baseline)
+	}
+}
+
+func TestRegressionResultOf(t *testing.T) {
+	if regressionResultOf(&uploader.Manifest{}) != nil {
+		t.Error("Expected no result for a capture without comparison")
+	}
+
+	result := regressionResultOf(&uploader.Manifest{
+		Comparison:      "profiles/2024-01-15/my-app/20240115-103045-comparison.json",
+		RegressionScore: aws.Float64(12.346),
+		Regressed:       true,
+	})
+	if result == nil || result.Score != "12.35" || !result.Regressed || result.ReportKey == "" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
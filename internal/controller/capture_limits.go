@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"math/rand/v2"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// thresholdCandidate is a pod that exceeded its thresholds during a check
+type thresholdCandidate struct {
+	pod         *corev1.Pod
+	trigger     metrics.Trigger
+	utilization float64
+}
+
+// captureBudget returns the most threshold captures a check of a config
+// triggers, from maxCapturesPerCheck or its deprecated maxCapturesPerInterval
+// name. 0 is no limit.
+func captureBudget(config *profilingv1alpha1.ProfilingConfig) int {
+	if config.Spec.MaxCapturesPerCheck > 0 {
+		return config.Spec.MaxCapturesPerCheck
+	}
+	return config.Spec.MaxCapturesPerInterval
+}
+
+// limitCandidates returns at most max candidates, furthest over their thresholds
+// first. A max of 0 returns every candidate.
+func limitCandidates(candidates []thresholdCandidate, max int) []thresholdCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].utilization > candidates[j].utilization
+	})
+
+	if max <= 0 || len(candidates) <= max {
+		return candidates
+	}
+	return candidates[:max]
+}
+
+// sampled reports whether a threshold violation is sampled for capture, with a
+// probability of percent. Percentages outside (0, 100) sample every violation.
+func sampled(percent int) bool {
+	if percent <= 0 || percent >= 100 {
+		return true
+	}
+	return rand.IntN(100) < percent
+}
+
+// selectOnDemandPods returns at most max pods in key order, starting at cursor,
+// along with the cursor for the next tick so pods are profiled in turn. A max
+// of 0 returns every pod.
+func selectOnDemandPods(pods []*TrackedPod, cursor, max int) ([]*TrackedPod, int) {
+	sort.Slice(pods, func(i, j int) bool {
+		return trackedPodKey(pods[i]) < trackedPodKey(pods[j])
+	})
+
+	if max <= 0 || len(pods) <= max {
+		return pods, 0
+	}
+
+	start := cursor % len(pods)
+	selected := make([]*TrackedPod, 0, max)
+	for i := 0; i < max; i++ {
+		selected = append(selected, pods[(start+i)%len(pods)])
+	}
+
+	return selected, (start + max) % len(pods)
+}
+
+// selectServiceReplicas returns at most perService replicas of each service,
+// in key order, taking the replicas of every service in turn across ticks.
+// cursors holds the cursor of each service between ticks and is updated. A
+// perService of 0 returns every pod.
+func selectServiceReplicas(pods []*TrackedPod, cursors map[string]int, perService int) []*TrackedPod {
+	if perService <= 0 {
+		return pods
+	}
+
+	replicas := make(map[string][]*TrackedPod)
+	for _, tracked := range pods {
+		service := serviceKey(tracked.Pod)
+		replicas[service] = append(replicas[service], tracked)
+	}
+
+	// Services gone since the last tick lose their cursor
+	for service := range cursors {
+		if _, ok := replicas[service]; !ok {
+			delete(cursors, service)
+		}
+	}
+
+	var selected []*TrackedPod
+	for service, servicePods := range replicas {
+		var servicePicks []*TrackedPod
+		servicePicks, cursors[service] = selectOnDemandPods(servicePods, cursors[service], perService)
+		selected = append(selected, servicePicks...)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return trackedPodKey(selected[i]) < trackedPodKey(selected[j])
+	})
+	return selected
+}
+
+// serviceKey returns the key of the service of a pod, so that services of the
+// same name in other namespaces or clusters are kept apart
+func serviceKey(pod *corev1.Pod) string {
+	return podKey(podCluster(pod), pod.Namespace, uploader.ServiceName(pod))
+}
+
+// trackedPodKey returns the key of a tracked pod
+func trackedPodKey(tracked *TrackedPod) string {
+	return podKey(podCluster(tracked.Pod), tracked.Pod.Namespace, tracked.Pod.Name)
+}
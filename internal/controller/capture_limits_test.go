@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTrackedPods(names ...string) []*TrackedPod {
+	pods := make([]*TrackedPod, 0, len(names))
+	for _, name := range names {
+		pods = append(pods, &TrackedPod{
+			Pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}},
+		})
+	}
+	return pods
+}
+
+func trackedPodNames(pods []*TrackedPod) []string {
+	names := make([]string, 0, len(pods))
+	for _, tracked := range pods {
+		names = append(names, tracked.Pod.Name)
+	}
+	return names
+}
+
+func TestSelectOnDemandPods_NoLimit(t *testing.T) {
+	selected, cursor := selectOnDemandPods(newTrackedPods("pod-c", "pod-a", "pod-b"), 2, 0)
+
+	if got, want := trackedPodNames(selected), []string{"pod-a", "pod-b", "pod-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if cursor != 0 {
+		t.Errorf("Expected cursor 0, got %d", cursor)
+	}
+}
+
+func TestSelectOnDemandPods_Rotates(t *testing.T) {
+	ticks := [][]string{
+		{"pod-a", "pod-b"},
+		{"pod-c", "pod-d"},
+		{"pod-e", "pod-a"},
+		{"pod-b", "pod-c"},
+	}
+
+	cursor := 0
+	for i, want := range ticks {
+		var selected []*TrackedPod
+		selected, cursor = selectOnDemandPods(newTrackedPods("pod-e", "pod-d", "pod-c", "pod-b", "pod-a"), cursor, 2)
+		if got := trackedPodNames(selected); !reflect.DeepEqual(got, want) {
+			t.Errorf("Tick %d: expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestSelectOnDemandPods_CursorBeyondPods(t *testing.T) {
+	// Pods removed since the last tick leave the cursor past the end
+	selected, cursor := selectOnDemandPods(newTrackedPods("pod-a", "pod-b", "pod-c"), 7, 2)
+
+	if got, want := trackedPodNames(selected), []string{"pod-b", "pod-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if cursor != 0 {
+		t.Errorf("Expected cursor 0, got %d", cursor)
+	}
+}
+
+func TestSelectServiceReplicas(t *testing.T) {
+	pods := newTrackedPods("web-1", "api-2", "web-0", "api-1", "api-0")
+	for _, tracked := range pods {
+		tracked.Pod.Labels = map[string]string{"app": tracked.Pod.Name[:3]}
+	}
+
+	// Each tick profiles one replica of every service, in turn
+	ticks := [][]string{
+		{"api-0", "web-0"},
+		{"api-1", "web-1"},
+		{"api-2", "web-0"},
+	}
+	cursors := make(map[string]int)
+	for i, want := range ticks {
+		if got := trackedPodNames(selectServiceReplicas(pods, cursors, 1)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Tick %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	// Services gone since the last tick lose their cursor
+	var api []*TrackedPod
+	for _, tracked := range pods {
+		if tracked.Pod.Labels["app"] == "api" {
+			api = append(api, tracked)
+		}
+	}
+	selectServiceReplicas(api, cursors, 1)
+	if _, ok := cursors["default/web"]; ok || len(cursors) != 1 {
+		t.Errorf("Unexpected cursors %v", cursors)
+	}
+
+	if got := selectServiceReplicas(pods, cursors, 0); len(got) != len(pods) {
+		t.Errorf("Expected every pod without a limit, got %v", trackedPodNames(got))
+	}
+}
+
+func TestCaptureBudget(t *testing.T) {
+	tests := []struct {
+		name        string
+		perCheck    int
+		perInterval int
+		want        int
+	}{
+		{name: "no limit", want: 0},
+		{name: "per check", perCheck: 3, want: 3},
+		{name: "deprecated per interval", perInterval: 5, want: 5},
+		{name: "per check wins", perCheck: 3, perInterval: 3, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestProfilingConfig("test-config", "default")
+			config.Spec.MaxCapturesPerCheck = tt.perCheck
+			config.Spec.MaxCapturesPerInterval = tt.perInterval
+			if got := captureBudget(config); got != tt.want {
+				t.Errorf("Expected a budget of %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_CaptureBudget(t *testing.T) {
+	reconciler := setupTestReconciler()
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.MaxCapturesPerCheck = 3
+	config.Spec.MaxCapturesPerInterval = 3
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected matching budgets to be valid, got %v", err)
+	}
+
+	config.Spec.MaxCapturesPerCheck = 0
+	config.Spec.MaxCapturesPerInterval = 5
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected the deprecated budget alone to be valid, got %v", err)
+	}
+
+	config.Spec.MaxCapturesPerCheck = 3
+	err := reconciler.validateConfig(config)
+	if err == nil || !strings.Contains(err.Error(), "must be equal") {
+		t.Errorf("Expected conflicting budgets to be rejected, got %v", err)
+	}
+}
+
+func TestLimitCandidates(t *testing.T) {
+	newCandidate := func(name string, utilization float64) thresholdCandidate {
+		return thresholdCandidate{
+			pod:         &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			utilization: utilization,
+		}
+	}
+
+	tests := []struct {
+		name string
+		max  int
+		want []string
+	}{
+		{name: "no limit", max: 0, want: []string{"pod-b", "pod-c", "pod-a"}},
+		{name: "limit", max: 2, want: []string{"pod-b", "pod-c"}},
+		{name: "limit above candidates", max: 5, want: []string{"pod-b", "pod-c", "pod-a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := []thresholdCandidate{
+				newCandidate("pod-a", 1.1),
+				newCandidate("pod-b", 2.0),
+				newCandidate("pod-c", 1.5),
+			}
+
+			var got []string
+			for _, candidate := range limitCandidates(candidates, tt.max) {
+				got = append(got, candidate.pod.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSampled(t *testing.T) {
+	for _, percent := range []int{0, 100} {
+		for i := 0; i < 100; i++ {
+			if !sampled(percent) {
+				t.Fatalf("Expected every violation to be sampled at %d%%", percent)
+			}
+		}
+	}
+
+	count := 0
+	for i := 0; i < 10000; i++ {
+		if sampled(10) {
+			count++
+		}
+	}
+	if count < 800 || count > 1200 {
+		t.Errorf("Expected about 1000 of 10000 violations sampled at 10%%, got %d", count)
+	}
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=patch
+
+// CaptureNowAnnotation requests a single capture of a pod, regardless of
+// thresholds and cooldowns: "true" for the profile types of the config, or a
+// comma-separated list of profile types. The operator removes it once the
+// capture is requested.
+const CaptureNowAnnotation = "bolometer.io/capture-now"
+
+// captureNowProfileTypes returns the profile types requested by the value of
+// the capture-now annotation, nil for the config's
+func captureNowProfileTypes(value string) []string {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "true") {
+		return nil
+	}
+	return splitProfileTypes(value)
+}
+
+// splitProfileTypes returns the profile types of a comma-separated list, nil if
+// it holds none
+func splitProfileTypes(value string) []string {
+	var profileTypes []string
+	for _, profileType := range strings.Split(value, ",") {
+		if profileType = strings.TrimSpace(profileType); profileType != "" {
+			profileTypes = append(profileTypes, profileType)
+		}
+	}
+	return profileTypes
+}
+
+// runAnnotatedCaptures requests a capture of every pod of a config carrying the
+// capture-now annotation. The annotation is removed first, so a capture is
+// requested once even when several reconciles see it.
+func (r *ProfilingConfigReconciler) runAnnotatedCaptures(ctx context.Context, cluster *targetCluster, config *profilingv1alpha1.ProfilingConfig) {
+	logger := log.FromContext(ctx)
+
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		pod := tracked.Pod
+		value, ok := pod.Annotations[CaptureNowAnnotation]
+		if !ok {
+			continue
+		}
+
+		if err := removeCaptureNowAnnotation(ctx, cluster.clientset, pod, value); err != nil {
+			// The annotation changed or went away since the pod was listed,
+			// the next reconcile sees the current value
+			if !apierrors.IsInvalid(err) && !apierrors.IsNotFound(err) {
+				logger.Error(err, "Failed to remove capture-now annotation", "pod", pod.Name)
+			}
+			continue
+		}
+
+		key := r.podWatcher.getPodKey(pod)
+		capture, err := NewCaptureRequest(config, key, captureNowProfileTypes(value))
+		if err != nil {
+			logger.Error(err, "Failed to build requested ProfileCapture", "pod", pod.Name)
+			continue
+		}
+		if err := controllerutil.SetControllerReference(config, capture, r.Scheme); err != nil {
+			logger.Error(err, "Failed to set owner on ProfileCapture")
+		}
+		if err := r.Create(ctx, capture); err != nil {
+			logger.Error(err, "Failed to create requested ProfileCapture", "pod", pod.Name)
+			continue
+		}
+
+		logger.Info("Capture requested by annotation", "pod", key, "capture", capture.Name)
+		r.Recorder.Eventf(config, corev1.EventTypeNormal, "CaptureRequested",
+			"Capture %s of pod %s requested by the %s annotation", capture.Name, key, CaptureNowAnnotation)
+		r.startRequestedCapture(ctx, config, capture)
+	}
+}
+
+// removeCaptureNowAnnotation removes the capture-now annotation of a pod if it
+// still has the given value. The API server rejects the patch as invalid
+// otherwise.
+func removeCaptureNowAnnotation(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, value string) error {
+	path := "/metadata/annotations/" + strings.ReplaceAll(strings.ReplaceAll(CaptureNowAnnotation, "~", "~0"), "/", "~1")
+	patch, err := json.Marshal([]map[string]string{
+		{"op": "test", "path": path, "value": value},
+		{"op": "remove", "path": path},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = clientset.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func TestCaptureNowProfileTypes(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{"true", nil},
+		{"True", nil},
+		{"", nil},
+		{"heap", []string{"heap"}},
+		{"heap, goroutine,", []string{"heap", "goroutine"}},
+	}
+
+	for _, tt := range tests {
+		if got := captureNowProfileTypes(tt.value); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("captureNowProfileTypes(%q) = %v, expected %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestRunAnnotatedCaptures(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	pod.Annotations[CaptureNowAnnotation] = "heap,goroutine"
+	idle := createTestPod("idle-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod, idle)
+	reconciler.Clientset = fake.NewSimpleClientset(pod.DeepCopy(), idle.DeepCopy())
+	reconciler.podWatcher.TrackPod(pod, config)
+	reconciler.podWatcher.TrackPod(idle, config)
+	ctx := context.Background()
+
+	reconciler.runAnnotatedCaptures(ctx, reconciler.localCluster(), config)
+
+	patched, err := reconciler.Clientset.CoreV1().Pods("default").Get(ctx, "test-pod", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get pod: %v", err)
+	}
+	if _, ok := patched.Annotations[CaptureNowAnnotation]; ok || patched.Annotations[ProfilingEnabledAnnotation] != "true" {
+		t.Errorf("Expected only the capture-now annotation to be removed, got %v", patched.Annotations)
+	}
+
+	captures := &profilingv1alpha1.ProfileCaptureList{}
+	if err := reconciler.List(ctx, captures, client.MatchingLabels{profilingv1alpha1.RequestedLabel: "true"}); err != nil {
+		t.Fatalf("Failed to list captures: %v", err)
+	}
+	if len(captures.Items) != 1 {
+		t.Fatalf("Expected 1 requested capture, got %d", len(captures.Items))
+	}
+	capture := captures.Items[0]
+	if capture.Spec.PodName != "test-pod" || !reflect.DeepEqual(capture.Spec.ProfileTypes, []string{"heap", "goroutine"}) {
+		t.Errorf("Unexpected capture spec %+v", capture.Spec)
+	}
+	if capture.Status.Phase != profilingv1alpha1.CapturePhaseRunning {
+		t.Errorf("Expected the capture to be running, got %q", capture.Status.Phase)
+	}
+	if pending := reconciler.captureQueue.Pending("default/test-config"); pending != 1 {
+		t.Errorf("Expected 1 queued capture, got %d", pending)
+	}
+
+	// A reconcile still seeing the annotation does not request another capture
+	reconciler.runAnnotatedCaptures(ctx, reconciler.localCluster(), config)
+	if err := reconciler.List(ctx, captures, client.MatchingLabels{profilingv1alpha1.RequestedLabel: "true"}); err != nil {
+		t.Fatalf("Failed to list captures: %v", err)
+	}
+	if len(captures.Items) != 1 {
+		t.Errorf("Expected the capture to be requested once, got %d", len(captures.Items))
+	}
+}
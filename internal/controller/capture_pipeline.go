@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// CaptureState is a capture moving through the capture pipeline. Hooks may
+// change Profiles; the other fields are read-only.
+type CaptureState struct {
+	Config       *profilingv1alpha1.ProfilingConfig
+	Pod          *corev1.Pod
+	ProfileTypes []string
+	Trigger      metrics.Trigger
+
+	// Profiles are the captured profiles, set once captured
+	Profiles []profiler.Profile
+
+	// Manifest describes the uploaded objects, set once uploaded
+	Manifest *uploader.Manifest
+
+	// Leaks are the leaks detected in the uploaded profiles
+	Leaks []analysis.Leak
+
+	// storage is the uploader the profiles were uploaded with
+	storage uploader.Uploader
+
+	// fingerprints are the fingerprints of the profiles by type, set when the
+	// config deduplicates profiles
+	fingerprints map[string]string
+
+	// restore undoes what the pre-capture exec hooks changed in the pod, set
+	// from the pre-capture stage until the post-capture exec hooks run
+	restore func(ctx context.Context) error
+}
+
+// CaptureHookFunc runs a hook at a stage of the capture pipeline
+type CaptureHookFunc func(ctx context.Context, capture *CaptureState) error
+
+// CaptureHook plugs a stage into the capture pipeline, e.g. validation,
+// redaction or compression of the profiles. Each of its functions is optional.
+// An error of a hook running before the upload fails the capture; the profiles
+// of a failed post-upload hook are already stored, so its error is only logged.
+type CaptureHook struct {
+	// Name identifies the hook in errors and logs
+	Name string
+
+	// PreCapture runs before the profiles are captured
+	PreCapture CaptureHookFunc
+
+	// PostCapture runs once the profiles are captured
+	PostCapture CaptureHookFunc
+
+	// PreUpload runs before the profiles are uploaded, after every PostCapture
+	PreUpload CaptureHookFunc
+
+	// PostUpload runs once the profiles are uploaded
+	PostUpload CaptureHookFunc
+}
+
+// captureHooks returns the built-in hooks followed by the added ones, then
+// deduplication, which compares the profiles as the added hooks left them
+func (r *ProfilingConfigReconciler) captureHooks() []CaptureHook {
+	hooks := []CaptureHook{
+		// Exec hooks of the config prepare the pod before the added hooks run
+		r.execHook(),
+		// The profiles are uploaded, failing to presign only leaves the capture without links
+		{Name: "presign", PostUpload: presignCapture},
+		// Leaks are only followed across uploaded profiles
+		{Name: "leaks", PostUpload: func(_ context.Context, capture *CaptureState) error {
+			capture.Leaks = r.detectLeaks(capture.Config, capture.Pod, capture.Profiles)
+			return nil
+		}},
+	}
+	hooks = append(hooks, r.addedHooks...)
+	return append(hooks, r.dedupHook())
+}
+
+// runCaptureHooks runs a stage of the hooks in order, stopping at the first error
+func runCaptureHooks(ctx context.Context, hooks []CaptureHook, stage func(CaptureHook) CaptureHookFunc, capture *CaptureState) error {
+	for _, hook := range hooks {
+		run := stage(hook)
+		if run == nil {
+			continue
+		}
+		if err := run(ctx, capture); err != nil {
+			return fmt.Errorf("capture hook %s failed: %w", hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// captureAndUploadProfiles captures the given profile types and uploads them to
+// the config's storage through the capture pipeline, returning the leaks
+// detected once the profiles are uploaded
+func (r *ProfilingConfigReconciler) captureAndUploadProfiles(ctx context.Context, pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig, profileTypes []string, trigger metrics.Trigger) (*uploader.Manifest, []analysis.Leak, error) {
+	capture := &CaptureState{Config: config, Pod: pod, ProfileTypes: profileTypes, Trigger: trigger}
+	err := r.runCapturePipeline(ctx, capture)
+	return capture.Manifest, capture.Leaks, err
+}
+
+// runCapturePipeline captures the profiles of a capture and uploads them,
+// running the hooks of each stage around both
+func (r *ProfilingConfigReconciler) runCapturePipeline(ctx context.Context, capture *CaptureState) error {
+	cluster, err := r.clusterOf(capture.Config)
+	if err != nil {
+		return err
+	}
+	hooks := r.captureHooks()
+
+	// A pod changed by pre-capture exec hooks is restored even if the capture
+	// fails before the post-capture stage
+	defer func() {
+		if capture.restore == nil {
+			return
+		}
+		if err := capture.restore(context.WithoutCancel(ctx)); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to restore the pod after a failed capture", "pod", capture.Pod.Name)
+		}
+	}()
+
+	if err := runCaptureHooks(ctx, hooks, func(h CaptureHook) CaptureHookFunc { return h.PreCapture }, capture); err != nil {
+		return err
+	}
+
+	// Capture profiles
+	capture.Profiles, err = cluster.profiler.CaptureProfiles(ctx, capture.Pod, capture.ProfileTypes)
+	if err != nil {
+		return fmt.Errorf("failed to capture profiles: %w", err)
+	}
+
+	if err := runCaptureHooks(ctx, hooks, func(h CaptureHook) CaptureHookFunc { return h.PostCapture }, capture); err != nil {
+		return err
+	}
+
+	// Profiles already captured are uploaded even if monitoring is stopped meanwhile,
+	// so that deleting a config flushes them instead of dropping them
+	uploadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), uploadTimeout)
+	defer cancel()
+
+	if err := runCaptureHooks(uploadCtx, hooks, func(h CaptureHook) CaptureHookFunc { return h.PreUpload }, capture); err != nil {
+		return err
+	}
+
+	// Create the uploader of the config's storage
+	s3Config, err := r.storageConfigOf(uploadCtx, capture.Config)
+	if err == nil {
+		capture.storage, err = r.uploaders(uploadCtx, s3Config)
+	}
+	if err != nil {
+		return &uploadError{fmt.Errorf("failed to create uploader: %w", err)}
+	}
+
+	// Upload profiles
+	capture.Manifest, err = capture.storage.UploadProfiles(uploadCtx, capture.Pod, capture.Profiles, capture.Trigger)
+	if err != nil {
+		return &uploadError{fmt.Errorf("failed to upload profiles: %w", err)}
+	}
+
+	// Each post-upload hook runs even if another one failed
+	for _, hook := range hooks {
+		if hook.PostUpload == nil {
+			continue
+		}
+		if err := hook.PostUpload(uploadCtx, capture); err != nil {
+			log.FromContext(ctx).Error(err, "Capture hook failed after upload", "hook", hook.Name, "pod", capture.Pod.Name)
+		}
+	}
+	return nil
+}
+
+// presignCapture presigns download URLs of the uploaded profiles of a capture
+// when its config asks for them and its uploader can
+func presignCapture(ctx context.Context, capture *CaptureState) error {
+	seconds := capture.Config.Spec.S3Config.PresignExpirySeconds
+	presigner, ok := capture.storage.(uploader.Presigner)
+	if seconds <= 0 || !ok {
+		return nil
+	}
+	return presigner.PresignManifest(ctx, capture.Manifest, time.Duration(seconds)*time.Second)
+}
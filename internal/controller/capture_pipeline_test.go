@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// setupPipelineReconciler returns a reconciler capturing heap profiles through
+// fakes, running the given hooks
+func setupPipelineReconciler(hooks ...CaptureHook) (*ProfilingConfigReconciler, *profiler.Fake, *uploader.Fake) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	podProfiler := &profiler.Fake{Data: map[string][]byte{"heap": []byte("heap profile")}}
+	reconciler.profiler = podProfiler
+	profileUploader := &uploader.Fake{Bucket: "test-bucket"}
+	reconciler.uploaders = func(context.Context, uploader.S3Config) (uploader.Uploader, error) {
+		return profileUploader, nil
+	}
+	reconciler.addedHooks = hooks
+	return reconciler, podProfiler, profileUploader
+}
+
+func TestCapturePipeline_Hooks(t *testing.T) {
+	var stages []string
+	stage := func(name string) CaptureHookFunc {
+		return func(context.Context, *CaptureState) error {
+			stages = append(stages, name)
+			return nil
+		}
+	}
+	redact := CaptureHook{
+		Name:       "redact",
+		PreCapture: stage("pre-capture"),
+		PostCapture: func(_ context.Context, capture *CaptureState) error {
+			stages = append(stages, "post-capture")
+			for i := range capture.Profiles {
+				capture.Profiles[i].Data = []byte("redacted")
+			}
+			return nil
+		},
+		PreUpload: stage("pre-upload"),
+		PostUpload: func(_ context.Context, capture *CaptureState) error {
+			stages = append(stages, "post-upload")
+			if capture.Manifest == nil {
+				t.Error("Expected the manifest to be set after upload")
+			}
+			return nil
+		},
+	}
+	reconciler, _, _ := setupPipelineReconciler(redact)
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+
+	manifest, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err != nil {
+		t.Fatalf("captureAndUploadProfiles returned unexpected error: %v", err)
+	}
+	if got := strings.Join(stages, ","); got != "pre-capture,post-capture,pre-upload,post-upload" {
+		t.Errorf("Unexpected stages %s", got)
+	}
+	if len(manifest.Objects) != 1 || manifest.Objects[0].SizeBytes != len("redacted") {
+		t.Errorf("Expected the redacted profile to be uploaded, got %+v", manifest.Objects)
+	}
+}
+
+func TestCapturePipeline_HookErrors(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	failing := func(context.Context, *CaptureState) error { return errors.New("pod excluded") }
+
+	// A failed pre-capture hook skips the capture
+	reconciler, podProfiler, profileUploader := setupPipelineReconciler(CaptureHook{Name: "exclude", PreCapture: failing})
+	_, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err == nil || !strings.Contains(err.Error(), "capture hook exclude failed") || isUploadError(err) {
+		t.Errorf("Expected the hook's error, got %v", err)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 0 {
+		t.Errorf("Expected no capture, got %v", captured)
+	}
+
+	// A failed pre-upload hook skips the upload
+	reconciler, _, profileUploader = setupPipelineReconciler(CaptureHook{Name: "validate", PreUpload: failing})
+	_, _, err = reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err == nil || !strings.Contains(err.Error(), "capture hook validate failed") {
+		t.Errorf("Expected the hook's error, got %v", err)
+	}
+	if uploaded := profileUploader.Uploaded(); len(uploaded) != 0 {
+		t.Errorf("Expected no upload, got %v", uploaded)
+	}
+
+	// The profiles of a failed post-upload hook are stored, the capture succeeds
+	reconciler, _, profileUploader = setupPipelineReconciler(CaptureHook{Name: "index", PostUpload: failing})
+	manifest, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err != nil || manifest == nil {
+		t.Errorf("Expected the capture to succeed, got %v", err)
+	}
+	if uploaded := profileUploader.Uploaded(); len(uploaded) != 1 {
+		t.Errorf("Expected one upload, got %v", uploaded)
+	}
+}
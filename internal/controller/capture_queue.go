@@ -0,0 +1,341 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+const (
+	// DefaultCaptureWorkers is the default number of captures run concurrently across all configs
+	DefaultCaptureWorkers = 4
+
+	// DefaultCaptureQueueSize is the default number of captures that can wait for a worker
+	DefaultCaptureQueueSize = 100
+
+	// stuckCaptureTimeout is how long a capture may run, or a full queue may go
+	// without finishing a capture, before the queue is reported as wedged
+	stuckCaptureTimeout = 10 * time.Minute
+)
+
+// CaptureJob is a single capture waiting for a worker
+type CaptureJob struct {
+	// ConfigKey identifies the ProfilingConfig the capture belongs to
+	ConfigKey string
+
+	// PodKey identifies the pod being captured; a pod is queued at most once
+	PodKey string
+
+	// Priority orders queued jobs, highest first; jobs of equal priority run in
+	// the order they were queued
+	Priority int
+
+	// Sheddable jobs, such as on-demand captures, run after other jobs of their
+	// priority and are dropped from a full queue for a job that runs before them
+	Sheddable bool
+
+	// Run performs the capture
+	Run func()
+}
+
+// CaptureQueue runs capture jobs on a bounded pool of workers so slow captures do
+// not hold up threshold checks. It enforces the global worker count and a
+// per-config limit on queued and running captures. Queued jobs run by priority.
+type CaptureQueue struct {
+	workers int
+	size    int
+	logger  logr.Logger
+
+	// ready holds a token per queued job, waking a worker to run one
+	ready chan struct{}
+
+	mu        sync.Mutex
+	queued    []CaptureJob
+	pending   map[string]struct{}
+	perConfig map[string]int
+	waiters   map[string][]chan struct{}
+
+	// limiter bounds the rate captures are started at, nil if unlimited
+	limiter *rate.Limiter
+
+	// running holds the start time of each running capture by pod key
+	running      map[string]time.Time
+	lastFinished time.Time
+
+	// panics counts the panics of the jobs of each config
+	panics *metrics.Panics
+}
+
+// NewCaptureQueue creates a capture queue with the given number of workers and capacity
+func NewCaptureQueue(workers, size int) *CaptureQueue {
+	if workers <= 0 {
+		workers = DefaultCaptureWorkers
+	}
+	if size <= 0 {
+		size = DefaultCaptureQueueSize
+	}
+
+	return &CaptureQueue{
+		workers:   workers,
+		size:      size,
+		logger:    ctrl.Log.WithName("captures"),
+		ready:     make(chan struct{}, size),
+		pending:   make(map[string]struct{}),
+		perConfig: make(map[string]int),
+		waiters:   make(map[string][]chan struct{}),
+
+		running:      make(map[string]time.Time),
+		lastFinished: time.Now(),
+		panics:       metrics.NewPanics(),
+	}
+}
+
+// CountPanics counts the panics of jobs with panics, e.g. counters registered
+// with the metrics endpoint
+func (q *CaptureQueue) CountPanics(panics *metrics.Panics) {
+	q.panics = panics
+}
+
+// SetRateLimit limits the number of captures started per minute across all
+// configs. 0 removes the limit. It can be changed while the queue is running.
+func (q *CaptureQueue) SetRateLimit(perMinute int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if perMinute <= 0 {
+		q.limiter = nil
+		return
+	}
+
+	limit := rate.Every(time.Minute / time.Duration(perMinute))
+	if q.limiter != nil && q.limiter.Limit() == limit && q.limiter.Burst() == perMinute {
+		return
+	}
+	q.limiter = rate.NewLimiter(limit, perMinute)
+}
+
+// Enqueue queues a job unless its pod is already queued, its config already has
+// limit captures queued or running (0 means no limit), or the queue is full.
+// A full queue sheds its lowest ranked sheddable job for a job running before it.
+// It reports whether the job was queued.
+func (q *CaptureQueue) Enqueue(job CaptureJob, limit int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.pending[job.PodKey]; ok {
+		return false
+	}
+	if limit > 0 && q.perConfig[job.ConfigKey] >= limit {
+		return false
+	}
+
+	if len(q.queued) >= q.size {
+		if !q.shed(job) {
+			return false
+		}
+		// The shed job's token wakes a worker for this one
+		q.queued = append(q.queued, job)
+	} else {
+		q.queued = append(q.queued, job)
+		q.ready <- struct{}{}
+	}
+
+	q.pending[job.PodKey] = struct{}{}
+	q.perConfig[job.ConfigKey]++
+	return true
+}
+
+// shed drops the lowest ranked sheddable job queued, the most recent of equal
+// rank, if job runs before it. It reports whether a job was dropped.
+func (q *CaptureQueue) shed(job CaptureJob) bool {
+	victim := -1
+	for i, queued := range q.queued {
+		if queued.Sheddable && (victim < 0 || !runsBefore(queued, q.queued[victim])) {
+			victim = i
+		}
+	}
+	if victim < 0 || !runsBefore(job, q.queued[victim]) {
+		return false
+	}
+
+	dropped := q.queued[victim]
+	q.queued = append(q.queued[:victim], q.queued[victim+1:]...)
+	q.release(dropped)
+	q.logger.Info("Capture queue full, shedding capture", "config", dropped.ConfigKey, "pod", dropped.PodKey,
+		"priority", dropped.Priority, "for", job.PodKey)
+	return true
+}
+
+// next removes and returns the highest ranked queued job, the earliest queued
+// of equal rank
+func (q *CaptureQueue) next() CaptureJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	best := 0
+	for i, queued := range q.queued {
+		if runsBefore(queued, q.queued[best]) {
+			best = i
+		}
+	}
+	job := q.queued[best]
+	q.queued = append(q.queued[:best], q.queued[best+1:]...)
+	return job
+}
+
+// runsBefore reports whether job a runs before job b: a has a higher priority, or
+// the same priority and only b is sheddable
+func runsBefore(a, b CaptureJob) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return !a.Sheddable && b.Sheddable
+}
+
+// Pending returns the number of captures queued or running for a config
+func (q *CaptureQueue) Pending(configKey string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.perConfig[configKey]
+}
+
+// Wait blocks until no captures are queued or running for a config
+func (q *CaptureQueue) Wait(ctx context.Context, configKey string) error {
+	q.mu.Lock()
+	if q.perConfig[configKey] == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	q.waiters[configKey] = append(q.waiters[configKey], idle)
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Start runs the workers until ctx is cancelled. It implements manager.Runnable.
+func (q *CaptureQueue) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(q.workers)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	return nil
+}
+
+// work runs queued jobs until ctx is cancelled
+func (q *CaptureQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.ready:
+			job := q.next()
+
+			q.mu.Lock()
+			limiter := q.limiter
+			q.mu.Unlock()
+
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					q.finish(job)
+					return
+				}
+			}
+
+			q.mu.Lock()
+			q.running[job.PodKey] = time.Now()
+			q.mu.Unlock()
+
+			// The worker carries on with the next job after a panic
+			if panicked, ok := asPanic(runJob(job)); ok {
+				q.panics.Inc(job.ConfigKey, metrics.TaskCapture)
+				q.logger.Error(panicked, "Capture job panicked", "config", job.ConfigKey, "pod", job.PodKey, "stack", string(panicked.stack))
+			}
+			q.finish(job)
+		}
+	}
+}
+
+// finish releases the slots of a job that ran
+func (q *CaptureQueue) finish(job CaptureJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.running, job.PodKey)
+	q.lastFinished = time.Now()
+	q.release(job)
+}
+
+// release releases a job's pod and config slots and wakes waiters of an idle
+// config. q.mu must be held.
+func (q *CaptureQueue) release(job CaptureJob) {
+	delete(q.pending, job.PodKey)
+	q.perConfig[job.ConfigKey]--
+	if q.perConfig[job.ConfigKey] > 0 {
+		return
+	}
+
+	delete(q.perConfig, job.ConfigKey)
+	for _, idle := range q.waiters[job.ConfigKey] {
+		close(idle)
+	}
+	delete(q.waiters, job.ConfigKey)
+}
+
+// Check reports an error if a capture has been running for longer than
+// stuckCaptureTimeout, or the queue is full and no capture has finished within
+// it. It implements healthz.Checker.
+func (q *CaptureQueue) Check(_ *http.Request) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pods := make([]string, 0, len(q.running))
+	for pod := range q.running {
+		pods = append(pods, pod)
+	}
+	sort.Strings(pods)
+
+	var errs []error
+	for _, pod := range pods {
+		if elapsed := time.Since(q.running[pod]); elapsed > stuckCaptureTimeout {
+			errs = append(errs, fmt.Errorf("capture of pod %s has been running for %s", pod, elapsed.Round(time.Second)))
+		}
+	}
+
+	if len(q.queued) >= q.size && time.Since(q.lastFinished) > stuckCaptureTimeout {
+		errs = append(errs, fmt.Errorf("capture queue is full and no capture finished in %s",
+			time.Since(q.lastFinished).Round(time.Second)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// runJob runs a job, converting a panic into a *panicError
+func runJob(job CaptureJob) (err error) {
+	defer recoverPanic("capture job", &err)
+
+	job.Run()
+	return nil
+}
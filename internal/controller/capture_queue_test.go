@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCaptureQueue_Enqueue(t *testing.T) {
+	queue := NewCaptureQueue(1, 2)
+	noop := func() {}
+
+	if !queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-1", Run: noop}, 0) {
+		t.Fatal("Expected first job to be queued")
+	}
+
+	// The same pod is only queued once
+	if queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-1", Run: noop}, 0) {
+		t.Error("Expected duplicate pod to be rejected")
+	}
+
+	// Per-config limit
+	if queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-2", Run: noop}, 1) {
+		t.Error("Expected job beyond the config limit to be rejected")
+	}
+
+	if !queue.Enqueue(CaptureJob{ConfigKey: "default/b", PodKey: "default/pod-3", Run: noop}, 1) {
+		t.Error("Expected job of another config to be queued")
+	}
+
+	// Queue capacity
+	if queue.Enqueue(CaptureJob{ConfigKey: "default/c", PodKey: "default/pod-4", Run: noop}, 0) {
+		t.Error("Expected job to be rejected when the queue is full")
+	}
+
+	if pending := queue.Pending("default/a"); pending != 1 {
+		t.Errorf("Expected 1 pending job for default/a, got %d", pending)
+	}
+}
+
+func TestCaptureQueue_RunsJobsAndWaits(t *testing.T) {
+	queue := NewCaptureQueue(2, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = queue.Start(ctx) }()
+
+	var ran atomic.Int32
+	release := make(chan struct{})
+	for _, pod := range []string{"default/pod-1", "default/pod-2"} {
+		queue.Enqueue(CaptureJob{
+			ConfigKey: "default/a",
+			PodKey:    pod,
+			Run: func() {
+				<-release
+				ran.Add(1)
+			},
+		}, 0)
+	}
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelWait()
+	if err := queue.Wait(waitCtx, "default/a"); err == nil {
+		t.Fatal("Expected Wait to block while jobs are running")
+	}
+
+	close(release)
+
+	waitCtx, cancelWait = context.WithTimeout(context.Background(), time.Second)
+	defer cancelWait()
+	if err := queue.Wait(waitCtx, "default/a"); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	if ran.Load() != 2 {
+		t.Errorf("Expected 2 jobs to run, got %d", ran.Load())
+	}
+
+	// Finished pods can be queued again
+	if !queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-1", Run: func() {}}, 1) {
+		t.Error("Expected pod to be queued again after its capture finished")
+	}
+}
+
+func TestCaptureQueue_RecoversFromPanic(t *testing.T) {
+	queue := NewCaptureQueue(1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = queue.Start(ctx) }()
+
+	queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-1", Run: func() { panic("boom") }}, 0)
+
+	var ran atomic.Bool
+	queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-2", Run: func() { ran.Store(true) }}, 0)
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), time.Second)
+	defer cancelWait()
+	if err := queue.Wait(waitCtx, "default/a"); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	if !ran.Load() {
+		t.Error("Expected worker to keep running after a panic")
+	}
+	if count := testutil.ToFloat64(queue.panics); count != 1 {
+		t.Errorf("Expected 1 panic counted, got %v", count)
+	}
+}
+
+func TestCaptureQueue_Priority(t *testing.T) {
+	queue := NewCaptureQueue(1, 3)
+	var mu sync.Mutex
+	var order []string
+	job := func(pod string, priority int, sheddable bool) CaptureJob {
+		return CaptureJob{
+			ConfigKey: "default/a",
+			PodKey:    pod,
+			Priority:  priority,
+			Sheddable: sheddable,
+			Run: func() {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, pod)
+			},
+		}
+	}
+
+	queue.Enqueue(job("default/on-demand", 0, true), 0)
+	queue.Enqueue(job("default/threshold", 0, false), 0)
+	queue.Enqueue(job("default/critical", 5, false), 0)
+
+	// The full queue sheds the on-demand capture for a threshold capture, but
+	// not for another on-demand capture
+	if !queue.Enqueue(job("default/threshold-2", 0, false), 0) {
+		t.Error("Expected the on-demand capture to be shed for a threshold capture")
+	}
+	if queue.Enqueue(job("default/on-demand-2", 0, true), 0) {
+		t.Error("Expected an on-demand capture to be rejected by a full queue")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = queue.Start(ctx) }()
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), time.Second)
+	defer cancelWait()
+	if err := queue.Wait(waitCtx, "default/a"); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"default/critical", "default/threshold", "default/threshold-2"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected jobs to run in order %v, got %v", expected, order)
+	}
+}
+
+func TestCaptureQueue_RateLimit(t *testing.T) {
+	queue := NewCaptureQueue(2, 10)
+	queue.SetRateLimit(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = queue.Start(ctx) }()
+
+	var ran atomic.Int32
+	for _, pod := range []string{"default/pod-1", "default/pod-2"} {
+		queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: pod, Run: func() { ran.Add(1) }}, 0)
+	}
+
+	// The burst allows one capture, the next has to wait a minute
+	waitFor(t, time.Second, func() bool { return ran.Load() == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if got := ran.Load(); got != 1 {
+		t.Fatalf("Expected 1 capture within the rate limit, got %d", got)
+	}
+
+	// Stopping the queue releases the job waiting on the limiter
+	cancel()
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	if err := queue.Wait(waitCtx, "default/a"); err != nil {
+		t.Errorf("Expected rate limited job to be released on shutdown: %v", err)
+	}
+}
+
+func TestCaptureQueue_Check(t *testing.T) {
+	queue := NewCaptureQueue(1, 1)
+
+	if err := queue.Check(nil); err != nil {
+		t.Fatalf("Expected an idle queue to be healthy, got %v", err)
+	}
+
+	// A full queue is healthy while captures are still finishing
+	queue.Enqueue(CaptureJob{ConfigKey: "default/a", PodKey: "default/pod-1", Run: func() {}}, 0)
+	if err := queue.Check(nil); err != nil {
+		t.Errorf("Expected a full but moving queue to be healthy, got %v", err)
+	}
+
+	queue.mu.Lock()
+	queue.lastFinished = time.Now().Add(-2 * stuckCaptureTimeout)
+	queue.mu.Unlock()
+	if err := queue.Check(nil); err == nil || !strings.Contains(err.Error(), "queue is full") {
+		t.Errorf("Expected a wedged queue error, got %v", err)
+	}
+
+	// A capture running for too long is reported
+	queue = NewCaptureQueue(1, 10)
+	queue.mu.Lock()
+	queue.running["default/pod-2"] = time.Now().Add(-2 * stuckCaptureTimeout)
+	queue.mu.Unlock()
+	if err := queue.Check(nil); err == nil || !strings.Contains(err.Error(), "default/pod-2") {
+		t.Errorf("Expected a stuck capture error, got %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+const (
+	// defaultCircuitFailureThreshold is the number of failed uploads in a row
+	// opening a circuit when a config sets none
+	defaultCircuitFailureThreshold = 3
+
+	// defaultCircuitProbeInterval is how long a circuit first stays open when
+	// a config sets no interval
+	defaultCircuitProbeInterval = time.Minute
+
+	// defaultCircuitMaxProbeInterval caps the probe interval when a config
+	// sets no cap
+	defaultCircuitMaxProbeInterval = 30 * time.Minute
+
+	// defaultCircuitMaxQueued is the number of captures of a config held back
+	// while a circuit is open when a config sets none
+	defaultCircuitMaxQueued = 10
+)
+
+// uploadCircuits tracks the circuit breaker of each upload destination. A
+// circuit opens after a number of failed uploads in a row, holding back the
+// automatic captures of the configs uploading there. Once the probe interval
+// passed a single probe, the next capture or an access check, is let
+// through; its success closes the circuit, its failure doubles the interval.
+// The zero value is ready to use.
+type uploadCircuits struct {
+	mu sync.Mutex
+
+	// circuits holds the circuit of each destination
+	circuits map[string]*uploadCircuit
+}
+
+// uploadCircuit is the circuit breaker of a destination
+type uploadCircuit struct {
+	// failures counts the uploads failed in a row
+	failures  int
+	lastError string
+
+	open          bool
+	probeInterval time.Duration
+	probeAt       time.Time
+
+	// probing is set while a probe is let through
+	probing bool
+
+	// queued holds the captures held back, by config
+	queued map[string][]heldCapture
+}
+
+// heldCapture is a capture held back while a circuit is open
+type heldCapture struct {
+	job CaptureJob
+
+	// concurrency is the limit of captures of the config queued at once
+	concurrency int
+}
+
+// circuitStatus is the state of a circuit reported in the status
+type circuitStatus struct {
+	open      bool
+	failures  int
+	probeAt   time.Time
+	lastError string
+}
+
+// admit reports whether a capture uploading to a destination may run at now,
+// which it may while the circuit is closed or as the probe of an open circuit
+func (c *uploadCircuits) admit(destination string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	circuit, ok := c.circuits[destination]
+	if !ok || !circuit.open {
+		return true
+	}
+	return circuit.claimProbe(now)
+}
+
+// claimProbe reports whether the probe of an open circuit is due at now,
+// claiming it if so. c.mu must be held.
+func (u *uploadCircuit) claimProbe(now time.Time) bool {
+	if u.probing || now.Before(u.probeAt) {
+		return false
+	}
+	u.probing = true
+	return true
+}
+
+// claimProbe reports whether the probe of the open circuit of a destination
+// is due at now, claiming it if so
+func (c *uploadCircuits) claimProbe(destination string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	circuit, ok := c.circuits[destination]
+	return ok && circuit.open && circuit.claimProbe(now)
+}
+
+// succeeded records an upload to a destination that succeeded. It reports
+// whether this closed the circuit, returning the captures held back by config.
+func (c *uploadCircuits) succeeded(destination string) (bool, map[string][]heldCapture) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	circuit, ok := c.circuits[destination]
+	if !ok {
+		return false, nil
+	}
+	delete(c.circuits, destination)
+	return circuit.open, circuit.queued
+}
+
+// failed records an upload to a destination that failed at now under the
+// circuit breaker of a config. It reports whether this opened the circuit.
+// While open, only the failure of a probe pushes the next probe back.
+func (c *uploadCircuits) failed(destination string, breaker *profilingv1alpha1.CircuitBreakerConfig, err error, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.circuits == nil {
+		c.circuits = make(map[string]*uploadCircuit)
+	}
+	circuit, ok := c.circuits[destination]
+	if !ok {
+		circuit = &uploadCircuit{}
+		c.circuits[destination] = circuit
+	}
+	circuit.failures++
+	circuit.lastError = err.Error()
+
+	switch {
+	case circuit.open && circuit.probing:
+		circuit.probing = false
+		maxInterval := time.Duration(breaker.MaxProbeIntervalSeconds) * time.Second
+		if maxInterval <= 0 {
+			maxInterval = defaultCircuitMaxProbeInterval
+		}
+		circuit.probeInterval = min(2*circuit.probeInterval, maxInterval)
+		circuit.probeAt = now.Add(circuit.probeInterval)
+		return false
+	case circuit.open:
+		return false
+	case circuit.failures >= withDefault(breaker.FailureThreshold, defaultCircuitFailureThreshold):
+		circuit.open = true
+		circuit.probeInterval = time.Duration(breaker.ProbeIntervalSeconds) * time.Second
+		if circuit.probeInterval <= 0 {
+			circuit.probeInterval = defaultCircuitProbeInterval
+		}
+		circuit.probeAt = now.Add(circuit.probeInterval)
+		return true
+	}
+	return false
+}
+
+// release ends the probe of a destination that told nothing about it, such as
+// a capture failing before its upload, so that the next capture probes it
+func (c *uploadCircuits) release(destination string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if circuit, ok := c.circuits[destination]; ok {
+		circuit.probing = false
+	}
+}
+
+// hold holds back a capture of a config while the circuit of its destination
+// is open, replacing the capture of the same pod and dropping the oldest
+// beyond limit. It reports whether the capture is held.
+func (c *uploadCircuits) hold(destination string, held heldCapture, limit int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	circuit, ok := c.circuits[destination]
+	if !ok || !circuit.open {
+		return false
+	}
+	if circuit.queued == nil {
+		circuit.queued = make(map[string][]heldCapture)
+	}
+	configKey := held.job.ConfigKey
+	captures := slices.DeleteFunc(circuit.queued[configKey], func(queued heldCapture) bool {
+		return queued.job.PodKey == held.job.PodKey
+	})
+	captures = append(captures, held)
+	if len(captures) > limit {
+		captures = captures[len(captures)-limit:]
+	}
+	circuit.queued[configKey] = captures
+	return true
+}
+
+// status returns the state of the circuit of a destination
+func (c *uploadCircuits) status(destination string) circuitStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	circuit, ok := c.circuits[destination]
+	if !ok {
+		return circuitStatus{}
+	}
+	return circuitStatus{
+		open:      circuit.open,
+		failures:  circuit.failures,
+		probeAt:   circuit.probeAt,
+		lastError: circuit.lastError,
+	}
+}
+
+// forget drops the captures of a config held back
+func (c *uploadCircuits) forget(configKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, circuit := range c.circuits {
+		delete(circuit.queued, configKey)
+	}
+}
+
+// circuitDestination returns the destination whose circuit the uploads of a
+// config go through: its bucket, reached with the same identity
+func circuitDestination(config *profilingv1alpha1.ProfilingConfig) string {
+	s3Config := config.Spec.S3Config
+	destination := s3Config.Endpoint + "/" + s3Config.Bucket
+	if webIdentity := s3Config.WebIdentity; webIdentity != nil {
+		destination += fmt.Sprintf("|%s/%s|%s", config.Namespace, webIdentity.ServiceAccountName, webIdentity.RoleARN)
+	}
+	return destination
+}
+
+// admitCapture reports whether an automatic capture may run under the circuit
+// breaker of its config, holding it back if the config queues captures
+func (r *ProfilingConfigReconciler) admitCapture(config *profilingv1alpha1.ProfilingConfig, job CaptureJob) bool {
+	breaker := config.Spec.S3Config.CircuitBreaker
+	if breaker == nil {
+		return true
+	}
+	destination := circuitDestination(config)
+	if r.circuits.admit(destination, time.Now()) {
+		return true
+	}
+	if breaker.Action == profilingv1alpha1.CircuitBreakerQueue {
+		held := heldCapture{job: job, concurrency: config.Spec.MaxConcurrentCaptures}
+		r.circuits.hold(destination, held, withDefault(breaker.MaxQueuedCaptures, defaultCircuitMaxQueued))
+	}
+	return false
+}
+
+// recordUpload feeds the outcome of a capture or probe to the circuit breaker
+// of its config. Opening the circuit records a warning event; closing it
+// records an event and queues the captures held back.
+func (r *ProfilingConfigReconciler) recordUpload(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, captureErr error) {
+	breaker := config.Spec.S3Config.CircuitBreaker
+	if breaker == nil {
+		return
+	}
+	logger := log.FromContext(ctx)
+	destination := circuitDestination(config)
+
+	switch {
+	case captureErr == nil:
+		closed, queued := r.circuits.succeeded(destination)
+		if !closed {
+			return
+		}
+		count := 0
+		for _, captures := range queued {
+			count += len(captures)
+		}
+		logger.Info("Uploads recovered, closing circuit", "bucket", config.Spec.S3Config.Bucket, "queued", count)
+		r.Recorder.Eventf(config, corev1.EventTypeNormal, reasonCircuitClosed,
+			"Uploads to bucket %s recovered, resuming captures (%d queued)", config.Spec.S3Config.Bucket, count)
+		for configKey, captures := range queued {
+			go r.resumeCaptures(r.monitorContext(ctx), configKey, captures)
+		}
+	case isUploadError(captureErr):
+		if r.circuits.failed(destination, breaker, captureErr, time.Now()) {
+			status := r.circuits.status(destination)
+			logger.Info("Uploads failing, opening circuit", "bucket", config.Spec.S3Config.Bucket, "failures", status.failures)
+			r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonCircuitOpen,
+				"Uploads to bucket %s failed %d times in a row, %s until a probe at %s succeeds: %v",
+				config.Spec.S3Config.Bucket, status.failures, circuitActionOf(breaker),
+				status.probeAt.Format(time.RFC3339), captureErr)
+		}
+	default:
+		r.circuits.release(destination)
+	}
+}
+
+// resumeCaptures queues the captures of a config held back while its circuit
+// was open, waiting for the captures of the config in flight to leave room.
+// Captures that still cannot be queued are dropped, a later check picks them
+// up again.
+func (r *ProfilingConfigReconciler) resumeCaptures(ctx context.Context, configKey string, captures []heldCapture) {
+	for _, held := range captures {
+		if r.captureQueue.Enqueue(held.job, held.concurrency) {
+			continue
+		}
+		if err := r.captureQueue.Wait(ctx, configKey); err != nil {
+			return
+		}
+		if !r.captureQueue.Enqueue(held.job, held.concurrency) {
+			log.FromContext(ctx).V(1).Info("Held capture dropped, pod already queued or limit reached", "pod", held.job.PodKey)
+		}
+	}
+}
+
+// probeUploadCircuit probes the destination of a config with an open circuit
+// once its probe is due, with a write access check where the uploader has
+// one, and reports the circuit in the conditions
+func (r *ProfilingConfigReconciler) probeUploadCircuit(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+	if config.Spec.S3Config.CircuitBreaker == nil {
+		return
+	}
+	destination := circuitDestination(config)
+	if r.circuits.claimProbe(destination, time.Now()) {
+		probeCtx, cancel := context.WithTimeout(ctx, storageCheckTimeout)
+		checked, err := r.probeStorage(probeCtx, config, true)
+		cancel()
+		switch {
+		case !checked:
+			// The next capture probes the destination
+			r.circuits.release(destination)
+		case err != nil:
+			r.recordUpload(ctx, config, &uploadError{fmt.Errorf("upload probe failed: %w", err)})
+		default:
+			r.recordUpload(ctx, config, nil)
+		}
+	}
+	r.setCircuitConditions(config)
+}
+
+// setCircuitConditions reports an open circuit of the destination of a config
+// in the upload conditions, and its closing until the next capture does
+func (r *ProfilingConfigReconciler) setCircuitConditions(config *profilingv1alpha1.ProfilingConfig) {
+	breaker := config.Spec.S3Config.CircuitBreaker
+	if breaker == nil {
+		return
+	}
+	status := r.circuits.status(circuitDestination(config))
+	if status.open {
+		message := fmt.Sprintf("Uploads to bucket %s failed %d times in a row, %s until a probe at %s succeeds: %s",
+			config.Spec.S3Config.Bucket, status.failures, circuitActionOf(breaker),
+			status.probeAt.Format(time.RFC3339), status.lastError)
+		setCondition(config, ConditionUploadHealthy, metav1.ConditionFalse, reasonCircuitOpen, message)
+		setCondition(config, ConditionDegraded, metav1.ConditionTrue, reasonCircuitOpen, message)
+		return
+	}
+	if previous := apimeta.FindStatusCondition(config.Status.Conditions, ConditionUploadHealthy); previous != nil && previous.Reason == reasonCircuitOpen {
+		message := fmt.Sprintf("Uploads to bucket %s recovered", config.Spec.S3Config.Bucket)
+		setCondition(config, ConditionUploadHealthy, metav1.ConditionTrue, reasonCircuitClosed, message)
+		setCondition(config, ConditionDegraded, metav1.ConditionFalse, reasonCircuitClosed, message)
+	}
+}
+
+// circuitActionOf describes what happens to the captures of a config while
+// its circuit is open
+func circuitActionOf(breaker *profilingv1alpha1.CircuitBreakerConfig) string {
+	if breaker.Action == profilingv1alpha1.CircuitBreakerQueue {
+		return "captures are queued"
+	}
+	return "captures are paused"
+}
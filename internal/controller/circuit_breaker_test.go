@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestUploadCircuits(t *testing.T) {
+	var circuits uploadCircuits
+	breaker := &profilingv1alpha1.CircuitBreakerConfig{FailureThreshold: 2, ProbeIntervalSeconds: 60, MaxProbeIntervalSeconds: 150}
+	uploadErr := errors.New("connection refused")
+	now := time.Now()
+
+	if circuits.failed("bucket", breaker, uploadErr, now) {
+		t.Fatal("Expected the circuit to stay closed below the threshold")
+	}
+	if !circuits.admit("bucket", now) {
+		t.Fatal("Expected a closed circuit to admit captures")
+	}
+	if !circuits.failed("bucket", breaker, uploadErr, now) {
+		t.Fatal("Expected the circuit to open at the threshold")
+	}
+	if circuits.admit("bucket", now.Add(59*time.Second)) {
+		t.Error("Expected an open circuit to hold captures back until the probe")
+	}
+	if !circuits.admit("other", now) {
+		t.Error("Expected other destinations to admit captures")
+	}
+
+	// A single probe is let through once due
+	probeAt := now.Add(time.Minute)
+	if !circuits.admit("bucket", probeAt) {
+		t.Fatal("Expected the probe to be admitted")
+	}
+	if circuits.admit("bucket", probeAt) || circuits.claimProbe("bucket", probeAt) {
+		t.Error("Expected a single probe at a time")
+	}
+
+	// A failed probe doubles the interval, up to the cap
+	circuits.failed("bucket", breaker, uploadErr, probeAt)
+	if status := circuits.status("bucket"); !status.open || !status.probeAt.Equal(probeAt.Add(2*time.Minute)) {
+		t.Errorf("Expected the next probe two minutes later, got %+v", status)
+	}
+	probeAt = probeAt.Add(2 * time.Minute)
+	if !circuits.claimProbe("bucket", probeAt) {
+		t.Fatal("Expected the probe to be claimed")
+	}
+	circuits.failed("bucket", breaker, uploadErr, probeAt)
+	if status := circuits.status("bucket"); !status.probeAt.Equal(probeAt.Add(150 * time.Second)) {
+		t.Errorf("Expected the interval to be capped, got %+v", status)
+	}
+
+	// A probe telling nothing lets the next one through
+	probeAt = probeAt.Add(150 * time.Second)
+	circuits.claimProbe("bucket", probeAt)
+	circuits.release("bucket")
+	if !circuits.admit("bucket", probeAt) {
+		t.Error("Expected a released probe to be claimed again")
+	}
+
+	if closed, _ := circuits.succeeded("bucket"); !closed {
+		t.Error("Expected a successful upload to close the circuit")
+	}
+	if status := circuits.status("bucket"); status.open || status.failures != 0 {
+		t.Errorf("Expected the circuit to be reset, got %+v", status)
+	}
+}
+
+func TestUploadCircuits_Hold(t *testing.T) {
+	var circuits uploadCircuits
+	breaker := &profilingv1alpha1.CircuitBreakerConfig{FailureThreshold: 1}
+	held := func(configKey, podKey string) heldCapture {
+		return heldCapture{job: CaptureJob{ConfigKey: configKey, PodKey: podKey}}
+	}
+
+	if circuits.hold("bucket", held("default/config", "default/a"), 2) {
+		t.Fatal("Expected a closed circuit not to hold captures")
+	}
+	circuits.failed("bucket", breaker, errors.New("connection refused"), time.Now())
+	for _, podKey := range []string{"default/a", "default/b", "default/a", "default/c"} {
+		circuits.hold("bucket", held("default/config", podKey), 2)
+	}
+	circuits.hold("bucket", held("default/other", "default/d"), 2)
+	circuits.forget("default/other")
+
+	_, queued := circuits.succeeded("bucket")
+	if len(queued) != 1 {
+		t.Fatalf("Expected the captures of one config, got %+v", queued)
+	}
+	captures := queued["default/config"]
+	if len(captures) != 2 || captures[0].job.PodKey != "default/a" || captures[1].job.PodKey != "default/c" {
+		t.Errorf("Expected the latest capture of each pod, oldest dropped, got %+v", captures)
+	}
+}
+
+func TestCircuitDestination(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	other := createTestProfilingConfig("other-config", "other")
+	if circuitDestination(config) != circuitDestination(other) {
+		t.Error("Expected configs uploading to the same bucket to share a circuit")
+	}
+
+	other.Spec.S3Config.WebIdentity = &profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "profiler"}
+	if circuitDestination(config) == circuitDestination(other) {
+		t.Error("Expected uploads with another identity to have their own circuit")
+	}
+}
+
+func TestCircuitBreaker_OpenAndRecover(t *testing.T) {
+	reconciler, _, profileUploader := setupPipelineReconciler()
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config.CircuitBreaker = &profilingv1alpha1.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		Action:           profilingv1alpha1.CircuitBreakerQueue,
+	}
+	pod := createTestPod("test-pod", "default", true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = reconciler.captureQueue.Start(ctx) }()
+
+	profileUploader.Err = errors.New("connection refused")
+	for range 2 {
+		if err := reconciler.captureAndUpload(ctx, pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"}); err == nil {
+			t.Fatal("Expected the upload to fail")
+		}
+	}
+
+	// Captures are held back while the circuit is open
+	resumed := make(chan struct{}, 1)
+	job := CaptureJob{ConfigKey: configKeyOf(config), PodKey: "default/test-pod", Run: func() { resumed <- struct{}{} }}
+	if reconciler.admitCapture(config, job) {
+		t.Fatal("Expected the capture to be held back")
+	}
+	reconciler.setCircuitConditions(config)
+	condition := apimeta.FindStatusCondition(config.Status.Conditions, ConditionUploadHealthy)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != reasonCircuitOpen {
+		t.Errorf("Expected uploads to be reported unhealthy, got %+v", condition)
+	}
+
+	// The probe passes once the bucket is back, closing the circuit
+	profileUploader.Err = nil
+	reconciler.circuits.circuits[circuitDestination(config)].probeAt = time.Now()
+	reconciler.probeUploadCircuit(ctx, config)
+	if reconciler.circuits.status(circuitDestination(config)).open {
+		t.Fatal("Expected the circuit to close")
+	}
+	condition = apimeta.FindStatusCondition(config.Status.Conditions, ConditionUploadHealthy)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonCircuitClosed {
+		t.Errorf("Expected uploads to be reported healthy, got %+v", condition)
+	}
+	select {
+	case <-resumed:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the held capture to run")
+	}
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+const (
+	// ClusterAnnotation is set in memory on pods listed from a remote cluster,
+	// so they are keyed and recorded apart from pods of the operator's cluster
+	ClusterAnnotation = "bolometer.io/cluster"
+
+	// defaultKubeconfigKey is the Secret key read when a config names none
+	defaultKubeconfigKey = "kubeconfig"
+)
+
+// targetCluster holds the clients used to profile the pods of one cluster
+type targetCluster struct {
+	// name is empty for the operator's own cluster
+	name string
+
+	// version is the resourceVersion of the kubeconfig Secret the clients were
+	// built from, empty for the operator's own cluster
+	version string
+
+	reader    client.Reader
+	clientset kubernetes.Interface
+	metrics   *metrics.Collector
+	profiler  profiler.Interface
+	executor  profiler.Executor
+}
+
+// clusterSecretKey identifies the clients built from a kubeconfig Secret
+type clusterSecretKey struct {
+	namespace string
+	secret    string
+	key       string
+	name      string
+}
+
+// clusterRegistry caches the clients of remote clusters. The zero value is
+// ready to use.
+type clusterRegistry struct {
+	mu sync.Mutex
+
+	// bySecret holds the clients built from each kubeconfig Secret
+	bySecret map[clusterSecretKey]*targetCluster
+
+	// byConfig holds the clients each config profiles through
+	byConfig map[string]*targetCluster
+}
+
+// lookup returns the clients built from a Secret, nil if none
+func (c *clusterRegistry) lookup(key clusterSecretKey) *targetCluster {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bySecret[key]
+}
+
+// use records the clients a config profiles through
+func (c *clusterRegistry) use(configKey string, key clusterSecretKey, cluster *targetCluster) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.bySecret == nil {
+		c.bySecret = make(map[clusterSecretKey]*targetCluster)
+		c.byConfig = make(map[string]*targetCluster)
+	}
+	c.bySecret[key] = cluster
+	c.byConfig[configKey] = cluster
+	c.pruneLocked()
+}
+
+// forConfig returns the clients a config profiles through, nil if none
+func (c *clusterRegistry) forConfig(configKey string) *targetCluster {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byConfig[configKey]
+}
+
+// forget drops the clients of a config, and those no other config uses
+func (c *clusterRegistry) forget(configKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.byConfig[configKey]; !ok {
+		return
+	}
+	delete(c.byConfig, configKey)
+	c.pruneLocked()
+}
+
+// pruneLocked drops the clients no config uses (must be called with lock held)
+func (c *clusterRegistry) pruneLocked() {
+	inUse := make(map[*targetCluster]struct{}, len(c.byConfig))
+	for _, cluster := range c.byConfig {
+		inUse[cluster] = struct{}{}
+	}
+	for key, cluster := range c.bySecret {
+		if _, ok := inUse[cluster]; !ok {
+			delete(c.bySecret, key)
+		}
+	}
+}
+
+// localCluster returns the clients of the operator's own cluster
+func (r *ProfilingConfigReconciler) localCluster() *targetCluster {
+	return &targetCluster{
+		reader:    r.podWatcher.reader,
+		clientset: r.Clientset,
+		metrics:   r.metricsCollector,
+		profiler:  r.profiler,
+		executor:  r.executor,
+	}
+}
+
+// resolveCluster returns the clients of the cluster a config profiles. Clients
+// of a remote cluster are built from its kubeconfig Secret and rebuilt when
+// the Secret changes.
+func (r *ProfilingConfigReconciler) resolveCluster(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) (*targetCluster, error) {
+	configKey := configKeyOf(config)
+	target := config.Spec.Cluster
+	if target == nil {
+		r.clusters.forget(configKey)
+		return r.localCluster(), nil
+	}
+
+	ref := target.KubeconfigSecretRef
+	key := clusterSecretKey{
+		namespace: config.Namespace,
+		secret:    ref.Name,
+		key:       withDefault(ref.Key, defaultKubeconfigKey),
+		name:      target.Name,
+	}
+
+	// Secrets are read directly rather than cached, so the operator does not
+	// watch every Secret in the cluster
+	secret, err := r.Clientset.CoreV1().Secrets(key.namespace).Get(ctx, key.secret, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig secret %s: %w", key.secret, err)
+	}
+
+	if cached := r.clusters.lookup(key); cached != nil && cached.version == secret.ResourceVersion {
+		r.clusters.use(configKey, key, cached)
+		return cached, nil
+	}
+
+	kubeconfig, ok := secret.Data[key.key]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig secret %s has no key %s", key.secret, key.key)
+	}
+
+	cluster, err := r.newRemoteCluster(target.Name, kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cluster %s: %w", target.Name, err)
+	}
+	cluster.version = secret.ResourceVersion
+
+	r.clusters.use(configKey, key, cluster)
+	return cluster, nil
+}
+
+// newRemoteCluster builds the clients of a remote cluster from its kubeconfig.
+// Port-forwards to the cluster count against the operator-wide limit.
+func (r *ProfilingConfigReconciler) newRemoteCluster(name string, kubeconfig []byte) (*targetCluster, error) {
+	clusterProfiler, ok := r.profiler.(profiler.ClusterProfiler)
+	if !ok {
+		return nil, fmt.Errorf("the profiler cannot capture pods of remote clusters")
+	}
+
+	restConfig, err := remoteRESTConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	metricsClient, err := metricsv.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remote pods are listed on every reconcile instead of being watched
+	reader, err := client.New(restConfig, client.Options{Scheme: r.Scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	collector := metrics.NewCollector(metricsClient)
+	collector.RegisterSource(metrics.SourceKubelet, metrics.NewKubeletSource(clientset))
+
+	return &targetCluster{
+		name:      name,
+		reader:    reader,
+		clientset: clientset,
+		metrics:   collector,
+		profiler:  clusterProfiler.ForCluster(clientset, restConfig),
+		executor:  profiler.NewExecutor(clientset, restConfig),
+	}, nil
+}
+
+// remoteRESTConfig builds the client config of a remote cluster from a
+// kubeconfig read from a Secret of the config's namespace. Exec and
+// auth-provider plugins and credentials read from files are rejected: they
+// would run binaries or read files, such as the operator's own service account
+// token, inside the operator pod.
+func remoteRESTConfig(kubeconfig []byte) (*rest.Config, error) {
+	apiConfig, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, authInfo := range apiConfig.AuthInfos {
+		switch {
+		case authInfo.Exec != nil:
+			return nil, fmt.Errorf("user %s: exec plugins are not supported", name)
+		case authInfo.AuthProvider != nil:
+			return nil, fmt.Errorf("user %s: auth providers are not supported", name)
+		case authInfo.TokenFile != "":
+			return nil, fmt.Errorf("user %s: tokenFile is not supported, set token", name)
+		case authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+			return nil, fmt.Errorf("user %s: client certificate files are not supported, set client-certificate-data and client-key-data", name)
+		}
+	}
+	for name, cluster := range apiConfig.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("cluster %s: certificate-authority files are not supported, set certificate-authority-data", name)
+		}
+	}
+
+	restConfig, err := clientcmd.NewDefaultClientConfig(*apiConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing outside the Secret may be reached through the config
+	restConfig.ExecProvider, restConfig.AuthProvider = nil, nil
+	restConfig.BearerTokenFile = ""
+	restConfig.CertFile, restConfig.KeyFile, restConfig.CAFile = "", "", ""
+	return restConfig, nil
+}
+
+// clusterOf returns the clients of the cluster a config's monitors profile
+func (r *ProfilingConfigReconciler) clusterOf(config *profilingv1alpha1.ProfilingConfig) (*targetCluster, error) {
+	if config.Spec.Cluster == nil {
+		return r.localCluster(), nil
+	}
+
+	cluster := r.clusters.forConfig(configKeyOf(config))
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %s is not connected", config.Spec.Cluster.Name)
+	}
+	return cluster, nil
+}
+
+// listClusterPods lists the pods of a cluster matching a config, marking the
+// pods of a remote cluster with its name
+func (r *ProfilingConfigReconciler) listClusterPods(ctx context.Context, cluster *targetCluster, config *profilingv1alpha1.ProfilingConfig) ([]*corev1.Pod, error) {
+	pods, err := r.podWatcher.ListMatchingPodsFrom(ctx, cluster.reader, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster.name != "" {
+		for _, pod := range pods {
+			metav1.SetMetaDataAnnotation(&pod.ObjectMeta, ClusterAnnotation, cluster.name)
+		}
+	}
+	return pods, nil
+}
+
+// podCluster returns the remote cluster a pod was listed from, empty for the
+// operator's own cluster
+func podCluster(pod *corev1.Pod) string {
+	return pod.Annotations[ClusterAnnotation]
+}
+
+// podKey returns the key of a pod, prefixed with its cluster for remote pods
+func podKey(cluster, namespace, name string) string {
+	if cluster == "" {
+		return namespace + "/" + name
+	}
+	return cluster + "/" + namespace + "/" + name
+}
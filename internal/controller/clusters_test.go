@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com
+contexts:
+- name: east
+  context:
+    cluster: east
+    user: bolometer
+current-context: east
+users:
+- name: bolometer
+  user:
+    token: secret-token
+`
+
+func createTestKubeconfigSecret(name, namespace, resourceVersion string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			ResourceVersion: resourceVersion,
+		},
+		Data: map[string][]byte{
+			defaultKubeconfigKey: []byte(testKubeconfig),
+		},
+	}
+}
+
+func createTestRemoteConfig(name, namespace string) *profilingv1alpha1.ProfilingConfig {
+	config := createTestProfilingConfig(name, namespace)
+	config.Spec.Cluster = &profilingv1alpha1.ClusterTarget{
+		Name:                "east",
+		KubeconfigSecretRef: profilingv1alpha1.SecretKeyRef{Name: "east-kubeconfig"},
+	}
+	return config
+}
+
+func TestResolveCluster_Local(t *testing.T) {
+	reconciler := setupTestReconciler()
+	config := createTestProfilingConfig("test-config", "default")
+
+	cluster, err := reconciler.resolveCluster(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cluster.name != "" || cluster.version != "" {
+		t.Errorf("Expected the operator's own cluster, got %q", cluster.name)
+	}
+}
+
+func TestResolveCluster_Remote(t *testing.T) {
+	reconciler := setupTestReconciler()
+	reconciler.profiler = profiler.NewProfiler(nil, nil)
+	ctx := context.Background()
+	config := createTestRemoteConfig("test-config", "default")
+
+	if _, err := reconciler.resolveCluster(ctx, config); err == nil {
+		t.Fatal("Expected error without the kubeconfig secret")
+	}
+	if _, err := reconciler.clusterOf(config); err == nil {
+		t.Error("Expected an unresolved cluster not to fall back to the local one")
+	}
+
+	secret := createTestKubeconfigSecret("east-kubeconfig", "default", "1")
+	secrets := reconciler.Clientset.CoreV1().Secrets("default")
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	cluster, err := reconciler.resolveCluster(ctx, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cluster.name != "east" || cluster.version != "1" {
+		t.Errorf("Expected cluster east at version 1, got %q at %q", cluster.name, cluster.version)
+	}
+
+	// Unchanged credentials reuse the clients
+	again, err := reconciler.resolveCluster(ctx, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again != cluster {
+		t.Error("Expected the clients to be reused while the secret is unchanged")
+	}
+	if current, err := reconciler.clusterOf(config); err != nil || current != cluster {
+		t.Errorf("Expected the config's monitors to use the resolved clients, got %v", err)
+	}
+
+	// Rotated credentials rebuild them
+	secret.ResourceVersion = "2"
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update secret: %v", err)
+	}
+	rotated, err := reconciler.resolveCluster(ctx, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rotated == cluster || rotated.version != "2" {
+		t.Error("Expected the clients to be rebuilt for the rotated secret")
+	}
+
+	reconciler.clusters.forget(configKeyOf(config))
+	if len(reconciler.clusters.bySecret) != 0 {
+		t.Error("Expected unused clients to be dropped")
+	}
+}
+
+func TestResolveCluster_MissingKey(t *testing.T) {
+	reconciler := setupTestReconciler()
+	reconciler.profiler = profiler.NewProfiler(nil, nil)
+	config := createTestRemoteConfig("test-config", "default")
+	config.Spec.Cluster.KubeconfigSecretRef.Key = "config"
+
+	secret := createTestKubeconfigSecret("east-kubeconfig", "default", "1")
+	if _, err := reconciler.Clientset.CoreV1().Secrets("default").Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if _, err := reconciler.resolveCluster(context.Background(), config); err == nil {
+		t.Error("Expected error for a key missing from the secret")
+	}
+}
+
+func TestResolveCluster_ProfilerWithoutClusters(t *testing.T) {
+	reconciler := setupTestReconciler()
+	reconciler.profiler = &profiler.Fake{}
+	config := createTestRemoteConfig("test-config", "default")
+
+	secret := createTestKubeconfigSecret("east-kubeconfig", "default", "1")
+	if _, err := reconciler.Clientset.CoreV1().Secrets("default").Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if _, err := reconciler.resolveCluster(context.Background(), config); err == nil || !strings.Contains(err.Error(), "remote clusters") {
+		t.Errorf("Expected error for a profiler without remote clusters, got %v", err)
+	}
+}
+
+func TestRemoteRESTConfig(t *testing.T) {
+	if _, err := remoteRESTConfig([]byte(testKubeconfig)); err != nil {
+		t.Fatalf("Expected the test kubeconfig to be accepted, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		user string
+	}{
+		{
+			name: "exec plugin",
+			user: "exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: /bin/sh",
+		},
+		{
+			name: "auth provider",
+			user: "auth-provider:\n      name: oidc",
+		},
+		{
+			name: "token file",
+			user: "tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token",
+		},
+		{
+			name: "client certificate file",
+			user: "client-certificate: /etc/ssl/client.crt\n    client-key: /etc/ssl/client.key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeconfig := strings.Replace(testKubeconfig, "token: secret-token", tt.user, 1)
+			if _, err := remoteRESTConfig([]byte(kubeconfig)); err == nil {
+				t.Errorf("Expected a kubeconfig with %s to be rejected", tt.name)
+			}
+		})
+	}
+
+	kubeconfig := strings.Replace(testKubeconfig, "server: https://east.example.com",
+		"server: https://east.example.com\n    certificate-authority: /etc/ssl/ca.crt", 1)
+	if _, err := remoteRESTConfig([]byte(kubeconfig)); err == nil {
+		t.Error("Expected a kubeconfig with a certificate-authority file to be rejected")
+	}
+}
+
+func TestPodKey_RemoteCluster(t *testing.T) {
+	watcher := NewPodWatcher(nil)
+	local := createTestPod("test-pod", "default", true)
+	remote := createTestPod("test-pod", "default", true)
+	metav1.SetMetaDataAnnotation(&remote.ObjectMeta, ClusterAnnotation, "east")
+
+	if key := watcher.getPodKey(local); key != "default/test-pod" {
+		t.Errorf("Expected default/test-pod, got %s", key)
+	}
+	if key := watcher.getPodKey(remote); key != "east/default/test-pod" {
+		t.Errorf("Expected east/default/test-pod, got %s", key)
+	}
+
+	// Pods of the same name in two clusters are tracked apart
+	watcher.TrackPod(local, createTestProfilingConfig("local", "default"))
+	watcher.TrackPod(remote, createTestRemoteConfig("remote", "default"))
+	if count := watcher.GetActivePodCount(); count != 2 {
+		t.Errorf("Expected 2 tracked pods, got %d", count)
+	}
+}
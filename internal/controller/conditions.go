@@ -0,0 +1,153 @@
+package controller
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// Condition types maintained in ProfilingConfig status
+const (
+	// ConditionReady reports whether the config is valid and being monitored
+	ConditionReady = "Ready"
+
+	// ConditionTargetsFound reports whether any pod matches the config
+	ConditionTargetsFound = "TargetsFound"
+
+	// ConditionUploadHealthy reports whether the last capture reached S3
+	ConditionUploadHealthy = "UploadHealthy"
+
+	// ConditionDegraded reports whether the last capture failed
+	ConditionDegraded = "Degraded"
+
+	// ConditionPodConflict reports pods also selected by other configs
+	ConditionPodConflict = "PodConflict"
+
+	// ConditionInvalidSpec reports whether the spec failed validation
+	ConditionInvalidSpec = "InvalidSpec"
+
+	// ConditionProbableLeak reports whether the last captures of a pod showed
+	// steadily growing allocation sites or goroutine stacks
+	ConditionProbableLeak = "ProbableLeak"
+
+	// ConditionPprofNotEnabled reports pods that seem not to serve pprof at all
+	ConditionPprofNotEnabled = "PprofNotEnabled"
+
+	// ConditionStorageReady reports whether the last access check of the
+	// bucket passed, with an access check configured only
+	ConditionStorageReady = "StorageReady"
+
+	// ConditionStorageBudgetExceeded reports whether the config used up its
+	// storage budget, with a budget configured only
+	ConditionStorageBudgetExceeded = "StorageBudgetExceeded"
+)
+
+// Condition reasons
+const (
+	reasonMonitoring           = "Monitoring"
+	reasonInvalidSpec          = "InvalidSpec"
+	reasonValidationFailed     = "ValidationFailed"
+	reasonValidSpec            = "ValidSpec"
+	reasonPodsFound            = "PodsFound"
+	reasonNoMatchingPods       = "NoMatchingPods"
+	reasonNoUploads            = "NoUploadsYet"
+	reasonUploadSucceeded      = "UploadSucceeded"
+	reasonUploadFailed         = "UploadFailed"
+	reasonCircuitOpen          = "CircuitOpen"
+	reasonCircuitClosed        = "CircuitClosed"
+	reasonStorageAccessible    = "StorageAccessible"
+	reasonStorageUnavailable   = "StorageUnavailable"
+	reasonLifecycleFailed      = "LifecycleFailed"
+	reasonBudgetExceeded       = "StorageBudgetExceeded"
+	reasonWithinBudget         = "WithinBudget"
+	reasonCaptureFailed        = "CaptureFailed"
+	reasonCaptureSucceeded     = "CaptureSucceeded"
+	reasonOverlappingSelectors = "OverlappingSelectors"
+	reasonNoConflicts          = "NoConflicts"
+	reasonPodQuarantined       = "PodQuarantined"
+	reasonPprofUnreachable     = "PprofUnreachable"
+	reasonPprofNotEnabled      = "PprofNotEnabled"
+	reasonPprofServed          = "PprofServed"
+	reasonProfileRegression    = "ProfileRegression"
+	reasonProbableLeak         = "ProbableLeak"
+	reasonHeapGrowth           = "HeapGrowth"
+	reasonGoroutineGrowth      = "GoroutineGrowth"
+	reasonNoLeaks              = "NoLeaksDetected"
+)
+
+// uploadError marks a capture failure that happened while uploading to S3
+type uploadError struct {
+	err error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+
+func (e *uploadError) Unwrap() error { return e.err }
+
+// isUploadError reports whether err happened while uploading to S3
+func isUploadError(err error) bool {
+	var target *uploadError
+	return stderrors.As(err, &target)
+}
+
+// setCondition sets a condition on the config status. The transition time only
+// changes when the condition status does.
+func setCondition(config *profilingv1alpha1.ProfilingConfig, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&config.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: config.Generation,
+	})
+}
+
+// setMonitoringConditions records a successful reconcile: the config is Ready and
+// TargetsFound reflects the number of matching pods. Upload conditions start out
+// unknown until the first capture.
+func setMonitoringConditions(config *profilingv1alpha1.ProfilingConfig, matchingPods int) {
+	setCondition(config, ConditionInvalidSpec, metav1.ConditionFalse, reasonValidSpec, "Spec passed validation")
+	setCondition(config, ConditionReady, metav1.ConditionTrue, reasonMonitoring,
+		fmt.Sprintf("Monitoring %d pods", config.Status.ActivePods))
+
+	if matchingPods > 0 {
+		setCondition(config, ConditionTargetsFound, metav1.ConditionTrue, reasonPodsFound,
+			fmt.Sprintf("%d pods match the selector", matchingPods))
+	} else {
+		setCondition(config, ConditionTargetsFound, metav1.ConditionFalse, reasonNoMatchingPods,
+			"No running pods with profiling enabled match the selector")
+	}
+
+	if meta.FindStatusCondition(config.Status.Conditions, ConditionUploadHealthy) == nil {
+		setCondition(config, ConditionUploadHealthy, metav1.ConditionUnknown, reasonNoUploads, "No profiles uploaded yet")
+	}
+	if meta.FindStatusCondition(config.Status.Conditions, ConditionDegraded) == nil {
+		setCondition(config, ConditionDegraded, metav1.ConditionFalse, reasonNoUploads, "No profiles captured yet")
+	}
+}
+
+// setCaptureConditions records the outcome of a capture. A failed capture marks
+// the config Degraded; only failures while uploading mark uploads unhealthy.
+func setCaptureConditions(config *profilingv1alpha1.ProfilingConfig, captureErr error) {
+	switch {
+	case captureErr == nil:
+		setCondition(config, ConditionUploadHealthy, metav1.ConditionTrue, reasonUploadSucceeded, "Last profiles were uploaded")
+		setCondition(config, ConditionDegraded, metav1.ConditionFalse, reasonCaptureSucceeded, "Last capture succeeded")
+	case isUploadError(captureErr):
+		setCondition(config, ConditionUploadHealthy, metav1.ConditionFalse, reasonUploadFailed, captureErr.Error())
+		setCondition(config, ConditionDegraded, metav1.ConditionTrue, reasonUploadFailed, captureErr.Error())
+	default:
+		setCondition(config, ConditionDegraded, metav1.ConditionTrue, reasonCaptureFailed, captureErr.Error())
+	}
+}
+
+// setLastError records an error in the config status
+func setLastError(config *profilingv1alpha1.ProfilingConfig, err error) {
+	now := metav1.Now()
+	config.Status.LastErrorMessage = err.Error()
+	config.Status.LastErrorTime = &now
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetMonitoringConditions(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Generation = 3
+
+	setMonitoringConditions(config, 0)
+
+	expected := map[string]metav1.ConditionStatus{
+		ConditionReady:         metav1.ConditionTrue,
+		ConditionTargetsFound:  metav1.ConditionFalse,
+		ConditionUploadHealthy: metav1.ConditionUnknown,
+		ConditionDegraded:      metav1.ConditionFalse,
+	}
+	for conditionType, status := range expected {
+		condition := meta.FindStatusCondition(config.Status.Conditions, conditionType)
+		if condition == nil {
+			t.Errorf("Expected condition %s to be set", conditionType)
+			continue
+		}
+		if condition.Status != status {
+			t.Errorf("Expected %s=%s, got %s", conditionType, status, condition.Status)
+		}
+		if condition.ObservedGeneration != 3 {
+			t.Errorf("Expected %s to observe generation 3, got %d", conditionType, condition.ObservedGeneration)
+		}
+	}
+
+	// Upload conditions set by captures are left alone
+	setCaptureConditions(config, nil)
+	setMonitoringConditions(config, 2)
+
+	if !meta.IsStatusConditionTrue(config.Status.Conditions, ConditionTargetsFound) {
+		t.Error("Expected TargetsFound to be true once pods match")
+	}
+	if !meta.IsStatusConditionTrue(config.Status.Conditions, ConditionUploadHealthy) {
+		t.Error("Expected UploadHealthy to keep the capture result")
+	}
+}
+
+func TestSetCaptureConditions(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		uploadHealthy  metav1.ConditionStatus
+		degraded       metav1.ConditionStatus
+		degradedReason string
+	}{
+		{
+			name:           "success",
+			uploadHealthy:  metav1.ConditionTrue,
+			degraded:       metav1.ConditionFalse,
+			degradedReason: reasonCaptureSucceeded,
+		},
+		{
+			name:           "upload failure",
+			err:            &uploadError{fmt.Errorf("failed to upload profiles: access denied")},
+			uploadHealthy:  metav1.ConditionFalse,
+			degraded:       metav1.ConditionTrue,
+			degradedReason: reasonUploadFailed,
+		},
+		{
+			name:           "capture failure",
+			err:            fmt.Errorf("failed to capture profiles: connection refused"),
+			uploadHealthy:  metav1.ConditionUnknown,
+			degraded:       metav1.ConditionTrue,
+			degradedReason: reasonCaptureFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestProfilingConfig("test-config", "default")
+			setMonitoringConditions(config, 1)
+
+			setCaptureConditions(config, tt.err)
+
+			if upload := meta.FindStatusCondition(config.Status.Conditions, ConditionUploadHealthy); upload.Status != tt.uploadHealthy {
+				t.Errorf("Expected UploadHealthy=%s, got %s", tt.uploadHealthy, upload.Status)
+			}
+			degraded := meta.FindStatusCondition(config.Status.Conditions, ConditionDegraded)
+			if degraded.Status != tt.degraded || degraded.Reason != tt.degradedReason {
+				t.Errorf("Expected Degraded=%s (%s), got %s (%s)", tt.degraded, tt.degradedReason, degraded.Status, degraded.Reason)
+			}
+		})
+	}
+}
+
+func TestIsUploadError(t *testing.T) {
+	wrapped := fmt.Errorf("capture failed: %w", &uploadError{fmt.Errorf("access denied")})
+	if !isUploadError(wrapped) {
+		t.Error("Expected wrapped upload error to be detected")
+	}
+	if isUploadError(fmt.Errorf("connection refused")) {
+		t.Error("Expected plain error not to be an upload error")
+	}
+}
@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// DefaultConfigLeaseDuration is how long a replica holds the Lease of a config
+// without renewing it before another replica can take it over
+const DefaultConfigLeaseDuration = 15 * time.Second
+
+// configLeasePrefix prefixes the names of the Leases of configs
+const configLeasePrefix = "bolometer-config-"
+
+// configLeaseReleaseTimeout bounds the release of the held Leases on shutdown
+const configLeaseReleaseTimeout = 5 * time.Second
+
+// heldLease is a config Lease held by this replica
+type heldLease struct {
+	// config is the config the Lease is named after, in the namespace of the Lease
+	config types.NamespacedName
+
+	// renewed is when the Lease was last renewed
+	renewed time.Time
+}
+
+// configLeases spreads the configs across the replicas of the operator. The
+// replica holding the Lease of a config monitors it, renewing the Lease while
+// it runs; the other replicas take over Leases left to expire.
+type configLeases struct {
+	clientset kubernetes.Interface
+	scheme    *runtime.Scheme
+	identity  string
+	duration  time.Duration
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+
+	mu   sync.Mutex
+	held map[string]*heldLease
+}
+
+// newConfigLeases creates the config Leases of a replica, nil if identity is
+// empty and every replica monitors every config
+func newConfigLeases(clientset kubernetes.Interface, scheme *runtime.Scheme, identity string, duration time.Duration) *configLeases {
+	if identity == "" {
+		return nil
+	}
+	if duration <= 0 {
+		duration = DefaultConfigLeaseDuration
+	}
+	return &configLeases{
+		clientset: clientset,
+		scheme:    scheme,
+		identity:  identity,
+		duration:  duration,
+		now:       time.Now,
+		held:      make(map[string]*heldLease),
+	}
+}
+
+// configLeaseName returns the name of the Lease of a config
+func configLeaseName(configName string) string {
+	return configLeasePrefix + configName
+}
+
+// acquire takes or renews the Lease of a config, reporting whether this
+// replica holds it. When another replica holds it, acquire returns how long
+// until the Lease expires if not renewed.
+func (l *configLeases) acquire(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) (bool, time.Duration, error) {
+	key := configKeyOf(config)
+	now := l.now()
+
+	// Leases renewed recently cannot have been taken over
+	l.mu.Lock()
+	held, ok := l.held[key]
+	l.mu.Unlock()
+	if ok && now.Sub(held.renewed) < l.duration/2 {
+		return true, 0, nil
+	}
+
+	leases := l.clientset.CoordinationV1().Leases(config.Namespace)
+	lease, err := leases.Get(ctx, configLeaseName(config.Name), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: configLeaseName(config.Name), Namespace: config.Namespace},
+		}
+		// The Lease goes away with its config
+		if err := controllerutil.SetControllerReference(config, lease, l.scheme); err != nil {
+			return false, 0, err
+		}
+		l.claim(lease, now)
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return false, 0, err
+		}
+		l.hold(key, config, now)
+		return true, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	if holder := leaseHolder(lease); holder != "" && holder != l.identity {
+		if expiry := leaseExpiry(lease); now.Before(expiry) {
+			l.forget(key)
+			return false, expiry.Sub(now), nil
+		}
+		log.FromContext(ctx).Info("Taking over expired config Lease", "holder", holder)
+	}
+
+	l.claim(lease, now)
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		// A conflict is another replica taking the Lease first; the retry reads it
+		return false, 0, err
+	}
+	l.hold(key, config, now)
+	return true, 0, nil
+}
+
+// claim sets this replica as the holder of a Lease renewed at now, counting a
+// transition when the Lease changes hands
+func (l *configLeases) claim(lease *coordinationv1.Lease, now time.Time) {
+	if leaseHolder(lease) != l.identity {
+		transitions := int32(0)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		identity := l.identity
+		acquired := metav1.NewMicroTime(now)
+		lease.Spec.HolderIdentity = &identity
+		lease.Spec.AcquireTime = &acquired
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	seconds := int32(l.duration / time.Second)
+	renewed := metav1.NewMicroTime(now)
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &renewed
+}
+
+// renew renews the held Leases, returning the keys of the configs whose Lease
+// was lost: taken over by another replica, deleted, or not renewed within its
+// duration
+func (l *configLeases) renew(ctx context.Context) []string {
+	logger := log.FromContext(ctx)
+
+	l.mu.Lock()
+	held := make(map[string]heldLease, len(l.held))
+	for key, lease := range l.held {
+		held[key] = *lease
+	}
+	l.mu.Unlock()
+
+	var lost []string
+	for key, lease := range held {
+		now := l.now()
+		leases := l.clientset.CoordinationV1().Leases(lease.config.Namespace)
+		current, err := leases.Get(ctx, configLeaseName(lease.config.Name), metav1.GetOptions{})
+		if err == nil && leaseHolder(current) == l.identity {
+			l.claim(current, now)
+			_, err = leases.Update(ctx, current, metav1.UpdateOptions{})
+			if err == nil {
+				l.renewed(key, now)
+				continue
+			}
+		}
+		if err == nil || errors.IsNotFound(err) || now.Sub(lease.renewed) >= l.duration {
+			l.forget(key)
+			lost = append(lost, key)
+			continue
+		}
+		// Still held until it expires; the next renewal retries
+		logger.Error(err, "Failed to renew config Lease", "config", key)
+	}
+	return lost
+}
+
+// release gives up the held Leases so that other replicas take the configs
+// over without waiting for the Leases to expire
+func (l *configLeases) release(ctx context.Context) {
+	logger := log.FromContext(ctx)
+
+	l.mu.Lock()
+	held := l.held
+	l.held = make(map[string]*heldLease)
+	l.mu.Unlock()
+
+	for key, lease := range held {
+		leases := l.clientset.CoordinationV1().Leases(lease.config.Namespace)
+		current, err := leases.Get(ctx, configLeaseName(lease.config.Name), metav1.GetOptions{})
+		if err != nil || leaseHolder(current) != l.identity {
+			continue
+		}
+		current.Spec.HolderIdentity = nil
+		if _, err := leases.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "Failed to release config Lease", "config", key)
+		}
+	}
+}
+
+// hold records the Lease of a config as held, renewed at now
+func (l *configLeases) hold(configKey string, config *profilingv1alpha1.ProfilingConfig, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held[configKey] = &heldLease{
+		config:  types.NamespacedName{Namespace: config.Namespace, Name: config.Name},
+		renewed: now,
+	}
+}
+
+// renewed records the renewal of a held Lease
+func (l *configLeases) renewed(configKey string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lease, ok := l.held[configKey]; ok {
+		lease.renewed = now
+	}
+}
+
+// forget drops the Lease of a config from the held Leases
+func (l *configLeases) forget(configKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, configKey)
+}
+
+// leaseHolder returns the holder of a Lease, empty if released
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// leaseExpiry returns when a Lease expires if not renewed
+func leaseExpiry(lease *coordinationv1.Lease) time.Time {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+}
+
+// renewConfigLeases renews the config Leases of this replica until ctx is
+// cancelled, stopping the monitoring of the configs whose Lease was lost, then
+// releases the Leases for the other replicas to take over
+func (r *ProfilingConfigReconciler) renewConfigLeases(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	ticker := time.NewTicker(r.leases.duration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), configLeaseReleaseTimeout)
+			defer cancel()
+			// Stop monitoring before the configs are up for grabs
+			r.monitors.StopAll()
+			r.scheduler.UnscheduleAll()
+			r.leases.release(releaseCtx)
+			return nil
+		case <-ticker.C:
+			for _, configKey := range r.leases.renew(ctx) {
+				logger.Info("Lost config Lease, stopping monitoring", "config", configKey)
+				r.disown(configKey)
+			}
+		}
+	}
+}
+
+// disown stops monitoring a config whose Lease is held by another replica
+func (r *ProfilingConfigReconciler) disown(configKey string) {
+	r.stopMonitoring(configKey)
+	r.pruneTrackedPods(configKey, nil)
+}
+
+// everyReplica runs a runnable on every replica instead of the elected leader
+type everyReplica struct {
+	manager.Runnable
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (everyReplica) NeedLeaderElection() bool {
+	return false
+}
+
+// sharded returns a runnable of the reconciler as config Leases run it: on
+// every replica, each serving the configs whose Lease it holds, while the
+// operator-wide runnables stay with the elected leader. Without config Leases
+// it runs on the leader only.
+func (r *ProfilingConfigReconciler) sharded(runnable manager.Runnable) manager.Runnable {
+	if r.leases == nil {
+		return runnable
+	}
+	return everyReplica{runnable}
+}
+
+// controllerOptions returns the options of the reconciler's controllers, run
+// on every replica with config Leases
+func (r *ProfilingConfigReconciler) controllerOptions() controller.Options {
+	var options controller.Options
+	if r.leases != nil {
+		needLeaderElection := false
+		options.NeedLeaderElection = &needLeaderElection
+	}
+	return options
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+func TestConfigLeases_Failover(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	clientset := fake.NewSimpleClientset()
+	now := time.Now()
+	clock := func() time.Time { return now }
+	a := newConfigLeases(clientset, reconciler.Scheme, "replica-a", 0)
+	b := newConfigLeases(clientset, reconciler.Scheme, "replica-b", 0)
+	a.now, b.now = clock, clock
+	ctx := context.Background()
+
+	if owned, _, err := a.acquire(ctx, config); err != nil || !owned {
+		t.Fatalf("Expected the first replica to take the Lease, got %v, %v", owned, err)
+	}
+	lease, err := clientset.CoordinationV1().Leases("default").Get(ctx, "bolometer-config-test-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected a Lease named after the config: %v", err)
+	}
+	if leaseHolder(lease) != "replica-a" || len(lease.OwnerReferences) != 1 || lease.OwnerReferences[0].Name != config.Name {
+		t.Errorf("Expected a Lease held by replica-a and owned by the config, got %+v", lease)
+	}
+
+	owned, expiresIn, err := b.acquire(ctx, config)
+	if err != nil || owned {
+		t.Fatalf("Expected the Lease to be held by the first replica, got %v, %v", owned, err)
+	}
+	if expiresIn != DefaultConfigLeaseDuration {
+		t.Errorf("Expected the Lease to expire in %v, got %v", DefaultConfigLeaseDuration, expiresIn)
+	}
+
+	// Renewals keep the Lease
+	now = now.Add(10 * time.Second)
+	if lost := a.renew(ctx); len(lost) != 0 {
+		t.Fatalf("Expected the renewal to keep the Lease, lost %v", lost)
+	}
+	if owned, _, _ := b.acquire(ctx, config); owned {
+		t.Fatal("Expected a renewed Lease to stay with the first replica")
+	}
+
+	// The first replica stops renewing and the Lease expires
+	now = now.Add(DefaultConfigLeaseDuration + time.Second)
+	if owned, _, err := b.acquire(ctx, config); err != nil || !owned {
+		t.Fatalf("Expected the second replica to take over the expired Lease, got %v, %v", owned, err)
+	}
+	lease, _ = clientset.CoordinationV1().Leases("default").Get(ctx, "bolometer-config-test-config", metav1.GetOptions{})
+	if leaseHolder(lease) != "replica-b" || lease.Spec.LeaseTransitions == nil || *lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("Expected the Lease to change hands once, got %+v", lease.Spec)
+	}
+
+	if lost := a.renew(ctx); len(lost) != 1 || lost[0] != "default/test-config" {
+		t.Errorf("Expected the first replica to lose the Lease, got %v", lost)
+	}
+}
+
+func TestConfigLeases_Release(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	clientset := fake.NewSimpleClientset()
+	a := newConfigLeases(clientset, reconciler.Scheme, "replica-a", 0)
+	b := newConfigLeases(clientset, reconciler.Scheme, "replica-b", 0)
+	ctx := context.Background()
+
+	if owned, _, err := a.acquire(ctx, config); err != nil || !owned {
+		t.Fatalf("Expected the first replica to take the Lease, got %v, %v", owned, err)
+	}
+	a.release(ctx)
+
+	// Released Leases are taken over without waiting for them to expire
+	if owned, _, err := b.acquire(ctx, config); err != nil || !owned {
+		t.Errorf("Expected the second replica to take the released Lease, got %v, %v", owned, err)
+	}
+}
+
+func TestNewConfigLeases_Disabled(t *testing.T) {
+	if leases := newConfigLeases(fake.NewSimpleClientset(), nil, "", time.Minute); leases != nil {
+		t.Error("Expected no config Leases without an identity")
+	}
+}
+
+func TestReconcile_ConfigLeaseHeldElsewhere(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+	other := newConfigLeases(reconciler.Clientset, reconciler.Scheme, "replica-b", 0)
+	if owned, _, err := other.acquire(context.Background(), config); err != nil || !owned {
+		t.Fatalf("Failed to take the Lease for another replica: %v, %v", owned, err)
+	}
+	reconciler.leases = newConfigLeases(reconciler.Clientset, reconciler.Scheme, "replica-a", 0)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace}}
+	result, err := reconciler.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > DefaultConfigLeaseDuration {
+		t.Errorf("Expected a requeue once the Lease would expire, got %v", result.RequeueAfter)
+	}
+	if reconciler.monitors.IsRunning(req.NamespacedName.String()) {
+		t.Error("Expected no monitoring of a config held by another replica")
+	}
+	if tracked := reconciler.podWatcher.GetTrackedPodsForConfig(req.NamespacedName.String()); len(tracked) != 0 {
+		t.Errorf("Expected no tracked pods, got %d", len(tracked))
+	}
+}
+
+func TestConfigLeases_LeaderElection(t *testing.T) {
+	reconciler := setupTestReconciler()
+	runnable := manager.RunnableFunc(func(context.Context) error { return nil })
+	needsLeader := func(runnable manager.Runnable) bool {
+		elected, ok := runnable.(manager.LeaderElectionRunnable)
+		return !ok || elected.NeedLeaderElection()
+	}
+
+	// Without config Leases the reconciler runs on the elected leader only
+	if !needsLeader(reconciler.sharded(runnable)) {
+		t.Error("Expected the runnable to need leader election")
+	}
+	if options := reconciler.controllerOptions(); options.NeedLeaderElection != nil {
+		t.Errorf("Expected the manager's leader election, got %v", *options.NeedLeaderElection)
+	}
+
+	// With them it runs on every replica
+	reconciler.leases = newConfigLeases(reconciler.Clientset, reconciler.Scheme, "replica-a", 0)
+	if needsLeader(reconciler.sharded(runnable)) {
+		t.Error("Expected the runnable to run on every replica")
+	}
+	if options := reconciler.controllerOptions(); options.NeedLeaderElection == nil || *options.NeedLeaderElection {
+		t.Error("Expected the controllers to run on every replica")
+	}
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// setConflictStatus records the pods a config shares with other configs in its
+// status, along with a condition summarising them
+func setConflictStatus(config *profilingv1alpha1.ProfilingConfig, conflicts []profilingv1alpha1.PodConflict) {
+	config.Status.Conflicts = conflicts
+
+	if len(conflicts) == 0 {
+		setCondition(config, ConditionPodConflict, metav1.ConditionFalse, reasonNoConflicts, "No pods are selected by other configs")
+		return
+	}
+
+	lost := 0
+	for _, conflict := range conflicts {
+		if conflict.Owner != configKeyOf(config) {
+			lost++
+		}
+	}
+
+	setCondition(config, ConditionPodConflict, metav1.ConditionTrue, reasonOverlappingSelectors,
+		fmt.Sprintf("%d pods are selected by other configs, %d of them are profiled by another config", len(conflicts), lost))
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+// defaultDedupMaxAge is how long a stored profile is referred to by its
+// duplicates when a config sets no maximum age
+const defaultDedupMaxAge = 6 * time.Hour
+
+// storedProfile is the last profile of a type stored for a pod
+type storedProfile struct {
+	// destination is the bucket and prefix the profile was stored under
+	destination string
+	fingerprint string
+	key         string
+	at          time.Time
+}
+
+// profileDeduplicator remembers the last profile of each type stored for each
+// pod, so that an identical profile captured next is not stored again. The
+// zero value is ready to use.
+type profileDeduplicator struct {
+	mu sync.Mutex
+
+	// stored holds the stored profiles, by config then pod and profile type
+	stored map[string]map[string]storedProfile
+}
+
+// duplicateOf returns the key of the profile of a pod stored last for a type
+// if it has the same fingerprint, is stored at the same destination and is
+// younger than maxAge, or "" if the profile must be stored
+func (d *profileDeduplicator) duplicateOf(configKey, podKey, profileType, destination, fingerprint string, maxAge time.Duration, now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stored, ok := d.stored[configKey][podKey+"|"+profileType]
+	if !ok || stored.destination != destination || stored.fingerprint != fingerprint || now.Sub(stored.at) >= maxAge {
+		return ""
+	}
+	return stored.key
+}
+
+// store records the profile of a type just stored for a pod
+func (d *profileDeduplicator) store(configKey, podKey, profileType string, stored storedProfile) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stored == nil {
+		d.stored = make(map[string]map[string]storedProfile)
+	}
+	pods, ok := d.stored[configKey]
+	if !ok {
+		pods = make(map[string]storedProfile)
+		d.stored[configKey] = pods
+	}
+	pods[podKey+"|"+profileType] = stored
+}
+
+// retain drops the profiles of pods that are not in podKeys
+func (d *profileDeduplicator) retain(podKeys map[string]struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, pods := range d.stored {
+		for key := range pods {
+			podKey, _, _ := strings.Cut(key, "|")
+			if _, ok := podKeys[podKey]; !ok {
+				delete(pods, key)
+			}
+		}
+	}
+}
+
+// forget drops the profiles of a config
+func (d *profileDeduplicator) forget(configKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.stored, configKey)
+}
+
+// profileFingerprint returns the fingerprint of a profile in a deduplication
+// mode. Samples falls back to the bytes of traces and of profiles that cannot
+// be parsed.
+func profileFingerprint(mode profilingv1alpha1.DeduplicationMode, profile profiler.Profile) string {
+	if mode == profilingv1alpha1.DeduplicationSamples && profile.Type != analysis.TraceProfileType {
+		if fingerprint, err := analysis.Fingerprint(profile.Type, profile.Data); err == nil {
+			return "samples:" + fingerprint
+		}
+	}
+	sum := sha256.Sum256(profile.Data)
+	return "bytes:" + hex.EncodeToString(sum[:])
+}
+
+// dedupDestination returns where the profiles of a config are stored, as
+// duplicates may only refer to profiles stored there
+func dedupDestination(config *profilingv1alpha1.ProfilingConfig) string {
+	return config.Spec.S3Config.Bucket + "/" + config.Spec.S3Config.Prefix
+}
+
+// dedupHook marks the profiles of a capture identical to the previous ones of
+// the pod as duplicates before the upload, and remembers the profiles stored
+// once uploaded, when the config enables deduplication
+func (r *ProfilingConfigReconciler) dedupHook() CaptureHook {
+	return CaptureHook{
+		Name: "dedup",
+		PreUpload: func(ctx context.Context, capture *CaptureState) error {
+			dedup := capture.Config.Spec.Deduplication
+			if dedup == nil {
+				return nil
+			}
+			configKey, podKey := configKeyOf(capture.Config), r.podWatcher.getPodKey(capture.Pod)
+			destination := dedupDestination(capture.Config)
+			maxAge := time.Duration(withDefault(dedup.MaxAgeSeconds, int(defaultDedupMaxAge/time.Second))) * time.Second
+			now := time.Now()
+
+			capture.fingerprints = make(map[string]string, len(capture.Profiles))
+			for i := range capture.Profiles {
+				profile := &capture.Profiles[i]
+				fingerprint := profileFingerprint(dedup.Mode, *profile)
+				capture.fingerprints[profile.Type] = fingerprint
+				profile.DuplicateOf = r.dedup.duplicateOf(configKey, podKey, profile.Type, destination, fingerprint, maxAge, now)
+				if profile.DuplicateOf != "" {
+					log.FromContext(ctx).V(1).Info("Not storing duplicate profile",
+						"pod", podKey, "type", profile.Type, "duplicateOf", profile.DuplicateOf)
+					r.suppress(capture.Config, metrics.SuppressedDedup)
+				}
+			}
+			return nil
+		},
+		PostUpload: func(_ context.Context, capture *CaptureState) error {
+			if capture.fingerprints == nil {
+				return nil
+			}
+			configKey, podKey := configKeyOf(capture.Config), r.podWatcher.getPodKey(capture.Pod)
+			destination := dedupDestination(capture.Config)
+			now := time.Now()
+			for _, object := range capture.Manifest.Objects {
+				fingerprint, ok := capture.fingerprints[object.Type]
+				if !ok || object.Deduplicated {
+					continue
+				}
+				r.dedup.store(configKey, podKey, object.Type, storedProfile{
+					destination: destination,
+					fingerprint: fingerprint,
+					key:         object.Key,
+					at:          now,
+				})
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestProfileDeduplicator(t *testing.T) {
+	var dedup profileDeduplicator
+	now := time.Now()
+	dedup.store("default/config", "default/pod", "heap", storedProfile{
+		destination: "bucket/profiles", fingerprint: "a", key: "heap-1.pb.gz", at: now,
+	})
+
+	if key := dedup.duplicateOf("default/config", "default/pod", "heap", "bucket/profiles", "a", time.Hour, now.Add(time.Minute)); key != "heap-1.pb.gz" {
+		t.Errorf("Expected a duplicate of heap-1.pb.gz, got %q", key)
+	}
+	for name, lookup := range map[string]func() string{
+		"changed": func() string {
+			return dedup.duplicateOf("default/config", "default/pod", "heap", "bucket/profiles", "b", time.Hour, now)
+		},
+		"other type": func() string {
+			return dedup.duplicateOf("default/config", "default/pod", "goroutine", "bucket/profiles", "a", time.Hour, now)
+		},
+		"other pod": func() string {
+			return dedup.duplicateOf("default/config", "default/other", "heap", "bucket/profiles", "a", time.Hour, now)
+		},
+		"moved": func() string {
+			return dedup.duplicateOf("default/config", "default/pod", "heap", "other/profiles", "a", time.Hour, now)
+		},
+		"too old": func() string {
+			return dedup.duplicateOf("default/config", "default/pod", "heap", "bucket/profiles", "a", time.Hour, now.Add(time.Hour))
+		},
+		"other config": func() string {
+			return dedup.duplicateOf("default/other", "default/pod", "heap", "bucket/profiles", "a", time.Hour, now)
+		},
+	} {
+		if key := lookup(); key != "" {
+			t.Errorf("Expected no duplicate when %s, got %q", name, key)
+		}
+	}
+
+	dedup.retain(map[string]struct{}{})
+	if key := dedup.duplicateOf("default/config", "default/pod", "heap", "bucket/profiles", "a", time.Hour, now); key != "" {
+		t.Errorf("Expected the profiles of untracked pods to be dropped, got %q", key)
+	}
+
+	dedup.store("default/config", "default/pod", "heap", storedProfile{fingerprint: "a", key: "heap-1.pb.gz", at: now})
+	dedup.forget("default/config")
+	if key := dedup.duplicateOf("default/config", "default/pod", "heap", "", "a", time.Hour, now); key != "" {
+		t.Errorf("Expected the profiles of a forgotten config to be dropped, got %q", key)
+	}
+}
+
+func TestProfileFingerprint(t *testing.T) {
+	heapProfile := func(timeNanos int64) []byte {
+		fn := &profile.Function{ID: 1, Name: "main.alloc"}
+		location := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "inuse_space", Unit: "bytes"}},
+			Sample:     []*profile.Sample{{Location: []*profile.Location{location}, Value: []int64{1024}}},
+			Location:   []*profile.Location{location},
+			Function:   []*profile.Function{fn},
+			TimeNanos:  timeNanos,
+		}
+		var buf bytes.Buffer
+		if err := p.Write(&buf); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		return buf.Bytes()
+	}
+	first := profiler.Profile{Type: "heap", Data: heapProfile(1)}
+	later := profiler.Profile{Type: "heap", Data: heapProfile(2)}
+
+	if profileFingerprint(profilingv1alpha1.DeduplicationBytes, first) == profileFingerprint(profilingv1alpha1.DeduplicationBytes, later) {
+		t.Error("Expected profiles taken at different times to differ in bytes")
+	}
+	if profileFingerprint(profilingv1alpha1.DeduplicationSamples, first) != profileFingerprint(profilingv1alpha1.DeduplicationSamples, later) {
+		t.Error("Expected profiles with the same samples to share a fingerprint")
+	}
+
+	// Profiles that cannot be parsed are compared by bytes
+	raw := profiler.Profile{Type: "heap", Data: []byte("not a profile")}
+	if profileFingerprint(profilingv1alpha1.DeduplicationSamples, raw) != profileFingerprint(profilingv1alpha1.DeduplicationBytes, raw) {
+		t.Error("Expected an unparseable profile to be fingerprinted by its bytes")
+	}
+}
+
+func TestCapturePipeline_Deduplication(t *testing.T) {
+	reconciler, podProfiler, _ := setupPipelineReconciler()
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Deduplication = &profilingv1alpha1.DeduplicationConfig{Mode: profilingv1alpha1.DeduplicationBytes}
+	pod := createTestPod("test-pod", "default", true)
+	capture := func() uploader.ManifestEntry {
+		t.Helper()
+		manifest, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+		if err != nil {
+			t.Fatalf("captureAndUploadProfiles returned unexpected error: %v", err)
+		}
+		if len(manifest.Objects) != 1 {
+			t.Fatalf("Expected one profile, got %+v", manifest.Objects)
+		}
+		return manifest.Objects[0]
+	}
+
+	first := capture()
+	if first.Deduplicated {
+		t.Fatalf("Expected the first profile to be stored, got %+v", first)
+	}
+
+	// The same profile refers to the stored one
+	if second := capture(); !second.Deduplicated || second.Key != first.Key {
+		t.Errorf("Expected a duplicate of %s, got %+v", first.Key, second)
+	}
+
+	// A changed profile is stored
+	podProfiler.Data = map[string][]byte{"heap": []byte("grown heap profile")}
+	if third := capture(); third.Deduplicated {
+		t.Errorf("Expected the changed profile to be stored, got %+v", third)
+	}
+
+	// Without deduplication every profile is stored
+	config.Spec.Deduplication = nil
+	if fourth := capture(); fourth.Deduplicated {
+		t.Errorf("Expected the profile to be stored, got %+v", fourth)
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// Defaults of the ephemeralStorage block
+const (
+	defaultEphemeralStorageThresholdPercent = 80
+	defaultEphemeralStorageCheckInterval    = 60 * time.Second
+)
+
+// ephemeralStorageSource captures the pods whose ephemeral-storage usage
+// approaches their limit, such as pods leaking logs or temporary files
+type ephemeralStorageSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+}
+
+// Name implements TriggerSource
+func (s *ephemeralStorageSource) Name() string { return "ephemeral-storage" }
+
+// Start implements TriggerSource
+func (s *ephemeralStorageSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource
+func (s *ephemeralStorageSource) InitialDelay() time.Duration {
+	if seconds := s.config.Spec.EphemeralStorage.CheckIntervalSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultEphemeralStorageCheckInterval
+}
+
+// Check implements ScheduledSource
+func (s *ephemeralStorageSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	s.r.checkEphemeralStorage(ctx, s.config, log.FromContext(ctx), emit)
+	return s.InitialDelay()
+}
+
+// checkEphemeralStorage reads the ephemeral-storage usage of the tracked pods
+// with a limit from the kubelet, emitting a capture request per pod above the
+// threshold out of its cooldown
+func (r *ProfilingConfigReconciler) checkEphemeralStorage(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, logger logr.Logger, emit func(CaptureRequest)) {
+	cluster, err := r.clusterOf(config)
+	if err != nil {
+		logger.Error(err, "Failed to check ephemeral-storage usage")
+		return
+	}
+	kubelet := metrics.NewKubeletSource(cluster.clientset)
+	thresholdPercent := withDefault(config.Spec.EphemeralStorage.ThresholdPercent, defaultEphemeralStorageThresholdPercent)
+
+	podsByNamespace := make(map[string][]*corev1.Pod)
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		if _, ok := ephemeralStorageLimit(tracked.Pod); ok {
+			podsByNamespace[tracked.Pod.Namespace] = append(podsByNamespace[tracked.Pod.Namespace], tracked.Pod)
+		}
+	}
+
+	for namespace, pods := range podsByNamespace {
+		usages, err := kubelet.ListPodEphemeralStorage(ctx, namespace, pods)
+		if err != nil {
+			logger.Error(err, "Failed to read ephemeral-storage usage", "namespace", namespace)
+			continue
+		}
+
+		for _, pod := range pods {
+			used, ok := usages[pod.Name]
+			if !ok {
+				continue
+			}
+			exceeded, reason := ephemeralStorageExceeded(pod, used, thresholdPercent)
+			if !exceeded {
+				continue
+			}
+
+			thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+			if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+				r.suppress(config, metrics.SuppressedCooldown)
+				continue
+			}
+			if r.nodeUnderPressure(ctx, config, pod, logger) {
+				r.suppress(config, metrics.SuppressedNodePressure)
+				continue
+			}
+
+			logger.Info("Ephemeral-storage usage approaching limit, capturing profile", "pod", pod.Name, "reason", reason)
+			emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: reason}, StartCooldown: true})
+		}
+	}
+}
+
+// ephemeralStorageLimit returns the ephemeral-storage limit of a pod, the sum of
+// the limits of its containers as enforced by the kubelet, and whether it has one
+func ephemeralStorageLimit(pod *corev1.Pod) (resource.Quantity, bool) {
+	var limit resource.Quantity
+	for _, container := range pod.Spec.Containers {
+		if containerLimit, ok := container.Resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+			limit.Add(containerLimit)
+		}
+	}
+	return limit, !limit.IsZero()
+}
+
+// ephemeralStorageExceeded reports whether the ephemeral-storage usage of a pod
+// exceeds the given percentage of its limit
+func ephemeralStorageExceeded(pod *corev1.Pod, used resource.Quantity, thresholdPercent int) (bool, string) {
+	limit, ok := ephemeralStorageLimit(pod)
+	if !ok {
+		return false, ""
+	}
+	percent := float64(used.Value()) / float64(limit.Value()) * 100
+	if percent <= float64(thresholdPercent) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Ephemeral-storage usage %s is %.2f%% of the limit %s, exceeds threshold %d%%",
+		used.String(), percent, limit.String(), thresholdPercent)
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// withEphemeralStorageLimit sets the ephemeral-storage limit of the first
+// container of a pod
+func withEphemeralStorageLimit(pod *corev1.Pod, limit string) *corev1.Pod {
+	if pod.Spec.Containers[0].Resources.Limits == nil {
+		pod.Spec.Containers[0].Resources.Limits = corev1.ResourceList{}
+	}
+	pod.Spec.Containers[0].Resources.Limits[corev1.ResourceEphemeralStorage] = resource.MustParse(limit)
+	return pod
+}
+
+func TestEphemeralStorageLimit(t *testing.T) {
+	pod := createTestPod("test-pod", "default", true)
+	if _, ok := ephemeralStorageLimit(pod); ok {
+		t.Error("Expected no limit for a pod without ephemeral-storage limits")
+	}
+
+	withEphemeralStorageLimit(pod, "1Gi")
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+		Name:      "sidecar",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("512Mi")}},
+	})
+	limit, ok := ephemeralStorageLimit(pod)
+	if !ok || limit.Value() != 1536*1024*1024 {
+		t.Errorf("Expected the limits of the containers to add up to 1536Mi, got %s", limit.String())
+	}
+}
+
+func TestEphemeralStorageExceeded(t *testing.T) {
+	pod := withEphemeralStorageLimit(createTestPod("test-pod", "default", true), "1Gi")
+
+	if exceeded, reason := ephemeralStorageExceeded(pod, resource.MustParse("512Mi"), 80); exceeded {
+		t.Errorf("Expected usage below the threshold, got %q", reason)
+	}
+
+	exceeded, reason := ephemeralStorageExceeded(pod, resource.MustParse("900Mi"), 80)
+	if !exceeded || !strings.Contains(reason, "900Mi is 87.89% of the limit 1Gi") {
+		t.Errorf("Expected usage above the threshold, got %v %q", exceeded, reason)
+	}
+
+	if exceeded, _ := ephemeralStorageExceeded(createTestPod("no-limit", "default", true), resource.MustParse("10Gi"), 80); exceeded {
+		t.Error("Expected pods without a limit not to be checked")
+	}
+}
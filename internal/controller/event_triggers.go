@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// Bounds of the delay before a failed Event watch is restarted
+const (
+	eventWatchMinBackoff = time.Second
+	eventWatchMaxBackoff = time.Minute
+)
+
+// maxEventMessageLength caps the Event message quoted in capture reasons
+const maxEventMessageLength = 200
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=list;watch
+
+// eventTriggerSource captures pods when Events with the reasons of the
+// config's eventTriggers are recorded for them or their nodes. Each reason is
+// watched across namespaces, filtered by the API server.
+type eventTriggerSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+}
+
+// Name implements TriggerSource
+func (s *eventTriggerSource) Name() string { return "events" }
+
+// Start implements TriggerSource
+func (s *eventTriggerSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	logger := log.FromContext(ctx)
+	cluster, err := s.r.clusterOf(s.config)
+	if err != nil {
+		logger.Error(err, "Failed to watch Events")
+		return
+	}
+
+	// Events recorded before the source started were acted on, or missed, by
+	// the previous one
+	started := time.Now()
+
+	// A panic in a watch stops the others and is raised again on the task's
+	// goroutine, for the monitor to restart the source
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var panicOnce sync.Once
+	var panicked error
+
+	var wg sync.WaitGroup
+	for reason, triggers := range eventTriggersByReason(s.config.Spec.EventTriggers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.watch(ctx, cluster, reason, triggers, started, logger, emit); err != nil {
+				panicOnce.Do(func() { panicked = err })
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// watch watches the Events of a reason, requesting captures for the triggers
+// of the reason, converting a panic into a *panicError
+func (s *eventTriggerSource) watch(ctx context.Context, cluster *targetCluster, reason string, triggers []profilingv1alpha1.EventTrigger, started time.Time, logger logr.Logger, emit func(CaptureRequest)) (err error) {
+	defer recoverPanic("event watch "+reason, &err)
+
+	watchEvents(ctx, cluster.clientset, reason, logger, func(event *corev1.Event) {
+		if eventTime(event).Before(started) {
+			return
+		}
+		for _, trigger := range triggers {
+			s.r.captureForEvent(ctx, s.config, cluster.reader, trigger, event, logger, emit)
+		}
+	})
+	return nil
+}
+
+// eventTriggersByReason groups event triggers by the reason they watch
+func eventTriggersByReason(triggers []profilingv1alpha1.EventTrigger) map[string][]profilingv1alpha1.EventTrigger {
+	byReason := make(map[string][]profilingv1alpha1.EventTrigger)
+	for _, trigger := range triggers {
+		byReason[trigger.Reason] = append(byReason[trigger.Reason], trigger)
+	}
+	return byReason
+}
+
+// watchEvents calls handle with the Events of a reason added or updated until
+// ctx is done. The watch starts from the current Events and is restarted with
+// backoff when it fails or expires.
+func watchEvents(ctx context.Context, clientset kubernetes.Interface, reason string, logger logr.Logger, handle func(*corev1.Event)) {
+	backoff := eventWatchMinBackoff
+	for {
+		err := watchEventsOnce(ctx, clientset, reason, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error(err, "Event watch failed, restarting", "reason", reason, "backoff", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, eventWatchMaxBackoff)
+			continue
+		}
+		backoff = eventWatchMinBackoff
+	}
+}
+
+// watchEventsOnce lists the Events of a reason to find where to watch from and
+// watches them until the watch closes or fails
+func watchEventsOnce(ctx context.Context, clientset kubernetes.Interface, reason string, handle func(*corev1.Event)) error {
+	selector := fields.OneTermEqualSelector("reason", reason).String()
+	events := clientset.CoreV1().Events(metav1.NamespaceAll)
+
+	list, err := events.List(ctx, metav1.ListOptions{FieldSelector: selector, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list Events: %w", err)
+	}
+	watcher, err := events.Watch(ctx, metav1.ListOptions{FieldSelector: selector, ResourceVersion: list.ResourceVersion})
+	if err != nil {
+		return fmt.Errorf("failed to watch Events: %w", err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch change.Type {
+			case watch.Added, watch.Modified:
+				if event, ok := change.Object.(*corev1.Event); ok && event.Reason == reason {
+					handle(event)
+				}
+			case watch.Error:
+				return errors.FromObject(change.Object)
+			}
+		}
+	}
+}
+
+// eventTime returns when an Event was last observed
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// captureForEvent emits capture requests for the tracked pods an Event
+// targets, skipping the pods in cooldown
+func (r *ProfilingConfigReconciler) captureForEvent(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, reader client.Reader, trigger profilingv1alpha1.EventTrigger, event *corev1.Event, logger logr.Logger, emit func(CaptureRequest)) {
+	var tracked []*corev1.Pod
+	for _, pod := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		tracked = append(tracked, pod.Pod)
+	}
+	pods := eventTargets(ctx, reader, tracked, trigger, event)
+	if len(pods) == 0 {
+		return
+	}
+
+	reason := eventReason(event)
+	maxPods := withDefault(trigger.MaxPods, 1)
+	captured := 0
+	for _, pod := range pods {
+		if captured == maxPods {
+			break
+		}
+		thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+		if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+			r.suppress(config, metrics.SuppressedCooldown)
+			continue
+		}
+		if r.nodeUnderPressure(ctx, config, pod, logger) {
+			r.suppress(config, metrics.SuppressedNodePressure)
+			continue
+		}
+
+		logger.Info("Event recorded, capturing profile", "pod", pod.Name, "reason", reason)
+		emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: reason}, StartCooldown: true})
+		captured++
+	}
+}
+
+// eventTargets returns the tracked pods an Event targets: the pod it is about
+// or its siblings, the longest running first, or the pods of the node it is
+// about
+func eventTargets(ctx context.Context, reader client.Reader, tracked []*corev1.Pod, trigger profilingv1alpha1.EventTrigger, event *corev1.Event) []*corev1.Pod {
+	object := event.InvolvedObject
+	var targets []*corev1.Pod
+
+	switch object.Kind {
+	case "Node":
+		for _, pod := range tracked {
+			if pod.Spec.NodeName == object.Name {
+				targets = append(targets, pod)
+			}
+		}
+
+	case "Pod":
+		var subject *corev1.Pod
+		for _, pod := range tracked {
+			if pod.Namespace == object.Namespace && pod.Name == object.Name &&
+				(object.UID == "" || pod.UID == object.UID) {
+				subject = pod
+			}
+		}
+		if trigger.Target != profilingv1alpha1.EventTargetSiblings {
+			if subject != nil {
+				targets = append(targets, subject)
+			}
+			break
+		}
+
+		// Evicted pods are no longer tracked, so their owner is read
+		if subject == nil {
+			subject = &corev1.Pod{}
+			if err := reader.Get(ctx, client.ObjectKey{Namespace: object.Namespace, Name: object.Name}, subject); err != nil {
+				return nil
+			}
+		}
+		owner := metav1.GetControllerOf(subject)
+		if owner == nil {
+			return nil
+		}
+		kind, name := workloadOf(subject, owner.Kind, owner.Name)
+		for _, pod := range tracked {
+			if pod.Namespace == subject.Namespace && pod.Name != subject.Name && ownedBy(pod, kind, name) {
+				targets = append(targets, pod)
+			}
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].CreationTimestamp.Before(&targets[j].CreationTimestamp)
+	})
+	return targets
+}
+
+// eventReason returns the capture reason of an Event
+func eventReason(event *corev1.Event) string {
+	message := event.Message
+	if len(message) > maxEventMessageLength {
+		message = message[:maxEventMessageLength] + "..."
+	}
+	object := event.InvolvedObject
+	name := object.Name
+	if object.Namespace != "" {
+		name = object.Namespace + "/" + object.Name
+	}
+	return fmt.Sprintf("Event %s on %s %s: %s", event.Reason, object.Kind, name, message)
+}
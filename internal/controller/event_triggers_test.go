@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// testEvent returns an Event of a reason about an object
+func testEvent(reason, kind, namespace, name string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name + "." + strings.ToLower(reason), Namespace: withDefault(namespace, "default")},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Namespace: namespace, Name: name},
+		Reason:         reason,
+		Message:        "Back-off restarting failed container",
+		LastTimestamp:  metav1.Now(),
+	}
+}
+
+func TestEventTargets(t *testing.T) {
+	now := time.Now()
+	subject := testDeploymentPod("my-app-7d4b9c-a", now.Add(-time.Hour))
+	subject.Spec.NodeName = "node-1"
+	older := testDeploymentPod("my-app-7d4b9c-b", now.Add(-2*time.Hour))
+	newer := testDeploymentPod("my-app-7d4b9c-c", now.Add(-time.Minute))
+	newer.Spec.NodeName = "node-1"
+	other := createTestPod("other", "default", true)
+	tracked := []*corev1.Pod{subject, newer, other, older}
+	reader := setupTestReconciler().podWatcher.reader
+	ctx := context.Background()
+
+	names := func(pods []*corev1.Pod) string {
+		var names []string
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	podTrigger := profilingv1alpha1.EventTrigger{Reason: "BackOff", Target: profilingv1alpha1.EventTargetPod}
+	if got := names(eventTargets(ctx, reader, tracked, podTrigger, testEvent("BackOff", "Pod", "default", subject.Name))); got != subject.Name {
+		t.Errorf("Expected the pod of the Event, got %s", got)
+	}
+
+	siblings := profilingv1alpha1.EventTrigger{Reason: "BackOff", Target: profilingv1alpha1.EventTargetSiblings}
+	if got := names(eventTargets(ctx, reader, tracked, siblings, testEvent("BackOff", "Pod", "default", subject.Name))); got != older.Name+","+newer.Name {
+		t.Errorf("Expected the siblings of the pod, longest running first, got %s", got)
+	}
+
+	// Node Events target the pods of the node
+	if got := names(eventTargets(ctx, reader, tracked, siblings, testEvent("OOMKilling", "Node", "", "node-1"))); got != subject.Name+","+newer.Name {
+		t.Errorf("Expected the pods of the node, got %s", got)
+	}
+
+	// A pod replaced under the same name is not the pod of the Event
+	replaced := testEvent("BackOff", "Pod", "default", subject.Name)
+	replaced.InvolvedObject.UID = "old-uid"
+	if got := eventTargets(ctx, reader, tracked, podTrigger, replaced); len(got) != 0 {
+		t.Errorf("Expected no pod for another UID, got %s", names(got))
+	}
+}
+
+func TestEventTargets_UntrackedPod(t *testing.T) {
+	now := time.Now()
+	evicted := testDeploymentPod("my-app-7d4b9c-a", now.Add(-time.Hour))
+	evicted.Status.Phase = corev1.PodFailed
+	sibling := testDeploymentPod("my-app-7d4b9c-b", now.Add(-2*time.Hour))
+	reader := setupTestReconciler(evicted).podWatcher.reader
+	siblings := profilingv1alpha1.EventTrigger{Reason: "Evicted", Target: profilingv1alpha1.EventTargetSiblings}
+
+	targets := eventTargets(context.Background(), reader, []*corev1.Pod{sibling}, siblings, testEvent("Evicted", "Pod", "default", evicted.Name))
+	if len(targets) != 1 || targets[0].Name != sibling.Name {
+		t.Errorf("Expected the sibling of the evicted pod, got %v", targets)
+	}
+
+	// Siblings of pods that are gone cannot be told
+	targets = eventTargets(context.Background(), reader, []*corev1.Pod{sibling}, siblings, testEvent("Evicted", "Pod", "default", "gone"))
+	if len(targets) != 0 {
+		t.Errorf("Expected no target for a deleted pod, got %v", targets)
+	}
+}
+
+func TestEventReason(t *testing.T) {
+	event := testEvent("BackOff", "Pod", "default", "my-app")
+	if reason := eventReason(event); reason != "Event BackOff on Pod default/my-app: Back-off restarting failed container" {
+		t.Errorf("Unexpected reason %q", reason)
+	}
+	event.Message = strings.Repeat("x", 500)
+	if reason := eventReason(event); len(reason) > 300 || !strings.HasSuffix(reason, "...") {
+		t.Errorf("Expected the message to be truncated, got %q", reason)
+	}
+}
+
+func TestEventTriggerSource(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.EventTriggers = []profilingv1alpha1.EventTrigger{{Reason: "BackOff"}}
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+	reconciler.podWatcher.TrackPod(pod, config)
+	clientset := fake.NewSimpleClientset()
+	reconciler.Clientset = clientset
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	requests := make(chan CaptureRequest, 1)
+	go (&eventTriggerSource{r: reconciler, config: config}).Start(ctx, func(request CaptureRequest) { requests <- request })
+
+	// Wait for the watch, as the fake clientset does not replay Events
+	deadline := time.Now().Add(5 * time.Second)
+	for !hasAction(clientset, "watch") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the Event watch")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, event := range []*corev1.Event{testEvent("Pulled", "Pod", "default", pod.Name), testEvent("BackOff", "Pod", "default", pod.Name)} {
+		if _, err := clientset.CoreV1().Events("default").Create(ctx, event, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Failed to create Event: %v", err)
+		}
+	}
+
+	select {
+	case request := <-requests:
+		if request.Pod.Name != pod.Name || !request.StartCooldown || !strings.HasPrefix(request.Trigger.Reason, "Event BackOff") {
+			t.Errorf("Unexpected capture request %+v", request)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the capture request")
+	}
+}
+
+// hasAction reports whether a fake clientset received an action of a verb
+func hasAction(clientset *fake.Clientset, verb string) bool {
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == verb {
+			return true
+		}
+	}
+	return false
+}
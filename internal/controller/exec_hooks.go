@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// defaultExecHookTimeout bounds exec hooks that set no timeout
+const defaultExecHookTimeout = 10 * time.Second
+
+// AllowExecHooksAnnotation opts a pod in to the exec hooks of the config
+// profiling it. Hooks are never run in pods without it.
+const AllowExecHooksAnnotation = "bolometer.io/allow-exec-hooks"
+
+// validateExecHooks only allows exec hooks in configs selecting pods of their
+// own namespace, so a config cannot run commands in other teams' pods
+func validateExecHooks(config *profilingv1alpha1.ProfilingConfig) error {
+	hooks := config.Spec.ExecHooks
+	if hooks == nil || len(hooks.PreCapture)+len(hooks.PostCapture) == 0 {
+		return nil
+	}
+	if namespace := selectorNamespace(config); namespace != config.Namespace {
+		return fmt.Errorf("execHooks can only run in pods of the config's namespace %s, not %s", config.Namespace, namespace)
+	}
+	return nil
+}
+
+// execHooksAllowed reports why exec hooks may not run in a pod, if they may not
+func execHooksAllowed(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod) error {
+	if pod.Namespace != config.Namespace {
+		return fmt.Errorf("pod %s/%s is not in the config's namespace %s", pod.Namespace, pod.Name, config.Namespace)
+	}
+	if pod.Annotations[AllowExecHooksAnnotation] != "true" {
+		return fmt.Errorf("pod %s/%s does not allow exec hooks with the %s annotation", pod.Namespace, pod.Name, AllowExecHooksAnnotation)
+	}
+	return nil
+}
+
+// execHook returns the built-in capture hook running the config's exec hooks
+// in the captured pod
+func (r *ProfilingConfigReconciler) execHook() CaptureHook {
+	return CaptureHook{
+		Name: "exec",
+		PreCapture: func(ctx context.Context, capture *CaptureState) error {
+			hooks := capture.Config.Spec.ExecHooks
+			if hooks == nil {
+				return nil
+			}
+			// Post-capture commands undo what pre-capture ones changed, so
+			// they run even if the capture fails once any of these ran
+			if len(hooks.PreCapture) > 0 && execHooksAllowed(capture.Config, capture.Pod) == nil {
+				capture.restore = func(ctx context.Context) error {
+					return r.runExecHooks(ctx, capture, hooks.PostCapture)
+				}
+			}
+			return r.runExecHooks(ctx, capture, hooks.PreCapture)
+		},
+		PostCapture: func(ctx context.Context, capture *CaptureState) error {
+			capture.restore = nil
+			if hooks := capture.Config.Spec.ExecHooks; hooks != nil {
+				return r.runExecHooks(ctx, capture, hooks.PostCapture)
+			}
+			return nil
+		},
+	}
+}
+
+// runExecHooks runs commands in the captured pod in order. A failed command
+// stops the capture if its failure policy is Fail and is only logged otherwise.
+// In pods that do not allow exec hooks, no command runs and the capture fails
+// if any of them has the Fail policy.
+func (r *ProfilingConfigReconciler) runExecHooks(ctx context.Context, capture *CaptureState, hooks []profilingv1alpha1.ExecHook) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx).WithValues("pod", capture.Pod.Name)
+
+	if err := execHooksAllowed(capture.Config, capture.Pod); err != nil {
+		for _, hook := range hooks {
+			if hook.FailurePolicy == profilingv1alpha1.ExecHookFail {
+				return fmt.Errorf("exec hooks cannot run: %w", err)
+			}
+		}
+		logger.Info("Skipping exec hooks", "reason", err.Error())
+		return nil
+	}
+
+	cluster, err := r.clusterOf(capture.Config)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		container := hook.Container
+		if container == "" && len(capture.Pod.Spec.Containers) > 0 {
+			container = capture.Pod.Spec.Containers[0].Name
+		}
+		timeout := defaultExecHookTimeout
+		if hook.TimeoutSeconds > 0 {
+			timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+		}
+
+		execCtx, cancel := context.WithTimeout(ctx, timeout)
+		output, err := cluster.executor.Exec(execCtx, capture.Pod, container, hook.Command)
+		cancel()
+
+		command := strings.Join(hook.Command, " ")
+		if err == nil {
+			logger.V(1).Info("Exec hook succeeded", "container", container, "command", command, "output", output)
+			continue
+		}
+		if hook.FailurePolicy == profilingv1alpha1.ExecHookFail {
+			return fmt.Errorf("exec hook %q in container %s failed: %w", command, container, err)
+		}
+		logger.Error(err, "Exec hook failed, continuing", "container", container, "command", command)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+func TestExecHooks(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.ExecHooks = &profilingv1alpha1.ExecHooksConfig{
+		PreCapture: []profilingv1alpha1.ExecHook{
+			{Command: []string{"curl", "-s", "localhost:8080/debug/gc"}},
+			{Command: []string{"touch", "/tmp/debug"}, Container: "sidecar"},
+		},
+		PostCapture: []profilingv1alpha1.ExecHook{
+			{Command: []string{"rm", "/tmp/debug"}, Container: "sidecar"},
+		},
+	}
+	pod := createTestPod("test-pod", "default", true)
+	pod.Annotations[AllowExecHooksAnnotation] = "true"
+	reconciler, podProfiler, _ := setupPipelineReconciler()
+	executor := &profiler.FakeExecutor{}
+	reconciler.executor = executor
+
+	_, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err != nil {
+		t.Fatalf("captureAndUploadProfiles returned unexpected error: %v", err)
+	}
+	expected := []string{
+		"test-pod/test-container: curl -s localhost:8080/debug/gc",
+		"test-pod/sidecar: touch /tmp/debug",
+		"test-pod/sidecar: rm /tmp/debug",
+	}
+	if executed := executor.Executed(); strings.Join(executed, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands %v, got %v", expected, executed)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 1 {
+		t.Errorf("Expected one capture, got %v", captured)
+	}
+}
+
+func TestExecHooks_FailurePolicy(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.ExecHooks = &profilingv1alpha1.ExecHooksConfig{
+		PreCapture: []profilingv1alpha1.ExecHook{{Command: []string{"kill", "-USR1", "1"}}},
+	}
+	pod := createTestPod("test-pod", "default", true)
+	pod.Annotations[AllowExecHooksAnnotation] = "true"
+
+	// Failures are ignored by default
+	reconciler, podProfiler, _ := setupPipelineReconciler()
+	reconciler.executor = &profiler.FakeExecutor{Err: errors.New("command terminated with exit code 1")}
+	if _, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"}); err != nil {
+		t.Errorf("Expected the failure to be ignored, got %v", err)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 1 {
+		t.Errorf("Expected one capture, got %v", captured)
+	}
+
+	// Fail stops the capture
+	config.Spec.ExecHooks.PreCapture[0].FailurePolicy = profilingv1alpha1.ExecHookFail
+	reconciler, podProfiler, _ = setupPipelineReconciler()
+	reconciler.executor = &profiler.FakeExecutor{Err: errors.New("command terminated with exit code 1")}
+	_, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err == nil || !strings.Contains(err.Error(), "exit code 1") {
+		t.Errorf("Expected the exec hook's error, got %v", err)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 0 {
+		t.Errorf("Expected no capture, got %v", captured)
+	}
+}
+
+func TestExecHooks_RestoreOnFailure(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.ExecHooks = &profilingv1alpha1.ExecHooksConfig{
+		PreCapture:  []profilingv1alpha1.ExecHook{{Command: []string{"kill", "-USR1", "1"}}},
+		PostCapture: []profilingv1alpha1.ExecHook{{Command: []string{"kill", "-USR2", "1"}}},
+	}
+	pod := createTestPod("test-pod", "default", true)
+	pod.Annotations[AllowExecHooksAnnotation] = "true"
+	expected := []string{
+		"test-pod/test-container: kill -USR1 1",
+		"test-pod/test-container: kill -USR2 1",
+	}
+
+	// A failed capture still runs the post-capture commands
+	reconciler, podProfiler, _ := setupPipelineReconciler()
+	podProfiler.Err = errors.New("connection refused")
+	executor := &profiler.FakeExecutor{}
+	reconciler.executor = executor
+	_, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the capture's error, got %v", err)
+	}
+	if executed := executor.Executed(); strings.Join(executed, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands %v, got %v", expected, executed)
+	}
+
+	// So does a pre-capture hook failing after the exec hooks ran
+	failing := CaptureHook{Name: "validate", PreCapture: func(context.Context, *CaptureState) error {
+		return errors.New("invalid pod")
+	}}
+	reconciler, podProfiler, _ = setupPipelineReconciler(failing)
+	executor = &profiler.FakeExecutor{}
+	reconciler.executor = executor
+	if _, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"}); err == nil {
+		t.Error("Expected the hook's error")
+	}
+	if executed := executor.Executed(); strings.Join(executed, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands %v, got %v", expected, executed)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 0 {
+		t.Errorf("Expected no capture, got %v", captured)
+	}
+}
+
+func TestExecHooks_PodOptIn(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.ExecHooks = &profilingv1alpha1.ExecHooksConfig{
+		PreCapture: []profilingv1alpha1.ExecHook{{Command: []string{"kill", "-USR1", "1"}}},
+	}
+	pod := createTestPod("test-pod", "default", true)
+
+	// Pods without the annotation are captured without running the hooks
+	reconciler, podProfiler, _ := setupPipelineReconciler()
+	executor := &profiler.FakeExecutor{}
+	reconciler.executor = executor
+	if _, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"}); err != nil {
+		t.Errorf("Expected the hooks to be skipped, got %v", err)
+	}
+	if executed := executor.Executed(); len(executed) != 0 {
+		t.Errorf("Expected no command in a pod without the annotation, got %v", executed)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 1 {
+		t.Errorf("Expected one capture, got %v", captured)
+	}
+
+	// Unless a hook must not fail
+	config.Spec.ExecHooks.PreCapture[0].FailurePolicy = profilingv1alpha1.ExecHookFail
+	reconciler, podProfiler, _ = setupPipelineReconciler()
+	reconciler.executor = executor
+	_, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err == nil || !strings.Contains(err.Error(), AllowExecHooksAnnotation) {
+		t.Errorf("Expected the missing annotation to fail the capture, got %v", err)
+	}
+	if executed := executor.Executed(); len(executed) != 0 {
+		t.Errorf("Expected no command in a pod without the annotation, got %v", executed)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 0 {
+		t.Errorf("Expected no capture, got %v", captured)
+	}
+
+	// Pods of other namespaces never run the hooks, annotated or not
+	other := createTestPod("test-pod", "payments", true)
+	other.Annotations[AllowExecHooksAnnotation] = "true"
+	if err := execHooksAllowed(config, other); err == nil {
+		t.Error("Expected exec hooks to be refused in another namespace")
+	}
+}
+
+func TestValidateConfig_ExecHooks(t *testing.T) {
+	reconciler := setupTestReconciler()
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.ExecHooks = &profilingv1alpha1.ExecHooksConfig{
+		PostCapture: []profilingv1alpha1.ExecHook{{Command: []string{"rm", "/tmp/debug"}}},
+	}
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected hooks on pods of the config's namespace to be valid, got %v", err)
+	}
+
+	config.Spec.Selector.Namespace = ""
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected hooks on pods of the config's own namespace to be valid, got %v", err)
+	}
+
+	config.Spec.Selector.Namespace = "payments"
+	if err := reconciler.validateConfig(config); err == nil || !strings.Contains(err.Error(), "execHooks") {
+		t.Errorf("Expected hooks on pods of another namespace to be rejected, got %v", err)
+	}
+}
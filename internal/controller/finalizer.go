@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+const (
+	// ProfilingConfigFinalizer holds ProfilingConfig deletion until its monitors are torn down
+	ProfilingConfigFinalizer = "bolometer.io/finalizer"
+
+	// FinalizeDeadlineAnnotation records, on a deleted config, the RFC 3339
+	// time after which the finalizer is removed even if captures are still in
+	// flight
+	FinalizeDeadlineAnnotation = "bolometer.io/finalize-deadline"
+
+	// uploadTimeout bounds the upload of a capture's profiles
+	uploadTimeout = 2 * time.Minute
+
+	// finalizeTimeout bounds how long a deleted config waits for in-flight
+	// captures and uploads
+	finalizeTimeout = uploadTimeout + 30*time.Second
+
+	// finalizeRequeueInterval is how often a deleted config checks whether its
+	// in-flight captures drained
+	finalizeRequeueInterval = 2 * time.Second
+)
+
+// stoppingMonitors remembers the done channel of the monitors of each deleted
+// config, as the monitor manager forgets a monitor once it is stopped. The
+// zero value is ready to use.
+type stoppingMonitors struct {
+	mu   sync.Mutex
+	done map[string]<-chan struct{}
+}
+
+// stop stops the monitors of a config on the first call and returns the
+// channel closed once they returned
+func (s *stoppingMonitors) stop(configKey string, stop func(string) <-chan struct{}) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if done, ok := s.done[configKey]; ok {
+		return done
+	}
+	if s.done == nil {
+		s.done = make(map[string]<-chan struct{})
+	}
+	done := stop(configKey)
+	s.done[configKey] = done
+	return done
+}
+
+// forget drops the channel of a finalized config
+func (s *stoppingMonitors) forget(configKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.done, configKey)
+}
+
+// finalize stops monitoring for a deleted config and requeues until its
+// in-flight captures are cancelled and their uploads flushed, or until the
+// deadline recorded on the config passed. It then releases its tracked pods
+// and removes the finalizer. It never blocks, so a deletion does not hold up
+// the reconciliation of other configs.
+func (r *ProfilingConfigReconciler) finalize(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(config, ProfilingConfigFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	logger := log.FromContext(ctx)
+	configKey := client.ObjectKeyFromObject(config).String()
+
+	// Stopping the monitors cancels their queued and running captures; the
+	// capture workers then flush the uploads
+	done := r.stopping.stop(configKey, r.stopMonitoring)
+	if !drained(done) || r.captureQueue.Pending(configKey) > 0 {
+		deadline, err := r.finalizeDeadline(ctx, config)
+		if err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		if remaining := time.Until(deadline); remaining > 0 {
+			logger.V(1).Info("Waiting for in-flight captures before finalizing", "pending", r.captureQueue.Pending(configKey))
+			return ctrl.Result{RequeueAfter: min(finalizeRequeueInterval, remaining)}, nil
+		}
+		logger.Info("Timed out waiting for in-flight captures, removing finalizer anyway")
+	}
+
+	r.forgetConfig(configKey)
+
+	controllerutil.RemoveFinalizer(config, ProfilingConfigFinalizer)
+	if err := r.Update(ctx, config); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	r.stopping.forget(configKey)
+
+	logger.Info("Finalized ProfilingConfig")
+	return ctrl.Result{}, nil
+}
+
+// forgetConfig releases the tracked pods of a deleted config and drops the
+// state kept for it, so a config recreated under the same key starts afresh
+func (r *ProfilingConfigReconciler) forgetConfig(configKey string) {
+	r.pruneTrackedPods(configKey, nil)
+	r.clusters.forget(configKey)
+	r.issues.forget(configKey)
+	r.leaks.forget(configKey)
+	r.storageChecks.forget(configKey)
+	r.lifecycleRules.forget(configKey)
+	r.storageBudgets.forget(configKey)
+	r.dedup.forget(configKey)
+	r.webIdentities.forget(configKey)
+	r.circuits.forget(configKey)
+	r.suppressions.Forget(configKey)
+	r.panics.Forget(configKey)
+}
+
+// finalizeDeadline returns the deadline recorded on a deleted config,
+// recording one finalizeTimeout from now on the first call so it survives
+// restarts and moves between replicas
+func (r *ProfilingConfigReconciler) finalizeDeadline(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) (time.Time, error) {
+	if value, ok := config.Annotations[FinalizeDeadlineAnnotation]; ok {
+		if deadline, err := time.Parse(time.RFC3339, value); err == nil {
+			return deadline, nil
+		}
+	}
+
+	deadline := time.Now().Add(finalizeTimeout)
+	if config.Annotations == nil {
+		config.Annotations = make(map[string]string)
+	}
+	config.Annotations[FinalizeDeadlineAnnotation] = deadline.Format(time.RFC3339)
+	if err := r.Update(ctx, config); err != nil {
+		return time.Time{}, err
+	}
+	return deadline, nil
+}
+
+// drained reports whether done is closed
+func drained(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func TestReconcile_AddsFinalizer(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(config)}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	updated := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(ctx, req.NamespacedName, updated); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+
+	if !controllerutil.ContainsFinalizer(updated, ProfilingConfigFinalizer) {
+		t.Error("Expected finalizer to be added")
+	}
+}
+
+func TestReconcile_FinalizeWaitsForCaptures(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(config)}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	// Replace the monitor with one simulating an in-flight upload that takes a
+	// moment to flush after cancellation
+	var flushed atomic.Bool
+	reconciler.monitors.Start(ctx, req.NamespacedName.String(), "hash", MonitorTask{
+		Name: "capture",
+		Run: func(ctx context.Context) {
+			<-ctx.Done()
+			time.Sleep(20 * time.Millisecond)
+			flushed.Store(true)
+		},
+	})
+
+	reconciler.storageBudgets.restore(req.NamespacedName.String(), nil)
+
+	current := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(ctx, req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if err := reconciler.Delete(ctx, current); err != nil {
+		t.Fatalf("Failed to delete config: %v", err)
+	}
+
+	// Deletion requeues instead of blocking while the upload is in flight
+	result, err := reconciler.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile after deletion returned unexpected error: %v", err)
+	}
+	if result.RequeueAfter == 0 {
+		t.Fatal("Expected deletion to requeue while captures are in flight")
+	}
+	if err := reconciler.Get(ctx, req.NamespacedName, &profilingv1alpha1.ProfilingConfig{}); err != nil {
+		t.Errorf("Expected config to be kept while captures are in flight, got %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile after deletion returned unexpected error: %v", err)
+	}
+
+	if !flushed.Load() {
+		t.Error("Expected finalizer to wait for in-flight captures")
+	}
+
+	if reconciler.monitors.IsRunning(req.NamespacedName.String()) {
+		t.Error("Expected monitoring to be stopped")
+	}
+
+	if count := reconciler.podWatcher.GetActivePodCount(); count != 0 {
+		t.Errorf("Expected tracked pods to be released, got %d", count)
+	}
+
+	if usage := reconciler.storageBudgets.snapshot(req.NamespacedName.String(), time.Now()); usage != nil {
+		t.Errorf("Expected the storage usage to be forgotten, got %+v", usage)
+	}
+
+	err = reconciler.Get(ctx, req.NamespacedName, &profilingv1alpha1.ProfilingConfig{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected config to be removed after finalizing, got %v", err)
+	}
+}
+
+func TestReconcile_FinalizeDeadline(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(config)}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	// A monitor that never returns keeps the config from draining
+	stuck := make(chan struct{})
+	defer close(stuck)
+	reconciler.monitors.Start(ctx, req.NamespacedName.String(), "hash", MonitorTask{
+		Name: "capture",
+		Run:  func(context.Context) { <-stuck },
+	})
+
+	current := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(ctx, req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if err := reconciler.Delete(ctx, current); err != nil {
+		t.Fatalf("Failed to delete config: %v", err)
+	}
+	if err := reconciler.Get(ctx, req.NamespacedName, current); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+
+	if result, err := reconciler.finalize(ctx, current); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("Expected finalize to requeue before the deadline, got %+v, %v", result, err)
+	}
+
+	if _, ok := current.Annotations[FinalizeDeadlineAnnotation]; !ok {
+		t.Fatal("Expected the finalize deadline to be recorded on the config")
+	}
+
+	// Past the deadline the finalizer is removed anyway
+	current.Annotations[FinalizeDeadlineAnnotation] = time.Now().Add(-time.Second).Format(time.RFC3339)
+	if err := reconciler.Update(ctx, current); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if result, err := reconciler.finalize(ctx, current); err != nil || result.RequeueAfter != 0 {
+		t.Fatalf("Expected finalize to complete after the deadline, got %+v, %v", result, err)
+	}
+
+	err := reconciler.Get(ctx, req.NamespacedName, &profilingv1alpha1.ProfilingConfig{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected config to be removed after the deadline, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// Defaults of the hpaScaleOut block
+const (
+	defaultHPAWindow        = 5 * time.Minute
+	defaultHPAMaxPods       = 2
+	defaultHPACheckInterval = 30 * time.Second
+)
+
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list
+
+// hpaSample is the replica count an HPA desired at a time
+type hpaSample struct {
+	at       time.Time
+	replicas int32
+}
+
+// hpaHistory is what the scale-out trigger remembers of an HPA
+type hpaHistory struct {
+	samples []hpaSample
+	atMax   bool
+}
+
+// scaleOutDetector follows the desired replicas of HPAs to tell rapid
+// scale-outs and HPAs reaching their maximum. It is owned by a single source.
+type scaleOutDetector struct {
+	minIncreasePercent int
+	window             time.Duration
+	onMaxReplicas      bool
+
+	// histories holds the history of each HPA, by namespace/name
+	histories map[string]*hpaHistory
+}
+
+// newScaleOutDetector creates the detector of an hpaScaleOut block
+func newScaleOutDetector(config *profilingv1alpha1.HPAScaleOutConfig) *scaleOutDetector {
+	window := defaultHPAWindow
+	if config.WindowSeconds > 0 {
+		window = time.Duration(config.WindowSeconds) * time.Second
+	}
+	return &scaleOutDetector{
+		minIncreasePercent: config.MinIncreasePercent,
+		window:             window,
+		onMaxReplicas:      config.OnMaxReplicas == nil || *config.OnMaxReplicas,
+		histories:          make(map[string]*hpaHistory),
+	}
+}
+
+// observe records the desired replicas of an HPA and returns why its workload
+// should be captured, or "" if it did not scale out. The first observation of
+// an HPA only sets its baseline. A scale-out restarts the window, so it is
+// reported once.
+func (d *scaleOutDetector) observe(hpa *autoscalingv2.HorizontalPodAutoscaler, now time.Time) string {
+	key := hpa.Namespace + "/" + hpa.Name
+	desired, maxReplicas := hpa.Status.DesiredReplicas, hpa.Spec.MaxReplicas
+	atMax := desired >= maxReplicas
+
+	history, ok := d.histories[key]
+	if !ok {
+		d.histories[key] = &hpaHistory{samples: []hpaSample{{at: now, replicas: desired}}, atMax: atMax}
+		return ""
+	}
+
+	reachedMax := atMax && !history.atMax
+	history.atMax = atMax
+
+	// Drop the samples that left the window, keeping the latest one as the
+	// replicas desired at the start of the window
+	for len(history.samples) > 1 && now.Sub(history.samples[1].at) >= d.window {
+		history.samples = history.samples[1:]
+	}
+	history.samples = append(history.samples, hpaSample{at: now, replicas: desired})
+
+	if reachedMax && d.onMaxReplicas {
+		history.samples = history.samples[len(history.samples)-1:]
+		return fmt.Sprintf("HPA %s reached its maxReplicas of %d", hpa.Name, maxReplicas)
+	}
+
+	if d.minIncreasePercent > 0 {
+		lowest := history.samples[0]
+		for _, sample := range history.samples {
+			if sample.replicas < lowest.replicas {
+				lowest = sample
+			}
+		}
+		if lowest.replicas > 0 && desired > lowest.replicas &&
+			int(desired-lowest.replicas)*100 >= int(lowest.replicas)*d.minIncreasePercent {
+			history.samples = history.samples[len(history.samples)-1:]
+			return fmt.Sprintf("HPA %s scaled out from %d to %d replicas in %s",
+				hpa.Name, lowest.replicas, desired, now.Sub(lowest.at).Round(time.Second))
+		}
+	}
+	return ""
+}
+
+// retain drops the histories of the HPAs not in keys
+func (d *scaleOutDetector) retain(keys map[string]struct{}) {
+	for key := range d.histories {
+		if _, ok := keys[key]; !ok {
+			delete(d.histories, key)
+		}
+	}
+}
+
+// hpaScaleOutSource captures the pods of a workload its HPA scales out
+// rapidly or to its maximum
+type hpaScaleOutSource struct {
+	r        *ProfilingConfigReconciler
+	config   *profilingv1alpha1.ProfilingConfig
+	detector *scaleOutDetector
+}
+
+// Name implements TriggerSource
+func (s *hpaScaleOutSource) Name() string { return "hpa-scale-out" }
+
+// Start implements TriggerSource
+func (s *hpaScaleOutSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource. The first check runs right away
+// to set the baseline of the HPAs.
+func (s *hpaScaleOutSource) InitialDelay() time.Duration { return 0 }
+
+// Check implements ScheduledSource
+func (s *hpaScaleOutSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	s.r.checkHPAScaleOut(ctx, s.config, s.detector, log.FromContext(ctx), emit)
+	if seconds := s.config.Spec.HPAScaleOut.CheckIntervalSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultHPACheckInterval
+}
+
+// checkHPAScaleOut reads the HPAs of the namespaces of the tracked pods and
+// emits capture requests for the pods of the workloads they scaled out
+func (r *ProfilingConfigReconciler) checkHPAScaleOut(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, detector *scaleOutDetector, logger logr.Logger, emit func(CaptureRequest)) {
+	cluster, err := r.clusterOf(config)
+	if err != nil {
+		logger.Error(err, "Failed to check HPA scale-outs")
+		return
+	}
+
+	podsByNamespace := make(map[string][]*corev1.Pod)
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		podsByNamespace[tracked.Pod.Namespace] = append(podsByNamespace[tracked.Pod.Namespace], tracked.Pod)
+	}
+
+	seen := make(map[string]struct{})
+	now := time.Now()
+	for namespace, pods := range podsByNamespace {
+		hpas, err := cluster.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			logger.Error(err, "Failed to list HorizontalPodAutoscalers", "namespace", namespace)
+			// Keep the histories of the namespace until it can be read again
+			for key := range detector.histories {
+				if strings.HasPrefix(key, namespace+"/") {
+					seen[key] = struct{}{}
+				}
+			}
+			continue
+		}
+
+		for i := range hpas.Items {
+			hpa := &hpas.Items[i]
+			seen[hpa.Namespace+"/"+hpa.Name] = struct{}{}
+			reason := detector.observe(hpa, now)
+			if reason == "" {
+				continue
+			}
+			r.captureScaledOut(ctx, config, hpa, scaledPods(pods, hpa), reason, logger, emit)
+		}
+	}
+	detector.retain(seen)
+}
+
+// scaledPods returns the pods of the workload an HPA scales, the longest
+// running first
+func scaledPods(pods []*corev1.Pod, hpa *autoscalingv2.HorizontalPodAutoscaler) []*corev1.Pod {
+	target := hpa.Spec.ScaleTargetRef
+	var scaled []*corev1.Pod
+	for _, pod := range pods {
+		if ownedBy(pod, target.Kind, target.Name) {
+			scaled = append(scaled, pod)
+		}
+	}
+	sort.SliceStable(scaled, func(i, j int) bool {
+		return scaled[i].CreationTimestamp.Before(&scaled[j].CreationTimestamp)
+	})
+	return scaled
+}
+
+// captureScaledOut emits capture requests for up to maxPods pods of a scaled
+// out workload, skipping the pods in cooldown
+func (r *ProfilingConfigReconciler) captureScaledOut(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, hpa *autoscalingv2.HorizontalPodAutoscaler, pods []*corev1.Pod, reason string, logger logr.Logger, emit func(CaptureRequest)) {
+	maxPods := withDefault(config.Spec.HPAScaleOut.MaxPods, defaultHPAMaxPods)
+	captured := 0
+	for _, pod := range pods {
+		if captured == maxPods {
+			break
+		}
+		thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+		if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+			r.suppress(config, metrics.SuppressedCooldown)
+			continue
+		}
+		if r.nodeUnderPressure(ctx, config, pod, logger) {
+			r.suppress(config, metrics.SuppressedNodePressure)
+			continue
+		}
+
+		logger.Info("HPA scaled out, capturing profile", "pod", pod.Name, "hpa", hpa.Name, "reason", reason)
+		emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: reason}, StartCooldown: true})
+		captured++
+	}
+}
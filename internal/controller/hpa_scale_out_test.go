@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// testHPA returns an HPA scaling the my-app Deployment
+func testHPA(desired, maxReplicas int32) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "default"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "my-app"},
+			MaxReplicas:    maxReplicas,
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{DesiredReplicas: desired},
+	}
+}
+
+// testDeploymentPod returns a pod of the my-app Deployment created at a time
+func testDeploymentPod(name string, created time.Time) *corev1.Pod {
+	controller := true
+	pod := createTestPod(name, "default", true)
+	pod.CreationTimestamp = metav1.NewTime(created)
+	pod.Labels["pod-template-hash"] = "7d4b9c"
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "my-app-7d4b9c", Controller: &controller}}
+	return pod
+}
+
+func TestScaleOutDetector(t *testing.T) {
+	onMax := false
+	detector := newScaleOutDetector(&profilingv1alpha1.HPAScaleOutConfig{MinIncreasePercent: 50, WindowSeconds: 300, OnMaxReplicas: &onMax})
+	start := time.Now()
+
+	if reason := detector.observe(testHPA(4, 20), start); reason != "" {
+		t.Errorf("Expected the first observation to set the baseline, got %q", reason)
+	}
+	if reason := detector.observe(testHPA(5, 20), start.Add(time.Minute)); reason != "" {
+		t.Errorf("Expected no capture below the increase, got %q", reason)
+	}
+	reason := detector.observe(testHPA(6, 20), start.Add(2*time.Minute))
+	if !strings.Contains(reason, "scaled out from 4 to 6 replicas in 2m0s") {
+		t.Errorf("Expected a scale-out, got %q", reason)
+	}
+
+	// The scale-out is reported once
+	if reason := detector.observe(testHPA(7, 20), start.Add(3*time.Minute)); reason != "" {
+		t.Errorf("Expected the window to restart, got %q", reason)
+	}
+
+	// Growth slower than the window is not rapid
+	detector.observe(testHPA(8, 20), start.Add(9*time.Minute))
+	if reason := detector.observe(testHPA(9, 20), start.Add(15*time.Minute)); reason != "" {
+		t.Errorf("Expected no capture for slow growth, got %q", reason)
+	}
+
+	// The HPA disappears and comes back with a new baseline
+	detector.retain(map[string]struct{}{})
+	if reason := detector.observe(testHPA(20, 20), start.Add(16*time.Minute)); reason != "" {
+		t.Errorf("Expected a new baseline, got %q", reason)
+	}
+}
+
+func TestScaleOutDetector_MaxReplicas(t *testing.T) {
+	detector := newScaleOutDetector(&profilingv1alpha1.HPAScaleOutConfig{})
+	start := time.Now()
+
+	detector.observe(testHPA(9, 10), start)
+	if reason := detector.observe(testHPA(10, 10), start.Add(time.Minute)); !strings.Contains(reason, "reached its maxReplicas of 10") {
+		t.Errorf("Expected the HPA to reach its maximum, got %q", reason)
+	}
+	if reason := detector.observe(testHPA(10, 10), start.Add(2*time.Minute)); reason != "" {
+		t.Errorf("Expected the maximum to be reported once, got %q", reason)
+	}
+	detector.observe(testHPA(8, 10), start.Add(3*time.Minute))
+	if reason := detector.observe(testHPA(10, 10), start.Add(4*time.Minute)); reason == "" {
+		t.Error("Expected the maximum to be reported again after scaling in")
+	}
+}
+
+func TestCheckHPAScaleOut(t *testing.T) {
+	now := time.Now()
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.HPAScaleOut = &profilingv1alpha1.HPAScaleOutConfig{MaxPods: 2}
+	oldest := testDeploymentPod("my-app-7d4b9c-a", now.Add(-3*time.Hour))
+	older := testDeploymentPod("my-app-7d4b9c-b", now.Add(-2*time.Hour))
+	newest := testDeploymentPod("my-app-7d4b9c-c", now.Add(-time.Minute))
+	other := createTestPod("other", "default", true)
+
+	reconciler := setupTestReconciler(config)
+	for _, pod := range []*corev1.Pod{newest, other, older, oldest} {
+		reconciler.podWatcher.TrackPod(pod, config)
+	}
+	hpa := testHPA(9, 10)
+	clientset := fake.NewSimpleClientset(hpa)
+	reconciler.Clientset = clientset
+
+	var requested []string
+	emit := func(request CaptureRequest) { requested = append(requested, request.Pod.Name) }
+	detector := newScaleOutDetector(config.Spec.HPAScaleOut)
+	ctx := context.Background()
+
+	reconciler.checkHPAScaleOut(ctx, config, detector, log.FromContext(ctx), emit)
+	if len(requested) != 0 {
+		t.Fatalf("Expected the first check to set the baseline, got %v", requested)
+	}
+
+	hpa.Status.DesiredReplicas = 10
+	if _, err := clientset.AutoscalingV2().HorizontalPodAutoscalers("default").UpdateStatus(ctx, hpa, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update HPA: %v", err)
+	}
+	reconciler.checkHPAScaleOut(ctx, config, detector, log.FromContext(ctx), emit)
+	if len(requested) != 2 || requested[0] != oldest.Name || requested[1] != older.Name {
+		t.Errorf("Expected the two longest running pods of the workload, got %v", requested)
+	}
+}
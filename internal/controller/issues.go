@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/notify"
+)
+
+const (
+	// defaultIssueInterval is the minimum time between two issues of a service
+	// when a config sets none
+	defaultIssueInterval = 24 * time.Hour
+
+	// defaultIssueTokenKey is the Secret key read when a reference names none
+	defaultIssueTokenKey = "token"
+)
+
+// issueLimiter spaces the issues filed for each service. The zero value is
+// ready to use.
+type issueLimiter struct {
+	mu sync.Mutex
+
+	// filed holds when an issue was last filed, by config and service
+	filed map[string]time.Time
+}
+
+// reserve reports whether an issue may be filed for a service of a config at
+// now, recording the filing if so
+func (l *issueLimiter) reserve(configKey, service string, interval time.Duration, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := configKey + "|" + service
+	if last, ok := l.filed[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if l.filed == nil {
+		l.filed = make(map[string]time.Time)
+	}
+	l.filed[key] = now
+	return true
+}
+
+// release drops the filing recorded by reserve, so a failed filing is retried
+// on the next capture
+func (l *issueLimiter) release(configKey, service string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.filed, configKey+"|"+service)
+}
+
+// forget drops the filings of a config
+func (l *issueLimiter) forget(configKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.filed {
+		if strings.HasPrefix(key, configKey+"|") {
+			delete(l.filed, key)
+		}
+	}
+}
+
+// fileIssue files an issue for a capture unless one was filed for the same
+// service within the config's interval
+func (r *ProfilingConfigReconciler) fileIssue(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, issues *profilingv1alpha1.IssueNotification, record audit.Record) error {
+	configKey := configKeyOf(config)
+	service := issueService(record)
+	interval := withDefault(time.Duration(issues.MinIntervalSeconds)*time.Second, defaultIssueInterval)
+	if !r.issues.reserve(configKey, service, interval, time.Now()) {
+		return nil
+	}
+
+	err := r.postIssue(ctx, config, issues, record)
+	if err != nil {
+		r.issues.release(configKey, service)
+	}
+	return err
+}
+
+// postIssue files an issue describing a capture in the tracker of a config
+func (r *ProfilingConfigReconciler) postIssue(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, issues *profilingv1alpha1.IssueNotification, record audit.Record) error {
+	token, err := r.secretValue(ctx, config.Namespace, issues.TokenSecretRef, defaultIssueTokenKey)
+	if err != nil {
+		return err
+	}
+
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier := notify.NewIssueNotifier(notify.IssueConfig{
+		Tracker:   string(issues.Tracker),
+		URL:       withDefault(issues.URL, defaultIssueURL(issues.Tracker)),
+		Project:   issues.Project,
+		Token:     token,
+		IssueType: withDefault(issues.IssueType, "Bug"),
+		Labels:    issues.Labels,
+	})
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// defaultIssueURL returns the API base URL of a hosted tracker, empty for Jira
+func defaultIssueURL(tracker profilingv1alpha1.IssueTracker) string {
+	switch tracker {
+	case profilingv1alpha1.IssueTrackerGitHub:
+		return notify.DefaultGitHubURL
+	case profilingv1alpha1.IssueTrackerGitLab:
+		return notify.DefaultGitLabURL
+	default:
+		return ""
+	}
+}
+
+// issueService returns the service issues of a capture are limited by
+func issueService(record audit.Record) string {
+	service := withDefault(record.Service, record.Pod.Name)
+	if record.Pod.Cluster != "" {
+		return record.Pod.Cluster + "/" + record.Pod.Namespace + "/" + service
+	}
+	return record.Pod.Namespace + "/" + service
+}
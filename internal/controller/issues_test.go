@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+func TestIssueLimiter(t *testing.T) {
+	var limiter issueLimiter
+	now := time.Now()
+
+	if !limiter.reserve("default/config", "default/my-app", time.Hour, now) {
+		t.Fatal("Expected the first issue to be allowed")
+	}
+	if limiter.reserve("default/config", "default/my-app", time.Hour, now.Add(30*time.Minute)) {
+		t.Error("Expected a second issue within the interval to be refused")
+	}
+	if !limiter.reserve("default/config", "default/other-app", time.Hour, now.Add(30*time.Minute)) {
+		t.Error("Expected services to be limited separately")
+	}
+	if !limiter.reserve("default/config", "default/my-app", time.Hour, now.Add(time.Hour)) {
+		t.Error("Expected an issue once the interval passed")
+	}
+
+	limiter.release("default/config", "default/my-app")
+	if !limiter.reserve("default/config", "default/my-app", time.Hour, now.Add(time.Hour)) {
+		t.Error("Expected a released filing to be retried")
+	}
+
+	limiter.forget("default/config")
+	if len(limiter.filed) != 0 {
+		t.Errorf("Expected filings to be forgotten, got %v", limiter.filed)
+	}
+}
+
+func TestNotifyCapture_Issues(t *testing.T) {
+	filed, status := 0, http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/my-app/issues" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		filed++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	reconciler := setupTestReconciler()
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github", Namespace: "default"},
+		Data:       map[string][]byte{defaultIssueTokenKey: []byte("ghp_token")},
+	}
+	if _, err := reconciler.Clientset.CoreV1().Secrets("default").Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		Issues: &profilingv1alpha1.IssueNotification{
+			Tracker:        profilingv1alpha1.IssueTrackerGitHub,
+			URL:            server.URL,
+			Project:        "acme/my-app",
+			TokenSecretRef: profilingv1alpha1.SecretKeyRef{Name: "github"},
+		},
+	}
+
+	record := audit.Record{
+		Config:      "default/test-config",
+		TriggeredBy: triggeredByThreshold,
+		Reason:      "cpu threshold exceeded",
+		Pod:         audit.Pod{Namespace: "default", Name: "my-app-0"},
+		Service:     "my-app",
+		Outcome:     audit.OutcomeSucceeded,
+	}
+
+	// A failed filing is retried on the next capture
+	reconciler.notifyCapture(ctx, config, record)
+	status = http.StatusCreated
+	reconciler.notifyCapture(ctx, config, record)
+
+	// Later captures of the service within the interval file nothing
+	other := record
+	other.Pod.Name = "my-app-1"
+	reconciler.notifyCapture(ctx, config, other)
+
+	if filed != 2 {
+		t.Errorf("Expected 2 filing attempts, got %d", filed)
+	}
+}
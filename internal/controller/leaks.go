@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+const (
+	// defaultLeakCaptures is the number of captures a stack must grow across
+	// when a config sets none
+	defaultLeakCaptures = 3
+
+	// defaultMinHeapGrowth is the in-use memory an allocation site must gain
+	// when a config sets no minimum
+	defaultMinHeapGrowth = 1 << 20
+
+	// defaultMinGoroutineGrowth is the number of goroutines a stack must gain
+	// when a config sets no minimum
+	defaultMinGoroutineGrowth = 10
+)
+
+// leakSeries holds the recent profiles of a pod of one type and the leaks
+// detected in them
+type leakSeries struct {
+	stacks []analysis.Stacks
+	leaks  []analysis.Leak
+}
+
+// leakDetector follows the profiles of each pod across captures to detect
+// stacks growing steadily. The zero value is ready to use.
+type leakDetector struct {
+	mu sync.Mutex
+
+	// series holds the profiles of each pod, by config then pod and profile type
+	series map[string]map[string]*leakSeries
+}
+
+// observe adds a profile of a pod to its series, keeping the last captures,
+// and returns the stacks that grew across all of them by at least minGrowth
+func (d *leakDetector) observe(configKey, podKey, profileType string, stacks analysis.Stacks, captures int, minGrowth int64) []analysis.Leak {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.series == nil {
+		d.series = make(map[string]map[string]*leakSeries)
+	}
+	pods, ok := d.series[configKey]
+	if !ok {
+		pods = make(map[string]*leakSeries)
+		d.series[configKey] = pods
+	}
+	key := podKey + "|" + profileType
+	series, ok := pods[key]
+	if !ok {
+		series = &leakSeries{}
+		pods[key] = series
+	}
+
+	series.stacks = append(series.stacks, stacks)
+	if len(series.stacks) > captures {
+		series.stacks = series.stacks[len(series.stacks)-captures:]
+	}
+	series.leaks = nil
+	if len(series.stacks) == captures {
+		series.leaks = analysis.DetectLeaks(profileType, series.stacks, minGrowth)
+	}
+	return series.leaks
+}
+
+// leakingPods returns the keys of the pods of a config whose last captures
+// showed leaks of a profile type
+func (d *leakDetector) leakingPods(configKey, profileType string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var pods []string
+	for key, series := range d.series[configKey] {
+		podKey, seriesType, _ := strings.Cut(key, "|")
+		if seriesType == profileType && len(series.leaks) > 0 {
+			pods = append(pods, podKey)
+		}
+	}
+	sort.Strings(pods)
+	return pods
+}
+
+// retain drops the series of pods that are not in podKeys
+func (d *leakDetector) retain(podKeys map[string]struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, pods := range d.series {
+		for key := range pods {
+			podKey, _, _ := strings.Cut(key, "|")
+			if _, ok := podKeys[podKey]; !ok {
+				delete(pods, key)
+			}
+		}
+	}
+}
+
+// forget drops the series of a config
+func (d *leakDetector) forget(configKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.series, configKey)
+}
+
+// detectLeaks adds the heap and goroutine profiles of a capture to the pod's
+// series when the config enables leak detection, returning the probable leaks
+func (r *ProfilingConfigReconciler) detectLeaks(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, profiles []profiler.Profile) []analysis.Leak {
+	detection := config.Spec.LeakDetection
+	if detection == nil {
+		return nil
+	}
+
+	captures := withDefault(detection.Captures, defaultLeakCaptures)
+	minGrowth := map[string]int64{
+		"heap":      defaultMinHeapGrowth,
+		"goroutine": int64(withDefault(detection.MinGoroutineGrowth, defaultMinGoroutineGrowth)),
+	}
+	if detection.MinHeapGrowth != nil {
+		minGrowth["heap"] = detection.MinHeapGrowth.Value()
+	}
+
+	var leaks []analysis.Leak
+	for _, profile := range profiles {
+		threshold, ok := minGrowth[profile.Type]
+		if !ok {
+			continue
+		}
+		stacks, err := analysis.StackValues(profile.Type, profile.Data)
+		if err != nil {
+			continue
+		}
+		leaks = append(leaks, r.leaks.observe(configKeyOf(config), r.podWatcher.getPodKey(pod), profile.Type, stacks, captures, threshold)...)
+	}
+	return leaks
+}
+
+// reportLeaks records a warning event on the config for the leaks detected in
+// a capture of pod
+func (r *ProfilingConfigReconciler) reportLeaks(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, leaks []analysis.Leak) {
+	for _, leak := range leaks {
+		r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonProbableLeak,
+			"Probable %s leak in pod %s: %s grew by %s over %d captures",
+			leak.Type, r.podWatcher.getPodKey(pod), leak.Site(), leak.FormatGrowth(), len(leak.Values))
+	}
+}
+
+// setLeakCondition reports whether the last captures of any pod showed a heap
+// or goroutine leak. The condition is removed when leak detection is disabled.
+func setLeakCondition(config *profilingv1alpha1.ProfilingConfig, heapPods, goroutinePods []string) {
+	if config.Spec.LeakDetection == nil {
+		meta.RemoveStatusCondition(&config.Status.Conditions, ConditionProbableLeak)
+		return
+	}
+
+	var messages []string
+	reason := reasonHeapGrowth
+	if len(heapPods) > 0 {
+		messages = append(messages, "In-use heap grew across the last captures of pods "+strings.Join(heapPods, ", "))
+	}
+	if len(goroutinePods) > 0 {
+		if len(heapPods) == 0 {
+			reason = reasonGoroutineGrowth
+		}
+		messages = append(messages, "Goroutines piled up across the last captures of pods "+strings.Join(goroutinePods, ", "))
+	}
+
+	if len(messages) == 0 {
+		setCondition(config, ConditionProbableLeak, metav1.ConditionFalse, reasonNoLeaks,
+			"No stack grew across the last captures")
+		return
+	}
+	setCondition(config, ConditionProbableLeak, metav1.ConditionTrue, reason, strings.Join(messages, "; "))
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+)
+
+func TestLeakDetector(t *testing.T) {
+	var detector leakDetector
+	heap := func(inuse int64) analysis.Stacks {
+		return analysis.Stacks{SampleType: "inuse_space", Unit: "bytes", Values: map[string]int64{"main.cache": inuse}}
+	}
+
+	for i, inuse := range []int64{1 << 20, 2 << 20} {
+		if leaks := detector.observe("default/config", "default/pod-1", "heap", heap(inuse), 3, 1<<20); len(leaks) != 0 {
+			t.Errorf("Expected no leaks after %d captures, got %+v", i+1, leaks)
+		}
+	}
+	leaks := detector.observe("default/config", "default/pod-1", "heap", heap(4<<20), 3, 1<<20)
+	if len(leaks) != 1 || leaks[0].Growth != 3<<20 {
+		t.Fatalf("Expected a leak of 3MiB after 3 growing captures, got %+v", leaks)
+	}
+	if pods := detector.leakingPods("default/config", "heap"); len(pods) != 1 || pods[0] != "default/pod-1" {
+		t.Errorf("Expected pod-1 to be leaking, got %v", pods)
+	}
+
+	// A shrinking capture clears the leak; only the last captures are compared
+	if leaks := detector.observe("default/config", "default/pod-1", "heap", heap(3<<20), 3, 1<<20); len(leaks) != 0 {
+		t.Errorf("Expected no leaks after the heap shrank, got %+v", leaks)
+	}
+	if pods := detector.leakingPods("default/config", "heap"); len(pods) != 0 {
+		t.Errorf("Expected no leaking pods, got %v", pods)
+	}
+
+	detector.retain(map[string]struct{}{})
+	if len(detector.series["default/config"]) != 0 {
+		t.Errorf("Expected the series of untracked pods to be dropped, got %v", detector.series)
+	}
+
+	detector.forget("default/config")
+	if _, ok := detector.series["default/config"]; ok {
+		t.Error("Expected the config to be forgotten")
+	}
+}
+
+func TestSetLeakCondition(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.LeakDetection = &profilingv1alpha1.LeakDetectionConfig{}
+
+	setLeakCondition(config, []string{"default/pod-1"}, nil)
+	condition := meta.FindStatusCondition(config.Status.Conditions, ConditionProbableLeak)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonHeapGrowth {
+		t.Fatalf("Expected a true ProbableLeak condition, got %+v", condition)
+	}
+
+	setLeakCondition(config, nil, []string{"default/pod-2"})
+	condition = meta.FindStatusCondition(config.Status.Conditions, ConditionProbableLeak)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonGoroutineGrowth {
+		t.Fatalf("Expected a true ProbableLeak condition for goroutines, got %+v", condition)
+	}
+
+	setLeakCondition(config, nil, nil)
+	condition = meta.FindStatusCondition(config.Status.Conditions, ConditionProbableLeak)
+	if condition == nil || condition.Status != metav1.ConditionFalse {
+		t.Errorf("Expected a false ProbableLeak condition, got %+v", condition)
+	}
+
+	config.Spec.LeakDetection = nil
+	setLeakCondition(config, nil, nil)
+	if meta.FindStatusCondition(config.Status.Conditions, ConditionProbableLeak) != nil {
+		t.Error("Expected the condition to be removed when leak detection is disabled")
+	}
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+const (
+	// lifecycleRuleInterval is how often the lifecycle rule of a config is put
+	// again while it is unchanged, restoring it if it was removed by hand
+	lifecycleRuleInterval = time.Hour
+
+	// lifecycleRuleTimeout bounds a change of the lifecycle rules of a bucket,
+	// which runs in the reconcile
+	lifecycleRuleTimeout = 10 * time.Second
+)
+
+// lifecycleRules remembers the lifecycle rule last put for each config, and
+// serializes the changes, as each rewrites the whole lifecycle configuration
+// of a bucket that configs may share. The zero value is ready to use.
+type lifecycleRules struct {
+	mu sync.Mutex
+
+	// put holds the rule last put and where and when, by config
+	put map[string]lifecycleRulePut
+
+	// changes is held while the rules of a bucket are changed
+	changes sync.Mutex
+}
+
+// lifecycleRulePut is a lifecycle rule put in the bucket of a config
+type lifecycleRulePut struct {
+	s3Config profilingv1alpha1.S3Configuration
+	rule     uploader.LifecycleRule
+	at       time.Time
+}
+
+// due reports whether the lifecycle rule of a config should be put at now,
+// which it is when the rule or its destination changed or the interval
+// passed, recording the attempt if so
+func (l *lifecycleRules) due(configKey string, s3Config profilingv1alpha1.S3Configuration, rule uploader.LifecycleRule, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.put[configKey]; ok && last.rule == rule &&
+		equality.Semantic.DeepEqual(last.s3Config, s3Config) && now.Sub(last.at) < lifecycleRuleInterval {
+		return false
+	}
+	if l.put == nil {
+		l.put = make(map[string]lifecycleRulePut)
+	}
+	l.put[configKey] = lifecycleRulePut{s3Config: s3Config, rule: rule, at: now}
+	return true
+}
+
+// forget drops the rule put for a config
+func (l *lifecycleRules) forget(configKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.put, configKey)
+}
+
+// lifecycleRuleID returns the ID of the lifecycle rule of a config
+func lifecycleRuleID(config *profilingv1alpha1.ProfilingConfig) string {
+	return fmt.Sprintf("bolometer-%s-%s", config.Namespace, config.Name)
+}
+
+// staticPrefixOf returns the part of the prefix of a config shared by the
+// objects of every pod. {namespace} only counts as shared when the config
+// selects a single namespace.
+func staticPrefixOf(config *profilingv1alpha1.ProfilingConfig) (string, error) {
+	values := prefixValuesOf(config)
+	values.Namespace = config.Spec.Selector.Namespace
+	return uploader.StaticPrefix(config.Spec.S3Config.Prefix, values)
+}
+
+// lifecycleRuleOf returns the lifecycle rule of a config, for the objects
+// under the part of its prefix shared by every pod
+func lifecycleRuleOf(config *profilingv1alpha1.ProfilingConfig) (uploader.LifecycleRule, error) {
+	lifecycle := config.Spec.S3Config.Lifecycle
+	prefix, err := staticPrefixOf(config)
+	if err != nil {
+		return uploader.LifecycleRule{}, err
+	}
+
+	rule := uploader.LifecycleRule{
+		ID:             lifecycleRuleID(config),
+		Prefix:         prefix,
+		TransitionDays: lifecycle.TransitionDays,
+		ExpirationDays: lifecycle.ExpirationDays,
+	}
+	if rule.TransitionDays > 0 {
+		rule.TransitionStorageClass = withDefault(lifecycle.TransitionStorageClass, "STANDARD_IA")
+	}
+	return rule, nil
+}
+
+// validateLifecycle validates the lifecycle block of a config
+func validateLifecycle(config *profilingv1alpha1.ProfilingConfig) error {
+	lifecycle := config.Spec.S3Config.Lifecycle
+	if lifecycle == nil {
+		return nil
+	}
+	if lifecycle.TransitionDays <= 0 && lifecycle.ExpirationDays <= 0 {
+		return fmt.Errorf("s3 lifecycle needs transitionDays or expirationDays")
+	}
+	if lifecycle.TransitionDays > 0 && lifecycle.ExpirationDays > 0 && lifecycle.TransitionDays >= lifecycle.ExpirationDays {
+		return fmt.Errorf("s3 lifecycle transitionDays must be less than expirationDays")
+	}
+	if _, err := lifecycleRuleOf(config); err != nil {
+		return fmt.Errorf("s3 lifecycle: %w", err)
+	}
+	return nil
+}
+
+// applyLifecycle puts the lifecycle rule of a config in its bucket, or
+// removes the rule once the lifecycle block is dropped. The rule is kept when
+// the config is deleted, so its objects still expire. A failure does not stop
+// monitoring but records a warning event and the last error.
+func (r *ProfilingConfigReconciler) applyLifecycle(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+	configKey := configKeyOf(config)
+	if config.Spec.S3Config.Lifecycle == nil {
+		if config.Status.LifecycleRule == "" {
+			r.lifecycleRules.forget(configKey)
+			return
+		}
+		// Removing the rule is attempted like putting one, so a failure is
+		// not reported on every reconcile
+		id := config.Status.LifecycleRule
+		if !r.lifecycleRules.due(configKey, config.Spec.S3Config, uploader.LifecycleRule{ID: id}, time.Now()) {
+			return
+		}
+		changed, err := r.changeLifecycle(ctx, config, func(ctx context.Context, manager uploader.LifecycleManager) error {
+			return manager.DeleteLifecycleRule(ctx, id)
+		})
+		if err != nil {
+			r.lifecycleFailed(ctx, config, err)
+			return
+		}
+		if changed {
+			config.Status.LifecycleRule = ""
+			r.lifecycleRules.forget(configKey)
+		}
+		return
+	}
+
+	rule, err := lifecycleRuleOf(config)
+	if err != nil {
+		// Rejected by validation
+		return
+	}
+	if !r.lifecycleRules.due(configKey, config.Spec.S3Config, rule, time.Now()) {
+		return
+	}
+
+	changed, err := r.changeLifecycle(ctx, config, func(ctx context.Context, manager uploader.LifecycleManager) error {
+		return manager.PutLifecycleRule(ctx, rule)
+	})
+	if err != nil {
+		r.lifecycleFailed(ctx, config, err)
+		return
+	}
+	if changed {
+		config.Status.LifecycleRule = rule.ID
+	}
+}
+
+// changeLifecycle changes the lifecycle rules of the bucket of a config with
+// its uploader, reporting false if the uploader cannot maintain rules
+func (r *ProfilingConfigReconciler) changeLifecycle(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, change func(context.Context, uploader.LifecycleManager) error) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, lifecycleRuleTimeout)
+	defer cancel()
+
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return true, err
+	}
+	storage, err := r.uploaders(ctx, s3Config)
+	if err != nil {
+		return true, fmt.Errorf("failed to create uploader: %w", err)
+	}
+	manager, ok := storage.(uploader.LifecycleManager)
+	if !ok {
+		return false, nil
+	}
+
+	r.lifecycleRules.changes.Lock()
+	defer r.lifecycleRules.changes.Unlock()
+	return true, change(ctx, manager)
+}
+
+// lifecycleFailed reports a failed change of the lifecycle rule of a config
+func (r *ProfilingConfigReconciler) lifecycleFailed(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, err error) {
+	log.FromContext(ctx).Error(err, "Failed to apply lifecycle rule", "bucket", config.Spec.S3Config.Bucket)
+	r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonLifecycleFailed,
+		"Failed to apply the lifecycle rule of the bucket: %v", err)
+	setLastError(config, err)
+}
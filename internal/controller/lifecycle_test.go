@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestLifecycleRuleOf(t *testing.T) {
+	config := createTestProfilingConfig("api", "default")
+	config.Spec.Selector.Namespace = ""
+	config.Spec.S3Config.Prefix = "{cluster}/{namespace}/{configName}"
+	config.Spec.S3Config.Lifecycle = &profilingv1alpha1.LifecycleConfig{TransitionDays: 30, ExpirationDays: 90}
+
+	// Every namespace is selected, so the rule covers the cluster only
+	rule, err := lifecycleRuleOf(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := uploader.LifecycleRule{
+		ID:                     "bolometer-default-api",
+		Prefix:                 "local/",
+		TransitionDays:         30,
+		TransitionStorageClass: "STANDARD_IA",
+		ExpirationDays:         90,
+	}
+	if rule != expected {
+		t.Errorf("Expected %+v, got %+v", expected, rule)
+	}
+
+	config.Spec.Selector.Namespace = "team-a"
+	if rule, _ := lifecycleRuleOf(config); rule.Prefix != "local/team-a/api/" {
+		t.Errorf("Expected the prefix of the selected namespace, got %q", rule.Prefix)
+	}
+}
+
+func TestValidateConfig_Lifecycle(t *testing.T) {
+	reconciler := setupTestReconciler()
+
+	tests := []struct {
+		name      string
+		prefix    string
+		lifecycle *profilingv1alpha1.LifecycleConfig
+		wantErr   bool
+	}{
+		{name: "transition and expiration", lifecycle: &profilingv1alpha1.LifecycleConfig{TransitionDays: 30, ExpirationDays: 90}},
+		{name: "expiration only", lifecycle: &profilingv1alpha1.LifecycleConfig{ExpirationDays: 7}},
+		{name: "no days", lifecycle: &profilingv1alpha1.LifecycleConfig{}, wantErr: true},
+		{name: "transition after expiration", lifecycle: &profilingv1alpha1.LifecycleConfig{TransitionDays: 90, ExpirationDays: 30}, wantErr: true},
+		{name: "prefix of every namespace", prefix: "{namespace}/profiles", lifecycle: &profilingv1alpha1.LifecycleConfig{ExpirationDays: 7}, wantErr: true},
+		{name: "no lifecycle", prefix: "{namespace}/profiles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestProfilingConfig("api", "default")
+			config.Spec.Selector.Namespace = ""
+			if tt.prefix != "" {
+				config.Spec.S3Config.Prefix = tt.prefix
+			}
+			config.Spec.S3Config.Lifecycle = tt.lifecycle
+			err := reconciler.validateConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyLifecycle(t *testing.T) {
+	config := createTestProfilingConfig("api", "default")
+	config.Spec.S3Config.Lifecycle = &profilingv1alpha1.LifecycleConfig{ExpirationDays: 30}
+	reconciler := setupTestReconciler(config)
+	storage := &uploader.Fake{Bucket: "test-bucket", LifecycleErr: errors.New("AccessDenied")}
+	reconciler.uploaders = func(context.Context, uploader.S3Config) (uploader.Uploader, error) {
+		return storage, nil
+	}
+	ctx := context.Background()
+
+	reconciler.applyLifecycle(ctx, config)
+	if config.Status.LifecycleRule != "" || config.Status.LastErrorMessage == "" {
+		t.Fatalf("Expected the failure to be recorded, got rule %q and error %q",
+			config.Status.LifecycleRule, config.Status.LastErrorMessage)
+	}
+
+	// The rule is put again once the lifecycle changes
+	storage.LifecycleErr = nil
+	config.Spec.S3Config.Lifecycle.ExpirationDays = 14
+	reconciler.applyLifecycle(ctx, config)
+	rule, ok := storage.LifecycleRules()["bolometer-default-api"]
+	if !ok || rule.Prefix != "profiles/" || rule.ExpirationDays != 14 {
+		t.Fatalf("Expected the rule to be put, got %+v", storage.LifecycleRules())
+	}
+	if config.Status.LifecycleRule != "bolometer-default-api" {
+		t.Errorf("Expected the rule in the status, got %q", config.Status.LifecycleRule)
+	}
+
+	// Dropping the lifecycle removes the rule
+	config.Spec.S3Config.Lifecycle = nil
+	reconciler.applyLifecycle(ctx, config)
+	if len(storage.LifecycleRules()) != 0 || config.Status.LifecycleRule != "" {
+		t.Errorf("Expected the rule to be removed, got %+v", storage.LifecycleRules())
+	}
+}
+
+func TestLifecycleRules_Due(t *testing.T) {
+	var rules lifecycleRules
+	s3Config := profilingv1alpha1.S3Configuration{Bucket: "test-bucket"}
+	rule := uploader.LifecycleRule{ID: "bolometer-default-api", Prefix: "profiles/", ExpirationDays: 30}
+	now := time.Now()
+
+	if !rules.due("default/api", s3Config, rule, now) {
+		t.Error("Expected the first put to be due")
+	}
+	if rules.due("default/api", s3Config, rule, now.Add(time.Minute)) {
+		t.Error("Expected no put within the interval")
+	}
+	rule.ExpirationDays = 14
+	if !rules.due("default/api", s3Config, rule, now.Add(2*time.Minute)) {
+		t.Error("Expected a put once the rule changed")
+	}
+	if !rules.due("default/api", s3Config, rule, now.Add(lifecycleRuleInterval+3*time.Minute)) {
+		t.Error("Expected a put once the interval passed")
+	}
+}
@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+const (
+	// DefaultMonitorRestartDelay is the initial delay before a failed monitor task is restarted
+	DefaultMonitorRestartDelay = time.Second
+
+	// maxMonitorRestartDelay caps the restart backoff of a repeatedly failing task
+	maxMonitorRestartDelay = time.Minute
+
+	// unhealthyTaskFailures is the number of consecutive failures after which a
+	// task is reported unhealthy
+	unhealthyTaskFailures = 3
+
+	// healthyTaskRun is how long a task must run without failing to be healthy again
+	healthyTaskRun = 2 * maxMonitorRestartDelay
+)
+
+// MonitorTask is a named long-running function supervised by the MonitorManager.
+// Run must return when its context is cancelled.
+type MonitorTask struct {
+	Name string
+	Run  func(ctx context.Context)
+}
+
+// MonitorInfo describes a running monitor
+type MonitorInfo struct {
+	Key       string
+	Hash      string
+	Tasks     []string
+	StartedAt time.Time
+	Restarts  int
+}
+
+// monitor is the set of tasks running for a single key
+type monitor struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	hash      string
+	tasks     []string
+	startedAt time.Time
+	restarts  int
+
+	// failures tracks the consecutive failures of each task
+	failures map[string]*taskFailures
+}
+
+// taskFailures counts the failures of a task that did not run long enough in between
+type taskFailures struct {
+	consecutive int
+	last        time.Time
+	lastErr     error
+}
+
+// MonitorManager runs and supervises the monitoring goroutines of each config.
+// Tasks that panic or return before they are stopped are restarted with backoff.
+type MonitorManager struct {
+	mu           sync.Mutex
+	monitors     map[string]*monitor
+	restartDelay time.Duration
+	logger       logr.Logger
+
+	// panics counts the panics of the tasks of each key
+	panics *metrics.Panics
+}
+
+// NewMonitorManager creates a new monitor manager
+func NewMonitorManager() *MonitorManager {
+	return &MonitorManager{
+		monitors:     make(map[string]*monitor),
+		restartDelay: DefaultMonitorRestartDelay,
+		logger:       ctrl.Log.WithName("monitors"),
+		panics:       metrics.NewPanics(),
+	}
+}
+
+// CountPanics counts the panics of tasks with panics, e.g. counters registered
+// with the metrics endpoint
+func (m *MonitorManager) CountPanics(panics *metrics.Panics) {
+	m.panics = panics
+}
+
+// Start runs the tasks under key, replacing any monitor already running for it.
+// hash identifies the configuration the tasks were started with.
+func (m *MonitorManager) Start(parentCtx context.Context, key, hash string, tasks ...MonitorTask) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.monitors[key]; ok {
+		existing.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(parentCtx)
+	mon := &monitor{
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		hash:      hash,
+		startedAt: time.Now(),
+		failures:  make(map[string]*taskFailures),
+	}
+	for _, task := range tasks {
+		mon.tasks = append(mon.tasks, task.Name)
+	}
+	m.monitors[key] = mon
+
+	var wg sync.WaitGroup
+	wg.Add(len(tasks))
+	for _, task := range tasks {
+		go func(task MonitorTask) {
+			defer wg.Done()
+			m.supervise(ctx, key, mon, task)
+		}(task)
+	}
+
+	go func() {
+		wg.Wait()
+		close(mon.done)
+	}()
+}
+
+// Stop cancels the monitor running under key, if any. The returned channel is
+// closed once all of its tasks have returned.
+func (m *MonitorManager) Stop(key string) <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mon, ok := m.monitors[key]
+	if !ok {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+
+	mon.cancel()
+	delete(m.monitors, key)
+	return mon.done
+}
+
+// StopAll cancels every running monitor
+func (m *MonitorManager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, mon := range m.monitors {
+		mon.cancel()
+		delete(m.monitors, key)
+	}
+}
+
+// IsRunning reports whether a monitor is running under key
+func (m *MonitorManager) IsRunning(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.monitors[key]
+	return ok
+}
+
+// Hash returns the hash the monitor under key was started with, and whether
+// a monitor is running
+func (m *MonitorManager) Hash(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mon, ok := m.monitors[key]
+	if !ok {
+		return "", false
+	}
+	return mon.hash, true
+}
+
+// List returns the running monitors sorted by key
+func (m *MonitorManager) List() []MonitorInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]MonitorInfo, 0, len(m.monitors))
+	for key, mon := range m.monitors {
+		infos = append(infos, MonitorInfo{
+			Key:       key,
+			Hash:      mon.hash,
+			Tasks:     append([]string(nil), mon.tasks...),
+			StartedAt: mon.startedAt,
+			Restarts:  mon.restarts,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+
+	return infos
+}
+
+// supervise runs a task until its context is cancelled, restarting it with
+// exponential backoff whenever it panics or returns early
+func (m *MonitorManager) supervise(ctx context.Context, key string, mon *monitor, task MonitorTask) {
+	logger := m.logger.WithValues("monitor", key, "task", task.Name)
+	delay := m.restartDelay
+
+	for {
+		startedAt := time.Now()
+		err := runTask(ctx, task)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if panicked, ok := asPanic(err); ok {
+			m.panics.Inc(key, task.Name)
+			logger.Error(err, "Monitor task panicked, restarting", "delay", delay, "stack", string(panicked.stack))
+		} else if err != nil {
+			logger.Error(err, "Monitor task failed, restarting", "delay", delay)
+		} else {
+			logger.Info("Monitor task exited unexpectedly, restarting", "delay", delay)
+			err = fmt.Errorf("monitor task %s exited unexpectedly", task.Name)
+		}
+
+		m.mu.Lock()
+		mon.restarts++
+		m.recordFailureLocked(mon, task.Name, err, time.Since(startedAt))
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxMonitorRestartDelay)
+	}
+}
+
+// recordFailureLocked records a task failure, resetting the consecutive count if
+// the task ran long enough before failing (must be called with lock held)
+func (m *MonitorManager) recordFailureLocked(mon *monitor, task string, err error, ran time.Duration) {
+	failures, ok := mon.failures[task]
+	if !ok || ran >= healthyTaskRun {
+		failures = &taskFailures{}
+		mon.failures[task] = failures
+	}
+
+	failures.consecutive++
+	failures.last = time.Now()
+	failures.lastErr = err
+}
+
+// Check reports an error while any monitor task keeps failing, i.e. it failed
+// repeatedly without running for long in between. It implements healthz.Checker.
+func (m *MonitorManager) Check(_ *http.Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.monitors))
+	for key := range m.monitors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		mon := m.monitors[key]
+		for _, task := range mon.tasks {
+			failures, ok := mon.failures[task]
+			if !ok || failures.consecutive < unhealthyTaskFailures || time.Since(failures.last) >= healthyTaskRun {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("monitor %s task %s failed %d times in a row: %w",
+				key, task, failures.consecutive, failures.lastErr))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runTask runs a task, converting a panic into a *panicError
+func runTask(ctx context.Context, task MonitorTask) (err error) {
+	defer recoverPanic("monitor task "+task.Name, &err)
+
+	task.Run(ctx)
+	return nil
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for condition")
+}
+
+func blockingTask(name string, started *atomic.Int32) MonitorTask {
+	return MonitorTask{
+		Name: name,
+		Run: func(ctx context.Context) {
+			started.Add(1)
+			<-ctx.Done()
+		},
+	}
+}
+
+func TestMonitorManager_StartStop(t *testing.T) {
+	manager := NewMonitorManager()
+	var started atomic.Int32
+
+	manager.Start(context.Background(), "default/config", "hash", blockingTask("a", &started), blockingTask("b", &started))
+	waitFor(t, time.Second, func() bool { return started.Load() == 2 })
+
+	if !manager.IsRunning("default/config") {
+		t.Fatal("Expected monitor to be running")
+	}
+
+	running := manager.List()
+	if len(running) != 1 || len(running[0].Tasks) != 2 {
+		t.Errorf("Expected one monitor with two tasks, got %+v", running)
+	}
+
+	done := manager.Stop("default/config")
+	if manager.IsRunning("default/config") {
+		t.Error("Expected monitor to be stopped")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected tasks to return after Stop")
+	}
+
+	// Stopping an unknown monitor is a no-op and reports done immediately
+	select {
+	case <-manager.Stop("default/unknown"):
+	default:
+		t.Error("Expected closed channel for unknown monitor")
+	}
+}
+
+func TestMonitorManager_StartReplacesExisting(t *testing.T) {
+	manager := NewMonitorManager()
+	var cancelled atomic.Bool
+
+	manager.Start(context.Background(), "default/config", "hash", MonitorTask{
+		Name: "first",
+		Run: func(ctx context.Context) {
+			<-ctx.Done()
+			cancelled.Store(true)
+		},
+	})
+
+	var started atomic.Int32
+	manager.Start(context.Background(), "default/config", "hash", blockingTask("second", &started))
+
+	waitFor(t, time.Second, cancelled.Load)
+
+	running := manager.List()
+	if len(running) != 1 || running[0].Tasks[0] != "second" {
+		t.Errorf("Expected replacement monitor, got %+v", running)
+	}
+
+	manager.StopAll()
+	if len(manager.List()) != 0 {
+		t.Error("Expected no monitors after StopAll")
+	}
+}
+
+func TestMonitorManager_RestartsOnPanic(t *testing.T) {
+	manager := NewMonitorManager()
+	manager.restartDelay = time.Millisecond
+	var runs atomic.Int32
+
+	manager.Start(context.Background(), "default/config", "hash", MonitorTask{
+		Name: "flaky",
+		Run: func(ctx context.Context) {
+			if runs.Add(1) < 3 {
+				panic("boom")
+			}
+			<-ctx.Done()
+		},
+	})
+	defer manager.StopAll()
+
+	waitFor(t, time.Second, func() bool { return runs.Load() == 3 })
+
+	running := manager.List()
+	if len(running) != 1 || running[0].Restarts != 2 {
+		t.Errorf("Expected 2 restarts, got %+v", running)
+	}
+	if count := testutil.ToFloat64(manager.panics); count != 2 {
+		t.Errorf("Expected 2 panics counted, got %v", count)
+	}
+}
+
+func TestMonitorManager_Check(t *testing.T) {
+	manager := NewMonitorManager()
+	manager.restartDelay = time.Millisecond
+	var runs atomic.Int32
+
+	manager.Start(context.Background(), "default/config", "hash", MonitorTask{
+		Name: "failing",
+		Run: func(ctx context.Context) {
+			if runs.Add(1) <= unhealthyTaskFailures {
+				panic("boom")
+			}
+			<-ctx.Done()
+		},
+	})
+	defer manager.StopAll()
+
+	waitFor(t, time.Second, func() bool { return runs.Load() > unhealthyTaskFailures })
+
+	err := manager.Check(nil)
+	if err == nil {
+		t.Fatal("Expected a repeatedly failing task to be reported unhealthy")
+	}
+	if !strings.Contains(err.Error(), "default/config") || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected error to name the monitor and the failure, got %v", err)
+	}
+
+	// A task that has been running long enough since its last failure is healthy again
+	manager.mu.Lock()
+	manager.monitors["default/config"].failures["failing"].last = time.Now().Add(-healthyTaskRun)
+	manager.mu.Unlock()
+
+	if err := manager.Check(nil); err != nil {
+		t.Errorf("Expected recovered task to be healthy, got %v", err)
+	}
+
+	// Stopped monitors are not reported
+	<-manager.Stop("default/config")
+	if err := manager.Check(nil); err != nil {
+		t.Errorf("Expected no error without monitors, got %v", err)
+	}
+}
+
+func TestMonitorManager_ConcurrentAccess(t *testing.T) {
+	manager := NewMonitorManager()
+	var started atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "default/config"
+			if i%2 == 0 {
+				manager.Start(context.Background(), key, "hash", blockingTask("task", &started))
+			} else {
+				manager.Stop(key)
+			}
+			_ = manager.List()
+			_ = manager.IsRunning(key)
+		}(i)
+	}
+
+	wg.Wait()
+	manager.StopAll()
+}
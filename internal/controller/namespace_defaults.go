@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+const (
+	// DefaultConfigAnnotation on a Namespace names the ProfilingConfig template
+	// applied to the annotated pods of the namespace
+	DefaultConfigAnnotation = "bolometer.io/default-config"
+
+	// TemplateLabel marks a ProfilingConfig as a template. Templates do not
+	// profile pods themselves.
+	TemplateLabel = "bolometer.io/template"
+
+	// DefaultConfigLabel marks the ProfilingConfigs created from a template,
+	// holding the template's name
+	DefaultConfigLabel = "bolometer.io/default-config"
+)
+
+// errNotGenerated is returned when a namespace already holds a ProfilingConfig
+// of a template's name that was not created from the template
+var errNotGenerated = errors.New("a ProfilingConfig of the same name that was not created from the template exists")
+
+// isTemplate reports whether a ProfilingConfig is a template
+func isTemplate(config *profilingv1alpha1.ProfilingConfig) bool {
+	return config.Labels[TemplateLabel] == "true"
+}
+
+// NamespaceDefaultsReconciler creates a ProfilingConfig in every Namespace
+// annotated with DefaultConfigAnnotation from the named template, and deletes
+// it once the annotation or the template is removed
+type NamespaceDefaultsReconciler struct {
+	client.Client
+
+	// TemplateNamespace holds the ProfilingConfig templates
+	TemplateNamespace string
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Reconcile applies the template named by a Namespace's annotation
+func (r *NamespaceDefaultsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, req.NamespacedName, namespace); err != nil {
+		// Deleting a namespace deletes its configs
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	template, err := r.templateOf(ctx, namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Delete the configs of templates the namespace no longer applies
+	wanted := ""
+	if template != nil {
+		wanted = template.Name
+	}
+	generated := &profilingv1alpha1.ProfilingConfigList{}
+	if err := r.List(ctx, generated, client.InNamespace(namespace.Name), client.HasLabels{DefaultConfigLabel}); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range generated.Items {
+		config := &generated.Items[i]
+		if config.Labels[DefaultConfigLabel] == wanted {
+			continue
+		}
+		if err := r.Delete(ctx, config); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("Deleted default ProfilingConfig", "namespace", namespace.Name, "config", config.Name)
+	}
+
+	if template == nil {
+		return ctrl.Result{}, nil
+	}
+
+	config := &profilingv1alpha1.ProfilingConfig{}
+	config.Name = template.Name
+	config.Namespace = namespace.Name
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, config, func() error {
+		if config.ResourceVersion != "" && config.Labels[DefaultConfigLabel] != template.Name {
+			return errNotGenerated
+		}
+		if config.Labels == nil {
+			config.Labels = make(map[string]string)
+		}
+		config.Labels[DefaultConfigLabel] = template.Name
+		config.Spec = defaultConfigSpec(template)
+		return controllerutil.SetControllerReference(namespace, config, r.Scheme())
+	})
+	if errors.Is(err, errNotGenerated) {
+		// Leave the config alone, deleting it lets the template apply
+		logger.Info("Not applying default ProfilingConfig", "namespace", namespace.Name, "config", config.Name, "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("Applied default ProfilingConfig", "namespace", namespace.Name, "config", config.Name, "operation", op)
+	}
+	return ctrl.Result{}, nil
+}
+
+// templateOf returns the template a namespace applies, nil if it applies none
+// or the template does not exist
+func (r *NamespaceDefaultsReconciler) templateOf(ctx context.Context, namespace *corev1.Namespace) (*profilingv1alpha1.ProfilingConfig, error) {
+	name := namespace.Annotations[DefaultConfigAnnotation]
+	if name == "" || namespace.Name == r.TemplateNamespace || !namespace.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	template := &profilingv1alpha1.ProfilingConfig{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.TemplateNamespace, Name: name}, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Info("Default ProfilingConfig template not found", "namespace", namespace.Name, "template", name)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !isTemplate(template) || !template.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return template, nil
+}
+
+// defaultConfigSpec returns the spec of the config a template creates, selecting
+// pods in the config's own namespace of the operator's own cluster
+func defaultConfigSpec(template *profilingv1alpha1.ProfilingConfig) profilingv1alpha1.ProfilingConfigSpec {
+	spec := template.Spec.DeepCopy()
+	spec.Selector.Namespace = ""
+	spec.Cluster = nil
+	return *spec
+}
+
+// namespacesForTemplate maps a template event to the namespaces applying it
+func (r *NamespaceDefaultsReconciler) namespacesForTemplate(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != r.TemplateNamespace {
+		return nil
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list Namespaces for template", "template", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, namespace := range namespaces.Items {
+		if namespace.Annotations[DefaultConfigAnnotation] == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: namespace.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the namespace defaults controller with the Manager
+func (r *NamespaceDefaultsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespacedefaults").
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Owns(&profilingv1alpha1.ProfilingConfig{}).
+		Watches(&profilingv1alpha1.ProfilingConfig{},
+			handler.EnqueueRequestsFromMapFunc(r.namespacesForTemplate),
+		).
+		Complete(r)
+}
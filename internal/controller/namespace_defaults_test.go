@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// setupNamespaceDefaults creates a namespace defaults reconciler with templates
+// in the bolometer namespace
+func setupNamespaceDefaults(objs ...client.Object) *NamespaceDefaultsReconciler {
+	scheme := runtime.NewScheme()
+	_ = profilingv1alpha1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	return &NamespaceDefaultsReconciler{
+		Client:            fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		TemplateNamespace: "bolometer",
+	}
+}
+
+// newTemplate creates a ProfilingConfig template in the bolometer namespace
+func newTemplate(name string) *profilingv1alpha1.ProfilingConfig {
+	template := createTestProfilingConfig(name, "bolometer")
+	template.Labels = map[string]string{TemplateLabel: "true"}
+	template.Spec.Selector.Namespace = "bolometer"
+	template.Spec.Cluster = &profilingv1alpha1.ClusterTarget{Name: "remote"}
+	return template
+}
+
+func TestNamespaceDefaults(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		Annotations: map[string]string{DefaultConfigAnnotation: "team-defaults"},
+	}}
+	reconciler := setupNamespaceDefaults(namespace, newTemplate("team-defaults"))
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "team-a"}}
+
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	config := &profilingv1alpha1.ProfilingConfig{}
+	key := types.NamespacedName{Namespace: "team-a", Name: "team-defaults"}
+	if err := reconciler.Get(ctx, key, config); err != nil {
+		t.Fatalf("Expected the default config to be created: %v", err)
+	}
+	if config.Labels[DefaultConfigLabel] != "team-defaults" || isTemplate(config) {
+		t.Errorf("Unexpected labels %v", config.Labels)
+	}
+	if config.Spec.Selector.Namespace != "" || config.Spec.Cluster != nil {
+		t.Errorf("Expected the config to select its own namespace, got %+v", config.Spec.Selector)
+	}
+	if config.Spec.S3Config.Bucket != "test-bucket" {
+		t.Errorf("Expected the template's s3Config, got %+v", config.Spec.S3Config)
+	}
+
+	// Removing the annotation deletes the config
+	namespace.Annotations = nil
+	if err := reconciler.Update(ctx, namespace); err != nil {
+		t.Fatalf("Failed to update namespace: %v", err)
+	}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	if err := reconciler.Get(ctx, key, config); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the default config to be deleted, got %v", err)
+	}
+}
+
+func TestNamespaceDefaults_KeepsExistingConfig(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		Annotations: map[string]string{DefaultConfigAnnotation: "team-defaults"},
+	}}
+	existing := createTestProfilingConfig("team-defaults", "team-a")
+	existing.Spec.S3Config.Bucket = "team-a-bucket"
+	reconciler := setupNamespaceDefaults(namespace, newTemplate("team-defaults"), existing)
+	ctx := context.Background()
+
+	if _, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "team-a"}}); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	config := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(ctx, client.ObjectKeyFromObject(existing), config); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if config.Spec.S3Config.Bucket != "team-a-bucket" {
+		t.Errorf("Expected the namespace's own config to be kept, got bucket %s", config.Spec.S3Config.Bucket)
+	}
+}
+
+func TestNamespacesForTemplate(t *testing.T) {
+	reconciler := setupNamespaceDefaults(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: map[string]string{DefaultConfigAnnotation: "team-defaults"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Annotations: map[string]string{DefaultConfigAnnotation: "other"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}},
+	)
+	ctx := context.Background()
+
+	requests := reconciler.namespacesForTemplate(ctx, newTemplate("team-defaults"))
+	if len(requests) != 1 || requests[0].Name != "team-a" {
+		t.Errorf("Expected a request for team-a only, got %v", requests)
+	}
+
+	// Configs outside the templates namespace are not templates
+	if requests := reconciler.namespacesForTemplate(ctx, createTestProfilingConfig("team-defaults", "team-b")); len(requests) != 0 {
+		t.Errorf("Expected no requests, got %v", requests)
+	}
+}
+
+func TestReconcile_SkipsTemplates(t *testing.T) {
+	template := newTemplate("team-defaults")
+	template.Spec.Cluster = nil
+	reconciler := setupTestReconciler(template, createTestPod("test-pod", "bolometer", true))
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(template)})
+	if err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	if tracked := reconciler.podWatcher.GetTrackedPodsForConfig(configKeyOf(template)); len(tracked) != 0 {
+		t.Errorf("Expected templates not to track pods, got %d", len(tracked))
+	}
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// defaultNetworkIOCheckInterval is how often network counters are read by default
+const defaultNetworkIOCheckInterval = 30 * time.Second
+
+// networkRate is the average network byte rate of a pod between two reads of
+// its counters
+type networkRate struct {
+	receive  float64
+	transmit float64
+}
+
+// networkSampler turns the cumulative network counters of pods into rates. It
+// is owned by a single source.
+type networkSampler struct {
+	// previous holds the counters last read for each pod, by UID
+	previous map[types.UID]metrics.NetworkUsage
+}
+
+// newNetworkSampler creates an empty network sampler
+func newNetworkSampler() *networkSampler {
+	return &networkSampler{previous: make(map[types.UID]metrics.NetworkUsage)}
+}
+
+// observe records the counters of a pod and returns its rate since the
+// previous read. There is no rate on the first read of a pod, nor when its
+// counters were reset, such as by a restart of its sandbox.
+func (s *networkSampler) observe(uid types.UID, usage metrics.NetworkUsage) (networkRate, bool) {
+	previous, ok := s.previous[uid]
+	s.previous[uid] = usage
+	if !ok {
+		return networkRate{}, false
+	}
+
+	elapsed := usage.Timestamp.Sub(previous.Timestamp).Seconds()
+	if elapsed <= 0 || usage.ReceiveBytes < previous.ReceiveBytes || usage.TransmitBytes < previous.TransmitBytes {
+		return networkRate{}, false
+	}
+	return networkRate{
+		receive:  float64(usage.ReceiveBytes-previous.ReceiveBytes) / elapsed,
+		transmit: float64(usage.TransmitBytes-previous.TransmitBytes) / elapsed,
+	}, true
+}
+
+// retain drops the counters of the pods not in uids
+func (s *networkSampler) retain(uids map[types.UID]struct{}) {
+	for uid := range s.previous {
+		if _, ok := uids[uid]; !ok {
+			delete(s.previous, uid)
+		}
+	}
+}
+
+// networkIOSource captures the pods whose network receive or transmit rate
+// exceeds a threshold
+type networkIOSource struct {
+	r       *ProfilingConfigReconciler
+	config  *profilingv1alpha1.ProfilingConfig
+	sampler *networkSampler
+}
+
+// Name implements TriggerSource
+func (s *networkIOSource) Name() string { return "network-io" }
+
+// Start implements TriggerSource
+func (s *networkIOSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource. The first check runs right away
+// to read the counters the rates are computed from.
+func (s *networkIOSource) InitialDelay() time.Duration { return 0 }
+
+// Check implements ScheduledSource
+func (s *networkIOSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	s.r.checkNetworkIO(ctx, s.config, s.sampler, log.FromContext(ctx), emit)
+	if seconds := s.config.Spec.NetworkIO.CheckIntervalSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultNetworkIOCheckInterval
+}
+
+// checkNetworkIO reads the network counters of the tracked pods from the
+// kubelet, emitting a capture request per pod above a rate threshold out of
+// its cooldown
+func (r *ProfilingConfigReconciler) checkNetworkIO(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, sampler *networkSampler, logger logr.Logger, emit func(CaptureRequest)) {
+	cluster, err := r.clusterOf(config)
+	if err != nil {
+		logger.Error(err, "Failed to check network I/O")
+		return
+	}
+	kubelet := metrics.NewKubeletSource(cluster.clientset)
+
+	podsByNamespace := make(map[string][]*corev1.Pod)
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		podsByNamespace[tracked.Pod.Namespace] = append(podsByNamespace[tracked.Pod.Namespace], tracked.Pod)
+	}
+
+	seen := make(map[types.UID]struct{})
+	for namespace, pods := range podsByNamespace {
+		usages, err := kubelet.ListPodNetwork(ctx, namespace, pods)
+		if err != nil {
+			logger.Error(err, "Failed to read network counters", "namespace", namespace)
+			// Keep the counters of the namespace until it can be read again
+			for _, pod := range pods {
+				seen[pod.UID] = struct{}{}
+			}
+			continue
+		}
+
+		for _, pod := range pods {
+			seen[pod.UID] = struct{}{}
+			usage, ok := usages[pod.Name]
+			if !ok {
+				continue
+			}
+			rate, ok := sampler.observe(pod.UID, usage)
+			if !ok {
+				continue
+			}
+			exceeded, reason := networkIOExceeded(rate, config.Spec.NetworkIO)
+			if !exceeded {
+				continue
+			}
+
+			thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+			if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+				r.suppress(config, metrics.SuppressedCooldown)
+				continue
+			}
+			if r.nodeUnderPressure(ctx, config, pod, logger) {
+				r.suppress(config, metrics.SuppressedNodePressure)
+				continue
+			}
+
+			logger.Info("Network rate exceeded threshold, capturing profile", "pod", pod.Name, "reason", reason)
+			emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: reason}, StartCooldown: true})
+		}
+	}
+	sampler.retain(seen)
+}
+
+// networkIOExceeded reports whether a network rate exceeds the receive or
+// transmit threshold of a networkIO block
+func networkIOExceeded(rate networkRate, config *profilingv1alpha1.NetworkIOConfig) (bool, string) {
+	if threshold := config.ReceiveBytesPerSecond; threshold != nil && rate.receive > float64(threshold.Value()) {
+		return true, fmt.Sprintf("Network receive rate %s/s exceeds threshold %s/s", formatByteRate(rate.receive), threshold.String())
+	}
+	if threshold := config.TransmitBytesPerSecond; threshold != nil && rate.transmit > float64(threshold.Value()) {
+		return true, fmt.Sprintf("Network transmit rate %s/s exceeds threshold %s/s", formatByteRate(rate.transmit), threshold.String())
+	}
+	return false, ""
+}
+
+// formatByteRate formats a byte rate as a binary quantity
+func formatByteRate(bytesPerSecond float64) string {
+	return resource.NewQuantity(int64(bytesPerSecond), resource.BinarySI).String()
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestNetworkSampler(t *testing.T) {
+	sampler := newNetworkSampler()
+	start := time.Now()
+
+	if _, ok := sampler.observe("pod-1", metrics.NetworkUsage{Timestamp: start, ReceiveBytes: 1000, TransmitBytes: 5000}); ok {
+		t.Error("Expected no rate on the first read")
+	}
+
+	rate, ok := sampler.observe("pod-1", metrics.NetworkUsage{Timestamp: start.Add(10 * time.Second), ReceiveBytes: 11000, TransmitBytes: 105000})
+	if !ok || rate.receive != 1000 || rate.transmit != 10000 {
+		t.Errorf("Expected 1000 B/s received and 10000 B/s transmitted, got %+v", rate)
+	}
+
+	// Counters reset when the pod sandbox is recreated
+	if _, ok := sampler.observe("pod-1", metrics.NetworkUsage{Timestamp: start.Add(20 * time.Second), ReceiveBytes: 10, TransmitBytes: 10}); ok {
+		t.Error("Expected no rate after a counter reset")
+	}
+
+	sampler.retain(map[types.UID]struct{}{})
+	if len(sampler.previous) != 0 {
+		t.Errorf("Expected the counters of untracked pods to be dropped, got %v", sampler.previous)
+	}
+}
+
+func TestNetworkIOExceeded(t *testing.T) {
+	transmit := resource.MustParse("10Mi")
+	config := &profilingv1alpha1.NetworkIOConfig{TransmitBytesPerSecond: &transmit}
+
+	if exceeded, reason := networkIOExceeded(networkRate{receive: 100 << 20, transmit: 5 << 20}, config); exceeded {
+		t.Errorf("Expected the receive rate not to be checked without a threshold, got %q", reason)
+	}
+
+	exceeded, reason := networkIOExceeded(networkRate{transmit: 25 << 20}, config)
+	if !exceeded || !strings.Contains(reason, "Network transmit rate 25Mi/s exceeds threshold 10Mi/s") {
+		t.Errorf("Expected the transmit rate to exceed the threshold, got %v %q", exceeded, reason)
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// pressureConditions are the node conditions that block captures
+var pressureConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+}
+
+// nodePressure returns the pressure conditions currently reported by a node
+func nodePressure(node *corev1.Node) []string {
+	var pressured []string
+	for _, condition := range node.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		for _, pressure := range pressureConditions {
+			if condition.Type == pressure {
+				pressured = append(pressured, string(condition.Type))
+			}
+		}
+	}
+	return pressured
+}
+
+// checkNodePressure returns the pressure conditions of the node running a pod.
+// Nothing is checked when the config ignores node pressure or the pod is unscheduled.
+func (r *ProfilingConfigReconciler) checkNodePressure(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod) ([]string, error) {
+	policy := config.Spec.NodePressurePolicy
+	if policy == "" || policy == profilingv1alpha1.NodePressureIgnore || pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+
+	cluster, err := r.clusterOf(config)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := cluster.clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", pod.Spec.NodeName, err)
+	}
+
+	return nodePressure(node), nil
+}
+
+// nodeUnderPressure reports whether a capture of the pod should be held back
+// because its node is under pressure. Lookup failures do not block captures.
+func (r *ProfilingConfigReconciler) nodeUnderPressure(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, logger logr.Logger) bool {
+	pressured, err := r.checkNodePressure(ctx, config, pod)
+	if err != nil {
+		logger.Error(err, "Failed to check node pressure", "pod", pod.Name)
+		return false
+	}
+
+	if len(pressured) == 0 {
+		return false
+	}
+
+	logger.Info("Node under pressure, not capturing profile",
+		"pod", pod.Name,
+		"node", pod.Spec.NodeName,
+		"conditions", pressured,
+		"policy", config.Spec.NodePressurePolicy,
+	)
+	return true
+}
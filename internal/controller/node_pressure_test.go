@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func createTestNode(name string, conditions ...corev1.NodeConditionType) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+			},
+		},
+	}
+
+	for _, condition := range conditions {
+		node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+			Type:   condition,
+			Status: corev1.ConditionTrue,
+		})
+	}
+
+	return node
+}
+
+func TestNodePressure(t *testing.T) {
+	if pressured := nodePressure(createTestNode("node-1")); len(pressured) != 0 {
+		t.Errorf("Expected no pressure on healthy node, got %v", pressured)
+	}
+
+	pressured := nodePressure(createTestNode("node-1", corev1.NodeDiskPressure, corev1.NodePIDPressure))
+	if len(pressured) != 2 || pressured[0] != "DiskPressure" || pressured[1] != "PIDPressure" {
+		t.Errorf("Expected DiskPressure and PIDPressure, got %v", pressured)
+	}
+}
+
+func TestCheckNodePressure(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   profilingv1alpha1.NodePressurePolicy
+		node     *corev1.Node
+		expected int
+	}{
+		{
+			name:     "ignore policy does not check node",
+			policy:   profilingv1alpha1.NodePressureIgnore,
+			node:     createTestNode("node-1", corev1.NodeMemoryPressure),
+			expected: 0,
+		},
+		{
+			name:     "skip policy reports pressure",
+			policy:   profilingv1alpha1.NodePressureSkip,
+			node:     createTestNode("node-1", corev1.NodeMemoryPressure),
+			expected: 1,
+		},
+		{
+			name:     "defer policy on healthy node",
+			policy:   profilingv1alpha1.NodePressureDefer,
+			node:     createTestNode("node-1"),
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestProfilingConfig("test-config", "default")
+			config.Spec.NodePressurePolicy = tt.policy
+			pod := createTestPod("test-pod", "default", true)
+			pod.Spec.NodeName = "node-1"
+
+			reconciler := setupTestReconciler(config)
+			reconciler.Clientset = fake.NewSimpleClientset(tt.node)
+
+			pressured, err := reconciler.checkNodePressure(context.Background(), config, pod)
+			if err != nil {
+				t.Fatalf("checkNodePressure returned error: %v", err)
+			}
+
+			if len(pressured) != tt.expected {
+				t.Errorf("Expected %d pressure conditions, got %v", tt.expected, pressured)
+			}
+		})
+	}
+}
+
+func TestCheckNodePressure_MissingNode(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.NodePressurePolicy = profilingv1alpha1.NodePressureSkip
+	pod := createTestPod("test-pod", "default", true)
+	pod.Spec.NodeName = "missing-node"
+
+	reconciler := setupTestReconciler(config)
+
+	if _, err := reconciler.checkNodePressure(context.Background(), config, pod); err == nil {
+		t.Error("Expected error for missing node")
+	}
+}
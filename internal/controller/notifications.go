@@ -0,0 +1,519 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/notify"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// notifyTimeout bounds the time spent notifying about a capture
+const notifyTimeout = 30 * time.Second
+
+// Secret keys read when a reference names none
+const (
+	defaultSlackWebhookKey   = "webhookURL"
+	defaultWebhookSigningKey = "signingKey"
+	defaultKafkaUsernameKey  = "username"
+	defaultKafkaPasswordKey  = "password"
+	defaultGrafanaTokenKey   = "token"
+	defaultSplunkTokenKey    = "token"
+)
+
+// Keys of the SMTP Secret of email notifications
+const (
+	smtpServerKey   = "server"
+	smtpUsernameKey = "username"
+	smtpPasswordKey = "password"
+)
+
+// Retries of failed notification deliveries
+const (
+	// defaultNotifyAttempts is how many times a delivery is tried by the
+	// built-in sinks that can safely deliver twice
+	defaultNotifyAttempts = 3
+
+	// notifyRetryDelay is the delay before the first retry, doubling after
+	notifyRetryDelay = time.Second
+)
+
+// NotificationSink reports captures to one kind of destination, e.g. Slack or
+// a webhook. The built-in sinks are read from the notifications block of a
+// config; others are added with ReconcilerOptions.NotificationSinks.
+type NotificationSink struct {
+	// Name identifies the sink in logs
+	Name string
+
+	// Attempts is how many times a failed delivery is tried, once if unset
+	Attempts int
+
+	// Deliveries returns the deliveries of the event of a capture to the
+	// sink's destinations of a config, none if the config does not use the
+	// sink or the sink does not report the capture
+	Deliveries func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery
+}
+
+// Delivery sends the event of a capture to one destination
+type Delivery struct {
+	// Target identifies the destination in logs, e.g. a URL or topic
+	Target string
+
+	// Send delivers the event
+	Send func(ctx context.Context) error
+}
+
+// notificationSinks returns the sinks of a config: the built-in ones if it has a
+// notifications block, followed by the added ones
+func (r *ProfilingConfigReconciler) notificationSinks(config *profilingv1alpha1.ProfilingConfig) []NotificationSink {
+	if config.Spec.Notifications == nil {
+		return r.addedSinks
+	}
+
+	// Slack, email and issues only hear about successful threshold captures
+	thresholdSucceeded := func(record audit.Record) bool {
+		return record.Outcome == audit.OutcomeSucceeded && record.TriggeredBy == triggeredByThreshold
+	}
+	// SNS, SQS and Grafana only hear about successful captures, whatever their trigger
+	succeeded := func(record audit.Record) bool {
+		return record.Outcome == audit.OutcomeSucceeded
+	}
+
+	sinks := []NotificationSink{
+		{Name: "slack", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			slack := config.Spec.Notifications.Slack
+			if slack == nil || !thresholdSucceeded(record) {
+				return nil
+			}
+			return []Delivery{{Target: slack.WebhookSecretRef.Name, Send: func(ctx context.Context) error {
+				return r.notifySlack(ctx, config, slack, record)
+			}}}
+		}},
+		{Name: "email", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			email := config.Spec.Notifications.Email
+			if email == nil || !thresholdSucceeded(record) {
+				return nil
+			}
+			return []Delivery{{Target: strings.Join(email.To, ","), Send: func(ctx context.Context) error {
+				return r.notifyEmail(ctx, config, email, record)
+			}}}
+		}},
+		// Webhooks hear about every capture attempt
+		{Name: "webhook", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			var deliveries []Delivery
+			for i := range config.Spec.Notifications.Webhooks {
+				webhook := &config.Spec.Notifications.Webhooks[i]
+				deliveries = append(deliveries, Delivery{Target: webhook.URL, Send: func(ctx context.Context) error {
+					return r.notifyWebhook(ctx, config, webhook, record)
+				}})
+			}
+			return deliveries
+		}},
+		// Splunk hears about every capture attempt
+		{Name: "splunk", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			splunk := config.Spec.Notifications.Splunk
+			if splunk == nil {
+				return nil
+			}
+			return []Delivery{{Target: splunk.URL, Send: func(ctx context.Context) error {
+				return r.notifySplunk(ctx, config, splunk, record)
+			}}}
+		}},
+		// Issues are spaced per service and filed once, a failed filing is
+		// retried on the next capture
+		{Name: "issues", Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			issues := config.Spec.Notifications.Issues
+			if issues == nil || !thresholdSucceeded(record) {
+				return nil
+			}
+			return []Delivery{{Target: string(issues.Tracker) + " " + issues.Project, Send: func(ctx context.Context) error {
+				return r.fileIssue(ctx, config, issues, record)
+			}}}
+		}},
+		// Kafka hears about every capture attempt too
+		{Name: "kafka", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			kafka := config.Spec.Notifications.Kafka
+			if kafka == nil {
+				return nil
+			}
+			return []Delivery{{Target: kafka.Topic, Send: func(ctx context.Context) error {
+				return r.notifyKafka(ctx, config, kafka, record)
+			}}}
+		}},
+		{Name: "sns", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			topic := config.Spec.Notifications.SNS
+			if topic == nil || !succeeded(record) {
+				return nil
+			}
+			return []Delivery{{Target: topic.TopicARN, Send: func(ctx context.Context) error {
+				return r.notifySNS(ctx, config, topic, record)
+			}}}
+		}},
+		{Name: "sqs", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			queue := config.Spec.Notifications.SQS
+			if queue == nil || !succeeded(record) {
+				return nil
+			}
+			return []Delivery{{Target: queue.QueueURL, Send: func(ctx context.Context) error {
+				return r.notifySQS(ctx, config, queue, record)
+			}}}
+		}},
+		{Name: "grafana", Attempts: defaultNotifyAttempts, Deliveries: func(config *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			grafana := config.Spec.Notifications.Grafana
+			if grafana == nil || !succeeded(record) {
+				return nil
+			}
+			return []Delivery{{Target: grafana.URL, Send: func(ctx context.Context) error {
+				return r.notifyGrafana(ctx, config, grafana, record)
+			}}}
+		}},
+	}
+	return append(sinks, r.addedSinks...)
+}
+
+// notifyCapture sends the event of a capture to the config's notification
+// sinks. Deliveries run concurrently, each retried as its sink allows.
+// Failures are logged but never fail the capture.
+func (r *ProfilingConfigReconciler) notifyCapture(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, record audit.Record) {
+	sinks := r.notificationSinks(config)
+	if len(sinks) == 0 {
+		return
+	}
+
+	// Captures interrupted by a config deletion are still reported
+	notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	logger := log.FromContext(ctx)
+
+	var wg sync.WaitGroup
+	for _, sink := range sinks {
+		for _, delivery := range sink.Deliveries(config, record) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := deliver(notifyCtx, delivery, max(sink.Attempts, 1)); err != nil {
+					logger.Error(err, "Failed to notify", "sink", sink.Name, "target", delivery.Target,
+						"pod", record.Pod.Name, "config", record.Config)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// deliver sends a delivery, trying up to attempts times with exponential backoff
+// until ctx is done
+func deliver(ctx context.Context, delivery Delivery, attempts int) error {
+	delay := notifyRetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = delivery.Send(ctx); err == nil || attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	if err != nil && attempts > 1 {
+		return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+	return err
+}
+
+// notifyGrafana posts a capture annotation to the Grafana of a config
+func (r *ProfilingConfigReconciler) notifyGrafana(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, grafana *profilingv1alpha1.GrafanaNotification, record audit.Record) error {
+	token, err := r.secretValue(ctx, config.Namespace, grafana.TokenSecretRef, defaultGrafanaTokenKey)
+	if err != nil {
+		return err
+	}
+
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier := notify.NewGrafanaNotifier(grafana.URL, token, grafana.DashboardUID, grafana.Tags)
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifyKafka produces a capture event to the Kafka topic of a config
+func (r *ProfilingConfigReconciler) notifyKafka(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, kafka *profilingv1alpha1.KafkaNotification, record audit.Record) error {
+	kafkaConfig := notify.KafkaConfig{
+		Brokers: kafka.Brokers,
+		Topic:   kafka.Topic,
+		Format:  string(withDefault(kafka.Format, profilingv1alpha1.KafkaFormatJSON)),
+		TLS:     kafka.TLS,
+	}
+	if sasl := kafka.SASL; sasl != nil {
+		username, err := r.secretValue(ctx, config.Namespace, sasl.UsernameSecretRef, defaultKafkaUsernameKey)
+		if err != nil {
+			return err
+		}
+		password, err := r.secretValue(ctx, config.Namespace, sasl.PasswordSecretRef, defaultKafkaPasswordKey)
+		if err != nil {
+			return err
+		}
+		kafkaConfig.SASLMechanism = withDefault(sasl.Mechanism, notify.SASLScramSHA512)
+		kafkaConfig.Username = username
+		kafkaConfig.Password = password
+	}
+
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := notify.NewKafkaNotifier(kafkaConfig)
+	if err != nil {
+		return err
+	}
+	defer notifier.Close()
+
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifySNS publishes a capture event to the SNS topic of a config
+func (r *ProfilingConfigReconciler) notifySNS(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, topic *profilingv1alpha1.SNSNotification, record audit.Record) error {
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := notify.NewSNSNotifier(ctx, topic.TopicARN, withDefault(topic.Region, s3Config.Region))
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifySQS sends a capture event to the SQS queue of a config
+func (r *ProfilingConfigReconciler) notifySQS(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, queue *profilingv1alpha1.SQSNotification, record audit.Record) error {
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := notify.NewSQSNotifier(ctx, queue.QueueURL, withDefault(queue.Region, s3Config.Region))
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifyEmail emails a capture summary to the recipients of a config
+func (r *ProfilingConfigReconciler) notifyEmail(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, email *profilingv1alpha1.EmailNotification, record audit.Record) error {
+	secret, err := r.Clientset.CoreV1().Secrets(config.Namespace).Get(ctx, email.SMTPSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s: %w", email.SMTPSecretName, err)
+	}
+	server := strings.TrimSpace(string(secret.Data[smtpServerKey]))
+	if server == "" {
+		return fmt.Errorf("secret %s has no key %s", email.SMTPSecretName, smtpServerKey)
+	}
+
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier := notify.NewEmailNotifier(notify.SMTPConfig{
+		Server:   server,
+		Username: strings.TrimSpace(string(secret.Data[smtpUsernameKey])),
+		Password: strings.TrimSpace(string(secret.Data[smtpPasswordKey])),
+		From:     email.From,
+		To:       email.To,
+	})
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifySplunk forwards a capture event to the Splunk collector of a config
+func (r *ProfilingConfigReconciler) notifySplunk(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, splunk *profilingv1alpha1.SplunkNotification, record audit.Record) error {
+	token, err := r.secretValue(ctx, config.Namespace, splunk.TokenSecretRef, defaultSplunkTokenKey)
+	if err != nil {
+		return err
+	}
+
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, 0)
+	if err != nil {
+		return err
+	}
+
+	notifier := notify.NewSplunkNotifier(splunk.URL, token, splunk.Index,
+		withDefault(splunk.Source, "bolometer"), withDefault(splunk.SourceType, "bolometer:capture"))
+	return notifier.Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifyWebhook posts a capture event to a webhook of a config
+func (r *ProfilingConfigReconciler) notifyWebhook(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, webhook *profilingv1alpha1.WebhookNotification, record audit.Record) error {
+	var signingKey []byte
+	if ref := webhook.SigningSecretRef; ref != nil {
+		key, err := r.secretValue(ctx, config.Namespace, *ref, defaultWebhookSigningKey)
+		if err != nil {
+			return err
+		}
+		signingKey = []byte(key)
+	}
+
+	// Links are presigned with the credentials the profiles were uploaded with
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, time.Duration(webhook.PresignExpirySeconds)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	return notify.NewWebhookNotifier(webhook.URL, signingKey).Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// notifySlack posts a capture to the Slack webhook of a config
+func (r *ProfilingConfigReconciler) notifySlack(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, slack *profilingv1alpha1.SlackNotification, record audit.Record) error {
+	webhookURL, err := r.secretValue(ctx, config.Namespace, slack.WebhookSecretRef, defaultSlackWebhookKey)
+	if err != nil {
+		return err
+	}
+
+	// Links are presigned with the credentials the profiles were uploaded with
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	links, err := profileLinks(ctx, s3Config, record, time.Duration(slack.PresignExpirySeconds)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	return notify.NewSlackNotifier(webhookURL).Notify(ctx, notify.Event{Record: record, Links: links})
+}
+
+// profileLinks links the profiles of a capture, with presigned URLs valid for
+// expiry if set
+func profileLinks(ctx context.Context, s3Config uploader.S3Config, record audit.Record, expiry time.Duration) ([]notify.Link, error) {
+	if len(record.Profiles) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(record.Profiles))
+	for _, profile := range record.Profiles {
+		keys = append(keys, profile.Key)
+	}
+
+	urls := make([]string, 0, len(keys))
+	if expiry > 0 {
+		presigned, err := uploader.PresignURLs(ctx, s3Config, keys, expiry)
+		if err != nil {
+			return nil, err
+		}
+		urls = presigned
+	} else {
+		// URLs presigned at upload download the profiles without bucket credentials
+		for i, key := range keys {
+			if url := record.Profiles[i].PresignedURL; url != "" {
+				urls = append(urls, url)
+			} else {
+				urls = append(urls, uploader.ObjectURL(s3Config, key))
+			}
+		}
+	}
+
+	links := make([]notify.Link, 0, len(urls))
+	for i, url := range urls {
+		links = append(links, notify.Link{Type: record.Profiles[i].Type, URL: url})
+	}
+	return links, nil
+}
+
+// secretValue reads a key of a Secret in namespace, defaultKey if the
+// reference names none. Surrounding whitespace is trimmed.
+func (r *ProfilingConfigReconciler) secretValue(ctx context.Context, namespace string, ref profilingv1alpha1.SecretKeyRef, defaultKey string) (string, error) {
+	key := withDefault(ref.Key, defaultKey)
+
+	secret, err := r.Clientset.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", ref.Name, err)
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", ref.Name, key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// s3ConfigOf returns the upload destination of a config
+func s3ConfigOf(config *profilingv1alpha1.ProfilingConfig) uploader.S3Config {
+	s3Config := uploader.S3Config{
+		Bucket:         config.Spec.S3Config.Bucket,
+		Prefix:         config.Spec.S3Config.Prefix,
+		Region:         config.Spec.S3Config.Region,
+		Endpoint:       config.Spec.S3Config.Endpoint,
+		ProxyURL:       config.Spec.S3Config.ProxyURL,
+		Accelerate:     config.Spec.S3Config.Accelerate,
+		DualStack:      config.Spec.S3Config.DualStack,
+		ForcePathStyle: config.Spec.S3Config.ForcePathStyle,
+		RetryMode:      string(config.Spec.S3Config.RetryMode),
+		MaxAttempts:    config.Spec.S3Config.MaxAttempts,
+		PrefixValues:   prefixValuesOf(config),
+		Baseline:       baselineOf(config),
+		Gallery:        config.Spec.S3Config.Gallery,
+	}
+	if s3Config.Gallery && config.Spec.S3Config.PresignExpirySeconds > 0 {
+		s3Config.GalleryLinkExpiry = time.Duration(config.Spec.S3Config.PresignExpirySeconds) * time.Second
+	}
+	return s3Config
+}
+
+// prefixValuesOf returns the values of the prefix placeholders that are the
+// same for every pod of a config. Objects of services render {namespace} as
+// the selected namespace, or the config's when it selects every namespace.
+func prefixValuesOf(config *profilingv1alpha1.ProfilingConfig) uploader.PrefixValues {
+	values := uploader.PrefixValues{
+		Namespace:  config.Spec.Selector.Namespace,
+		Cluster:    uploader.LocalCluster,
+		ConfigName: config.Name,
+	}
+	if values.Namespace == "" {
+		values.Namespace = config.Namespace
+	}
+	if config.Spec.Cluster != nil {
+		values.Cluster = config.Spec.Cluster.Name
+	}
+	return values
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestNotifyCapture_Slack(t *testing.T) {
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("Failed to decode message: %v", err)
+		}
+		messages = append(messages, message.Text)
+	}))
+	defer server.Close()
+
+	reconciler := setupTestReconciler()
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "default"},
+		Data:       map[string][]byte{defaultSlackWebhookKey: []byte(server.URL + "\n")},
+	}
+	if _, err := reconciler.Clientset.CoreV1().Secrets("default").Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		Slack: &profilingv1alpha1.SlackNotification{
+			WebhookSecretRef: profilingv1alpha1.SecretKeyRef{Name: "slack"},
+		},
+	}
+
+	record := audit.Record{
+		Config:      "default/test-config",
+		TriggeredBy: triggeredByThreshold,
+		Reason:      "cpu threshold exceeded",
+		Pod:         audit.Pod{Namespace: "default", Name: "test-pod"},
+		Profiles:    []audit.Profile{{Type: "heap", Key: "profiles/heap.pprof"}},
+		Outcome:     audit.OutcomeSucceeded,
+	}
+	reconciler.notifyCapture(ctx, config, record)
+
+	// On-demand and failed captures are not posted
+	onDemand := record
+	onDemand.TriggeredBy = triggeredByOnDemand
+	reconciler.notifyCapture(ctx, config, onDemand)
+	failed := record
+	failed.Outcome = audit.OutcomeFailed
+	reconciler.notifyCapture(ctx, config, failed)
+
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 Slack message, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0], "default/test-pod") ||
+		!strings.Contains(messages[0], "https://s3.console.aws.amazon.com/s3/object/test-bucket") {
+		t.Errorf("Unexpected Slack message: %s", messages[0])
+	}
+}
+
+func TestNotifyCapture_Grafana(t *testing.T) {
+	var tags [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer glsa_token" {
+			t.Errorf("Unexpected authorization %q", auth)
+		}
+		var annotation struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+			t.Errorf("Failed to decode annotation: %v", err)
+		}
+		tags = append(tags, annotation.Tags)
+	}))
+	defer server.Close()
+
+	reconciler := setupTestReconciler()
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "grafana", Namespace: "default"},
+		Data:       map[string][]byte{defaultGrafanaTokenKey: []byte("glsa_token")},
+	}
+	if _, err := reconciler.Clientset.CoreV1().Secrets("default").Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		Grafana: &profilingv1alpha1.GrafanaNotification{
+			URL:            server.URL,
+			TokenSecretRef: profilingv1alpha1.SecretKeyRef{Name: "grafana"},
+		},
+	}
+
+	record := audit.Record{
+		Config:      "default/test-config",
+		TriggeredBy: triggeredByOnDemand,
+		Reason:      onDemandReason,
+		Pod:         audit.Pod{Namespace: "default", Name: "test-pod"},
+		Service:     "test-app",
+		Outcome:     audit.OutcomeSucceeded,
+	}
+	reconciler.notifyCapture(ctx, config, record)
+
+	// Failed captures are not annotated
+	failed := record
+	failed.Outcome = audit.OutcomeFailed
+	reconciler.notifyCapture(ctx, config, failed)
+
+	if len(tags) != 1 {
+		t.Fatalf("Expected 1 annotation, got %d", len(tags))
+	}
+	if !strings.Contains(strings.Join(tags[0], ","), "service:test-app") {
+		t.Errorf("Expected service tag, got %v", tags[0])
+	}
+
+	// Links are built with the storage config the profiles were uploaded with,
+	// so a web identity that cannot be set up fails the annotation
+	config.Spec.S3Config.WebIdentity = &profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "missing"}
+	err := reconciler.notifyGrafana(ctx, config, config.Spec.Notifications.Grafana, record)
+	if err == nil || !strings.Contains(err.Error(), "web identity") {
+		t.Errorf("Expected the web identity's error, got %v", err)
+	}
+	if len(tags) != 1 {
+		t.Errorf("Expected no further annotation, got %d", len(tags))
+	}
+}
+
+func TestNotifyCapture_AddedSinks(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[string]int{}
+	send := func(target string, failures int) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent[target]++
+			if sent[target] <= failures {
+				return errors.New("service unavailable")
+			}
+			return nil
+		}
+	}
+
+	reconciler := setupTestReconciler()
+	reconciler.addedSinks = []NotificationSink{
+		{Name: "pagerduty", Attempts: 2, Deliveries: func(_ *profilingv1alpha1.ProfilingConfig, record audit.Record) []Delivery {
+			if record.Outcome != audit.OutcomeSucceeded {
+				return nil
+			}
+			return []Delivery{
+				{Target: "flaky", Send: send("flaky", 1)},
+				{Target: "down", Send: send("down", 5)},
+			}
+		}},
+		{Name: "once", Deliveries: func(*profilingv1alpha1.ProfilingConfig, audit.Record) []Delivery {
+			return []Delivery{{Target: "once", Send: send("once", 5)}}
+		}},
+	}
+
+	// Added sinks hear about captures of configs without a notifications block
+	config := createTestProfilingConfig("test-config", "default")
+	record := audit.Record{Config: "default/test-config", Pod: audit.Pod{Namespace: "default", Name: "test-pod"}, Outcome: audit.OutcomeSucceeded}
+	reconciler.notifyCapture(context.Background(), config, record)
+
+	// Deliveries are retried up to the attempts of their sink
+	if sent["flaky"] != 2 || sent["down"] != 2 || sent["once"] != 1 {
+		t.Errorf("Unexpected delivery attempts %v", sent)
+	}
+}
+
+func TestProfileLinks(t *testing.T) {
+	s3Config := uploader.S3Config{Bucket: "my-bucket", Endpoint: "http://minio:9000"}
+	record := audit.Record{Profiles: []audit.Profile{
+		{Type: "heap", Key: "profiles/heap.pprof", PresignedURL: "http://minio:9000/my-bucket/profiles/heap.pprof?X-Amz-Signature=abc"},
+		{Type: "cpu", Key: "profiles/cpu.pprof"},
+	}}
+
+	links, err := profileLinks(context.Background(), s3Config, record, 0)
+	if err != nil {
+		t.Fatalf("profileLinks returned unexpected error: %v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links, got %+v", links)
+	}
+	if links[0].URL != record.Profiles[0].PresignedURL {
+		t.Errorf("Expected the URL presigned at upload, got %s", links[0].URL)
+	}
+	if links[1].URL != "http://minio:9000/my-bucket/profiles/cpu.pprof" {
+		t.Errorf("Expected the object URL, got %s", links[1].URL)
+	}
+}
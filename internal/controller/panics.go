@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// panicError is a panic recovered in a monitor task, scheduled check or
+// capture job, along with the stack it was raised on
+type panicError struct {
+	task  string
+	value any
+	stack []byte
+}
+
+// Error implements error
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.task, e.value)
+}
+
+// recoverPanic converts a panic of the function deferring it into a
+// *panicError in err. Panics re-raised with a *panicError keep their stack.
+func recoverPanic(task string, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if panicked, ok := recovered.(*panicError); ok {
+		*err = panicked
+		return
+	}
+	*err = &panicError{task: task, value: recovered, stack: debug.Stack()}
+}
+
+// asPanic returns the recovered panic an error is, if any
+func asPanic(err error) (*panicError, bool) {
+	var panicked *panicError
+	ok := errors.As(err, &panicked)
+	return panicked, ok
+}
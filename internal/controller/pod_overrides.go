@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// Annotations overriding the thresholds of a config for one pod, e.g. a
+// leader replica that legitimately runs hotter than the others
+const (
+	CPUThresholdAnnotation    = "bolometer.io/cpu-threshold"
+	MemoryThresholdAnnotation = "bolometer.io/memory-threshold"
+	CooldownAnnotation        = "bolometer.io/cooldown-seconds"
+)
+
+// ProfileTypesAnnotation overrides the profile types a config captures from a
+// pod with a comma-separated list, so application owners can tune what is
+// captured without changing the config. The CPU profile duration is set with
+// profiler.CPUSecondsAnnotation.
+const ProfileTypesAnnotation = "bolometer.io/profile-types"
+
+// minCooldownSeconds is the shortest cooldown a pod may ask for, the same as
+// the minimum of the config's cooldownSeconds
+const minCooldownSeconds = 60
+
+// podThresholds returns the thresholds of a config for a pod, overridden by the
+// pod's annotations. Invalid annotations are ignored and reported in the error.
+func podThresholds(pod *corev1.Pod, thresholds profilingv1alpha1.ThresholdConfig) (profilingv1alpha1.ThresholdConfig, error) {
+	var errs []error
+	override := func(key string, minValue, maxValue int, field *int) {
+		value, ok, err := intAnnotation(pod, key, minValue, maxValue)
+		if err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			*field = value
+		}
+	}
+
+	override(CPUThresholdAnnotation, 1, 100, &thresholds.CPUThresholdPercent)
+	override(MemoryThresholdAnnotation, 1, 100, &thresholds.MemoryThresholdPercent)
+	override(CooldownAnnotation, minCooldownSeconds, 0, &thresholds.CooldownSeconds)
+	return thresholds, errors.Join(errs...)
+}
+
+// intAnnotation parses an integer annotation of a pod within [minValue,
+// maxValue], without upper bound if maxValue is 0. It reports whether the
+// annotation is set.
+func intAnnotation(pod *corev1.Pod, key string, minValue, maxValue int) (int, bool, error) {
+	raw, ok := pod.Annotations[key]
+	if !ok {
+		return 0, false, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("annotation %s: %q is not an integer", key, raw)
+	}
+	if value < minValue || (maxValue > 0 && value > maxValue) {
+		if maxValue > 0 {
+			return 0, false, fmt.Errorf("annotation %s: %d is not between %d and %d", key, value, minValue, maxValue)
+		}
+		return 0, false, fmt.Errorf("annotation %s: %d is less than %d", key, value, minValue)
+	}
+	return value, true, nil
+}
+
+// podProfileTypes returns the profile types captured from a pod: those of its
+// annotation if any, the config's otherwise
+func podProfileTypes(pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig) []string {
+	if profileTypes := splitProfileTypes(pod.Annotations[ProfileTypesAnnotation]); len(profileTypes) > 0 {
+		return profileTypes
+	}
+	return profileTypesOf(config)
+}
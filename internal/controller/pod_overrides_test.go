@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestPodThresholds(t *testing.T) {
+	defaults := profilingv1alpha1.ThresholdConfig{CPUThresholdPercent: 80, MemoryThresholdPercent: 90, CooldownSeconds: 300}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    profilingv1alpha1.ThresholdConfig
+		err         string
+	}{
+		{
+			name:     "no overrides",
+			expected: defaults,
+		},
+		{
+			name: "all overrides",
+			annotations: map[string]string{
+				CPUThresholdAnnotation:    "95",
+				MemoryThresholdAnnotation: "70",
+				CooldownAnnotation:        "900",
+			},
+			expected: profilingv1alpha1.ThresholdConfig{CPUThresholdPercent: 95, MemoryThresholdPercent: 70, CooldownSeconds: 900},
+		},
+		{
+			name: "invalid overrides are ignored",
+			annotations: map[string]string{
+				CPUThresholdAnnotation:    "high",
+				MemoryThresholdAnnotation: "150",
+				CooldownAnnotation:        "10",
+			},
+			expected: defaults,
+			err:      "is not an integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := createTestPod("test-pod", "default", true)
+			for key, value := range tt.annotations {
+				pod.Annotations[key] = value
+			}
+
+			thresholds, err := podThresholds(pod, defaults)
+			if thresholds.CPUThresholdPercent != tt.expected.CPUThresholdPercent ||
+				thresholds.MemoryThresholdPercent != tt.expected.MemoryThresholdPercent ||
+				thresholds.CooldownSeconds != tt.expected.CooldownSeconds {
+				t.Errorf("Expected thresholds %+v, got %+v", tt.expected, thresholds)
+			}
+			if tt.err == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSimulation_PodOverrides(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	var pods []*corev1.Pod
+	for _, name := range []string{"leader-pod", "follower-pod"} {
+		pod := createTestPod(name, "default", true)
+		pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		}
+		pods = append(pods, pod)
+	}
+	// The leader runs hotter, the follower is captured at most every 10 minutes
+	pods[0].Annotations[CPUThresholdAnnotation] = "98"
+	pods[1].Annotations[CooldownAnnotation] = "600"
+	reconciler := setupTestReconciler(config, pods[0], pods[1])
+
+	source := &staticSource{cpu: map[string]string{"leader-pod": "95m", "follower-pod": "95m"}}
+	collector := metrics.NewCollector(&fakeMetricsClientset{})
+	collector.SetCacheTTL(0)
+	collector.RegisterSource(metrics.SourceMetricsServer, source)
+	simulation := NewSimulation(config, reconciler.Client, collector)
+	ctx := context.Background()
+	start := time.Now()
+
+	check, err := simulation.Check(ctx, start)
+	if err != nil {
+		t.Fatalf("Check returned unexpected error: %v", err)
+	}
+	if len(check.Captures) != 1 || check.Captures[0].Pod != "default/follower-pod" {
+		t.Fatalf("Expected only follower-pod to be captured, got %+v", check)
+	}
+
+	// The config's cooldown has passed, the pod's own has not
+	check, err = simulation.Check(ctx, start.Add(301*time.Second))
+	if err != nil {
+		t.Fatalf("Check returned unexpected error: %v", err)
+	}
+	if check.InCooldown != 1 || len(check.Captures) != 0 {
+		t.Errorf("Expected follower-pod to stay in cooldown, got %+v", check)
+	}
+}
+
+func TestPodProfileTypes(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.ProfileTypes = []string{"heap", "cpu"}
+
+	tests := []struct {
+		annotation string
+		expected   []string
+	}{
+		{"", []string{"heap", "cpu"}},
+		{"goroutine, mutex", []string{"goroutine", "mutex"}},
+		{" , ", []string{"heap", "cpu"}},
+	}
+	for _, tt := range tests {
+		pod := createTestPod("test-pod", "default", true)
+		if tt.annotation != "" {
+			pod.Annotations[ProfileTypesAnnotation] = tt.annotation
+		}
+		if got := podProfileTypes(pod, config); strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("podProfileTypes(%q) = %v, expected %v", tt.annotation, got, tt.expected)
+		}
+	}
+}
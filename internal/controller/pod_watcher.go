@@ -2,15 +2,17 @@ package controller
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
 )
 
 const (
@@ -18,59 +20,130 @@ const (
 	ProfilingEnabledAnnotation = "bolometer.io/enabled"
 )
 
-// PodWatcher watches and tracks pods that should be profiled
+const (
+	// captureBackoffBase is how long a pod is held back after a failed capture,
+	// doubled on every further failure
+	captureBackoffBase = 30 * time.Second
+
+	// captureBackoffMax caps the backoff of a failing pod
+	captureBackoffMax = 30 * time.Minute
+
+	// quarantineFailures is the number of failed captures in a row after which
+	// a pod is quarantined
+	quarantineFailures = 5
+
+	// quarantineRetryInterval is how often a quarantined pod is tried again
+	quarantineRetryInterval = time.Hour
+)
+
+// PodWatcher watches and tracks pods that should be profiled. A pod selected by
+// several configs is tracked once, on behalf of the config that outranks the others.
 type PodWatcher struct {
-	clientset kubernetes.Interface
+	reader client.Reader
 
 	mu              sync.RWMutex
 	trackedPods     map[string]*TrackedPod
 	lastProfileTime map[string]time.Time
+
+	// claims holds every config selecting a pod, keyed by pod then config key
+	claims map[string]map[string]*profilingv1alpha1.ProfilingConfig
+
+	// captures holds the outcome of the captures of each pod
+	captures map[string]*podCaptureState
+}
+
+// podCaptureState is the capture history of a tracked pod reported in status
+type podCaptureState struct {
+	lastCaptureTime     time.Time
+	lastTriggerReason   string
+	consecutiveFailures int
+
+	// backoffFailures counts the failures the pod is to blame for, leaving out
+	// upload failures
+	backoffFailures int
+	retryAfter      time.Time
+	quarantined     bool
+}
+
+// resetBackoff lifts the backoff and quarantine of a pod
+func (s *podCaptureState) resetBackoff() {
+	s.backoffFailures = 0
+	s.retryAfter = time.Time{}
+	s.quarantined = false
 }
 
 // TrackedPod represents a pod being monitored for profiling
 type TrackedPod struct {
-	Pod             *corev1.Pod
+	Pod *corev1.Pod
+
+	// Config is the config that owns the pod
 	Config          *profilingv1alpha1.ProfilingConfig
 	LastProfileTime time.Time
 	OnDemandTicker  *time.Ticker
 	StopChan        chan struct{}
+
+	// probe is the probe of the pod's pprof endpoint, nil until claimed
+	probe *pprofProbe
+}
+
+// pprofProbe is the outcome of probing the pprof endpoint of a tracked pod,
+// with an empty state while the probe runs
+type pprofProbe struct {
+	state   profilingv1alpha1.PprofState
+	message string
 }
 
-// NewPodWatcher creates a new pod watcher
-func NewPodWatcher(clientset kubernetes.Interface) *PodWatcher {
+// NewPodWatcher creates a new pod watcher listing pods through the given reader,
+// normally the manager's cache-backed client
+func NewPodWatcher(reader client.Reader) *PodWatcher {
 	return &PodWatcher{
-		clientset:       clientset,
+		reader:          reader,
 		trackedPods:     make(map[string]*TrackedPod),
 		lastProfileTime: make(map[string]time.Time),
+		claims:          make(map[string]map[string]*profilingv1alpha1.ProfilingConfig),
+		captures:        make(map[string]*podCaptureState),
 	}
 }
 
-// ListMatchingPods lists pods that match the profiling config selector
+// ListMatchingPods lists pods that match the profiling config selector. Pods are
+// read through the manager's cache, so listing does not reach the apiserver.
 func (pw *PodWatcher) ListMatchingPods(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) ([]*corev1.Pod, error) {
-	namespace := config.Spec.Selector.Namespace
-	if namespace == "" {
-		namespace = config.Namespace
-	}
+	return pw.ListMatchingPodsFrom(ctx, pw.reader, config)
+}
 
-	// List pods with the profiling annotation
-	listOptions := metav1.ListOptions{}
+// ListMatchingPodsFrom lists pods that match the profiling config selector
+// through the given reader, for instance one of a remote cluster
+func (pw *PodWatcher) ListMatchingPodsFrom(ctx context.Context, reader client.Reader, config *profilingv1alpha1.ProfilingConfig) ([]*corev1.Pod, error) {
+	namespace := selectorNamespace(config)
+	listOptions := []client.ListOption{client.InNamespace(namespace)}
 
 	// Add label selector if specified
 	if len(config.Spec.Selector.LabelSelector) > 0 {
-		selector := labels.SelectorFromSet(config.Spec.Selector.LabelSelector)
-		listOptions.LabelSelector = selector.String()
+		listOptions = append(listOptions, client.MatchingLabels(config.Spec.Selector.LabelSelector))
 	}
 
-	podList, err := pw.clientset.CoreV1().Pods(namespace).List(ctx, listOptions)
-	if err != nil {
+	podList := &corev1.PodList{}
+	if err := reader.List(ctx, podList, listOptions...); err != nil {
 		return nil, err
 	}
 
-	// Filter pods by annotation
+	// Narrow down to the pods serving the selected Service
+	var serving map[string]bool
+	if config.Spec.Selector.Service != "" {
+		var err error
+		if serving, err = servingPods(ctx, reader, namespace, config.Spec.Selector.Service); err != nil {
+			return nil, err
+		}
+	}
+
+	// Filter pods by annotation, leaving out pods being deleted and, unless the
+	// config asks for them, pods that are not ready
+	requireReady := config.Spec.Selector.RequireReady == nil || *config.Spec.Selector.RequireReady
 	var matchingPods []*corev1.Pod
 	for i := range podList.Items {
 		pod := &podList.Items[i]
-		if pw.isPodProfilingEnabled(pod) && pod.Status.Phase == corev1.PodRunning {
+		if pw.isPodProfilingEnabled(pod) && pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil &&
+			(!requireReady || isPodReady(pod)) && (serving == nil || serving[pod.Name]) {
 			matchingPods = append(matchingPods, pod)
 		}
 	}
@@ -78,6 +151,36 @@ func (pw *PodWatcher) ListMatchingPods(ctx context.Context, config *profilingv1a
 	return matchingPods, nil
 }
 
+// MatchesSelector checks if a pod falls within the config's selector, regardless of
+// its annotation and phase
+func (pw *PodWatcher) MatchesSelector(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod) bool {
+	if pod.Namespace != selectorNamespace(config) {
+		return false
+	}
+
+	selector := labels.SelectorFromSet(config.Spec.Selector.LabelSelector)
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+// selectorNamespace returns the namespace a config selects pods in, its own
+// unless the selector names another
+func selectorNamespace(config *profilingv1alpha1.ProfilingConfig) string {
+	if config.Spec.Selector.Namespace != "" {
+		return config.Spec.Selector.Namespace
+	}
+	return config.Namespace
+}
+
+// isPodReady checks if the Ready condition of a pod is true
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // isPodProfilingEnabled checks if a pod has profiling enabled
 func (pw *PodWatcher) isPodProfilingEnabled(pod *corev1.Pod) bool {
 	if pod.Annotations == nil {
@@ -88,16 +191,27 @@ func (pw *PodWatcher) isPodProfilingEnabled(pod *corev1.Pod) bool {
 	return ok && value == "true"
 }
 
-// TrackPod starts tracking a pod for profiling
-func (pw *PodWatcher) TrackPod(pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig) {
+// TrackPod records that config selects a pod and starts tracking it for the config
+// that owns it. It reports whether config owns the pod; a config that is
+// outranked by another config selecting the same pod leaves tracking untouched.
+func (pw *PodWatcher) TrackPod(pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig) bool {
 	pw.mu.Lock()
 	defer pw.mu.Unlock()
 
 	key := pw.getPodKey(pod)
-
-	// Stop existing tracking if any
-	if existing, ok := pw.trackedPods[key]; ok {
-		pw.stopTrackingLocked(key, existing)
+	if pw.claims[key] == nil {
+		pw.claims[key] = make(map[string]*profilingv1alpha1.ProfilingConfig)
+	}
+	pw.claims[key][configKeyOf(config)] = config
+
+	owner := pw.ownerLocked(key)
+	if owner != config {
+		if tracked, ok := pw.trackedPods[key]; ok {
+			tracked.Config = owner
+		} else {
+			pw.trackedPods[key] = &TrackedPod{Pod: pod, Config: owner}
+		}
+		return false
 	}
 
 	tracked := &TrackedPod{
@@ -105,7 +219,86 @@ func (pw *PodWatcher) TrackPod(pod *corev1.Pod, config *profilingv1alpha1.Profil
 		Config: config,
 	}
 
+	// Stop existing tracking if any, keeping its cooldown and capture history
+	if existing, ok := pw.trackedPods[key]; ok {
+		lastTime, hasLastTime := pw.lastProfileTime[key]
+		captures, hasCaptures := pw.captures[key]
+		pw.stopTrackingLocked(key, existing)
+		if hasLastTime {
+			pw.lastProfileTime[key] = lastTime
+		}
+		// A recreated pod or a new pprof port deserves a fresh start
+		restarted := existing.Pod.UID != pod.UID ||
+			existing.Pod.Annotations[profiler.PprofPortAnnotation] != pod.Annotations[profiler.PprofPortAnnotation]
+		if hasCaptures {
+			if restarted {
+				captures.resetBackoff()
+			}
+			pw.captures[key] = captures
+		}
+		if !restarted {
+			tracked.probe = existing.probe
+		}
+	}
+
 	pw.trackedPods[key] = tracked
+	return true
+}
+
+// ownerLocked returns the config that outranks every other config selecting a pod
+// (must be called with lock held)
+func (pw *PodWatcher) ownerLocked(key string) *profilingv1alpha1.ProfilingConfig {
+	var owner *profilingv1alpha1.ProfilingConfig
+	for _, config := range pw.claims[key] {
+		if owner == nil || outranks(config, owner) {
+			owner = config
+		}
+	}
+	return owner
+}
+
+// outranks reports whether config a takes precedence over config b for a pod
+// both select: higher priority first, then the oldest config, then the lowest key
+func outranks(a, b *profilingv1alpha1.ProfilingConfig) bool {
+	if a.Spec.Priority != b.Spec.Priority {
+		return a.Spec.Priority > b.Spec.Priority
+	}
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return configKeyOf(a) < configKeyOf(b)
+}
+
+// Conflicts returns the pods a config selects together with other configs,
+// sorted by pod
+func (pw *PodWatcher) Conflicts(configKey string) []profilingv1alpha1.PodConflict {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+
+	var conflicts []profilingv1alpha1.PodConflict
+	for key, claims := range pw.claims {
+		if _, ok := claims[configKey]; !ok || len(claims) < 2 {
+			continue
+		}
+
+		configs := make([]string, 0, len(claims))
+		for claimant := range claims {
+			configs = append(configs, claimant)
+		}
+		sort.Strings(configs)
+
+		conflicts = append(conflicts, profilingv1alpha1.PodConflict{
+			Pod:     key,
+			Owner:   configKeyOf(pw.ownerLocked(key)),
+			Configs: configs,
+		})
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Pod < conflicts[j].Pod
+	})
+
+	return conflicts
 }
 
 // StopTrackingPod stops tracking a pod
@@ -117,6 +310,7 @@ func (pw *PodWatcher) StopTrackingPod(pod *corev1.Pod) {
 	if tracked, ok := pw.trackedPods[key]; ok {
 		pw.stopTrackingLocked(key, tracked)
 	}
+	delete(pw.claims, key)
 }
 
 // stopTrackingLocked stops tracking (must be called with lock held)
@@ -129,6 +323,89 @@ func (pw *PodWatcher) stopTrackingLocked(key string, tracked *TrackedPod) {
 	}
 	delete(pw.trackedPods, key)
 	delete(pw.lastProfileTime, key)
+	delete(pw.captures, key)
+}
+
+// PruneTrackedPods drops the claims of a config on pods that are not in current.
+// Pods still selected by another config are handed over to it; the others stop
+// being tracked and their keys are returned.
+func (pw *PodWatcher) PruneTrackedPods(configKey string, current []*corev1.Pod) []string {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	keep := make(map[string]struct{}, len(current))
+	for _, pod := range current {
+		keep[pw.getPodKey(pod)] = struct{}{}
+	}
+
+	var pruned []string
+	for key, claims := range pw.claims {
+		if _, ok := claims[configKey]; !ok {
+			continue
+		}
+		if _, ok := keep[key]; ok {
+			continue
+		}
+
+		delete(claims, configKey)
+		if len(claims) > 0 {
+			if tracked, ok := pw.trackedPods[key]; ok {
+				tracked.Config = pw.ownerLocked(key)
+			}
+			continue
+		}
+
+		delete(pw.claims, key)
+		if tracked, ok := pw.trackedPods[key]; ok {
+			pw.stopTrackingLocked(key, tracked)
+		}
+		pruned = append(pruned, key)
+	}
+
+	return pruned
+}
+
+// ClaimingConfigs returns the keys of the configs selecting tracked pods
+func (pw *PodWatcher) ClaimingConfigs() []string {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+
+	seen := make(map[string]struct{})
+	for _, claims := range pw.claims {
+		for configKey := range claims {
+			seen[configKey] = struct{}{}
+		}
+	}
+
+	configKeys := make([]string, 0, len(seen))
+	for configKey := range seen {
+		configKeys = append(configKeys, configKey)
+	}
+	sort.Strings(configKeys)
+	return configKeys
+}
+
+// PruneUntracked drops the cooldowns and capture state kept for pods that are
+// no longer tracked, for instance cooldowns restored for deleted pods, and
+// returns their keys
+func (pw *PodWatcher) PruneUntracked() []string {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	var pruned []string
+	for key := range pw.lastProfileTime {
+		if _, ok := pw.trackedPods[key]; !ok {
+			delete(pw.lastProfileTime, key)
+			pruned = append(pruned, key)
+		}
+	}
+	for key := range pw.captures {
+		if _, ok := pw.trackedPods[key]; !ok {
+			delete(pw.captures, key)
+			pruned = append(pruned, key)
+		}
+	}
+	return pruned
 }
 
 // GetTrackedPods returns all currently tracked pods
@@ -144,6 +421,21 @@ func (pw *PodWatcher) GetTrackedPods() []*TrackedPod {
 	return pods
 }
 
+// GetTrackedPodsForConfig returns the tracked pods owned by a config
+func (pw *PodWatcher) GetTrackedPodsForConfig(configKey string) []*TrackedPod {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+
+	var pods []*TrackedPod
+	for _, tracked := range pw.trackedPods {
+		if tracked.Config != nil && configKeyOf(tracked.Config) == configKey {
+			pods = append(pods, tracked)
+		}
+	}
+
+	return pods
+}
+
 // CanProfile checks if enough time has passed since last profile
 func (pw *PodWatcher) CanProfile(pod *corev1.Pod, cooldownSeconds int) bool {
 	pw.mu.RLock()
@@ -168,9 +460,175 @@ func (pw *PodWatcher) UpdateLastProfileTime(pod *corev1.Pod) {
 	pw.lastProfileTime[key] = time.Now()
 }
 
-// getPodKey generates a unique key for a pod
+// RecordCapture records the outcome of a capture attempt of a pod. Failed
+// captures back the pod off exponentially, and a pod failing too often is
+// quarantined. It reports whether this capture quarantined the pod.
+func (pw *PodWatcher) RecordCapture(pod *corev1.Pod, reason string, captureErr error) bool {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	key := pw.getPodKey(pod)
+	if _, ok := pw.trackedPods[key]; !ok {
+		return false
+	}
+
+	state, ok := pw.captures[key]
+	if !ok {
+		state = &podCaptureState{}
+		pw.captures[key] = state
+	}
+
+	state.lastTriggerReason = reason
+	if captureErr == nil {
+		// A successful capture proves the pprof endpoint reachable
+		pw.trackedPods[key].probe = &pprofProbe{state: profilingv1alpha1.PprofReachable}
+		state.lastCaptureTime = time.Now()
+		state.consecutiveFailures = 0
+		state.resetBackoff()
+		return false
+	}
+
+	state.consecutiveFailures++
+
+	// S3 being unavailable says nothing about the pod
+	if isUploadError(captureErr) {
+		return false
+	}
+
+	state.backoffFailures++
+	if state.backoffFailures >= quarantineFailures {
+		state.retryAfter = time.Now().Add(quarantineRetryInterval)
+		quarantined := !state.quarantined
+		state.quarantined = true
+		return quarantined
+	}
+	state.retryAfter = time.Now().Add(captureBackoff(state.backoffFailures))
+	return false
+}
+
+// ClaimProbe reports whether the pprof endpoint of a tracked pod is still to be
+// probed, claiming the probe so the pod is probed once. Pods are probed again
+// when recreated or their pprof port changes.
+func (pw *PodWatcher) ClaimProbe(pod *corev1.Pod) bool {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	tracked, ok := pw.trackedPods[pw.getPodKey(pod)]
+	if !ok || tracked.Pod.UID != pod.UID || tracked.probe != nil {
+		return false
+	}
+	tracked.probe = &pprofProbe{}
+	return true
+}
+
+// RecordProbe records the outcome of a claimed probe of a pod's pprof endpoint
+func (pw *PodWatcher) RecordProbe(pod *corev1.Pod, state profilingv1alpha1.PprofState, message string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	tracked, ok := pw.trackedPods[pw.getPodKey(pod)]
+	if !ok || tracked.Pod.UID != pod.UID || tracked.probe == nil || tracked.probe.state != "" {
+		return
+	}
+	tracked.probe.state = state
+	tracked.probe.message = message
+}
+
+// captureBackoff returns how long a pod is held back after failures failed
+// captures in a row
+func captureBackoff(failures int) time.Duration {
+	backoff := captureBackoffBase
+	for i := 1; i < failures && backoff < captureBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, captureBackoffMax)
+}
+
+// InBackoff reports whether captures of a pod are held back after failed captures
+func (pw *PodWatcher) InBackoff(pod *corev1.Pod) bool {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+
+	state, ok := pw.captures[pw.getPodKey(pod)]
+	return ok && time.Now().Before(state.retryAfter)
+}
+
+// Quarantined reports whether a pod is quarantined after repeated failed captures
+func (pw *PodWatcher) Quarantined(pod *corev1.Pod) bool {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+
+	state, ok := pw.captures[pw.getPodKey(pod)]
+	return ok && state.quarantined
+}
+
+// ProfiledPods returns the capture state of the pods owned by a config, sorted
+// by pod. Pods annotated with their own cooldown use it instead of cooldownSeconds.
+func (pw *PodWatcher) ProfiledPods(configKey string, cooldownSeconds int) []profilingv1alpha1.ProfiledPod {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+
+	var pods []profilingv1alpha1.ProfiledPod
+	for key, tracked := range pw.trackedPods {
+		if tracked.Config == nil || configKeyOf(tracked.Config) != configKey {
+			continue
+		}
+
+		profiled := profilingv1alpha1.ProfiledPod{Pod: key}
+		if lastTime, ok := pw.lastProfileTime[key]; ok {
+			thresholds, _ := podThresholds(tracked.Pod, profilingv1alpha1.ThresholdConfig{CooldownSeconds: cooldownSeconds})
+			profiled.InCooldown = time.Since(lastTime) <= time.Duration(thresholds.CooldownSeconds)*time.Second
+			cooldownStart := metav1.NewTime(lastTime)
+			profiled.CooldownStartTime = &cooldownStart
+		}
+		if state, ok := pw.captures[key]; ok {
+			if !state.lastCaptureTime.IsZero() {
+				lastCapture := metav1.NewTime(state.lastCaptureTime)
+				profiled.LastCaptureTime = &lastCapture
+			}
+			profiled.LastTriggerReason = state.lastTriggerReason
+			profiled.ConsecutiveFailures = state.consecutiveFailures
+			profiled.Quarantined = state.quarantined
+			if time.Now().Before(state.retryAfter) {
+				retryAfter := metav1.NewTime(state.retryAfter)
+				profiled.RetryAfter = &retryAfter
+			}
+		}
+		if probe := tracked.probe; probe != nil {
+			profiled.Pprof = probe.state
+			profiled.PprofMessage = probe.message
+		}
+		pods = append(pods, profiled)
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Pod < pods[j].Pod
+	})
+
+	return pods
+}
+
+// RestoreLastProfileTime records a capture time for a pod key, for instance one read
+// back from a ProfileCapture after a restart. Newer times already known are kept.
+func (pw *PodWatcher) RestoreLastProfileTime(key string, profileTime time.Time) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if existing, ok := pw.lastProfileTime[key]; ok && !profileTime.After(existing) {
+		return
+	}
+	pw.lastProfileTime[key] = profileTime
+}
+
+// getPodKey generates a unique key for a pod, prefixed with its cluster for
+// pods of a remote cluster
 func (pw *PodWatcher) getPodKey(pod *corev1.Pod) string {
-	return pod.Namespace + "/" + pod.Name
+	return podKey(podCluster(pod), pod.Namespace, pod.Name)
+}
+
+// configKeyOf returns the namespace/name key of a config
+func configKeyOf(config *profilingv1alpha1.ProfilingConfig) string {
+	return config.Namespace + "/" + config.Name
 }
 
 // GetActivePodCount returns the number of tracked pods
@@ -2,24 +2,41 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
 )
 
+// newTestPodClient creates a fake client serving pods to a PodWatcher
+func newTestPodClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	return fakeclient.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		Build()
+}
+
 func TestNewPodWatcher(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	if watcher == nil {
 		t.Fatal("Expected non-nil PodWatcher")
 	}
 
-	if watcher.clientset == nil {
-		t.Error("Expected clientset to be set")
+	if watcher.reader == nil {
+		t.Error("Expected reader to be set")
 	}
 
 	if watcher.trackedPods == nil {
@@ -32,17 +49,17 @@ func TestNewPodWatcher(t *testing.T) {
 }
 
 func TestPodWatcher_ListMatchingPods(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	// Create test pods
 	pod1 := createTestPod("pod-1", "default", true)
 	pod2 := createTestPod("pod-2", "default", true)
 	pod3 := createTestPod("pod-3", "default", false) // No annotation
 
-	_, _ = clientset.CoreV1().Pods("default").Create(context.Background(), pod1, metav1.CreateOptions{})
-	_, _ = clientset.CoreV1().Pods("default").Create(context.Background(), pod2, metav1.CreateOptions{})
-	_, _ = clientset.CoreV1().Pods("default").Create(context.Background(), pod3, metav1.CreateOptions{})
+	_ = podClient.Create(context.Background(), pod1)
+	_ = podClient.Create(context.Background(), pod2)
+	_ = podClient.Create(context.Background(), pod3)
 
 	config := createTestProfilingConfig("test-config", "default")
 
@@ -57,9 +74,46 @@ func TestPodWatcher_ListMatchingPods(t *testing.T) {
 	}
 }
 
+func TestPodWatcher_ListMatchingPods_RequireReady(t *testing.T) {
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
+
+	ready := createTestPod("ready-pod", "default", true)
+	unready := createTestPod("unready-pod", "default", true)
+	unready.Status.Conditions[0].Status = corev1.ConditionFalse
+	starting := createTestPod("starting-pod", "default", true)
+	starting.Status.Conditions = nil
+
+	for _, pod := range []*corev1.Pod{ready, unready, starting} {
+		_ = podClient.Create(context.Background(), pod)
+	}
+
+	config := createTestProfilingConfig("test-config", "default")
+
+	// Only ready pods are profiled by default
+	pods, err := watcher.ListMatchingPods(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Failed to list pods: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "ready-pod" {
+		t.Errorf("Expected only ready-pod, got %d pods", len(pods))
+	}
+
+	// Configs may profile pods failing readiness
+	requireReady := false
+	config.Spec.Selector.RequireReady = &requireReady
+	pods, err = watcher.ListMatchingPods(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Failed to list pods: %v", err)
+	}
+	if len(pods) != 3 {
+		t.Errorf("Expected 3 matching pods, got %d", len(pods))
+	}
+}
+
 func TestPodWatcher_ListMatchingPods_WithLabels(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	// Create pods with different labels
 	pod1 := createTestPod("pod-1", "default", true)
@@ -68,8 +122,8 @@ func TestPodWatcher_ListMatchingPods_WithLabels(t *testing.T) {
 	pod2 := createTestPod("pod-2", "default", true)
 	pod2.Labels["app"] = "other-app"
 
-	_, _ = clientset.CoreV1().Pods("default").Create(context.Background(), pod1, metav1.CreateOptions{})
-	_, _ = clientset.CoreV1().Pods("default").Create(context.Background(), pod2, metav1.CreateOptions{})
+	_ = podClient.Create(context.Background(), pod1)
+	_ = podClient.Create(context.Background(), pod2)
 
 	config := createTestProfilingConfig("test-config", "default")
 	config.Spec.Selector.LabelSelector = map[string]string{"app": "test-app"}
@@ -90,14 +144,14 @@ func TestPodWatcher_ListMatchingPods_WithLabels(t *testing.T) {
 }
 
 func TestPodWatcher_ListMatchingPods_DifferentNamespace(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod1 := createTestPod("pod-1", "namespace-a", true)
 	pod2 := createTestPod("pod-2", "namespace-b", true)
 
-	_, _ = clientset.CoreV1().Pods("namespace-a").Create(context.Background(), pod1, metav1.CreateOptions{})
-	_, _ = clientset.CoreV1().Pods("namespace-b").Create(context.Background(), pod2, metav1.CreateOptions{})
+	_ = podClient.Create(context.Background(), pod1)
+	_ = podClient.Create(context.Background(), pod2)
 
 	config := createTestProfilingConfig("test-config", "default")
 	config.Spec.Selector.Namespace = "namespace-a"
@@ -113,13 +167,13 @@ func TestPodWatcher_ListMatchingPods_DifferentNamespace(t *testing.T) {
 }
 
 func TestPodWatcher_ListMatchingPods_NonRunningPod(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 	pod.Status.Phase = corev1.PodPending
 
-	_, _ = clientset.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+	_ = podClient.Create(context.Background(), pod)
 
 	config := createTestProfilingConfig("test-config", "default")
 
@@ -135,8 +189,8 @@ func TestPodWatcher_ListMatchingPods_NonRunningPod(t *testing.T) {
 }
 
 func TestPodWatcher_TrackPod(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 	config := createTestProfilingConfig("test-config", "default")
@@ -158,19 +212,24 @@ func TestPodWatcher_TrackPod(t *testing.T) {
 	}
 }
 
-func TestPodWatcher_TrackPod_ReplaceExisting(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+func TestPodWatcher_TrackPod_HigherPriorityReplaces(t *testing.T) {
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 	config1 := createTestProfilingConfig("config-1", "default")
 	config2 := createTestProfilingConfig("config-2", "default")
+	config2.Spec.Priority = 10
 
 	// Track with first config
-	watcher.TrackPod(pod, config1)
+	if !watcher.TrackPod(pod, config1) {
+		t.Error("Expected config-1 to own the pod")
+	}
 
-	// Track again with second config (should replace)
-	watcher.TrackPod(pod, config2)
+	// Track again with a higher priority config (should replace)
+	if !watcher.TrackPod(pod, config2) {
+		t.Error("Expected config-2 to own the pod")
+	}
 
 	tracked := watcher.GetTrackedPods()
 	if len(tracked) != 1 {
@@ -180,11 +239,162 @@ func TestPodWatcher_TrackPod_ReplaceExisting(t *testing.T) {
 	if len(tracked) > 0 && tracked[0].Config.Name != "config-2" {
 		t.Errorf("Expected config 'config-2', got '%s'", tracked[0].Config.Name)
 	}
+
+	// The outranked config does not take the pod back
+	if watcher.TrackPod(pod, config1) {
+		t.Error("Expected config-1 not to own the pod")
+	}
+	if owned := watcher.GetTrackedPodsForConfig("default/config-1"); len(owned) != 0 {
+		t.Errorf("Expected config-1 to own no pods, got %d", len(owned))
+	}
+}
+
+func TestPodWatcher_TrackPod_TieBreak(t *testing.T) {
+	older := createTestProfilingConfig("config-b", "default")
+	older.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	newer := createTestProfilingConfig("config-a", "default")
+	newer.CreationTimestamp = metav1.NewTime(time.Now())
+
+	tests := []struct {
+		name     string
+		first    *profilingv1alpha1.ProfilingConfig
+		second   *profilingv1alpha1.ProfilingConfig
+		expected string
+	}{
+		{
+			name:     "oldest config wins",
+			first:    newer,
+			second:   older,
+			expected: "config-b",
+		},
+		{
+			name:     "lowest key wins when created together",
+			first:    createTestProfilingConfig("config-2", "default"),
+			second:   createTestProfilingConfig("config-1", "default"),
+			expected: "config-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watcher := NewPodWatcher(newTestPodClient())
+			pod := createTestPod("pod-1", "default", true)
+
+			watcher.TrackPod(pod, tt.first)
+			watcher.TrackPod(pod, tt.second)
+
+			tracked := watcher.GetTrackedPods()
+			if len(tracked) != 1 || tracked[0].Config.Name != tt.expected {
+				t.Errorf("Expected pod to be owned by %s, got %+v", tt.expected, tracked)
+			}
+		})
+	}
+}
+
+func TestPodWatcher_Conflicts(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+
+	config1 := createTestProfilingConfig("config-1", "default")
+	config2 := createTestProfilingConfig("config-2", "default")
+	shared := createTestPod("pod-1", "default", true)
+	exclusive := createTestPod("pod-2", "default", true)
+
+	watcher.TrackPod(shared, config1)
+	watcher.TrackPod(exclusive, config1)
+	watcher.TrackPod(shared, config2)
+
+	expected := []profilingv1alpha1.PodConflict{{
+		Pod:     "default/pod-1",
+		Owner:   "default/config-1",
+		Configs: []string{"default/config-1", "default/config-2"},
+	}}
+
+	// Both configs see the conflict
+	for _, key := range []string{"default/config-1", "default/config-2"} {
+		if conflicts := watcher.Conflicts(key); !reflect.DeepEqual(conflicts, expected) {
+			t.Errorf("Expected conflicts of %s to be %+v, got %+v", key, expected, conflicts)
+		}
+	}
+
+	if conflicts := watcher.Conflicts("default/other"); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts for an unrelated config, got %+v", conflicts)
+	}
+}
+
+func TestPodWatcher_PruneTrackedPods_HandsOver(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+
+	owner := createTestProfilingConfig("config-1", "default")
+	other := createTestProfilingConfig("config-2", "default")
+	pod := createTestPod("pod-1", "default", true)
+
+	watcher.TrackPod(pod, owner)
+	watcher.TrackPod(pod, other)
+	watcher.UpdateLastProfileTime(pod)
+
+	// The owner stops selecting the pod, the other config takes over
+	if pruned := watcher.PruneTrackedPods("default/config-1", nil); len(pruned) != 0 {
+		t.Errorf("Expected no pods to be pruned, got %v", pruned)
+	}
+
+	owned := watcher.GetTrackedPodsForConfig("default/config-2")
+	if len(owned) != 1 {
+		t.Fatalf("Expected config-2 to own the pod, got %d pods", len(owned))
+	}
+	if watcher.CanProfile(pod, 300) {
+		t.Error("Expected the cooldown to carry over to the new owner")
+	}
+	if conflicts := watcher.Conflicts("default/config-2"); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts left, got %+v", conflicts)
+	}
+
+	if pruned := watcher.PruneTrackedPods("default/config-2", nil); len(pruned) != 1 {
+		t.Errorf("Expected the pod to be pruned, got %v", pruned)
+	}
+}
+
+func TestPodWatcher_TrackPod_KeepsCooldown(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+
+	pod := createTestPod("pod-1", "default", true)
+	config := createTestProfilingConfig("test-config", "default")
+
+	watcher.TrackPod(pod, config)
+	watcher.UpdateLastProfileTime(pod)
+
+	// Re-tracking on the next reconcile must not reset the cooldown
+	watcher.TrackPod(pod, config)
+
+	if watcher.CanProfile(pod, 300) {
+		t.Error("Expected pod to stay in cooldown after re-tracking")
+	}
+}
+
+func TestPodWatcher_RestoreLastProfileTime(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+	pod := createTestPod("pod-1", "default", true)
+	key := watcher.getPodKey(pod)
+
+	watcher.RestoreLastProfileTime(key, time.Now().Add(-10*time.Minute))
+	if !watcher.CanProfile(pod, 300) {
+		t.Error("Expected old capture not to block profiling")
+	}
+
+	watcher.RestoreLastProfileTime(key, time.Now().Add(-time.Minute))
+	if watcher.CanProfile(pod, 300) {
+		t.Error("Expected recent capture to start a cooldown")
+	}
+
+	// Older times never replace newer ones
+	watcher.RestoreLastProfileTime(key, time.Now().Add(-time.Hour))
+	if watcher.CanProfile(pod, 300) {
+		t.Error("Expected cooldown to keep the newest capture time")
+	}
 }
 
 func TestPodWatcher_StopTrackingPod(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 	config := createTestProfilingConfig("test-config", "default")
@@ -204,9 +414,54 @@ func TestPodWatcher_StopTrackingPod(t *testing.T) {
 	}
 }
 
+func TestPodWatcher_PruneTrackedPods(t *testing.T) {
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
+
+	config := createTestProfilingConfig("test-config", "default")
+	other := createTestProfilingConfig("other-config", "default")
+
+	pod1 := createTestPod("pod-1", "default", true)
+	pod2 := createTestPod("pod-2", "default", true)
+	pod3 := createTestPod("pod-3", "default", true)
+
+	watcher.TrackPod(pod1, config)
+	watcher.TrackPod(pod2, config)
+	watcher.TrackPod(pod3, other)
+
+	pruned := watcher.PruneTrackedPods("default/test-config", []*corev1.Pod{pod1})
+	if len(pruned) != 1 || pruned[0] != "default/pod-2" {
+		t.Errorf("Expected default/pod-2 to be pruned, got %v", pruned)
+	}
+
+	// Pods of other configs are left alone
+	if count := watcher.GetActivePodCount(); count != 2 {
+		t.Errorf("Expected 2 tracked pods, got %d", count)
+	}
+}
+
+func TestPodWatcher_MatchesSelector(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+	config := createTestProfilingConfig("test-config", "default")
+
+	if !watcher.MatchesSelector(config, createTestPod("pod-1", "default", false)) {
+		t.Error("Expected pod with matching labels to match")
+	}
+
+	if watcher.MatchesSelector(config, createTestPod("pod-1", "other", true)) {
+		t.Error("Expected pod in another namespace not to match")
+	}
+
+	unlabeled := createTestPod("pod-1", "default", true)
+	unlabeled.Labels = nil
+	if watcher.MatchesSelector(config, unlabeled) {
+		t.Error("Expected pod without labels not to match")
+	}
+}
+
 func TestPodWatcher_GetTrackedPods(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	config := createTestProfilingConfig("test-config", "default")
 
@@ -232,8 +487,8 @@ func TestPodWatcher_GetTrackedPods(t *testing.T) {
 }
 
 func TestPodWatcher_CanProfile_FirstTime(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 
@@ -244,8 +499,8 @@ func TestPodWatcher_CanProfile_FirstTime(t *testing.T) {
 }
 
 func TestPodWatcher_CanProfile_WithinCooldown(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 
@@ -259,8 +514,8 @@ func TestPodWatcher_CanProfile_WithinCooldown(t *testing.T) {
 }
 
 func TestPodWatcher_CanProfile_AfterCooldown(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 
@@ -277,8 +532,8 @@ func TestPodWatcher_CanProfile_AfterCooldown(t *testing.T) {
 }
 
 func TestPodWatcher_UpdateLastProfileTime(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("pod-1", "default", true)
 
@@ -301,8 +556,8 @@ func TestPodWatcher_UpdateLastProfileTime(t *testing.T) {
 }
 
 func TestPodWatcher_GetActivePodCount(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	config := createTestProfilingConfig("test-config", "default")
 
@@ -333,8 +588,8 @@ func TestPodWatcher_GetActivePodCount(t *testing.T) {
 }
 
 func TestPodWatcher_IsPodProfilingEnabled(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	tests := []struct {
 		name     string
@@ -387,8 +642,8 @@ func TestPodWatcher_IsPodProfilingEnabled(t *testing.T) {
 }
 
 func TestPodWatcher_GetPodKey(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	pod := createTestPod("test-pod", "test-namespace", true)
 
@@ -401,8 +656,8 @@ func TestPodWatcher_GetPodKey(t *testing.T) {
 }
 
 func TestPodWatcher_ConcurrentAccess(t *testing.T) {
-	clientset := fake.NewSimpleClientset()
-	watcher := NewPodWatcher(clientset)
+	podClient := newTestPodClient()
+	watcher := NewPodWatcher(podClient)
 
 	config := createTestProfilingConfig("test-config", "default")
 
@@ -435,3 +690,119 @@ func TestPodWatcher_ConcurrentAccess(t *testing.T) {
 
 	// If we get here without deadlock or race, the test passes
 }
+
+func TestPodWatcher_ProfiledPods(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+	config := createTestProfilingConfig("test-config", "default")
+	other := createTestProfilingConfig("other-config", "default")
+
+	pod1 := createTestPod("pod-1", "default", true)
+	pod2 := createTestPod("pod-2", "default", true)
+	pod3 := createTestPod("pod-3", "default", true)
+	watcher.TrackPod(pod2, config)
+	watcher.TrackPod(pod1, config)
+	watcher.TrackPod(pod3, other)
+
+	// pod-1 captured once, then failed twice
+	watcher.RecordCapture(pod1, "cpu", nil)
+	watcher.UpdateLastProfileTime(pod1)
+	watcher.RecordCapture(pod1, "memory", errors.New("connection refused"))
+	watcher.RecordCapture(pod1, "on-demand", errors.New("connection refused"))
+
+	pods := watcher.ProfiledPods("default/test-config", 300)
+	if len(pods) != 2 {
+		t.Fatalf("Expected 2 profiled pods, got %d", len(pods))
+	}
+
+	first := pods[0]
+	if first.Pod != "default/pod-1" || first.LastCaptureTime == nil || first.LastTriggerReason != "on-demand" ||
+		first.ConsecutiveFailures != 2 || !first.InCooldown {
+		t.Errorf("Unexpected state for pod-1: %+v", first)
+	}
+
+	second := pods[1]
+	if second.Pod != "default/pod-2" || second.LastCaptureTime != nil || second.ConsecutiveFailures != 0 || second.InCooldown {
+		t.Errorf("Unexpected state for pod-2: %+v", second)
+	}
+
+	// A successful capture resets the failure count
+	watcher.RecordCapture(pod1, "cpu", nil)
+	if pods := watcher.ProfiledPods("default/test-config", 300); pods[0].ConsecutiveFailures != 0 {
+		t.Errorf("Expected failures to reset after a success, got %d", pods[0].ConsecutiveFailures)
+	}
+
+	// Untracked pods are not recorded
+	watcher.RecordCapture(createTestPod("pod-4", "default", true), "cpu", nil)
+	if pods := watcher.ProfiledPods("default/test-config", 300); len(pods) != 2 {
+		t.Errorf("Expected untracked pod to be ignored, got %d pods", len(pods))
+	}
+}
+
+func TestPodWatcher_CaptureBackoff(t *testing.T) {
+	watcher := NewPodWatcher(newTestPodClient())
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("pod-1", "default", true)
+	watcher.TrackPod(pod, config)
+
+	captureErr := errors.New("connection refused")
+
+	// Upload failures do not back the pod off
+	watcher.RecordCapture(pod, "cpu", &uploadError{errors.New("access denied")})
+	if watcher.InBackoff(pod) {
+		t.Error("Expected upload failures not to back off the pod")
+	}
+
+	for i := 1; i < quarantineFailures; i++ {
+		if watcher.RecordCapture(pod, "cpu", captureErr) {
+			t.Fatalf("Expected no quarantine after %d failures", i)
+		}
+	}
+	if !watcher.InBackoff(pod) {
+		t.Error("Expected the pod to be backed off after failed captures")
+	}
+
+	if !watcher.RecordCapture(pod, "cpu", captureErr) {
+		t.Fatal("Expected the pod to be quarantined")
+	}
+	if watcher.RecordCapture(pod, "cpu", captureErr) {
+		t.Error("Expected quarantine to be reported once")
+	}
+
+	profiled := watcher.ProfiledPods("default/test-config", 300)[0]
+	if !profiled.Quarantined || profiled.RetryAfter == nil {
+		t.Errorf("Expected the quarantine in status, got %+v", profiled)
+	}
+
+	// A recreated pod starts afresh
+	recreated := createTestPod("pod-1", "default", true)
+	recreated.UID = "new-uid"
+	watcher.TrackPod(recreated, config)
+	if watcher.InBackoff(recreated) {
+		t.Error("Expected a recreated pod not to inherit the quarantine")
+	}
+
+	// So does a pod that captures successfully
+	watcher.RecordCapture(recreated, "cpu", captureErr)
+	watcher.RecordCapture(recreated, "cpu", nil)
+	if watcher.InBackoff(recreated) {
+		t.Error("Expected a successful capture to lift the backoff")
+	}
+}
+
+func TestCaptureBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 30 * time.Second},
+		{failures: 2, expected: time.Minute},
+		{failures: 4, expected: 4 * time.Minute},
+		{failures: 20, expected: captureBackoffMax},
+	}
+
+	for _, tt := range tests {
+		if backoff := captureBackoff(tt.failures); backoff != tt.expected {
+			t.Errorf("captureBackoff(%d) = %v, expected %v", tt.failures, backoff, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+// pprofProbeTimeout bounds a probe of a pod's pprof endpoint, including the
+// wait for a port-forward slot
+const pprofProbeTimeout = 30 * time.Second
+
+// probePprof probes the pprof endpoint of a pod in the background the first
+// time the config tracks it. Unreachable endpoints are reported in the pod's
+// status and with an event, rather than failing silently at capture time.
+func (r *ProfilingConfigReconciler) probePprof(ctx context.Context, cluster *targetCluster, config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod) {
+	prober, ok := cluster.profiler.(profiler.Prober)
+	if !ok || !r.podWatcher.ClaimProbe(pod) {
+		return
+	}
+
+	probeCtx := r.monitorContext(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(probeCtx, pprofProbeTimeout)
+		defer cancel()
+		logger := log.FromContext(ctx)
+		key := r.podWatcher.getPodKey(pod)
+
+		err := prober.Probe(ctx, pod)
+		if err == nil {
+			r.podWatcher.RecordProbe(pod, profilingv1alpha1.PprofReachable, "")
+			return
+		}
+
+		// Tell an application without pprof apart from network and RBAC problems
+		if hint := pprofNotEnabled(pod, err); hint != "" {
+			r.podWatcher.RecordProbe(pod, profilingv1alpha1.PprofNotEnabled, hint)
+			logger.Info("Pprof not enabled", "pod", pod.Name, "error", err.Error(), "hint", hint)
+			r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonPprofNotEnabled,
+				"Pod %s does not seem to serve pprof: %s", key, hint)
+			return
+		}
+
+		r.podWatcher.RecordProbe(pod, profilingv1alpha1.PprofUnreachable, err.Error())
+		logger.Info("Pprof endpoint unreachable", "pod", pod.Name, "error", err.Error())
+		r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonPprofUnreachable,
+			"Pprof endpoint of pod %s is unreachable, its captures will fail: %v", key, err)
+	}()
+}
+
+// pprofNotEnabled returns why a failed probe of a pod suggests its application
+// does not serve pprof, empty if the failure may as well be a network or RBAC
+// problem. A pod answering 404 has no pprof handlers, and a pod declaring
+// container ports but not the pprof port likely does not listen on it. Image
+// labels are not inspected, as that needs access to the registry.
+func pprofNotEnabled(pod *corev1.Pod, probeErr error) string {
+	if apierrors.IsForbidden(probeErr) || strings.Contains(probeErr.Error(), "forbidden") {
+		return ""
+	}
+
+	var statusErr *profiler.StatusError
+	if errors.As(probeErr, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return fmt.Sprintf("%s answered %d, register the net/http/pprof handlers", statusErr.Path, statusErr.StatusCode)
+	}
+
+	port := profiler.PprofPort(pod)
+	declared := false
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			if int(containerPort.ContainerPort) == port {
+				return ""
+			}
+			declared = true
+		}
+	}
+	if declared {
+		return fmt.Sprintf("no container exposes the pprof port %d, serve pprof on it or set the %s annotation",
+			port, profiler.PprofPortAnnotation)
+	}
+	return ""
+}
+
+// setPprofCondition reports the profiled pods that seem not to serve pprof
+func setPprofCondition(config *profilingv1alpha1.ProfilingConfig) {
+	var pods []string
+	for _, profiled := range config.Status.ProfiledPods {
+		if profiled.Pprof == profilingv1alpha1.PprofNotEnabled {
+			pods = append(pods, profiled.Pod)
+		}
+	}
+
+	if len(pods) == 0 {
+		setCondition(config, ConditionPprofNotEnabled, metav1.ConditionFalse, reasonPprofServed,
+			"No profiled pod is missing pprof")
+		return
+	}
+	setCondition(config, ConditionPprofNotEnabled, metav1.ConditionTrue, reasonPprofNotEnabled,
+		"Pods do not seem to serve pprof: "+strings.Join(pods, ", "))
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+func TestReconcile_ProbesPprof(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+	reconciler.profiler = &profiler.Fake{ProbeErr: errors.New("connection refused")}
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	defer reconciler.stopMonitoring("default/test-config")
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-config", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	var profiled []profilingv1alpha1.ProfiledPod
+	waitFor(t, time.Second, func() bool {
+		profiled = reconciler.podWatcher.ProfiledPods("default/test-config", 300)
+		return len(profiled) == 1 && profiled[0].Pprof != ""
+	})
+	if profiled[0].Pprof != profilingv1alpha1.PprofUnreachable || profiled[0].PprofMessage != "connection refused" {
+		t.Errorf("Expected the pod to be reported unreachable, got %+v", profiled[0])
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, reasonPprofUnreachable) {
+			t.Errorf("Expected a %s event, got %q", reasonPprofUnreachable, event)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a PprofUnreachable event")
+	}
+
+	// The pod is probed once, a successful capture marks it reachable
+	if reconciler.podWatcher.ClaimProbe(pod) {
+		t.Error("Expected the probe to be claimed only once")
+	}
+	reconciler.podWatcher.RecordCapture(pod, "test", nil)
+	if profiled := reconciler.podWatcher.ProfiledPods("default/test-config", 300); profiled[0].Pprof != profilingv1alpha1.PprofReachable {
+		t.Errorf("Expected the pod to be reachable after a capture, got %+v", profiled[0])
+	}
+
+	// A recreated pod is probed again
+	recreated := pod.DeepCopy()
+	recreated.UID = "recreated"
+	reconciler.podWatcher.TrackPod(recreated, config)
+	if !reconciler.podWatcher.ClaimProbe(recreated) {
+		t.Error("Expected a recreated pod to be probed again")
+	}
+}
+
+func TestPprofNotEnabled(t *testing.T) {
+	withPorts := func(ports ...int32) *corev1.Pod {
+		pod := createTestPod("test-pod", "default", true)
+		for _, port := range ports {
+			pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, corev1.ContainerPort{ContainerPort: port})
+		}
+		return pod
+	}
+	refused := errors.New("dial tcp 127.0.0.1:6060: connect: connection refused")
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "pods/portforward"}, "test-pod", errors.New("denied"))
+
+	tests := []struct {
+		name       string
+		pod        *corev1.Pod
+		err        error
+		notEnabled bool
+	}{
+		{"no pprof handlers", withPorts(), &profiler.StatusError{Path: "/debug/pprof/", StatusCode: 404}, true},
+		{"pprof port not exposed", withPorts(8080), refused, true},
+		{"pprof port exposed", withPorts(8080, 6060), refused, false},
+		{"no ports declared", withPorts(), refused, false},
+		{"port-forward forbidden", withPorts(8080), forbidden, false},
+		{"server error", withPorts(), &profiler.StatusError{Path: "/debug/pprof/", StatusCode: 500}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hint := pprofNotEnabled(tt.pod, tt.err); (hint != "") != tt.notEnabled {
+				t.Errorf("pprofNotEnabled() = %q, expected not enabled %v", hint, tt.notEnabled)
+			}
+		})
+	}
+}
+
+func TestSetPprofCondition(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Status.ProfiledPods = []profilingv1alpha1.ProfiledPod{
+		{Pod: "default/app-1", Pprof: profilingv1alpha1.PprofReachable},
+		{Pod: "default/app-2", Pprof: profilingv1alpha1.PprofNotEnabled},
+		{Pod: "default/app-3", Pprof: profilingv1alpha1.PprofUnreachable},
+	}
+
+	setPprofCondition(config)
+	condition := apimeta.FindStatusCondition(config.Status.Conditions, ConditionPprofNotEnabled)
+	if condition == nil || condition.Reason != reasonPprofNotEnabled || !strings.HasSuffix(condition.Message, ": default/app-2") {
+		t.Errorf("Expected PprofNotEnabled=True naming default/app-2, got %+v", condition)
+	}
+}
@@ -0,0 +1,353 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// DefaultCaptureHistoryLimit is the number of ProfileCaptures kept per config by default
+const DefaultCaptureHistoryLimit = 20
+
+// newProfileCapture builds the ProfileCapture record for a capture of a pod
+func newProfileCapture(config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, profileTypes []string, trigger metrics.Trigger) *profilingv1alpha1.ProfileCapture {
+	return &profilingv1alpha1.ProfileCapture{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: config.Name + "-",
+			Namespace:    config.Namespace,
+			Labels: map[string]string{
+				profilingv1alpha1.ConfigNameLabel: config.Name,
+			},
+		},
+		Spec: profilingv1alpha1.ProfileCaptureSpec{
+			ConfigName:   config.Name,
+			Cluster:      podCluster(pod),
+			PodName:      pod.Name,
+			PodNamespace: pod.Namespace,
+			ProfileTypes: profileTypes,
+			Reason:       trigger.Reason,
+		},
+	}
+}
+
+// NewCaptureRequest builds a ProfileCapture requesting an immediate capture,
+// through a config, of the pod with the given key: namespace/name, prefixed
+// with the cluster for pods of a remote cluster. Empty profileTypes take the
+// config's.
+func NewCaptureRequest(config *profilingv1alpha1.ProfilingConfig, key string, profileTypes []string) (*profilingv1alpha1.ProfileCapture, error) {
+	pod := &corev1.Pod{}
+	switch parts := strings.Split(key, "/"); len(parts) {
+	case 2:
+		pod.Namespace, pod.Name = parts[0], parts[1]
+	case 3:
+		pod.Namespace, pod.Name = parts[1], parts[2]
+		pod.Annotations = map[string]string{ClusterAnnotation: parts[0]}
+	default:
+		return nil, fmt.Errorf("invalid pod %q, must be namespace/name or cluster/namespace/name", key)
+	}
+
+	capture := newProfileCapture(config, pod, profileTypes, metrics.Trigger{Reason: profilingv1alpha1.RequestedReason})
+	capture.Labels[profilingv1alpha1.RequestedLabel] = "true"
+	return capture, nil
+}
+
+// triggerMetricsFor converts a trigger into its status representation, nil if the
+// trigger carries no metrics
+func triggerMetricsFor(trigger metrics.Trigger) *profilingv1alpha1.TriggerMetrics {
+	if trigger.Metrics == nil {
+		return nil
+	}
+
+	return &profilingv1alpha1.TriggerMetrics{
+		CPUUsagePercent:        strconv.FormatFloat(trigger.Metrics.CPUUsagePercent, 'f', 2, 64),
+		MemoryUsagePercent:     strconv.FormatFloat(trigger.Metrics.MemoryUsagePercent, 'f', 2, 64),
+		CPUUsage:               trigger.Metrics.CPUUsage.DeepCopy(),
+		MemoryUsage:            trigger.Metrics.MemoryUsage.DeepCopy(),
+		CPUThresholdPercent:    trigger.CPUThresholdPercent,
+		MemoryThresholdPercent: trigger.MemoryThresholdPercent,
+	}
+}
+
+// startCapture records the start of a capture as a ProfileCapture. Failures are
+// logged and a nil record is returned so that profiling is never blocked on it.
+func (r *ProfilingConfigReconciler) startCapture(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, profileTypes []string, trigger metrics.Trigger) *profilingv1alpha1.ProfileCapture {
+	logger := log.FromContext(ctx)
+
+	capture := newProfileCapture(config, pod, profileTypes, trigger)
+	if err := controllerutil.SetControllerReference(config, capture, r.Scheme); err != nil {
+		logger.Error(err, "Failed to set owner on ProfileCapture")
+	}
+
+	if err := r.Create(ctx, capture); err != nil {
+		logger.Error(err, "Failed to create ProfileCapture", "pod", pod.Name)
+		return nil
+	}
+
+	now := metav1.Now()
+	capture.Status.Phase = profilingv1alpha1.CapturePhaseRunning
+	capture.Status.StartTime = &now
+	capture.Status.Trigger = triggerMetricsFor(trigger)
+	if err := r.Status().Update(ctx, capture); err != nil {
+		logger.Error(err, "Failed to update ProfileCapture status", "capture", capture.Name)
+	}
+
+	return capture
+}
+
+// finishCapture records the outcome of a capture and prunes old records
+func (r *ProfilingConfigReconciler) finishCapture(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, capture *profilingv1alpha1.ProfileCapture, manifest *uploader.Manifest, captureErr error) {
+	if capture == nil {
+		return
+	}
+
+	// Record the outcome even when the capture was cancelled by monitoring stopping
+	ctx = context.WithoutCancel(ctx)
+	logger := log.FromContext(ctx)
+
+	now := metav1.Now()
+	capture.Status.CompletionTime = &now
+	if captureErr != nil {
+		capture.Status.Phase = profilingv1alpha1.CapturePhaseFailed
+		capture.Status.Message = captureErr.Error()
+	} else {
+		capture.Status.Phase = profilingv1alpha1.CapturePhaseSucceeded
+	}
+
+	if manifest != nil {
+		capture.Status.Bucket = manifest.Bucket
+		capture.Status.ManifestKey = manifest.Key
+		capture.Status.ObjectKeys = nil
+		for _, object := range manifest.Objects {
+			capture.Status.ObjectKeys = append(capture.Status.ObjectKeys, object.Key)
+		}
+		capture.Status.Regression = regressionResultOf(manifest)
+		setProfileURLs(capture, manifest)
+	}
+
+	if err := r.Status().Update(ctx, capture); err != nil {
+		logger.Error(err, "Failed to update ProfileCapture status", "capture", capture.Name)
+	}
+
+	if len(capture.Status.ProfileURLs) > 0 {
+		urls := make([]string, 0, len(capture.Status.ProfileURLs))
+		for _, profileURL := range capture.Status.ProfileURLs {
+			urls = append(urls, profileURL.Type+": "+profileURL.URL)
+		}
+		r.Recorder.Eventf(capture, corev1.EventTypeNormal, "ProfilesPresigned",
+			"Profiles downloadable until %s: %s", capture.Status.ProfileURLsExpireTime.UTC().Format(time.RFC3339), strings.Join(urls, " "))
+	}
+
+	if err := r.pruneCaptureHistory(ctx, config); err != nil {
+		logger.Error(err, "Failed to prune ProfileCapture history")
+	}
+}
+
+// setProfileURLs records the presigned URLs of the profiles of a manifest in
+// the status of a capture, clearing them if the profiles were not presigned
+func setProfileURLs(capture *profilingv1alpha1.ProfileCapture, manifest *uploader.Manifest) {
+	capture.Status.ProfileURLs = nil
+	capture.Status.ProfileURLsExpireTime = nil
+	if manifest.URLsExpire.IsZero() {
+		return
+	}
+
+	for _, object := range manifest.Objects {
+		capture.Status.ProfileURLs = append(capture.Status.ProfileURLs, profilingv1alpha1.ProfileURL{
+			Type: object.Type,
+			URL:  object.URL,
+		})
+	}
+	expires := metav1.NewTime(manifest.URLsExpire)
+	capture.Status.ProfileURLsExpireTime = &expires
+}
+
+// pruneCaptureHistory deletes the oldest finished ProfileCaptures of a config
+// beyond its history limit. Pending and running captures are neither counted
+// nor deleted, so a requested capture is not dropped before it runs.
+func (r *ProfilingConfigReconciler) pruneCaptureHistory(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) error {
+	limit := DefaultCaptureHistoryLimit
+	if config.Spec.CaptureHistoryLimit != nil {
+		limit = int(*config.Spec.CaptureHistoryLimit)
+	}
+
+	captures := &profilingv1alpha1.ProfileCaptureList{}
+	if err := r.List(ctx, captures,
+		client.InNamespace(config.Namespace),
+		client.MatchingLabels{profilingv1alpha1.ConfigNameLabel: config.Name},
+	); err != nil {
+		return err
+	}
+
+	finished := make([]*profilingv1alpha1.ProfileCapture, 0, len(captures.Items))
+	for i := range captures.Items {
+		switch captures.Items[i].Status.Phase {
+		case profilingv1alpha1.CapturePhaseSucceeded, profilingv1alpha1.CapturePhaseFailed:
+			finished = append(finished, &captures.Items[i])
+		}
+	}
+	if len(finished) <= limit {
+		return nil
+	}
+
+	// Oldest first
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreationTimestamp.Before(&finished[j].CreationTimestamp)
+	})
+
+	for _, capture := range finished[:len(finished)-limit] {
+		if err := r.Delete(ctx, capture); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreCooldowns rebuilds the cooldown state of a config's pods from the
+// cooldown start times in its status and from its ProfileCaptures, so an
+// operator restart does not reset every cooldown. The status covers every
+// profiled pod, while the captures are pruned to the history limit.
+func (r *ProfilingConfigReconciler) restoreCooldowns(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) error {
+	for _, profiled := range config.Status.ProfiledPods {
+		if profiled.CooldownStartTime != nil {
+			r.podWatcher.RestoreLastProfileTime(profiled.Pod, profiled.CooldownStartTime.Time)
+		}
+	}
+
+	captures := &profilingv1alpha1.ProfileCaptureList{}
+	if err := r.List(ctx, captures,
+		client.InNamespace(config.Namespace),
+		client.MatchingLabels{profilingv1alpha1.ConfigNameLabel: config.Name},
+	); err != nil {
+		return err
+	}
+
+	for i := range captures.Items {
+		capture := &captures.Items[i]
+
+		// Only successful threshold captures start a cooldown
+		if capture.Status.Phase != profilingv1alpha1.CapturePhaseSucceeded ||
+			capture.Spec.Reason == onDemandReason || capture.Spec.Reason == profilingv1alpha1.RequestedReason {
+			continue
+		}
+
+		completedAt := capture.CreationTimestamp.Time
+		if capture.Status.CompletionTime != nil {
+			completedAt = capture.Status.CompletionTime.Time
+		}
+
+		r.podWatcher.RestoreLastProfileTime(podKey(capture.Spec.Cluster, capture.Spec.PodNamespace, capture.Spec.PodName), completedAt)
+	}
+
+	return nil
+}
+
+// isPendingRequest reports whether a ProfileCapture was requested by a user and
+// has not been started yet
+func isPendingRequest(capture *profilingv1alpha1.ProfileCapture) bool {
+	return capture.Labels[profilingv1alpha1.RequestedLabel] == "true" &&
+		capture.Spec.ConfigName != "" &&
+		capture.Status.Phase == ""
+}
+
+// configForRequest maps a pending requested ProfileCapture to the config that
+// runs it
+func configForRequest(_ context.Context, obj client.Object) []reconcile.Request {
+	capture, ok := obj.(*profilingv1alpha1.ProfileCapture)
+	if !ok || !isPendingRequest(capture) {
+		return nil
+	}
+
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: capture.Namespace, Name: capture.Spec.ConfigName},
+	}}
+}
+
+// runRequestedCaptures starts the pending ProfileCaptures requested through a config
+func (r *ProfilingConfigReconciler) runRequestedCaptures(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+	captures := &profilingv1alpha1.ProfileCaptureList{}
+	if err := r.List(ctx, captures,
+		client.InNamespace(config.Namespace),
+		client.MatchingLabels{profilingv1alpha1.RequestedLabel: "true"},
+	); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list requested ProfileCaptures")
+		return
+	}
+
+	for i := range captures.Items {
+		capture := &captures.Items[i]
+		if isPendingRequest(capture) && capture.Spec.ConfigName == config.Name {
+			r.startRequestedCapture(ctx, config, capture)
+		}
+	}
+}
+
+// startRequestedCapture queues the capture of a requested ProfileCapture. The
+// pod must be tracked by the config. The capture is marked running before it is
+// queued, so a stale cache cannot start it twice.
+func (r *ProfilingConfigReconciler) startRequestedCapture(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, capture *profilingv1alpha1.ProfileCapture) {
+	logger := log.FromContext(ctx)
+
+	key := podKey(capture.Spec.Cluster, capture.Spec.PodNamespace, capture.Spec.PodName)
+	var pod *corev1.Pod
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		if r.podWatcher.getPodKey(tracked.Pod) == key {
+			pod = tracked.Pod
+		}
+	}
+
+	now := metav1.Now()
+	capture.Status.StartTime = &now
+	if pod == nil {
+		r.finishCapture(ctx, config, capture, nil,
+			fmt.Errorf("pod %s is not profiled by ProfilingConfig %s", key, config.Name))
+		return
+	}
+
+	capture.Status.Phase = profilingv1alpha1.CapturePhaseRunning
+	if err := r.Status().Update(ctx, capture); err != nil {
+		logger.V(1).Info("Requested capture not started", "capture", capture.Name, "error", err.Error())
+		return
+	}
+
+	profileTypes := capture.Spec.ProfileTypes
+	if len(profileTypes) == 0 {
+		profileTypes = podProfileTypes(pod, config)
+	}
+	trigger := metrics.Trigger{Reason: profilingv1alpha1.RequestedReason}
+
+	// The capture outlives the reconcile that started it
+	captureCtx := r.monitorContext(ctx)
+	job := CaptureJob{
+		ConfigKey: configKeyOf(config),
+		PodKey:    key,
+		Priority:  config.Spec.Priority,
+		Run: func() {
+			err := r.runCapture(captureCtx, pod, config, profileTypes, trigger, capture, now.Time)
+			if err != nil {
+				logger.Error(err, "Failed to capture and upload requested profiles", "pod", pod.Name, "capture", capture.Name)
+			}
+			r.updateCaptureStatus(captureCtx, config, err)
+		},
+	}
+	if !r.captureQueue.Enqueue(job, config.Spec.MaxConcurrentCaptures) {
+		r.finishCapture(ctx, config, capture, nil,
+			errors.New("the pod is already being captured or the config's capture limit is reached, retry later"))
+	}
+}
@@ -0,0 +1,379 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestStartAndFinishCapture(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.UID = "config-uid"
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	trigger := metrics.Trigger{
+		Reason: "CPU usage 92.50% exceeds threshold 80%",
+		Metrics: &metrics.PodMetrics{
+			CPUUsagePercent:    92.5,
+			MemoryUsagePercent: 40,
+			CPUUsage:           resource.MustParse("925m"),
+			MemoryUsage:        resource.MustParse("200Mi"),
+		},
+		CPUThresholdPercent:    80,
+		MemoryThresholdPercent: 90,
+	}
+
+	capture := reconciler.startCapture(ctx, config, pod, []string{"heap", "cpu"}, trigger)
+	if capture == nil {
+		t.Fatal("Expected ProfileCapture to be created")
+	}
+
+	if capture.Spec.PodName != "test-pod" || capture.Spec.ConfigName != "test-config" {
+		t.Errorf("Unexpected capture spec: %+v", capture.Spec)
+	}
+
+	if capture.Labels[profilingv1alpha1.ConfigNameLabel] != "test-config" {
+		t.Error("Expected capture to be labeled with its config")
+	}
+
+	if len(capture.OwnerReferences) != 1 || capture.OwnerReferences[0].Name != "test-config" {
+		t.Errorf("Expected capture to be owned by config, got %+v", capture.OwnerReferences)
+	}
+
+	manifest := &uploader.Manifest{
+		Bucket: "test-bucket",
+		Key:    "profiles/2024-01-15/test-app/20240115-120000-manifest.json",
+		Objects: []uploader.ManifestEntry{
+			{Type: "heap", Key: "profiles/2024-01-15/test-app/20240115-120000-heap.pprof"},
+			{Type: "cpu", Key: "profiles/2024-01-15/test-app/20240115-120000-cpu.pprof"},
+		},
+	}
+	reconciler.finishCapture(ctx, config, capture, manifest, nil)
+
+	stored := &profilingv1alpha1.ProfileCapture{}
+	if err := reconciler.Get(ctx, client.ObjectKeyFromObject(capture), stored); err != nil {
+		t.Fatalf("Failed to get capture: %v", err)
+	}
+
+	if stored.Status.Phase != profilingv1alpha1.CapturePhaseSucceeded {
+		t.Errorf("Expected phase Succeeded, got %s", stored.Status.Phase)
+	}
+
+	if len(stored.Status.ObjectKeys) != 2 || stored.Status.ManifestKey != manifest.Key {
+		t.Errorf("Expected object and manifest keys to be recorded, got %+v", stored.Status)
+	}
+
+	if stored.Status.Trigger == nil {
+		t.Fatal("Expected trigger metrics to be recorded")
+	}
+
+	if stored.Status.Trigger.CPUUsagePercent != "92.50" || stored.Status.Trigger.CPUThresholdPercent != 80 {
+		t.Errorf("Unexpected trigger metrics: %+v", stored.Status.Trigger)
+	}
+
+	if stored.Status.Trigger.CPUUsage.String() != "925m" {
+		t.Errorf("Expected raw CPU usage 925m, got %s", stored.Status.Trigger.CPUUsage.String())
+	}
+}
+
+func TestFinishCapture_PresignedURLs(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	capture := reconciler.startCapture(ctx, config, pod, []string{"heap"}, metrics.Trigger{Reason: "on-demand"})
+	if capture == nil {
+		t.Fatal("Expected ProfileCapture to be created")
+	}
+
+	expires := time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC)
+	manifest := &uploader.Manifest{
+		Bucket: "test-bucket",
+		Objects: []uploader.ManifestEntry{
+			{Type: "heap", Key: "profiles/heap.pprof", URL: "https://test-bucket.s3.amazonaws.com/profiles/heap.pprof?X-Amz-Signature=abc"},
+		},
+		URLsExpire: expires,
+	}
+	reconciler.finishCapture(ctx, config, capture, manifest, nil)
+
+	stored := &profilingv1alpha1.ProfileCapture{}
+	if err := reconciler.Get(ctx, client.ObjectKeyFromObject(capture), stored); err != nil {
+		t.Fatalf("Failed to get capture: %v", err)
+	}
+	if len(stored.Status.ProfileURLs) != 1 || stored.Status.ProfileURLs[0].URL != manifest.Objects[0].URL {
+		t.Errorf("Expected the presigned URL to be recorded, got %+v", stored.Status.ProfileURLs)
+	}
+	if stored.Status.ProfileURLsExpireTime == nil || !stored.Status.ProfileURLsExpireTime.Time.Equal(expires) {
+		t.Errorf("Expected the URLs expiry to be recorded, got %v", stored.Status.ProfileURLsExpireTime)
+	}
+
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	if len(recorder.Events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(recorder.Events))
+	}
+	event := <-recorder.Events
+	if !strings.Contains(event, "ProfilesPresigned") || !strings.Contains(event, "heap: "+manifest.Objects[0].URL) {
+		t.Errorf("Unexpected event %q", event)
+	}
+}
+
+func TestFinishCapture_Failed(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	capture := reconciler.startCapture(ctx, config, pod, []string{"heap"}, metrics.Trigger{Reason: "on-demand"})
+	if capture == nil {
+		t.Fatal("Expected ProfileCapture to be created")
+	}
+
+	if capture.Status.Trigger != nil {
+		t.Error("Expected no trigger metrics for on-demand capture")
+	}
+
+	reconciler.finishCapture(ctx, config, capture, nil, errors.New("port forward failed"))
+
+	stored := &profilingv1alpha1.ProfileCapture{}
+	if err := reconciler.Get(ctx, client.ObjectKeyFromObject(capture), stored); err != nil {
+		t.Fatalf("Failed to get capture: %v", err)
+	}
+
+	if stored.Status.Phase != profilingv1alpha1.CapturePhaseFailed {
+		t.Errorf("Expected phase Failed, got %s", stored.Status.Phase)
+	}
+
+	if stored.Status.Message != "port forward failed" {
+		t.Errorf("Expected failure message, got %q", stored.Status.Message)
+	}
+}
+
+func TestPruneCaptureHistory(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	limit := int32(2)
+	config.Spec.CaptureHistoryLimit = &limit
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	// A requested capture still pending and a running one, older than the
+	// finished captures kept
+	phases := []profilingv1alpha1.CapturePhase{
+		"",
+		profilingv1alpha1.CapturePhaseRunning,
+		profilingv1alpha1.CapturePhaseFailed,
+		profilingv1alpha1.CapturePhaseSucceeded,
+		profilingv1alpha1.CapturePhaseSucceeded,
+		profilingv1alpha1.CapturePhaseSucceeded,
+	}
+	for i, phase := range phases {
+		capture := &profilingv1alpha1.ProfileCapture{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("capture-%d", i),
+				Namespace:         "default",
+				Labels:            map[string]string{profilingv1alpha1.ConfigNameLabel: config.Name},
+				CreationTimestamp: metav1.Unix(int64(1000+i), 0),
+			},
+			Status: profilingv1alpha1.ProfileCaptureStatus{Phase: phase},
+		}
+		if err := reconciler.Create(ctx, capture); err != nil {
+			t.Fatalf("Failed to create capture: %v", err)
+		}
+	}
+
+	if err := reconciler.pruneCaptureHistory(ctx, config); err != nil {
+		t.Fatalf("pruneCaptureHistory returned error: %v", err)
+	}
+
+	captures := &profilingv1alpha1.ProfileCaptureList{}
+	if err := reconciler.List(ctx, captures, client.InNamespace("default")); err != nil {
+		t.Fatalf("Failed to list captures: %v", err)
+	}
+
+	var kept []string
+	for _, capture := range captures.Items {
+		kept = append(kept, capture.Name)
+	}
+	sort.Strings(kept)
+	expected := []string{"capture-0", "capture-1", "capture-4", "capture-5"}
+	if !reflect.DeepEqual(kept, expected) {
+		t.Errorf("Expected captures %v after pruning, got %v", expected, kept)
+	}
+}
+
+func TestRestoreCooldowns(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	recent := metav1.NewTime(time.Now().Add(-time.Minute))
+	captures := []*profilingv1alpha1.ProfileCapture{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "threshold", Namespace: "default",
+				Labels: map[string]string{profilingv1alpha1.ConfigNameLabel: config.Name}},
+			Spec:   profilingv1alpha1.ProfileCaptureSpec{PodName: "hot-pod", PodNamespace: "default", Reason: "CPU usage 95.00% exceeds threshold 80%"},
+			Status: profilingv1alpha1.ProfileCaptureStatus{Phase: profilingv1alpha1.CapturePhaseSucceeded, CompletionTime: &recent},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "on-demand", Namespace: "default",
+				Labels: map[string]string{profilingv1alpha1.ConfigNameLabel: config.Name}},
+			Spec:   profilingv1alpha1.ProfileCaptureSpec{PodName: "on-demand-pod", PodNamespace: "default", Reason: onDemandReason},
+			Status: profilingv1alpha1.ProfileCaptureStatus{Phase: profilingv1alpha1.CapturePhaseSucceeded, CompletionTime: &recent},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "failed", Namespace: "default",
+				Labels: map[string]string{profilingv1alpha1.ConfigNameLabel: config.Name}},
+			Spec:   profilingv1alpha1.ProfileCaptureSpec{PodName: "failed-pod", PodNamespace: "default", Reason: "CPU usage 95.00% exceeds threshold 80%"},
+			Status: profilingv1alpha1.ProfileCaptureStatus{Phase: profilingv1alpha1.CapturePhaseFailed, CompletionTime: &recent},
+		},
+	}
+	for _, capture := range captures {
+		if err := reconciler.Create(ctx, capture); err != nil {
+			t.Fatalf("Failed to create capture: %v", err)
+		}
+	}
+
+	if err := reconciler.restoreCooldowns(ctx, config); err != nil {
+		t.Fatalf("restoreCooldowns returned error: %v", err)
+	}
+
+	cooldown := config.Spec.Thresholds.CooldownSeconds
+	if reconciler.podWatcher.CanProfile(createTestPod("hot-pod", "default", true), cooldown) {
+		t.Error("Expected hot-pod to be in cooldown after restore")
+	}
+
+	if !reconciler.podWatcher.CanProfile(createTestPod("on-demand-pod", "default", true), cooldown) {
+		t.Error("Expected on-demand captures not to start a cooldown")
+	}
+
+	if !reconciler.podWatcher.CanProfile(createTestPod("failed-pod", "default", true), cooldown) {
+		t.Error("Expected failed captures not to start a cooldown")
+	}
+}
+
+func TestRestoreCooldowns_FromStatus(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	// The capture of this pod was pruned from the history, only the status
+	// remembers its cooldown
+	recent := metav1.NewTime(time.Now().Add(-time.Minute))
+	config.Status.ProfiledPods = []profilingv1alpha1.ProfiledPod{
+		{Pod: "default/pruned-pod", InCooldown: true, CooldownStartTime: &recent},
+		{Pod: "default/idle-pod"},
+	}
+
+	if err := reconciler.restoreCooldowns(ctx, config); err != nil {
+		t.Fatalf("restoreCooldowns returned error: %v", err)
+	}
+
+	cooldown := config.Spec.Thresholds.CooldownSeconds
+	if reconciler.podWatcher.CanProfile(createTestPod("pruned-pod", "default", true), cooldown) {
+		t.Error("Expected pruned-pod to be in cooldown after restore from status")
+	}
+
+	if !reconciler.podWatcher.CanProfile(createTestPod("idle-pod", "default", true), cooldown) {
+		t.Error("Expected idle-pod not to be in cooldown")
+	}
+}
+
+// requestedCapture builds a ProfileCapture requesting a capture of a pod
+func requestedCapture(name, configName, podName string) *profilingv1alpha1.ProfileCapture {
+	return &profilingv1alpha1.ProfileCapture{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				profilingv1alpha1.ConfigNameLabel: configName,
+				profilingv1alpha1.RequestedLabel:  "true",
+			},
+		},
+		Spec: profilingv1alpha1.ProfileCaptureSpec{
+			ConfigName:   configName,
+			PodName:      podName,
+			PodNamespace: "default",
+			Reason:       profilingv1alpha1.RequestedReason,
+		},
+	}
+}
+
+func TestNewCaptureRequest(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "profiling")
+
+	capture, err := NewCaptureRequest(config, "east/default/test-pod", []string{"heap"})
+	if err != nil {
+		t.Fatalf("NewCaptureRequest returned unexpected error: %v", err)
+	}
+	if !isPendingRequest(capture) || capture.Namespace != "profiling" {
+		t.Errorf("Expected a pending request in the config namespace, got %+v", capture)
+	}
+	if spec := capture.Spec; spec.Cluster != "east" || spec.PodNamespace != "default" || spec.PodName != "test-pod" ||
+		spec.Reason != profilingv1alpha1.RequestedReason {
+		t.Errorf("Unexpected spec %+v", spec)
+	}
+
+	if _, err := NewCaptureRequest(config, "test-pod", nil); err == nil {
+		t.Error("Expected error for a pod key without namespace")
+	}
+}
+
+func TestConfigForRequest(t *testing.T) {
+	capture := requestedCapture("request-1", "test-config", "test-pod")
+
+	requests := configForRequest(context.Background(), capture)
+	if len(requests) != 1 || requests[0].Name != "test-config" || requests[0].Namespace != "default" {
+		t.Errorf("Expected the request to map to its config, got %+v", requests)
+	}
+
+	capture.Status.Phase = profilingv1alpha1.CapturePhaseRunning
+	if requests := configForRequest(context.Background(), capture); len(requests) != 0 {
+		t.Errorf("Expected started captures to be ignored, got %+v", requests)
+	}
+}
+
+func TestRunRequestedCaptures(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	tracked := requestedCapture("request-1", "test-config", "test-pod")
+	untracked := requestedCapture("request-2", "test-config", "other-pod")
+	reconciler := setupTestReconciler(config, pod, tracked, untracked)
+	reconciler.podWatcher.TrackPod(pod, config)
+	ctx := context.Background()
+
+	reconciler.runRequestedCaptures(ctx, config)
+
+	stored := &profilingv1alpha1.ProfileCapture{}
+	if err := reconciler.Get(ctx, client.ObjectKeyFromObject(tracked), stored); err != nil {
+		t.Fatalf("Failed to get capture: %v", err)
+	}
+	if stored.Status.Phase != profilingv1alpha1.CapturePhaseRunning || stored.Status.StartTime == nil {
+		t.Errorf("Expected the capture of the tracked pod to be running, got %+v", stored.Status)
+	}
+	if pending := reconciler.captureQueue.Pending("default/test-config"); pending != 1 {
+		t.Errorf("Expected 1 queued capture, got %d", pending)
+	}
+
+	if err := reconciler.Get(ctx, client.ObjectKeyFromObject(untracked), stored); err != nil {
+		t.Fatalf("Failed to get capture: %v", err)
+	}
+	if stored.Status.Phase != profilingv1alpha1.CapturePhaseFailed || stored.Status.Message == "" {
+		t.Errorf("Expected the capture of an untracked pod to fail, got %+v", stored.Status)
+	}
+}
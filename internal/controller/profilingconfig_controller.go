@@ -2,27 +2,49 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
 	"github.com/a-kash-singh/bolometer/internal/metrics"
 	"github.com/a-kash-singh/bolometer/internal/profiler"
 	"github.com/a-kash-singh/bolometer/internal/uploader"
 )
 
+// onDemandReason is the capture reason of on-demand captures
+const onDemandReason = "on-demand"
+
 // ProfilingConfigReconciler reconciles a ProfilingConfig object
 type ProfilingConfigReconciler struct {
 	client.Client
@@ -30,13 +52,163 @@ type ProfilingConfigReconciler struct {
 	Clientset     kubernetes.Interface
 	MetricsClient metricsv.Interface
 	RestConfig    *rest.Config
+	Recorder      record.EventRecorder
 
 	podWatcher       *PodWatcher
 	metricsCollector *metrics.Collector
-	profiler         *profiler.Profiler
+	metricsHistory   *metrics.History
+	usageGauges      *metrics.UsageGauges
+	suppressions     *metrics.Suppressions
+	profiler         profiler.Interface
+
+	// Runs the exec hooks of captures in the pods of the operator's cluster
+	executor profiler.Executor
+
+	// Creates the uploader of the storage destination of each capture
+	uploaders uploader.Factory
+
+	// Create the trigger sources of each config besides the built-in ones
+	triggerFactories []TriggerSourceFactory
+
+	// Receive the events of captures besides the built-in notification sinks
+	addedSinks []NotificationSink
+
+	// Run in the capture pipeline after the built-in capture hooks
+	addedHooks []CaptureHook
+
+	// Supervises the monitoring goroutines of each config
+	monitors *MonitorManager
+
+	// Runs the checks of the periodic trigger sources of every config
+	scheduler *Scheduler
+
+	// Counts the panics of the monitors, checks and captures of each config
+	panics *metrics.Panics
+
+	// Runs captures off the monitoring goroutines
+	captureQueue *CaptureQueue
+
+	// Receives an audit record of every capture attempt
+	audit audit.Sink
+
+	// Applies the rate limits of the BolometerSettings
+	settings *settingsReconciler
+
+	// Clients of the remote clusters configs profile
+	clusters clusterRegistry
+
+	// Spaces the issues filed for each service
+	issues issueLimiter
+
+	// Follows the heap and goroutine profiles of each pod to detect leaks
+	leaks leakDetector
+
+	// Spaces the access checks of the bucket of each config
+	storageChecks storageChecks
+
+	// Tracks the lifecycle rule put for each config
+	lifecycleRules lifecycleRules
+
+	// Tracks the storage usage of each config against its budget
+	storageBudgets storageBudgets
+
+	// Remembers the last profiles stored for each pod to skip duplicates
+	dedup profileDeduplicator
+
+	// Caches the credentials of the web identity of each config
+	webIdentities webIdentities
 
-	// Track active monitoring goroutines
-	activeMonitors map[string]context.CancelFunc
+	// Holds back captures uploading to destinations that keep failing
+	circuits uploadCircuits
+
+	// Follows the monitors of deleted configs until they returned
+	stopping stoppingMonitors
+
+	// Holds the Leases of the configs this replica monitors, nil when every
+	// replica monitors every config
+	leases *configLeases
+
+	// Controller-lifetime parent context of the monitors, set up in SetupWithManager
+	baseCtx context.Context
+}
+
+// ReconcilerOptions holds the operator-level settings of the reconciler
+type ReconcilerOptions struct {
+	// History is the per-pod usage history, shared with the metrics endpoint.
+	// A new history is created if nil.
+	History *metrics.History
+
+	// UsageGauges export the usage evaluated for each tracked pod, registered
+	// with the metrics endpoint. New gauges are created if nil.
+	UsageGauges *metrics.UsageGauges
+
+	// Suppressions count the captures that did not happen, registered with
+	// the metrics endpoint. New counters are created if nil.
+	Suppressions *metrics.Suppressions
+
+	// CaptureWorkers is the number of captures run concurrently across all configs
+	CaptureWorkers int
+
+	// CaptureQueueSize is the number of captures that can wait for a worker
+	CaptureQueueSize int
+
+	// CheckWorkers is the number of checks of periodic trigger sources run
+	// concurrently across all configs
+	CheckWorkers int
+
+	// SchedulerMetrics export the checks run by the scheduler, registered with
+	// the metrics endpoint. New metrics are created if nil.
+	SchedulerMetrics *metrics.SchedulerMetrics
+
+	// Panics count the panics recovered in monitor tasks, scheduled checks and
+	// capture jobs, registered with the metrics endpoint. New counters are
+	// created if nil.
+	Panics *metrics.Panics
+
+	// MaxPortForwards limits the port-forwards open at once, 0 for no limit.
+	// BolometerSettings can override it.
+	MaxPortForwards int
+
+	// MaxCapturesPerMinute limits the captures started per minute, 0 for no limit.
+	// BolometerSettings can override it.
+	MaxCapturesPerMinute int
+
+	// Audit receives an audit record of every capture attempt. Records are
+	// dropped if nil.
+	Audit audit.Sink
+
+	// Profiler captures the profiles of pods. Defaults to a profiler.Profiler
+	// port-forwarding to their pprof endpoints. Configs profiling remote
+	// clusters need a profiler.ClusterProfiler, and MaxPortForwards only
+	// applies to a profiler.PortForwardLimiter.
+	Profiler profiler.Interface
+
+	// Uploaders creates the uploader storing the profiles of a capture from
+	// the s3Config of its ProfilingConfig. Defaults to uploader.NewUploader;
+	// presignExpirySeconds only applies to an uploader.Presigner.
+	Uploaders uploader.Factory
+
+	// TriggerSources create additional trigger sources of each config, run
+	// next to the built-in threshold and on-demand sources
+	TriggerSources []TriggerSourceFactory
+
+	// NotificationSinks receive the events of captures next to the built-in
+	// sinks of the notifications block, e.g. PagerDuty in a fork
+	NotificationSinks []NotificationSink
+
+	// CaptureHooks run at the stages of every capture, after the built-in
+	// hooks, e.g. to validate, redact or compress the profiles
+	CaptureHooks []CaptureHook
+
+	// LeaseIdentity enables per-config Leases: each config is monitored by the
+	// replica holding its Lease, identified by LeaseIdentity, and taken over by
+	// another replica when that one stops renewing it. Every replica monitors
+	// every config if empty, which requires leader election with more than one.
+	LeaseIdentity string
+
+	// LeaseDuration is how long a config Lease is held without renewal,
+	// DefaultConfigLeaseDuration if 0
+	LeaseDuration time.Duration
 }
 
 // NewProfilingConfigReconciler creates a new reconciler
@@ -46,27 +218,103 @@ func NewProfilingConfigReconciler(
 	clientset kubernetes.Interface,
 	metricsClient metricsv.Interface,
 	restConfig *rest.Config,
+	opts ReconcilerOptions,
 ) *ProfilingConfigReconciler {
+	metricsCollector := metrics.NewCollector(metricsClient)
+	metricsCollector.RegisterSource(metrics.SourceKubelet, metrics.NewKubeletSource(clientset))
+
+	history := opts.History
+	if history == nil {
+		history = metrics.NewHistory(metrics.DefaultHistorySize)
+	}
+
+	usageGauges := opts.UsageGauges
+	if usageGauges == nil {
+		usageGauges = metrics.NewUsageGauges()
+	}
+	suppressions := opts.Suppressions
+	if suppressions == nil {
+		suppressions = metrics.NewSuppressions()
+	}
+
+	podProfiler := opts.Profiler
+	if podProfiler == nil {
+		podProfiler = profiler.NewProfiler(clientset, restConfig)
+	}
+	if limiter, ok := podProfiler.(profiler.PortForwardLimiter); ok {
+		limiter.SetMaxPortForwards(opts.MaxPortForwards)
+	}
+
+	uploaders := opts.Uploaders
+	if uploaders == nil {
+		uploaders = uploader.NewUploader
+	}
+
+	panics := opts.Panics
+	if panics == nil {
+		panics = metrics.NewPanics()
+	}
+
+	captureQueue := NewCaptureQueue(opts.CaptureWorkers, opts.CaptureQueueSize)
+	captureQueue.SetRateLimit(opts.MaxCapturesPerMinute)
+	captureQueue.CountPanics(panics)
+
+	monitors := NewMonitorManager()
+	monitors.CountPanics(panics)
+	scheduler := NewScheduler(opts.CheckWorkers, opts.SchedulerMetrics)
+	scheduler.CountPanics(panics)
+
+	auditSink := opts.Audit
+	if auditSink == nil {
+		auditSink = audit.Discard
+	}
+
 	return &ProfilingConfigReconciler{
 		Client:           client,
 		Scheme:           scheme,
 		Clientset:        clientset,
 		MetricsClient:    metricsClient,
 		RestConfig:       restConfig,
-		podWatcher:       NewPodWatcher(clientset),
-		metricsCollector: metrics.NewCollector(metricsClient),
-		profiler:         profiler.NewProfiler(clientset, restConfig),
-		activeMonitors:   make(map[string]context.CancelFunc),
+		podWatcher:       NewPodWatcher(client),
+		metricsCollector: metricsCollector,
+		metricsHistory:   history,
+		usageGauges:      usageGauges,
+		suppressions:     suppressions,
+		profiler:         podProfiler,
+		executor:         profiler.NewExecutor(clientset, restConfig),
+		uploaders:        uploaders,
+		triggerFactories: opts.TriggerSources,
+		addedSinks:       opts.NotificationSinks,
+		addedHooks:       opts.CaptureHooks,
+		monitors:         monitors,
+		scheduler:        scheduler,
+		panics:           panics,
+		captureQueue:     captureQueue,
+		audit:            auditSink,
+		leases:           newConfigLeases(clientset, scheme, opts.LeaseIdentity, opts.LeaseDuration),
+		settings: &settingsReconciler{
+			Client:               client,
+			profiler:             podProfiler,
+			captureQueue:         captureQueue,
+			maxPortForwards:      opts.MaxPortForwards,
+			maxCapturesPerMinute: opts.MaxCapturesPerMinute,
+		},
 	}
 }
 
 // +kubebuilder:rbac:groups=bolometer.io,resources=profilingconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=bolometer.io,resources=profilingconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=bolometer.io,resources=profilingconfigs/finalizers,verbs=update
+// +kubebuilder:rbac:groups=bolometer.io,resources=profilecaptures,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=bolometer.io,resources=profilecaptures/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=pods/portforward,verbs=create;get
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=nodes/proxy,verbs=get
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
 
 // Reconcile handles ProfilingConfig changes
 func (r *ProfilingConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -76,197 +324,592 @@ func (r *ProfilingConfigReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	config := &profilingv1alpha1.ProfilingConfig{}
 	if err := r.Get(ctx, req.NamespacedName, config); err != nil {
 		if errors.IsNotFound(err) {
-			// Object deleted, stop monitoring
+			// Object deleted, stop monitoring and tracking its pods
 			r.stopMonitoring(req.NamespacedName.String())
+			r.forgetConfig(req.NamespacedName.String())
+			r.stopping.forget(req.NamespacedName.String())
+			if r.leases != nil {
+				r.leases.forget(req.NamespacedName.String())
+			}
+			if err := r.retainMetricsSources(ctx); err != nil {
+				logger.Error(err, "Failed to drop the errors of unused metrics sources")
+			}
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
 	}
 
+	// Only the replica holding the Lease of a config monitors and finalizes
+	// it; the others check back once the Lease would expire
+	if r.leases != nil && !isTemplate(config) {
+		owned, expiresIn, err := r.leases.acquire(ctx, config)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !owned {
+			logger.V(1).Info("Config is monitored by another replica")
+			r.disown(req.NamespacedName.String())
+			return ctrl.Result{RequeueAfter: expiresIn}, nil
+		}
+	}
+
+	// Tear down before the object goes away
+	if !config.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, config)
+	}
+
+	// Templates only hold the spec of the configs of annotated namespaces
+	if isTemplate(config) {
+		r.stopMonitoring(req.NamespacedName.String())
+		r.pruneTrackedPods(req.NamespacedName.String(), nil)
+		return ctrl.Result{}, nil
+	}
+
+	if controllerutil.AddFinalizer(config, ProfilingConfigFinalizer) {
+		if err := r.Update(ctx, config); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Fill unset fields from the operator-wide settings
+	settings, err := r.loadSettings(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	applySettings(config, settings)
+
 	// Validate configuration
 	if err := r.validateConfig(config); err != nil {
-		logger.Error(err, "Invalid configuration")
+		r.rejectInvalidSpec(ctx, config, err)
+
+		// Requeuing cannot fix the spec, the next spec update triggers a reconcile
+		return ctrl.Result{}, nil
+	}
+
+	// Probe the bucket before a capture depends on it
+	r.checkStorage(ctx, config)
+
+	// Probe a destination whose uploads kept failing, to resume captures
+	r.probeUploadCircuit(ctx, config)
+
+	// Keep the bucket's lifecycle rule for the prefix in line with the spec
+	r.applyLifecycle(ctx, config)
+
+	// Track the storage used against the budget before captures depend on it
+	r.trackStorageBudget(ctx, config)
+
+	// Connect to the cluster running the pods. Monitors already running keep
+	// the clients they were started with until the cluster is reachable again.
+	cluster, err := r.resolveCluster(ctx, config)
+	if err != nil {
+		logger.Error(err, "Failed to resolve target cluster")
+		setLastError(config, err)
+		if statusErr := r.updateStatus(ctx, config); statusErr != nil {
+			logger.Error(statusErr, "Failed to update status")
+		}
 		return ctrl.Result{}, err
 	}
 
 	// List matching pods
-	pods, err := r.podWatcher.ListMatchingPods(ctx, config)
+	pods, err := r.listClusterPods(ctx, cluster, config)
 	if err != nil {
 		logger.Error(err, "Failed to list pods")
+		setLastError(config, fmt.Errorf("failed to list pods: %w", err))
+		if statusErr := r.updateStatus(ctx, config); statusErr != nil {
+			logger.Error(statusErr, "Failed to update status")
+		}
 		return ctrl.Result{}, err
 	}
 
 	logger.Info("Found matching pods", "count", len(pods))
 
-	// Track all matching pods
+	// Track all matching pods and drop the ones that went away. Pods also
+	// selected by a higher ranked config are profiled by that config instead.
+	configKey := req.NamespacedName.String()
 	for _, pod := range pods {
-		r.podWatcher.TrackPod(pod, config)
+		if !r.podWatcher.TrackPod(pod, config) {
+			logger.V(1).Info("Pod is profiled by another config", "pod", pod.Name)
+			continue
+		}
+		r.probePprof(ctx, cluster, config, pod)
 	}
+	r.pruneTrackedPods(configKey, pods)
+
+	// Run the captures users requested through the capture-now annotation or
+	// ProfileCaptures
+	r.runAnnotatedCaptures(ctx, cluster, config)
+	r.runRequestedCaptures(ctx, config)
 
 	// Update status
-	config.Status.ActivePods = len(pods)
-	if err := r.Status().Update(ctx, config); err != nil {
+	config.Status.ActivePods = len(r.podWatcher.GetTrackedPodsForConfig(configKey))
+	config.Status.ProfiledPods = r.profiledPods(config)
+	setPprofCondition(config)
+	setConflictStatus(config, r.podWatcher.Conflicts(configKey))
+	setMonitoringConditions(config, len(pods))
+	config.Status.ObservedGeneration = config.Generation
+	if err := r.updateStatus(ctx, config); err != nil {
 		logger.Error(err, "Failed to update status")
 	}
 
-	// Start or update monitoring
-	configKey := req.NamespacedName.String()
-	r.stopMonitoring(configKey)
-	r.startMonitoring(ctx, config)
+	// Start monitoring, restarting it only when the spec changed
+	hash, err := specHash(&config.Spec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster.version != "" {
+		// Rotated credentials restart monitoring on the new clients
+		hash += "-" + cluster.version
+	}
+	if running, ok := r.monitors.Hash(configKey); !ok || running != hash {
+		if err := r.restoreCooldowns(ctx, config); err != nil {
+			logger.Error(err, "Failed to restore cooldowns from ProfileCaptures")
+		}
+		r.startMonitoring(ctx, config, hash)
+	}
+
+	return ctrl.Result{RequeueAfter: requeueInterval(settings)}, nil
+}
 
-	return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+// profiledPods returns the capture state of the pods owned by a config, with
+// their latest usage
+func (r *ProfilingConfigReconciler) profiledPods(config *profilingv1alpha1.ProfilingConfig) []profilingv1alpha1.ProfiledPod {
+	pods := r.podWatcher.ProfiledPods(configKeyOf(config), config.Spec.Thresholds.CooldownSeconds)
+	for i := range pods {
+		usage := r.metricsHistory.Latest(pods[i].Pod)
+		if usage == nil {
+			continue
+		}
+		pods[i].CPUUsagePercent = strconv.FormatFloat(usage.CPUUsagePercent, 'f', 2, 64)
+		pods[i].MemoryUsagePercent = strconv.FormatFloat(usage.MemoryUsagePercent, 'f', 2, 64)
+		usageTime := metav1.NewTime(usage.Timestamp)
+		pods[i].UsageTime = &usageTime
+	}
+	return pods
+}
+
+// pruneTrackedPods stops tracking the pods of a config that no longer match and
+// drops their metrics history
+func (r *ProfilingConfigReconciler) pruneTrackedPods(configKey string, current []*corev1.Pod) {
+	for _, key := range r.podWatcher.PruneTrackedPods(configKey, current) {
+		r.metricsHistory.Forget(key)
+		r.usageGauges.Forget(key)
+	}
 }
 
 // startMonitoring starts monitoring for a ProfilingConfig
-func (r *ProfilingConfigReconciler) startMonitoring(parentCtx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+func (r *ProfilingConfigReconciler) startMonitoring(reconcileCtx context.Context, config *profilingv1alpha1.ProfilingConfig, hash string) {
 	configKey := config.Namespace + "/" + config.Name
-	ctx, cancel := context.WithCancel(parentCtx)
-	r.activeMonitors[configKey] = cancel
 
-	// Start threshold-based monitoring
-	go r.monitorThresholds(ctx, config)
+	// Periodic sources are checked by the scheduler, the others run as monitor tasks
+	var tasks []MonitorTask
+	var checks []ScheduledTask
+	for _, source := range r.triggerSources(config) {
+		if scheduled, ok := source.(ScheduledSource); ok {
+			checks = append(checks, r.scheduledTask(config, scheduled))
+			continue
+		}
+		tasks = append(tasks, r.triggerTask(config, source))
+	}
 
-	// Start on-demand monitoring if enabled
-	if config.Spec.OnDemand != nil && config.Spec.OnDemand.Enabled {
-		go r.monitorOnDemand(ctx, config)
+	// Merging across replicas if enabled
+	if config.Spec.Aggregation != nil {
+		tasks = append(tasks, MonitorTask{
+			Name: "aggregation",
+			Run:  func(ctx context.Context) { r.monitorAggregation(ctx, config) },
+		})
 	}
+
+	ctx := r.monitorContext(reconcileCtx)
+	r.monitors.Start(ctx, configKey, hash, tasks...)
+	r.scheduler.Schedule(ctx, configKey, checks...)
 }
 
-// stopMonitoring stops monitoring for a ProfilingConfig
-func (r *ProfilingConfigReconciler) stopMonitoring(configKey string) {
-	if cancel, ok := r.activeMonitors[configKey]; ok {
-		cancel()
-		delete(r.activeMonitors, configKey)
+// monitorContext returns the parent context for monitors. Monitors outlive the
+// reconcile that started them, so they derive from the controller-lifetime base
+// context and only keep the reconcile's logger.
+func (r *ProfilingConfigReconciler) monitorContext(reconcileCtx context.Context) context.Context {
+	base := r.baseCtx
+	if base == nil {
+		base = context.Background()
 	}
+	return log.IntoContext(base, log.FromContext(reconcileCtx))
 }
 
-// monitorThresholds monitors pods for threshold violations
-func (r *ProfilingConfigReconciler) monitorThresholds(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
-	logger := log.FromContext(ctx)
-	checkInterval := time.Duration(config.Spec.Thresholds.CheckIntervalSeconds) * time.Second
-	ticker := time.NewTicker(checkInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			r.checkPodsThresholds(ctx, config, logger)
-		}
+// specHash returns a stable hash of a ProfilingConfig spec, used to detect
+// changes that require monitoring to restart
+func specHash(spec *profilingv1alpha1.ProfilingConfigSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash spec: %w", err)
 	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// stopMonitoring stops monitoring for a ProfilingConfig. The returned channel is
+// closed once its monitor tasks and running checks have returned.
+func (r *ProfilingConfigReconciler) stopMonitoring(configKey string) <-chan struct{} {
+	monitorsDone := r.monitors.Stop(configKey)
+	checksDone := r.scheduler.Unschedule(configKey)
+
+	done := make(chan struct{})
+	go func() {
+		<-monitorsDone
+		<-checksDone
+		close(done)
+	}()
+	return done
 }
 
-// checkPodsThresholds checks all tracked pods for threshold violations
-func (r *ProfilingConfigReconciler) checkPodsThresholds(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, logger logr.Logger) {
-	trackedPods := r.podWatcher.GetTrackedPods()
+// checkPodsThresholds checks all tracked pods for threshold violations, or the
+// compiled triggerExpression when set, emitting a capture request per violation,
+// and returns the highest threshold utilization observed
+func (r *ProfilingConfigReconciler) checkPodsThresholds(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, expression *triggerExpression, logger logr.Logger, emit func(CaptureRequest)) float64 {
+	trackedPods := r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config))
 
+	cluster, err := r.clusterOf(config)
+	if err != nil {
+		logger.Error(err, "Failed to check thresholds")
+		return 0
+	}
+
+	// Group pods by namespace so metrics can be listed in bulk
+	podsByNamespace := make(map[string][]*corev1.Pod)
 	for _, tracked := range trackedPods {
-		// Skip if in cooldown period
-		if !r.podWatcher.CanProfile(tracked.Pod, config.Spec.Thresholds.CooldownSeconds) {
-			continue
-		}
+		podsByNamespace[tracked.Pod.Namespace] = append(podsByNamespace[tracked.Pod.Namespace], tracked.Pod)
+	}
+
+	window := time.Duration(config.Spec.Thresholds.AveragingWindowSeconds) * time.Second
+	utilization := 0.0
+	var candidates []thresholdCandidate
 
-		// Get pod metrics
-		podMetrics, err := r.metricsCollector.GetPodMetrics(ctx, tracked.Pod.Namespace, tracked.Pod.Name, tracked.Pod)
+	for namespace, pods := range podsByNamespace {
+		// Get metrics for all pods in the namespace with a single call
+		podMetrics, err := cluster.metrics.ListPodMetrics(ctx, resolveMetricsSource(cluster.metrics, config), namespace, pods)
 		if err != nil {
-			logger.Error(err, "Failed to get pod metrics", "pod", tracked.Pod.Name)
+			logger.Error(err, "Failed to list pod metrics", "namespace", namespace)
 			continue
 		}
 
-		// Check thresholds
-		exceeded, reason := podMetrics.CheckThresholds(
-			config.Spec.Thresholds.CPUThresholdPercent,
-			config.Spec.Thresholds.MemoryThresholdPercent,
-		)
+		for _, pod := range pods {
+			usage, ok := podMetrics[pod.Name]
+			if !ok {
+				logger.V(1).Info("No metrics available for pod", "pod", pod.Name)
+				continue
+			}
 
-		if exceeded {
-			logger.Info("Threshold exceeded, capturing profile",
-				"pod", tracked.Pod.Name,
-				"reason", reason,
-			)
+			// Record every sample so averages stay accurate through cooldowns
+			podKey := r.podWatcher.getPodKey(pod)
+			r.metricsHistory.Record(podKey, usage)
 
-			if err := r.captureAndUpload(ctx, tracked.Pod, config, reason); err != nil {
-				logger.Error(err, "Failed to capture and upload profile", "pod", tracked.Pod.Name)
+			// Evaluate against the rolling average when a window is configured
+			if window > 0 {
+				usage = r.metricsHistory.Average(podKey, window)
+			}
+
+			// Pods may override the config's thresholds and cooldown
+			thresholds, err := podThresholds(pod, config.Spec.Thresholds)
+			if err != nil {
+				logger.V(1).Info("Ignoring invalid threshold annotations", "pod", pod.Name, "error", err.Error())
+			}
+			r.usageGauges.Set(podKey, metrics.UsageLabels{
+				Config:    configKeyOf(config),
+				Cluster:   podCluster(pod),
+				Namespace: pod.Namespace,
+				Pod:       pod.Name,
+			}, usage, metrics.Thresholds{
+				CPUPercent:    thresholds.CPUThresholdPercent,
+				MemoryPercent: thresholds.MemoryThresholdPercent,
+			})
+			podUtilization := thresholdUtilization(usage, thresholds)
+			utilization = max(utilization, podUtilization)
+
+			// Check thresholds
+			var exceeded bool
+			var reason string
+			goroutines := podGoroutines(ctx, cluster, pod, logger)
+			if expression != nil {
+				if exceeded, reason, err = expression.evaluate(pod, usage, goroutines); err != nil {
+					logger.V(1).Info("Trigger expression not evaluated", "pod", pod.Name, "error", err.Error())
+				}
 			} else {
-				r.podWatcher.UpdateLastProfileTime(tracked.Pod)
-				r.updateProfileStats(ctx, config)
+				exceeded, reason = evaluateThresholds(usage, thresholds, goroutines)
 			}
+
+			if exceeded {
+				// Skip if in cooldown period
+				if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+					r.suppress(config, metrics.SuppressedCooldown)
+					continue
+				}
+
+				// Skip if recent captures failed
+				if r.podWatcher.InBackoff(pod) {
+					logger.V(1).Info("Pod backed off after failed captures", "pod", pod.Name)
+					r.suppress(config, r.backoffReason(pod))
+					continue
+				}
+
+				if r.nodeUnderPressure(ctx, config, pod, logger) {
+					// Skip starts the cooldown, Defer retries on the next check
+					if config.Spec.NodePressurePolicy == profilingv1alpha1.NodePressureSkip {
+						r.podWatcher.UpdateLastProfileTime(pod)
+					}
+					r.suppress(config, metrics.SuppressedNodePressure)
+					continue
+				}
+
+				// Violations that are not sampled start the cooldown as if captured
+				if !sampled(config.Spec.SamplingPercent) {
+					logger.V(1).Info("Threshold exceeded, capture not sampled", "pod", pod.Name, "samplingPercent", config.Spec.SamplingPercent)
+					r.podWatcher.UpdateLastProfileTime(pod)
+					r.suppress(config, metrics.SuppressedSampling)
+					continue
+				}
+
+				candidates = append(candidates, thresholdCandidate{
+					pod: pod,
+					trigger: metrics.Trigger{
+						Reason:                 reason,
+						Metrics:                usage,
+						CPUThresholdPercent:    thresholds.CPUThresholdPercent,
+						MemoryThresholdPercent: thresholds.MemoryThresholdPercent,
+						History:                r.metricsHistory.Samples(podKey),
+					},
+					utilization: podUtilization,
+				})
+			}
+		}
+	}
+
+	// Capture the pods furthest over their thresholds, the rest retry next check
+	budget := captureBudget(config)
+	selected := limitCandidates(candidates, budget)
+	if skipped := len(candidates) - len(selected); skipped > 0 {
+		logger.Info("Capture limit reached, deferring captures", "limit", budget, "deferred", skipped)
+		for range skipped {
+			r.suppress(config, metrics.SuppressedRateLimit)
+		}
+	}
+
+	for _, candidate := range selected {
+		logger.Info("Threshold exceeded, capturing profile",
+			"pod", candidate.pod.Name,
+			"reason", candidate.trigger.Reason,
+		)
+		emit(CaptureRequest{Pod: candidate.pod, Trigger: candidate.trigger, StartCooldown: true})
+	}
+
+	return utilization
+}
+
+// resolveMetricsSource returns the collector source name for a config, registering a
+// Prometheus source for the config's endpoint and a source reporting its memory
+// metric on first use
+func resolveMetricsSource(collector *metrics.Collector, config *profilingv1alpha1.ProfilingConfig) string {
+	if config.Spec.MetricsSource != metrics.SourcePrometheus || config.Spec.Prometheus == nil {
+		return collector.MemoryMetricSourceName(config.Spec.MetricsSource, config.Spec.MemoryMetric)
+	}
+
+	prometheusURL := config.Spec.Prometheus.URL
+	rateWindow := time.Duration(config.Spec.Prometheus.RateWindowSeconds) * time.Second
+	name := metrics.PrometheusSourceName(prometheusURL, rateWindow)
+	if !collector.HasSource(name) {
+		collector.RegisterSource(name, metrics.NewPrometheusSource(prometheusURL, rateWindow))
+	}
+
+	return collector.MemoryMetricSourceName(name, config.Spec.MemoryMetric)
+}
+
+// retainMetricsSources drops the errors of the metrics sources no remaining
+// config uses, so a deleted config does not keep the operator NotReady
+func (r *ProfilingConfigReconciler) retainMetricsSources(ctx context.Context) error {
+	configs := &profilingv1alpha1.ProfilingConfigList{}
+	if err := r.List(ctx, configs); err != nil {
+		return err
+	}
+
+	inUse := make(map[string]bool)
+	for i := range configs.Items {
+		config := &configs.Items[i]
+		if !config.DeletionTimestamp.IsZero() || isTemplate(config) {
+			continue
+		}
+		name := resolveMetricsSource(r.metricsCollector, config)
+		if name == "" {
+			name = metrics.SourceMetricsServer
 		}
+		inUse[name] = true
 	}
+
+	r.metricsCollector.RetainSources(inUse)
+	return nil
 }
 
-// monitorOnDemand performs on-demand continuous profiling
-func (r *ProfilingConfigReconciler) monitorOnDemand(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+// evaluateThresholds checks pod metrics against the configured thresholds, either
+// pod-wide or per container, or against the configured conditions. goroutines
+// may be nil where goroutines cannot be counted.
+func evaluateThresholds(usage *metrics.PodMetrics, thresholds profilingv1alpha1.ThresholdConfig, goroutines goroutineCount) (bool, string) {
+	if len(thresholds.Conditions) > 0 {
+		return evaluateConditions(thresholds.Conditions, usage, goroutines)
+	}
+
+	if thresholds.PerContainer || len(thresholds.Containers) > 0 {
+		return usage.CheckContainerThresholds(
+			thresholds.CPUThresholdPercent,
+			thresholds.MemoryThresholdPercent,
+			thresholds.Containers,
+		)
+	}
+
+	return usage.CheckThresholds(
+		thresholds.CPUThresholdPercent,
+		thresholds.MemoryThresholdPercent,
+	)
+}
+
+// checkOnDemand performs a round of on-demand continuous profiling, requesting
+// a capture of the tracked pods through emit. cursor rotates through the
+// tracked pods when MaxPodsPerCapture is set, serviceCursors through the
+// replicas of each service when ReplicasPerService is; the cursor of the next
+// round is returned.
+func (r *ProfilingConfigReconciler) checkOnDemand(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, cursor int, serviceCursors map[string]int, emit func(CaptureRequest)) int {
 	logger := log.FromContext(ctx)
-	interval := time.Duration(config.Spec.OnDemand.IntervalSeconds) * time.Second
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			trackedPods := r.podWatcher.GetTrackedPods()
-			for _, tracked := range trackedPods {
-				logger.Info("On-demand profiling", "pod", tracked.Pod.Name)
-
-				if err := r.captureAndUpload(ctx, tracked.Pod, config, "on-demand"); err != nil {
-					logger.Error(err, "Failed to capture on-demand profile", "pod", tracked.Pod.Name)
-				} else {
-					r.updateProfileStats(ctx, config)
-				}
-			}
+
+	var trackedPods []*TrackedPod
+	trackedPods = selectServiceReplicas(r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)), serviceCursors, config.Spec.OnDemand.ReplicasPerService)
+	trackedPods, cursor = selectOnDemandPods(trackedPods, cursor, config.Spec.MaxPodsPerCapture)
+	for _, tracked := range trackedPods {
+		if r.nodeUnderPressure(ctx, config, tracked.Pod, logger) {
+			r.suppress(config, metrics.SuppressedNodePressure)
+			continue
 		}
+
+		logger.Info("On-demand profiling", "pod", tracked.Pod.Name)
+
+		emit(CaptureRequest{Pod: tracked.Pod, Trigger: metrics.Trigger{Reason: onDemandReason}})
 	}
+	return cursor
 }
 
-// captureAndUpload captures profiles and uploads them to S3
-func (r *ProfilingConfigReconciler) captureAndUpload(ctx context.Context, pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig, reason string) error {
-	// Determine which profile types to capture
-	profileTypes := config.Spec.ProfileTypes
-	if len(profileTypes) == 0 {
-		profileTypes = []string{"heap", "cpu", "goroutine", "mutex"}
+// enqueueCapture queues a capture of a pod on the capture workers. Threshold captures
+// start the pod's cooldown once they succeed. Captures that cannot be queued are
+// dropped and picked up again by a later check.
+func (r *ProfilingConfigReconciler) enqueueCapture(ctx context.Context, pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig, trigger metrics.Trigger, startCooldown bool) {
+	logger := log.FromContext(ctx)
+
+	var job CaptureJob
+	job = CaptureJob{
+		ConfigKey: client.ObjectKeyFromObject(config).String(),
+		PodKey:    r.podWatcher.getPodKey(pod),
+		Priority:  config.Spec.Priority,
+		Sheddable: trigger.Reason == onDemandReason,
+		Run: func() {
+			profileTypes, ok := r.budgetedProfileTypes(ctx, config, pod, trigger, podProfileTypes(pod, config))
+			if !ok {
+				// Captures skipped over the storage budget start the cooldown
+				logger.V(1).Info("Storage budget exceeded, capture skipped", "pod", pod.Name)
+				r.suppress(config, metrics.SuppressedBudget)
+				if startCooldown {
+					r.podWatcher.UpdateLastProfileTime(pod)
+				}
+				return
+			}
+			if !r.admitCapture(config, job) {
+				// Paused captures start the cooldown, queued ones once they run
+				logger.V(1).Info("Upload circuit open, capture held back", "pod", pod.Name)
+				r.suppress(config, metrics.SuppressedCircuitOpen)
+				if startCooldown && config.Spec.S3Config.CircuitBreaker.Action != profilingv1alpha1.CircuitBreakerQueue {
+					r.podWatcher.UpdateLastProfileTime(pod)
+				}
+				return
+			}
+
+			err := r.captureAndUpload(ctx, pod, config, profileTypes, trigger)
+			if r.podWatcher.RecordCapture(pod, trigger.Reason, err) {
+				logger.Info("Quarantining pod after repeated capture failures", "pod", pod.Name)
+				r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonPodQuarantined,
+					"Pod %s quarantined after %d failed captures, retrying every %s: %v",
+					r.podWatcher.getPodKey(pod), quarantineFailures, quarantineRetryInterval, err)
+			}
+			if err != nil {
+				logger.Error(err, "Failed to capture and upload profile", "pod", pod.Name, "reason", trigger.Reason)
+			} else if startCooldown {
+				r.podWatcher.UpdateLastProfileTime(pod)
+			}
+			r.updateCaptureStatus(ctx, config, err)
+		},
 	}
 
-	// Capture profiles
-	profiles, err := r.profiler.CaptureProfiles(ctx, pod, profileTypes)
-	if err != nil {
-		return fmt.Errorf("failed to capture profiles: %w", err)
+	if !r.captureQueue.Enqueue(job, config.Spec.MaxConcurrentCaptures) {
+		logger.V(1).Info("Capture not queued, pod already queued or limit reached", "pod", pod.Name)
+		r.suppress(config, metrics.SuppressedConcurrency)
 	}
+}
 
-	// Create S3 uploader
-	s3Uploader, err := uploader.NewS3Uploader(ctx, uploader.S3Config{
-		Bucket:   config.Spec.S3Config.Bucket,
-		Prefix:   config.Spec.S3Config.Prefix,
-		Region:   config.Spec.S3Config.Region,
-		Endpoint: config.Spec.S3Config.Endpoint,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create S3 uploader: %w", err)
+// captureAndUpload captures profiles and uploads them to S3, recording the
+// capture as a ProfileCapture
+func (r *ProfilingConfigReconciler) captureAndUpload(ctx context.Context, pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig, profileTypes []string, trigger metrics.Trigger) error {
+	startedAt := time.Now()
+	capture := r.startCapture(ctx, config, pod, profileTypes, trigger)
+
+	return r.runCapture(ctx, pod, config, profileTypes, trigger, capture, startedAt)
+}
+
+// profileTypesOf returns the profile types a config captures
+func profileTypesOf(config *profilingv1alpha1.ProfilingConfig) []string {
+	if len(config.Spec.ProfileTypes) == 0 {
+		return []string{"heap", "cpu", "goroutine", "mutex"}
 	}
+	return config.Spec.ProfileTypes
+}
 
-	// Upload profiles
-	if err := s3Uploader.UploadProfiles(ctx, pod, profiles, reason); err != nil {
-		return fmt.Errorf("failed to upload profiles: %w", err)
+// runCapture captures profiles for a started ProfileCapture, uploads them and
+// reports the outcome
+func (r *ProfilingConfigReconciler) runCapture(ctx context.Context, pod *corev1.Pod, config *profilingv1alpha1.ProfilingConfig, profileTypes []string, trigger metrics.Trigger, capture *profilingv1alpha1.ProfileCapture, startedAt time.Time) error {
+	manifest, leaks, err := r.captureAndUploadProfiles(ctx, pod, config, profileTypes, trigger)
+	r.recordUpload(ctx, config, err)
+	if manifest != nil {
+		r.storageBudgets.recordUpload(configKeyOf(config), uploader.ManifestBytes(manifest), time.Now())
 	}
+	r.finishCapture(ctx, config, capture, manifest, err)
+	r.reportRegression(config, pod, manifest)
+	r.reportLeaks(config, pod, leaks)
+	record := newAuditRecord(config, pod, profileTypes, trigger, capture, manifest, err, startedAt)
+	record.Leaks = leaks
+	r.auditCapture(ctx, record)
+	r.notifyCapture(ctx, config, record)
 
-	return nil
+	return err
 }
 
-// updateProfileStats updates the profile statistics in the status
-func (r *ProfilingConfigReconciler) updateProfileStats(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+// updateCaptureStatus records the outcome of a capture in the status: profile
+// statistics on success and the upload and degraded conditions either way
+func (r *ProfilingConfigReconciler) updateCaptureStatus(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, captureErr error) {
 	// Fetch latest version
 	latest := &profilingv1alpha1.ProfilingConfig{}
 	if err := r.Get(ctx, client.ObjectKeyFromObject(config), latest); err != nil {
 		return
 	}
 
-	now := metav1.Now()
-	latest.Status.LastProfileTime = &now
-	latest.Status.TotalProfiles++
-	latest.Status.TotalUploads++
+	if captureErr == nil {
+		now := metav1.Now()
+		latest.Status.LastProfileTime = &now
+		latest.Status.TotalProfiles++
+		latest.Status.TotalUploads++
+	}
+	latest.Status.ProfiledPods = r.profiledPods(config)
+	setPprofCondition(latest)
+	r.setStorageBudgetStatus(latest)
+	setCaptureConditions(latest, captureErr)
+	r.setCircuitConditions(latest)
+	setLeakCondition(latest,
+		r.leaks.leakingPods(configKeyOf(latest), "heap"),
+		r.leaks.leakingPods(configKeyOf(latest), "goroutine"))
+	if captureErr != nil {
+		setLastError(latest, captureErr)
+	}
 
 	if err := r.Status().Update(ctx, latest); err != nil {
 		// Log but don't fail
@@ -274,6 +917,28 @@ func (r *ProfilingConfigReconciler) updateProfileStats(ctx context.Context, conf
 	}
 }
 
+// updateStatus writes the status of a config. A copy is sent so the in-memory
+// spec keeps the settings applied to it rather than the stored spec.
+func (r *ProfilingConfigReconciler) updateStatus(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) error {
+	stored := config.DeepCopy()
+	if err := r.Status().Update(ctx, stored); err != nil {
+		return err
+	}
+	config.ResourceVersion = stored.ResourceVersion
+	return nil
+}
+
+// ValidateConfig fills the fields a config leaves unset from the settings,
+// which may be nil, and from the built-in defaults, then validates it the way
+// the reconciler does
+func ValidateConfig(config *profilingv1alpha1.ProfilingConfig, settings *profilingv1alpha1.BolometerSettingsSpec) error {
+	if settings == nil {
+		settings = &profilingv1alpha1.BolometerSettingsSpec{}
+	}
+	applySettings(config, settings)
+	return (&ProfilingConfigReconciler{}).validateConfig(config)
+}
+
 // validateConfig validates the ProfilingConfig
 func (r *ProfilingConfigReconciler) validateConfig(config *profilingv1alpha1.ProfilingConfig) error {
 	if config.Spec.S3Config.Bucket == "" {
@@ -282,12 +947,307 @@ func (r *ProfilingConfigReconciler) validateConfig(config *profilingv1alpha1.Pro
 	if config.Spec.S3Config.Region == "" {
 		return fmt.Errorf("s3 region is required")
 	}
+	if proxyURL := config.Spec.S3Config.ProxyURL; proxyURL != "" {
+		if proxy, err := url.Parse(proxyURL); err != nil || (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Host == "" {
+			return fmt.Errorf("s3 proxyURL %q must be an http or https URL", proxyURL)
+		}
+	}
+	if ref := config.Spec.S3Config.CABundleRef; ref != nil && ref.Name == "" {
+		return fmt.Errorf("s3 caBundleRef name is required")
+	}
+	if s3Config := config.Spec.S3Config; s3Config.Accelerate &&
+		(s3Config.Endpoint != "" || (s3Config.ForcePathStyle != nil && *s3Config.ForcePathStyle)) {
+		return fmt.Errorf("s3 accelerate is not supported with a custom endpoint or forcePathStyle")
+	}
+	if err := validateLifecycle(config); err != nil {
+		return err
+	}
+	if err := validateStorageBudget(config); err != nil {
+		return err
+	}
+	if err := validateWebIdentity(config); err != nil {
+		return err
+	}
+	if err := validateExecHooks(config); err != nil {
+		return err
+	}
+	for key, value := range config.Spec.Selector.LabelSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("selector label key %q is invalid: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("selector label value %q is invalid: %s", value, strings.Join(errs, "; "))
+		}
+	}
+	if service := config.Spec.Selector.Service; service != "" {
+		if errs := validation.IsDNS1035Label(service); len(errs) > 0 {
+			return fmt.Errorf("selector service %q is invalid: %s", service, strings.Join(errs, "; "))
+		}
+	}
+	if spec := config.Spec; spec.MaxCapturesPerCheck > 0 && spec.MaxCapturesPerInterval > 0 &&
+		spec.MaxCapturesPerCheck != spec.MaxCapturesPerInterval {
+		return fmt.Errorf("maxCapturesPerCheck and the deprecated maxCapturesPerInterval must be equal when both are set")
+	}
+	if config.Spec.MetricsSource == metrics.SourcePrometheus &&
+		(config.Spec.Prometheus == nil || config.Spec.Prometheus.URL == "") {
+		return fmt.Errorf("prometheus url is required when metricsSource is prometheus")
+	}
+	if (config.Spec.MetricsSource == "" || config.Spec.MetricsSource == metrics.SourceMetricsServer) &&
+		config.Spec.MemoryMetric != "" && config.Spec.MemoryMetric != metrics.MemoryWorkingSet {
+		return fmt.Errorf("memoryMetric %s is not supported by metrics-server", config.Spec.MemoryMetric)
+	}
+	if expression := config.Spec.TriggerExpression; expression != "" {
+		if _, err := compileTriggerExpression(expression); err != nil {
+			return fmt.Errorf("triggerExpression is invalid: %w", err)
+		}
+		if len(config.Spec.Thresholds.Conditions) > 0 {
+			return fmt.Errorf("triggerExpression and thresholds conditions cannot be combined")
+		}
+	}
+	if thresholds := config.Spec.Thresholds; len(thresholds.Conditions) > 0 && (thresholds.PerContainer || len(thresholds.Containers) > 0) {
+		return fmt.Errorf("thresholds conditions are evaluated pod-wide and cannot be combined with perContainer or containers")
+	}
+	if networkIO := config.Spec.NetworkIO; networkIO != nil && networkIO.ReceiveBytesPerSecond == nil && networkIO.TransmitBytesPerSecond == nil {
+		return fmt.Errorf("networkIO receiveBytesPerSecond or transmitBytesPerSecond is required")
+	}
+	for _, schedule := range config.Spec.Schedules {
+		if _, err := parseCron(schedule.Cron); err != nil {
+			return fmt.Errorf("schedules cron is invalid: %w", err)
+		}
+	}
+	if notifications := config.Spec.Notifications; notifications != nil {
+		if slack := notifications.Slack; slack != nil && slack.WebhookSecretRef.Name == "" {
+			return fmt.Errorf("slack webhookSecretRef name is required")
+		}
+		for _, webhook := range notifications.Webhooks {
+			if !strings.HasPrefix(webhook.URL, "https://") {
+				return fmt.Errorf("webhook url %q must use https", webhook.URL)
+			}
+		}
+		if topic := notifications.SNS; topic != nil && !strings.HasPrefix(topic.TopicARN, "arn:") {
+			return fmt.Errorf("sns topicARN %q is not an ARN", topic.TopicARN)
+		}
+		if queue := notifications.SQS; queue != nil && !strings.HasPrefix(queue.QueueURL, "https://") {
+			return fmt.Errorf("sqs queueURL %q must use https", queue.QueueURL)
+		}
+		if kafka := notifications.Kafka; kafka != nil {
+			if len(kafka.Brokers) == 0 || kafka.Topic == "" {
+				return fmt.Errorf("kafka brokers and topic are required")
+			}
+			if sasl := kafka.SASL; sasl != nil && (sasl.UsernameSecretRef.Name == "" || sasl.PasswordSecretRef.Name == "") {
+				return fmt.Errorf("kafka sasl usernameSecretRef and passwordSecretRef names are required")
+			}
+		}
+		if grafana := notifications.Grafana; grafana != nil && grafana.TokenSecretRef.Name == "" {
+			return fmt.Errorf("grafana tokenSecretRef name is required")
+		}
+		if splunk := notifications.Splunk; splunk != nil {
+			if !strings.HasPrefix(splunk.URL, "https://") {
+				return fmt.Errorf("splunk url %q must use https", splunk.URL)
+			}
+			if splunk.TokenSecretRef.Name == "" {
+				return fmt.Errorf("splunk tokenSecretRef name is required")
+			}
+		}
+		if email := notifications.Email; email != nil && (email.SMTPSecretName == "" || email.From == "" || len(email.To) == 0) {
+			return fmt.Errorf("email smtpSecretName, from and to are required")
+		}
+		if issues := notifications.Issues; issues != nil {
+			if issues.Project == "" || issues.TokenSecretRef.Name == "" {
+				return fmt.Errorf("issues project and tokenSecretRef name are required")
+			}
+			if issues.Tracker == profilingv1alpha1.IssueTrackerJira && issues.URL == "" {
+				return fmt.Errorf("issues url is required for Jira")
+			}
+		}
+	}
+	if cluster := config.Spec.Cluster; cluster != nil {
+		if cluster.Name == "" || strings.Contains(cluster.Name, "/") {
+			return fmt.Errorf("cluster name must be non-empty and must not contain '/'")
+		}
+		if cluster.KubeconfigSecretRef.Name == "" {
+			return fmt.Errorf("cluster kubeconfigSecretRef name is required")
+		}
+	}
+	if baseline := config.Spec.Baseline; baseline != nil {
+		if len(baseline.Profiles) == 0 {
+			return fmt.Errorf("baseline profiles are required")
+		}
+		types := make(map[string]bool, len(baseline.Profiles))
+		for _, profile := range baseline.Profiles {
+			if profile.Type == "" || profile.Key == "" {
+				return fmt.Errorf("baseline profile type and key are required")
+			}
+			if types[profile.Type] {
+				return fmt.Errorf("baseline has several %s profiles", profile.Type)
+			}
+			types[profile.Type] = true
+		}
+	}
 	return nil
 }
 
+// rejectInvalidSpec stops monitoring a config whose spec failed validation and
+// reports the failure through its status and a warning event
+func (r *ProfilingConfigReconciler) rejectInvalidSpec(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, validationErr error) {
+	logger := log.FromContext(ctx)
+	logger.Error(validationErr, "Invalid configuration")
+
+	configKey := configKeyOf(config)
+	r.stopMonitoring(configKey)
+	r.pruneTrackedPods(configKey, nil)
+	r.clusters.forget(configKey)
+
+	// Only report the failure once per spec, so the status update does not
+	// trigger another reconcile
+	previous := apimeta.FindStatusCondition(config.Status.Conditions, ConditionInvalidSpec)
+	if previous == nil || previous.Status != metav1.ConditionTrue ||
+		previous.Message != validationErr.Error() || previous.ObservedGeneration != config.Generation {
+		r.Recorder.Event(config, corev1.EventTypeWarning, reasonInvalidSpec, validationErr.Error())
+		setLastError(config, validationErr)
+	}
+
+	config.Status.ObservedGeneration = config.Generation
+	config.Status.ActivePods = 0
+	config.Status.ProfiledPods = nil
+	config.Status.Conflicts = nil
+	setCondition(config, ConditionInvalidSpec, metav1.ConditionTrue, reasonValidationFailed, validationErr.Error())
+	setCondition(config, ConditionReady, metav1.ConditionFalse, reasonInvalidSpec, validationErr.Error())
+
+	if err := r.updateStatus(ctx, config); err != nil {
+		logger.Error(err, "Failed to update status")
+	}
+}
+
+// configsForPod maps a pod event to the ProfilingConfigs whose selector covers the
+// pod, so new pods are tracked and deleted pods dropped without waiting for a requeue
+func (r *ProfilingConfigReconciler) configsForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil
+	}
+
+	configs := &profilingv1alpha1.ProfilingConfigList{}
+	if err := r.List(ctx, configs); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list ProfilingConfigs for pod", "pod", pod.Name)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range configs.Items {
+		config := &configs.Items[i]
+
+		// Pods of remote clusters are not watched, only listed on reconcile
+		if config.Spec.Cluster != nil {
+			continue
+		}
+		if r.podWatcher.MatchesSelector(config, pod) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(config),
+			})
+		}
+	}
+
+	return requests
+}
+
+// podTrackingChanged filters pod updates down to the changes that affect whether
+// a pod is tracked: labels, annotations, phase, readiness and the start of its
+// deletion
+func podTrackingChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, ok := e.ObjectOld.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+			newPod, ok := e.ObjectNew.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+
+			return oldPod.Status.Phase != newPod.Status.Phase ||
+				isPodReady(oldPod) != isPodReady(newPod) ||
+				(oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil) ||
+				!equality.Semantic.DeepEqual(oldPod.Labels, newPod.Labels) ||
+				!equality.Semantic.DeepEqual(oldPod.Annotations, newPod.Annotations)
+		},
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager
 func (r *ProfilingConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Monitors run until the manager shuts down rather than per reconcile
+	baseCtx, cancel := context.WithCancel(context.Background())
+	r.baseCtx = baseCtx
+	if err := mgr.Add(r.sharded(manager.RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		cancel()
+		r.monitors.StopAll()
+		r.scheduler.UnscheduleAll()
+		return nil
+	}))); err != nil {
+		cancel()
+		return err
+	}
+
+	if err := mgr.Add(r.sharded(r.captureQueue)); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(r.sharded(r.scheduler)); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(r.sharded(manager.RunnableFunc(r.sweepTrackingState))); err != nil {
+		return err
+	}
+
+	if r.leases != nil {
+		if err := mgr.Add(r.sharded(manager.RunnableFunc(r.renewConfigLeases))); err != nil {
+			return err
+		}
+	}
+
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("bolometer")
+	}
+
+	if err := r.settings.SetupWithManager(mgr, r.controllerOptions()); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.controllerOptions()).
 		For(&profilingv1alpha1.ProfilingConfig{}).
+		Watches(&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(r.configsForPod),
+			builder.WithPredicates(podTrackingChanged()),
+		).
+		Watches(&discoveryv1.EndpointSlice{},
+			handler.EnqueueRequestsFromMapFunc(r.configsForEndpointSlice),
+		).
+		Watches(&profilingv1alpha1.BolometerSettings{},
+			handler.EnqueueRequestsFromMapFunc(r.configsForSettings),
+		).
+		Watches(&profilingv1alpha1.ProfileCapture{},
+			handler.EnqueueRequestsFromMapFunc(configForRequest),
+		).
 		Complete(r)
 }
+
+// AddHealthChecks registers the reconciler's health checks with the manager.
+// Liveness fails while captures are wedged; readiness fails while monitors or
+// checks keep failing or a metrics source is unreachable.
+func (r *ProfilingConfigReconciler) AddHealthChecks(mgr ctrl.Manager) error {
+	if err := mgr.AddHealthzCheck("captures", r.captureQueue.Check); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("monitors", r.monitors.Check); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("checks", r.scheduler.Check); err != nil {
+		return err
+	}
+	return mgr.AddReadyzCheck("metrics-sources", r.metricsCollector.Check)
+}
@@ -2,10 +2,16 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -14,14 +20,20 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
 	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 	metricsapiv1alpha1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1alpha1"
 	metricsapiv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
 )
 
 // setupTestReconciler creates a test reconciler with fake clients
@@ -33,20 +45,30 @@ func setupTestReconciler(objs ...client.Object) *ProfilingConfigReconciler {
 	fakeClient := fakeclient.NewClientBuilder().
 		WithScheme(scheme).
 		WithObjects(objs...).
-		WithStatusSubresource(&profilingv1alpha1.ProfilingConfig{}).
+		WithStatusSubresource(&profilingv1alpha1.ProfilingConfig{}, &profilingv1alpha1.ProfileCapture{}, &profilingv1alpha1.BolometerSettings{}).
 		Build()
 
 	fakeClientset := fake.NewSimpleClientset()
 	fakeMetricsClient := &fakeMetricsClientset{}
 
 	reconciler := &ProfilingConfigReconciler{
-		Client:         fakeClient,
-		Scheme:         scheme,
-		Clientset:      fakeClientset,
-		MetricsClient:  fakeMetricsClient,
-		RestConfig:     &rest.Config{},
-		podWatcher:     NewPodWatcher(fakeClientset),
-		activeMonitors: make(map[string]context.CancelFunc),
+		Client:           fakeClient,
+		Scheme:           scheme,
+		Clientset:        fakeClientset,
+		MetricsClient:    fakeMetricsClient,
+		RestConfig:       &rest.Config{},
+		Recorder:         record.NewFakeRecorder(10),
+		podWatcher:       NewPodWatcher(fakeClient),
+		metricsCollector: metrics.NewCollector(fakeMetricsClient),
+		metricsHistory:   metrics.NewHistory(metrics.DefaultHistorySize),
+		usageGauges:      metrics.NewUsageGauges(),
+		suppressions:     metrics.NewSuppressions(),
+		panics:           metrics.NewPanics(),
+		monitors:         NewMonitorManager(),
+		scheduler:        NewScheduler(DefaultCheckWorkers, nil),
+		captureQueue:     NewCaptureQueue(DefaultCaptureWorkers, DefaultCaptureQueueSize),
+		uploaders:        uploader.NewUploader,
+		audit:            audit.Discard,
 	}
 
 	return reconciler
@@ -102,6 +124,9 @@ func createTestPod(name, namespace string, withAnnotation bool) *corev1.Pod {
 		},
 		Status: corev1.PodStatus{
 			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
 		},
 	}
 
@@ -136,7 +161,7 @@ func TestReconcile_ConfigNotFound(t *testing.T) {
 
 	// Verify monitoring is stopped
 	configKey := req.NamespacedName.String()
-	if _, ok := reconciler.activeMonitors[configKey]; ok {
+	if reconciler.monitors.IsRunning(configKey) {
 		t.Error("Expected monitoring to be stopped for deleted config")
 	}
 }
@@ -168,7 +193,7 @@ func TestReconcile_ValidConfig(t *testing.T) {
 
 	// Verify monitoring is started
 	configKey := req.NamespacedName.String()
-	if _, ok := reconciler.activeMonitors[configKey]; !ok {
+	if !reconciler.monitors.IsRunning(configKey) {
 		t.Error("Expected monitoring to be started for valid config")
 	}
 }
@@ -185,14 +210,63 @@ func TestReconcile_InvalidConfig_MissingBucket(t *testing.T) {
 		},
 	}
 
-	_, err := reconciler.Reconcile(context.Background(), req)
+	result, err := reconciler.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Errorf("Expected validation failure to be reported in status, got error: %v", err)
+	}
+	if result.RequeueAfter != 0 || result.Requeue {
+		t.Errorf("Expected no requeue for an invalid spec, got %+v", result)
+	}
 
-	if err == nil {
-		t.Error("Expected error for missing S3 bucket")
+	updatedConfig := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(context.Background(), req.NamespacedName, updatedConfig); err != nil {
+		t.Fatalf("Failed to get updated config: %v", err)
+	}
+	ready := apimeta.FindStatusCondition(updatedConfig.Status.Conditions, ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != reasonInvalidSpec {
+		t.Errorf("Expected Ready=False with reason %s, got %+v", reasonInvalidSpec, ready)
+	}
+	invalid := apimeta.FindStatusCondition(updatedConfig.Status.Conditions, ConditionInvalidSpec)
+	if invalid == nil || invalid.Status != metav1.ConditionTrue || invalid.Message != "s3 bucket is required" {
+		t.Errorf("Expected InvalidSpec=True with the validation error, got %+v", invalid)
+	}
+	if reconciler.monitors.IsRunning("default/test-config") {
+		t.Error("Expected no monitoring for an invalid spec")
+	}
+
+	if updatedConfig.Status.LastErrorMessage != "s3 bucket is required" || updatedConfig.Status.LastErrorTime == nil {
+		t.Errorf("Expected the validation error as last error, got %q at %v",
+			updatedConfig.Status.LastErrorMessage, updatedConfig.Status.LastErrorTime)
+	}
+
+	// The failure is reported once, not on every reconcile
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	expectEvents(t, recorder, "Warning InvalidSpec s3 bucket is required")
+
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	expectEvents(t, recorder)
+
+	reconciled := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(context.Background(), req.NamespacedName, reconciled); err != nil {
+		t.Fatalf("Failed to get updated config: %v", err)
 	}
+	if !reconciled.Status.LastErrorTime.Equal(updatedConfig.Status.LastErrorTime) {
+		t.Errorf("Expected last error time to stay at %v, got %v", updatedConfig.Status.LastErrorTime, reconciled.Status.LastErrorTime)
+	}
+}
+
+// expectEvents checks the events recorded since the last call
+func expectEvents(t *testing.T, recorder *record.FakeRecorder, expected ...string) {
+	t.Helper()
 
-	if err.Error() != "s3 bucket is required" {
-		t.Errorf("Expected 's3 bucket is required' error, got: %v", err)
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
 	}
 }
 
@@ -208,14 +282,17 @@ func TestReconcile_InvalidConfig_MissingRegion(t *testing.T) {
 		},
 	}
 
-	_, err := reconciler.Reconcile(context.Background(), req)
-
-	if err == nil {
-		t.Error("Expected error for missing S3 region")
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Errorf("Expected validation failure to be reported in status, got error: %v", err)
 	}
 
-	if err.Error() != "s3 region is required" {
-		t.Errorf("Expected 's3 region is required' error, got: %v", err)
+	updatedConfig := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(context.Background(), req.NamespacedName, updatedConfig); err != nil {
+		t.Fatalf("Failed to get updated config: %v", err)
+	}
+	invalid := apimeta.FindStatusCondition(updatedConfig.Status.Conditions, ConditionInvalidSpec)
+	if invalid == nil || invalid.Status != metav1.ConditionTrue || invalid.Message != "s3 region is required" {
+		t.Errorf("Expected InvalidSpec=True with the validation error, got %+v", invalid)
 	}
 }
 
@@ -258,6 +335,21 @@ func TestReconcile_StatusUpdate(t *testing.T) {
 	if updatedConfig.Status.ActivePods != 1 {
 		t.Errorf("Expected ActivePods=1, got %d", updatedConfig.Status.ActivePods)
 	}
+	if len(updatedConfig.Status.ProfiledPods) != 1 || updatedConfig.Status.ProfiledPods[0].Pod != "default/test-pod" {
+		t.Errorf("Expected test-pod in profiledPods, got %+v", updatedConfig.Status.ProfiledPods)
+	}
+	if updatedConfig.Status.ObservedGeneration != updatedConfig.Generation {
+		t.Errorf("Expected ObservedGeneration=%d, got %d", updatedConfig.Generation, updatedConfig.Status.ObservedGeneration)
+	}
+
+	for _, conditionType := range []string{ConditionReady, ConditionTargetsFound} {
+		if !apimeta.IsStatusConditionTrue(updatedConfig.Status.Conditions, conditionType) {
+			t.Errorf("Expected condition %s to be true, got %+v", conditionType, updatedConfig.Status.Conditions)
+		}
+	}
+	if condition := apimeta.FindStatusCondition(updatedConfig.Status.Conditions, ConditionUploadHealthy); condition == nil || condition.Status != metav1.ConditionUnknown {
+		t.Errorf("Expected UploadHealthy to be unknown before any upload, got %+v", condition)
+	}
 }
 
 func TestReconcile_MultiplePodsTracked(t *testing.T) {
@@ -316,6 +408,85 @@ func TestReconcile_MultiplePodsTracked(t *testing.T) {
 	}
 }
 
+func TestReconcile_OverlappingConfigs(t *testing.T) {
+	low := createTestProfilingConfig("config-low", "default")
+	high := createTestProfilingConfig("config-high", "default")
+	high.Spec.Priority = 10
+	pod := createTestPod("test-pod", "default", true)
+
+	reconciler := setupTestReconciler(low, high, pod)
+	defer reconciler.monitors.StopAll()
+
+	for _, config := range []*profilingv1alpha1.ProfilingConfig{low, high} {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace}}
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Reconcile of %s returned unexpected error: %v", config.Name, err)
+		}
+	}
+
+	// The higher priority config profiles the pod
+	tracked := reconciler.podWatcher.GetTrackedPods()
+	if len(tracked) != 1 || tracked[0].Config.Name != "config-high" {
+		t.Fatalf("Expected the pod to be owned by config-high, got %+v", tracked)
+	}
+
+	// Reconcile the low priority config again so its status sees the new owner
+	lowReq := ctrl.Request{NamespacedName: types.NamespacedName{Name: low.Name, Namespace: low.Namespace}}
+	if _, err := reconciler.Reconcile(context.Background(), lowReq); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	expectedActive := map[string]int{"config-low": 0, "config-high": 1}
+	for _, config := range []*profilingv1alpha1.ProfilingConfig{low, high} {
+		updated := &profilingv1alpha1.ProfilingConfig{}
+		if err := reconciler.Get(context.Background(), client.ObjectKeyFromObject(config), updated); err != nil {
+			t.Fatalf("Failed to get %s: %v", config.Name, err)
+		}
+
+		if updated.Status.ActivePods != expectedActive[config.Name] {
+			t.Errorf("Expected %s ActivePods=%d, got %d", config.Name, expectedActive[config.Name], updated.Status.ActivePods)
+		}
+		if len(updated.Status.Conflicts) != 1 || updated.Status.Conflicts[0].Owner != "default/config-high" {
+			t.Errorf("Expected %s to report the conflict owned by config-high, got %+v", config.Name, updated.Status.Conflicts)
+		}
+		if !apimeta.IsStatusConditionTrue(updated.Status.Conditions, ConditionPodConflict) {
+			t.Errorf("Expected %s to have the %s condition set", config.Name, ConditionPodConflict)
+		}
+	}
+}
+
+func TestUpdateCaptureStatus(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	key := client.ObjectKeyFromObject(config)
+
+	reconciler.updateCaptureStatus(context.Background(), config, nil)
+
+	updated := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(context.Background(), key, updated); err != nil {
+		t.Fatalf("Failed to get updated config: %v", err)
+	}
+	if updated.Status.TotalProfiles != 1 || updated.Status.LastErrorMessage != "" {
+		t.Errorf("Expected one profile and no error, got %+v", updated.Status)
+	}
+
+	captureErr := &uploadError{fmt.Errorf("failed to upload profiles: access denied")}
+	reconciler.updateCaptureStatus(context.Background(), config, captureErr)
+
+	if err := reconciler.Get(context.Background(), key, updated); err != nil {
+		t.Fatalf("Failed to get updated config: %v", err)
+	}
+	if updated.Status.TotalProfiles != 1 {
+		t.Errorf("Expected failed capture not to be counted, got %d profiles", updated.Status.TotalProfiles)
+	}
+	if updated.Status.LastErrorMessage != captureErr.Error() || updated.Status.LastErrorTime == nil {
+		t.Errorf("Expected capture error to be recorded, got %q at %v", updated.Status.LastErrorMessage, updated.Status.LastErrorTime)
+	}
+	if !apimeta.IsStatusConditionTrue(updated.Status.Conditions, ConditionDegraded) {
+		t.Error("Expected Degraded after a failed upload")
+	}
+}
+
 func TestReconcile_PodWithoutAnnotation(t *testing.T) {
 	config := createTestProfilingConfig("test-config", "default")
 	pod := createTestPod("test-pod", "default", false) // No annotation
@@ -379,26 +550,45 @@ func TestReconcile_MonitoringRestartOnUpdate(t *testing.T) {
 	}
 
 	configKey := req.NamespacedName.String()
-	firstCancel, ok := reconciler.activeMonitors[configKey]
-	if !ok {
+	if !reconciler.monitors.IsRunning(configKey) {
 		t.Fatal("Expected monitoring to be started")
 	}
 
-	// Second reconcile - should restart monitoring
+	firstHash, _ := reconciler.monitors.Hash(configKey)
+
+	// Second reconcile with the same spec - monitoring keeps running
 	_, err = reconciler.Reconcile(context.Background(), req)
 	if err != nil {
 		t.Errorf("Second reconcile failed: %v", err)
 	}
 
-	secondCancel, ok := reconciler.activeMonitors[configKey]
-	if !ok {
-		t.Fatal("Expected monitoring to be restarted")
+	first := reconciler.monitors.List()
+	if len(first) != 1 || first[0].Hash != firstHash {
+		t.Fatalf("Expected the original monitor to keep running, got %+v", first)
+	}
+
+	// Change the spec - monitoring should restart
+	updated := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	updated.Spec.Thresholds.CPUThresholdPercent = 50
+	if err := reconciler.Update(context.Background(), updated); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	_, err = reconciler.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Errorf("Third reconcile failed: %v", err)
 	}
 
-	// Verify it's a new cancel function (monitoring was restarted)
-	// We can't directly compare functions, but we can check they're both present
-	if firstCancel == nil || secondCancel == nil {
-		t.Error("Expected valid cancel functions")
+	second := reconciler.monitors.List()
+	if len(second) != 1 {
+		t.Fatalf("Expected a single monitor after restart, got %d", len(second))
+	}
+
+	if second[0].Hash == firstHash || !second[0].StartedAt.After(first[0].StartedAt) {
+		t.Error("Expected monitoring to be restarted after a spec change")
 	}
 }
 
@@ -425,16 +615,22 @@ func TestReconcile_WithOnDemandEnabled(t *testing.T) {
 
 	// Verify monitoring is started
 	configKey := req.NamespacedName.String()
-	if _, ok := reconciler.activeMonitors[configKey]; !ok {
+	if !reconciler.monitors.IsRunning(configKey) {
 		t.Error("Expected monitoring to be started with on-demand enabled")
 	}
 
-	// Give goroutines time to start
-	time.Sleep(10 * time.Millisecond)
+	// Both threshold and on-demand checks should be scheduled
+	due := reconciler.scheduler.Scheduled(configKey)
+	if len(due) != 2 {
+		t.Fatalf("Expected two scheduled checks, got %v", due)
+	}
 
-	// Both threshold and on-demand monitoring should be active
-	// (We can't easily verify this without complex mocking, but at least
-	// we verify the reconcile succeeded)
+	if _, ok := due["thresholds"]; !ok {
+		t.Errorf("Expected the thresholds check, got %v", due)
+	}
+	if _, ok := due["on-demand"]; !ok {
+		t.Errorf("Expected the on-demand check, got %v", due)
+	}
 }
 
 func TestValidateConfig_Valid(t *testing.T) {
@@ -469,23 +665,314 @@ func TestValidateConfig_MissingRegion(t *testing.T) {
 	}
 }
 
+func TestValidateConfig_StorageTransport(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler()
+
+	config.Spec.S3Config.ProxyURL = "proxy.internal:3128"
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for a proxy URL without scheme")
+	}
+
+	config.Spec.S3Config.ProxyURL = "http://proxy.internal:3128"
+	config.Spec.S3Config.CABundleRef = &profilingv1alpha1.ConfigMapKeyRef{}
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for a CA bundle reference without name")
+	}
+
+	config.Spec.S3Config.CABundleRef.Name = "corporate-ca"
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+
+	config.Spec.S3Config.Accelerate = true
+	config.Spec.S3Config.Endpoint = "https://minio.internal:9000"
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for acceleration with a custom endpoint")
+	}
+}
+
+func TestValidateConfig_SelectorLabels(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler()
+
+	config.Spec.Selector.LabelSelector = map[string]string{"app kubernetes": "my-app"}
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an invalid label key")
+	}
+
+	config.Spec.Selector.LabelSelector = map[string]string{"app": "my app"}
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an invalid label value")
+	}
+
+	config.Spec.Selector.LabelSelector = nil
+	config.Spec.Selector.Service = "My_Service"
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an invalid service name")
+	}
+}
+
+func TestValidateConfig_Defaults(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config.Bucket = ""
+	config.Spec.Thresholds.CooldownSeconds = 0
+
+	if err := ValidateConfig(config.DeepCopy(), nil); err == nil {
+		t.Error("Expected error for missing bucket without settings")
+	}
+
+	settings := &profilingv1alpha1.BolometerSettingsSpec{
+		DefaultS3Config: &profilingv1alpha1.S3Configuration{Bucket: "shared-bucket"},
+	}
+	if err := ValidateConfig(config, settings); err != nil {
+		t.Errorf("Expected the settings bucket to be used, got error: %v", err)
+	}
+	if config.Spec.Thresholds.CooldownSeconds != defaultCooldownSeconds {
+		t.Errorf("Expected the built-in cooldown, got %d", config.Spec.Thresholds.CooldownSeconds)
+	}
+}
+
+func TestValidateConfig_PrometheusWithoutURL(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.MetricsSource = "prometheus"
+	reconciler := setupTestReconciler()
+
+	err := reconciler.validateConfig(config)
+	if err == nil {
+		t.Error("Expected error for prometheus source without url")
+	}
+
+	config.Spec.Prometheus = &profilingv1alpha1.PrometheusConfig{URL: "http://prometheus:9090"}
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_MemoryMetric(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.MemoryMetric = "rss"
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for the rss memory metric with metrics-server")
+	}
+
+	config.Spec.MetricsSource = "kubelet"
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_NetworkIO(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.NetworkIO = &profilingv1alpha1.NetworkIOConfig{CheckIntervalSeconds: 30}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for networkIO without a threshold")
+	}
+
+	transmit := resource.MustParse("10Mi")
+	config.Spec.NetworkIO.TransmitBytesPerSecond = &transmit
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_Conditions(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Thresholds.Conditions = []profilingv1alpha1.ConditionGroup{{All: []profilingv1alpha1.MetricCondition{
+		{Metric: profilingv1alpha1.ConditionCPUPercent, Value: 80},
+	}}}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+
+	config.Spec.Thresholds.PerContainer = true
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for conditions evaluated per container")
+	}
+}
+
+func TestValidateConfig_TriggerExpression(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.TriggerExpression = "cpuPercent > 80.0 && restarts > 3"
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+
+	config.Spec.TriggerExpression = "cpuPercent > "
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an expression that does not compile")
+	}
+}
+
+func TestValidateConfig_WebhookURL(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		Webhooks: []profilingv1alpha1.WebhookNotification{{URL: "http://automation.example.com/hooks"}},
+	}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for a webhook without https")
+	}
+
+	config.Spec.Notifications.Webhooks[0].URL = "https://automation.example.com/hooks"
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_SNSAndSQS(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		SNS: &profilingv1alpha1.SNSNotification{TopicARN: "captures"},
+	}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an SNS topic that is not an ARN")
+	}
+
+	config.Spec.Notifications.SNS.TopicARN = "arn:aws:sns:us-east-1:123456789012:captures"
+	config.Spec.Notifications.SQS = &profilingv1alpha1.SQSNotification{QueueURL: "http://sqs.us-east-1.amazonaws.com/123456789012/captures"}
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an SQS queue without https")
+	}
+
+	config.Spec.Notifications.SQS.QueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/captures"
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_Kafka(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		Kafka: &profilingv1alpha1.KafkaNotification{Topic: "profile-captures"},
+	}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for Kafka without brokers")
+	}
+
+	config.Spec.Notifications.Kafka.Brokers = []string{"kafka-0.kafka:9092"}
+	config.Spec.Notifications.Kafka.SASL = &profilingv1alpha1.KafkaSASL{
+		UsernameSecretRef: profilingv1alpha1.SecretKeyRef{Name: "kafka-credentials"},
+	}
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for SASL without a password secret")
+	}
+
+	config.Spec.Notifications.Kafka.SASL.PasswordSecretRef.Name = "kafka-credentials"
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_Email(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Notifications = &profilingv1alpha1.NotificationConfig{
+		Email: &profilingv1alpha1.EmailNotification{SMTPSecretName: "smtp", From: "bolometer@example.com"},
+	}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an email without recipients")
+	}
+
+	config.Spec.Notifications.Email.To = []string{"oncall@example.com"}
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestValidateConfig_Baseline(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Baseline = &profilingv1alpha1.BaselineConfig{
+		Profiles: []profilingv1alpha1.BaselineProfile{
+			{Type: "cpu", Key: "baselines/v1.4.0/cpu.pprof"},
+			{Type: "cpu", Key: "baselines/v1.5.0/cpu.pprof"},
+		},
+	}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for a baseline with several cpu profiles")
+	}
+
+	config.Spec.Baseline.Profiles[1].Type = "heap"
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+}
+
+func TestSpecHash(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+
+	first, err := specHash(&config.Spec)
+	if err != nil {
+		t.Fatalf("specHash returned error: %v", err)
+	}
+
+	config.Status.ActivePods = 3
+	unchanged, _ := specHash(&config.Spec)
+	if unchanged != first {
+		t.Error("Expected status changes not to affect the hash")
+	}
+
+	config.Spec.ProfileTypes = []string{"heap"}
+	changed, _ := specHash(&config.Spec)
+	if changed == first {
+		t.Error("Expected spec changes to change the hash")
+	}
+}
+
+func TestMonitorContext_OutlivesReconcile(t *testing.T) {
+	reconciler := setupTestReconciler()
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	reconciler.baseCtx = baseCtx
+
+	reconcileCtx, cancelReconcile := context.WithCancel(context.Background())
+	monitorCtx := reconciler.monitorContext(reconcileCtx)
+
+	// Reconcile returning must not stop monitors
+	cancelReconcile()
+	if monitorCtx.Err() != nil {
+		t.Fatal("Expected monitor context to survive the reconcile context")
+	}
+
+	// Controller shutdown stops them
+	cancelBase()
+	if monitorCtx.Err() == nil {
+		t.Error("Expected monitor context to be cancelled with the base context")
+	}
+}
+
 func TestStopMonitoring(t *testing.T) {
 	config := createTestProfilingConfig("test-config", "default")
 	reconciler := setupTestReconciler(config)
 
 	// Start monitoring
 	ctx := context.Background()
-	reconciler.startMonitoring(ctx, config)
+	reconciler.startMonitoring(ctx, config, "hash")
 
 	configKey := config.Namespace + "/" + config.Name
-	if _, ok := reconciler.activeMonitors[configKey]; !ok {
+	if !reconciler.monitors.IsRunning(configKey) {
 		t.Fatal("Expected monitoring to be started")
 	}
 
 	// Stop monitoring
 	reconciler.stopMonitoring(configKey)
 
-	if _, ok := reconciler.activeMonitors[configKey]; ok {
+	if reconciler.monitors.IsRunning(configKey) {
 		t.Error("Expected monitoring to be stopped")
 	}
 }
@@ -497,7 +984,7 @@ func TestStopMonitoring_NotStarted(t *testing.T) {
 	configKey := "default/nonexistent"
 	reconciler.stopMonitoring(configKey) // Should not panic
 
-	if _, ok := reconciler.activeMonitors[configKey]; ok {
+	if reconciler.monitors.IsRunning(configKey) {
 		t.Error("Expected no monitoring entry")
 	}
 }
@@ -520,7 +1007,7 @@ func TestReconcile_ConfigDeletion(t *testing.T) {
 	}
 
 	configKey := req.NamespacedName.String()
-	if _, ok := reconciler.activeMonitors[configKey]; !ok {
+	if !reconciler.monitors.IsRunning(configKey) {
 		t.Fatal("Expected monitoring to be started")
 	}
 
@@ -541,11 +1028,119 @@ func TestReconcile_ConfigDeletion(t *testing.T) {
 	}
 
 	// Verify monitoring is stopped
-	if _, ok := reconciler.activeMonitors[configKey]; ok {
+	if reconciler.monitors.IsRunning(configKey) {
 		t.Error("Expected monitoring to be stopped after deletion")
 	}
 }
 
+func TestReconcile_DeletedPodUntracked(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod1 := createTestPod("test-pod-1", "default", true)
+	pod2 := createTestPod("test-pod-2", "default", true)
+
+	reconciler := setupTestReconciler(config, pod1, pod2)
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(config)}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	reconciler.metricsHistory.Record("default/test-pod-2", &metrics.PodMetrics{})
+
+	if err := reconciler.Delete(ctx, pod2); err != nil {
+		t.Fatalf("Failed to delete pod: %v", err)
+	}
+
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	tracked := reconciler.podWatcher.GetTrackedPods()
+	if len(tracked) != 1 || tracked[0].Pod.Name != "test-pod-1" {
+		t.Errorf("Expected only test-pod-1 to be tracked, got %d pods", len(tracked))
+	}
+
+	if reconciler.metricsHistory.Samples("default/test-pod-2") != nil {
+		t.Error("Expected history of deleted pod to be dropped")
+	}
+}
+
+func TestProfiledPods_Usage(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod1 := createTestPod("test-pod-1", "default", true)
+	pod2 := createTestPod("test-pod-2", "default", true)
+	reconciler := setupTestReconciler(config)
+	reconciler.podWatcher.TrackPod(pod1, config)
+	reconciler.podWatcher.TrackPod(pod2, config)
+
+	sampled := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	reconciler.metricsHistory.Record("default/test-pod-1", &metrics.PodMetrics{Timestamp: sampled.Add(-time.Minute), CPUUsagePercent: 10})
+	reconciler.metricsHistory.Record("default/test-pod-1", &metrics.PodMetrics{Timestamp: sampled, CPUUsagePercent: 92.5, MemoryUsagePercent: 40})
+
+	pods := reconciler.profiledPods(config)
+	if len(pods) != 2 {
+		t.Fatalf("Expected 2 profiled pods, got %+v", pods)
+	}
+	if p := pods[0]; p.CPUUsagePercent != "92.50" || p.MemoryUsagePercent != "40.00" || p.UsageTime == nil || !p.UsageTime.Time.Equal(sampled) {
+		t.Errorf("Expected the latest usage of test-pod-1, got %+v", p)
+	}
+	if p := pods[1]; p.CPUUsagePercent != "" || p.UsageTime != nil {
+		t.Errorf("Expected no usage for an unsampled pod, got %+v", p)
+	}
+}
+
+func TestConfigsForPod(t *testing.T) {
+	matching := createTestProfilingConfig("matching", "default")
+	otherLabels := createTestProfilingConfig("other-labels", "default")
+	otherLabels.Spec.Selector.LabelSelector = map[string]string{"app": "other"}
+	otherNamespace := createTestProfilingConfig("other-namespace", "other")
+
+	reconciler := setupTestReconciler(matching, otherLabels, otherNamespace)
+
+	requests := reconciler.configsForPod(context.Background(), createTestPod("test-pod", "default", false))
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+
+	if requests[0].Name != "matching" || requests[0].Namespace != "default" {
+		t.Errorf("Expected request for default/matching, got %s", requests[0].NamespacedName)
+	}
+}
+
+func TestPodTrackingChanged(t *testing.T) {
+	pred := podTrackingChanged()
+	pod := createTestPod("test-pod", "default", true)
+
+	unchanged := pod.DeepCopy()
+	unchanged.Status.PodIP = "10.0.0.1"
+	if pred.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: unchanged}) {
+		t.Error("Expected unrelated status change to be filtered")
+	}
+
+	annotated := pod.DeepCopy()
+	annotated.Annotations[ProfilingEnabledAnnotation] = "false"
+	if !pred.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: annotated}) {
+		t.Error("Expected annotation change to pass")
+	}
+
+	stopped := pod.DeepCopy()
+	stopped.Status.Phase = corev1.PodSucceeded
+	if !pred.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: stopped}) {
+		t.Error("Expected phase change to pass")
+	}
+
+	unready := pod.DeepCopy()
+	unready.Status.Conditions[0].Status = corev1.ConditionFalse
+	if !pred.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: unready}) {
+		t.Error("Expected readiness change to pass")
+	}
+
+	if !pred.Delete(event.DeleteEvent{Object: pod}) {
+		t.Error("Expected deletes to pass")
+	}
+}
+
 func TestReconcile_NamespaceIsolation(t *testing.T) {
 	config := createTestProfilingConfig("test-config", "namespace-a")
 	pod1 := createTestPod("pod-1", "namespace-a", true)
@@ -635,6 +1230,7 @@ func TestNewProfilingConfigReconciler(t *testing.T) {
 		fakeClientset,
 		fakeMetricsClient,
 		restConfig,
+		ReconcilerOptions{},
 	)
 
 	if reconciler == nil {
@@ -665,12 +1261,81 @@ func TestNewProfilingConfigReconciler(t *testing.T) {
 		t.Error("Expected metricsCollector to be initialized")
 	}
 
+	if reconciler.metricsHistory == nil {
+		t.Error("Expected metricsHistory to be initialized")
+	}
+
 	if reconciler.profiler == nil {
 		t.Error("Expected profiler to be initialized")
 	}
 
-	if reconciler.activeMonitors == nil {
-		t.Error("Expected activeMonitors map to be initialized")
+	if reconciler.monitors == nil {
+		t.Error("Expected monitors to be initialized")
+	}
+
+	if reconciler.uploaders == nil {
+		t.Error("Expected uploaders to be initialized")
+	}
+
+	podProfiler := &profiler.Fake{}
+	reconciler = NewProfilingConfigReconciler(fakeClient, scheme, fakeClientset, fakeMetricsClient, restConfig,
+		ReconcilerOptions{Profiler: podProfiler})
+	if reconciler.profiler != podProfiler || reconciler.localCluster().profiler != podProfiler {
+		t.Error("Expected the given profiler to be used")
+	}
+}
+
+func TestCaptureAndUploadProfiles_ProfilerError(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config)
+	podProfiler := &profiler.Fake{Err: errors.New("connection refused")}
+	reconciler.profiler = podProfiler
+
+	_, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the profiler's error, got %v", err)
+	}
+	if captured := podProfiler.Captured(); len(captured) != 1 || captured[0] != "default/test-pod" {
+		t.Errorf("Expected test-pod to be captured through the profiler, got %v", captured)
+	}
+}
+
+func TestCaptureAndUploadProfiles_Uploader(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config.PresignExpirySeconds = 3600
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config)
+	reconciler.profiler = &profiler.Fake{Data: map[string][]byte{"heap": []byte("heap profile")}}
+	profileUploader := &uploader.Fake{Bucket: "test-bucket", Prefix: "profiles"}
+	var storage uploader.S3Config
+	reconciler.uploaders = func(_ context.Context, cfg uploader.S3Config) (uploader.Uploader, error) {
+		storage = cfg
+		return profileUploader, nil
+	}
+
+	manifest, _, err := reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if err != nil {
+		t.Fatalf("captureAndUploadProfiles returned unexpected error: %v", err)
+	}
+	if storage.Bucket != config.Spec.S3Config.Bucket {
+		t.Errorf("Expected the uploader of bucket %s, got %+v", config.Spec.S3Config.Bucket, storage)
+	}
+	if uploaded := profileUploader.Uploaded(); len(uploaded) != 1 || uploaded[0] != manifest {
+		t.Fatalf("Expected the capture to be uploaded through the uploader, got %v", uploaded)
+	}
+	if len(manifest.Objects) != 1 || manifest.Objects[0].SizeBytes != len("heap profile") || manifest.Reason != "test" {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+	// The fake cannot presign, the capture is uploaded without links
+	if manifest.Objects[0].URL != "" || !manifest.URLsExpire.IsZero() {
+		t.Errorf("Expected no presigned URLs, got %+v", manifest.Objects[0])
+	}
+
+	profileUploader.Err = errors.New("access denied")
+	_, _, err = reconciler.captureAndUploadProfiles(context.Background(), pod, config, []string{"heap"}, metrics.Trigger{Reason: "test"})
+	if !isUploadError(err) || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("Expected an upload error, got %v", err)
 	}
 }
 
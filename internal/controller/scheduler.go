@@ -0,0 +1,386 @@
+package controller
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+const (
+	// DefaultCheckWorkers is the number of checks of trigger sources run concurrently across all configs
+	DefaultCheckWorkers = 4
+
+	// minCheckDelay is the shortest delay between two runs of a check
+	minCheckDelay = time.Second
+)
+
+// ScheduledTask is a named check run periodically by the Scheduler. Check
+// returns the delay until its next run.
+type ScheduledTask struct {
+	Name string
+
+	// Delay is the delay until the first run
+	Delay time.Duration
+
+	Check func(ctx context.Context) time.Duration
+}
+
+// scheduledCheck is a task in the queue of the Scheduler
+type scheduledCheck struct {
+	task  ScheduledTask
+	group *checkGroup
+	due   time.Time
+
+	// index is the position of the check in the queue, -1 while it runs
+	index int
+
+	// panics counts the consecutive runs of the check that panicked, the
+	// last of them being lastPanic
+	panics    int
+	lastPanic error
+}
+
+// checkGroup is the checks scheduled under a key
+type checkGroup struct {
+	key     string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	checks  []*scheduledCheck
+	running sync.WaitGroup
+	removed bool
+}
+
+// checkQueue orders checks by due time. It implements heap.Interface.
+type checkQueue []*scheduledCheck
+
+func (q checkQueue) Len() int           { return len(q) }
+func (q checkQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q checkQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *checkQueue) Push(x any) {
+	check := x.(*scheduledCheck)
+	check.index = len(*q)
+	*q = append(*q, check)
+}
+
+func (q *checkQueue) Pop() any {
+	old := *q
+	check := old[len(old)-1]
+	old[len(old)-1] = nil
+	check.index = -1
+	*q = old[:len(old)-1]
+	return check
+}
+
+// Scheduler runs the checks of every config from a single queue ordered by
+// due time, on a fixed pool of check workers, rather than a goroutine and a
+// ticker per check. A check is queued again once it returns, so it never runs
+// twice at once; checks that panic are retried with backoff. It implements
+// manager.Runnable.
+type Scheduler struct {
+	workers      int
+	metrics      *metrics.SchedulerMetrics
+	logger       logr.Logger
+	restartDelay time.Duration
+
+	// panics counts the panics of the checks of each key
+	panics *metrics.Panics
+
+	mu     sync.Mutex
+	queue  checkQueue
+	groups map[string]*checkGroup
+
+	// wake interrupts the wait for the next due check when the queue changes
+	wake chan struct{}
+}
+
+// NewScheduler creates a scheduler running checks on workers goroutines.
+// New metrics are created if schedulerMetrics is nil.
+func NewScheduler(workers int, schedulerMetrics *metrics.SchedulerMetrics) *Scheduler {
+	if workers <= 0 {
+		workers = DefaultCheckWorkers
+	}
+	if schedulerMetrics == nil {
+		schedulerMetrics = metrics.NewSchedulerMetrics()
+	}
+	return &Scheduler{
+		workers:      workers,
+		metrics:      schedulerMetrics,
+		logger:       ctrl.Log.WithName("scheduler"),
+		restartDelay: DefaultMonitorRestartDelay,
+		panics:       metrics.NewPanics(),
+		groups:       make(map[string]*checkGroup),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// CountPanics counts the panics of checks with panics, e.g. counters
+// registered with the metrics endpoint
+func (s *Scheduler) CountPanics(panics *metrics.Panics) {
+	s.panics = panics
+}
+
+// Schedule runs the tasks under key, each first after its Delay, replacing
+// the tasks scheduled under key before. Tasks stop when parentCtx is done or
+// they are unscheduled.
+func (s *Scheduler) Schedule(parentCtx context.Context, key string, tasks ...ScheduledTask) {
+	s.Unschedule(key)
+	if len(tasks) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(parentCtx)
+	group := &checkGroup{key: key, ctx: ctx, cancel: cancel}
+	now := time.Now()
+	for _, task := range tasks {
+		check := &scheduledCheck{task: task, group: group, due: now.Add(task.Delay)}
+		group.checks = append(group.checks, check)
+		heap.Push(&s.queue, check)
+	}
+	s.groups[key] = group
+	s.scheduledChangedLocked()
+}
+
+// Unschedule drops the tasks scheduled under key, cancelling the context of
+// those running. The returned channel is closed once they have returned.
+func (s *Scheduler) Unschedule(key string) <-chan struct{} {
+	s.mu.Lock()
+	group, ok := s.groups[key]
+	if ok {
+		s.removeLocked(key, group)
+		s.scheduledChangedLocked()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	if !ok {
+		close(done)
+		return done
+	}
+	go func() {
+		group.running.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// UnscheduleAll drops the tasks of every key
+func (s *Scheduler) UnscheduleAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, group := range s.groups {
+		s.removeLocked(key, group)
+	}
+	s.scheduledChangedLocked()
+}
+
+// removeLocked drops a group and its queued checks (must be called with lock held)
+func (s *Scheduler) removeLocked(key string, group *checkGroup) {
+	group.removed = true
+	group.cancel()
+	for _, check := range group.checks {
+		if check.index >= 0 {
+			heap.Remove(&s.queue, check.index)
+		}
+	}
+	delete(s.groups, key)
+}
+
+// scheduledChangedLocked wakes the scheduler up and updates the metrics after
+// the scheduled checks changed (must be called with lock held)
+func (s *Scheduler) scheduledChangedLocked() {
+	checks := 0
+	for _, group := range s.groups {
+		checks += len(group.checks)
+	}
+	s.metrics.SetScheduled(checks)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Scheduled returns the due time of each task scheduled under key, by name
+func (s *Scheduler) Scheduled(key string) map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[key]
+	if !ok {
+		return nil
+	}
+	due := make(map[string]time.Time, len(group.checks))
+	for _, check := range group.checks {
+		due[check.task.Name] = check.due
+	}
+	return due
+}
+
+// Start runs the due checks on the check workers until ctx is cancelled,
+// waiting for the running checks to return. It implements manager.Runnable.
+func (s *Scheduler) Start(ctx context.Context) error {
+	ready := make(chan *scheduledCheck)
+	var workers sync.WaitGroup
+	workers.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer workers.Done()
+			for check := range ready {
+				s.run(check)
+			}
+		}()
+	}
+	defer func() {
+		close(ready)
+		workers.Wait()
+	}()
+
+	for {
+		check, wait := s.next()
+		if check != nil {
+			select {
+			case ready <- check:
+			case <-ctx.Done():
+				check.group.running.Done()
+				return nil
+			}
+			continue
+		}
+
+		// With nothing scheduled, only a wake-up ends the wait
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// next pops the earliest check if it is due. Otherwise it returns how long
+// until it is due, or a negative delay when nothing is scheduled.
+func (s *Scheduler) next() (*scheduledCheck, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, -1
+	}
+	if wait := time.Until(s.queue[0].due); wait > 0 {
+		return nil, wait
+	}
+	check := heap.Pop(&s.queue).(*scheduledCheck)
+	check.group.running.Add(1)
+	return check, 0
+}
+
+// run runs a check and queues it again after the delay it returns, or after
+// a backoff if it panicked, unless it was unscheduled in the meantime
+func (s *Scheduler) run(check *scheduledCheck) {
+	defer check.group.running.Done()
+	if check.group.ctx.Err() != nil {
+		return
+	}
+
+	started := time.Now()
+	delay, err := s.runCheck(check)
+	s.metrics.ObserveCheck(check.task.Name, started.Sub(check.due), time.Since(started))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if panicked, ok := asPanic(err); ok {
+		check.panics++
+		check.lastPanic = panicked
+		delay = restartBackoff(s.restartDelay, check.panics)
+		s.panics.Inc(check.group.key, check.task.Name)
+		s.logger.Error(panicked, "Check panicked, rescheduling", "config", check.group.key,
+			"delay", delay, "stack", string(panicked.stack))
+	} else {
+		check.panics = 0
+		delay = max(delay, minCheckDelay)
+	}
+
+	if check.group.removed {
+		return
+	}
+	check.due = time.Now().Add(delay)
+	heap.Push(&s.queue, check)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runCheck runs a check, converting a panic into a *panicError
+func (s *Scheduler) runCheck(check *scheduledCheck) (delay time.Duration, err error) {
+	defer recoverPanic("check "+check.task.Name, &err)
+
+	return check.task.Check(check.group.ctx), nil
+}
+
+// restartBackoff returns the delay before running a task again after it
+// failed failures times in a row, doubling from base up to maxMonitorRestartDelay
+func restartBackoff(base time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < maxMonitorRestartDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxMonitorRestartDelay)
+}
+
+// Check reports an error while any check keeps panicking. It implements
+// healthz.Checker.
+func (s *Scheduler) Check(_ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.groups))
+	for key := range s.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		for _, check := range s.groups[key].checks {
+			if check.panics < unhealthyTaskFailures {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("check %s of %s panicked %d times in a row: %w",
+				check.task.Name, key, check.panics, check.lastPanic))
+		}
+	}
+
+	return errors.Join(errs...)
+}
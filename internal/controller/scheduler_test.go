@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// recordingTask is a scheduled task appending its name to runs, first after
+// delay and then every next
+func recordingTask(name string, delay, next time.Duration, mu *sync.Mutex, runs *[]string) ScheduledTask {
+	return ScheduledTask{
+		Name:  name,
+		Delay: delay,
+		Check: func(ctx context.Context) time.Duration {
+			mu.Lock()
+			defer mu.Unlock()
+			*runs = append(*runs, name)
+			return next
+		},
+	}
+}
+
+// startScheduler runs a scheduler until the test ends
+func startScheduler(t *testing.T, scheduler *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = scheduler.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestScheduler_RunsChecksByDueTime(t *testing.T) {
+	scheduler := NewScheduler(1, nil)
+	var mu sync.Mutex
+	var runs []string
+
+	scheduler.Schedule(context.Background(), "default/a", recordingTask("slow", 60*time.Millisecond, time.Hour, &mu, &runs))
+	scheduler.Schedule(context.Background(), "default/b",
+		recordingTask("fast", 0, time.Hour, &mu, &runs),
+		recordingTask("medium", 30*time.Millisecond, time.Hour, &mu, &runs),
+	)
+	startScheduler(t, scheduler)
+
+	waitFor(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(runs) == 3
+	})
+	if order := strings.Join(runs, ","); order != "fast,medium,slow" {
+		t.Errorf("Expected checks to run by due time, got %s", order)
+	}
+
+	// Checks are queued again after the delay they return
+	due := scheduler.Scheduled("default/b")
+	if next := time.Until(due["fast"]); next < 50*time.Minute {
+		t.Errorf("Expected the next check in an hour, got %v", next)
+	}
+}
+
+func TestScheduler_MinimumDelay(t *testing.T) {
+	scheduler := NewScheduler(1, nil)
+	var mu sync.Mutex
+	var runs []string
+
+	scheduler.Schedule(context.Background(), "default/config", recordingTask("eager", 0, 0, &mu, &runs))
+	startScheduler(t, scheduler)
+
+	waitFor(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(runs) == 1
+	})
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(runs) != 1 {
+		t.Errorf("Expected checks returning no delay to wait %v, ran %d times", minCheckDelay, len(runs))
+	}
+}
+
+func TestScheduler_Unschedule(t *testing.T) {
+	scheduler := NewScheduler(2, nil)
+	started := make(chan struct{})
+	scheduler.Schedule(context.Background(), "default/config", ScheduledTask{
+		Name: "blocking",
+		Check: func(ctx context.Context) time.Duration {
+			close(started)
+			<-ctx.Done()
+			return time.Hour
+		},
+	})
+	startScheduler(t, scheduler)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the check")
+	}
+
+	done := scheduler.Unschedule("default/config")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the running check to return after Unschedule")
+	}
+	if due := scheduler.Scheduled("default/config"); due != nil {
+		t.Errorf("Expected no scheduled checks, got %v", due)
+	}
+
+	// Unscheduling an unknown key reports done immediately
+	select {
+	case <-scheduler.Unschedule("default/unknown"):
+	default:
+		t.Error("Expected closed channel for unknown key")
+	}
+}
+
+func TestScheduler_Panic(t *testing.T) {
+	scheduler := NewScheduler(1, nil)
+	scheduler.restartDelay = time.Millisecond
+	var mu sync.Mutex
+	var runs []string
+
+	scheduler.Schedule(context.Background(), "default/bad", ScheduledTask{
+		Name: "panicking",
+		Check: func(ctx context.Context) time.Duration {
+			var config *profilingv1alpha1.ProfilingConfig
+			return time.Duration(config.Spec.Thresholds.CheckIntervalSeconds)
+		},
+	})
+	scheduler.Schedule(context.Background(), "default/good", recordingTask("healthy", 20*time.Millisecond, time.Hour, &mu, &runs))
+	startScheduler(t, scheduler)
+
+	// The panic neither stops the scheduler nor the check, which is retried
+	// with backoff rather than after the minimum delay
+	waitFor(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(runs) == 1
+	})
+	waitFor(t, time.Second, func() bool { return scheduler.Check(nil) != nil })
+
+	if count := testutil.ToFloat64(scheduler.panics); count < unhealthyTaskFailures {
+		t.Errorf("Expected at least %d panics counted, got %v", unhealthyTaskFailures, count)
+	}
+	err := scheduler.Check(nil)
+	if err == nil {
+		t.Fatal("Expected a check that keeps panicking to be reported unhealthy")
+	}
+	if !strings.Contains(err.Error(), "default/bad") || !strings.Contains(err.Error(), "panicking") {
+		t.Errorf("Expected error to name the config and the check, got %v", err)
+	}
+
+	// Unscheduled checks are not reported
+	<-scheduler.Unschedule("default/bad")
+	if err := scheduler.Check(nil); err != nil {
+		t.Errorf("Expected no unhealthy checks once unscheduled, got %v", err)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	for failures, expected := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		20: maxMonitorRestartDelay,
+	} {
+		if delay := restartBackoff(time.Second, failures); delay != expected {
+			t.Errorf("Expected %v after %d failures, got %v", expected, failures, delay)
+		}
+	}
+}
+
+func TestStartMonitoring_SchedulesPeriodicSources(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.OnDemand = &profilingv1alpha1.OnDemandConfig{Enabled: true, IntervalSeconds: 60}
+	config.Spec.EventTriggers = []profilingv1alpha1.EventTrigger{{Reason: "BackOff"}}
+	reconciler := setupTestReconciler(config)
+
+	reconciler.startMonitoring(context.Background(), config, "hash")
+	defer reconciler.stopMonitoring(configKeyOf(config))
+
+	due := reconciler.scheduler.Scheduled(configKeyOf(config))
+	if _, ok := due["thresholds"]; !ok || len(due) != 2 {
+		t.Errorf("Expected the threshold and on-demand checks to be scheduled, got %v", due)
+	}
+	if _, ok := due["on-demand"]; !ok {
+		t.Errorf("Expected the on-demand check to be scheduled, got %v", due)
+	}
+
+	// Event triggers watch Events on a monitor task of their own
+	running := reconciler.monitors.List()
+	if len(running) != 1 || len(running[0].Tasks) != 1 || running[0].Tasks[0] != "events" {
+		t.Errorf("Expected only the event triggers to run as a monitor task, got %+v", running)
+	}
+}
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// cronSearchYears bounds how far ahead the next time of a cron schedule is
+// searched, so that expressions never matching, such as February 30, end
+const cronSearchYears = 5
+
+// cronSchedule is a parsed five-field cron expression. Each field is the set of
+// values it matches, as a bitmask.
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+
+	// anyDayOfMonth and anyDayOfWeek are set when the field is "*". When
+	// both day fields are restricted, a day matching either one matches.
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// parseCron parses a five-field cron expression: minute, hour, day of month,
+// month and day of week (0 or 7 for Sunday). Fields are "*", values, ranges
+// and lists of them, each optionally with a step, e.g. "*/15" or "1-5".
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", expression, len(fields))
+	}
+
+	schedule := &cronSchedule{
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+	for i, field := range []struct {
+		name     string
+		bits     *uint64
+		min, max int
+	}{
+		{"minute", &schedule.minute, 0, 59},
+		{"hour", &schedule.hour, 0, 23},
+		{"day of month", &schedule.dayOfMonth, 1, 31},
+		{"month", &schedule.month, 1, 12},
+		{"day of week", &schedule.dayOfWeek, 0, 7},
+	} {
+		value, err := parseCronField(fields[i], field.min, field.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %s: %w", field.name, err)
+		}
+		*field.bits = value
+	}
+	// Sunday is both 0 and 7
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+
+	if schedule.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", expression)
+	}
+	return schedule, nil
+}
+
+// parseCronField returns the values a cron field matches within [min, max]
+func parseCronField(field string, min, max int) (uint64, error) {
+	var values uint64
+	for _, part := range strings.Split(field, ",") {
+		span, stepValue, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepValue); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepValue)
+			}
+		}
+
+		low, high := min, max
+		if span != "*" {
+			first, last, isRange := strings.Cut(span, "-")
+			var err error
+			if low, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value %q", first)
+			}
+			switch {
+			case isRange:
+				if high, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid value %q", last)
+				}
+			case !hasStep:
+				high = low
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			values |= 1 << value
+		}
+	}
+	return values, nil
+}
+
+// matchesDay reports whether the schedule runs on the day of t
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth&(1<<t.Day()) != 0
+	dayOfWeek := s.dayOfWeek&(1<<t.Weekday()) != 0
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// next returns the first time of the schedule after t, in UTC, or the zero
+// time if it does not match within cronSearchYears
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(end) {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<t.Minute()) == 0:
+			// Jump to the next matching minute of the hour, if any
+			if later := s.minute >> t.Minute(); later != 0 {
+				t = t.Add(time.Duration(bits.TrailingZeros64(later)) * time.Minute)
+			} else {
+				t = t.Truncate(time.Hour).Add(time.Hour)
+			}
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// scheduleSource captures the tracked pods of a config at the times of its
+// cron schedules
+type scheduleSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+
+	// schedules are the parsed cron expressions of the config's schedules,
+	// nil for those that fail to parse, and due when each runs next
+	schedules []*cronSchedule
+	due       []time.Time
+}
+
+// newScheduleSource creates the schedule source of a config, parsing its cron
+// expressions and computing when each runs first
+func newScheduleSource(r *ProfilingConfigReconciler, config *profilingv1alpha1.ProfilingConfig) *scheduleSource {
+	s := &scheduleSource{r: r, config: config}
+	now := time.Now()
+	for _, schedule := range config.Spec.Schedules {
+		parsed, _ := parseCron(schedule.Cron)
+		var due time.Time
+		if parsed != nil {
+			due = parsed.next(now)
+		}
+		s.schedules = append(s.schedules, parsed)
+		s.due = append(s.due, due)
+	}
+	return s
+}
+
+// Name implements TriggerSource
+func (s *scheduleSource) Name() string { return "schedule" }
+
+// Start implements TriggerSource
+func (s *scheduleSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource
+func (s *scheduleSource) InitialDelay() time.Duration {
+	return s.untilDue(time.Now())
+}
+
+// Check implements ScheduledSource, capturing the pods of the schedules due
+func (s *scheduleSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	now := time.Now()
+	for i, schedule := range s.schedules {
+		if schedule == nil || now.Before(s.due[i]) {
+			continue
+		}
+		s.r.captureScheduled(ctx, s.config, s.config.Spec.Schedules[i], emit)
+		s.due[i] = schedule.next(now)
+	}
+	return s.untilDue(now)
+}
+
+// untilDue returns the delay from now until the next schedule is due, an hour
+// if none is
+func (s *scheduleSource) untilDue(now time.Time) time.Duration {
+	var next time.Time
+	for _, due := range s.due {
+		if !due.IsZero() && (next.IsZero() || due.Before(next)) {
+			next = due
+		}
+	}
+	if next.IsZero() {
+		return time.Hour
+	}
+	return max(next.Sub(now), 0)
+}
+
+// captureScheduled emits a capture request for the tracked pods of a config
+// when one of its schedules is due, up to the schedule's maxPods by pod name.
+// Like on-demand captures they ignore cooldowns.
+func (r *ProfilingConfigReconciler) captureScheduled(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, schedule profilingv1alpha1.CaptureSchedule, emit func(CaptureRequest)) {
+	logger := log.FromContext(ctx)
+
+	var pods []*corev1.Pod
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		pods = append(pods, tracked.Pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return r.podWatcher.getPodKey(pods[i]) < r.podWatcher.getPodKey(pods[j])
+	})
+	if schedule.MaxPods > 0 && len(pods) > schedule.MaxPods {
+		pods = pods[:schedule.MaxPods]
+	}
+
+	reason := fmt.Sprintf("Scheduled capture (%s)", schedule.Cron)
+	for _, pod := range pods {
+		if r.nodeUnderPressure(ctx, config, pod, logger) {
+			r.suppress(config, metrics.SuppressedNodePressure)
+			continue
+		}
+
+		logger.Info("Scheduled profiling", "pod", pod.Name, "cron", schedule.Cron)
+		emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: reason}})
+	}
+}
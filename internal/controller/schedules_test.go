@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expression := range []string{"* * * * *", "*/15 * * * *", "0 3 * * 1-5", "0,30 8-18/2 1 1,7 0", "0 0 * * 7"} {
+		if _, err := parseCron(expression); err != nil {
+			t.Errorf("Expected %q to parse, got %v", expression, err)
+		}
+	}
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "0 0 30 2 *"} {
+		if _, err := parseCron(expression); err == nil {
+			t.Errorf("Expected %q to be rejected", expression)
+		}
+	}
+}
+
+func TestValidateConfig_Schedules(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Schedules = []profilingv1alpha1.CaptureSchedule{{Cron: "0 3 * * *"}}
+	reconciler := setupTestReconciler()
+
+	if err := reconciler.validateConfig(config); err != nil {
+		t.Errorf("Expected valid config, got error: %v", err)
+	}
+
+	config.Spec.Schedules = append(config.Spec.Schedules, profilingv1alpha1.CaptureSchedule{Cron: "0 3 * *"})
+	if err := reconciler.validateConfig(config); err == nil {
+		t.Error("Expected error for an invalid cron expression")
+	}
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, 1, 17, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 17, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 17, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 1, 18, 3, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 1, 18, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * *", time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 1 * 5", time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 6 *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.expression)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expression, err)
+		}
+		if got := schedule.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: expected next run at %v, got %v", tt.expression, tt.want, got)
+		}
+	}
+}
+
+func TestScheduleSource(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Schedules = []profilingv1alpha1.CaptureSchedule{{Cron: "* * * * *", MaxPods: 2}, {Cron: "0 0 1 1 *"}}
+	reconciler := setupTestReconciler(config)
+	for _, name := range []string{"pod-c", "pod-a", "pod-b"} {
+		reconciler.podWatcher.TrackPod(createTestPod(name, "default", true), config)
+	}
+
+	source := newScheduleSource(reconciler, config)
+	if delay := source.InitialDelay(); delay <= 0 || delay > time.Minute {
+		t.Errorf("Expected the first run within a minute, got %v", delay)
+	}
+
+	// Only the schedule due runs, on the first pods by name
+	source.due[0] = time.Now().Add(-time.Second)
+	var captured []string
+	delay := source.Check(context.Background(), func(request CaptureRequest) {
+		captured = append(captured, request.Pod.Name)
+		if request.StartCooldown || request.Trigger.Reason != "Scheduled capture (* * * * *)" {
+			t.Errorf("Unexpected capture request %+v", request)
+		}
+	})
+	if len(captured) != 2 || captured[0] != "pod-a" || captured[1] != "pod-b" {
+		t.Errorf("Expected pod-a and pod-b captured, got %v", captured)
+	}
+	if delay <= 0 || delay > time.Minute {
+		t.Errorf("Expected the next run within a minute, got %v", delay)
+	}
+	if !source.due[0].After(time.Now()) {
+		t.Errorf("Expected the schedule to be due again later, got %v", source.due[0])
+	}
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+const (
+	// OperatorProfilePrefix is the key prefix of the operator's own profiles
+	OperatorProfilePrefix = "_operator"
+
+	// selfProfilingReason is the capture reason of the operator's own captures
+	selfProfilingReason = "self-profiling"
+
+	// serviceAccountNamespaceFile holds the namespace of the operator's pod
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// DefaultSelfProfilingTypes are the profile types the operator captures of itself by default
+var DefaultSelfProfilingTypes = []string{"heap", "goroutine"}
+
+// SelfProfilerOptions configures the operator's captures of itself
+type SelfProfilerOptions struct {
+	// PprofAddress is the bind address of the operator's pprof server
+	PprofAddress string
+
+	// Interval is the time between captures
+	Interval time.Duration
+
+	// ProfileTypes are the profile types captured, DefaultSelfProfilingTypes if empty
+	ProfileTypes []string
+
+	// S3Config is where the profiles are uploaded. The prefix is always
+	// OperatorProfilePrefix so they never mix with the profiles of workloads.
+	S3Config uploader.S3Config
+
+	// Audit receives an audit record of every capture attempt. Records are
+	// dropped if nil.
+	Audit audit.Sink
+}
+
+// SelfProfiler periodically captures the operator's own profiles through its
+// pprof server and uploads them like those of any other pod
+type SelfProfiler struct {
+	profiler *profiler.Profiler
+	baseURL  string
+	opts     SelfProfilerOptions
+	pod      *corev1.Pod
+	logger   logr.Logger
+}
+
+// NewSelfProfiler creates a self-profiler for the operator's pod
+func NewSelfProfiler(opts SelfProfilerOptions) (*SelfProfiler, error) {
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("self-profiling interval must be positive")
+	}
+	if opts.S3Config.Bucket == "" {
+		return nil, fmt.Errorf("self-profiling requires an S3 bucket")
+	}
+
+	baseURL, err := pprofBaseURL(opts.PprofAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.ProfileTypes) == 0 {
+		opts.ProfileTypes = DefaultSelfProfilingTypes
+	}
+	if opts.Audit == nil {
+		opts.Audit = audit.Discard
+	}
+	opts.S3Config.Prefix = OperatorProfilePrefix
+
+	return &SelfProfiler{
+		profiler: profiler.NewProfiler(nil, nil),
+		baseURL:  baseURL,
+		opts:     opts,
+		pod:      operatorPod(),
+		logger:   ctrl.Log.WithName("self-profiler"),
+	}, nil
+}
+
+// Start captures profiles every interval until ctx is cancelled. It implements
+// manager.Runnable.
+func (s *SelfProfiler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.capture(ctx); err != nil {
+				s.logger.Error(err, "Failed to profile the operator")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection reports false so every replica profiles itself
+func (s *SelfProfiler) NeedLeaderElection() bool {
+	return false
+}
+
+// capture captures and uploads the operator's profiles once
+func (s *SelfProfiler) capture(ctx context.Context) error {
+	startedAt := time.Now()
+	trigger := metrics.Trigger{Reason: selfProfilingReason}
+
+	manifest, err := s.captureAndUpload(ctx, trigger)
+	s.audit(ctx, manifest, err, startedAt)
+	if err != nil {
+		return err
+	}
+
+	s.logger.V(1).Info("Profiled the operator", "manifest", manifest.Key)
+	return nil
+}
+
+// captureAndUpload captures the operator's profiles and uploads them to S3
+func (s *SelfProfiler) captureAndUpload(ctx context.Context, trigger metrics.Trigger) (*uploader.Manifest, error) {
+	profiles, err := s.profiler.CaptureProfilesFromURL(ctx, s.baseURL, s.opts.ProfileTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture profiles: %w", err)
+	}
+
+	uploadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), uploadTimeout)
+	defer cancel()
+
+	s3Uploader, err := uploader.NewS3Uploader(uploadCtx, s.opts.S3Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 uploader: %w", err)
+	}
+
+	manifest, err := s3Uploader.UploadProfiles(uploadCtx, s.pod, profiles, trigger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload profiles: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// audit stores the audit record of a capture attempt
+func (s *SelfProfiler) audit(ctx context.Context, manifest *uploader.Manifest, captureErr error, startedAt time.Time) {
+	record := audit.Record{
+		Time:        startedAt,
+		TriggeredBy: selfProfilingReason,
+		Reason:      selfProfilingReason,
+		Pod: audit.Pod{
+			Namespace: s.pod.Namespace,
+			Name:      s.pod.Name,
+			Node:      s.pod.Spec.NodeName,
+		},
+		ProfileTypes:    s.opts.ProfileTypes,
+		Bucket:          s.opts.S3Config.Bucket,
+		Outcome:         audit.OutcomeSucceeded,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+	}
+	if manifest != nil {
+		record.Manifest = manifest.Key
+		record.Summary = manifest.Summary
+		record.TopFunctions = manifest.TopFunctions
+		for _, object := range manifest.Objects {
+			record.Profiles = append(record.Profiles, audit.Profile{
+				Type:      object.Type,
+				Key:       object.Key,
+				SizeBytes: object.SizeBytes,
+			})
+		}
+	}
+	if captureErr != nil {
+		record.Outcome = audit.OutcomeFailed
+		record.Error = captureErr.Error()
+	}
+
+	auditCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditTimeout)
+	defer cancel()
+
+	if err := s.opts.Audit.Write(auditCtx, record); err != nil {
+		s.logger.Error(err, "Failed to write audit record")
+	}
+}
+
+// pprofBaseURL returns the URL the operator reaches its own pprof server at
+func pprofBaseURL(bindAddress string) (string, error) {
+	host, port, err := net.SplitHostPort(bindAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid pprof bind address %q: %w", bindAddress, err)
+	}
+	if port == "" || port == "0" {
+		return "", fmt.Errorf("pprof bind address %q must have a fixed port", bindAddress)
+	}
+
+	// Wildcard addresses are reached through the loopback interface
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	return "http://" + net.JoinHostPort(host, port), nil
+}
+
+// operatorPod describes the operator's own pod from the downward API
+// environment, falling back to the hostname and service account namespace
+func operatorPod() *corev1.Pod {
+	name := os.Getenv("POD_NAME")
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		if data, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name": "bolometer",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: os.Getenv("NODE_NAME"),
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestPprofBaseURL(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected string
+		wantErr  bool
+	}{
+		{address: ":6060", expected: "http://localhost:6060"},
+		{address: "0.0.0.0:6060", expected: "http://localhost:6060"},
+		{address: "127.0.0.1:6061", expected: "http://127.0.0.1:6061"},
+		{address: "[::]:6060", expected: "http://localhost:6060"},
+		{address: "", wantErr: true},
+		{address: ":0", wantErr: true},
+		{address: "6060", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			url, err := pprofBaseURL(tt.address)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %s", tt.address, url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if url != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, url)
+			}
+		})
+	}
+}
+
+func TestOperatorPod(t *testing.T) {
+	t.Setenv("POD_NAME", "bolometer-7d9f8b-x2k4p")
+	t.Setenv("POD_NAMESPACE", "bolometer-system")
+	t.Setenv("NODE_NAME", "node-1")
+
+	pod := operatorPod()
+	if pod.Name != "bolometer-7d9f8b-x2k4p" || pod.Namespace != "bolometer-system" || pod.Spec.NodeName != "node-1" {
+		t.Errorf("Unexpected operator pod: %s/%s on %s", pod.Namespace, pod.Name, pod.Spec.NodeName)
+	}
+	if pod.Labels["app.kubernetes.io/name"] != "bolometer" {
+		t.Errorf("Expected the operator's service label, got %v", pod.Labels)
+	}
+}
+
+func TestNewSelfProfiler(t *testing.T) {
+	valid := SelfProfilerOptions{
+		PprofAddress: ":6060",
+		Interval:     time.Hour,
+		S3Config:     uploader.S3Config{Bucket: "profiles", Prefix: "workloads"},
+	}
+
+	selfProfiler, err := NewSelfProfiler(valid)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if selfProfiler.opts.S3Config.Prefix != OperatorProfilePrefix {
+		t.Errorf("Expected prefix %s, got %s", OperatorProfilePrefix, selfProfiler.opts.S3Config.Prefix)
+	}
+	if len(selfProfiler.opts.ProfileTypes) != len(DefaultSelfProfilingTypes) {
+		t.Errorf("Expected default profile types, got %v", selfProfiler.opts.ProfileTypes)
+	}
+	if selfProfiler.NeedLeaderElection() {
+		t.Error("Expected every replica to profile itself")
+	}
+
+	noBucket := valid
+	noBucket.S3Config.Bucket = ""
+	if _, err := NewSelfProfiler(noBucket); err == nil {
+		t.Error("Expected error without a bucket")
+	}
+
+	noInterval := valid
+	noInterval.Interval = 0
+	if _, err := NewSelfProfiler(noInterval); err == nil {
+		t.Error("Expected error without an interval")
+	}
+}
+
+func TestSelfProfiler_CaptureFailureIsAudited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	selfProfiler, err := NewSelfProfiler(SelfProfilerOptions{
+		PprofAddress: strings.TrimPrefix(server.URL, "http://"),
+		Interval:     time.Hour,
+		S3Config:     uploader.S3Config{Bucket: "profiles"},
+		Audit:        audit.NewWriterSink(&buf),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := selfProfiler.capture(context.Background()); err == nil {
+		t.Fatal("Expected capture to fail when pprof is unavailable")
+	}
+
+	var record audit.Record
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode audit record: %v", err)
+	}
+	if record.Outcome != audit.OutcomeFailed || record.TriggeredBy != selfProfilingReason || record.Bucket != "profiles" {
+		t.Errorf("Unexpected audit record: %+v", record)
+	}
+}
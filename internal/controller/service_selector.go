@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+
+// servingPods returns the names of the pods a Service's EndpointSlices list as
+// ready. Endpoints without a ready condition count as ready, as the API defines.
+func servingPods(ctx context.Context, reader client.Reader, namespace, service string) (map[string]bool, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := reader.List(ctx, slices,
+		client.InNamespace(namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: service},
+	); err != nil {
+		return nil, err
+	}
+
+	pods := make(map[string]bool)
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" {
+				continue
+			}
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			pods[endpoint.TargetRef.Name] = true
+		}
+	}
+	return pods, nil
+}
+
+// configsForEndpointSlice maps an EndpointSlice event to the ProfilingConfigs
+// selecting the slice's Service, so pods entering or leaving rotation are
+// tracked without waiting for a requeue
+func (r *ProfilingConfigReconciler) configsForEndpointSlice(ctx context.Context, obj client.Object) []reconcile.Request {
+	service := obj.GetLabels()[discoveryv1.LabelServiceName]
+	if service == "" {
+		return nil
+	}
+
+	configs := &profilingv1alpha1.ProfilingConfigList{}
+	if err := r.List(ctx, configs); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list ProfilingConfigs for EndpointSlice", "endpointSlice", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range configs.Items {
+		config := &configs.Items[i]
+
+		// EndpointSlices of remote clusters are not watched, only listed on reconcile
+		if config.Spec.Cluster != nil || config.Spec.Selector.Service != service {
+			continue
+		}
+		if selectorNamespace(config) == obj.GetNamespace() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(config),
+			})
+		}
+	}
+
+	return requests
+}
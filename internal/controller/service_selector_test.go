@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newEndpointSlice creates an EndpointSlice of a service listing pods by
+// readiness, nil for an endpoint without a ready condition
+func newEndpointSlice(name, service string, pods map[string]*bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	for pod, ready := range pods {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: ready},
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "default"},
+		})
+	}
+	return slice
+}
+
+func TestPodWatcher_ListMatchingPods_Service(t *testing.T) {
+	ready, notReady := true, false
+	objs := []client.Object{
+		newEndpointSlice("api-1", "api", map[string]*bool{"serving-pod": &ready, "draining-pod": &notReady}),
+		newEndpointSlice("api-2", "api", map[string]*bool{"unknown-pod": nil}),
+		newEndpointSlice("web-1", "web", map[string]*bool{"web-pod": &ready}),
+	}
+	for _, name := range []string{"serving-pod", "draining-pod", "unknown-pod", "web-pod", "unlisted-pod"} {
+		objs = append(objs, createTestPod(name, "default", true))
+	}
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = discoveryv1.AddToScheme(scheme)
+	watcher := NewPodWatcher(fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build())
+
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Selector.Service = "api"
+	pods, err := watcher.ListMatchingPods(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ListMatchingPods returned unexpected error: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, pod := range pods {
+		names[pod.Name] = true
+	}
+	if len(names) != 2 || !names["serving-pod"] || !names["unknown-pod"] {
+		t.Errorf("Expected serving-pod and unknown-pod, got %v", names)
+	}
+}
+
+func TestConfigsForEndpointSlice(t *testing.T) {
+	selecting := createTestProfilingConfig("api-config", "default")
+	selecting.Spec.Selector.Service = "api"
+	otherService := createTestProfilingConfig("web-config", "default")
+	otherService.Spec.Selector.Service = "web"
+	otherNamespace := createTestProfilingConfig("other-config", "default")
+	otherNamespace.Spec.Selector.Namespace = "other"
+	otherNamespace.Spec.Selector.Service = "api"
+	labelsOnly := createTestProfilingConfig("labels-config", "default")
+	reconciler := setupTestReconciler(selecting, otherService, otherNamespace, labelsOnly)
+
+	requests := reconciler.configsForEndpointSlice(context.Background(), newEndpointSlice("api-1", "api", nil))
+	if len(requests) != 1 || requests[0].Name != "api-config" {
+		t.Errorf("Expected a request for api-config only, got %v", requests)
+	}
+}
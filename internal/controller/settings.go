@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+// Feature gates that can be switched off in BolometerSettings
+const (
+	// FeatureOnDemandProfiling runs the on-demand monitors of configs that enable them
+	FeatureOnDemandProfiling = "OnDemandProfiling"
+
+	// FeatureNodePressureChecks honours the nodePressurePolicy of configs
+	FeatureNodePressureChecks = "NodePressureChecks"
+
+	// FeatureAdaptiveCheckInterval honours the maxCheckIntervalSeconds of configs
+	FeatureAdaptiveCheckInterval = "AdaptiveCheckInterval"
+)
+
+// knownFeatureGates lists the feature gates understood by the operator
+var knownFeatureGates = []string{
+	FeatureOnDemandProfiling,
+	FeatureNodePressureChecks,
+	FeatureAdaptiveCheckInterval,
+}
+
+// Built-in defaults used when neither a config nor the settings set a value
+const (
+	// DefaultRequeueInterval is how often every config is reconciled
+	DefaultRequeueInterval = 30 * time.Second
+
+	defaultCPUThresholdPercent    = 80
+	defaultMemoryThresholdPercent = 90
+	defaultCheckIntervalSeconds   = 30
+	defaultCooldownSeconds        = 300
+)
+
+// Condition and reasons maintained in BolometerSettings status
+const (
+	// ConditionApplied reports whether the settings are in effect
+	ConditionApplied = "Applied"
+
+	reasonSettingsApplied    = "SettingsApplied"
+	reasonUnknownFeatureGate = "UnknownFeatureGate"
+)
+
+// loadSettings returns the spec of the default BolometerSettings, or an empty
+// spec if there is none
+func (r *ProfilingConfigReconciler) loadSettings(ctx context.Context) (*profilingv1alpha1.BolometerSettingsSpec, error) {
+	settings := &profilingv1alpha1.BolometerSettings{}
+	if err := r.Get(ctx, client.ObjectKey{Name: profilingv1alpha1.SettingsName}, settings); err != nil {
+		if errors.IsNotFound(err) {
+			return &profilingv1alpha1.BolometerSettingsSpec{}, nil
+		}
+		return nil, fmt.Errorf("failed to get BolometerSettings: %w", err)
+	}
+	return &settings.Spec, nil
+}
+
+// applySettings fills the fields a config leaves unset from the settings, then
+// from the built-in defaults, and turns off the features disabled by feature
+// gates. Only the in-memory copy is changed; the stored spec is never updated.
+func applySettings(config *profilingv1alpha1.ProfilingConfig, settings *profilingv1alpha1.BolometerSettingsSpec) {
+	spec := &config.Spec
+
+	if defaults := settings.DefaultS3Config; defaults != nil {
+		spec.S3Config.Bucket = withDefault(spec.S3Config.Bucket, defaults.Bucket)
+		spec.S3Config.Region = withDefault(spec.S3Config.Region, defaults.Region)
+		spec.S3Config.Prefix = withDefault(spec.S3Config.Prefix, defaults.Prefix)
+		spec.S3Config.Endpoint = withDefault(spec.S3Config.Endpoint, defaults.Endpoint)
+		spec.S3Config.PresignExpirySeconds = withDefault(spec.S3Config.PresignExpirySeconds, defaults.PresignExpirySeconds)
+		spec.S3Config.AccessCheck = withDefault(spec.S3Config.AccessCheck, defaults.AccessCheck)
+		spec.S3Config.CABundleRef = withDefault(spec.S3Config.CABundleRef, defaults.CABundleRef)
+		spec.S3Config.ProxyURL = withDefault(spec.S3Config.ProxyURL, defaults.ProxyURL)
+		spec.S3Config.Accelerate = withDefault(spec.S3Config.Accelerate, defaults.Accelerate)
+		spec.S3Config.DualStack = withDefault(spec.S3Config.DualStack, defaults.DualStack)
+		spec.S3Config.ForcePathStyle = withDefault(spec.S3Config.ForcePathStyle, defaults.ForcePathStyle)
+		spec.S3Config.RetryMode = withDefault(spec.S3Config.RetryMode, defaults.RetryMode)
+		spec.S3Config.MaxAttempts = withDefault(spec.S3Config.MaxAttempts, defaults.MaxAttempts)
+		spec.S3Config.Gallery = withDefault(spec.S3Config.Gallery, defaults.Gallery)
+		spec.S3Config.Lifecycle = withDefault(spec.S3Config.Lifecycle, defaults.Lifecycle)
+		spec.S3Config.WebIdentity = withDefault(spec.S3Config.WebIdentity, defaults.WebIdentity)
+		spec.S3Config.CircuitBreaker = withDefault(spec.S3Config.CircuitBreaker, defaults.CircuitBreaker)
+	}
+
+	thresholds := &spec.Thresholds
+	if defaults := settings.DefaultThresholds; defaults != nil {
+		thresholds.CPUThresholdPercent = withDefault(thresholds.CPUThresholdPercent, defaults.CPUThresholdPercent)
+		thresholds.MemoryThresholdPercent = withDefault(thresholds.MemoryThresholdPercent, defaults.MemoryThresholdPercent)
+		thresholds.CheckIntervalSeconds = withDefault(thresholds.CheckIntervalSeconds, defaults.CheckIntervalSeconds)
+		thresholds.CooldownSeconds = withDefault(thresholds.CooldownSeconds, defaults.CooldownSeconds)
+	}
+	thresholds.CPUThresholdPercent = withDefault(thresholds.CPUThresholdPercent, defaultCPUThresholdPercent)
+	thresholds.MemoryThresholdPercent = withDefault(thresholds.MemoryThresholdPercent, defaultMemoryThresholdPercent)
+	thresholds.CheckIntervalSeconds = withDefault(thresholds.CheckIntervalSeconds, defaultCheckIntervalSeconds)
+	thresholds.CooldownSeconds = withDefault(thresholds.CooldownSeconds, defaultCooldownSeconds)
+
+	if !featureEnabled(settings, FeatureOnDemandProfiling) {
+		spec.OnDemand = nil
+	}
+	if !featureEnabled(settings, FeatureNodePressureChecks) {
+		spec.NodePressurePolicy = profilingv1alpha1.NodePressureIgnore
+	}
+	if !featureEnabled(settings, FeatureAdaptiveCheckInterval) {
+		thresholds.MaxCheckIntervalSeconds = 0
+	}
+}
+
+// withDefault returns value, or def if value is the zero value
+func withDefault[T comparable](value, def T) T {
+	var zero T
+	if value == zero {
+		return def
+	}
+	return value
+}
+
+// featureEnabled reports whether a feature gate is enabled; gates are enabled
+// unless switched off
+func featureEnabled(settings *profilingv1alpha1.BolometerSettingsSpec, gate string) bool {
+	enabled, ok := settings.FeatureGates[gate]
+	return !ok || enabled
+}
+
+// unknownFeatureGates returns the sorted gates the operator does not understand
+func unknownFeatureGates(settings *profilingv1alpha1.BolometerSettingsSpec) []string {
+	var unknown []string
+	for gate := range settings.FeatureGates {
+		known := false
+		for _, k := range knownFeatureGates {
+			if gate == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, gate)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// requeueInterval returns how often every config is reconciled
+func requeueInterval(settings *profilingv1alpha1.BolometerSettingsSpec) time.Duration {
+	if settings.RequeueIntervalSeconds > 0 {
+		return time.Duration(settings.RequeueIntervalSeconds) * time.Second
+	}
+	return DefaultRequeueInterval
+}
+
+// configsForSettings maps a settings event to every ProfilingConfig, so changed
+// defaults and gates take effect without waiting for a requeue
+func (r *ProfilingConfigReconciler) configsForSettings(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != profilingv1alpha1.SettingsName {
+		return nil
+	}
+
+	configs := &profilingv1alpha1.ProfilingConfigList{}
+	if err := r.List(ctx, configs); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list ProfilingConfigs for settings")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(configs.Items))
+	for i := range configs.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&configs.Items[i]),
+		})
+	}
+	return requests
+}
+
+// settingsReconciler applies the rate limits of the default BolometerSettings
+// and reports them applied in its status
+type settingsReconciler struct {
+	client.Client
+
+	profiler     profiler.Interface
+	captureQueue *CaptureQueue
+
+	// Limits the operator was started with, used for limits the settings leave unset
+	maxPortForwards      int
+	maxCapturesPerMinute int
+}
+
+// +kubebuilder:rbac:groups=bolometer.io,resources=bolometersettings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=bolometer.io,resources=bolometersettings/status,verbs=get;update;patch
+
+// Reconcile applies the settings' rate limits, falling back to the operator's
+// flags when the settings are deleted
+func (s *settingsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	settings := &profilingv1alpha1.BolometerSettings{}
+	if err := s.Get(ctx, req.NamespacedName, settings); err != nil {
+		if errors.IsNotFound(err) {
+			s.applyRateLimits(nil)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	s.applyRateLimits(settings.Spec.RateLimits)
+	logger.Info("Applied BolometerSettings")
+
+	condition := metav1.Condition{
+		Type:               ConditionApplied,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonSettingsApplied,
+		Message:            "Settings are in effect",
+		ObservedGeneration: settings.Generation,
+	}
+	if unknown := unknownFeatureGates(&settings.Spec); len(unknown) > 0 {
+		condition.Reason = reasonUnknownFeatureGate
+		condition.Message = "Ignoring unknown feature gates: " + strings.Join(unknown, ", ")
+	}
+
+	changed := apimeta.SetStatusCondition(&settings.Status.Conditions, condition)
+	if !changed && settings.Status.ObservedGeneration == settings.Generation {
+		return ctrl.Result{}, nil
+	}
+
+	settings.Status.ObservedGeneration = settings.Generation
+	if err := s.Status().Update(ctx, settings); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// applyRateLimits sets the shared capture limits, keeping the flag values for
+// limits left unset
+func (s *settingsReconciler) applyRateLimits(limits *profilingv1alpha1.RateLimits) {
+	maxPortForwards := s.maxPortForwards
+	maxCapturesPerMinute := s.maxCapturesPerMinute
+	if limits != nil {
+		if limits.MaxPortForwards != nil {
+			maxPortForwards = *limits.MaxPortForwards
+		}
+		if limits.MaxCapturesPerMinute != nil {
+			maxCapturesPerMinute = *limits.MaxCapturesPerMinute
+		}
+	}
+
+	if limiter, ok := s.profiler.(profiler.PortForwardLimiter); ok {
+		limiter.SetMaxPortForwards(maxPortForwards)
+	}
+	s.captureQueue.SetRateLimit(maxCapturesPerMinute)
+}
+
+// SetupWithManager sets up the settings controller with the Manager
+func (s *settingsReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&profilingv1alpha1.BolometerSettings{}, builder.WithPredicates(isDefaultSettings())).
+		Complete(s)
+}
+
+// isDefaultSettings filters settings events down to the instance the operator reads
+func isDefaultSettings() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == profilingv1alpha1.SettingsName
+	})
+}
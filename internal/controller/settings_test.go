@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+func createTestSettings(spec profilingv1alpha1.BolometerSettingsSpec) *profilingv1alpha1.BolometerSettings {
+	return &profilingv1alpha1.BolometerSettings{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       profilingv1alpha1.SettingsName,
+			Generation: 1,
+		},
+		Spec: spec,
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config = profilingv1alpha1.S3Configuration{Prefix: "team-a"}
+	config.Spec.Thresholds = profilingv1alpha1.ThresholdConfig{CPUThresholdPercent: 70, MaxCheckIntervalSeconds: 300}
+	config.Spec.OnDemand = &profilingv1alpha1.OnDemandConfig{Enabled: true, IntervalSeconds: 35}
+	config.Spec.NodePressurePolicy = profilingv1alpha1.NodePressureSkip
+
+	applySettings(config, &profilingv1alpha1.BolometerSettingsSpec{
+		DefaultS3Config: &profilingv1alpha1.S3Configuration{
+			Bucket: "shared-bucket",
+			Region: "eu-west-1",
+			Prefix: "profiles",
+
+			PresignExpirySeconds: 3600,
+		},
+		DefaultThresholds: &profilingv1alpha1.ThresholdDefaults{
+			CPUThresholdPercent: 60,
+			CooldownSeconds:     600,
+		},
+		FeatureGates: map[string]bool{
+			FeatureOnDemandProfiling:     false,
+			FeatureAdaptiveCheckInterval: true,
+		},
+	})
+
+	s3Config := config.Spec.S3Config
+	if s3Config.Bucket != "shared-bucket" || s3Config.Region != "eu-west-1" || s3Config.Prefix != "team-a" || s3Config.PresignExpirySeconds != 3600 {
+		t.Errorf("Expected unset S3 fields to take the defaults, got %+v", s3Config)
+	}
+
+	thresholds := config.Spec.Thresholds
+	if thresholds.CPUThresholdPercent != 70 {
+		t.Errorf("Expected the config's CPU threshold to be kept, got %d", thresholds.CPUThresholdPercent)
+	}
+	if thresholds.CooldownSeconds != 600 {
+		t.Errorf("Expected cooldown from the settings, got %d", thresholds.CooldownSeconds)
+	}
+	if thresholds.MemoryThresholdPercent != defaultMemoryThresholdPercent || thresholds.CheckIntervalSeconds != defaultCheckIntervalSeconds {
+		t.Errorf("Expected built-in defaults for thresholds unset everywhere, got %+v", thresholds)
+	}
+	if thresholds.MaxCheckIntervalSeconds != 300 {
+		t.Errorf("Expected the enabled adaptive interval to be kept, got %d", thresholds.MaxCheckIntervalSeconds)
+	}
+
+	if config.Spec.OnDemand != nil {
+		t.Error("Expected on-demand profiling to be disabled by its feature gate")
+	}
+	if config.Spec.NodePressurePolicy != profilingv1alpha1.NodePressureSkip {
+		t.Errorf("Expected node pressure policy to be kept, got %s", config.Spec.NodePressurePolicy)
+	}
+}
+
+func TestUnknownFeatureGates(t *testing.T) {
+	unknown := unknownFeatureGates(&profilingv1alpha1.BolometerSettingsSpec{
+		FeatureGates: map[string]bool{
+			"Zeta":                    true,
+			FeatureNodePressureChecks: false,
+			"Alpha":                   false,
+		},
+	})
+
+	if len(unknown) != 2 || unknown[0] != "Alpha" || unknown[1] != "Zeta" {
+		t.Errorf("Expected [Alpha Zeta], got %v", unknown)
+	}
+}
+
+func TestReconcile_SettingsDefaults(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config = profilingv1alpha1.S3Configuration{}
+	settings := createTestSettings(profilingv1alpha1.BolometerSettingsSpec{
+		DefaultS3Config:        &profilingv1alpha1.S3Configuration{Bucket: "shared-bucket", Region: "us-east-1"},
+		RequeueIntervalSeconds: 120,
+	})
+	reconciler := setupTestReconciler(config, settings)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace}}
+	result, err := reconciler.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	if result.RequeueAfter != 2*time.Minute {
+		t.Errorf("Expected requeue after the settings interval, got %v", result.RequeueAfter)
+	}
+	if !reconciler.monitors.IsRunning(req.NamespacedName.String()) {
+		t.Error("Expected a config relying on the default bucket to be monitored")
+	}
+
+	// The defaults are never written back to the config
+	stored := &profilingv1alpha1.ProfilingConfig{}
+	if err := reconciler.Get(context.Background(), req.NamespacedName, stored); err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	if stored.Spec.S3Config.Bucket != "" {
+		t.Errorf("Expected the stored spec to be unchanged, got bucket %q", stored.Spec.S3Config.Bucket)
+	}
+}
+
+func TestReconcile_SettingsChangeRestartsMonitoring(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Thresholds.CooldownSeconds = 0
+	reconciler := setupTestReconciler(config)
+	ctx := context.Background()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace}}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	before, _ := reconciler.monitors.Hash(req.NamespacedName.String())
+
+	settings := createTestSettings(profilingv1alpha1.BolometerSettingsSpec{
+		DefaultThresholds: &profilingv1alpha1.ThresholdDefaults{CooldownSeconds: 900},
+	})
+	if err := reconciler.Create(ctx, settings); err != nil {
+		t.Fatalf("Failed to create settings: %v", err)
+	}
+
+	if requests := reconciler.configsForSettings(ctx, settings); len(requests) != 1 || requests[0] != req {
+		t.Fatalf("Expected the config to be requeued for the settings change, got %v", requests)
+	}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	after, _ := reconciler.monitors.Hash(req.NamespacedName.String())
+	if before == after {
+		t.Error("Expected monitoring to restart when a default it relies on changes")
+	}
+}
+
+func TestSettingsReconciler(t *testing.T) {
+	portForwards := 5
+	settings := createTestSettings(profilingv1alpha1.BolometerSettingsSpec{
+		RateLimits:   &profilingv1alpha1.RateLimits{MaxPortForwards: &portForwards, MaxCapturesPerMinute: new(int)},
+		FeatureGates: map[string]bool{"Unknown": true},
+	})
+	reconciler := setupTestReconciler(settings)
+	settingsReconciler := &settingsReconciler{
+		Client:               reconciler.Client,
+		profiler:             profiler.NewProfiler(nil, nil),
+		captureQueue:         reconciler.captureQueue,
+		maxPortForwards:      10,
+		maxCapturesPerMinute: 30,
+	}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: profilingv1alpha1.SettingsName}}
+
+	if _, err := settingsReconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+
+	// A limit of 0 disables rate limiting
+	if settingsReconciler.captureQueue.limiter != nil {
+		t.Error("Expected the settings to disable the capture rate limit")
+	}
+
+	updated := &profilingv1alpha1.BolometerSettings{}
+	if err := reconciler.Get(ctx, req.NamespacedName, updated); err != nil {
+		t.Fatalf("Failed to get settings: %v", err)
+	}
+	condition := apimeta.FindStatusCondition(updated.Status.Conditions, ConditionApplied)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonUnknownFeatureGate {
+		t.Errorf("Expected Applied condition reporting the unknown gate, got %+v", condition)
+	}
+	if updated.Status.ObservedGeneration != updated.Generation {
+		t.Errorf("Expected observedGeneration %d, got %d", updated.Generation, updated.Status.ObservedGeneration)
+	}
+
+	// Deleting the settings restores the flag values
+	if err := reconciler.Delete(ctx, updated); err != nil {
+		t.Fatalf("Failed to delete settings: %v", err)
+	}
+	if _, err := settingsReconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile returned unexpected error: %v", err)
+	}
+	if limiter := settingsReconciler.captureQueue.limiter; limiter == nil || limiter.Burst() != 30 {
+		t.Errorf("Expected the flag rate limit to be restored, got %v", limiter)
+	}
+}
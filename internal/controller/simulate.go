@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// SimulatedCapture is a capture a simulated config would have triggered
+type SimulatedCapture struct {
+	Time    time.Time
+	Pod     string
+	Reason  string
+	Metrics *metrics.PodMetrics
+}
+
+// SimulationCheck is the outcome of one threshold check of a simulation
+type SimulationCheck struct {
+	// Pods is the number of pods checked
+	Pods int
+
+	// Captures are the captures the check would have triggered
+	Captures []SimulatedCapture
+
+	// InCooldown counts the pods over their thresholds held back by the cooldown
+	InCooldown int
+
+	// Deferred counts the pods over their thresholds left for a later check by
+	// maxCapturesPerCheck
+	Deferred int
+
+	// Utilization is the highest threshold utilization observed, which drives
+	// the adaptive check interval
+	Utilization float64
+}
+
+// Simulation replays the threshold checks of a config against live metrics
+// without capturing anything, to tune thresholds before deploying the config.
+// Cooldowns start at every simulated capture, as if the capture succeeded.
+type Simulation struct {
+	config      *profilingv1alpha1.ProfilingConfig
+	reader      client.Reader
+	collector   *metrics.Collector
+	podWatcher  *PodWatcher
+	history     *metrics.History
+	lastCapture map[string]time.Time
+
+	// expression is the config's triggerExpression, compiled on the first check
+	expression *triggerExpression
+}
+
+// NewSimulation creates a simulation of a config, with the fields it leaves
+// unset already filled as by ValidateConfig. Pods are listed through reader
+// and their usage read through collector.
+func NewSimulation(config *profilingv1alpha1.ProfilingConfig, reader client.Reader, collector *metrics.Collector) *Simulation {
+	return &Simulation{
+		config:      config,
+		reader:      reader,
+		collector:   collector,
+		podWatcher:  NewPodWatcher(reader),
+		history:     metrics.NewHistory(metrics.DefaultHistorySize),
+		lastCapture: make(map[string]time.Time),
+	}
+}
+
+// Run checks the thresholds of the config at its check interval until duration
+// has passed or ctx is done, calling report after each check
+func (s *Simulation) Run(ctx context.Context, duration time.Duration, report func(time.Time, SimulationCheck, error)) {
+	thresholds := s.config.Spec.Thresholds
+	baseInterval := time.Duration(thresholds.CheckIntervalSeconds) * time.Second
+	maxInterval := time.Duration(thresholds.MaxCheckIntervalSeconds) * time.Second
+
+	deadline := time.Now().Add(duration)
+	checkInterval := baseInterval
+	for {
+		now := time.Now()
+		check, err := s.Check(ctx, now)
+		report(now, check, err)
+		checkInterval = nextCheckInterval(checkInterval, baseInterval, maxInterval, check.Utilization)
+
+		if now.Add(checkInterval).After(deadline) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(checkInterval):
+		}
+	}
+}
+
+// Check runs one threshold check of the pods of the config at now, the way
+// the operator's monitor does
+func (s *Simulation) Check(ctx context.Context, now time.Time) (SimulationCheck, error) {
+	var check SimulationCheck
+	config := s.config
+
+	pods, err := s.podWatcher.ListMatchingPods(ctx, config)
+	if err != nil {
+		return check, fmt.Errorf("failed to list pods: %w", err)
+	}
+	check.Pods = len(pods)
+
+	podsByNamespace := make(map[string][]*corev1.Pod)
+	for _, pod := range pods {
+		podsByNamespace[pod.Namespace] = append(podsByNamespace[pod.Namespace], pod)
+	}
+
+	if config.Spec.TriggerExpression != "" && s.expression == nil {
+		if s.expression, err = compileTriggerExpression(config.Spec.TriggerExpression); err != nil {
+			return check, fmt.Errorf("invalid trigger expression: %w", err)
+		}
+	}
+	expression := s.expression
+
+	window := time.Duration(config.Spec.Thresholds.AveragingWindowSeconds) * time.Second
+	var candidates []thresholdCandidate
+	for namespace, pods := range podsByNamespace {
+		podMetrics, err := s.collector.ListPodMetrics(ctx, resolveMetricsSource(s.collector, config), namespace, pods)
+		if err != nil {
+			return check, err
+		}
+
+		for _, pod := range pods {
+			usage, ok := podMetrics[pod.Name]
+			if !ok {
+				continue
+			}
+
+			podKey := s.podWatcher.getPodKey(pod)
+			s.history.Record(podKey, usage)
+			if window > 0 {
+				usage = s.history.Average(podKey, window)
+			}
+			thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+			podUtilization := thresholdUtilization(usage, thresholds)
+			check.Utilization = max(check.Utilization, podUtilization)
+
+			var exceeded bool
+			var reason string
+			if expression != nil {
+				// Expressions reading goroutines fail, as they are not counted
+				exceeded, reason, _ = expression.evaluate(pod, usage, nil)
+			} else {
+				exceeded, reason = evaluateThresholds(usage, thresholds, nil)
+			}
+			if !exceeded {
+				continue
+			}
+			cooldown := time.Duration(thresholds.CooldownSeconds) * time.Second
+			if last, ok := s.lastCapture[podKey]; ok && now.Sub(last) <= cooldown {
+				check.InCooldown++
+				continue
+			}
+			candidates = append(candidates, thresholdCandidate{
+				pod:         pod,
+				trigger:     metrics.Trigger{Reason: reason, Metrics: usage},
+				utilization: podUtilization,
+			})
+		}
+	}
+
+	selected := limitCandidates(candidates, captureBudget(config))
+	check.Deferred = len(candidates) - len(selected)
+	for _, candidate := range selected {
+		podKey := s.podWatcher.getPodKey(candidate.pod)
+		s.lastCapture[podKey] = now
+		check.Captures = append(check.Captures, SimulatedCapture{
+			Time:    now,
+			Pod:     podKey,
+			Reason:  candidate.trigger.Reason,
+			Metrics: candidate.trigger.Metrics,
+		})
+	}
+	return check, nil
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// staticSource reports the same CPU usage for every pod of test-container
+type staticSource struct {
+	cpu map[string]string
+}
+
+func (s *staticSource) ListPodUsage(_ context.Context, _ string, pods []*corev1.Pod) (map[string]*metrics.PodUsage, error) {
+	usages := make(map[string]*metrics.PodUsage)
+	for _, pod := range pods {
+		if cpu, ok := s.cpu[pod.Name]; ok {
+			usages[pod.Name] = &metrics.PodUsage{Containers: []metrics.ContainerUsage{
+				{Name: "test-container", CPU: resource.MustParse(cpu), Memory: resource.MustParse("64Mi")},
+			}}
+		}
+	}
+	return usages, nil
+}
+
+func TestSimulation_Check(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.MaxCapturesPerCheck = 1
+	var pods []*corev1.Pod
+	for _, name := range []string{"busy-pod", "hot-pod", "idle-pod"} {
+		pod := createTestPod(name, "default", true)
+		pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		}
+		pods = append(pods, pod)
+	}
+	reconciler := setupTestReconciler(config, pods[0], pods[1], pods[2])
+
+	source := &staticSource{cpu: map[string]string{"busy-pod": "85m", "hot-pod": "95m", "idle-pod": "10m"}}
+	collector := metrics.NewCollector(&fakeMetricsClientset{})
+	collector.SetCacheTTL(0)
+	collector.RegisterSource(metrics.SourceMetricsServer, source)
+	simulation := NewSimulation(config, reconciler.Client, collector)
+	ctx := context.Background()
+	start := time.Now()
+
+	check, err := simulation.Check(ctx, start)
+	if err != nil {
+		t.Fatalf("Check returned unexpected error: %v", err)
+	}
+	if check.Pods != 3 || check.Deferred != 1 || len(check.Captures) != 1 {
+		t.Fatalf("Expected 1 capture of 3 pods and 1 deferred, got %+v", check)
+	}
+	if capture := check.Captures[0]; capture.Pod != "default/hot-pod" || capture.Reason == "" {
+		t.Errorf("Expected the pod furthest over its threshold to be captured, got %+v", capture)
+	}
+
+	// The captured pod is in cooldown, the deferred one is captured next
+	check, err = simulation.Check(ctx, start.Add(30*time.Second))
+	if err != nil {
+		t.Fatalf("Check returned unexpected error: %v", err)
+	}
+	if check.InCooldown != 1 || len(check.Captures) != 1 || check.Captures[0].Pod != "default/busy-pod" {
+		t.Errorf("Expected busy-pod to be captured and hot-pod in cooldown, got %+v", check)
+	}
+
+	// After the cooldown the pod is captured again
+	check, err = simulation.Check(ctx, start.Add(301*time.Second))
+	if err != nil {
+		t.Fatalf("Check returned unexpected error: %v", err)
+	}
+	if len(check.Captures) != 1 || check.Captures[0].Pod != "default/hot-pod" {
+		t.Errorf("Expected hot-pod to be captured after its cooldown, got %+v", check)
+	}
+}
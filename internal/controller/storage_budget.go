@@ -0,0 +1,311 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+const (
+	// storageMeasureInterval is how often the objects under the prefix of a
+	// config capping its stored bytes are measured
+	storageMeasureInterval = time.Hour
+
+	// storageMeasureTimeout bounds a measurement, which lists every object
+	// under the prefix
+	storageMeasureTimeout = 5 * time.Minute
+
+	// budgetDayLayout is the layout of the day daily uploads are counted for
+	budgetDayLayout = "2006-01-02"
+)
+
+// storageBudgets tracks the storage usage of each config with a storage
+// budget. The zero value is ready to use.
+type storageBudgets struct {
+	mu sync.Mutex
+
+	// usage holds the usage of each config, by config
+	usage map[string]*budgetUsage
+}
+
+// budgetUsage is the storage usage of a config
+type budgetUsage struct {
+	profilingv1alpha1.StorageUsage
+
+	// measuredAt is when a measurement of the prefix was last started
+	measuredAt time.Time
+
+	// announced is set once the exceeded budget was reported, until the usage
+	// is back under it
+	announced bool
+}
+
+// restore starts tracking a config from the usage recorded in its status,
+// keeping the daily uploads of the operator's previous run
+func (b *storageBudgets) restore(configKey string, status *profilingv1alpha1.StorageUsage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.usage[configKey]; ok {
+		return
+	}
+	if b.usage == nil {
+		b.usage = make(map[string]*budgetUsage)
+	}
+	usage := &budgetUsage{}
+	if status != nil {
+		status.DeepCopyInto(&usage.StorageUsage)
+	}
+	b.usage[configKey] = usage
+}
+
+// measureDue reports whether the prefix of a config should be measured at
+// now, recording the measurement if so
+func (b *storageBudgets) measureDue(configKey string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage, ok := b.usage[configKey]
+	if !ok || now.Sub(usage.measuredAt) < storageMeasureInterval {
+		return false
+	}
+	usage.measuredAt = now
+	return true
+}
+
+// measured records the bytes stored under the prefix of a config at now
+func (b *storageBudgets) measured(configKey string, stored int64, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if usage, ok := b.usage[configKey]; ok {
+		usage.StoredBytes = stored
+		measureTime := metav1.NewTime(now)
+		usage.MeasureTime = &measureTime
+	}
+}
+
+// recordUpload counts the bytes a config uploaded at now
+func (b *storageBudgets) recordUpload(configKey string, size int64, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if usage, ok := b.usage[configKey]; ok {
+		usage.rollDay(now)
+		usage.StoredBytes += size
+		usage.DailyUploadBytes += size
+	}
+}
+
+// exceeded returns how a config exceeds its budget at now, empty if it does
+// not
+func (b *storageBudgets) exceeded(configKey string, budget *profilingv1alpha1.StorageBudget, now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage, ok := b.usage[configKey]
+	if !ok {
+		return ""
+	}
+	usage.rollDay(now)
+
+	switch {
+	case budget.MaxStorageBytes > 0 && usage.StoredBytes >= budget.MaxStorageBytes:
+		return fmt.Sprintf("%d bytes stored, the limit is %d", usage.StoredBytes, budget.MaxStorageBytes)
+	case budget.MaxDailyUploadBytes > 0 && usage.DailyUploadBytes >= budget.MaxDailyUploadBytes:
+		return fmt.Sprintf("%d bytes uploaded today, the limit is %d", usage.DailyUploadBytes, budget.MaxDailyUploadBytes)
+	}
+	usage.announced = false
+	return ""
+}
+
+// announce reports whether the exceeded budget of a config is yet to be
+// reported, marking it reported
+func (b *storageBudgets) announce(configKey string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage, ok := b.usage[configKey]
+	if !ok || usage.announced {
+		return false
+	}
+	usage.announced = true
+	return true
+}
+
+// snapshot returns the usage of a config at now, nil if it is not tracked
+func (b *storageBudgets) snapshot(configKey string, now time.Time) *profilingv1alpha1.StorageUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage, ok := b.usage[configKey]
+	if !ok {
+		return nil
+	}
+	usage.rollDay(now)
+	return usage.StorageUsage.DeepCopy()
+}
+
+// forget stops tracking a config
+func (b *storageBudgets) forget(configKey string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.usage, configKey)
+}
+
+// rollDay starts counting the uploads of the day of now
+func (u *budgetUsage) rollDay(now time.Time) {
+	if day := now.UTC().Format(budgetDayLayout); u.Day != day {
+		u.Day = day
+		u.DailyUploadBytes = 0
+	}
+}
+
+// validateStorageBudget validates the storage budget of a config
+func validateStorageBudget(config *profilingv1alpha1.ProfilingConfig) error {
+	budget := config.Spec.StorageBudget
+	if budget == nil {
+		return nil
+	}
+	if budget.MaxStorageBytes <= 0 && budget.MaxDailyUploadBytes <= 0 {
+		return fmt.Errorf("storageBudget needs maxStorageBytes or maxDailyUploadBytes")
+	}
+	if budget.MaxStorageBytes > 0 {
+		if _, err := staticPrefixOf(config); err != nil {
+			return fmt.Errorf("storageBudget maxStorageBytes: %w", err)
+		}
+	}
+	return nil
+}
+
+// trackStorageBudget tracks the storage usage of a config against its budget,
+// measuring its prefix in the background when due, and reports it in the
+// status
+func (r *ProfilingConfigReconciler) trackStorageBudget(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+	configKey := configKeyOf(config)
+	budget := config.Spec.StorageBudget
+	if budget == nil {
+		r.storageBudgets.forget(configKey)
+		config.Status.StorageUsage = nil
+		apimeta.RemoveStatusCondition(&config.Status.Conditions, ConditionStorageBudgetExceeded)
+		return
+	}
+
+	r.storageBudgets.restore(configKey, config.Status.StorageUsage)
+	if budget.MaxStorageBytes > 0 && r.storageBudgets.measureDue(configKey, time.Now()) {
+		measureCtx := r.monitorContext(ctx)
+		go func() {
+			ctx, cancel := context.WithTimeout(measureCtx, storageMeasureTimeout)
+			defer cancel()
+			if err := r.measureStorage(ctx, config); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to measure stored profiles", "bucket", config.Spec.S3Config.Bucket)
+			}
+		}()
+	}
+	r.setStorageBudgetStatus(config)
+}
+
+// measureStorage measures the objects under the prefix of a config, if its
+// uploader can
+func (r *ProfilingConfigReconciler) measureStorage(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) error {
+	prefix, err := staticPrefixOf(config)
+	if err != nil {
+		return err
+	}
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return err
+	}
+	storage, err := r.uploaders(ctx, s3Config)
+	if err != nil {
+		return fmt.Errorf("failed to create uploader: %w", err)
+	}
+	meter, ok := storage.(uploader.StorageMeter)
+	if !ok {
+		return nil
+	}
+
+	stored, err := meter.MeasurePrefix(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	r.storageBudgets.measured(configKeyOf(config), stored, time.Now())
+	return nil
+}
+
+// setStorageBudgetStatus reports the storage usage of a config with a budget
+// and whether it exceeds the budget
+func (r *ProfilingConfigReconciler) setStorageBudgetStatus(config *profilingv1alpha1.ProfilingConfig) {
+	budget := config.Spec.StorageBudget
+	if budget == nil {
+		return
+	}
+
+	configKey := configKeyOf(config)
+	now := time.Now()
+	if usage := r.storageBudgets.snapshot(configKey, now); usage != nil {
+		config.Status.StorageUsage = usage
+	}
+	if exceeded := r.storageBudgets.exceeded(configKey, budget, now); exceeded != "" {
+		setCondition(config, ConditionStorageBudgetExceeded, metav1.ConditionTrue, reasonBudgetExceeded,
+			fmt.Sprintf("Storage budget exceeded, %s", exceeded))
+		return
+	}
+	setCondition(config, ConditionStorageBudgetExceeded, metav1.ConditionFalse, reasonWithinBudget,
+		"Storage usage is within the budget")
+}
+
+// budgetedProfileTypes returns the profile types a capture of a pod takes
+// under the storage budget of its config, false if the capture is skipped.
+// The first capture held back once the budget is exceeded is reported with
+// an event, and with an audit record and a notification of the skipped
+// profile types.
+func (r *ProfilingConfigReconciler) budgetedProfileTypes(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, pod *corev1.Pod, trigger metrics.Trigger, profileTypes []string) ([]string, bool) {
+	budget := config.Spec.StorageBudget
+	if budget == nil {
+		return profileTypes, true
+	}
+	configKey := configKeyOf(config)
+	exceeded := r.storageBudgets.exceeded(configKey, budget, time.Now())
+	if exceeded == "" {
+		return profileTypes, true
+	}
+
+	// HeapOnly never adds heap to a capture that would not take it
+	var kept []string
+	skipped := profileTypes
+	if budget.Action == profilingv1alpha1.StorageBudgetHeapOnly && slices.Contains(profileTypes, "heap") {
+		kept = []string{"heap"}
+		skipped = slices.DeleteFunc(slices.Clone(profileTypes), func(profileType string) bool {
+			return profileType == "heap"
+		})
+	}
+
+	if r.storageBudgets.announce(configKey) {
+		message := "captures are skipped"
+		if len(kept) > 0 {
+			message = "only heap profiles are captured"
+		}
+		r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonBudgetExceeded,
+			"Storage budget exceeded, %s: %s", message, exceeded)
+
+		record := newAuditRecord(config, pod, skipped, trigger, nil, nil,
+			fmt.Errorf("storage budget exceeded: %s", exceeded), time.Now())
+		record.Outcome = audit.OutcomeSkipped
+		r.auditCapture(ctx, record)
+		r.notifyCapture(ctx, config, record)
+	}
+	return kept, len(kept) > 0
+}
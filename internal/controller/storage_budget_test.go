@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestStorageBudgets_Exceeded(t *testing.T) {
+	var budgets storageBudgets
+	budget := &profilingv1alpha1.StorageBudget{MaxDailyUploadBytes: 1000}
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// Uploads of configs without a budget are not counted
+	budgets.recordUpload("default/other", 5000, day)
+	if budgets.snapshot("default/other", day) != nil {
+		t.Error("Expected configs without a budget to be untracked")
+	}
+
+	budgets.restore("default/api", &profilingv1alpha1.StorageUsage{Day: "2026-03-10", DailyUploadBytes: 600})
+	if exceeded := budgets.exceeded("default/api", budget, day); exceeded != "" {
+		t.Errorf("Expected the budget to hold, got %q", exceeded)
+	}
+	budgets.recordUpload("default/api", 500, day)
+	if exceeded := budgets.exceeded("default/api", budget, day); !strings.Contains(exceeded, "1100 bytes uploaded today") {
+		t.Errorf("Expected the daily budget to be exceeded, got %q", exceeded)
+	}
+	if !budgets.announce("default/api") || budgets.announce("default/api") {
+		t.Error("Expected the exceeded budget to be announced once")
+	}
+
+	// A new day starts over
+	next := day.Add(24 * time.Hour)
+	if exceeded := budgets.exceeded("default/api", budget, next); exceeded != "" {
+		t.Errorf("Expected the budget to hold on the next day, got %q", exceeded)
+	}
+	if usage := budgets.snapshot("default/api", next); usage.Day != "2026-03-11" || usage.DailyUploadBytes != 0 || usage.StoredBytes != 500 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+	if !budgets.announce("default/api") {
+		t.Error("Expected the budget to be announced again once back under it")
+	}
+
+	// Measurements replace the stored bytes
+	budget.MaxStorageBytes = 2000
+	budgets.measured("default/api", 2500, next)
+	if exceeded := budgets.exceeded("default/api", budget, next); !strings.Contains(exceeded, "2500 bytes stored") {
+		t.Errorf("Expected the storage budget to be exceeded, got %q", exceeded)
+	}
+}
+
+func TestStorageBudgets_MeasureDue(t *testing.T) {
+	var budgets storageBudgets
+	now := time.Now()
+
+	if budgets.measureDue("default/api", now) {
+		t.Error("Expected no measurement of an untracked config")
+	}
+	budgets.restore("default/api", nil)
+	if !budgets.measureDue("default/api", now) {
+		t.Error("Expected the first measurement to be due")
+	}
+	if budgets.measureDue("default/api", now.Add(time.Minute)) {
+		t.Error("Expected no measurement within the interval")
+	}
+	if !budgets.measureDue("default/api", now.Add(storageMeasureInterval)) {
+		t.Error("Expected a measurement once the interval passed")
+	}
+}
+
+func TestBudgetedProfileTypes(t *testing.T) {
+	config := createTestProfilingConfig("api", "default")
+	config.Spec.StorageBudget = &profilingv1alpha1.StorageBudget{MaxDailyUploadBytes: 100}
+	reconciler := setupTestReconciler(config)
+	var records bytes.Buffer
+	reconciler.audit = audit.NewWriterSink(&records)
+	pod := createTestPod("api-0", "default", true)
+	trigger := metrics.Trigger{Reason: "cpu"}
+	profileTypes := []string{"heap", "cpu"}
+	ctx := context.Background()
+
+	reconciler.storageBudgets.restore(configKeyOf(config), nil)
+	if got, ok := reconciler.budgetedProfileTypes(ctx, config, pod, trigger, profileTypes); !ok || len(got) != 2 {
+		t.Fatalf("Expected every profile type within the budget, got %v", got)
+	}
+
+	reconciler.storageBudgets.recordUpload(configKeyOf(config), 150, time.Now())
+	if got, ok := reconciler.budgetedProfileTypes(ctx, config, pod, trigger, profileTypes); ok || len(got) != 0 {
+		t.Errorf("Expected the capture to be skipped, got %v", got)
+	}
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	if len(recorder.Events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(recorder.Events))
+	}
+	var skipped audit.Record
+	if err := json.Unmarshal(records.Bytes(), &skipped); err != nil {
+		t.Fatalf("Expected an audit record, got %q: %v", records.String(), err)
+	}
+	if skipped.Outcome != audit.OutcomeSkipped || len(skipped.ProfileTypes) != 2 {
+		t.Errorf("Unexpected audit record %+v", skipped)
+	}
+
+	// Further captures are held back without another report
+	config.Spec.StorageBudget.Action = profilingv1alpha1.StorageBudgetHeapOnly
+	if got, ok := reconciler.budgetedProfileTypes(ctx, config, pod, trigger, profileTypes); !ok || len(got) != 1 || got[0] != "heap" {
+		t.Errorf("Expected a heap-only capture, got %v", got)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("Expected no further event, got %d", len(recorder.Events))
+	}
+
+	// Captures without heap are skipped rather than switched to heap
+	if got, ok := reconciler.budgetedProfileTypes(ctx, config, pod, trigger, []string{"cpu", "goroutine"}); ok || len(got) != 0 {
+		t.Errorf("Expected the capture without heap to be skipped, got %v", got)
+	}
+}
+
+func TestSetStorageBudgetStatus(t *testing.T) {
+	config := createTestProfilingConfig("api", "default")
+	config.Spec.StorageBudget = &profilingv1alpha1.StorageBudget{MaxStorageBytes: 1000}
+	reconciler := setupTestReconciler(config)
+	reconciler.uploaders = func(context.Context, uploader.S3Config) (uploader.Uploader, error) {
+		return &uploader.Fake{Bucket: "test-bucket", Stored: 4000}, nil
+	}
+
+	reconciler.storageBudgets.restore(configKeyOf(config), nil)
+	reconciler.setStorageBudgetStatus(config)
+	condition := apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageBudgetExceeded)
+	if condition == nil || condition.Status != metav1.ConditionFalse {
+		t.Fatalf("Expected the budget to hold, got %+v", condition)
+	}
+
+	if err := reconciler.measureStorage(context.Background(), config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reconciler.setStorageBudgetStatus(config)
+	condition = apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageBudgetExceeded)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonBudgetExceeded {
+		t.Fatalf("Expected the budget to be exceeded, got %+v", condition)
+	}
+	if usage := config.Status.StorageUsage; usage == nil || usage.StoredBytes != 4000 || usage.MeasureTime == nil {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+
+	// Dropping the budget drops the usage and condition
+	config.Spec.StorageBudget = nil
+	reconciler.trackStorageBudget(context.Background(), config)
+	if config.Status.StorageUsage != nil || apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageBudgetExceeded) != nil {
+		t.Errorf("Expected no usage nor condition, got %+v", config.Status)
+	}
+}
+
+func TestValidateConfig_StorageBudget(t *testing.T) {
+	reconciler := setupTestReconciler()
+
+	tests := []struct {
+		name    string
+		prefix  string
+		budget  *profilingv1alpha1.StorageBudget
+		wantErr bool
+	}{
+		{name: "storage cap", budget: &profilingv1alpha1.StorageBudget{MaxStorageBytes: 1 << 30}},
+		{name: "daily cap", prefix: "{namespace}", budget: &profilingv1alpha1.StorageBudget{MaxDailyUploadBytes: 1 << 20}},
+		{name: "no cap", budget: &profilingv1alpha1.StorageBudget{Action: profilingv1alpha1.StorageBudgetHeapOnly}, wantErr: true},
+		{name: "storage cap of every namespace", prefix: "{namespace}", budget: &profilingv1alpha1.StorageBudget{MaxStorageBytes: 1 << 30}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestProfilingConfig("api", "default")
+			config.Spec.Selector.Namespace = ""
+			if tt.prefix != "" {
+				config.Spec.S3Config.Prefix = tt.prefix
+			}
+			config.Spec.StorageBudget = tt.budget
+			err := reconciler.validateConfig(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+const (
+	// storageCheckInterval is how often the bucket of a config is probed again
+	// while its s3Config is unchanged
+	storageCheckInterval = 10 * time.Minute
+
+	// storageCheckTimeout bounds a probe of a bucket, which runs in the reconcile
+	storageCheckTimeout = 10 * time.Second
+)
+
+// storageChecks remembers when the bucket of each config was last probed. The
+// zero value is ready to use.
+type storageChecks struct {
+	mu sync.Mutex
+
+	// checked holds the s3Config last probed and when, by config
+	checked map[string]storageCheck
+}
+
+// storageCheck is a probe of the bucket of a config
+type storageCheck struct {
+	s3Config profilingv1alpha1.S3Configuration
+	at       time.Time
+}
+
+// due reports whether the bucket of a config should be probed at now, which it
+// is when its s3Config changed or the interval passed, recording the probe if so
+func (c *storageChecks) due(configKey string, s3Config profilingv1alpha1.S3Configuration, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if last, ok := c.checked[configKey]; ok && equality.Semantic.DeepEqual(last.s3Config, s3Config) && now.Sub(last.at) < storageCheckInterval {
+		return false
+	}
+	if c.checked == nil {
+		c.checked = make(map[string]storageCheck)
+	}
+	c.checked[configKey] = storageCheck{s3Config: s3Config, at: now}
+	return true
+}
+
+// forget drops the probes of a config
+func (c *storageChecks) forget(configKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.checked, configKey)
+}
+
+// checkStorage probes the bucket of a config with the access check of its
+// s3Config and reports the result in the StorageReady condition. A failure
+// does not stop monitoring, as the bucket may come back before the next
+// capture, but records a warning event when it first shows up.
+func (r *ProfilingConfigReconciler) checkStorage(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) {
+	configKey := configKeyOf(config)
+	accessCheck := config.Spec.S3Config.AccessCheck
+	if accessCheck == "" {
+		r.storageChecks.forget(configKey)
+		apimeta.RemoveStatusCondition(&config.Status.Conditions, ConditionStorageReady)
+		return
+	}
+	if !r.storageChecks.due(configKey, config.Spec.S3Config, time.Now()) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storageCheckTimeout)
+	defer cancel()
+
+	write := accessCheck == profilingv1alpha1.StorageAccessCheckWrite
+	checked, err := r.probeStorage(ctx, config, write)
+	if !checked {
+		// The destination cannot be probed, captures tell
+		apimeta.RemoveStatusCondition(&config.Status.Conditions, ConditionStorageReady)
+		return
+	}
+	if err == nil {
+		message := fmt.Sprintf("Bucket %s can be accessed", config.Spec.S3Config.Bucket)
+		if write {
+			message = fmt.Sprintf("Bucket %s can be written", config.Spec.S3Config.Bucket)
+		}
+		setCondition(config, ConditionStorageReady, metav1.ConditionTrue, reasonStorageAccessible, message)
+		return
+	}
+
+	log.FromContext(ctx).Error(err, "Storage access check failed", "bucket", config.Spec.S3Config.Bucket)
+	previous := apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageReady)
+	if previous == nil || previous.Status != metav1.ConditionFalse || previous.Message != err.Error() {
+		r.Recorder.Eventf(config, corev1.EventTypeWarning, reasonStorageUnavailable,
+			"Storage access check failed, captures will not be uploaded: %v", err)
+		setLastError(config, err)
+	}
+	setCondition(config, ConditionStorageReady, metav1.ConditionFalse, reasonStorageUnavailable, err.Error())
+}
+
+// probeStorage runs the access check of the uploader of a config, reporting
+// false if the uploader cannot be probed
+func (r *ProfilingConfigReconciler) probeStorage(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, write bool) (bool, error) {
+	s3Config, err := r.storageConfigOf(ctx, config)
+	if err != nil {
+		return true, err
+	}
+	storage, err := r.uploaders(ctx, s3Config)
+	if err != nil {
+		return true, fmt.Errorf("failed to create uploader: %w", err)
+	}
+
+	checker, ok := storage.(uploader.AccessChecker)
+	if !ok {
+		return false, nil
+	}
+	return true, checker.CheckAccess(ctx, write)
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func TestStorageChecks_Due(t *testing.T) {
+	var checks storageChecks
+	s3Config := profilingv1alpha1.S3Configuration{Bucket: "test-bucket", AccessCheck: profilingv1alpha1.StorageAccessCheckRead}
+	now := time.Now()
+
+	if !checks.due("default/test", s3Config, now) {
+		t.Error("Expected the first check to be due")
+	}
+	if checks.due("default/test", s3Config, now.Add(time.Minute)) {
+		t.Error("Expected no check within the interval")
+	}
+	if !checks.due("default/test", s3Config, now.Add(storageCheckInterval+time.Minute)) {
+		t.Error("Expected a check once the interval passed")
+	}
+
+	s3Config.Bucket = "other-bucket"
+	if !checks.due("default/test", s3Config, now.Add(storageCheckInterval+2*time.Minute)) {
+		t.Error("Expected a check once the s3Config changed")
+	}
+}
+
+func TestCheckStorage(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config.AccessCheck = profilingv1alpha1.StorageAccessCheckWrite
+	reconciler := setupTestReconciler(config)
+	storage := &uploader.Fake{Bucket: "test-bucket", AccessErr: errors.New("AccessDenied")}
+	reconciler.uploaders = func(context.Context, uploader.S3Config) (uploader.Uploader, error) {
+		return storage, nil
+	}
+	ctx := context.Background()
+
+	reconciler.checkStorage(ctx, config)
+	condition := apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageReady)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != reasonStorageUnavailable {
+		t.Fatalf("Expected StorageReady to be false, got %+v", condition)
+	}
+	if config.Status.LastErrorMessage == "" {
+		t.Error("Expected the failure to be recorded as the last error")
+	}
+
+	// The bucket is probed again once the s3Config changes
+	storage.AccessErr = nil
+	config.Spec.S3Config.Prefix = "team-a"
+	reconciler.checkStorage(ctx, config)
+	condition = apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageReady)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("Expected StorageReady to be true, got %+v", condition)
+	}
+
+	// Disabling the check drops the condition
+	config.Spec.S3Config.AccessCheck = ""
+	reconciler.checkStorage(ctx, config)
+	if condition := apimeta.FindStatusCondition(config.Status.Conditions, ConditionStorageReady); condition != nil {
+		t.Errorf("Expected no StorageReady condition, got %+v", condition)
+	}
+}
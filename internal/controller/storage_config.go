@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+// defaultCABundleKey is the ConfigMap key of a CA bundle reference naming none
+const defaultCABundleKey = "ca.crt"
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// storageConfigOf returns the upload destination of a config with the CA
+// bundle it references and the credentials of its web identity, for the
+// clients connecting to the bucket
+func (r *ProfilingConfigReconciler) storageConfigOf(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) (uploader.S3Config, error) {
+	s3Config := s3ConfigOf(config)
+	if ref := config.Spec.S3Config.CABundleRef; ref != nil {
+		caBundle, err := r.configMapValue(ctx, config.Namespace, *ref, defaultCABundleKey)
+		if err != nil {
+			return uploader.S3Config{}, fmt.Errorf("failed to load ca bundle: %w", err)
+		}
+		s3Config.CABundle = caBundle
+	}
+	if config.Spec.S3Config.WebIdentity != nil {
+		credentials, err := r.webIdentityCredentialsOf(ctx, config, s3Config)
+		if err != nil {
+			return uploader.S3Config{}, fmt.Errorf("failed to set up web identity: %w", err)
+		}
+		s3Config.Credentials = credentials
+	}
+	return s3Config, nil
+}
+
+// configMapValue reads a key of a ConfigMap in namespace, defaultKey if the
+// reference names none
+func (r *ProfilingConfigReconciler) configMapValue(ctx context.Context, namespace string, ref profilingv1alpha1.ConfigMapKeyRef, defaultKey string) ([]byte, error) {
+	key := withDefault(ref.Key, defaultKey)
+
+	configMap, err := r.Clientset.CoreV1().ConfigMaps(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configmap %s: %w", ref.Name, err)
+	}
+
+	if value, ok := configMap.Data[key]; ok {
+		return []byte(value), nil
+	}
+	if value, ok := configMap.BinaryData[key]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("configmap %s has no key %s", ref.Name, key)
+}
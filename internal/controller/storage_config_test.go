@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func TestStorageConfigOf(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config.ProxyURL = "http://proxy.internal:3128"
+	config.Spec.S3Config.CABundleRef = &profilingv1alpha1.ConfigMapKeyRef{Name: "corporate-ca"}
+	reconciler := setupTestReconciler(config)
+	reconciler.Clientset = fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "corporate-ca", Namespace: "default"},
+		Data:       map[string]string{"ca.crt": "-----BEGIN CERTIFICATE-----"},
+	})
+	ctx := context.Background()
+
+	s3Config, err := reconciler.storageConfigOf(ctx, config)
+	if err != nil {
+		t.Fatalf("storageConfigOf returned unexpected error: %v", err)
+	}
+	if string(s3Config.CABundle) != "-----BEGIN CERTIFICATE-----" || s3Config.ProxyURL != "http://proxy.internal:3128" {
+		t.Errorf("Expected the CA bundle and proxy of the config, got %+v", s3Config)
+	}
+
+	config.Spec.S3Config.CABundleRef.Key = "bundle.pem"
+	if _, err := reconciler.storageConfigOf(ctx, config); err == nil {
+		t.Error("Expected an error for a missing ConfigMap key")
+	}
+}
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// suppress counts a capture of a config that did not happen for reason
+func (r *ProfilingConfigReconciler) suppress(config *profilingv1alpha1.ProfilingConfig, reason string) {
+	r.suppressions.Inc(configKeyOf(config), reason)
+}
+
+// backoffReason returns the reason the captures of a pod backed off after
+// failed captures are suppressed for
+func (r *ProfilingConfigReconciler) backoffReason(pod *corev1.Pod) string {
+	if r.podWatcher.Quarantined(pod) {
+		return metrics.SuppressedQuarantine
+	}
+	return metrics.SuppressedBackoff
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestBackoffReason(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+	reconciler.podWatcher.TrackPod(pod, config)
+
+	reconciler.podWatcher.RecordCapture(pod, "test", errors.New("connection refused"))
+	if reason := reconciler.backoffReason(pod); reason != metrics.SuppressedBackoff {
+		t.Errorf("Expected a backed off pod, got %q", reason)
+	}
+	for range quarantineFailures {
+		reconciler.podWatcher.RecordCapture(pod, "test", errors.New("connection refused"))
+	}
+	if reason := reconciler.backoffReason(pod); reason != metrics.SuppressedQuarantine {
+		t.Errorf("Expected a quarantined pod, got %q", reason)
+	}
+}
+
+func TestEnqueueCapture_Suppressed(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+
+	// The queue is not started, so the pod stays queued
+	reconciler.enqueueCapture(context.Background(), pod, config, metrics.Trigger{Reason: "test"}, false)
+	reconciler.enqueueCapture(context.Background(), pod, config, metrics.Trigger{Reason: "test"}, false)
+
+	expected := `
+# HELP bolometer_captures_suppressed_total Captures that did not happen, by config and reason. Dedup counts profiles not stored.
+# TYPE bolometer_captures_suppressed_total counter
+bolometer_captures_suppressed_total{config="default/test-config",reason="concurrency"} 1
+`
+	if err := testutil.CollectAndCompare(reconciler.suppressions, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
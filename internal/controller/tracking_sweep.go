@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// trackingSweepInterval is how often tracking state is checked against the
+// live pods and configs
+const trackingSweepInterval = 5 * time.Minute
+
+// sweepTrackingState periodically drops the tracking state of deleted pods and
+// configs until ctx is cancelled. Reconciles prune tracked pods against the
+// live list already; the sweep catches state left behind by missed events.
+func (r *ProfilingConfigReconciler) sweepTrackingState(ctx context.Context) error {
+	ticker := time.NewTicker(trackingSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.sweepOnce(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to sweep tracking state")
+			}
+		}
+	}
+}
+
+// sweepOnce drops the tracking state of deleted configs and pods, along with
+// cooldowns, capture state, metrics history and profile series no tracked pod needs
+func (r *ProfilingConfigReconciler) sweepOnce(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	configs := &profilingv1alpha1.ProfilingConfigList{}
+	if err := r.List(ctx, configs); err != nil {
+		return err
+	}
+	live := make(map[string]struct{}, len(configs.Items))
+	for i := range configs.Items {
+		live[configKeyOf(&configs.Items[i])] = struct{}{}
+	}
+
+	for _, configKey := range r.podWatcher.ClaimingConfigs() {
+		if _, ok := live[configKey]; !ok {
+			logger.Info("Dropping pods of deleted config", "config", configKey)
+			r.pruneTrackedPods(configKey, nil)
+		}
+	}
+
+	// Pods of remote clusters are pruned by the reconciles listing them
+	for _, tracked := range r.podWatcher.GetTrackedPods() {
+		pod := tracked.Pod
+		if podCluster(pod) != "" {
+			continue
+		}
+
+		gone, err := r.podGone(ctx, pod)
+		if err != nil {
+			return err
+		}
+		if gone {
+			logger.V(1).Info("Dropping deleted pod", "pod", r.podWatcher.getPodKey(pod))
+			r.podWatcher.StopTrackingPod(pod)
+		}
+	}
+
+	r.podWatcher.PruneUntracked()
+
+	tracked := make(map[string]struct{})
+	for _, pod := range r.podWatcher.GetTrackedPods() {
+		tracked[r.podWatcher.getPodKey(pod.Pod)] = struct{}{}
+	}
+	for _, key := range r.metricsHistory.Keys() {
+		if _, ok := tracked[key]; !ok {
+			r.metricsHistory.Forget(key)
+		}
+	}
+	for _, key := range r.usageGauges.Keys() {
+		if _, ok := tracked[key]; !ok {
+			r.usageGauges.Forget(key)
+		}
+	}
+	r.leaks.retain(tracked)
+	r.dedup.retain(tracked)
+
+	return nil
+}
+
+// podGone reports whether a tracked pod was deleted or replaced by a pod of
+// the same name
+func (r *ProfilingConfigReconciler) podGone(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	current := &corev1.Pod{}
+	if err := r.podWatcher.reader.Get(ctx, client.ObjectKeyFromObject(pod), current); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return current.UID != pod.UID || current.DeletionTimestamp != nil, nil
+}
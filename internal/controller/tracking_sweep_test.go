@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestSweepOnce(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	live := createTestPod("live-pod", "default", true)
+	deleted := createTestPod("deleted-pod", "default", true)
+	reconciler := setupTestReconciler(config, live)
+	ctx := context.Background()
+
+	reconciler.podWatcher.TrackPod(live, config)
+	reconciler.podWatcher.TrackPod(deleted, config)
+	reconciler.podWatcher.TrackPod(createTestPod("orphan-pod", "default", true), createTestProfilingConfig("deleted-config", "default"))
+	reconciler.podWatcher.RestoreLastProfileTime("default/gone-pod", time.Now())
+	for _, key := range []string{"default/live-pod", "default/deleted-pod", "default/gone-pod"} {
+		reconciler.metricsHistory.Record(key, &metrics.PodMetrics{})
+		reconciler.usageGauges.Set(key, metrics.UsageLabels{}, &metrics.PodMetrics{}, metrics.Thresholds{})
+	}
+
+	if err := reconciler.sweepOnce(ctx); err != nil {
+		t.Fatalf("sweepOnce returned unexpected error: %v", err)
+	}
+
+	tracked := reconciler.podWatcher.GetTrackedPods()
+	if len(tracked) != 1 || tracked[0].Pod.Name != "live-pod" {
+		t.Errorf("Expected only the live pod to stay tracked, got %d pods", len(tracked))
+	}
+	if configs := reconciler.podWatcher.ClaimingConfigs(); len(configs) != 1 || configs[0] != "default/test-config" {
+		t.Errorf("Expected the deleted config's claims to be dropped, got %v", configs)
+	}
+	if !reconciler.podWatcher.CanProfile(createTestPod("gone-pod", "default", true), 300) {
+		t.Error("Expected the cooldown of an untracked pod to be dropped")
+	}
+	if keys := reconciler.metricsHistory.Keys(); len(keys) != 1 || keys[0] != "default/live-pod" {
+		t.Errorf("Expected only the live pod's history to be kept, got %v", keys)
+	}
+	if keys := reconciler.usageGauges.Keys(); len(keys) != 1 || keys[0] != "default/live-pod" {
+		t.Errorf("Expected only the live pod's usage to be exported, got %v", keys)
+	}
+}
+
+func TestPodWatcher_ListMatchingPods_Terminating(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	terminating := createTestPod("terminating-pod", "default", true)
+	now := metav1.Now()
+	terminating.DeletionTimestamp = &now
+	terminating.Finalizers = []string{"example.com/hold"}
+	reconciler := setupTestReconciler(terminating, createTestPod("running-pod", "default", true))
+
+	pods, err := reconciler.podWatcher.ListMatchingPods(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ListMatchingPods returned unexpected error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "running-pod" {
+		t.Errorf("Expected pods being deleted to be left out, got %d pods", len(pods))
+	}
+}
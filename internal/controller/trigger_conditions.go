@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+// goroutineCount returns the number of goroutines of a pod, false if it could
+// not be counted
+type goroutineCount func() (int, bool)
+
+// evaluateConditions reports whether all the comparisons of any condition
+// group hold for a pod, with the comparisons of the first such group as the
+// reason. Goroutines are only counted when a group reaches a comparison of
+// them.
+func evaluateConditions(groups []profilingv1alpha1.ConditionGroup, usage *metrics.PodMetrics, goroutines goroutineCount) (bool, string) {
+	for _, group := range groups {
+		var met []string
+		for _, condition := range group.All {
+			value, ok := conditionValue(condition.Metric, usage, goroutines)
+			if !ok || !conditionHolds(condition, value) {
+				met = nil
+				break
+			}
+			met = append(met, describeCondition(condition, value))
+		}
+		if len(met) > 0 {
+			return true, "Conditions met: " + strings.Join(met, " and ")
+		}
+	}
+	return false, ""
+}
+
+// conditionValue returns the value of a condition metric for a pod, false if
+// it is unknown
+func conditionValue(metric profilingv1alpha1.ConditionMetric, usage *metrics.PodMetrics, goroutines goroutineCount) (float64, bool) {
+	switch metric {
+	case profilingv1alpha1.ConditionCPUPercent:
+		return usage.CPUUsagePercent, true
+	case profilingv1alpha1.ConditionMemoryPercent:
+		return usage.MemoryUsagePercent, true
+	case profilingv1alpha1.ConditionGoroutines:
+		if goroutines == nil {
+			return 0, false
+		}
+		count, ok := goroutines()
+		return float64(count), ok
+	default:
+		return 0, false
+	}
+}
+
+// conditionHolds reports whether a comparison holds for a value
+func conditionHolds(condition profilingv1alpha1.MetricCondition, value float64) bool {
+	if condition.Operator == profilingv1alpha1.ConditionLessThan {
+		return value < float64(condition.Value)
+	}
+	return value > float64(condition.Value)
+}
+
+// describeCondition describes a comparison that holds, e.g. "cpuPercent 85.20 > 80"
+func describeCondition(condition profilingv1alpha1.MetricCondition, value float64) string {
+	operator := ">"
+	if condition.Operator == profilingv1alpha1.ConditionLessThan {
+		operator = "<"
+	}
+	if condition.Metric == profilingv1alpha1.ConditionGoroutines {
+		return fmt.Sprintf("%s %.0f %s %d", condition.Metric, value, operator, condition.Value)
+	}
+	return fmt.Sprintf("%s %.2f %s %d", condition.Metric, value, operator, condition.Value)
+}
+
+// podGoroutines returns the goroutine count of a pod, counted through the
+// profiler of its cluster on first use so that conditions not reaching a
+// goroutines comparison open no port-forward
+func podGoroutines(ctx context.Context, cluster *targetCluster, pod *corev1.Pod, logger logr.Logger) goroutineCount {
+	counted, count, ok := false, 0, false
+	return func() (int, bool) {
+		if counted {
+			return count, ok
+		}
+		counted = true
+
+		counter, isCounter := cluster.profiler.(profiler.GoroutineCounter)
+		if !isCounter {
+			logger.V(1).Info("Profiler cannot count goroutines", "pod", pod.Name)
+			return count, ok
+		}
+		ctx, cancel := context.WithTimeout(ctx, pprofProbeTimeout)
+		defer cancel()
+		n, err := counter.CountGoroutines(ctx, pod)
+		if err != nil {
+			logger.V(1).Info("Failed to count goroutines", "pod", pod.Name, "error", err.Error())
+			return count, ok
+		}
+		count, ok = n, true
+		return count, ok
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+func TestEvaluateConditions(t *testing.T) {
+	groups := []profilingv1alpha1.ConditionGroup{
+		{All: []profilingv1alpha1.MetricCondition{
+			{Metric: profilingv1alpha1.ConditionCPUPercent, Value: 80},
+			{Metric: profilingv1alpha1.ConditionMemoryPercent, Value: 70},
+		}},
+		{All: []profilingv1alpha1.MetricCondition{
+			{Metric: profilingv1alpha1.ConditionGoroutines, Operator: profilingv1alpha1.ConditionGreaterThan, Value: 50000},
+		}},
+	}
+
+	counted := 0
+	goroutines := func(count int) goroutineCount {
+		return func() (int, bool) {
+			counted++
+			return count, true
+		}
+	}
+
+	tests := []struct {
+		name       string
+		usage      *metrics.PodMetrics
+		goroutines goroutineCount
+		met        bool
+		reason     string
+		counted    int
+	}{
+		{
+			name:       "all comparisons of a group hold",
+			usage:      &metrics.PodMetrics{CPUUsagePercent: 85.2, MemoryUsagePercent: 75},
+			goroutines: goroutines(100),
+			met:        true,
+			reason:     "Conditions met: cpuPercent 85.20 > 80 and memoryPercent 75.00 > 70",
+		},
+		{
+			name:       "one comparison of each group fails",
+			usage:      &metrics.PodMetrics{CPUUsagePercent: 85, MemoryUsagePercent: 50},
+			goroutines: goroutines(100),
+			counted:    1,
+		},
+		{
+			name:       "another group holds",
+			usage:      &metrics.PodMetrics{CPUUsagePercent: 10},
+			goroutines: goroutines(60000),
+			met:        true,
+			reason:     "Conditions met: goroutines 60000 > 50000",
+			counted:    1,
+		},
+		{
+			name:  "goroutines cannot be counted",
+			usage: &metrics.PodMetrics{CPUUsagePercent: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted = 0
+			met, reason := evaluateConditions(groups, tt.usage, tt.goroutines)
+			if met != tt.met || reason != tt.reason {
+				t.Errorf("Expected %v %q, got %v %q", tt.met, tt.reason, met, reason)
+			}
+			if counted != tt.counted {
+				t.Errorf("Expected goroutines to be counted %d times, got %d", tt.counted, counted)
+			}
+		})
+	}
+}
+
+func TestEvaluateConditions_LessThan(t *testing.T) {
+	groups := []profilingv1alpha1.ConditionGroup{{All: []profilingv1alpha1.MetricCondition{
+		{Metric: profilingv1alpha1.ConditionCPUPercent, Operator: profilingv1alpha1.ConditionLessThan, Value: 5},
+	}}}
+
+	if met, reason := evaluateConditions(groups, &metrics.PodMetrics{CPUUsagePercent: 2}, nil); !met || reason != "Conditions met: cpuPercent 2.00 < 5" {
+		t.Errorf("Expected the condition to hold, got %v %q", met, reason)
+	}
+}
+
+func TestPodGoroutines(t *testing.T) {
+	ctx := context.Background()
+	pod := createTestPod("test-pod", "default", true)
+
+	goroutines := podGoroutines(ctx, &targetCluster{profiler: &profiler.Fake{Goroutines: 42}}, pod, log.FromContext(ctx))
+	if count, ok := goroutines(); !ok || count != 42 {
+		t.Errorf("Expected 42 goroutines, got %d %v", count, ok)
+	}
+
+	goroutines = podGoroutines(ctx, &targetCluster{profiler: &profiler.Fake{ProbeErr: errors.New("connection refused")}}, pod, log.FromContext(ctx))
+	if _, ok := goroutines(); ok {
+		t.Error("Expected no count when the pprof endpoint is unreachable")
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// Variables of the triggerExpression metrics context
+const (
+	expressionCPUPercent    = "cpuPercent"
+	expressionMemPercent    = "memPercent"
+	expressionRestarts      = "restarts"
+	expressionThrottledRate = "throttledRate"
+	expressionGoroutines    = "goroutines"
+)
+
+// triggerExpression is a compiled triggerExpression
+type triggerExpression struct {
+	source  string
+	program cel.Program
+}
+
+// compileTriggerExpression compiles a triggerExpression against the metrics
+// context, requiring it to evaluate to a bool
+func compileTriggerExpression(source string) (*triggerExpression, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(expressionCPUPercent, cel.DoubleType),
+		cel.Variable(expressionMemPercent, cel.DoubleType),
+		cel.Variable(expressionRestarts, cel.IntType),
+		cel.Variable(expressionThrottledRate, cel.DoubleType),
+		cel.Variable(expressionGoroutines, cel.IntType),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(source)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return &triggerExpression{source: source, program: program}, nil
+}
+
+// evaluate reports whether the expression holds for a pod. The throttledRate
+// of sources not reporting throttling, and goroutines that cannot be counted,
+// fail the evaluation when the expression reads them. Goroutines are only
+// counted when the evaluation reaches them.
+func (e *triggerExpression) evaluate(pod *corev1.Pod, usage *metrics.PodMetrics, goroutines goroutineCount) (bool, string, error) {
+	variables := map[string]any{
+		expressionCPUPercent: usage.CPUUsagePercent,
+		expressionMemPercent: usage.MemoryUsagePercent,
+		expressionRestarts:   podRestarts(pod),
+		expressionThrottledRate: func() ref.Val {
+			if usage.CPUThrottledRate == nil {
+				return types.NewErr("throttledRate is not reported by the metrics source")
+			}
+			return types.Double(*usage.CPUThrottledRate)
+		},
+		expressionGoroutines: func() ref.Val {
+			if goroutines == nil {
+				return types.NewErr("goroutines cannot be counted")
+			}
+			count, ok := goroutines()
+			if !ok {
+				return types.NewErr("goroutines cannot be counted")
+			}
+			return types.Int(count)
+		},
+	}
+
+	result, _, err := e.program.Eval(variables)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to evaluate trigger expression: %w", err)
+	}
+	if result != types.True {
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("Trigger expression %q is true", e.source), nil
+}
+
+// podRestarts returns the restarts of the containers of a pod
+func podRestarts(pod *corev1.Pod) int64 {
+	var restarts int64
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += int64(status.RestartCount)
+	}
+	return restarts
+}
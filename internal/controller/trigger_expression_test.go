@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+func TestCompileTriggerExpression(t *testing.T) {
+	for _, source := range []string{
+		"cpuPercent > 80.0 && memPercent > 70.0",
+		"restarts > 3 || goroutines > 50000 || throttledRate > 0.5",
+	} {
+		if _, err := compileTriggerExpression(source); err != nil {
+			t.Errorf("Expected %q to compile, got %v", source, err)
+		}
+	}
+
+	for _, source := range []string{
+		"cpuPercent >",
+		"cpuPercent + 1.0",
+		"cpu > 80.0",
+	} {
+		if _, err := compileTriggerExpression(source); err == nil {
+			t.Errorf("Expected %q not to compile", source)
+		}
+	}
+}
+
+func TestTriggerExpression_Evaluate(t *testing.T) {
+	pod := createTestPod("test-pod", "default", true)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", RestartCount: 3}, {Name: "sidecar", RestartCount: 1}}
+	throttled := 0.6
+	usage := &metrics.PodMetrics{CPUUsagePercent: 85, MemoryUsagePercent: 40, CPUThrottledRate: &throttled}
+
+	counted := false
+	goroutines := func() (int, bool) {
+		counted = true
+		return 60000, true
+	}
+
+	tests := []struct {
+		source  string
+		met     bool
+		counted bool
+		err     bool
+	}{
+		{source: "cpuPercent > 80.0 && restarts >= 4", met: true},
+		{source: "memPercent > 50.0", met: false},
+		{source: "throttledRate > 0.5", met: true},
+		{source: "cpuPercent > 80.0 || goroutines > 50000", met: true},
+		{source: "cpuPercent > 90.0 || goroutines > 50000", met: true, counted: true},
+		{source: "memPercent > 90.0 && goroutines > 50000", met: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			counted = false
+			expression, err := compileTriggerExpression(tt.source)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			met, reason, err := expression.evaluate(pod, usage, goroutines)
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+			if met != tt.met || (met && reason != `Trigger expression "`+tt.source+`" is true`) {
+				t.Errorf("Expected %v, got %v %q", tt.met, met, reason)
+			}
+			if counted != tt.counted {
+				t.Errorf("Expected goroutines counted=%v, got %v", tt.counted, counted)
+			}
+		})
+	}
+}
+
+func TestTriggerExpression_UnavailableVariables(t *testing.T) {
+	pod := createTestPod("test-pod", "default", true)
+	usage := &metrics.PodMetrics{CPUUsagePercent: 85}
+
+	expression, _ := compileTriggerExpression("throttledRate > 0.5")
+	if met, _, err := expression.evaluate(pod, usage, nil); met || err == nil {
+		t.Errorf("Expected an error without throttling, got %v %v", met, err)
+	}
+
+	// Operands deciding the result spare the unavailable ones
+	expression, _ = compileTriggerExpression("goroutines > 50000 || cpuPercent > 80.0")
+	if met, _, err := expression.evaluate(pod, usage, nil); !met || err != nil {
+		t.Errorf("Expected the CPU comparison to decide, got %v %v", met, err)
+	}
+}
@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// CaptureRequest is a capture of a pod requested by a trigger source
+type CaptureRequest struct {
+	Pod     *corev1.Pod
+	Trigger metrics.Trigger
+
+	// StartCooldown starts the pod's cooldown once the capture succeeds, so
+	// the source does not request it again until the cooldown has passed
+	StartCooldown bool
+}
+
+// TriggerSource decides when the pods of a config are captured. Each source
+// runs as a supervised monitor task of the config, restarted with backoff if
+// it panics or returns early, unless it is a ScheduledSource.
+type TriggerSource interface {
+	// Name identifies the source in monitor tasks, logs and audit records
+	Name() string
+
+	// Start requests captures through emit until ctx is done. Requests for
+	// pods backed off after failed captures are dropped.
+	Start(ctx context.Context, emit func(CaptureRequest))
+}
+
+// ScheduledSource is a TriggerSource checking the pods of a config
+// periodically. Its checks are run by the Scheduler on the check workers
+// shared by all configs instead of Start running on a goroutine of its own.
+type ScheduledSource interface {
+	TriggerSource
+
+	// InitialDelay returns the delay until the first check
+	InitialDelay() time.Duration
+
+	// Check requests captures through emit and returns the delay until the
+	// next check. Checks of a source never run concurrently.
+	Check(ctx context.Context, emit func(CaptureRequest)) time.Duration
+}
+
+// runScheduled runs the checks of a scheduled source on the calling goroutine
+// until ctx is done, for callers starting it as a plain TriggerSource
+func runScheduled(ctx context.Context, source ScheduledSource, emit func(CaptureRequest)) {
+	timer := time.NewTimer(source.InitialDelay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(max(source.Check(ctx, emit), minCheckDelay))
+		}
+	}
+}
+
+// TriggerSourceFactory creates a trigger source for a config, nil if the config
+// does not use it. trackedPods returns the pods the config currently profiles.
+// Sources are recreated whenever the config's spec changes.
+type TriggerSourceFactory func(config *profilingv1alpha1.ProfilingConfig, trackedPods func() []*corev1.Pod) TriggerSource
+
+// thresholdSource captures the pods whose usage exceeds the config's
+// thresholds, checked at the adaptive check interval
+type thresholdSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+
+	// expression is the config's compiled triggerExpression, if any, and
+	// expressionErr why it failed to compile. Sources are recreated when the
+	// spec hash changes, so it is compiled once per spec.
+	expression    *triggerExpression
+	expressionErr error
+
+	// interval is the current adaptive check interval
+	interval time.Duration
+}
+
+// newThresholdSource creates the threshold source of a config, compiling its
+// triggerExpression
+func newThresholdSource(r *ProfilingConfigReconciler, config *profilingv1alpha1.ProfilingConfig) *thresholdSource {
+	s := &thresholdSource{r: r, config: config}
+	if config.Spec.TriggerExpression != "" {
+		s.expression, s.expressionErr = compileTriggerExpression(config.Spec.TriggerExpression)
+	}
+	return s
+}
+
+// Name implements TriggerSource
+func (s *thresholdSource) Name() string { return "thresholds" }
+
+// Start implements TriggerSource
+func (s *thresholdSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource
+func (s *thresholdSource) InitialDelay() time.Duration {
+	return time.Duration(s.config.Spec.Thresholds.CheckIntervalSeconds) * time.Second
+}
+
+// Check implements ScheduledSource, adjusting the check interval to the
+// threshold utilization observed
+func (s *thresholdSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	logger := log.FromContext(ctx)
+	baseInterval := time.Duration(s.config.Spec.Thresholds.CheckIntervalSeconds) * time.Second
+	maxInterval := time.Duration(s.config.Spec.Thresholds.MaxCheckIntervalSeconds) * time.Second
+	if s.interval == 0 {
+		s.interval = baseInterval
+	}
+
+	if s.expressionErr != nil {
+		logger.Error(s.expressionErr, "Invalid trigger expression")
+		return s.interval
+	}
+	utilization := s.r.checkPodsThresholds(ctx, s.config, s.expression, logger, emit)
+
+	next := nextCheckInterval(s.interval, baseInterval, maxInterval, utilization)
+	if next != s.interval {
+		logger.V(1).Info("Adjusting check interval",
+			"interval", next,
+			"utilization", utilization,
+		)
+		s.interval = next
+	}
+	return s.interval
+}
+
+// onDemandSource captures the tracked pods on the schedule of the config's
+// onDemand block
+type onDemandSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+
+	// cursor rotates through the tracked pods when MaxPodsPerCapture is set,
+	// serviceCursors through the replicas of each service when ReplicasPerService is
+	cursor         int
+	serviceCursors map[string]int
+}
+
+// Name implements TriggerSource
+func (s *onDemandSource) Name() string { return "on-demand" }
+
+// Start implements TriggerSource
+func (s *onDemandSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource
+func (s *onDemandSource) InitialDelay() time.Duration {
+	return time.Duration(s.config.Spec.OnDemand.IntervalSeconds) * time.Second
+}
+
+// Check implements ScheduledSource
+func (s *onDemandSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	s.cursor = s.r.checkOnDemand(ctx, s.config, s.cursor, s.serviceCursors, emit)
+	return s.InitialDelay()
+}
+
+// triggerSources returns the sources triggering the captures of a config: the
+// built-in ones it enables followed by those of the registered factories
+func (r *ProfilingConfigReconciler) triggerSources(config *profilingv1alpha1.ProfilingConfig) []TriggerSource {
+	// Threshold-based monitoring always runs
+	sources := []TriggerSource{newThresholdSource(r, config)}
+
+	if config.Spec.OnDemand != nil && config.Spec.OnDemand.Enabled {
+		sources = append(sources, &onDemandSource{r: r, config: config, serviceCursors: make(map[string]int)})
+	}
+	if config.Spec.VPADrift != nil {
+		sources = append(sources, &vpaDriftSource{r: r, config: config})
+	}
+	if config.Spec.HPAScaleOut != nil {
+		sources = append(sources, &hpaScaleOutSource{r: r, config: config, detector: newScaleOutDetector(config.Spec.HPAScaleOut)})
+	}
+	if len(config.Spec.EventTriggers) > 0 {
+		sources = append(sources, &eventTriggerSource{r: r, config: config})
+	}
+	if config.Spec.EphemeralStorage != nil {
+		sources = append(sources, &ephemeralStorageSource{r: r, config: config})
+	}
+	if config.Spec.NetworkIO != nil {
+		sources = append(sources, &networkIOSource{r: r, config: config, sampler: newNetworkSampler()})
+	}
+	if len(config.Spec.Schedules) > 0 {
+		sources = append(sources, newScheduleSource(r, config))
+	}
+	if config.Spec.Webhook != nil {
+		sources = append(sources, &webhookSource{r: r, config: config})
+	}
+
+	trackedPods := func() []*corev1.Pod {
+		var pods []*corev1.Pod
+		for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+			pods = append(pods, tracked.Pod)
+		}
+		return pods
+	}
+	for _, factory := range r.triggerFactories {
+		if source := factory(config, trackedPods); source != nil {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// triggerTask runs a trigger source as a monitor task, queueing the captures it
+// requests
+func (r *ProfilingConfigReconciler) triggerTask(config *profilingv1alpha1.ProfilingConfig, source TriggerSource) MonitorTask {
+	return MonitorTask{
+		Name: source.Name(),
+		Run: func(ctx context.Context) {
+			source.Start(ctx, r.triggerEmitter(ctx, config, source))
+		},
+	}
+}
+
+// scheduledTask runs the checks of a scheduled source as a task of the
+// Scheduler, queueing the captures it requests
+func (r *ProfilingConfigReconciler) scheduledTask(config *profilingv1alpha1.ProfilingConfig, source ScheduledSource) ScheduledTask {
+	return ScheduledTask{
+		Name:  source.Name(),
+		Delay: source.InitialDelay(),
+		Check: func(ctx context.Context) time.Duration {
+			return source.Check(ctx, r.triggerEmitter(ctx, config, source))
+		},
+	}
+}
+
+// triggerEmitter returns the emit function of a trigger source, queueing the
+// captures it requests. Captures of sources other than the built-in ones are
+// audited as triggered by the source.
+func (r *ProfilingConfigReconciler) triggerEmitter(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, source TriggerSource) func(CaptureRequest) {
+	builtin := false
+	switch source.(type) {
+	case *thresholdSource, *onDemandSource:
+		builtin = true
+	}
+
+	logger := log.FromContext(ctx).WithValues("source", source.Name())
+	return func(request CaptureRequest) {
+		if r.podWatcher.InBackoff(request.Pod) {
+			logger.V(1).Info("Pod backed off after failed captures", "pod", request.Pod.Name)
+			r.suppress(config, r.backoffReason(request.Pod))
+			return
+		}
+		if !builtin && request.Trigger.Source == "" {
+			request.Trigger.Source = source.Name()
+		}
+		r.enqueueCapture(ctx, request.Pod, config, request.Trigger, request.StartCooldown)
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// requestingSource requests a capture of each of its pods once
+type requestingSource struct {
+	pods func() []*corev1.Pod
+}
+
+func (s *requestingSource) Name() string { return "rollouts" }
+
+func (s *requestingSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	for _, pod := range s.pods() {
+		emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: "rollout finished"}})
+	}
+	<-ctx.Done()
+}
+
+func TestTriggerSources(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	reconciler := setupTestReconciler(config)
+	reconciler.triggerFactories = []TriggerSourceFactory{
+		func(config *profilingv1alpha1.ProfilingConfig, trackedPods func() []*corev1.Pod) TriggerSource {
+			if config.Labels["rollouts"] != "true" {
+				return nil
+			}
+			return &requestingSource{pods: trackedPods}
+		},
+	}
+
+	names := func() []string {
+		var names []string
+		for _, source := range reconciler.triggerSources(config) {
+			names = append(names, source.Name())
+		}
+		return names
+	}
+	if sources := names(); len(sources) != 1 || sources[0] != "thresholds" {
+		t.Errorf("Expected only the threshold source, got %v", sources)
+	}
+
+	config.Spec.OnDemand = &profilingv1alpha1.OnDemandConfig{Enabled: true, IntervalSeconds: 60}
+	config.Labels = map[string]string{"rollouts": "true"}
+	if sources := names(); len(sources) != 3 || sources[1] != "on-demand" || sources[2] != "rollouts" {
+		t.Errorf("Expected the on-demand and plugged-in sources after the thresholds, got %v", sources)
+	}
+
+	config.Spec.Schedules = []profilingv1alpha1.CaptureSchedule{{Cron: "0 3 * * *"}}
+	config.Spec.Webhook = &profilingv1alpha1.WebhookTriggerConfig{}
+	if sources := names(); len(sources) != 5 || sources[2] != "schedule" || sources[3] != "webhook" {
+		t.Errorf("Expected the schedule and webhook sources before the plugged-in one, got %v", sources)
+	}
+}
+
+func TestTriggerTask(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	healthy := createTestPod("healthy-pod", "default", true)
+	failing := createTestPod("failing-pod", "default", true)
+	reconciler := setupTestReconciler(config, healthy, failing)
+	reconciler.podWatcher.TrackPod(healthy, config)
+	reconciler.podWatcher.TrackPod(failing, config)
+	reconciler.podWatcher.RecordCapture(failing, "cpu", errors.New("connection refused"))
+
+	source := &requestingSource{pods: func() []*corev1.Pod { return []*corev1.Pod{healthy, failing} }}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	task := reconciler.triggerTask(config, source)
+	if task.Name != "rollouts" {
+		t.Errorf("Expected the task to be named after the source, got %s", task.Name)
+	}
+	task.Run(ctx)
+
+	// The pod backed off is dropped, the other one queued
+	if pending := reconciler.captureQueue.Pending(configKeyOf(config)); pending != 1 {
+		t.Errorf("Expected 1 queued capture, got %d", pending)
+	}
+}
+
+func TestThresholdSource_CompilesExpression(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.TriggerExpression = "cpuPercent > 80.0"
+	reconciler := setupTestReconciler(config)
+
+	source := newThresholdSource(reconciler, config)
+	if source.expression == nil || source.expressionErr != nil {
+		t.Fatalf("Expected the expression to be compiled, got %v", source.expressionErr)
+	}
+	expression := source.expression
+	source.Check(context.Background(), func(CaptureRequest) {})
+	if source.expression != expression {
+		t.Error("Expected checks to reuse the compiled expression")
+	}
+
+	// An invalid expression is reported on every check without checking pods
+	config.Spec.TriggerExpression = "cpuPercent >"
+	source = newThresholdSource(reconciler, config)
+	if source.expressionErr == nil {
+		t.Fatal("Expected a compile error")
+	}
+	interval := time.Duration(config.Spec.Thresholds.CheckIntervalSeconds) * time.Second
+	if next := source.Check(context.Background(), func(CaptureRequest) {}); next != interval {
+		t.Errorf("Expected the base interval %v, got %v", interval, next)
+	}
+}
@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// Defaults of the vpaDrift block
+const (
+	defaultVPADriftFactorPercent = 200
+	defaultVPADriftCheckInterval = 60 * time.Second
+)
+
+// vpaListGVK identifies lists of VerticalPodAutoscalers. They are read as
+// unstructured objects, so clusters without the VPA CRDs need nothing installed.
+var vpaListGVK = schema.GroupVersionKind{Group: "autoscaling.k8s.io", Version: "v1", Kind: "VerticalPodAutoscalerList"}
+
+// +kubebuilder:rbac:groups=autoscaling.k8s.io,resources=verticalpodautoscalers,verbs=get;list
+
+// verticalPodAutoscaler holds the fields of a VerticalPodAutoscaler the drift
+// trigger reads
+type verticalPodAutoscaler struct {
+	Name string `json:"-"`
+
+	Spec struct {
+		TargetRef *autoscalingv1.CrossVersionObjectReference `json:"targetRef,omitempty"`
+	} `json:"spec"`
+
+	Status struct {
+		Recommendation *vpaRecommendation `json:"recommendation,omitempty"`
+	} `json:"status"`
+}
+
+// vpaRecommendation is the recommendation of a VPA for its containers
+type vpaRecommendation struct {
+	ContainerRecommendations []vpaContainerRecommendation `json:"containerRecommendations,omitempty"`
+}
+
+// vpaContainerRecommendation is the recommendation of a VPA for a container
+type vpaContainerRecommendation struct {
+	ContainerName string              `json:"containerName"`
+	Target        corev1.ResourceList `json:"target,omitempty"`
+}
+
+// targets returns the recommended target of a container, nil if the VPA has
+// no recommendation for it yet
+func (v *verticalPodAutoscaler) targets(container string) corev1.ResourceList {
+	if v.Status.Recommendation == nil {
+		return nil
+	}
+	for _, recommendation := range v.Status.Recommendation.ContainerRecommendations {
+		if recommendation.ContainerName == container {
+			return recommendation.Target
+		}
+	}
+	return nil
+}
+
+// vpaDriftSource captures the pods whose usage drifts from the
+// recommendations of the VPA of their workload
+type vpaDriftSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+}
+
+// Name implements TriggerSource
+func (s *vpaDriftSource) Name() string { return "vpa-drift" }
+
+// Start implements TriggerSource
+func (s *vpaDriftSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	runScheduled(ctx, s, emit)
+}
+
+// InitialDelay implements ScheduledSource
+func (s *vpaDriftSource) InitialDelay() time.Duration {
+	if seconds := s.config.Spec.VPADrift.CheckIntervalSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultVPADriftCheckInterval
+}
+
+// Check implements ScheduledSource
+func (s *vpaDriftSource) Check(ctx context.Context, emit func(CaptureRequest)) time.Duration {
+	s.r.checkVPADrift(ctx, s.config, log.FromContext(ctx), emit)
+	return s.InitialDelay()
+}
+
+// checkVPADrift compares the usage of the tracked pods to the recommendations
+// of the VPAs of their workloads, emitting a capture request per pod drifting
+// out of its cooldown
+func (r *ProfilingConfigReconciler) checkVPADrift(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, logger logr.Logger, emit func(CaptureRequest)) {
+	cluster, err := r.clusterOf(config)
+	if err != nil {
+		logger.Error(err, "Failed to check VPA drift")
+		return
+	}
+
+	podsByNamespace := make(map[string][]*corev1.Pod)
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		podsByNamespace[tracked.Pod.Namespace] = append(podsByNamespace[tracked.Pod.Namespace], tracked.Pod)
+	}
+
+	for namespace, pods := range podsByNamespace {
+		vpas, err := listVPAs(ctx, cluster.reader, namespace)
+		if apimeta.IsNoMatchError(err) {
+			logger.V(1).Info("VerticalPodAutoscaler API not installed, skipping drift checks")
+			return
+		}
+		if err != nil {
+			logger.Error(err, "Failed to list VerticalPodAutoscalers", "namespace", namespace)
+			continue
+		}
+		if len(vpas) == 0 {
+			continue
+		}
+
+		podMetrics, err := cluster.metrics.ListPodMetrics(ctx, resolveMetricsSource(cluster.metrics, config), namespace, pods)
+		if err != nil {
+			logger.Error(err, "Failed to list pod metrics", "namespace", namespace)
+			continue
+		}
+
+		for _, pod := range pods {
+			vpa := vpaOfPod(vpas, pod)
+			usage, ok := podMetrics[pod.Name]
+			if vpa == nil || !ok {
+				continue
+			}
+			drifted, reason := vpaDrift(usage, vpa, config.Spec.VPADrift)
+			if !drifted {
+				continue
+			}
+
+			thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+			if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+				r.suppress(config, metrics.SuppressedCooldown)
+				continue
+			}
+			if r.nodeUnderPressure(ctx, config, pod, logger) {
+				r.suppress(config, metrics.SuppressedNodePressure)
+				continue
+			}
+
+			logger.Info("Usage drifted from VPA recommendation, capturing profile", "pod", pod.Name, "reason", reason)
+			emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: reason, Metrics: usage}, StartCooldown: true})
+		}
+	}
+}
+
+// listVPAs lists the VerticalPodAutoscalers of a namespace
+func listVPAs(ctx context.Context, reader client.Reader, namespace string) ([]*verticalPodAutoscaler, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(vpaListGVK)
+	if err := reader.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	vpas := make([]*verticalPodAutoscaler, 0, len(list.Items))
+	for _, item := range list.Items {
+		vpa := &verticalPodAutoscaler{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, vpa); err != nil {
+			return nil, fmt.Errorf("failed to decode VerticalPodAutoscaler %s: %w", item.GetName(), err)
+		}
+		vpa.Name = item.GetName()
+		vpas = append(vpas, vpa)
+	}
+	return vpas, nil
+}
+
+// vpaOfPod returns the VPA targeting the workload of a pod, nil if none does
+func vpaOfPod(vpas []*verticalPodAutoscaler, pod *corev1.Pod) *verticalPodAutoscaler {
+	for _, vpa := range vpas {
+		if target := vpa.Spec.TargetRef; target != nil && ownedBy(pod, target.Kind, target.Name) {
+			return vpa
+		}
+	}
+	return nil
+}
+
+// vpaDrift reports whether the usage of a container diverges from the target
+// recommended by a VPA by more than the configured factor, in either direction
+func vpaDrift(usage *metrics.PodMetrics, vpa *verticalPodAutoscaler, config *profilingv1alpha1.VPADriftConfig) (bool, string) {
+	factor := float64(withDefault(config.FactorPercent, defaultVPADriftFactorPercent)) / 100
+	resources := config.Resources
+	if len(resources) == 0 {
+		resources = []profilingv1alpha1.VPADriftResource{profilingv1alpha1.VPADriftCPU, profilingv1alpha1.VPADriftMemory}
+	}
+
+	for _, container := range usage.Containers {
+		targets := vpa.targets(container.Name)
+		if targets == nil {
+			continue
+		}
+		for _, name := range resources {
+			var used resource.Quantity
+			var label string
+			switch name {
+			case profilingv1alpha1.VPADriftCPU:
+				used, label = container.CPUUsage, "CPU"
+			case profilingv1alpha1.VPADriftMemory:
+				used, label = container.MemoryUsage, "memory"
+			default:
+				continue
+			}
+			target, ok := targets[corev1.ResourceName(name)]
+			if !ok || target.IsZero() || used.IsZero() {
+				continue
+			}
+
+			ratio := float64(used.MilliValue()) / float64(target.MilliValue())
+			if ratio > factor || ratio < 1/factor {
+				return true, fmt.Sprintf("Container %s %s usage %s is %.2fx the target %s of VPA %s",
+					container.Name, label, used.String(), ratio, target.String(), vpa.Name)
+			}
+		}
+	}
+	return false, ""
+}
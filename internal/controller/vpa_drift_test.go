@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// testVPA returns a VPA targeting a Deployment, recommending a target for the
+// app container
+func testVPA(cpu, memory string) *verticalPodAutoscaler {
+	vpa := &verticalPodAutoscaler{Name: "my-app"}
+	vpa.Spec.TargetRef = &autoscalingv1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "my-app"}
+	vpa.Status.Recommendation = &vpaRecommendation{
+		ContainerRecommendations: []vpaContainerRecommendation{{
+			ContainerName: "app",
+			Target: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		}},
+	}
+	return vpa
+}
+
+func TestVPAOfPod(t *testing.T) {
+	controller := true
+	pod := createTestPod("my-app-7d4b9c-x2k4p", "default", true)
+	pod.Labels["pod-template-hash"] = "7d4b9c"
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "my-app-7d4b9c", Controller: &controller}}
+
+	other := testVPA("100m", "128Mi")
+	other.Name, other.Spec.TargetRef.Name = "other", "other"
+	vpa := testVPA("100m", "128Mi")
+	if got := vpaOfPod([]*verticalPodAutoscaler{other, vpa}, pod); got != vpa {
+		t.Errorf("Expected the VPA of the Deployment, got %+v", got)
+	}
+
+	// A VPA may also target the ReplicaSet itself
+	vpa.Spec.TargetRef = &autoscalingv1.CrossVersionObjectReference{Kind: "ReplicaSet", Name: "my-app-7d4b9c"}
+	if got := vpaOfPod([]*verticalPodAutoscaler{vpa}, pod); got != vpa {
+		t.Errorf("Expected the VPA of the ReplicaSet, got %+v", got)
+	}
+
+	pod.OwnerReferences = nil
+	if got := vpaOfPod([]*verticalPodAutoscaler{vpa}, pod); got != nil {
+		t.Errorf("Expected no VPA for a pod without controller, got %+v", got)
+	}
+}
+
+func TestVPADrift(t *testing.T) {
+	usageOf := func(cpu, memory string) *metrics.PodMetrics {
+		return &metrics.PodMetrics{Containers: []metrics.ContainerMetrics{{
+			Name:        "app",
+			CPUUsage:    resource.MustParse(cpu),
+			MemoryUsage: resource.MustParse(memory),
+		}}}
+	}
+	vpa := testVPA("200m", "256Mi")
+
+	tests := []struct {
+		name      string
+		usage     *metrics.PodMetrics
+		config    profilingv1alpha1.VPADriftConfig
+		drifted   bool
+		substring string
+	}{
+		{name: "close to target", usage: usageOf("300m", "300Mi")},
+		{name: "CPU above", usage: usageOf("500m", "256Mi"), drifted: true, substring: "CPU usage 500m is 2.50x the target 200m"},
+		{name: "memory below", usage: usageOf("200m", "100Mi"), drifted: true, substring: "memory usage 100Mi is 0.39x"},
+		{
+			name:   "resource not compared",
+			usage:  usageOf("500m", "256Mi"),
+			config: profilingv1alpha1.VPADriftConfig{Resources: []profilingv1alpha1.VPADriftResource{profilingv1alpha1.VPADriftMemory}},
+		},
+		{name: "wider factor", usage: usageOf("500m", "256Mi"), config: profilingv1alpha1.VPADriftConfig{FactorPercent: 300}},
+		{name: "no recommendation", usage: &metrics.PodMetrics{Containers: []metrics.ContainerMetrics{{Name: "sidecar", CPUUsage: resource.MustParse("1")}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drifted, reason := vpaDrift(tt.usage, vpa, &tt.config)
+			if drifted != tt.drifted {
+				t.Fatalf("Expected drifted=%v, got %v (%s)", tt.drifted, drifted, reason)
+			}
+			if !strings.Contains(reason, tt.substring) {
+				t.Errorf("Expected the reason to contain %q, got %q", tt.substring, reason)
+			}
+		})
+	}
+}
+
+func TestListVPAs(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "autoscaling.k8s.io", Version: "v1", Kind: "VerticalPodAutoscaler"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+
+	object := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "my-app", "namespace": "default"},
+		"spec": map[string]any{
+			"targetRef": map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "name": "my-app"},
+		},
+		"status": map[string]any{
+			"recommendation": map[string]any{
+				"containerRecommendations": []any{
+					map[string]any{"containerName": "app", "target": map[string]any{"cpu": "200m", "memory": "256Mi"}},
+				},
+			},
+		},
+	}}
+	object.SetGroupVersionKind(gvk)
+	reader := fakeclient.NewClientBuilder().WithScheme(runtime.NewScheme()).WithRESTMapper(mapper).WithObjects(object).Build()
+
+	vpas, err := listVPAs(context.Background(), reader, "default")
+	if err != nil {
+		t.Fatalf("listVPAs returned unexpected error: %v", err)
+	}
+	if len(vpas) != 1 || vpas[0].Name != "my-app" || vpas[0].Spec.TargetRef.Kind != "Deployment" {
+		t.Fatalf("Unexpected VPAs %+v", vpas)
+	}
+	if target := vpas[0].targets("app"); target.Memory().String() != "256Mi" {
+		t.Errorf("Expected the memory target of the app container, got %v", target)
+	}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+const (
+	// defaultWebIdentityAudience is the audience of the service account tokens
+	// exchanged with STS when a config sets none
+	defaultWebIdentityAudience = "sts.amazonaws.com"
+
+	// roleARNAnnotation names the role of a service account for IRSA, assumed
+	// when a config sets no role
+	roleARNAnnotation = "eks.amazonaws.com/role-arn"
+
+	// webIdentityTokenExpiry is the lifetime requested for service account
+	// tokens, which are only used for a single exchange with STS
+	webIdentityTokenExpiry = time.Hour
+
+	// webIdentityRefreshInterval is how often the role of a config is looked
+	// up again, following changes to the annotation of its service account
+	webIdentityRefreshInterval = time.Hour
+
+	// maxRoleSessionNameLength is the longest role session name STS accepts
+	maxRoleSessionNameLength = 64
+)
+
+// webIdentities caches the web identity credentials of each config, so that
+// the tokens and role credentials are reused across uploads until they
+// expire. The zero value is ready to use.
+type webIdentities struct {
+	mu sync.Mutex
+
+	// credentials holds the credentials and what they were set up for, by
+	// config
+	credentials map[string]webIdentityCredentials
+}
+
+// webIdentityCredentials are the credentials of the web identity of a config
+type webIdentityCredentials struct {
+	webIdentity profilingv1alpha1.WebIdentityConfig
+	region      string
+	provider    aws.CredentialsProvider
+	at          time.Time
+}
+
+// get returns the cached credentials of a config if they were set up for the
+// same web identity and region within the refresh interval
+func (w *webIdentities) get(configKey string, webIdentity profilingv1alpha1.WebIdentityConfig, region string, now time.Time) (aws.CredentialsProvider, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cached, ok := w.credentials[configKey]
+	if !ok || cached.webIdentity != webIdentity || cached.region != region || now.Sub(cached.at) >= webIdentityRefreshInterval {
+		return nil, false
+	}
+	return cached.provider, true
+}
+
+// put caches the credentials of a config
+func (w *webIdentities) put(configKey string, credentials webIdentityCredentials) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.credentials == nil {
+		w.credentials = make(map[string]webIdentityCredentials)
+	}
+	w.credentials[configKey] = credentials
+}
+
+// forget drops the credentials of a config
+func (w *webIdentities) forget(configKey string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.credentials, configKey)
+}
+
+// validateWebIdentity validates the web identity block of a config
+func validateWebIdentity(config *profilingv1alpha1.ProfilingConfig) error {
+	webIdentity := config.Spec.S3Config.WebIdentity
+	if webIdentity == nil {
+		return nil
+	}
+	if webIdentity.ServiceAccountName == "" {
+		return fmt.Errorf("s3 webIdentity serviceAccountName is required")
+	}
+	if webIdentity.RoleARN != "" && !strings.HasPrefix(webIdentity.RoleARN, "arn:") {
+		return fmt.Errorf("s3 webIdentity roleARN %q is not an ARN", webIdentity.RoleARN)
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+
+// webIdentityCredentialsOf returns the credentials of the role of a config,
+// assumed with the tokens of its service account
+func (r *ProfilingConfigReconciler) webIdentityCredentialsOf(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, s3Config uploader.S3Config) (aws.CredentialsProvider, error) {
+	webIdentity := *config.Spec.S3Config.WebIdentity
+	configKey := configKeyOf(config)
+	now := time.Now()
+	if provider, ok := r.webIdentities.get(configKey, webIdentity, s3Config.Region, now); ok {
+		return provider, nil
+	}
+
+	roleARN := webIdentity.RoleARN
+	if roleARN == "" {
+		serviceAccount, err := r.Clientset.CoreV1().ServiceAccounts(config.Namespace).Get(ctx, webIdentity.ServiceAccountName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get service account %s: %w", webIdentity.ServiceAccountName, err)
+		}
+		roleARN = serviceAccount.Annotations[roleARNAnnotation]
+		if roleARN == "" {
+			return nil, fmt.Errorf("service account %s has no %s annotation", webIdentity.ServiceAccountName, roleARNAnnotation)
+		}
+	}
+
+	provider, err := uploader.WebIdentityCredentials(ctx, s3Config, roleARN, roleSessionName(config),
+		r.serviceAccountToken(config.Namespace, webIdentity.ServiceAccountName, withDefault(webIdentity.Audience, defaultWebIdentityAudience)))
+	if err != nil {
+		return nil, err
+	}
+	r.webIdentities.put(configKey, webIdentityCredentials{
+		webIdentity: webIdentity,
+		region:      s3Config.Region,
+		provider:    provider,
+		at:          now,
+	})
+	return provider, nil
+}
+
+// serviceAccountToken returns a function requesting a token of a service
+// account for an audience
+func (r *ProfilingConfigReconciler) serviceAccountToken(namespace, name, audience string) uploader.IdentityTokenFunc {
+	return func(ctx context.Context) ([]byte, error) {
+		expirationSeconds := int64(webIdentityTokenExpiry / time.Second)
+		token, err := r.Clientset.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences:         []string{audience},
+				ExpirationSeconds: &expirationSeconds,
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to request token of service account %s: %w", name, err)
+		}
+		return []byte(token.Status.Token), nil
+	}
+}
+
+// roleSessionName returns the name of the role sessions of a config, which
+// identifies it in CloudTrail
+func roleSessionName(config *profilingv1alpha1.ProfilingConfig) string {
+	name := fmt.Sprintf("bolometer-%s-%s", config.Namespace, config.Name)
+	if len(name) > maxRoleSessionNameLength {
+		name = name[:maxRoleSessionNameLength]
+	}
+	return name
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func TestWebIdentities(t *testing.T) {
+	var identities webIdentities
+	webIdentity := profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "profiler"}
+	now := time.Now()
+	identities.put("default/config", webIdentityCredentials{webIdentity: webIdentity, region: "us-west-2", at: now})
+
+	if _, ok := identities.get("default/config", webIdentity, "us-west-2", now.Add(time.Minute)); !ok {
+		t.Error("Expected the cached credentials")
+	}
+	changed := webIdentity
+	changed.Audience = "example.com"
+	for name, ok := range map[string]bool{
+		"changed":      func() bool { _, ok := identities.get("default/config", changed, "us-west-2", now); return ok }(),
+		"other region": func() bool { _, ok := identities.get("default/config", webIdentity, "eu-west-1", now); return ok }(),
+		"stale": func() bool {
+			_, ok := identities.get("default/config", webIdentity, "us-west-2", now.Add(time.Hour))
+			return ok
+		}(),
+		"other config": func() bool { _, ok := identities.get("default/other", webIdentity, "us-west-2", now); return ok }(),
+	} {
+		if ok {
+			t.Errorf("Expected no cached credentials when %s", name)
+		}
+	}
+
+	identities.forget("default/config")
+	if _, ok := identities.get("default/config", webIdentity, "us-west-2", now); ok {
+		t.Error("Expected the credentials of a forgotten config to be dropped")
+	}
+}
+
+func TestValidateWebIdentity(t *testing.T) {
+	for name, test := range map[string]struct {
+		webIdentity *profilingv1alpha1.WebIdentityConfig
+		valid       bool
+	}{
+		"unset":           {nil, true},
+		"annotated role":  {&profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "profiler"}, true},
+		"role":            {&profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "profiler", RoleARN: "arn:aws:iam::111122223333:role/profiler"}, true},
+		"no account":      {&profilingv1alpha1.WebIdentityConfig{}, false},
+		"role not an ARN": {&profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "profiler", RoleARN: "profiler"}, false},
+	} {
+		config := createTestProfilingConfig("test-config", "default")
+		config.Spec.S3Config.WebIdentity = test.webIdentity
+		if err := validateWebIdentity(config); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %v, got %v", name, test.valid, err)
+		}
+	}
+}
+
+func TestRoleSessionName(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	if name := roleSessionName(config); name != "bolometer-default-test-config" {
+		t.Errorf("Unexpected role session name %q", name)
+	}
+
+	config.Name = strings.Repeat("a", 80)
+	if name := roleSessionName(config); len(name) != maxRoleSessionNameLength {
+		t.Errorf("Expected the role session name to be truncated, got %q", name)
+	}
+}
+
+func TestStorageConfigOf_WebIdentity(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.S3Config.WebIdentity = &profilingv1alpha1.WebIdentityConfig{ServiceAccountName: "profiler"}
+	reconciler := setupTestReconciler(config)
+	clientset := fake.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "profiler", Namespace: "default"},
+	})
+	reconciler.Clientset = clientset
+	ctx := context.Background()
+
+	if _, err := reconciler.storageConfigOf(ctx, config); err == nil || !strings.Contains(err.Error(), roleARNAnnotation) {
+		t.Errorf("Expected an error for a service account without a role, got %v", err)
+	}
+
+	serviceAccount, _ := clientset.CoreV1().ServiceAccounts("default").Get(ctx, "profiler", metav1.GetOptions{})
+	serviceAccount.Annotations = map[string]string{roleARNAnnotation: "arn:aws:iam::111122223333:role/profiler"}
+	if _, err := clientset.CoreV1().ServiceAccounts("default").Update(ctx, serviceAccount, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to annotate service account: %v", err)
+	}
+	s3Config, err := reconciler.storageConfigOf(ctx, config)
+	if err != nil {
+		t.Fatalf("storageConfigOf returned unexpected error: %v", err)
+	}
+	if s3Config.Credentials == nil {
+		t.Fatal("Expected the credentials of the web identity")
+	}
+
+	// The credentials are reused across uploads
+	again, err := reconciler.storageConfigOf(ctx, config)
+	if err != nil || again.Credentials != s3Config.Credentials {
+		t.Errorf("Expected the cached credentials, got %v", err)
+	}
+
+	// Without a web identity the operator's own credentials are used
+	config.Spec.S3Config.WebIdentity = nil
+	if s3Config, err := reconciler.storageConfigOf(ctx, config); err != nil || s3Config.Credentials != nil {
+		t.Errorf("Expected no credentials, got %v (%v)", s3Config.Credentials, err)
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	reconciler := setupTestReconciler()
+	clientset := fake.NewSimpleClientset()
+	var request *authenticationv1.TokenRequest
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		request = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: "jwt"}}, nil
+	})
+	reconciler.Clientset = clientset
+
+	token, err := reconciler.serviceAccountToken("default", "profiler", defaultWebIdentityAudience)(context.Background())
+	if err != nil {
+		t.Fatalf("Token request returned unexpected error: %v", err)
+	}
+	if string(token) != "jwt" {
+		t.Errorf("Expected the issued token, got %q", token)
+	}
+	if len(request.Spec.Audiences) != 1 || request.Spec.Audiences[0] != defaultWebIdentityAudience {
+		t.Errorf("Expected a token for the STS audience, got %v", request.Spec.Audiences)
+	}
+	if request.Spec.ExpirationSeconds == nil || *request.Spec.ExpirationSeconds != 3600 {
+		t.Errorf("Expected a token valid for an hour, got %v", request.Spec.ExpirationSeconds)
+	}
+}
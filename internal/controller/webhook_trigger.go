@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+)
+
+// AlertEventReason is the reason of the Events recording the alerts posted to
+// the webhook of a config. The HTTP API records them on the config, so that
+// whichever replica monitors the config captures the pods they name.
+const AlertEventReason = "BolometerAlert"
+
+// AlertPodAnnotation holds the key of the pod an alert Event names:
+// namespace/name, prefixed with the cluster for pods of a remote cluster
+const AlertPodAnnotation = "bolometer.io/alert-pod"
+
+// WebhookAccepts reports whether the webhook of a config captures the pods
+// named by an alert
+func WebhookAccepts(config *profilingv1alpha1.ProfilingConfig, alertName string) bool {
+	webhook := config.Spec.Webhook
+	return webhook != nil && (len(webhook.Alerts) == 0 || slices.Contains(webhook.Alerts, alertName))
+}
+
+// NewAlertEvent builds the Event recording, on a config, an alert firing for a
+// pod of the cluster the config profiles
+func NewAlertEvent(config *profilingv1alpha1.ProfilingConfig, alertName, namespace, name string) *corev1.Event {
+	var cluster string
+	if config.Spec.Cluster != nil {
+		cluster = config.Spec.Cluster.Name
+	}
+	key := podKey(cluster, namespace, name)
+
+	now := metav1.Now()
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: config.Name + "-alert-",
+			Namespace:    config.Namespace,
+			Annotations:  map[string]string{AlertPodAnnotation: key},
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: profilingv1alpha1.GroupVersion.String(),
+			Kind:       "ProfilingConfig",
+			Namespace:  config.Namespace,
+			Name:       config.Name,
+			UID:        config.UID,
+		},
+		Reason:         AlertEventReason,
+		Message:        fmt.Sprintf("Alert %s firing for pod %s", alertName, key),
+		Type:           corev1.EventTypeNormal,
+		Source:         corev1.EventSource{Component: "bolometer-api"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
+
+// webhookSource captures the pods named by the alerts posted to the webhook of
+// a config, watching the alert Events the HTTP API records on the config
+type webhookSource struct {
+	r      *ProfilingConfigReconciler
+	config *profilingv1alpha1.ProfilingConfig
+}
+
+// Name implements TriggerSource
+func (s *webhookSource) Name() string { return "webhook" }
+
+// Start implements TriggerSource. Alert Events are recorded in the cluster the
+// operator runs in, whatever the config's cluster.
+func (s *webhookSource) Start(ctx context.Context, emit func(CaptureRequest)) {
+	logger := log.FromContext(ctx)
+
+	// Alerts posted before the source started were acted on, or missed, by
+	// the previous one
+	started := time.Now()
+
+	watchEvents(ctx, s.r.localCluster().clientset, AlertEventReason, logger, func(event *corev1.Event) {
+		object := event.InvolvedObject
+		if eventTime(event).Before(started) || object.Kind != "ProfilingConfig" ||
+			object.Namespace != s.config.Namespace || object.Name != s.config.Name {
+			return
+		}
+		s.r.captureForAlert(ctx, s.config, event, logger, emit)
+	})
+}
+
+// captureForAlert emits a capture request for the tracked pod an alert Event
+// names, unless it is in cooldown
+func (r *ProfilingConfigReconciler) captureForAlert(ctx context.Context, config *profilingv1alpha1.ProfilingConfig, event *corev1.Event, logger logr.Logger, emit func(CaptureRequest)) {
+	key := event.Annotations[AlertPodAnnotation]
+	var pod *corev1.Pod
+	for _, tracked := range r.podWatcher.GetTrackedPodsForConfig(configKeyOf(config)) {
+		if r.podWatcher.getPodKey(tracked.Pod) == key {
+			pod = tracked.Pod
+		}
+	}
+	if pod == nil {
+		logger.V(1).Info("Alert names a pod not profiled by the config", "pod", key)
+		return
+	}
+
+	thresholds, _ := podThresholds(pod, config.Spec.Thresholds)
+	if !r.podWatcher.CanProfile(pod, thresholds.CooldownSeconds) {
+		r.suppress(config, metrics.SuppressedCooldown)
+		return
+	}
+	if r.nodeUnderPressure(ctx, config, pod, logger) {
+		r.suppress(config, metrics.SuppressedNodePressure)
+		return
+	}
+
+	logger.Info("Alert firing, capturing profile", "pod", pod.Name)
+	emit(CaptureRequest{Pod: pod, Trigger: metrics.Trigger{Reason: event.Message}, StartCooldown: true})
+}
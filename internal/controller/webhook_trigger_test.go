@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func TestWebhookAccepts(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	if WebhookAccepts(config, "HighLatency") {
+		t.Error("Expected no alert accepted without a webhook")
+	}
+
+	config.Spec.Webhook = &profilingv1alpha1.WebhookTriggerConfig{}
+	if !WebhookAccepts(config, "HighLatency") {
+		t.Error("Expected every alert accepted without alert names")
+	}
+
+	config.Spec.Webhook.Alerts = []string{"HighLatency"}
+	if !WebhookAccepts(config, "HighLatency") || WebhookAccepts(config, "DiskFull") {
+		t.Error("Expected only the listed alerts accepted")
+	}
+}
+
+func TestWebhookSource(t *testing.T) {
+	config := createTestProfilingConfig("test-config", "default")
+	config.Spec.Webhook = &profilingv1alpha1.WebhookTriggerConfig{}
+	other := createTestProfilingConfig("other-config", "default")
+	pod := createTestPod("test-pod", "default", true)
+	reconciler := setupTestReconciler(config, pod)
+	reconciler.podWatcher.TrackPod(pod, config)
+	clientset := fake.NewSimpleClientset()
+	reconciler.Clientset = clientset
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	requests := make(chan CaptureRequest, 2)
+	go (&webhookSource{r: reconciler, config: config}).Start(ctx, func(request CaptureRequest) { requests <- request })
+
+	// Wait for the watch, as the fake clientset does not replay Events
+	deadline := time.Now().Add(5 * time.Second)
+	for !hasAction(clientset, "watch") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the Event watch")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Alerts of other configs and for untracked pods capture nothing
+	for i, event := range []*corev1.Event{
+		NewAlertEvent(other, "HighLatency", "default", pod.Name),
+		NewAlertEvent(config, "HighLatency", "default", "untracked"),
+		NewAlertEvent(config, "HighLatency", "default", pod.Name),
+	} {
+		event.Name = event.GenerateName + string(rune('a'+i))
+		if _, err := clientset.CoreV1().Events("default").Create(ctx, event, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Failed to create Event: %v", err)
+		}
+	}
+
+	select {
+	case request := <-requests:
+		if request.Pod.Name != pod.Name || !request.StartCooldown || request.Trigger.Reason != "Alert HighLatency firing for pod default/test-pod" {
+			t.Errorf("Unexpected capture request %+v", request)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the capture request")
+	}
+	select {
+	case request := <-requests:
+		t.Errorf("Expected a single capture request, got another for %s", request.Pod.Name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ownedBy reports whether a pod belongs to a workload, as named by the target
+// references of autoscalers: its controller, or the Deployment of its ReplicaSet
+func ownedBy(pod *corev1.Pod, kind, name string) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return false
+	}
+	if owner.Kind == kind && owner.Name == name {
+		return true
+	}
+	workloadKind, workloadName := workloadOf(pod, owner.Kind, owner.Name)
+	return workloadKind == kind && workloadName == name
+}
+
+// workloadOf returns the workload owning a pod through its controller. Pods
+// of a ReplicaSet created by a Deployment belong to the Deployment, whose name
+// the ReplicaSet's carries before the pod template hash.
+func workloadOf(pod *corev1.Pod, ownerKind, ownerName string) (string, string) {
+	hash := pod.Labels["pod-template-hash"]
+	if ownerKind == "ReplicaSet" && hash != "" && strings.HasSuffix(ownerName, "-"+hash) {
+		return "Deployment", strings.TrimSuffix(ownerName, "-"+hash)
+	}
+	return ownerKind, ownerName
+}
@@ -0,0 +1,153 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+// CapturesPath is the path the index is queried on
+const CapturesPath = "/captures"
+
+// ErrForbidden is returned when the caller may not read the captures of the
+// requested namespace
+var ErrForbidden = errors.New("forbidden")
+
+// Authorizer decides which captures the caller of a request may read
+type Authorizer interface {
+	// CanReadCaptures reports whether the caller may read the captures of a
+	// namespace, or of every namespace when it is empty
+	CanReadCaptures(req *http.Request, namespace string) (bool, error)
+}
+
+// Authorize restricts a filter to the namespaces the caller of a request may
+// read the captures of. It returns ErrForbidden if the filter selects a
+// namespace the caller may not read.
+func (i *Index) Authorize(req *http.Request, auth Authorizer, filter *Filter) error {
+	if filter.Namespace != "" {
+		allowed, err := auth.CanReadCaptures(req, filter.Namespace)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrForbidden
+		}
+		return nil
+	}
+
+	if allowed, err := auth.CanReadCaptures(req, ""); err != nil || allowed {
+		return err
+	}
+	namespaces, err := i.Namespaces(req.Context())
+	if err != nil {
+		return err
+	}
+	filter.Namespaces = []string{}
+	for _, namespace := range namespaces {
+		allowed, err := auth.CanReadCaptures(req, namespace)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			filter.Namespaces = append(filter.Namespaces, namespace)
+		}
+	}
+	return nil
+}
+
+// Handler serves the captures matching the query parameters as JSON:
+// namespace, service, pod, config, type, reason, triggeredBy, outcome, since
+// and until as RFC 3339 times or durations back from now, and limit. Only the
+// captures of the namespaces the caller may read are served.
+type Handler struct {
+	index *Index
+	auth  Authorizer
+}
+
+// NewHandler creates a new handler querying the given index, authorizing
+// callers with auth
+func NewHandler(index *Index, auth Authorizer) *Handler {
+	return &Handler{
+		index: index,
+		auth:  auth,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := ParseFilter(req.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.index.Authorize(req, h.auth, &filter); err != nil {
+		if errors.Is(err, ErrForbidden) {
+			http.Error(w, "cannot read the captures of namespace "+filter.Namespace, http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	captures, err := h.index.Query(req.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(captures); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ParseFilter builds a filter from the query parameters of a request
+func ParseFilter(query url.Values, now time.Time) (Filter, error) {
+	filter := Filter{
+		Namespace:   query.Get("namespace"),
+		Service:     query.Get("service"),
+		Pod:         query.Get("pod"),
+		Config:      query.Get("config"),
+		ProfileType: query.Get("type"),
+		Reason:      query.Get("reason"),
+		TriggeredBy: query.Get("triggeredBy"),
+		Outcome:     audit.Outcome(query.Get("outcome")),
+	}
+
+	var err error
+	if filter.Since, err = ParseTime(query.Get("since"), now); err != nil {
+		return Filter{}, fmt.Errorf("invalid since: %w", err)
+	}
+	if filter.Until, err = ParseTime(query.Get("until"), now); err != nil {
+		return Filter{}, fmt.Errorf("invalid until: %w", err)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return Filter{}, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+	return filter, nil
+}
+
+// ParseTime parses an RFC 3339 time, or a duration back from now such as 24h.
+// An empty value is the zero time.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,438 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Database drivers selectable with --index-driver
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+// Supported index databases
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Query limits
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Capture is an indexed capture attempt
+type Capture struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	Capture     string    `json:"capture,omitempty"`
+	Config      string    `json:"config"`
+	TriggeredBy string    `json:"triggeredBy"`
+	Reason      string    `json:"reason"`
+
+	// CPUUsagePercent and MemoryUsagePercent are the usage that triggered a
+	// threshold capture
+	CPUUsagePercent    *float64 `json:"cpuUsagePercent,omitempty"`
+	MemoryUsagePercent *float64 `json:"memoryUsagePercent,omitempty"`
+
+	Pod     audit.Pod `json:"pod"`
+	Service string    `json:"service,omitempty"`
+
+	Bucket   string          `json:"bucket"`
+	Manifest string          `json:"manifest,omitempty"`
+	Profiles []audit.Profile `json:"profiles"`
+
+	Outcome         audit.Outcome `json:"outcome"`
+	Error           string        `json:"error,omitempty"`
+	DurationSeconds float64       `json:"durationSeconds"`
+}
+
+// Filter selects captures. Empty fields match every capture.
+type Filter struct {
+	// ID selects a single capture
+	ID int64
+
+	Namespace   string
+	Service     string
+	Pod         string
+	Config      string
+	TriggeredBy string
+	Outcome     audit.Outcome
+
+	// ProfileType selects the captures with a profile of this type, and only
+	// lists their profiles of this type
+	ProfileType string
+
+	// Reason selects the captures whose reason contains it, ignoring case,
+	// e.g. memory
+	Reason string
+
+	// Since and Until bound the capture time, [Since, Until)
+	Since time.Time
+	Until time.Time
+
+	// Limit is the maximum number of captures returned, most recent first
+	Limit int
+
+	// Namespaces restricts the captures to those of pods in these namespaces,
+	// the namespaces the caller may read. Nil does not restrict them; empty
+	// matches no capture.
+	Namespaces []string
+}
+
+// Index stores every capture attempt in a SQL database it can be queried from.
+// It is an audit sink, so it sees the same records as the audit log.
+type Index struct {
+	db     *sql.DB
+	driver string
+}
+
+// Open connects to the index database and creates its tables if needed. dsn
+// is a file path for SQLite and a connection string for Postgres.
+func Open(ctx context.Context, driver, dsn string) (*Index, error) {
+	var sqlDriver string
+	switch driver {
+	case DriverSQLite:
+		sqlDriver = "sqlite"
+	case DriverPostgres:
+		sqlDriver = "pgx"
+	default:
+		return nil, fmt.Errorf("unsupported index driver %q, must be %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+
+	db, err := sql.Open(sqlDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture index: %w", err)
+	}
+	if driver == DriverSQLite {
+		// SQLite allows a single writer
+		db.SetMaxOpenConns(1)
+	}
+
+	index := &Index{db: db, driver: driver}
+	if err := index.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return index, nil
+}
+
+// Close closes the database
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// migrate creates the tables and indexes of the index
+func (i *Index) migrate(ctx context.Context) error {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if i.driver == DriverPostgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS captures (
+			id ` + id + `,
+			captured_at TIMESTAMP NOT NULL,
+			capture TEXT NOT NULL,
+			config TEXT NOT NULL,
+			triggered_by TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			cpu_usage_percent DOUBLE PRECISION,
+			memory_usage_percent DOUBLE PRECISION,
+			cluster TEXT NOT NULL,
+			namespace TEXT NOT NULL,
+			pod TEXT NOT NULL,
+			pod_uid TEXT NOT NULL,
+			node TEXT NOT NULL,
+			service TEXT NOT NULL,
+			bucket TEXT NOT NULL,
+			manifest TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			error TEXT NOT NULL,
+			duration_seconds DOUBLE PRECISION NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS captures_service_time ON captures (service, captured_at)`,
+		`CREATE INDEX IF NOT EXISTS captures_namespace_time ON captures (namespace, captured_at)`,
+		`CREATE TABLE IF NOT EXISTS profiles (
+			capture_id BIGINT NOT NULL REFERENCES captures (id) ON DELETE CASCADE,
+			type TEXT NOT NULL,
+			object_key TEXT NOT NULL,
+			size_bytes BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS profiles_capture ON profiles (capture_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := i.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create capture index tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write indexes a capture attempt and its profiles
+func (i *Index) Write(ctx context.Context, record audit.Record) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to index capture: %w", err)
+	}
+	// Rolling back after the commit is a no-op
+	defer tx.Rollback()
+
+	var cpu, memory *float64
+	if record.Trigger != nil {
+		cpu, memory = &record.Trigger.CPUUsagePercent, &record.Trigger.MemoryUsagePercent
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, i.rebind(`INSERT INTO captures (
+			captured_at, capture, config, triggered_by, reason, cpu_usage_percent, memory_usage_percent,
+			cluster, namespace, pod, pod_uid, node, service, bucket, manifest, outcome, error, duration_seconds
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		record.Time.UTC(), record.Capture, record.Config, record.TriggeredBy, record.Reason, cpu, memory,
+		record.Pod.Cluster, record.Pod.Namespace, record.Pod.Name, record.Pod.UID, record.Pod.Node, record.Service,
+		record.Bucket, record.Manifest, string(record.Outcome), record.Error, record.DurationSeconds,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to index capture: %w", err)
+	}
+
+	for _, profile := range record.Profiles {
+		_, err := tx.ExecContext(ctx, i.rebind(`INSERT INTO profiles (capture_id, type, object_key, size_bytes) VALUES (?, ?, ?, ?)`),
+			id, profile.Type, profile.Key, profile.SizeBytes)
+		if err != nil {
+			return fmt.Errorf("failed to index %s profile: %w", profile.Type, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to index capture: %w", err)
+	}
+	return nil
+}
+
+// Query returns the captures matching a filter, most recent first
+func (i *Index) Query(ctx context.Context, filter Filter) ([]Capture, error) {
+	where, args := filter.where()
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	rows, err := i.db.QueryContext(ctx, i.rebind(`SELECT
+			id, captured_at, capture, config, triggered_by, reason, cpu_usage_percent, memory_usage_percent,
+			cluster, namespace, pod, pod_uid, node, service, bucket, manifest, outcome, error, duration_seconds
+		FROM captures`+where+` ORDER BY captured_at DESC, id DESC LIMIT `+strconv.Itoa(limit)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query capture index: %w", err)
+	}
+	defer rows.Close()
+
+	captures := []Capture{}
+	byID := make(map[int64]int)
+	for rows.Next() {
+		var c Capture
+		var outcome string
+		err := rows.Scan(&c.ID, &c.Time, &c.Capture, &c.Config, &c.TriggeredBy, &c.Reason, &c.CPUUsagePercent, &c.MemoryUsagePercent,
+			&c.Pod.Cluster, &c.Pod.Namespace, &c.Pod.Name, &c.Pod.UID, &c.Pod.Node, &c.Service, &c.Bucket, &c.Manifest,
+			&outcome, &c.Error, &c.DurationSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read capture index: %w", err)
+		}
+		c.Outcome = audit.Outcome(outcome)
+		c.Profiles = []audit.Profile{}
+		byID[c.ID] = len(captures)
+		captures = append(captures, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture index: %w", err)
+	}
+
+	if err := i.loadProfiles(ctx, captures, byID, filter.ProfileType); err != nil {
+		return nil, err
+	}
+	return captures, nil
+}
+
+// Service is a service with captures in the index
+type Service struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// Captures is the number of matching captures of the service
+	Captures int `json:"captures"`
+
+	// LastCapture is the time of its most recent matching capture
+	LastCapture time.Time `json:"lastCapture"`
+}
+
+// Services returns the services with captures matching a filter, by namespace
+// and name. The limit of the filter is ignored.
+func (i *Index) Services(ctx context.Context, filter Filter) ([]Service, error) {
+	where, args := filter.where()
+
+	// Captures are written in order, so the highest id of a service is its
+	// most recent capture
+	rows, err := i.db.QueryContext(ctx, i.rebind(`SELECT captures.namespace, captures.service, services.captures, captures.captured_at
+		FROM captures JOIN (
+			SELECT MAX(id) AS last_id, COUNT(*) AS captures FROM captures`+where+` GROUP BY namespace, service
+		) services ON captures.id = services.last_id
+		ORDER BY captures.namespace, captures.service`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query capture index: %w", err)
+	}
+	defer rows.Close()
+
+	services := []Service{}
+	for rows.Next() {
+		var s Service
+		if err := rows.Scan(&s.Namespace, &s.Name, &s.Captures, &s.LastCapture); err != nil {
+			return nil, fmt.Errorf("failed to read capture index: %w", err)
+		}
+		services = append(services, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture index: %w", err)
+	}
+	return services, nil
+}
+
+// Namespaces returns the namespaces with captures in the index
+func (i *Index) Namespaces(ctx context.Context) ([]string, error) {
+	rows, err := i.db.QueryContext(ctx, `SELECT DISTINCT namespace FROM captures ORDER BY namespace`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query capture index: %w", err)
+	}
+	defer rows.Close()
+
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, fmt.Errorf("failed to read capture index: %w", err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture index: %w", err)
+	}
+	return namespaces, nil
+}
+
+// loadProfiles fills in the profiles of the captures, only those of
+// profileType if set
+func (i *Index) loadProfiles(ctx context.Context, captures []Capture, byID map[int64]int, profileType string) error {
+	if len(captures) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, 0, len(captures))
+	args := make([]any, 0, len(captures)+1)
+	for _, c := range captures {
+		placeholders = append(placeholders, "?")
+		args = append(args, c.ID)
+	}
+	query := `SELECT capture_id, type, object_key, size_bytes FROM profiles WHERE capture_id IN (` + strings.Join(placeholders, ", ") + `)`
+	if profileType != "" {
+		query += ` AND type = ?`
+		args = append(args, profileType)
+	}
+
+	rows, err := i.db.QueryContext(ctx, i.rebind(query+` ORDER BY capture_id, type`), args...)
+	if err != nil {
+		return fmt.Errorf("failed to query capture index: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var p audit.Profile
+		if err := rows.Scan(&id, &p.Type, &p.Key, &p.SizeBytes); err != nil {
+			return fmt.Errorf("failed to read capture index: %w", err)
+		}
+		c := &captures[byID[id]]
+		c.Profiles = append(c.Profiles, p)
+	}
+	return rows.Err()
+}
+
+// where builds the WHERE clause of a filter, with ? placeholders
+func (f Filter) where() (string, []any) {
+	var conditions []string
+	var args []any
+	equal := func(column, value string) {
+		if value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	if f.ID != 0 {
+		conditions = append(conditions, "id = ?")
+		args = append(args, f.ID)
+	}
+	equal("namespace", f.Namespace)
+	if f.Namespaces != nil {
+		if len(f.Namespaces) == 0 {
+			conditions = append(conditions, "1 = 0")
+		} else {
+			placeholders := make([]string, 0, len(f.Namespaces))
+			for _, namespace := range f.Namespaces {
+				placeholders = append(placeholders, "?")
+				args = append(args, namespace)
+			}
+			conditions = append(conditions, "namespace IN ("+strings.Join(placeholders, ", ")+")")
+		}
+	}
+	equal("service", f.Service)
+	equal("pod", f.Pod)
+	equal("config", f.Config)
+	equal("triggered_by", f.TriggeredBy)
+	equal("outcome", string(f.Outcome))
+	if f.Reason != "" {
+		conditions = append(conditions, "LOWER(reason) LIKE ?")
+		args = append(args, "%"+strings.ToLower(f.Reason)+"%")
+	}
+	if f.ProfileType != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM profiles WHERE profiles.capture_id = captures.id AND profiles.type = ?)")
+		args = append(args, f.ProfileType)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "captured_at >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "captured_at < ?")
+		args = append(args, f.Until.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// rebind rewrites ? placeholders into the numbered placeholders of Postgres
+func (i *Index) rebind(query string) string {
+	if i.driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,276 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func testIndex(t *testing.T) *Index {
+	t.Helper()
+
+	index, err := Open(context.Background(), DriverSQLite, filepath.Join(t.TempDir(), "captures.db"))
+	if err != nil {
+		t.Fatalf("Open returned unexpected error: %v", err)
+	}
+	t.Cleanup(func() { index.Close() })
+	return index
+}
+
+func testRecord(pod, reason string, at time.Time, profileTypes ...string) audit.Record {
+	record := audit.Record{
+		Time:        at,
+		Config:      "default/my-app",
+		TriggeredBy: "threshold",
+		Reason:      reason,
+		Trigger:     &uploader.TriggerValues{CPUUsagePercent: 20, MemoryUsagePercent: 95},
+		Pod:         audit.Pod{Namespace: "default", Name: pod, Node: "node-1"},
+		Service:     "my-app",
+		Bucket:      "my-bucket",
+		Outcome:     audit.OutcomeSucceeded,
+	}
+	for _, profileType := range profileTypes {
+		record.Profiles = append(record.Profiles, audit.Profile{
+			Type:      profileType,
+			Key:       "profiles/" + pod + "-" + profileType + ".pprof",
+			SizeBytes: 1024,
+		})
+	}
+	return record
+}
+
+func TestIndex_Query(t *testing.T) {
+	ctx := context.Background()
+	index := testIndex(t)
+	tuesday := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+
+	for _, record := range []audit.Record{
+		testRecord("my-app-1", "Memory usage 95.00% exceeds threshold 90%", tuesday, "heap", "goroutine"),
+		testRecord("my-app-2", "CPU usage 95.00% exceeds threshold 80%", tuesday.Add(time.Hour), "heap", "cpu"),
+		testRecord("my-app-1", "Memory usage 92.00% exceeds threshold 90%", tuesday.Add(24*time.Hour), "heap"),
+	} {
+		if err := index.Write(ctx, record); err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+
+	captures, err := index.Query(ctx, Filter{
+		Service:     "my-app",
+		ProfileType: "heap",
+		Reason:      "memory",
+		Since:       tuesday,
+		Until:       tuesday.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Query returned unexpected error: %v", err)
+	}
+
+	if len(captures) != 1 {
+		t.Fatalf("Expected 1 capture, got %+v", captures)
+	}
+	capture := captures[0]
+	if capture.Pod.Name != "my-app-1" || !capture.Time.Equal(tuesday) || capture.Outcome != audit.OutcomeSucceeded {
+		t.Errorf("Unexpected capture %+v", capture)
+	}
+	if capture.MemoryUsagePercent == nil || *capture.MemoryUsagePercent != 95 {
+		t.Errorf("Expected the triggering memory usage, got %v", capture.MemoryUsagePercent)
+	}
+	if len(capture.Profiles) != 1 || capture.Profiles[0].Key != "profiles/my-app-1-heap.pprof" {
+		t.Errorf("Expected only the heap profile, got %+v", capture.Profiles)
+	}
+}
+
+func TestIndex_QueryLimit(t *testing.T) {
+	ctx := context.Background()
+	index := testIndex(t)
+	start := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if err := index.Write(ctx, testRecord("my-app-1", "cpu", start.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+
+	captures, err := index.Query(ctx, Filter{Limit: 2})
+	if err != nil {
+		t.Fatalf("Query returned unexpected error: %v", err)
+	}
+	if len(captures) != 2 || !captures[0].Time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected the 2 most recent captures, got %+v", captures)
+	}
+
+	captures, err = index.Query(ctx, Filter{ID: captures[1].ID})
+	if err != nil {
+		t.Fatalf("Query returned unexpected error: %v", err)
+	}
+	if len(captures) != 1 || !captures[0].Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the capture with the given id, got %+v", captures)
+	}
+}
+
+func TestIndex_Services(t *testing.T) {
+	ctx := context.Background()
+	index := testIndex(t)
+	start := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+
+	other := testRecord("checkout-1", "cpu", start.Add(time.Hour), "cpu")
+	other.Service = "checkout"
+	for _, record := range []audit.Record{
+		testRecord("my-app-1", "cpu", start, "cpu"),
+		testRecord("my-app-2", "cpu", start.Add(2*time.Hour), "cpu"),
+		other,
+	} {
+		if err := index.Write(ctx, record); err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+
+	services, err := index.Services(ctx, Filter{})
+	if err != nil {
+		t.Fatalf("Services returned unexpected error: %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 services, got %+v", services)
+	}
+	if s := services[0]; s.Name != "checkout" || s.Captures != 1 || !s.LastCapture.Equal(start.Add(time.Hour)) {
+		t.Errorf("Unexpected service %+v", s)
+	}
+	if s := services[1]; s.Name != "my-app" || s.Captures != 2 || !s.LastCapture.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Unexpected service %+v", s)
+	}
+
+	services, err = index.Services(ctx, Filter{Since: start.Add(90 * time.Minute)})
+	if err != nil {
+		t.Fatalf("Services returned unexpected error: %v", err)
+	}
+	if len(services) != 1 || services[0].Name != "my-app" || services[0].Captures != 1 {
+		t.Errorf("Expected only the services captured since, got %+v", services)
+	}
+}
+
+func TestOpen_UnsupportedDriver(t *testing.T) {
+	if _, err := Open(context.Background(), "mysql", ""); err == nil {
+		t.Error("Expected error for an unsupported driver")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	postgres := &Index{driver: DriverPostgres}
+	if query := postgres.rebind("a = ? AND b = ?"); query != "a = $1 AND b = $2" {
+		t.Errorf("Unexpected Postgres query %q", query)
+	}
+
+	sqlite := &Index{driver: DriverSQLite}
+	if query := sqlite.rebind("a = ?"); query != "a = ?" {
+		t.Errorf("Unexpected SQLite query %q", query)
+	}
+}
+
+// namespaceAuth allows reading the captures of the listed namespaces, or of
+// every namespace if it lists ""
+type namespaceAuth map[string]bool
+
+func (a namespaceAuth) CanReadCaptures(_ *http.Request, namespace string) (bool, error) {
+	return a[namespace], nil
+}
+
+func TestHandler(t *testing.T) {
+	index := testIndex(t)
+	at := time.Now().Add(-time.Hour)
+	if err := index.Write(context.Background(), testRecord("my-app-1", "cpu", at, "cpu")); err != nil {
+		t.Fatalf("Write returned unexpected error: %v", err)
+	}
+	handler := NewHandler(index, namespaceAuth{"": true})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CapturesPath+"?service=my-app&since=2h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var captures []Capture
+	if err := json.Unmarshal(rec.Body.Bytes(), &captures); err != nil {
+		t.Fatalf("Failed to decode captures: %v", err)
+	}
+	if len(captures) != 1 || captures[0].Profiles[0].Type != "cpu" {
+		t.Errorf("Unexpected captures %+v", captures)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CapturesPath+"?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid time, got %d", rec.Code)
+	}
+}
+
+func TestHandler_Namespaces(t *testing.T) {
+	index := testIndex(t)
+	at := time.Now().Add(-time.Hour)
+	for _, namespace := range []string{"default", "payments", "search"} {
+		record := testRecord("my-app-1", "cpu", at, "cpu")
+		record.Pod.Namespace = namespace
+		if err := index.Write(context.Background(), record); err != nil {
+			t.Fatalf("Write returned unexpected error: %v", err)
+		}
+	}
+	handler := NewHandler(index, namespaceAuth{"default": true, "search": true})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CapturesPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var captures []Capture
+	if err := json.Unmarshal(rec.Body.Bytes(), &captures); err != nil {
+		t.Fatalf("Failed to decode captures: %v", err)
+	}
+	var namespaces []string
+	for _, capture := range captures {
+		namespaces = append(namespaces, capture.Pod.Namespace)
+	}
+	if len(namespaces) != 2 || namespaces[0] != "search" || namespaces[1] != "default" {
+		t.Errorf("Expected the captures of search and default only, got %v", namespaces)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CapturesPath+"?namespace=payments", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a namespace the caller cannot read, got %d", rec.Code)
+	}
+
+	handler = NewHandler(index, namespaceAuth{})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CapturesPath, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+		t.Errorf("Expected no captures for a caller without access, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	now := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+
+	filter, err := ParseFilter(map[string][]string{
+		"type":  {"heap"},
+		"since": {"24h"},
+		"until": {"2024-01-16T09:00:00Z"},
+		"limit": {"10"},
+	}, now)
+	if err != nil {
+		t.Fatalf("ParseFilter returned unexpected error: %v", err)
+	}
+	if filter.ProfileType != "heap" || filter.Limit != 10 ||
+		!filter.Since.Equal(now.Add(-24*time.Hour)) || !filter.Until.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+
+	if _, err := ParseFilter(map[string][]string{"limit": {"-1"}}, now); err == nil {
+		t.Error("Expected error for a negative limit")
+	}
+}
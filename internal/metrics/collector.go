@@ -2,33 +2,224 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
+// DefaultCacheTTL is how long listed usage is reused across callers
+const DefaultCacheTTL = 10 * time.Second
+
+// SourceErrorTTL is how long a failed listing is reported by Check without the
+// source being listed again in the namespace, a few default check intervals
+const SourceErrorTTL = 5 * time.Minute
+
 // Collector collects and analyzes pod metrics
 type Collector struct {
 	metricsClient metricsv.Interface
+
+	mu      sync.RWMutex
+	sources map[string]Source
+
+	cacheMu  sync.Mutex
+	cacheTTL time.Duration
+	cache    map[string]*cacheEntry
+	now      func() time.Time
+
+	// sourceErrors holds the error of the last listing of each source and
+	// namespace, if it failed
+	sourceErrors map[string]sourceError
+}
+
+// sourceError is the failed listing of a source in a namespace
+type sourceError struct {
+	sourceName string
+	namespace  string
+	err        error
+	failedAt   time.Time
+}
+
+// cacheEntry holds the usage listed for a source and namespace, per pod
+type cacheEntry struct {
+	pods map[string]cachedUsage
+}
+
+// cachedUsage is the usage of a pod as of the listing it was fetched in. Usage
+// is nil for a pod that was requested but not reported by the source, so the
+// pod does not force a refetch until the entry expires.
+type cachedUsage struct {
+	fetchedAt time.Time
+	usage     *PodUsage
+}
+
+// covers reports whether a fresh listing includes every pod
+func (e *cacheEntry) covers(pods []*corev1.Pod, now time.Time, ttl time.Duration) bool {
+	for _, pod := range pods {
+		cached, ok := e.pods[pod.Name]
+		if !ok || now.Sub(cached.fetchedAt) >= ttl {
+			return false
+		}
+	}
+	return true
 }
 
-// NewCollector creates a new metrics collector
+// store records the usage listed for the requested pods and drops pods not
+// refreshed within the TTL, such as deleted pods
+func (e *cacheEntry) store(usages map[string]*PodUsage, pods []*corev1.Pod, now time.Time, ttl time.Duration) {
+	for _, pod := range pods {
+		e.pods[pod.Name] = cachedUsage{fetchedAt: now}
+	}
+	for name, usage := range usages {
+		e.pods[name] = cachedUsage{fetchedAt: now, usage: usage}
+	}
+	for name, cached := range e.pods {
+		if now.Sub(cached.fetchedAt) >= ttl {
+			delete(e.pods, name)
+		}
+	}
+}
+
+// usages returns the fresh usage of the pods reported by the source
+func (e *cacheEntry) usages(now time.Time, ttl time.Duration) map[string]*PodUsage {
+	usages := make(map[string]*PodUsage, len(e.pods))
+	for name, cached := range e.pods {
+		if cached.usage != nil && now.Sub(cached.fetchedAt) < ttl {
+			usages[name] = cached.usage
+		}
+	}
+	return usages
+}
+
+// NewCollector creates a new metrics collector backed by metrics-server
 func NewCollector(metricsClient metricsv.Interface) *Collector {
 	return &Collector{
 		metricsClient: metricsClient,
+		sources: map[string]Source{
+			SourceMetricsServer: NewMetricsServerSource(metricsClient),
+		},
+		cacheTTL:     DefaultCacheTTL,
+		cache:        make(map[string]*cacheEntry),
+		now:          time.Now,
+		sourceErrors: make(map[string]sourceError),
 	}
 }
 
+// SetCacheTTL sets how long listed usage is shared between callers. A zero TTL
+// disables caching.
+func (c *Collector) SetCacheTTL(ttl time.Duration) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	c.cacheTTL = ttl
+}
+
+// RegisterSource registers an additional metrics source under the given name
+func (c *Collector) RegisterSource(name string, source Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sources[name] = source
+}
+
+// HasSource reports whether a source is registered under the given name
+func (c *Collector) HasSource(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.sources[name]
+	return ok
+}
+
+// MemoryMetricSourceName returns the name of the source reporting memory of
+// the named source as the given metric, registering it on first use. The
+// named source is returned as is for the working set, and for sources that
+// only report the working set.
+func (c *Collector) MemoryMetricSourceName(sourceName, metric string) string {
+	if metric == "" || metric == MemoryWorkingSet {
+		return sourceName
+	}
+	if sourceName == "" {
+		sourceName = SourceMetricsServer
+	}
+
+	name := sourceName + "/memory=" + metric
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sources[name]; ok {
+		return name
+	}
+	source, ok := c.sources[sourceName].(MemoryMetricSource)
+	if !ok {
+		return sourceName
+	}
+	c.sources[name] = source.WithMemoryMetric(metric)
+	return name
+}
+
+// getSource returns the named source, defaulting to metrics-server
+func (c *Collector) getSource(name string) (Source, error) {
+	if name == "" {
+		name = SourceMetricsServer
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	source, ok := c.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown metrics source %q", name)
+	}
+	return source, nil
+}
+
 // PodMetrics represents the resource usage of a pod
 type PodMetrics struct {
-	CPUUsagePercent    float64
-	MemoryUsagePercent float64
-	CPUUsage           resource.Quantity
-	MemoryUsage        resource.Quantity
+	Timestamp          time.Time         `json:"timestamp"`
+	CPUUsagePercent    float64           `json:"cpuUsagePercent"`
+	MemoryUsagePercent float64           `json:"memoryUsagePercent"`
+	CPUUsage           resource.Quantity `json:"cpuUsage"`
+	MemoryUsage        resource.Quantity `json:"memoryUsage"`
+
+	// CPUThrottledRate is the highest share of CPU periods a container of the
+	// pod was throttled in, nil if the source does not report throttling
+	CPUThrottledRate *float64 `json:"cpuThrottledRate,omitempty"`
+
+	// Containers holds the usage of each individual container
+	Containers []ContainerMetrics `json:"containers,omitempty"`
+}
+
+// ContainerMetrics represents the resource usage of a single container
+type ContainerMetrics struct {
+	Name               string            `json:"name"`
+	CPUUsagePercent    float64           `json:"cpuUsagePercent"`
+	MemoryUsagePercent float64           `json:"memoryUsagePercent"`
+	CPUUsage           resource.Quantity `json:"cpuUsage"`
+	MemoryUsage        resource.Quantity `json:"memoryUsage"`
+}
+
+// Trigger records why a capture was requested and, for metric-based triggers, the
+// usage and thresholds that caused it
+type Trigger struct {
+	Reason string
+
+	// Metrics is the evaluated usage, nil for triggers not based on metrics
+	Metrics *PodMetrics
+
+	// History holds the samples recorded for the pod leading up to the trigger
+	History []*PodMetrics
+
+	CPUThresholdPercent    int
+	MemoryThresholdPercent int
+
+	// Source names the trigger source that requested the capture, empty for
+	// the built-in sources
+	Source string
 }
 
 // GetPodMetrics retrieves metrics for a specific pod
@@ -38,22 +229,168 @@ func (c *Collector) GetPodMetrics(ctx context.Context, namespace, podName string
 		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
 	}
 
-	return c.calculateMetrics(pod, podMetrics)
+	return c.calculateMetrics(pod, podUsageFromMetrics(podMetrics))
+}
+
+// ListPodMetrics retrieves metrics for the given pods of a namespace from the named
+// source with a single list call and returns them indexed by pod name. Pods without
+// metrics are omitted.
+func (c *Collector) ListPodMetrics(ctx context.Context, sourceName, namespace string, pods []*corev1.Pod) (map[string]*PodMetrics, error) {
+	usages, err := c.listPodUsage(ctx, sourceName, namespace, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*PodMetrics, len(pods))
+	for _, pod := range pods {
+		usage, ok := usages[pod.Name]
+		if !ok {
+			continue
+		}
+
+		metrics, err := c.calculateMetrics(pod, usage)
+		if err != nil {
+			return nil, err
+		}
+		result[pod.Name] = metrics
+	}
+
+	return result, nil
+}
+
+// listPodUsage returns usage for the pods, reusing a cached listing for the same
+// source and namespace while it is fresh and covers all requested pods
+func (c *Collector) listPodUsage(ctx context.Context, sourceName, namespace string, pods []*corev1.Pod) (map[string]*PodUsage, error) {
+	source, err := c.getSource(sourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	key := sourceName + "|" + namespace
+
+	c.cacheMu.Lock()
+	ttl := c.cacheTTL
+	entry, ok := c.cache[key]
+	if now := c.now(); ok && entry.covers(pods, now, ttl) {
+		usages := entry.usages(now, ttl)
+		c.cacheMu.Unlock()
+		return usages, nil
+	}
+	c.cacheMu.Unlock()
+
+	usages, err := source.ListPodUsage(ctx, namespace, pods)
+	c.recordSourceResult(sourceName, namespace, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		return usages, nil
+	}
+
+	// Sources such as the kubelet only return pods on the requested nodes, so keep
+	// fresh usage fetched for other callers until it expires on its own
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	entry, ok = c.cache[key]
+	if !ok {
+		entry = &cacheEntry{pods: make(map[string]cachedUsage)}
+		c.cache[key] = entry
+	}
+	now := c.now()
+	entry.store(usages, pods, now, ttl)
+
+	return entry.usages(now, ttl), nil
+}
+
+// recordSourceResult records whether the last listing of a source in a
+// namespace failed
+func (c *Collector) recordSourceResult(sourceName, namespace string, err error) {
+	if sourceName == "" {
+		sourceName = SourceMetricsServer
+	}
+	key := sourceName + "|" + namespace
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	if err != nil {
+		c.sourceErrors[key] = sourceError{sourceName: sourceName, namespace: namespace, err: err, failedAt: c.now()}
+		return
+	}
+	delete(c.sourceErrors, key)
+}
+
+// RetainSources drops the errors of the sources no config uses anymore, such
+// as the Prometheus endpoint of a deleted config
+func (c *Collector) RetainSources(inUse map[string]bool) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	for key, failed := range c.sourceErrors {
+		if !inUse[failed.sourceName] {
+			delete(c.sourceErrors, key)
+		}
+	}
+}
+
+// Check reports an error while the last listing of any source in use failed,
+// such as metrics-server being unreachable. Failures not listed again within
+// SourceErrorTTL are dropped. It implements healthz.Checker.
+func (c *Collector) Check(_ *http.Request) error {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	now := c.now()
+	keys := make([]string, 0, len(c.sourceErrors))
+	for key, failed := range c.sourceErrors {
+		if now.Sub(failed.failedAt) >= SourceErrorTTL {
+			delete(c.sourceErrors, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		failed := c.sourceErrors[key]
+		errs = append(errs, fmt.Errorf("metrics source %s is unavailable in namespace %s: %w", failed.sourceName, failed.namespace, failed.err))
+	}
+	return errors.Join(errs...)
 }
 
 // calculateMetrics calculates usage percentages based on requests
-func (c *Collector) calculateMetrics(pod *corev1.Pod, podMetrics *v1beta1.PodMetrics) (*PodMetrics, error) {
+func (c *Collector) calculateMetrics(pod *corev1.Pod, usage *PodUsage) (*PodMetrics, error) {
 	var totalCPUUsage, totalMemoryUsage resource.Quantity
 	var totalCPURequest, totalMemoryRequest resource.Quantity
 
+	// Index requests by container name
+	requests := make(map[string]corev1.ResourceList, len(pod.Spec.Containers))
+	for _, container := range pod.Spec.Containers {
+		requests[container.Name] = container.Resources.Requests
+	}
+
 	// Aggregate metrics from all containers
-	for _, container := range podMetrics.Containers {
-		if cpu, ok := container.Usage[corev1.ResourceCPU]; ok {
-			totalCPUUsage.Add(cpu)
-		}
-		if memory, ok := container.Usage[corev1.ResourceMemory]; ok {
-			totalMemoryUsage.Add(memory)
+	containers := make([]ContainerMetrics, 0, len(usage.Containers))
+	var throttled *float64
+	for _, container := range usage.Containers {
+		cpu := container.CPU
+		memory := container.Memory
+		totalCPUUsage.Add(cpu)
+		totalMemoryUsage.Add(memory)
+		if container.CPUThrottled != nil && (throttled == nil || *container.CPUThrottled > *throttled) {
+			throttled = container.CPUThrottled
 		}
+
+		containerRequests := requests[container.Name]
+		containers = append(containers, ContainerMetrics{
+			Name:               container.Name,
+			CPUUsagePercent:    cpuPercent(cpu, containerRequests[corev1.ResourceCPU]),
+			MemoryUsagePercent: memoryPercent(memory, containerRequests[corev1.ResourceMemory]),
+			CPUUsage:           cpu,
+			MemoryUsage:        memory,
+		})
 	}
 
 	// Aggregate requests from pod spec
@@ -66,26 +403,38 @@ func (c *Collector) calculateMetrics(pod *corev1.Pod, podMetrics *v1beta1.PodMet
 		}
 	}
 
-	// Calculate percentages
-	cpuPercent := 0.0
-	memoryPercent := 0.0
-
-	if !totalCPURequest.IsZero() {
-		cpuPercent = float64(totalCPUUsage.MilliValue()) / float64(totalCPURequest.MilliValue()) * 100
-	}
-
-	if !totalMemoryRequest.IsZero() {
-		memoryPercent = float64(totalMemoryUsage.Value()) / float64(totalMemoryRequest.Value()) * 100
+	timestamp := usage.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
 	}
 
 	return &PodMetrics{
-		CPUUsagePercent:    cpuPercent,
-		MemoryUsagePercent: memoryPercent,
+		Timestamp:          timestamp,
+		CPUUsagePercent:    cpuPercent(totalCPUUsage, totalCPURequest),
+		MemoryUsagePercent: memoryPercent(totalMemoryUsage, totalMemoryRequest),
 		CPUUsage:           totalCPUUsage,
 		MemoryUsage:        totalMemoryUsage,
+		CPUThrottledRate:   throttled,
+		Containers:         containers,
 	}, nil
 }
 
+// cpuPercent calculates CPU usage as a percentage of the request
+func cpuPercent(usage, request resource.Quantity) float64 {
+	if request.IsZero() {
+		return 0
+	}
+	return float64(usage.MilliValue()) / float64(request.MilliValue()) * 100
+}
+
+// memoryPercent calculates memory usage as a percentage of the request
+func memoryPercent(usage, request resource.Quantity) float64 {
+	if request.IsZero() {
+		return 0
+	}
+	return float64(usage.Value()) / float64(request.Value()) * 100
+}
+
 // CheckThresholds checks if metrics exceed configured thresholds
 func (pm *PodMetrics) CheckThresholds(cpuThreshold, memoryThreshold int) (exceeded bool, reason string) {
 	if pm.CPUUsagePercent > float64(cpuThreshold) {
@@ -98,3 +447,23 @@ func (pm *PodMetrics) CheckThresholds(cpuThreshold, memoryThreshold int) (exceed
 
 	return false, ""
 }
+
+// CheckContainerThresholds checks each container individually against the thresholds.
+// If containerNames is non-empty, only the named containers are evaluated.
+func (pm *PodMetrics) CheckContainerThresholds(cpuThreshold, memoryThreshold int, containerNames []string) (exceeded bool, reason string) {
+	for _, container := range pm.Containers {
+		if len(containerNames) > 0 && !slices.Contains(containerNames, container.Name) {
+			continue
+		}
+
+		if container.CPUUsagePercent > float64(cpuThreshold) {
+			return true, fmt.Sprintf("Container %s CPU usage %.2f%% exceeds threshold %d%%", container.Name, container.CPUUsagePercent, cpuThreshold)
+		}
+
+		if container.MemoryUsagePercent > float64(memoryThreshold) {
+			return true, fmt.Sprintf("Container %s memory usage %.2f%% exceeds threshold %d%%", container.Name, container.MemoryUsagePercent, memoryThreshold)
+		}
+	}
+
+	return false, ""
+}
@@ -1,9 +1,24 @@
 package metrics
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
 )
 
 func TestCheckThresholds(t *testing.T) {
@@ -170,3 +185,549 @@ func TestCalculateMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestListPodMetrics(t *testing.T) {
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("1000m"),
+								corev1.ResourceMemory: resource.MustParse("512Mi"),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	newPodMetrics := func(name, cpu, memory string) v1beta1.PodMetrics {
+		return v1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Containers: []v1beta1.ContainerMetrics{
+				{
+					Name: "app",
+					Usage: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse(cpu),
+						corev1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			},
+		}
+	}
+
+	listCalls := 0
+	fakeClient := metricsfake.NewSimpleClientset()
+	fakeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listCalls++
+		return true, &v1beta1.PodMetricsList{
+			Items: []v1beta1.PodMetrics{
+				newPodMetrics("pod-1", "500m", "256Mi"),
+				newPodMetrics("pod-2", "900m", "128Mi"),
+				newPodMetrics("untracked", "100m", "64Mi"),
+			},
+		}, nil
+	})
+
+	collector := NewCollector(fakeClient)
+	pods := []*corev1.Pod{newPod("pod-1"), newPod("pod-2"), newPod("pod-3")}
+
+	result, err := collector.ListPodMetrics(context.Background(), SourceMetricsServer, "default", pods)
+	if err != nil {
+		t.Fatalf("ListPodMetrics returned error: %v", err)
+	}
+
+	if listCalls != 1 {
+		t.Errorf("expected a single list call, got %d", listCalls)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected metrics for 2 pods, got %d", len(result))
+	}
+
+	if _, ok := result["untracked"]; ok {
+		t.Error("expected metrics for pods not requested to be omitted")
+	}
+
+	if _, ok := result["pod-3"]; ok {
+		t.Error("expected pod without metrics to be omitted")
+	}
+
+	if got := result["pod-1"].CPUUsagePercent; got != 50 {
+		t.Errorf("expected pod-1 CPU percent 50, got %f", got)
+	}
+
+	if got := result["pod-2"].CPUUsagePercent; got != 90 {
+		t.Errorf("expected pod-2 CPU percent 90, got %f", got)
+	}
+
+	if len(result["pod-1"].Containers) != 1 {
+		t.Fatalf("expected 1 container entry for pod-1, got %d", len(result["pod-1"].Containers))
+	}
+
+	if got := result["pod-1"].Containers[0].MemoryUsagePercent; got != 50 {
+		t.Errorf("expected pod-1 container memory percent 50, got %f", got)
+	}
+}
+
+func TestCheckContainerThresholds(t *testing.T) {
+	pm := &PodMetrics{
+		CPUUsagePercent:    40,
+		MemoryUsagePercent: 40,
+		Containers: []ContainerMetrics{
+			{Name: "app", CPUUsagePercent: 20, MemoryUsagePercent: 30},
+			{Name: "sidecar", CPUUsagePercent: 95, MemoryUsagePercent: 50},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		containers     []string
+		expectExceeded bool
+		expectReason   string
+	}{
+		{
+			name:           "Hot sidecar detected without filter",
+			expectExceeded: true,
+			expectReason:   "Container sidecar CPU usage 95.00% exceeds threshold 80%",
+		},
+		{
+			name:           "Hot sidecar ignored by filter",
+			containers:     []string{"app"},
+			expectExceeded: false,
+		},
+		{
+			name:           "Filter selecting sidecar",
+			containers:     []string{"sidecar"},
+			expectExceeded: true,
+			expectReason:   "Container sidecar CPU usage 95.00% exceeds threshold 80%",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exceeded, reason := pm.CheckContainerThresholds(80, 90, tt.containers)
+
+			if exceeded != tt.expectExceeded {
+				t.Errorf("expected exceeded=%v, got %v", tt.expectExceeded, exceeded)
+			}
+
+			if reason != tt.expectReason {
+				t.Errorf("expected reason %q, got %q", tt.expectReason, reason)
+			}
+		})
+	}
+}
+
+func TestListPodMetrics_UnknownSource(t *testing.T) {
+	collector := NewCollector(metricsfake.NewSimpleClientset())
+
+	_, err := collector.ListPodMetrics(context.Background(), "does-not-exist", "default", nil)
+	if err == nil {
+		t.Error("expected error for unknown metrics source")
+	}
+}
+
+func TestPodUsageFromSummary(t *testing.T) {
+	data := []byte(`{
+		"pods": [
+			{
+				"podRef": {"name": "pod-1", "namespace": "default"},
+				"containers": [
+					{
+						"name": "app",
+						"cpu": {"time": "2024-01-15T12:00:00Z", "usageNanoCores": 250000000},
+						"memory": {"time": "2024-01-15T12:00:00Z", "workingSetBytes": 134217728}
+					}
+				]
+			},
+			{
+				"podRef": {"name": "pod-2", "namespace": "other"},
+				"containers": [{"name": "app"}]
+			}
+		]
+	}`)
+
+	nodeSummary := &summary{}
+	if err := json.Unmarshal(data, nodeSummary); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+
+	usages := podUsageFromSummary(nodeSummary, "default", MemoryWorkingSet)
+
+	if len(usages) != 1 {
+		t.Fatalf("expected 1 pod in namespace, got %d", len(usages))
+	}
+
+	usage, ok := usages["pod-1"]
+	if !ok {
+		t.Fatal("expected usage for pod-1")
+	}
+
+	if len(usage.Containers) != 1 {
+		t.Fatalf("expected 1 container, got %d", len(usage.Containers))
+	}
+
+	if got := usage.Containers[0].CPU.MilliValue(); got != 250 {
+		t.Errorf("expected 250m CPU, got %dm", got)
+	}
+
+	if got := usage.Containers[0].Memory.Value(); got != 128*1024*1024 {
+		t.Errorf("expected 128Mi memory, got %d", got)
+	}
+}
+
+func TestPodUsageFromSummary_MemoryMetric(t *testing.T) {
+	data := []byte(`{
+		"pods": [
+			{
+				"podRef": {"name": "pod-1", "namespace": "default"},
+				"containers": [
+					{
+						"name": "app",
+						"memory": {"workingSetBytes": 134217728, "rssBytes": 67108864, "usageBytes": 268435456}
+					}
+				]
+			}
+		]
+	}`)
+
+	nodeSummary := &summary{}
+	if err := json.Unmarshal(data, nodeSummary); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+
+	for metric, want := range map[string]int64{
+		MemoryWorkingSet: 128 * 1024 * 1024,
+		MemoryRSS:        64 * 1024 * 1024,
+		MemoryUsage:      256 * 1024 * 1024,
+	} {
+		usage := podUsageFromSummary(nodeSummary, "default", metric)["pod-1"]
+		if got := usage.Containers[0].Memory.Value(); got != want {
+			t.Errorf("expected %d bytes of %s memory, got %d", want, metric, got)
+		}
+	}
+}
+
+func TestEphemeralStorageFromSummary(t *testing.T) {
+	data := []byte(`{
+		"pods": [
+			{"podRef": {"name": "pod-1", "namespace": "default"}, "ephemeral-storage": {"usedBytes": 1073741824}},
+			{"podRef": {"name": "pod-2", "namespace": "default"}},
+			{"podRef": {"name": "pod-3", "namespace": "other"}, "ephemeral-storage": {"usedBytes": 1024}}
+		]
+	}`)
+
+	nodeSummary := &summary{}
+	if err := json.Unmarshal(data, nodeSummary); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+
+	usages := ephemeralStorageFromSummary(nodeSummary, "default")
+	if len(usages) != 1 {
+		t.Fatalf("expected the usage of 1 pod, got %d", len(usages))
+	}
+	if used := usages["pod-1"]; used.Value() != 1024*1024*1024 {
+		t.Errorf("expected 1Gi used by pod-1, got %s", used.String())
+	}
+}
+
+func TestNetworkFromSummary(t *testing.T) {
+	data := []byte(`{
+		"pods": [
+			{
+				"podRef": {"name": "pod-1", "namespace": "default"},
+				"network": {
+					"time": "2024-01-15T12:00:00Z",
+					"name": "eth0", "rxBytes": 1000, "txBytes": 2000,
+					"interfaces": [
+						{"name": "eth0", "rxBytes": 1000, "txBytes": 2000},
+						{"name": "net1", "rxBytes": 500, "txBytes": 100}
+					]
+				}
+			},
+			{
+				"podRef": {"name": "pod-2", "namespace": "default"},
+				"network": {"time": "2024-01-15T12:00:00Z", "rxBytes": 10, "txBytes": 20}
+			},
+			{"podRef": {"name": "pod-3", "namespace": "default"}}
+		]
+	}`)
+
+	nodeSummary := &summary{}
+	if err := json.Unmarshal(data, nodeSummary); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+
+	usages := networkFromSummary(nodeSummary, "default")
+	if len(usages) != 2 {
+		t.Fatalf("expected the counters of 2 pods, got %d", len(usages))
+	}
+	if usage := usages["pod-1"]; usage.ReceiveBytes != 1500 || usage.TransmitBytes != 2100 {
+		t.Errorf("expected the interfaces of pod-1 to be summed, got %+v", usage)
+	}
+	if usage := usages["pod-2"]; usage.ReceiveBytes != 10 || usage.TransmitBytes != 20 {
+		t.Errorf("expected the default interface of pod-2, got %+v", usage)
+	}
+}
+
+func TestMemoryMetricSourceName(t *testing.T) {
+	collector := NewCollector(metricsfake.NewSimpleClientset())
+	collector.RegisterSource(SourceKubelet, NewKubeletSource(nil))
+
+	if name := collector.MemoryMetricSourceName(SourceKubelet, MemoryWorkingSet); name != SourceKubelet {
+		t.Errorf("expected the kubelet source for the working set, got %s", name)
+	}
+
+	name := collector.MemoryMetricSourceName(SourceKubelet, MemoryRSS)
+	if name == SourceKubelet || !collector.HasSource(name) {
+		t.Errorf("expected a registered kubelet source reporting rss, got %s", name)
+	}
+	if again := collector.MemoryMetricSourceName(SourceKubelet, MemoryRSS); again != name {
+		t.Errorf("expected the rss source to be reused, got %s", again)
+	}
+
+	// metrics-server only reports the working set
+	if name := collector.MemoryMetricSourceName(SourceMetricsServer, MemoryRSS); name != SourceMetricsServer {
+		t.Errorf("expected metrics-server, got %s", name)
+	}
+}
+
+func TestListPodMetrics_Cache(t *testing.T) {
+	listCalls := 0
+	fakeClient := metricsfake.NewSimpleClientset()
+	fakeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listCalls++
+		return true, &v1beta1.PodMetricsList{
+			Items: []v1beta1.PodMetrics{
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}},
+			},
+		}, nil
+	})
+
+	collector := NewCollector(fakeClient)
+	pods := []*corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := collector.ListPodMetrics(context.Background(), SourceMetricsServer, "default", pods); err != nil {
+			t.Fatalf("ListPodMetrics returned error: %v", err)
+		}
+	}
+
+	if listCalls != 1 {
+		t.Errorf("expected cached listing to be reused, got %d list calls", listCalls)
+	}
+
+	// A pod missing from the cached listing forces a refresh
+	missing := append(pods, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default"}})
+	if _, err := collector.ListPodMetrics(context.Background(), SourceMetricsServer, "default", missing); err != nil {
+		t.Fatalf("ListPodMetrics returned error: %v", err)
+	}
+
+	if listCalls != 2 {
+		t.Errorf("expected refresh for uncovered pod, got %d list calls", listCalls)
+	}
+
+	// A pod the source did not report does not force another refresh
+	if _, err := collector.ListPodMetrics(context.Background(), SourceMetricsServer, "default", missing); err != nil {
+		t.Fatalf("ListPodMetrics returned error: %v", err)
+	}
+
+	if listCalls != 2 {
+		t.Errorf("expected pod without metrics to be cached, got %d list calls", listCalls)
+	}
+
+	// Disabling the cache lists on every call
+	collector.SetCacheTTL(0)
+	for i := 0; i < 2; i++ {
+		if _, err := collector.ListPodMetrics(context.Background(), SourceMetricsServer, "default", pods); err != nil {
+			t.Fatalf("ListPodMetrics returned error: %v", err)
+		}
+	}
+
+	if listCalls != 4 {
+		t.Errorf("expected uncached listing, got %d list calls", listCalls)
+	}
+}
+
+// sourceFunc adapts a function to a Source
+type sourceFunc func(pods []*corev1.Pod) map[string]*PodUsage
+
+func (f sourceFunc) ListPodUsage(_ context.Context, _ string, pods []*corev1.Pod) (map[string]*PodUsage, error) {
+	return f(pods), nil
+}
+
+func TestListPodMetrics_CarriedOverPodExpires(t *testing.T) {
+	// Like the kubelet, the source only reports the requested pods
+	source := sourceFunc(func(pods []*corev1.Pod) map[string]*PodUsage {
+		usages := make(map[string]*PodUsage)
+		for _, pod := range pods {
+			usages[pod.Name] = &PodUsage{}
+		}
+		return usages
+	})
+
+	now := time.Now()
+	collector := NewCollector(metricsfake.NewSimpleClientset())
+	collector.now = func() time.Time { return now }
+	collector.RegisterSource("test", source)
+
+	pod := func(name string) []*corev1.Pod {
+		return []*corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}}
+	}
+
+	if _, err := collector.listPodUsage(context.Background(), "test", "default", pod("pod-1")); err != nil {
+		t.Fatalf("listPodUsage returned error: %v", err)
+	}
+
+	now = now.Add(DefaultCacheTTL / 2)
+	usages, err := collector.listPodUsage(context.Background(), "test", "default", pod("pod-2"))
+	if err != nil {
+		t.Fatalf("listPodUsage returned error: %v", err)
+	}
+	if _, ok := usages["pod-1"]; !ok {
+		t.Error("expected fresh usage of pod-1 to be carried over")
+	}
+
+	// pod-1 expires on its own TTL even though pod-2 was refetched since
+	now = now.Add(DefaultCacheTTL / 2)
+	usages, err = collector.listPodUsage(context.Background(), "test", "default", pod("pod-2"))
+	if err != nil {
+		t.Fatalf("listPodUsage returned error: %v", err)
+	}
+	if _, ok := usages["pod-1"]; ok {
+		t.Error("expected usage of pod-1 to expire")
+	}
+	if _, ok := usages["pod-2"]; !ok {
+		t.Error("expected cached usage of pod-2")
+	}
+}
+
+func TestCollector_Check(t *testing.T) {
+	unavailable := true
+	fakeClient := metricsfake.NewSimpleClientset()
+	fakeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if unavailable {
+			return true, nil, errors.New("the server is currently unable to handle the request")
+		}
+		return true, &v1beta1.PodMetricsList{}, nil
+	})
+
+	collector := NewCollector(fakeClient)
+	collector.SetCacheTTL(0)
+	pods := []*corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}}
+
+	// Sources that were never used are not reported
+	if err := collector.Check(nil); err != nil {
+		t.Errorf("expected no error before any listing, got %v", err)
+	}
+
+	if _, err := collector.ListPodMetrics(context.Background(), SourceMetricsServer, "default", pods); err == nil {
+		t.Fatal("expected listing to fail")
+	}
+	if err := collector.Check(nil); err == nil || !strings.Contains(err.Error(), SourceMetricsServer) {
+		t.Errorf("expected metrics-server to be reported unavailable, got %v", err)
+	}
+
+	// A successful listing clears the error
+	unavailable = false
+	if _, err := collector.ListPodMetrics(context.Background(), "", "default", pods); err != nil {
+		t.Fatalf("ListPodMetrics returned error: %v", err)
+	}
+	if err := collector.Check(nil); err != nil {
+		t.Errorf("expected no error after recovery, got %v", err)
+	}
+}
+
+func TestCollector_Check_PerNamespace(t *testing.T) {
+	failing := map[string]bool{"team-a": true}
+	source := sourceFunc(func(pods []*corev1.Pod) map[string]*PodUsage {
+		return map[string]*PodUsage{}
+	})
+
+	now := time.Now()
+	collector := NewCollector(metricsfake.NewSimpleClientset())
+	collector.now = func() time.Time { return now }
+	collector.SetCacheTTL(0)
+	collector.RegisterSource("prometheus", failingSource{Source: source, failing: failing})
+
+	pods := func(namespace string) []*corev1.Pod {
+		return []*corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: namespace}}}
+	}
+
+	_, _ = collector.ListPodMetrics(context.Background(), "prometheus", "team-a", pods("team-a"))
+	if _, err := collector.ListPodMetrics(context.Background(), "prometheus", "team-b", pods("team-b")); err != nil {
+		t.Fatalf("ListPodMetrics returned error: %v", err)
+	}
+
+	// A successful listing in another namespace does not clear the failure
+	if err := collector.Check(nil); err == nil || !strings.Contains(err.Error(), "team-a") {
+		t.Errorf("expected team-a to be reported unavailable, got %v", err)
+	}
+
+	// Failures not listed again expire
+	now = now.Add(SourceErrorTTL)
+	if err := collector.Check(nil); err != nil {
+		t.Errorf("expected the failure to expire, got %v", err)
+	}
+
+	// Failures of sources no config uses anymore are dropped
+	_, _ = collector.ListPodMetrics(context.Background(), "prometheus", "team-a", pods("team-a"))
+	collector.RetainSources(map[string]bool{SourceMetricsServer: true})
+	if err := collector.Check(nil); err != nil {
+		t.Errorf("expected the failure of the unused source to be dropped, got %v", err)
+	}
+}
+
+// failingSource fails the listings of the failing namespaces
+type failingSource struct {
+	Source
+	failing map[string]bool
+}
+
+func (s failingSource) ListPodUsage(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]*PodUsage, error) {
+	if s.failing[namespace] {
+		return nil, errors.New("connection refused")
+	}
+	return s.Source.ListPodUsage(ctx, namespace, pods)
+}
+
+func TestKubeletSource_ListPodUsage_FailedNode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/nodes/node-b/") {
+			http.Error(w, "node not ready", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"pods": [{"podRef": {"name": "pod-a", "namespace": "default"}, "containers": [{"name": "app"}]}]}`))
+	}))
+	defer server.Close()
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create clientset: %v", err)
+	}
+	source := NewKubeletSource(clientset)
+
+	podOn := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+
+	usages, err := source.ListPodUsage(context.Background(), "default", []*corev1.Pod{podOn("pod-a", "node-a"), podOn("pod-b", "node-b")})
+	if err != nil {
+		t.Fatalf("expected the usage of the healthy node, got error: %v", err)
+	}
+	if _, ok := usages["pod-a"]; !ok || len(usages) != 1 {
+		t.Errorf("expected only the usage of pod-a, got %v", usages)
+	}
+
+	if _, err := source.ListPodUsage(context.Background(), "default", []*corev1.Pod{podOn("pod-b", "node-b")}); err == nil {
+		t.Error("expected an error when every node failed")
+	}
+}
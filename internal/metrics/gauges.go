@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Labels of the usage gauges
+var usageLabels = []string{"config", "cluster", "namespace", "pod"}
+
+var (
+	cpuUsageDesc = prometheus.NewDesc("bolometer_pod_cpu_usage_percent",
+		"CPU usage of a tracked pod in percent of its requests or limits, as evaluated against its thresholds.",
+		usageLabels, nil)
+	memoryUsageDesc = prometheus.NewDesc("bolometer_pod_memory_usage_percent",
+		"Memory usage of a tracked pod in percent of its requests or limits, as evaluated against its thresholds.",
+		usageLabels, nil)
+	cpuThresholdDesc = prometheus.NewDesc("bolometer_pod_cpu_threshold_percent",
+		"CPU threshold a tracked pod is evaluated against, with its overrides.",
+		usageLabels, nil)
+	memoryThresholdDesc = prometheus.NewDesc("bolometer_pod_memory_threshold_percent",
+		"Memory threshold a tracked pod is evaluated against, with its overrides.",
+		usageLabels, nil)
+)
+
+// UsageLabels identify a tracked pod in the usage gauges
+type UsageLabels struct {
+	// Config is the namespace/name of the config owning the pod
+	Config string
+
+	// Cluster is the remote cluster of the pod, empty for the operator's own
+	Cluster string
+
+	Namespace string
+	Pod       string
+}
+
+// Thresholds are the thresholds a pod is evaluated against, 0 if disabled
+type Thresholds struct {
+	CPUPercent    int
+	MemoryPercent int
+}
+
+// UsageGauges exports the usage last evaluated for each tracked pod, with the
+// thresholds it was evaluated against, as Prometheus gauges. Pods are exported
+// until forgotten. It implements prometheus.Collector.
+type UsageGauges struct {
+	mu   sync.RWMutex
+	pods map[string]evaluatedUsage
+}
+
+// evaluatedUsage is the usage last evaluated for a pod
+type evaluatedUsage struct {
+	labels        UsageLabels
+	cpuPercent    float64
+	memoryPercent float64
+	thresholds    Thresholds
+}
+
+// NewUsageGauges creates usage gauges exporting no pods
+func NewUsageGauges() *UsageGauges {
+	return &UsageGauges{pods: make(map[string]evaluatedUsage)}
+}
+
+// Set records the usage evaluated for a pod and its thresholds
+func (g *UsageGauges) Set(key string, labels UsageLabels, usage *PodMetrics, thresholds Thresholds) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pods[key] = evaluatedUsage{
+		labels:        labels,
+		cpuPercent:    usage.CPUUsagePercent,
+		memoryPercent: usage.MemoryUsagePercent,
+		thresholds:    thresholds,
+	}
+}
+
+// Forget stops exporting a pod
+func (g *UsageGauges) Forget(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pods, key)
+}
+
+// Keys returns the keys of all exported pods, sorted
+func (g *UsageGauges) Keys() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	keys := make([]string, 0, len(g.pods))
+	for key := range g.pods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Describe implements prometheus.Collector
+func (g *UsageGauges) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cpuUsageDesc
+	ch <- memoryUsageDesc
+	ch <- cpuThresholdDesc
+	ch <- memoryThresholdDesc
+}
+
+// Collect implements prometheus.Collector. Disabled thresholds are left out.
+func (g *UsageGauges) Collect(ch chan<- prometheus.Metric) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, usage := range g.pods {
+		labels := []string{usage.labels.Config, usage.labels.Cluster, usage.labels.Namespace, usage.labels.Pod}
+		ch <- prometheus.MustNewConstMetric(cpuUsageDesc, prometheus.GaugeValue, usage.cpuPercent, labels...)
+		ch <- prometheus.MustNewConstMetric(memoryUsageDesc, prometheus.GaugeValue, usage.memoryPercent, labels...)
+		if usage.thresholds.CPUPercent > 0 {
+			ch <- prometheus.MustNewConstMetric(cpuThresholdDesc, prometheus.GaugeValue, float64(usage.thresholds.CPUPercent), labels...)
+		}
+		if usage.thresholds.MemoryPercent > 0 {
+			ch <- prometheus.MustNewConstMetric(memoryThresholdDesc, prometheus.GaugeValue, float64(usage.thresholds.MemoryPercent), labels...)
+		}
+	}
+}
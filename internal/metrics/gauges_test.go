@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUsageGauges(t *testing.T) {
+	gauges := NewUsageGauges()
+	gauges.Set("default/my-app-1", UsageLabels{Config: "default/my-app", Namespace: "default", Pod: "my-app-1"},
+		&PodMetrics{CPUUsagePercent: 42.5, MemoryUsagePercent: 61}, Thresholds{CPUPercent: 80})
+	gauges.Set("default/my-app-2", UsageLabels{Config: "default/my-app", Namespace: "default", Pod: "my-app-2"},
+		&PodMetrics{CPUUsagePercent: 10, MemoryUsagePercent: 20}, Thresholds{CPUPercent: 80, MemoryPercent: 90})
+	gauges.Forget("default/my-app-2")
+
+	expected := `
+# HELP bolometer_pod_cpu_threshold_percent CPU threshold a tracked pod is evaluated against, with its overrides.
+# TYPE bolometer_pod_cpu_threshold_percent gauge
+bolometer_pod_cpu_threshold_percent{cluster="",config="default/my-app",namespace="default",pod="my-app-1"} 80
+# HELP bolometer_pod_cpu_usage_percent CPU usage of a tracked pod in percent of its requests or limits, as evaluated against its thresholds.
+# TYPE bolometer_pod_cpu_usage_percent gauge
+bolometer_pod_cpu_usage_percent{cluster="",config="default/my-app",namespace="default",pod="my-app-1"} 42.5
+# HELP bolometer_pod_memory_usage_percent Memory usage of a tracked pod in percent of its requests or limits, as evaluated against its thresholds.
+# TYPE bolometer_pod_memory_usage_percent gauge
+bolometer_pod_memory_usage_percent{cluster="",config="default/my-app",namespace="default",pod="my-app-1"} 61
+`
+	if err := testutil.CollectAndCompare(gauges, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	if keys := gauges.Keys(); len(keys) != 1 || keys[0] != "default/my-app-1" {
+		t.Errorf("Expected the remaining pod, got %v", keys)
+	}
+}
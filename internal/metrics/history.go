@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of samples kept per pod
+const DefaultHistorySize = 60
+
+// History keeps a bounded buffer of recent metrics samples per pod
+type History struct {
+	mu       sync.RWMutex
+	capacity int
+	samples  map[string][]*PodMetrics
+}
+
+// NewHistory creates a new history keeping up to capacity samples per pod
+func NewHistory(capacity int) *History {
+	if capacity <= 0 {
+		capacity = DefaultHistorySize
+	}
+
+	return &History{
+		capacity: capacity,
+		samples:  make(map[string][]*PodMetrics),
+	}
+}
+
+// Record adds a sample for a pod, evicting the oldest sample when full
+func (h *History) Record(key string, sample *PodMetrics) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := append(h.samples[key], sample)
+	if len(samples) > h.capacity {
+		samples = samples[len(samples)-h.capacity:]
+	}
+	h.samples[key] = samples
+}
+
+// Samples returns a copy of the samples recorded for a pod, oldest first
+func (h *History) Samples(key string) []*PodMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[key]
+	if len(samples) == 0 {
+		return nil
+	}
+
+	return append([]*PodMetrics(nil), samples...)
+}
+
+// Latest returns the most recent sample recorded for a pod, nil if none
+func (h *History) Latest(key string) *PodMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[key]
+	if len(samples) == 0 {
+		return nil
+	}
+	return samples[len(samples)-1]
+}
+
+// Keys returns the keys of all pods with recorded samples, sorted
+func (h *History) Keys() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	keys := make([]string, 0, len(h.samples))
+	for key := range h.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Forget drops all samples for a pod
+func (h *History) Forget(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.samples, key)
+}
+
+// Average returns the mean of the samples recorded for a pod within window of
+// the latest sample. Quantities are taken from the latest sample. Returns nil
+// if no samples are recorded.
+func (h *History) Average(key string, window time.Duration) *PodMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[key]
+	if len(samples) == 0 {
+		return nil
+	}
+
+	latest := samples[len(samples)-1]
+	cutoff := latest.Timestamp.Add(-window)
+
+	var count int
+	var cpuSum, memorySum float64
+	containerCPU := make(map[string]float64)
+	containerMemory := make(map[string]float64)
+	containerCount := make(map[string]int)
+
+	for i := len(samples) - 1; i >= 0; i-- {
+		sample := samples[i]
+		if sample.Timestamp.Before(cutoff) {
+			break
+		}
+
+		count++
+		cpuSum += sample.CPUUsagePercent
+		memorySum += sample.MemoryUsagePercent
+		for _, container := range sample.Containers {
+			containerCPU[container.Name] += container.CPUUsagePercent
+			containerMemory[container.Name] += container.MemoryUsagePercent
+			containerCount[container.Name]++
+		}
+	}
+
+	average := &PodMetrics{
+		Timestamp:          latest.Timestamp,
+		CPUUsagePercent:    cpuSum / float64(count),
+		MemoryUsagePercent: memorySum / float64(count),
+		CPUUsage:           latest.CPUUsage,
+		MemoryUsage:        latest.MemoryUsage,
+		CPUThrottledRate:   latest.CPUThrottledRate,
+		Containers:         make([]ContainerMetrics, 0, len(latest.Containers)),
+	}
+
+	for _, container := range latest.Containers {
+		n := float64(containerCount[container.Name])
+		average.Containers = append(average.Containers, ContainerMetrics{
+			Name:               container.Name,
+			CPUUsagePercent:    containerCPU[container.Name] / n,
+			MemoryUsagePercent: containerMemory[container.Name] / n,
+			CPUUsage:           container.CPUUsage,
+			MemoryUsage:        container.MemoryUsage,
+		})
+	}
+
+	return average
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HistoryPath is the path the history handler is served on
+const HistoryPath = "/history"
+
+// PodHistory is the exported sample history of a single pod
+type PodHistory struct {
+	Pod     string        `json:"pod"`
+	Samples []*PodMetrics `json:"samples"`
+}
+
+// HistoryHandler serves the recorded sample history as JSON. A single pod can be
+// selected with the pod query parameter, in namespace/name form.
+type HistoryHandler struct {
+	history *History
+}
+
+// NewHistoryHandler creates a new handler serving the given history
+func NewHistoryHandler(history *History) *HistoryHandler {
+	return &HistoryHandler{
+		history: history,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys := h.history.Keys()
+	if pod := req.URL.Query().Get("pod"); pod != "" {
+		keys = []string{pod}
+	}
+
+	result := make([]PodHistory, 0, len(keys))
+	for _, key := range keys {
+		samples := h.history.Samples(key)
+		if samples == nil {
+			continue
+		}
+		result = append(result, PodHistory{Pod: key, Samples: samples})
+	}
+
+	if len(result) == 0 && req.URL.Query().Get("pod") != "" {
+		http.Error(w, "no history for pod", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
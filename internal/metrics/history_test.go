@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHistory_Average(t *testing.T) {
+	history := NewHistory(10)
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	for i, cpu := range []float64{10, 100, 50, 60, 70} {
+		history.Record("default/pod-1", &PodMetrics{
+			Timestamp:          start.Add(time.Duration(i) * 10 * time.Second),
+			CPUUsagePercent:    cpu,
+			MemoryUsagePercent: 40,
+			Containers: []ContainerMetrics{
+				{Name: "app", CPUUsagePercent: cpu},
+			},
+		})
+	}
+
+	// The window covers the last three samples (20s, 30s, 40s)
+	average := history.Average("default/pod-1", 20*time.Second)
+	if average == nil {
+		t.Fatal("expected average, got nil")
+	}
+
+	if average.CPUUsagePercent != 60 {
+		t.Errorf("expected average CPU 60, got %f", average.CPUUsagePercent)
+	}
+
+	if average.MemoryUsagePercent != 40 {
+		t.Errorf("expected average memory 40, got %f", average.MemoryUsagePercent)
+	}
+
+	if len(average.Containers) != 1 || average.Containers[0].CPUUsagePercent != 60 {
+		t.Errorf("expected container average CPU 60, got %+v", average.Containers)
+	}
+}
+
+func TestHistory_Capacity(t *testing.T) {
+	history := NewHistory(3)
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		history.Record("default/pod-1", &PodMetrics{
+			Timestamp:       start.Add(time.Duration(i) * time.Second),
+			CPUUsagePercent: float64(i),
+		})
+	}
+
+	// Only the last three samples (2, 3, 4) are kept
+	average := history.Average("default/pod-1", time.Hour)
+	if average.CPUUsagePercent != 3 {
+		t.Errorf("expected average CPU 3, got %f", average.CPUUsagePercent)
+	}
+}
+
+func TestHistory_Forget(t *testing.T) {
+	history := NewHistory(3)
+	history.Record("default/pod-1", &PodMetrics{Timestamp: time.Now()})
+	history.Forget("default/pod-1")
+
+	if average := history.Average("default/pod-1", time.Minute); average != nil {
+		t.Errorf("expected nil average after forget, got %+v", average)
+	}
+}
+
+func TestHistory_Samples(t *testing.T) {
+	history := NewHistory(3)
+
+	for i := 0; i < 5; i++ {
+		history.Record("default/pod-1", &PodMetrics{CPUUsagePercent: float64(i)})
+	}
+	history.Record("default/pod-0", &PodMetrics{})
+
+	samples := history.Samples("default/pod-1")
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+
+	if samples[0].CPUUsagePercent != 2 || samples[2].CPUUsagePercent != 4 {
+		t.Errorf("expected oldest samples to be evicted, got %v, %v", samples[0].CPUUsagePercent, samples[2].CPUUsagePercent)
+	}
+	if latest := history.Latest("default/pod-1"); latest == nil || latest.CPUUsagePercent != 4 {
+		t.Errorf("expected the latest sample, got %+v", latest)
+	}
+	if history.Latest("default/pod-2") != nil {
+		t.Error("expected no latest sample for an unknown pod")
+	}
+
+	keys := history.Keys()
+	if len(keys) != 2 || keys[0] != "default/pod-0" {
+		t.Errorf("expected sorted keys, got %v", keys)
+	}
+
+	history.Forget("default/pod-1")
+	if history.Samples("default/pod-1") != nil {
+		t.Error("expected no samples after Forget")
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	history := NewHistory(10)
+	history.Record("default/pod-1", &PodMetrics{CPUUsagePercent: 42})
+	history.Record("default/pod-2", &PodMetrics{CPUUsagePercent: 7})
+
+	handler := NewHistoryHandler(history)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HistoryPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var all []PodHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 pods, got %d", len(all))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HistoryPath+"?pod=default/pod-1", nil))
+
+	var single []PodHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &single); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(single) != 1 || single[0].Samples[0].CPUUsagePercent != 42 {
+		t.Errorf("unexpected history for pod: %+v", single)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HistoryPath+"?pod=default/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown pod, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,301 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// KubeletSource reads pod usage from the kubelet Summary API through the
+// apiserver node proxy, for clusters without metrics-server
+type KubeletSource struct {
+	clientset    kubernetes.Interface
+	memoryMetric string
+}
+
+// NewKubeletSource creates a new kubelet Summary API source
+func NewKubeletSource(clientset kubernetes.Interface) *KubeletSource {
+	return &KubeletSource{
+		clientset:    clientset,
+		memoryMetric: MemoryWorkingSet,
+	}
+}
+
+// WithMemoryMetric implements MemoryMetricSource
+func (s *KubeletSource) WithMemoryMetric(metric string) Source {
+	return &KubeletSource{
+		clientset:    s.clientset,
+		memoryMetric: metric,
+	}
+}
+
+// summary is the subset of the kubelet /stats/summary response used by bolometer
+type summary struct {
+	Pods []podStats `json:"pods"`
+}
+
+type podStats struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"podRef"`
+	Containers []containerStats `json:"containers"`
+
+	// EphemeralStorage is the usage of the pod's container rootfs, logs and
+	// local volumes
+	EphemeralStorage *struct {
+		UsedBytes *uint64 `json:"usedBytes"`
+	} `json:"ephemeral-storage"`
+
+	Network *networkStats `json:"network"`
+}
+
+// networkStats are the network counters of a pod, for its default interface
+// and for each of its interfaces
+type networkStats struct {
+	Time time.Time `json:"time"`
+	interfaceStats
+	Interfaces []interfaceStats `json:"interfaces"`
+}
+
+// interfaceStats are the cumulative byte counters of a network interface
+type interfaceStats struct {
+	RxBytes *uint64 `json:"rxBytes"`
+	TxBytes *uint64 `json:"txBytes"`
+}
+
+// NetworkUsage holds the cumulative network counters of a pod over all its
+// interfaces
+type NetworkUsage struct {
+	Timestamp     time.Time
+	ReceiveBytes  uint64
+	TransmitBytes uint64
+}
+
+type containerStats struct {
+	Name string `json:"name"`
+	CPU  *struct {
+		Time           time.Time `json:"time"`
+		UsageNanoCores *uint64   `json:"usageNanoCores"`
+	} `json:"cpu"`
+	Memory *struct {
+		Time            time.Time `json:"time"`
+		WorkingSetBytes *uint64   `json:"workingSetBytes"`
+		RSSBytes        *uint64   `json:"rssBytes"`
+		UsageBytes      *uint64   `json:"usageBytes"`
+	} `json:"memory"`
+}
+
+// ListPodUsage fetches the summary of every node running one of the given pods
+// and returns the usage of the pods in the namespace
+func (s *KubeletSource) ListPodUsage(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]*PodUsage, error) {
+	summaries, err := s.getSummaries(ctx, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]*PodUsage)
+	for _, nodeSummary := range summaries {
+		for name, usage := range podUsageFromSummary(nodeSummary, namespace, s.memoryMetric) {
+			usages[name] = usage
+		}
+	}
+
+	return usages, nil
+}
+
+// ListPodEphemeralStorage fetches the summary of every node running one of the
+// given pods and returns the ephemeral-storage usage of the pods in the
+// namespace, indexed by pod name
+func (s *KubeletSource) ListPodEphemeralStorage(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]resource.Quantity, error) {
+	summaries, err := s.getSummaries(ctx, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]resource.Quantity)
+	for _, nodeSummary := range summaries {
+		for name, usage := range ephemeralStorageFromSummary(nodeSummary, namespace) {
+			usages[name] = usage
+		}
+	}
+
+	return usages, nil
+}
+
+// ListPodNetwork fetches the summary of every node running one of the given
+// pods and returns the network counters of the pods in the namespace, indexed
+// by pod name
+func (s *KubeletSource) ListPodNetwork(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]NetworkUsage, error) {
+	summaries, err := s.getSummaries(ctx, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]NetworkUsage)
+	for _, nodeSummary := range summaries {
+		for name, usage := range networkFromSummary(nodeSummary, namespace) {
+			usages[name] = usage
+		}
+	}
+
+	return usages, nil
+}
+
+// nodesOf returns the nodes the given pods are scheduled on
+func nodesOf(pods []*corev1.Pod) map[string]struct{} {
+	nodes := make(map[string]struct{})
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName] = struct{}{}
+		}
+	}
+	return nodes
+}
+
+// getSummaries fetches the summaries of the nodes running the given pods. A
+// node whose summary cannot be fetched, such as a NotReady node, is logged and
+// skipped so the pods of the other nodes are still reported; it only fails when
+// every node failed
+func (s *KubeletSource) getSummaries(ctx context.Context, pods []*corev1.Pod) ([]*summary, error) {
+	logger := log.FromContext(ctx)
+
+	var summaries []*summary
+	var errs []error
+	for node := range nodesOf(pods) {
+		nodeSummary, err := s.getSummary(ctx, node)
+		if err != nil {
+			logger.Error(err, "Skipping node without a kubelet summary", "node", node)
+			errs = append(errs, err)
+			continue
+		}
+		summaries = append(summaries, nodeSummary)
+	}
+
+	if len(summaries) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return summaries, nil
+}
+
+// getSummary fetches the kubelet summary for a node
+func (s *KubeletSource) getSummary(ctx context.Context, node string) (*summary, error) {
+	data, err := s.clientset.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", node, "proxy", "stats", "summary").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubelet summary for node %s: %w", node, err)
+	}
+
+	nodeSummary := &summary{}
+	if err := json.Unmarshal(data, nodeSummary); err != nil {
+		return nil, fmt.Errorf("failed to decode kubelet summary for node %s: %w", node, err)
+	}
+
+	return nodeSummary, nil
+}
+
+// podUsageFromSummary extracts the usage of pods in a namespace from a kubelet
+// summary, reporting memory as the given metric
+func podUsageFromSummary(nodeSummary *summary, namespace, memoryMetric string) map[string]*PodUsage {
+	usages := make(map[string]*PodUsage)
+
+	for _, pod := range nodeSummary.Pods {
+		if pod.PodRef.Namespace != namespace {
+			continue
+		}
+
+		usage := &PodUsage{
+			Containers: make([]ContainerUsage, 0, len(pod.Containers)),
+		}
+
+		for _, container := range pod.Containers {
+			containerUsage := ContainerUsage{Name: container.Name}
+
+			if container.CPU != nil && container.CPU.UsageNanoCores != nil {
+				containerUsage.CPU = *resource.NewScaledQuantity(int64(*container.CPU.UsageNanoCores), resource.Nano)
+				if container.CPU.Time.After(usage.Timestamp) {
+					usage.Timestamp = container.CPU.Time
+				}
+			}
+
+			if container.Memory != nil {
+				bytes := container.Memory.WorkingSetBytes
+				switch memoryMetric {
+				case MemoryRSS:
+					bytes = container.Memory.RSSBytes
+				case MemoryUsage:
+					bytes = container.Memory.UsageBytes
+				}
+				if bytes != nil {
+					containerUsage.Memory = *resource.NewQuantity(int64(*bytes), resource.BinarySI)
+				}
+			}
+
+			usage.Containers = append(usage.Containers, containerUsage)
+		}
+
+		usages[pod.PodRef.Name] = usage
+	}
+
+	return usages
+}
+
+// ephemeralStorageFromSummary extracts the ephemeral-storage usage of pods in a
+// namespace from a kubelet summary, omitting the pods it does not report
+func ephemeralStorageFromSummary(nodeSummary *summary, namespace string) map[string]resource.Quantity {
+	usages := make(map[string]resource.Quantity)
+
+	for _, pod := range nodeSummary.Pods {
+		if pod.PodRef.Namespace != namespace || pod.EphemeralStorage == nil || pod.EphemeralStorage.UsedBytes == nil {
+			continue
+		}
+		usages[pod.PodRef.Name] = *resource.NewQuantity(int64(*pod.EphemeralStorage.UsedBytes), resource.BinarySI)
+	}
+
+	return usages
+}
+
+// networkFromSummary extracts the network counters of pods in a namespace from
+// a kubelet summary, summing the interfaces of each pod, or reading its default
+// interface when the kubelet does not list them
+func networkFromSummary(nodeSummary *summary, namespace string) map[string]NetworkUsage {
+	usages := make(map[string]NetworkUsage)
+
+	for _, pod := range nodeSummary.Pods {
+		if pod.PodRef.Namespace != namespace || pod.Network == nil {
+			continue
+		}
+
+		interfaces := pod.Network.Interfaces
+		if len(interfaces) == 0 {
+			interfaces = []interfaceStats{pod.Network.interfaceStats}
+		}
+
+		usage := NetworkUsage{Timestamp: pod.Network.Time}
+		reported := false
+		for _, stats := range interfaces {
+			if stats.RxBytes != nil {
+				usage.ReceiveBytes += *stats.RxBytes
+				reported = true
+			}
+			if stats.TxBytes != nil {
+				usage.TransmitBytes += *stats.TxBytes
+				reported = true
+			}
+		}
+		if reported {
+			usages[pod.PodRef.Name] = usage
+		}
+	}
+
+	return usages
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TaskCapture labels the panics of capture jobs
+const TaskCapture = "capture"
+
+// Panics counts the panics recovered in the monitor tasks, scheduled checks
+// and capture jobs of each config, by task. It implements prometheus.Collector.
+type Panics struct {
+	counter *prometheus.CounterVec
+}
+
+// NewPanics creates panic counters
+func NewPanics() *Panics {
+	return &Panics{
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bolometer_panics_total",
+			Help: "Panics recovered in monitor tasks, scheduled checks and captures, by config and task.",
+		}, []string{"config", "task"}),
+	}
+}
+
+// Inc counts a panic of a task of a config
+func (p *Panics) Inc(config, task string) {
+	p.counter.WithLabelValues(config, task).Inc()
+}
+
+// Forget drops the counters of a config
+func (p *Panics) Forget(config string) {
+	p.counter.DeletePartialMatch(prometheus.Labels{"config": config})
+}
+
+// Describe implements prometheus.Collector
+func (p *Panics) Describe(ch chan<- *prometheus.Desc) {
+	p.counter.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (p *Panics) Collect(ch chan<- prometheus.Metric) {
+	p.counter.Collect(ch)
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPanics(t *testing.T) {
+	panics := NewPanics()
+	panics.Inc("default/my-app", "thresholds")
+	panics.Inc("default/my-app", "thresholds")
+	panics.Inc("default/my-app", TaskCapture)
+	panics.Inc("default/other", "events")
+	panics.Forget("default/other")
+
+	expected := `
+# HELP bolometer_panics_total Panics recovered in monitor tasks, scheduled checks and captures, by config and task.
+# TYPE bolometer_panics_total counter
+bolometer_panics_total{config="default/my-app",task="capture"} 1
+bolometer_panics_total{config="default/my-app",task="thresholds"} 2
+`
+	if err := testutil.CollectAndCompare(panics, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
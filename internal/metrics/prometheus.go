@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// SourcePrometheus reads usage from a Prometheus endpoint
+	SourcePrometheus = "prometheus"
+
+	// DefaultPrometheusRateWindow is the default range used for CPU rate calculations
+	DefaultPrometheusRateWindow = 5 * time.Minute
+)
+
+// prometheusMemoryMetrics maps memory metrics to the cAdvisor metric reporting them
+var prometheusMemoryMetrics = map[string]string{
+	MemoryWorkingSet: "container_memory_working_set_bytes",
+	MemoryRSS:        "container_memory_rss",
+	MemoryUsage:      "container_memory_usage_bytes",
+}
+
+// PrometheusSource computes pod usage from cAdvisor metrics stored in Prometheus
+type PrometheusSource struct {
+	url          string
+	rateWindow   time.Duration
+	memoryMetric string
+	httpClient   *http.Client
+}
+
+// NewPrometheusSource creates a new Prometheus source for the given endpoint
+func NewPrometheusSource(prometheusURL string, rateWindow time.Duration) *PrometheusSource {
+	if rateWindow <= 0 {
+		rateWindow = DefaultPrometheusRateWindow
+	}
+
+	return &PrometheusSource{
+		url:          strings.TrimSuffix(prometheusURL, "/"),
+		rateWindow:   rateWindow,
+		memoryMetric: MemoryWorkingSet,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithMemoryMetric implements MemoryMetricSource
+func (s *PrometheusSource) WithMemoryMetric(metric string) Source {
+	source := *s
+	source.memoryMetric = metric
+	return &source
+}
+
+// PrometheusSourceName returns the collector source name for a Prometheus endpoint
+func PrometheusSourceName(prometheusURL string, rateWindow time.Duration) string {
+	return fmt.Sprintf("%s/%s/%s", SourcePrometheus, prometheusURL, rateWindow)
+}
+
+// promResponse is the subset of the Prometheus instant query response used by bolometer
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// ListPodUsage queries container CPU, memory and CPU throttling for the namespace
+func (s *PrometheusSource) ListPodUsage(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]*PodUsage, error) {
+	selector := fmt.Sprintf(`namespace=%q,container!="",container!="POD"`, namespace)
+
+	cpuQuery := fmt.Sprintf("sum by (pod, container) (rate(container_cpu_usage_seconds_total{%s}[%ds]))",
+		selector, int(s.rateWindow.Seconds()))
+	cpuSamples, err := s.query(ctx, cpuQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CPU usage: %w", err)
+	}
+
+	memoryMetric, ok := prometheusMemoryMetrics[s.memoryMetric]
+	if !ok {
+		memoryMetric = prometheusMemoryMetrics[MemoryWorkingSet]
+	}
+	memoryQuery := fmt.Sprintf("sum by (pod, container) (%s{%s})", memoryMetric, selector)
+	memorySamples, err := s.query(ctx, memoryQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memory usage: %w", err)
+	}
+
+	throttledQuery := fmt.Sprintf("sum by (pod, container) (rate(container_cpu_cfs_throttled_periods_total{%[1]s}[%[2]ds])) / "+
+		"sum by (pod, container) (rate(container_cpu_cfs_periods_total{%[1]s}[%[2]ds]))",
+		selector, int(s.rateWindow.Seconds()))
+	throttledSamples, err := s.query(ctx, throttledQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CPU throttling: %w", err)
+	}
+
+	usages := make(map[string]*PodUsage)
+	containerFor := func(pod, container string) *ContainerUsage {
+		usage, ok := usages[pod]
+		if !ok {
+			usage = &PodUsage{Timestamp: time.Now()}
+			usages[pod] = usage
+		}
+		for i := range usage.Containers {
+			if usage.Containers[i].Name == container {
+				return &usage.Containers[i]
+			}
+		}
+		usage.Containers = append(usage.Containers, ContainerUsage{Name: container})
+		return &usage.Containers[len(usage.Containers)-1]
+	}
+
+	for _, sample := range cpuSamples {
+		containerFor(sample.pod, sample.container).CPU = *resource.NewMilliQuantity(int64(sample.value*1000), resource.DecimalSI)
+	}
+	for _, sample := range memorySamples {
+		containerFor(sample.pod, sample.container).Memory = *resource.NewQuantity(int64(sample.value), resource.BinarySI)
+	}
+	for _, sample := range throttledSamples {
+		// Containers without a CPU limit have no periods, their ratio is NaN
+		if math.IsNaN(sample.value) {
+			continue
+		}
+		throttled := sample.value
+		containerFor(sample.pod, sample.container).CPUThrottled = &throttled
+	}
+
+	return usages, nil
+}
+
+// promSample is a single pod/container value from an instant query
+type promSample struct {
+	pod       string
+	container string
+	value     float64
+}
+
+// query runs an instant query and returns the resulting samples
+func (s *PrometheusSource) query(ctx context.Context, query string) ([]promSample, error) {
+	endpoint := s.url + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &promResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query failed with status %d: %s", resp.StatusCode, result.Error)
+	}
+
+	samples := make([]promSample, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		if len(r.Value) != 2 {
+			continue
+		}
+		raw, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, promSample{
+			pod:       r.Metric["pod"],
+			container: r.Metric["container"],
+			value:     value,
+		})
+	}
+
+	return samples, nil
+}
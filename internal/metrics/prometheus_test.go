@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusSource_ListPodUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.Contains(query, `namespace="default"`) {
+			t.Errorf("expected query to select namespace, got %s", query)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(query, "container_cpu_cfs_throttled_periods_total") {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"pod":"pod-1","container":"app"},"value":[1700000000,"0.4"]},
+				{"metric":{"pod":"pod-1","container":"sidecar"},"value":[1700000000,"NaN"]}
+			]}}`))
+			return
+		}
+		if strings.Contains(query, "container_cpu_usage_seconds_total") {
+			if !strings.Contains(query, "[120s]") {
+				t.Errorf("expected rate window of 120s, got %s", query)
+			}
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"pod":"pod-1","container":"app"},"value":[1700000000,"0.25"]},
+				{"metric":{"pod":"pod-1","container":"sidecar"},"value":[1700000000,"0.05"]}
+			]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"pod":"pod-1","container":"app"},"value":[1700000000,"134217728"]}
+		]}}`))
+	}))
+	defer server.Close()
+
+	source := NewPrometheusSource(server.URL, 2*time.Minute)
+
+	usages, err := source.ListPodUsage(context.Background(), "default", nil)
+	if err != nil {
+		t.Fatalf("ListPodUsage returned error: %v", err)
+	}
+
+	usage, ok := usages["pod-1"]
+	if !ok {
+		t.Fatal("expected usage for pod-1")
+	}
+
+	if len(usage.Containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(usage.Containers))
+	}
+
+	for _, container := range usage.Containers {
+		switch container.Name {
+		case "app":
+			if got := container.CPU.MilliValue(); got != 250 {
+				t.Errorf("expected 250m CPU for app, got %dm", got)
+			}
+			if got := container.Memory.Value(); got != 134217728 {
+				t.Errorf("expected 128Mi memory for app, got %d", got)
+			}
+			if container.CPUThrottled == nil || *container.CPUThrottled != 0.4 {
+				t.Errorf("expected 40%% of CPU periods throttled for app, got %v", container.CPUThrottled)
+			}
+		case "sidecar":
+			if got := container.CPU.MilliValue(); got != 50 {
+				t.Errorf("expected 50m CPU for sidecar, got %dm", got)
+			}
+			if container.CPUThrottled != nil {
+				t.Errorf("expected no throttling for sidecar without CPU limit, got %v", *container.CPUThrottled)
+			}
+		default:
+			t.Errorf("unexpected container %s", container.Name)
+		}
+	}
+}
+
+func TestPrometheusSource_QueryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	}))
+	defer server.Close()
+
+	source := NewPrometheusSource(server.URL, 0)
+
+	if _, err := source.ListPodUsage(context.Background(), "default", nil); err == nil {
+		t.Error("expected error for failed query")
+	}
+}
+
+func TestPrometheusSource_MemoryMetric(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("query"))
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	source := NewPrometheusSource(server.URL, 0).WithMemoryMetric(MemoryRSS)
+	if _, err := source.ListPodUsage(context.Background(), "default", nil); err != nil {
+		t.Fatalf("ListPodUsage returned error: %v", err)
+	}
+
+	if len(queries) != 3 || !strings.Contains(queries[1], "container_memory_rss{") {
+		t.Errorf("expected the memory query to read container_memory_rss, got %v", queries)
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SchedulerMetrics export the checks of the trigger sources run by the
+// scheduler: how many are scheduled, how late they start and how long they
+// take, by source. It implements prometheus.Collector.
+type SchedulerMetrics struct {
+	scheduled prometheus.Gauge
+	lag       *prometheus.HistogramVec
+	duration  *prometheus.HistogramVec
+}
+
+// NewSchedulerMetrics creates scheduler metrics
+func NewSchedulerMetrics() *SchedulerMetrics {
+	return &SchedulerMetrics{
+		scheduled: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "bolometer_scheduled_checks",
+			Help: "Checks of trigger sources scheduled across all configs.",
+		}),
+		lag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "bolometer_check_lag_seconds",
+			Help:    "Delay between when a check was due and when a check worker started it, by source.",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 15, 60},
+		}, []string{"source"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "bolometer_check_duration_seconds",
+			Help:    "Duration of the checks of trigger sources, by source.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"source"}),
+	}
+}
+
+// SetScheduled sets the number of scheduled checks
+func (m *SchedulerMetrics) SetScheduled(checks int) {
+	m.scheduled.Set(float64(checks))
+}
+
+// ObserveCheck records a check of a source that started lag after it was due
+// and ran for duration
+func (m *SchedulerMetrics) ObserveCheck(source string, lag, duration time.Duration) {
+	m.lag.WithLabelValues(source).Observe(max(lag, 0).Seconds())
+	m.duration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+// Describe implements prometheus.Collector
+func (m *SchedulerMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.scheduled.Describe(ch)
+	m.lag.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *SchedulerMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.scheduled.Collect(ch)
+	m.lag.Collect(ch)
+	m.duration.Collect(ch)
+}
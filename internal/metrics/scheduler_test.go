@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSchedulerMetrics(t *testing.T) {
+	schedulerMetrics := NewSchedulerMetrics()
+	schedulerMetrics.SetScheduled(3)
+	schedulerMetrics.ObserveCheck("thresholds", 200*time.Millisecond, time.Second)
+	schedulerMetrics.ObserveCheck("thresholds", -time.Second, time.Second)
+	schedulerMetrics.ObserveCheck("on-demand", 0, 10*time.Millisecond)
+
+	expected := `
+# HELP bolometer_scheduled_checks Checks of trigger sources scheduled across all configs.
+# TYPE bolometer_scheduled_checks gauge
+bolometer_scheduled_checks 3
+`
+	if err := testutil.CollectAndCompare(schedulerMetrics, strings.NewReader(expected), "bolometer_scheduled_checks"); err != nil {
+		t.Error(err)
+	}
+
+	// One series per source in each histogram
+	if count := testutil.CollectAndCount(schedulerMetrics, "bolometer_check_lag_seconds", "bolometer_check_duration_seconds"); count != 4 {
+		t.Errorf("Expected 4 histogram series, got %d", count)
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+const (
+	// SourceMetricsServer reads usage from the metrics.k8s.io API
+	SourceMetricsServer = "metrics-server"
+
+	// SourceKubelet reads usage from the kubelet Summary API
+	SourceKubelet = "kubelet"
+)
+
+const (
+	// MemoryWorkingSet reports memory as the working set, what the kubelet
+	// evicts pods on and what metrics-server reports
+	MemoryWorkingSet = "workingSet"
+
+	// MemoryRSS reports memory as the resident set size, excluding the page cache
+	MemoryRSS = "rss"
+
+	// MemoryUsage reports memory as the total usage, including the page cache
+	MemoryUsage = "usage"
+)
+
+// Source provides raw resource usage for pods
+type Source interface {
+	// ListPodUsage returns the usage of the given pods in a namespace indexed by pod name
+	ListPodUsage(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]*PodUsage, error)
+}
+
+// MemoryMetricSource is implemented by the sources able to report memory as
+// another metric than the working set
+type MemoryMetricSource interface {
+	Source
+
+	// WithMemoryMetric returns a copy of the source reporting memory as the
+	// given metric
+	WithMemoryMetric(metric string) Source
+}
+
+// PodUsage is the raw resource usage of a pod as reported by a source
+type PodUsage struct {
+	Timestamp  time.Time
+	Containers []ContainerUsage
+}
+
+// ContainerUsage is the raw resource usage of a single container. Memory is
+// the working set unless the source reports another memory metric.
+type ContainerUsage struct {
+	Name   string
+	CPU    resource.Quantity
+	Memory resource.Quantity
+
+	// CPUThrottled is the share of CPU periods the container was throttled
+	// in, from 0 to 1, nil if the source does not report throttling
+	CPUThrottled *float64
+}
+
+// MetricsServerSource reads pod usage from metrics-server
+type MetricsServerSource struct {
+	metricsClient metricsv.Interface
+}
+
+// NewMetricsServerSource creates a new metrics-server source
+func NewMetricsServerSource(metricsClient metricsv.Interface) *MetricsServerSource {
+	return &MetricsServerSource{
+		metricsClient: metricsClient,
+	}
+}
+
+// ListPodUsage lists PodMetrics for the namespace with a single call
+func (s *MetricsServerSource) ListPodUsage(ctx context.Context, namespace string, pods []*corev1.Pod) (map[string]*PodUsage, error) {
+	podMetricsList, err := s.metricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod metrics: %w", err)
+	}
+
+	usages := make(map[string]*PodUsage, len(podMetricsList.Items))
+	for i := range podMetricsList.Items {
+		usages[podMetricsList.Items[i].Name] = podUsageFromMetrics(&podMetricsList.Items[i])
+	}
+
+	return usages, nil
+}
+
+// podUsageFromMetrics converts a metrics.k8s.io PodMetrics into PodUsage
+func podUsageFromMetrics(podMetrics *v1beta1.PodMetrics) *PodUsage {
+	usage := &PodUsage{
+		Timestamp:  podMetrics.Timestamp.Time,
+		Containers: make([]ContainerUsage, 0, len(podMetrics.Containers)),
+	}
+
+	for _, container := range podMetrics.Containers {
+		usage.Containers = append(usage.Containers, ContainerUsage{
+			Name:   container.Name,
+			CPU:    container.Usage[corev1.ResourceCPU],
+			Memory: container.Usage[corev1.ResourceMemory],
+		})
+	}
+
+	return usage
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons captures are suppressed for
+const (
+	// SuppressedCooldown counts threshold violations of pods in cooldown
+	SuppressedCooldown = "cooldown"
+
+	// SuppressedBackoff counts captures held back after failed captures
+	SuppressedBackoff = "backoff"
+
+	// SuppressedQuarantine counts captures held back from quarantined pods
+	SuppressedQuarantine = "quarantine"
+
+	// SuppressedRateLimit counts threshold violations deferred by
+	// maxCapturesPerCheck
+	SuppressedRateLimit = "rate-limit"
+
+	// SuppressedConcurrency counts captures not queued because the pod was
+	// already queued or maxConcurrentCaptures was reached
+	SuppressedConcurrency = "concurrency"
+
+	// SuppressedSampling counts threshold violations left out by
+	// samplingPercent
+	SuppressedSampling = "sampling"
+
+	// SuppressedNodePressure counts captures held back from pressured nodes
+	SuppressedNodePressure = "node-pressure"
+
+	// SuppressedBudget counts captures skipped or reduced over the storage
+	// budget
+	SuppressedBudget = "budget"
+
+	// SuppressedCircuitOpen counts captures paused or queued while uploads to
+	// the destination keep failing
+	SuppressedCircuitOpen = "circuit-open"
+
+	// SuppressedDedup counts profiles not stored as duplicates of the previous
+	// ones of the pod
+	SuppressedDedup = "dedup"
+)
+
+// Suppressions counts the captures that did not happen, by config and reason,
+// as a Prometheus counter. It implements prometheus.Collector.
+type Suppressions struct {
+	counter *prometheus.CounterVec
+}
+
+// NewSuppressions creates suppression counters
+func NewSuppressions() *Suppressions {
+	return &Suppressions{
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bolometer_captures_suppressed_total",
+			Help: "Captures that did not happen, by config and reason. Dedup counts profiles not stored.",
+		}, []string{"config", "reason"}),
+	}
+}
+
+// Inc counts a capture of a config suppressed for reason
+func (s *Suppressions) Inc(config, reason string) {
+	s.counter.WithLabelValues(config, reason).Inc()
+}
+
+// Forget drops the counters of a config
+func (s *Suppressions) Forget(config string) {
+	s.counter.DeletePartialMatch(prometheus.Labels{"config": config})
+}
+
+// Describe implements prometheus.Collector
+func (s *Suppressions) Describe(ch chan<- *prometheus.Desc) {
+	s.counter.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (s *Suppressions) Collect(ch chan<- prometheus.Metric) {
+	s.counter.Collect(ch)
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSuppressions(t *testing.T) {
+	suppressions := NewSuppressions()
+	suppressions.Inc("default/my-app", SuppressedCooldown)
+	suppressions.Inc("default/my-app", SuppressedCooldown)
+	suppressions.Inc("default/my-app", SuppressedBudget)
+	suppressions.Inc("default/other", SuppressedDedup)
+	suppressions.Forget("default/other")
+
+	expected := `
+# HELP bolometer_captures_suppressed_total Captures that did not happen, by config and reason. Dedup counts profiles not stored.
+# TYPE bolometer_captures_suppressed_total counter
+bolometer_captures_suppressed_total{config="default/my-app",reason="budget"} 1
+bolometer_captures_suppressed_total{config="default/my-app",reason="cooldown"} 2
+`
+	if err := testutil.CollectAndCompare(suppressions, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// implicitTLSPort is the SMTP submission port speaking TLS from the start
+const implicitTLSPort = "465"
+
+// SMTPConfig describes an SMTP server and the envelope of the emails sent through it
+type SMTPConfig struct {
+	// Server is the host:port of the server. Port 465 uses implicit TLS, other
+	// ports upgrade with STARTTLS when the server offers it.
+	Server string
+
+	// Username and Password authenticate with PLAIN auth unless Username is empty
+	Username string
+	Password string
+
+	From string
+	To   []string
+}
+
+// EmailNotifier emails a summary of capture events
+type EmailNotifier struct {
+	config SMTPConfig
+
+	// tlsConfig is used for implicit TLS and STARTTLS, verified against the system roots by default
+	tlsConfig *tls.Config
+}
+
+// NewEmailNotifier creates a notifier sending through the server of config
+func NewEmailNotifier(config SMTPConfig) *EmailNotifier {
+	host, _, _ := net.SplitHostPort(config.Server)
+	return &EmailNotifier{
+		config:    config,
+		tlsConfig: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12},
+	}
+}
+
+// Notify emails a summary of the capture
+func (e *EmailNotifier) Notify(ctx context.Context, event Event) error {
+	if err := e.send(ctx, emailMessage(e.config.From, e.config.To, event)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// send delivers a message over a single SMTP session
+func (e *EmailNotifier) send(ctx context.Context, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	host, port, err := net.SplitHostPort(e.config.Server)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP server %q: %w", e.config.Server, err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.config.Server)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if port == implicitTLSPort {
+		conn = tls.Client(conn, e.tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != implicitTLSPort {
+		if err := client.StartTLS(e.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if e.config.Username != "" {
+		// PLAIN auth refuses to send credentials over unencrypted connections
+		// to anything but localhost
+		if err := client.Auth(smtp.PlainAuth("", e.config.Username, e.config.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.config.From); err != nil {
+		return err
+	}
+	for _, to := range e.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailMessage formats an event as a plain text email
+func emailMessage(from string, to []string, event Event) []byte {
+	pod := event.Pod.Namespace + "/" + event.Pod.Name
+	if event.Pod.Cluster != "" {
+		pod = event.Pod.Cluster + "/" + pod
+	}
+	subject := fmt.Sprintf("[bolometer] Profile captured for %s", pod)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "Profiles were captured for %s by config %s.\r\n\r\n", pod, event.Config)
+	fmt.Fprintf(&b, "Reason: %s\r\n", event.Reason)
+	if trigger := event.Trigger; trigger != nil {
+		fmt.Fprintf(&b, "CPU: %.2f%% of requests (%s, threshold %d%%)\r\n",
+			trigger.CPUUsagePercent, trigger.CPUUsage, trigger.CPUThresholdPercent)
+		fmt.Fprintf(&b, "Memory: %.2f%% of requests (%s, threshold %d%%)\r\n",
+			trigger.MemoryUsagePercent, trigger.MemoryUsage, trigger.MemoryThresholdPercent)
+	}
+
+	if lines := leakLines(event); len(lines) > 0 {
+		b.WriteString("\r\nProbable leaks:\r\n")
+		for _, line := range lines {
+			fmt.Fprintf(&b, "  %s\r\n", line)
+		}
+	}
+
+	if lines := topFunctionLines(event); len(lines) > 0 {
+		b.WriteString("\r\nTop functions:\r\n")
+		for _, line := range lines {
+			fmt.Fprintf(&b, "  %s\r\n", line)
+		}
+	}
+
+	if len(event.Links) > 0 {
+		b.WriteString("\r\nProfiles:\r\n")
+		for _, link := range event.Links {
+			fmt.Fprintf(&b, "  %s: %s\r\n", link.Type, link.URL)
+		}
+	}
+
+	return []byte(b.String())
+}
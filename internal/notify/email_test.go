@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveSMTP answers a single SMTP session on l, returning the received message
+func serveSMTP(l net.Listener) <-chan string {
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "DATA"):
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(command, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return received
+}
+
+func TestEmailNotifier_Notify(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	received := serveSMTP(l)
+
+	notifier := NewEmailNotifier(SMTPConfig{
+		Server: l.Addr().String(),
+		From:   "bolometer@example.com",
+		To:     []string{"oncall@example.com", "perf@example.com"},
+	})
+	if err := notifier.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+
+	message := <-received
+	for _, expected := range []string{
+		"To: oncall@example.com, perf@example.com",
+		"Subject: [bolometer] Profile captured for default/my-app-0",
+		"Reason: cpu threshold exceeded",
+		"heap: https://example.com/heap",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected %q in message:\n%s", expected, message)
+		}
+	}
+}
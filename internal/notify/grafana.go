@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GrafanaNotifier annotates Grafana dashboards with capture events
+type GrafanaNotifier struct {
+	url          string
+	token        string
+	dashboardUID string
+	tags         []string
+	client       *http.Client
+}
+
+// NewGrafanaNotifier creates a notifier posting annotations to the Grafana at
+// url, authenticated with a service account token. Annotations are limited to
+// a dashboard unless dashboardUID is empty, and carry tags besides their own.
+func NewGrafanaNotifier(url, token, dashboardUID string, tags []string) *GrafanaNotifier {
+	return &GrafanaNotifier{
+		url:          strings.TrimSuffix(url, "/"),
+		token:        token,
+		dashboardUID: dashboardUID,
+		tags:         tags,
+		client:       &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// grafanaAnnotation is the payload of the Grafana annotations API
+type grafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// Notify posts an annotation spanning the capture
+func (g *GrafanaNotifier) Notify(ctx context.Context, event Event) error {
+	start := event.Time
+	if start.IsZero() {
+		start = time.Now()
+	}
+	end := start.Add(time.Duration(event.DurationSeconds * float64(time.Second)))
+
+	annotation := grafanaAnnotation{
+		DashboardUID: g.dashboardUID,
+		Time:         start.UnixMilli(),
+		Tags:         append(grafanaTags(event), g.tags...),
+		Text:         grafanaText(event),
+	}
+	if end.After(start) {
+		annotation.TimeEnd = end.UnixMilli()
+	}
+
+	body, err := json.Marshal(annotation)
+	if err != nil {
+		return fmt.Errorf("failed to encode Grafana annotation: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+g.token)
+	if err := postJSON(ctx, g.client, g.url+"/api/annotations", body, header); err != nil {
+		return fmt.Errorf("failed to post to Grafana: %w", err)
+	}
+	return nil
+}
+
+// grafanaTags returns the tags identifying an event on dashboards
+func grafanaTags(event Event) []string {
+	tags := []string{"bolometer", "namespace:" + event.Pod.Namespace, "reason:" + event.Reason}
+	if event.Service != "" {
+		tags = append(tags, "service:"+event.Service)
+	}
+	if event.Pod.Cluster != "" {
+		tags = append(tags, "cluster:"+event.Pod.Cluster)
+	}
+	return tags
+}
+
+// grafanaText formats an event as annotation text. Grafana renders it as HTML.
+func grafanaText(event Event) string {
+	pod := event.Pod.Namespace + "/" + event.Pod.Name
+	if event.Pod.Cluster != "" {
+		pod = event.Pod.Cluster + "/" + pod
+	}
+
+	text := fmt.Sprintf("Profile captured for %s: %s", html.EscapeString(pod), html.EscapeString(event.Reason))
+	if len(event.Links) > 0 {
+		links := make([]string, 0, len(event.Links))
+		for _, link := range event.Links {
+			links = append(links, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link.URL), html.EscapeString(link.Type)))
+		}
+		text += "<br>" + strings.Join(links, " | ")
+	}
+	return text
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrafanaNotifier_Notify(t *testing.T) {
+	var received grafanaAnnotation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer glsa_token" {
+			t.Errorf("Unexpected authorization %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode annotation: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	event := testEvent()
+	event.Time = time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+	event.DurationSeconds = 30
+	event.Service = "my-app"
+
+	notifier := NewGrafanaNotifier(server.URL+"/", "glsa_token", "abc123", []string{"team:payments"})
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+
+	if received.DashboardUID != "abc123" || received.Time != event.Time.UnixMilli() ||
+		received.TimeEnd != event.Time.Add(30*time.Second).UnixMilli() {
+		t.Errorf("Unexpected annotation: %+v", received)
+	}
+
+	tags := strings.Join(received.Tags, ",")
+	for _, expected := range []string{"bolometer", "service:my-app", "namespace:default", "reason:cpu threshold exceeded", "team:payments"} {
+		if !strings.Contains(tags, expected) {
+			t.Errorf("Expected tag %q in %v", expected, received.Tags)
+		}
+	}
+	if !strings.Contains(received.Text, `<a href="https://example.com/heap">heap</a>`) {
+		t.Errorf("Expected profile links in text: %s", received.Text)
+	}
+}
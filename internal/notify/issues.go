@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Issue trackers
+const (
+	TrackerGitHub = "GitHub"
+	TrackerGitLab = "GitLab"
+	TrackerJira   = "Jira"
+)
+
+// Default API base URLs of the hosted trackers
+const (
+	DefaultGitHubURL = "https://api.github.com"
+	DefaultGitLabURL = "https://gitlab.com"
+)
+
+// IssueConfig describes where issues are filed
+type IssueConfig struct {
+	// Tracker is one of TrackerGitHub, TrackerGitLab or TrackerJira
+	Tracker string
+
+	// URL is the API base URL of the tracker
+	URL string
+
+	// Project is the owner/repo on GitHub, the project ID or path on GitLab and
+	// the project key on Jira
+	Project string
+
+	// Token authenticates to the tracker. On Jira, email:token authenticates
+	// with basic auth and anything else as a bearer token.
+	Token string
+
+	// IssueType is the Jira issue type
+	IssueType string
+
+	Labels []string
+}
+
+// IssueNotifier files an issue describing a capture
+type IssueNotifier struct {
+	config IssueConfig
+	client *http.Client
+}
+
+// NewIssueNotifier creates a notifier filing issues in the project of config
+func NewIssueNotifier(config IssueConfig) *IssueNotifier {
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return &IssueNotifier{
+		config: config,
+		client: &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// Notify files an issue for the event
+func (n *IssueNotifier) Notify(ctx context.Context, event Event) error {
+	title := issueTitle(event)
+
+	var (
+		endpoint string
+		payload  any
+		header   = http.Header{}
+	)
+	switch n.config.Tracker {
+	case TrackerGitHub:
+		endpoint = n.config.URL + "/repos/" + n.config.Project + "/issues"
+		payload = map[string]any{
+			"title":  title,
+			"body":   issueMarkdown(event),
+			"labels": n.config.Labels,
+		}
+		header.Set("Authorization", "Bearer "+n.config.Token)
+		header.Set("Accept", "application/vnd.github+json")
+	case TrackerGitLab:
+		endpoint = n.config.URL + "/api/v4/projects/" + url.PathEscape(n.config.Project) + "/issues"
+		payload = map[string]any{
+			"title":       title,
+			"description": issueMarkdown(event),
+			"labels":      strings.Join(n.config.Labels, ","),
+		}
+		header.Set("PRIVATE-TOKEN", n.config.Token)
+	case TrackerJira:
+		endpoint = n.config.URL + "/rest/api/2/issue"
+		payload = map[string]any{
+			"fields": map[string]any{
+				"project":     map[string]string{"key": n.config.Project},
+				"issuetype":   map[string]string{"name": n.config.IssueType},
+				"summary":     title,
+				"description": issueJiraText(event),
+				"labels":      n.config.Labels,
+			},
+		}
+		header.Set("Authorization", jiraAuthorization(n.config.Token))
+	default:
+		return fmt.Errorf("unsupported issue tracker %q", n.config.Tracker)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode issue: %w", err)
+	}
+
+	if err := postJSON(ctx, n.client, endpoint, body, header); err != nil {
+		return fmt.Errorf("failed to file %s issue: %w", n.config.Tracker, err)
+	}
+	return nil
+}
+
+// jiraAuthorization returns the Authorization header of a Jira token: basic
+// auth for Jira Cloud email:token pairs, bearer for personal access tokens
+func jiraAuthorization(token string) string {
+	if strings.Contains(token, ":") {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(token))
+	}
+	return "Bearer " + token
+}
+
+// issueTitle returns the title of the issue filed for an event
+func issueTitle(event Event) string {
+	service := event.Service
+	if service == "" {
+		service = event.Pod.Name
+	}
+	return fmt.Sprintf("Profile captured for %s in %s: %s", service, event.Pod.Namespace, event.Reason)
+}
+
+// issueMarkdown formats an event as a GitHub or GitLab issue body
+func issueMarkdown(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Bolometer captured profiles of `%s` after it exceeded its thresholds.\n\n", issuePod(event))
+	fmt.Fprintf(&b, "- **Config:** `%s`\n", event.Config)
+	fmt.Fprintf(&b, "- **Reason:** %s\n", event.Reason)
+	fmt.Fprintf(&b, "- **Captured at:** %s\n", event.Time.UTC().Format("2006-01-02 15:04:05 UTC"))
+	if trigger := event.Trigger; trigger != nil {
+		fmt.Fprintf(&b, "- **CPU:** %.2f%% of requests (%s, threshold %d%%)\n",
+			trigger.CPUUsagePercent, trigger.CPUUsage, trigger.CPUThresholdPercent)
+		fmt.Fprintf(&b, "- **Memory:** %.2f%% of requests (%s, threshold %d%%)\n",
+			trigger.MemoryUsagePercent, trigger.MemoryUsage, trigger.MemoryThresholdPercent)
+	}
+
+	if len(event.TopFunctions) > 0 {
+		b.WriteString("\n### Top functions\n")
+		for _, summary := range event.TopFunctions {
+			fmt.Fprintf(&b, "\n**%s** %s (total %s)\n\n", summary.Type, summary.SampleType, summary.FormatValue(summary.Total))
+			b.WriteString("| Function | Flat | Flat% | Cum | Cum% |\n|---|---:|---:|---:|---:|\n")
+			for _, fn := range summary.TopFlat {
+				fmt.Fprintf(&b, "| `%s` | %s | %.2f%% | %s | %.2f%% |\n",
+					fn.Name, summary.FormatValue(fn.Flat), fn.FlatPercent, summary.FormatValue(fn.Cum), fn.CumPercent)
+			}
+		}
+	}
+
+	if len(event.Links) > 0 {
+		b.WriteString("\n### Profiles\n\n")
+		for _, link := range event.Links {
+			fmt.Fprintf(&b, "- [%s](%s)\n", link.Type, link.URL)
+		}
+	}
+	if event.Manifest != "" {
+		fmt.Fprintf(&b, "\nManifest: `s3://%s/%s`\n", event.Bucket, event.Manifest)
+	}
+	if event.Summary != "" {
+		fmt.Fprintf(&b, "Full report: `s3://%s/%s`\n", event.Bucket, event.Summary)
+	}
+
+	return b.String()
+}
+
+// issueJiraText formats an event in Jira wiki markup
+func issueJiraText(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Bolometer captured profiles of {{%s}} after it exceeded its thresholds.\n\n", issuePod(event))
+	fmt.Fprintf(&b, "* *Config:* {{%s}}\n", event.Config)
+	fmt.Fprintf(&b, "* *Reason:* %s\n", event.Reason)
+	fmt.Fprintf(&b, "* *Captured at:* %s\n", event.Time.UTC().Format("2006-01-02 15:04:05 UTC"))
+	if trigger := event.Trigger; trigger != nil {
+		fmt.Fprintf(&b, "* *CPU:* %.2f%% of requests (%s, threshold %d%%)\n",
+			trigger.CPUUsagePercent, trigger.CPUUsage, trigger.CPUThresholdPercent)
+		fmt.Fprintf(&b, "* *Memory:* %.2f%% of requests (%s, threshold %d%%)\n",
+			trigger.MemoryUsagePercent, trigger.MemoryUsage, trigger.MemoryThresholdPercent)
+	}
+
+	if len(event.TopFunctions) > 0 {
+		b.WriteString("\nh3. Top functions\n")
+		for _, summary := range event.TopFunctions {
+			fmt.Fprintf(&b, "\n*%s* %s (total %s)\n\n", summary.Type, summary.SampleType, summary.FormatValue(summary.Total))
+			b.WriteString("||Function||Flat||Flat%||Cum||Cum%||\n")
+			for _, fn := range summary.TopFlat {
+				fmt.Fprintf(&b, "|{{%s}}|%s|%.2f%%|%s|%.2f%%|\n",
+					fn.Name, summary.FormatValue(fn.Flat), fn.FlatPercent, summary.FormatValue(fn.Cum), fn.CumPercent)
+			}
+		}
+	}
+
+	if len(event.Links) > 0 {
+		b.WriteString("\nh3. Profiles\n\n")
+		for _, link := range event.Links {
+			fmt.Fprintf(&b, "* [%s|%s]\n", link.Type, link.URL)
+		}
+	}
+	if event.Manifest != "" {
+		fmt.Fprintf(&b, "\nManifest: {{s3://%s/%s}}\n", event.Bucket, event.Manifest)
+	}
+	if event.Summary != "" {
+		fmt.Fprintf(&b, "Full report: {{s3://%s/%s}}\n", event.Bucket, event.Summary)
+	}
+
+	return b.String()
+}
+
+// issuePod returns the qualified name of the pod of an event
+func issuePod(event Event) string {
+	pod := event.Pod.Namespace + "/" + event.Pod.Name
+	if event.Pod.Cluster != "" {
+		pod = event.Pod.Cluster + "/" + pod
+	}
+	return pod
+}
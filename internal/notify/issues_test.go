@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIssueNotifier_Trackers(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     IssueConfig
+		path       string
+		authHeader string
+		auth       string
+		bodyField  func(payload map[string]any) string
+	}{
+		{
+			name:       "GitHub",
+			config:     IssueConfig{Tracker: TrackerGitHub, Project: "acme/my-app", Token: "ghp_token"},
+			path:       "/repos/acme/my-app/issues",
+			authHeader: "Authorization",
+			auth:       "Bearer ghp_token",
+			bodyField:  func(payload map[string]any) string { return payload["body"].(string) },
+		},
+		{
+			name:       "GitLab",
+			config:     IssueConfig{Tracker: TrackerGitLab, Project: "acme/my-app", Token: "glpat_token"},
+			path:       "/api/v4/projects/acme%2Fmy-app/issues",
+			authHeader: "PRIVATE-TOKEN",
+			auth:       "glpat_token",
+			bodyField:  func(payload map[string]any) string { return payload["description"].(string) },
+		},
+		{
+			name:       "Jira",
+			config:     IssueConfig{Tracker: TrackerJira, Project: "OPS", IssueType: "Bug", Token: "me@example.com:api-token"},
+			path:       "/rest/api/2/issue",
+			authHeader: "Authorization",
+			auth:       "Basic bWVAZXhhbXBsZS5jb206YXBpLXRva2Vu",
+			bodyField: func(payload map[string]any) string {
+				return payload["fields"].(map[string]any)["description"].(string)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != tt.path {
+					t.Errorf("Expected path %s, got %s", tt.path, r.URL.EscapedPath())
+				}
+				if auth := r.Header.Get(tt.authHeader); auth != tt.auth {
+					t.Errorf("Expected %s %q, got %q", tt.authHeader, tt.auth, auth)
+				}
+				var payload map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("Failed to decode issue: %v", err)
+				}
+				body = tt.bodyField(payload)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			tt.config.URL = server.URL
+			if err := NewIssueNotifier(tt.config).Notify(context.Background(), testEvent()); err != nil {
+				t.Fatalf("Notify returned unexpected error: %v", err)
+			}
+			if !strings.Contains(body, "https://example.com/heap") || !strings.Contains(body, "default/my-app-0") {
+				t.Errorf("Unexpected issue body: %s", body)
+			}
+		})
+	}
+}
+
+func TestIssueTitle(t *testing.T) {
+	event := testEvent()
+	event.Service = "my-app"
+	if title := issueTitle(event); title != "Profile captured for my-app in default: cpu threshold exceeded" {
+		t.Errorf("Unexpected title %q", title)
+	}
+}
+
+func TestIssueMarkdown_TopFunctions(t *testing.T) {
+	body := issueMarkdown(testEvent())
+	for _, expected := range []string{
+		"**heap** inuse_space (total 4.0MiB)",
+		"| `bytes.growSlice` | 3.0MiB | 75.00% | 3.0MiB | 75.00% |",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected issue body to contain %q, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestJiraAuthorization(t *testing.T) {
+	if auth := jiraAuthorization("pat-token"); auth != "Bearer pat-token" {
+		t.Errorf("Expected bearer auth for a personal access token, got %q", auth)
+	}
+}
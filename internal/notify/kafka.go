@@ -0,0 +1,245 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Kafka value formats
+const (
+	KafkaFormatJSON           = "JSON"
+	KafkaFormatJSONWithSchema = "JSONWithSchema"
+)
+
+// Kafka SASL mechanisms
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// KafkaConfig describes a Kafka topic and how to reach its brokers
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+	Format  string
+	TLS     bool
+
+	// SASLMechanism authenticates with Username and Password unless empty
+	SASLMechanism string
+	Username      string
+	Password      string
+}
+
+// messageWriter is the part of the Kafka writer used by KafkaNotifier
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaNotifier produces capture events to a Kafka topic, keyed by pod so the
+// events of a pod stay in order
+type KafkaNotifier struct {
+	format    string
+	writer    messageWriter
+	transport *kafka.Transport
+}
+
+// NewKafkaNotifier creates a notifier producing to the topic of cfg. Close it
+// once done.
+func NewKafkaNotifier(cfg KafkaConfig) (*KafkaNotifier, error) {
+	transport := &kafka.Transport{DialTimeout: deliveryTimeout}
+	if cfg.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if cfg.SASLMechanism != "" {
+		mechanism, err := saslMechanism(cfg.SASLMechanism, cfg.Username, cfg.Password)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+
+	return &KafkaNotifier{
+		format:    cfg.Format,
+		transport: transport,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			WriteTimeout: deliveryTimeout,
+			Transport:    transport,
+		},
+	}, nil
+}
+
+// saslMechanism returns the SASL mechanism of the given name
+func saslMechanism(name, username, password string) (sasl.Mechanism, error) {
+	switch name {
+	case SASLPlain:
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, username, password)
+	case SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", name)
+	}
+}
+
+// Notify produces the event and waits for every in-sync replica to acknowledge it
+func (k *KafkaNotifier) Notify(ctx context.Context, event Event) error {
+	value, err := kafkaValue(k.format, event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	key := event.Pod.Namespace + "/" + event.Pod.Name
+	if event.Pod.Cluster != "" {
+		key = event.Pod.Cluster + "/" + key
+	}
+
+	message := kafka.Message{
+		Key:   []byte(key),
+		Value: value,
+		Time:  event.Time,
+		Headers: []kafka.Header{
+			{Key: "event", Value: []byte(captureEvent)},
+			{Key: "outcome", Value: []byte(event.Outcome)},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	if err := k.writer.WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to produce to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close flushes the writer and closes its broker connections
+func (k *KafkaNotifier) Close() error {
+	err := k.writer.Close()
+	if k.transport != nil {
+		k.transport.CloseIdleConnections()
+	}
+	return err
+}
+
+// kafkaValue encodes an event in a Kafka value format
+func kafkaValue(format string, event Event) ([]byte, error) {
+	if format != KafkaFormatJSONWithSchema {
+		return json.Marshal(event)
+	}
+
+	return json.Marshal(struct {
+		Schema  connectSchema `json:"schema"`
+		Payload Event         `json:"payload"`
+	}{Schema: eventSchema, Payload: event})
+}
+
+// connectSchema is a Kafka Connect schema, as embedded in JsonConverter messages
+type connectSchema struct {
+	Type     string          `json:"type"`
+	Name     string          `json:"name,omitempty"`
+	Version  int             `json:"version,omitempty"`
+	Optional bool            `json:"optional"`
+	Field    string          `json:"field,omitempty"`
+	Fields   []connectSchema `json:"fields,omitempty"`
+	Items    *connectSchema  `json:"items,omitempty"`
+}
+
+// schemaField returns a named field of a schema
+func schemaField(name, schemaType string, optional bool) connectSchema {
+	return connectSchema{Type: schemaType, Optional: optional, Field: name}
+}
+
+// structField returns a named struct field of a schema
+func structField(name string, optional bool, fields ...connectSchema) connectSchema {
+	return connectSchema{Type: "struct", Optional: optional, Field: name, Fields: fields}
+}
+
+// arrayField returns a named array field of a schema
+func arrayField(name string, items connectSchema) connectSchema {
+	return connectSchema{Type: "array", Optional: true, Field: name, Items: &items}
+}
+
+// eventSchema describes Event, field for field. Times are RFC 3339 strings.
+var eventSchema = connectSchema{
+	Type:    "struct",
+	Name:    "io.bolometer.CaptureEvent",
+	Version: 1,
+	Fields: []connectSchema{
+		schemaField("time", "string", false),
+		schemaField("capture", "string", true),
+		schemaField("config", "string", false),
+		schemaField("triggeredBy", "string", false),
+		schemaField("reason", "string", false),
+		structField("trigger", true,
+			schemaField("cpuUsagePercent", "double", false),
+			schemaField("memoryUsagePercent", "double", false),
+			schemaField("cpuUsage", "string", false),
+			schemaField("memoryUsage", "string", false),
+			schemaField("cpuThresholdPercent", "int32", false),
+			schemaField("memoryThresholdPercent", "int32", false),
+		),
+		structField("pod", false,
+			schemaField("cluster", "string", true),
+			schemaField("namespace", "string", false),
+			schemaField("name", "string", false),
+			schemaField("uid", "string", true),
+			schemaField("node", "string", true),
+		),
+		schemaField("service", "string", true),
+		arrayField("profileTypes", connectSchema{Type: "string"}),
+		arrayField("profiles", connectSchema{Type: "struct", Fields: []connectSchema{
+			schemaField("type", "string", false),
+			schemaField("key", "string", false),
+			schemaField("sizeBytes", "int64", false),
+			schemaField("deduplicated", "boolean", true),
+		}}),
+		schemaField("bucket", "string", false),
+		schemaField("manifest", "string", true),
+		schemaField("summary", "string", true),
+		schemaField("comparison", "string", true),
+		schemaField("regressionScore", "double", true),
+		schemaField("regressed", "boolean", true),
+		arrayField("leaks", connectSchema{Type: "struct", Fields: []connectSchema{
+			schemaField("type", "string", false),
+			schemaField("sampleType", "string", false),
+			schemaField("unit", "string", false),
+			arrayField("stack", connectSchema{Type: "string"}),
+			arrayField("values", connectSchema{Type: "int64"}),
+			schemaField("growth", "int64", false),
+		}}),
+		arrayField("topFunctions", connectSchema{Type: "struct", Fields: []connectSchema{
+			schemaField("type", "string", false),
+			schemaField("sampleType", "string", false),
+			schemaField("unit", "string", false),
+			schemaField("total", "int64", false),
+			arrayField("topFlat", connectSchema{Type: "struct", Fields: []connectSchema{
+				schemaField("name", "string", false),
+				schemaField("flat", "int64", false),
+				schemaField("flatPercent", "double", false),
+				schemaField("cum", "int64", false),
+				schemaField("cumPercent", "double", false),
+			}}),
+		}}),
+		schemaField("outcome", "string", false),
+		schemaField("error", "string", true),
+		schemaField("durationSeconds", "double", false),
+		arrayField("links", connectSchema{Type: "struct", Fields: []connectSchema{
+			schemaField("type", "string", false),
+			schemaField("url", "string", false),
+		}}),
+	},
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+// fakeWriter records produced messages
+type fakeWriter struct {
+	messages []kafka.Message
+	closed   bool
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.messages = append(f.messages, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestKafkaNotifier_Notify(t *testing.T) {
+	writer := &fakeWriter{}
+	notifier := &KafkaNotifier{format: KafkaFormatJSON, writer: writer}
+
+	event := testEvent()
+	event.Pod.Cluster = "eu-west"
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+	if err := notifier.Close(); err != nil || !writer.closed {
+		t.Errorf("Expected the writer to be closed, got error: %v", err)
+	}
+	if len(writer.messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(writer.messages))
+	}
+
+	message := writer.messages[0]
+	if string(message.Key) != "eu-west/default/my-app-0" {
+		t.Errorf("Expected message keyed by pod, got %q", message.Key)
+	}
+	var received Event
+	if err := json.Unmarshal(message.Value, &received); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if received.Pod.Name != "my-app-0" || received.Outcome != audit.OutcomeSucceeded || len(received.Links) != 2 {
+		t.Errorf("Unexpected event: %+v", received)
+	}
+}
+
+func TestKafkaValue_JSONWithSchema(t *testing.T) {
+	value, err := kafkaValue(KafkaFormatJSONWithSchema, testEvent())
+	if err != nil {
+		t.Fatalf("kafkaValue returned unexpected error: %v", err)
+	}
+
+	var envelope struct {
+		Schema struct {
+			Type   string `json:"type"`
+			Name   string `json:"name"`
+			Fields []struct {
+				Field string `json:"field"`
+			} `json:"fields"`
+		} `json:"schema"`
+		Payload map[string]json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		t.Fatalf("Failed to decode envelope: %v", err)
+	}
+	if envelope.Schema.Type != "struct" || envelope.Schema.Name != "io.bolometer.CaptureEvent" {
+		t.Errorf("Unexpected schema: %+v", envelope.Schema)
+	}
+
+	// Every field of the payload is described by the schema
+	described := make(map[string]bool)
+	for _, field := range envelope.Schema.Fields {
+		described[field.Field] = true
+	}
+	for name := range envelope.Payload {
+		if !described[name] {
+			t.Errorf("Payload field %q is missing from the schema", name)
+		}
+	}
+}
+
+func TestSASLMechanism(t *testing.T) {
+	for _, name := range []string{SASLPlain, SASLScramSHA256, SASLScramSHA512} {
+		if _, err := saslMechanism(name, "user", "secret"); err != nil {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		}
+	}
+	if _, err := saslMechanism("GSSAPI", "user", "secret"); err == nil {
+		t.Error("Expected error for an unsupported mechanism")
+	}
+}
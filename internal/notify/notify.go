@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+// deliveryTimeout bounds a single delivery to a sink
+const deliveryTimeout = 10 * time.Second
+
+// fifoSuffix ends the names of FIFO SNS topics and SQS queues
+const fifoSuffix = ".fifo"
+
+// Event is a capture event delivered to notification sinks: the audit record of
+// the capture and links to its profiles
+type Event struct {
+	audit.Record
+
+	// Links point at the uploaded profiles, in upload order
+	Links []Link `json:"links,omitempty"`
+}
+
+// Link points at an uploaded profile
+type Link struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Notifier delivers capture events
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// postJSON posts a JSON body to url, failing on any non-2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// messageAttributes returns the attributes of a message carrying an event, for
+// SNS subscription filter policies and queue consumers. AWS rejects empty
+// attribute values, so unset fields are left out.
+func messageAttributes(event Event) map[string]string {
+	attributes := map[string]string{"event": captureEvent}
+	for name, value := range map[string]string{
+		"config":      event.Config,
+		"triggeredBy": event.TriggeredBy,
+		"outcome":     string(event.Outcome),
+	} {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
+
+// deduplicationID identifies a message body to FIFO topics and queues, so a
+// retried delivery of the same event is dropped
+func deduplicationID(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// leakLines describes the probable leaks of an event, one line per leak
+func leakLines(event Event) []string {
+	lines := make([]string, 0, len(event.Leaks))
+	for _, leak := range event.Leaks {
+		lines = append(lines, fmt.Sprintf("%s %s grew by %s over %d captures",
+			leak.Type, leak.Site(), leak.FormatGrowth(), len(leak.Values)))
+	}
+	return lines
+}
+
+// topFunctionLines describes the top functions of each profile of an event,
+// one line per profile, e.g. heap inuse_space: runtime.mallocgc 3.0MiB (75%)
+func topFunctionLines(event Event) []string {
+	lines := make([]string, 0, len(event.TopFunctions))
+	for _, summary := range event.TopFunctions {
+		if len(summary.TopFlat) == 0 {
+			continue
+		}
+		functions := make([]string, 0, len(summary.TopFlat))
+		for _, fn := range summary.TopFlat {
+			functions = append(functions, fmt.Sprintf("%s %s (%.4g%%)", fn.Name, summary.FormatValue(fn.Flat), fn.FlatPercent))
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", summary.Type, summary.SampleType, strings.Join(functions, ", ")))
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SlackNotifier posts capture events to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a notifier posting to a Slack incoming webhook
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// slackMessage is the payload of a Slack incoming webhook
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Notify posts a message describing the capture
+func (s *SlackNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(slackMessage{Text: slackText(event)})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+
+	if err := postJSON(ctx, s.client, s.webhookURL, body, nil); err != nil {
+		return fmt.Errorf("failed to post to Slack: %w", err)
+	}
+	return nil
+}
+
+// slackText formats an event as Slack mrkdwn
+func slackText(event Event) string {
+	pod := event.Pod.Namespace + "/" + event.Pod.Name
+	if event.Pod.Cluster != "" {
+		pod = event.Pod.Cluster + "/" + pod
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Profile captured* for `%s` by config `%s`\n", pod, event.Config)
+	fmt.Fprintf(&b, "*Reason:* %s\n", event.Reason)
+	if trigger := event.Trigger; trigger != nil {
+		fmt.Fprintf(&b, "*CPU:* %.2f%% of requests (%s, threshold %d%%)\n",
+			trigger.CPUUsagePercent, trigger.CPUUsage, trigger.CPUThresholdPercent)
+		fmt.Fprintf(&b, "*Memory:* %.2f%% of requests (%s, threshold %d%%)\n",
+			trigger.MemoryUsagePercent, trigger.MemoryUsage, trigger.MemoryThresholdPercent)
+	}
+
+	if lines := leakLines(event); len(lines) > 0 {
+		b.WriteString("*Probable leaks:*\n")
+		for _, line := range lines {
+			fmt.Fprintf(&b, "• `%s`\n", line)
+		}
+	}
+
+	if lines := topFunctionLines(event); len(lines) > 0 {
+		b.WriteString("*Top functions:*\n")
+		for _, line := range lines {
+			fmt.Fprintf(&b, "• `%s`\n", line)
+		}
+	}
+
+	if len(event.Links) > 0 {
+		links := make([]string, 0, len(event.Links))
+		for _, link := range event.Links {
+			links = append(links, fmt.Sprintf("<%s|%s>", link.URL, link.Type))
+		}
+		fmt.Fprintf(&b, "*Profiles:* %s", strings.Join(links, " | "))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/uploader"
+)
+
+func testEvent() Event {
+	return Event{
+		Record: audit.Record{
+			Config:      "default/my-app-profiling",
+			TriggeredBy: "threshold",
+			Reason:      "cpu threshold exceeded",
+			Trigger: &uploader.TriggerValues{
+				CPUUsagePercent:        92.5,
+				MemoryUsagePercent:     40,
+				CPUUsage:               "925m",
+				MemoryUsage:            "200Mi",
+				CPUThresholdPercent:    80,
+				MemoryThresholdPercent: 90,
+			},
+			Pod: audit.Pod{Namespace: "default", Name: "my-app-0"},
+			TopFunctions: []analysis.Summary{{
+				Type:       "heap",
+				SampleType: "inuse_space",
+				Unit:       "bytes",
+				Total:      4 << 20,
+				TopFlat: []analysis.Function{
+					{Name: "bytes.growSlice", Flat: 3 << 20, FlatPercent: 75, Cum: 3 << 20, CumPercent: 75},
+				},
+			}},
+			Leaks: []analysis.Leak{{
+				Type:       "heap",
+				SampleType: "inuse_space",
+				Unit:       "bytes",
+				Stack:      []string{"runtime.mallocgc", "main.(*cache).add"},
+				Values:     []int64{1 << 20, 2 << 20, 4 << 20},
+				Growth:     3 << 20,
+			}},
+			Outcome: audit.OutcomeSucceeded,
+		},
+		Links: []Link{
+			{Type: "heap", URL: "https://example.com/heap"},
+			{Type: "cpu", URL: "https://example.com/cpu"},
+		},
+	}
+}
+
+func TestSlackText(t *testing.T) {
+	text := slackText(testEvent())
+
+	for _, expected := range []string{
+		"`default/my-app-0` by config `default/my-app-profiling`",
+		"*Reason:* cpu threshold exceeded",
+		"*CPU:* 92.50% of requests (925m, threshold 80%)",
+		"• `heap inuse_space: bytes.growSlice 3.0MiB (75%)`",
+		"• `heap main.(*cache).add grew by 3.0MiB over 3 captures`",
+		"*Profiles:* <https://example.com/heap|heap> | <https://example.com/cpu|cpu>",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected message to contain %q, got:\n%s", expected, text)
+		}
+	}
+}
+
+func TestSlackNotifier_Notify(t *testing.T) {
+	var received slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode message: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := NewSlackNotifier(server.URL).Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+	if !strings.Contains(received.Text, "my-app-0") {
+		t.Errorf("Unexpected message: %q", received.Text)
+	}
+}
+
+func TestSlackNotifier_NotifyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier(server.URL).Notify(context.Background(), testEvent())
+	if err == nil || !strings.Contains(err.Error(), "invalid_token") {
+		t.Errorf("Expected the response to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// snsPublisher is the part of the SNS client used by SNSNotifier
+type snsPublisher interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNSNotifier publishes capture events as JSON to an SNS topic
+type SNSNotifier struct {
+	topicARN string
+	client   snsPublisher
+}
+
+// NewSNSNotifier creates a notifier publishing to a topic in region
+func NewSNSNotifier(ctx context.Context, topicARN, region string) (*SNSNotifier, error) {
+	// Load AWS config from environment (uses IRSA/IAM roles automatically)
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &SNSNotifier{
+		topicARN: topicARN,
+		client:   sns.NewFromConfig(awsCfg),
+	}, nil
+}
+
+// Notify publishes the event. Events sent to FIFO topics are grouped by config.
+func (s *SNSNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	attributes := make(map[string]snstypes.MessageAttributeValue)
+	for name, value := range messageAttributes(event) {
+		attributes[name] = snstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(s.topicARN),
+		Message:           aws.String(string(body)),
+		MessageAttributes: attributes,
+	}
+	if strings.HasSuffix(s.topicARN, fifoSuffix) {
+		input.MessageGroupId = aws.String(event.Config)
+		input.MessageDeduplicationId = aws.String(deduplicationID(body))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	if _, err := s.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish to SNS: %w", err)
+	}
+	return nil
+}
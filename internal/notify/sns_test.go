@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// fakeSNS records published messages
+type fakeSNS struct {
+	inputs []*sns.PublishInput
+	err    error
+}
+
+func (f *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sns.PublishOutput{}, f.err
+}
+
+func TestSNSNotifier_Notify(t *testing.T) {
+	client := &fakeSNS{}
+	notifier := &SNSNotifier{topicARN: "arn:aws:sns:us-east-1:123456789012:captures", client: client}
+
+	if err := notifier.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(client.inputs))
+	}
+
+	input := client.inputs[0]
+	var received Event
+	if err := json.Unmarshal([]byte(aws.ToString(input.Message)), &received); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if received.Pod.Name != "my-app-0" || len(received.Links) != 2 {
+		t.Errorf("Unexpected event: %+v", received)
+	}
+	if outcome := aws.ToString(input.MessageAttributes["outcome"].StringValue); outcome != "Succeeded" {
+		t.Errorf("Expected outcome attribute Succeeded, got %q", outcome)
+	}
+	if input.MessageGroupId != nil || input.MessageDeduplicationId != nil {
+		t.Error("Expected no message group on a standard topic")
+	}
+}
+
+func TestSNSNotifier_FIFO(t *testing.T) {
+	client := &fakeSNS{}
+	notifier := &SNSNotifier{topicARN: "arn:aws:sns:us-east-1:123456789012:captures.fifo", client: client}
+
+	for i := 0; i < 2; i++ {
+		if err := notifier.Notify(context.Background(), testEvent()); err != nil {
+			t.Fatalf("Notify returned unexpected error: %v", err)
+		}
+	}
+
+	first, second := client.inputs[0], client.inputs[1]
+	if group := aws.ToString(first.MessageGroupId); group != "default/my-app-profiling" {
+		t.Errorf("Expected the config as message group, got %q", group)
+	}
+	if aws.ToString(first.MessageDeduplicationId) != aws.ToString(second.MessageDeduplicationId) {
+		t.Error("Expected the same event to get the same deduplication ID")
+	}
+}
+
+func TestSNSNotifier_Error(t *testing.T) {
+	notifier := &SNSNotifier{topicARN: "arn:aws:sns:us-east-1:123456789012:captures", client: &fakeSNS{err: errors.New("denied")}}
+	if err := notifier.Notify(context.Background(), testEvent()); err == nil {
+		t.Error("Expected error when publishing fails")
+	}
+}
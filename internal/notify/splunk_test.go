@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSplunkNotifier_Notify(t *testing.T) {
+	var received splunkEvent
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector/event" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Splunk hec-token" {
+			t.Errorf("Unexpected authorization %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode event: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	notifier := NewSplunkNotifier(server.URL+"/", "hec-token", "profiling", "bolometer", "bolometer:capture")
+	notifier.client = server.Client()
+
+	event := testEvent()
+	event.Time = time.Date(2024, 1, 15, 10, 30, 45, 500_000_000, time.UTC)
+	event.Pod.Node = "node-1"
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+
+	if received.Time != 1705314645.5 || received.Host != "node-1" || received.Index != "profiling" ||
+		received.SourceType != "bolometer:capture" {
+		t.Errorf("Unexpected envelope: %+v", received)
+	}
+	if received.Event.Pod.Name != "my-app-0" || len(received.Event.Links) != 2 {
+		t.Errorf("Unexpected event: %+v", received.Event)
+	}
+}
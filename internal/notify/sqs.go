@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// sqsSender is the part of the SQS client used by SQSNotifier
+type sqsSender interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SQSNotifier sends capture events as JSON to an SQS queue
+type SQSNotifier struct {
+	queueURL string
+	client   sqsSender
+}
+
+// NewSQSNotifier creates a notifier sending to a queue in region
+func NewSQSNotifier(ctx context.Context, queueURL, region string) (*SQSNotifier, error) {
+	// Load AWS config from environment (uses IRSA/IAM roles automatically)
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &SQSNotifier{
+		queueURL: queueURL,
+		client:   sqs.NewFromConfig(awsCfg),
+	}, nil
+}
+
+// Notify sends the event. Events sent to FIFO queues are grouped by config.
+func (s *SQSNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	attributes := make(map[string]sqstypes.MessageAttributeValue)
+	for name, value := range messageAttributes(event) {
+		attributes[name] = sqstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(s.queueURL),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: attributes,
+	}
+	if strings.HasSuffix(s.queueURL, fifoSuffix) {
+		input.MessageGroupId = aws.String(event.Config)
+		input.MessageDeduplicationId = aws.String(deduplicationID(body))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	if _, err := s.client.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to send to SQS: %w", err)
+	}
+	return nil
+}
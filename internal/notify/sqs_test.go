@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+// fakeSQS records sent messages
+type fakeSQS struct {
+	inputs []*sqs.SendMessageInput
+}
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func TestSQSNotifier_Notify(t *testing.T) {
+	client := &fakeSQS{}
+	notifier := &SQSNotifier{queueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/captures.fifo", client: client}
+
+	if err := notifier.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(client.inputs))
+	}
+
+	input := client.inputs[0]
+	var received Event
+	if err := json.Unmarshal([]byte(aws.ToString(input.MessageBody)), &received); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if received.Pod.Name != "my-app-0" || received.Outcome != audit.OutcomeSucceeded {
+		t.Errorf("Unexpected event: %+v", received)
+	}
+	if event := aws.ToString(input.MessageAttributes["event"].StringValue); event != "capture" {
+		t.Errorf("Expected event attribute capture, got %q", event)
+	}
+	if group := aws.ToString(input.MessageGroupId); group != "default/my-app-profiling" {
+		t.Errorf("Expected the config as message group, got %q", group)
+	}
+}
+
+func TestMessageAttributes_SkipsEmpty(t *testing.T) {
+	event := testEvent()
+	event.TriggeredBy = ""
+
+	attributes := messageAttributes(event)
+	if _, ok := attributes["triggeredBy"]; ok {
+		t.Error("Expected empty triggeredBy to be left out")
+	}
+	if attributes["config"] != "default/my-app-profiling" {
+		t.Errorf("Unexpected config attribute %q", attributes["config"])
+	}
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the request body, formatted as
+	// sha256=<hex>, when the webhook has a signing key
+	SignatureHeader = "X-Bolometer-Signature"
+
+	// EventHeader names the kind of event posted
+	EventHeader = "X-Bolometer-Event"
+
+	// captureEvent is the EventHeader value of capture events
+	captureEvent = "capture"
+)
+
+// WebhookNotifier posts capture events as JSON to an HTTPS endpoint
+type WebhookNotifier struct {
+	url        string
+	signingKey []byte
+	client     *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url. Events are signed with
+// signingKey unless it is empty.
+func NewWebhookNotifier(url string, signingKey []byte) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		signingKey: signingKey,
+		client:     &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// Notify posts the event, signed if the webhook has a signing key
+func (w *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set(EventHeader, captureEvent)
+	if len(w.signingKey) > 0 {
+		header.Set(SignatureHeader, Sign(w.signingKey, body))
+	}
+
+	if err := postJSON(ctx, w.client, w.url, body, header); err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value of a body signed with key. Receivers
+// verify events by computing it over the raw body and comparing in constant time.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
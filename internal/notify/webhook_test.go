@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+)
+
+func TestSign(t *testing.T) {
+	// Known HMAC-SHA256 test vector (RFC 4231 test case 2)
+	expected := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if signature := Sign([]byte("Jefe"), []byte("what do ya want for nothing?")); signature != expected {
+		t.Errorf("Expected %s, got %s", expected, signature)
+	}
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	key := []byte("signing-key")
+
+	var received Event
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+
+		if !hmac.Equal([]byte(r.Header.Get(SignatureHeader)), []byte(Sign(key, body))) {
+			t.Errorf("Invalid signature %q", r.Header.Get(SignatureHeader))
+		}
+		if r.Header.Get(EventHeader) != "capture" {
+			t.Errorf("Unexpected event header %q", r.Header.Get(EventHeader))
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Failed to decode event: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, key)
+	notifier.client = server.Client()
+
+	event := testEvent()
+	event.Profiles = []audit.Profile{{Type: "heap", Key: "profiles/heap.pprof", SizeBytes: 1024}}
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+
+	if received.Pod.Name != "my-app-0" || received.Outcome != audit.OutcomeSucceeded ||
+		len(received.Profiles) != 1 || len(received.Links) != 2 || received.Trigger == nil {
+		t.Errorf("Unexpected event: %+v", received)
+	}
+}
+
+func TestWebhookNotifier_Unsigned(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if signature := r.Header.Get(SignatureHeader); signature != "" {
+			t.Errorf("Expected no signature without a key, got %q", signature)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, nil)
+	notifier.client = server.Client()
+	if err := notifier.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify returned unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Executor runs commands in the containers of pods
+type Executor interface {
+	// Exec runs a command in a container of a pod until it exits or ctx is
+	// done, returning its output
+	Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) (string, error)
+}
+
+var _ Executor = (*PodExecutor)(nil)
+
+// PodExecutor runs commands through the exec subresource of pods
+type PodExecutor struct {
+	clientset  kubernetes.Interface
+	restConfig *rest.Config
+}
+
+// NewExecutor creates an executor for the pods of a cluster
+func NewExecutor(clientset kubernetes.Interface, restConfig *rest.Config) *PodExecutor {
+	return &PodExecutor{
+		clientset:  clientset,
+		restConfig: restConfig,
+	}
+}
+
+// Exec implements Executor
+func (e *PodExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) (string, error) {
+	req := e.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(e.restConfig, http.MethodPost, req.URL())
+	if err != nil {
+		return "", fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	output := stdout.String() + stderr.String()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return output, fmt.Errorf("failed to exec %q: %w: %s", strings.Join(command, " "), err, msg)
+		}
+		return output, fmt.Errorf("failed to exec %q: %w", strings.Join(command, " "), err)
+	}
+	return output, nil
+}
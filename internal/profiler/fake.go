@@ -0,0 +1,112 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Fake is a profiler returning canned profiles without reaching the pods, for
+// tests of code capturing profiles
+type Fake struct {
+	// Data is the content returned for each profile type. Types without data
+	// return an empty profile.
+	Data map[string][]byte
+
+	// Err fails every capture if set
+	Err error
+
+	// ProbeErr fails every probe and goroutine count if set
+	ProbeErr error
+
+	// Goroutines is the number of goroutines counted in every pod
+	Goroutines int
+
+	mu       sync.Mutex
+	captured []string
+}
+
+var (
+	_ Interface        = (*Fake)(nil)
+	_ Prober           = (*Fake)(nil)
+	_ GoroutineCounter = (*Fake)(nil)
+)
+
+// CaptureProfiles implements Interface
+func (f *Fake) CaptureProfiles(ctx context.Context, pod *corev1.Pod, profileTypes []string) ([]Profile, error) {
+	f.mu.Lock()
+	f.captured = append(f.captured, pod.Namespace+"/"+pod.Name)
+	f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, fmt.Errorf("failed to capture profiles: %w", f.Err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	profiles := make([]Profile, 0, len(profileTypes))
+	for _, profileType := range profileTypes {
+		profiles = append(profiles, Profile{Type: profileType, Data: f.Data[profileType], Timestamp: now})
+	}
+	return profiles, nil
+}
+
+// Probe implements Prober
+func (f *Fake) Probe(ctx context.Context, pod *corev1.Pod) error {
+	return f.ProbeErr
+}
+
+// CountGoroutines implements GoroutineCounter
+func (f *Fake) CountGoroutines(ctx context.Context, pod *corev1.Pod) (int, error) {
+	return f.Goroutines, f.ProbeErr
+}
+
+// Captured returns the namespace/name of the pods captured so far, in order
+func (f *Fake) Captured() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.captured...)
+}
+
+// FakeExecutor is an executor recording commands without running them, for
+// tests of code executing commands in pods
+type FakeExecutor struct {
+	// Output is returned by every command
+	Output string
+
+	// Err fails every command if set
+	Err error
+
+	mu       sync.Mutex
+	executed []string
+}
+
+var _ Executor = (*FakeExecutor)(nil)
+
+// Exec implements Executor
+func (f *FakeExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) (string, error) {
+	f.mu.Lock()
+	f.executed = append(f.executed, pod.Name+"/"+container+": "+strings.Join(command, " "))
+	f.mu.Unlock()
+
+	if f.Err != nil {
+		return f.Output, fmt.Errorf("failed to exec %q: %w", strings.Join(command, " "), f.Err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return f.Output, nil
+}
+
+// Executed returns the commands run so far, in order, as "pod/container: command"
+func (f *FakeExecutor) Executed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.executed...)
+}
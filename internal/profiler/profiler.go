@@ -1,12 +1,15 @@
 package profiler
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -22,12 +25,77 @@ const (
 
 	// PprofPortAnnotation is the annotation key for custom pprof port
 	PprofPortAnnotation = "bolometer.io/port"
+
+	// DefaultCPUSeconds is the default duration of CPU profiles
+	DefaultCPUSeconds = 30
+
+	// MaxCPUSeconds is the longest CPU profile a pod may ask for
+	MaxCPUSeconds = 120
+
+	// CPUSecondsAnnotation is the annotation key for a custom CPU profile duration
+	CPUSecondsAnnotation = "bolometer.io/cpu-seconds"
+)
+
+// Interface captures the profiles of pods. Profiler captures them through
+// port-forwards; other implementations may exec into the pods, ask an agent
+// running next to them, or return canned profiles in tests.
+type Interface interface {
+	// CaptureProfiles captures the given profile types from a pod
+	CaptureProfiles(ctx context.Context, pod *corev1.Pod, profileTypes []string) ([]Profile, error)
+}
+
+// ClusterProfiler is a profiler that can also capture the pods of remote
+// clusters
+type ClusterProfiler interface {
+	Interface
+
+	// ForCluster returns a profiler capturing pods through another cluster's clients
+	ForCluster(clientset kubernetes.Interface, restConfig *rest.Config) Interface
+}
+
+// PortForwardLimiter is a profiler whose concurrent port-forwards can be limited
+type PortForwardLimiter interface {
+	// SetMaxPortForwards limits the port-forwards open at once, 0 for no limit
+	SetMaxPortForwards(limit int)
+}
+
+// Prober is a profiler that can check whether the pprof endpoint of a pod is
+// reachable without capturing profiles
+type Prober interface {
+	// Probe reports an error if the pod's pprof endpoint cannot be reached
+	Probe(ctx context.Context, pod *corev1.Pod) error
+}
+
+// GoroutineCounter is a profiler that can count the goroutines of a pod
+// without capturing profiles
+type GoroutineCounter interface {
+	// CountGoroutines returns the number of goroutines of the pod
+	CountGoroutines(ctx context.Context, pod *corev1.Pod) (int, error)
+}
+
+var (
+	_ ClusterProfiler    = (*Profiler)(nil)
+	_ PortForwardLimiter = (*Profiler)(nil)
+	_ Prober             = (*Profiler)(nil)
+	_ GoroutineCounter   = (*Profiler)(nil)
 )
 
 // Profiler captures pprof profiles from Go applications
 type Profiler struct {
 	clientset  kubernetes.Interface
 	restConfig *rest.Config
+
+	// limit is shared with the profilers of other clusters
+	limit *portForwardLimit
+}
+
+// portForwardLimit bounds the number of concurrent port-forwards
+type portForwardLimit struct {
+	mu sync.Mutex
+
+	// slots holds a token per open port-forward, nil if unlimited
+	slots chan struct{}
+	max   int
 }
 
 // NewProfiler creates a new profiler
@@ -35,7 +103,71 @@ func NewProfiler(clientset kubernetes.Interface, restConfig *rest.Config) *Profi
 	return &Profiler{
 		clientset:  clientset,
 		restConfig: restConfig,
+		limit:      &portForwardLimit{},
+	}
+}
+
+// ForCluster returns a profiler port-forwarding through another cluster's
+// clients. Port-forwards of both profilers count against the same limit.
+func (p *Profiler) ForCluster(clientset kubernetes.Interface, restConfig *rest.Config) Interface {
+	return &Profiler{
+		clientset:  clientset,
+		restConfig: restConfig,
+		limit:      p.limit,
+	}
+}
+
+// SetMaxPortForwards limits the number of port-forwards open at once across all
+// captures. 0 removes the limit. Port-forwards already open when the limit
+// changes are not counted against the new limit.
+func (p *Profiler) SetMaxPortForwards(limit int) {
+	l := p.limit
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit = max(limit, 0)
+	if limit == l.max {
+		return
+	}
+
+	l.max = limit
+	if limit == 0 {
+		l.slots = nil
+		return
+	}
+	l.slots = make(chan struct{}, limit)
+}
+
+// acquirePortForward waits for a free port-forward slot and returns its release func
+func (p *Profiler) acquirePortForward(ctx context.Context) (func(), error) {
+	p.limit.mu.Lock()
+	slots := p.limit.slots
+	p.limit.mu.Unlock()
+
+	if slots == nil {
+		return func() {}, nil
 	}
+
+	// The slot is returned to the channel it was taken from, even if the limit
+	// changed meanwhile
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// StatusError is returned when a pprof endpoint answers with an unexpected
+// status code
+type StatusError struct {
+	Path       string
+	StatusCode int
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code from %s: %d", e.Path, e.StatusCode)
 }
 
 // Profile represents a captured profile
@@ -43,16 +175,105 @@ type Profile struct {
 	Type      string
 	Data      []byte
 	Timestamp time.Time
+
+	// DuplicateOf is the key of an identical profile stored earlier, set when
+	// the profile is not to be stored again
+	DuplicateOf string
 }
 
 // CaptureProfiles captures all specified profile types from a pod
 func (p *Profiler) CaptureProfiles(ctx context.Context, pod *corev1.Pod, profileTypes []string) ([]Profile, error) {
-	port := p.getPprofPort(pod)
+	var profiles []Profile
+	err := p.forwardPprof(ctx, pod, func(baseURL string) error {
+		var err error
+		profiles, err = p.captureProfilesFromURL(ctx, baseURL, profileTypes, p.getCPUSeconds(pod))
+		return err
+	})
+	return profiles, err
+}
+
+// Probe requests the pprof index of a pod through a port-forward
+func (p *Profiler) Probe(ctx context.Context, pod *corev1.Pod) error {
+	return p.forwardPprof(ctx, pod, func(baseURL string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/debug/pprof/", nil)
+		if err != nil {
+			return err
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return &StatusError{Path: "/debug/pprof/", StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+}
+
+// CountGoroutines reads the total of the goroutine profile of a pod in its
+// text form through a port-forward
+func (p *Profiler) CountGoroutines(ctx context.Context, pod *corev1.Pod) (int, error) {
+	var count int
+	err := p.forwardPprof(ctx, pod, func(baseURL string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/debug/pprof/goroutine?debug=1", nil)
+		if err != nil {
+			return err
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return &StatusError{Path: "/debug/pprof/goroutine", StatusCode: resp.StatusCode}
+		}
+		count, err = parseGoroutineTotal(resp.Body)
+		return err
+	})
+	return count, err
+}
+
+// parseGoroutineTotal reads the total from the first line of a goroutine
+// profile in its text form, "goroutine profile: total N"
+func parseGoroutineTotal(r io.Reader) (int, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return 0, fmt.Errorf("failed to read goroutine profile: %w", err)
+	}
+	total, ok := strings.CutPrefix(strings.TrimSpace(line), "goroutine profile: total ")
+	if !ok {
+		return 0, fmt.Errorf("unexpected goroutine profile header %q", strings.TrimSpace(line))
+	}
+	count, err := strconv.Atoi(total)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected goroutine total %q", total)
+	}
+	return count, nil
+}
+
+// forwardPprof port-forwards to the pprof port of a pod and calls fn with the
+// base URL of the forwarded endpoint
+func (p *Profiler) forwardPprof(ctx context.Context, pod *corev1.Pod, fn func(baseURL string) error) error {
+	port := PprofPort(pod)
+
+	// Respect the global port-forward limit
+	release, err := p.acquirePortForward(ctx)
+	if err != nil {
+		return fmt.Errorf("failed waiting for a port forward slot: %w", err)
+	}
+	defer release()
 
 	// Create port-forward to the pod
 	localPort, stopChan, readyChan, err := p.setupPortForward(ctx, pod, port)
 	if err != nil {
-		return nil, fmt.Errorf("failed to setup port forward: %w", err)
+		return fmt.Errorf("failed to setup port forward: %w", err)
 	}
 	defer close(stopChan)
 
@@ -61,15 +282,27 @@ func (p *Profiler) CaptureProfiles(ctx context.Context, pod *corev1.Pod, profile
 	case <-readyChan:
 		// Port-forward is ready
 	case <-time.After(10 * time.Second):
-		return nil, fmt.Errorf("timeout waiting for port forward")
+		return fmt.Errorf("timeout waiting for port forward")
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return ctx.Err()
 	}
 
+	return fn(fmt.Sprintf("http://localhost:%d", localPort))
+}
+
+// CaptureProfilesFromURL captures all specified profile types from a pprof
+// server reachable at baseURL, e.g. the operator's own
+func (p *Profiler) CaptureProfilesFromURL(ctx context.Context, baseURL string, profileTypes []string) ([]Profile, error) {
+	return p.captureProfilesFromURL(ctx, baseURL, profileTypes, DefaultCPUSeconds)
+}
+
+// captureProfilesFromURL captures all specified profile types from a pprof
+// server, profiling CPU for cpuSeconds
+func (p *Profiler) captureProfilesFromURL(ctx context.Context, baseURL string, profileTypes []string, cpuSeconds int) ([]Profile, error) {
 	// Capture each profile type
 	var profiles []Profile
 	for _, profileType := range profileTypes {
-		profile, err := p.captureProfile(ctx, localPort, profileType)
+		profile, err := p.captureProfile(ctx, baseURL, profileType, cpuSeconds)
 		if err != nil {
 			return nil, fmt.Errorf("failed to capture %s profile: %w", profileType, err)
 		}
@@ -136,9 +369,8 @@ func (p *Profiler) setupPortForward(ctx context.Context, pod *corev1.Pod, remote
 }
 
 // captureProfile captures a specific profile type
-func (p *Profiler) captureProfile(ctx context.Context, localPort int, profileType string) (Profile, error) {
-	endpoint := p.getProfileEndpoint(profileType)
-	url := fmt.Sprintf("http://localhost:%d%s", localPort, endpoint)
+func (p *Profiler) captureProfile(ctx context.Context, baseURL string, profileType string, cpuSeconds int) (Profile, error) {
+	url := baseURL + p.getProfileEndpoint(profileType, cpuSeconds)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -146,7 +378,7 @@ func (p *Profiler) captureProfile(ctx context.Context, localPort int, profileTyp
 	}
 
 	client := &http.Client{
-		Timeout: 60 * time.Second, // CPU profiling can take up to 30 seconds
+		Timeout: time.Duration(cpuSeconds+30) * time.Second, // CPU profiling takes cpuSeconds
 	}
 
 	resp, err := client.Do(req)
@@ -172,12 +404,12 @@ func (p *Profiler) captureProfile(ctx context.Context, localPort int, profileTyp
 }
 
 // getProfileEndpoint returns the pprof endpoint for a profile type
-func (p *Profiler) getProfileEndpoint(profileType string) string {
+func (p *Profiler) getProfileEndpoint(profileType string, cpuSeconds int) string {
 	switch profileType {
 	case "heap":
 		return "/debug/pprof/heap"
 	case "cpu":
-		return "/debug/pprof/profile?seconds=30"
+		return fmt.Sprintf("/debug/pprof/profile?seconds=%d", cpuSeconds)
 	case "goroutine":
 		return "/debug/pprof/goroutine"
 	case "mutex":
@@ -186,13 +418,15 @@ func (p *Profiler) getProfileEndpoint(profileType string) string {
 		return "/debug/pprof/block"
 	case "threadcreate":
 		return "/debug/pprof/threadcreate"
+	case "trace":
+		return "/debug/pprof/trace?seconds=5"
 	default:
 		return fmt.Sprintf("/debug/pprof/%s", profileType)
 	}
 }
 
-// getPprofPort gets the pprof port from pod annotations or uses default
-func (p *Profiler) getPprofPort(pod *corev1.Pod) int {
+// PprofPort returns the pprof port of a pod from its annotations, or the default
+func PprofPort(pod *corev1.Pod) int {
 	if pod.Annotations == nil {
 		return DefaultPprofPort
 	}
@@ -209,3 +443,18 @@ func (p *Profiler) getPprofPort(pod *corev1.Pod) int {
 
 	return port
 }
+
+// getCPUSeconds gets the CPU profile duration from pod annotations or uses default
+func (p *Profiler) getCPUSeconds(pod *corev1.Pod) int {
+	secondsStr, ok := pod.Annotations[CPUSecondsAnnotation]
+	if !ok {
+		return DefaultCPUSeconds
+	}
+
+	seconds, err := strconv.Atoi(secondsStr)
+	if err != nil || seconds <= 0 || seconds > MaxCPUSeconds {
+		return DefaultCPUSeconds
+	}
+
+	return seconds
+}
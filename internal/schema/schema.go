@@ -0,0 +1,196 @@
+// Package schema validates objects against the OpenAPI schemas of
+// CustomResourceDefinitions without an API server, for checking manifests
+// offline
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Load returns the schema of a version of the CustomResourceDefinition
+// manifest data
+func Load(data []byte, version string) (*apiextensionsv1.JSONSchemaProps, error) {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(data, crd); err != nil {
+		return nil, fmt.Errorf("failed to decode CustomResourceDefinition: %w", err)
+	}
+	for _, v := range crd.Spec.Versions {
+		if v.Name != version {
+			continue
+		}
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			return nil, fmt.Errorf("%s %s has no schema", crd.Name, version)
+		}
+		return v.Schema.OpenAPIV3Schema, nil
+	}
+	return nil, fmt.Errorf("%s has no version %s", crd.Name, version)
+}
+
+// Validate checks a value decoded from JSON against a schema and returns the
+// violations, prefixed with their path below path. It covers the structural
+// checks of the API server: types, unknown and required fields, enums,
+// bounds, lengths and patterns. Formats and CEL rules are not checked.
+func Validate(schema *apiextensionsv1.JSONSchemaProps, value interface{}, path string) []error {
+	v := &validator{}
+	v.validate(schema, value, path)
+	return v.errs
+}
+
+// validator accumulates the violations found in a value
+type validator struct {
+	errs []error
+}
+
+// fail records a violation at path
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) validate(s *apiextensionsv1.JSONSchemaProps, value interface{}, path string) {
+	if value == nil {
+		if !s.Nullable {
+			v.fail(path, "must not be null")
+		}
+		return
+	}
+
+	if s.XIntOrString {
+		switch value := value.(type) {
+		case float64:
+			if value != math.Trunc(value) {
+				v.fail(path, "must be an integer or a string")
+			}
+		case string:
+			v.validateString(s, value, path)
+		default:
+			v.fail(path, "must be an integer or a string")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "must be an object")
+			return
+		}
+		v.validateObject(s, object, path)
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "must be an array")
+			return
+		}
+		if s.MinItems != nil && int64(len(items)) < *s.MinItems {
+			v.fail(path, "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && int64(len(items)) > *s.MaxItems {
+			v.fail(path, "must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range items {
+				v.validate(s.Items.Schema, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(path, "must be a string")
+			return
+		}
+		v.validateString(s, str, path)
+
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || (s.Type == "integer" && number != math.Trunc(number)) {
+			v.fail(path, "must be an %s", s.Type)
+			return
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			v.fail(path, "must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			v.fail(path, "must be at most %v", *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "must be a boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		allowed := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			var option interface{}
+			if err := json.Unmarshal(e.Raw, &option); err == nil && reflect.DeepEqual(option, value) {
+				return
+			}
+			allowed = append(allowed, string(e.Raw))
+		}
+		v.fail(path, "must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+// validateObject checks the fields of an object
+func (v *validator) validateObject(s *apiextensionsv1.JSONSchemaProps, object map[string]interface{}, path string) {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			v.fail(path+"."+name, "is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	preserveUnknown := s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields
+	for _, name := range names {
+		fieldPath := path + "." + name
+		if property, ok := s.Properties[name]; ok {
+			v.validate(&property, object[name], fieldPath)
+			continue
+		}
+
+		switch additional := s.AdditionalProperties; {
+		case additional != nil && additional.Schema != nil:
+			v.validate(additional.Schema, object[name], fieldPath)
+		case additional != nil && additional.Allows, preserveUnknown:
+		default:
+			v.fail(fieldPath, "unknown field")
+		}
+	}
+}
+
+// validateString checks the length and pattern of a string
+func (v *validator) validateString(s *apiextensionsv1.JSONSchemaProps, str, path string) {
+	length := int64(len([]rune(str)))
+	if s.MinLength != nil && length < *s.MinLength {
+		v.fail(path, "must be at least %d characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		v.fail(path, "must be at most %d characters", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			v.fail(path, "schema pattern %q is invalid: %v", s.Pattern, err)
+		} else if !pattern.MatchString(str) {
+			v.fail(path, "must match %s", s.Pattern)
+		}
+	}
+}
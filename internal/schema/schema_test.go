@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/a-kash-singh/bolometer/config/crd"
+)
+
+func specSchema(t *testing.T) *apiextensionsv1.JSONSchemaProps {
+	t.Helper()
+
+	schema, err := Load(crd.ProfilingConfigs, "v1alpha1")
+	if err != nil {
+		t.Fatalf("Load returned unexpected error: %v", err)
+	}
+	spec := schema.Properties["spec"]
+	return &spec
+}
+
+func decode(t *testing.T, manifest string) map[string]interface{} {
+	t.Helper()
+
+	var object map[string]interface{}
+	if err := yaml.Unmarshal([]byte(manifest), &object); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+	return object
+}
+
+func TestValidate_Samples(t *testing.T) {
+	schema := specSchema(t)
+
+	for _, name := range []string{
+		"profiling_v1alpha1_profilingconfig.yaml",
+		"profiling_v1alpha1_ondemand.yaml",
+		"profiling_v1alpha1_remotecluster.yaml",
+	} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "config", "samples", name))
+		if err != nil {
+			t.Fatalf("Failed to read sample: %v", err)
+		}
+		if errs := Validate(schema, decode(t, string(data))["spec"], "spec"); len(errs) > 0 {
+			t.Errorf("Expected sample %s to be valid, got %v", name, errs)
+		}
+	}
+}
+
+func TestValidate_Violations(t *testing.T) {
+	spec := decode(t, `
+spec:
+  selector:
+    labelSelector:
+      app: my-app
+  thresholds:
+    cpuThresholdPercent: 150
+    cooldownSeconds: "300"
+  metricsSource: datadog
+  leakDetection:
+    minHeapGrowth: 1Mb
+  profileTypes: heap
+  s3Config:
+    bucket: my-bucket
+    bukcet: typo
+`)["spec"]
+
+	errs := Validate(specSchema(t), spec, "spec")
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	got := strings.Join(messages, "\n")
+
+	for _, want := range []string{
+		"spec.thresholds.cpuThresholdPercent: must be at most 100",
+		"spec.thresholds.cooldownSeconds: must be an integer",
+		"spec.metricsSource: must be one of",
+		"spec.leakDetection.minHeapGrowth: must match",
+		"spec.profileTypes: must be an array",
+		"spec.s3Config.bukcet: unknown field",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected violation %q, got:\n%s", want, got)
+		}
+	}
+	if len(errs) != 6 {
+		t.Errorf("Expected 6 violations, got %d:\n%s", len(errs), got)
+	}
+}
+
+func TestLoad_UnknownVersion(t *testing.T) {
+	if _, err := Load(crd.ProfilingConfigs, "v1"); err == nil {
+		t.Error("Expected error for an unknown version")
+	}
+}
@@ -0,0 +1,123 @@
+// Renders the flamegraph embedded in the page as rows of frames, the root at
+// the top. Clicking a frame zooms into it; the search box highlights the
+// functions matching a regular expression.
+(function () {
+  "use strict";
+
+  var ROW_HEIGHT = 17;
+  var MIN_WIDTH = 0.5;
+
+  var data = JSON.parse(document.getElementById("flamegraph-data").textContent);
+  var container = document.getElementById("flamegraph");
+  var search = document.getElementById("search");
+  var focus = data.root;
+
+  function formatValue(value) {
+    var units;
+    switch (data.unit) {
+      case "bytes":
+        units = ["B", "KiB", "MiB", "GiB", "TiB"];
+        var i = 0;
+        while (value >= 1024 && i < units.length - 1) {
+          value /= 1024;
+          i++;
+        }
+        return i === 0 ? value + "B" : value.toFixed(1) + units[i];
+      case "nanoseconds":
+        return (value / 1e9).toFixed(2) + "s";
+      default:
+        return String(value);
+    }
+  }
+
+  // Hues go from red for the runtime to yellow, stable per function name
+  function color(name) {
+    var hash = 0;
+    for (var i = 0; i < name.length; i++) {
+      hash = (hash * 31 + name.charCodeAt(i)) | 0;
+    }
+    var hue = name.indexOf("runtime.") === 0 ? 0 : 20 + Math.abs(hash % 35);
+    return "hsl(" + hue + ", 80%, " + (60 + Math.abs(hash % 15)) + "%)";
+  }
+
+  // path returns the frames from the root down to target
+  function path(frame, target) {
+    if (frame === target) {
+      return [frame];
+    }
+    var children = frame.children || [];
+    for (var i = 0; i < children.length; i++) {
+      var found = path(children[i], target);
+      if (found) {
+        return [frame].concat(found);
+      }
+    }
+    return null;
+  }
+
+  function render() {
+    var width = container.clientWidth;
+    var scale = width / focus.value;
+    var pattern = null;
+    if (search.value) {
+      try {
+        pattern = new RegExp(search.value);
+      } catch (e) {
+        pattern = null;
+      }
+    }
+
+    var fragment = document.createDocumentFragment();
+    var depth = 0;
+
+    function add(frame, x, y, frameWidth) {
+      var div = document.createElement("div");
+      div.className = "frame" + (pattern && pattern.test(frame.name) ? " match" : "");
+      div.style.left = x + "px";
+      div.style.top = y * ROW_HEIGHT + "px";
+      div.style.width = frameWidth + "px";
+      div.style.background = color(frame.name);
+      div.textContent = frame.name;
+      div.title = frame.name + "\n" + formatValue(frame.value) + " (" +
+        (100 * frame.value / data.root.value).toFixed(2) + "%)";
+      div.addEventListener("click", function () {
+        focus = frame;
+        render();
+      });
+      fragment.appendChild(div);
+      depth = Math.max(depth, y + 1);
+    }
+
+    // The callers of the focused frame span the full width
+    var callers = path(data.root, focus);
+    for (var i = 0; i < callers.length - 1; i++) {
+      add(callers[i], 0, i, width);
+    }
+
+    function walk(frame, x, y) {
+      var frameWidth = frame.value * scale;
+      if (frameWidth < MIN_WIDTH) {
+        return;
+      }
+      add(frame, x, y, frameWidth);
+      var children = frame.children || [];
+      for (var i = 0; i < children.length; i++) {
+        walk(children[i], x, y + 1);
+        x += children[i].value * scale;
+      }
+    }
+    walk(focus, 0, callers.length - 1);
+
+    container.innerHTML = "";
+    container.style.height = depth * ROW_HEIGHT + "px";
+    container.appendChild(fragment);
+  }
+
+  document.getElementById("reset").addEventListener("click", function () {
+    focus = data.root;
+    render();
+  });
+  search.addEventListener("input", render);
+  window.addEventListener("resize", render);
+  render();
+})();
@@ -0,0 +1,10 @@
+// Package static holds the stylesheet and flamegraph script of the web UI,
+// which the gallery stored next to the profiles inlines in its pages.
+package static
+
+import "embed"
+
+// FS holds style.css and flamegraph.js
+//
+//go:embed style.css flamegraph.js
+var FS embed.FS
@@ -0,0 +1,221 @@
+// Package ui serves a web UI over the capture index: the services with
+// captures, their recent captures, and the flamegraph and top functions of
+// each profile, downloaded from the bucket and rendered in the browser.
+package ui
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/index"
+	"github.com/a-kash-singh/bolometer/internal/ui/static"
+)
+
+// Path is the path the UI is served under
+const Path = "/ui/"
+
+// DefaultWindow is how far back the services page looks without a since
+// parameter
+const DefaultWindow = 7 * 24 * time.Hour
+
+// topN is the number of functions listed under a flamegraph
+const topN = 20
+
+//go:embed templates/*.html
+var content embed.FS
+
+// Downloader downloads stored profiles
+type Downloader interface {
+	Download(ctx context.Context, bucket, key string) ([]byte, error)
+}
+
+// Handler serves the UI. Every page only shows the captures of the namespaces
+// the caller may read.
+type Handler struct {
+	index      *index.Index
+	downloader Downloader
+	auth       index.Authorizer
+	templates  *template.Template
+	mux        *http.ServeMux
+}
+
+// NewHandler creates a UI over the given capture index, downloading profiles
+// with downloader and authorizing callers with auth
+func NewHandler(captureIndex *index.Index, downloader Downloader, auth index.Authorizer) (*Handler, error) {
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"viewable": viewable,
+	}).ParseFS(content, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UI templates: %w", err)
+	}
+
+	h := &Handler{
+		index:      captureIndex,
+		downloader: downloader,
+		auth:       auth,
+		templates:  templates,
+		mux:        http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET "+Path+"{$}", h.services)
+	h.mux.HandleFunc("GET "+Path+"captures", h.captures)
+	h.mux.HandleFunc("GET "+Path+"captures/{id}/{type}", h.profile)
+	h.mux.Handle("GET "+Path+"static/", http.StripPrefix(Path+"static/", http.FileServerFS(static.FS)))
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+// services lists the services captured in the window
+func (h *Handler) services(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if !query.Has("since") {
+		query.Set("since", DefaultWindow.String())
+	}
+	filter, err := index.ParseFilter(query, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.authorize(w, req, &filter) {
+		return
+	}
+
+	services, err := h.index.Services(req.Context(), filter)
+	if err != nil {
+		h.serverError(w, req, err)
+		return
+	}
+	h.render(w, req, "services.html", map[string]any{
+		"Title":    "Services",
+		"Query":    query,
+		"Services": services,
+	})
+}
+
+// captures lists the most recent captures matching the query
+func (h *Handler) captures(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter, err := index.ParseFilter(query, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.authorize(w, req, &filter) {
+		return
+	}
+
+	captures, err := h.index.Query(req.Context(), filter)
+	if err != nil {
+		h.serverError(w, req, err)
+		return
+	}
+	h.render(w, req, "captures.html", map[string]any{
+		"Title":    "Captures",
+		"Query":    query,
+		"Captures": captures,
+	})
+}
+
+// profile renders the flamegraph and top functions of a profile of a capture
+func (h *Handler) profile(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid capture id %q", req.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	profileType := req.PathValue("type")
+	if !viewable(profileType) {
+		http.Error(w, fmt.Sprintf("%s profiles cannot be viewed", profileType), http.StatusBadRequest)
+		return
+	}
+
+	// Only indexed profiles are downloaded, never arbitrary keys, and only
+	// those of the namespaces the caller may read
+	filter := index.Filter{ID: id, ProfileType: profileType}
+	if !h.authorize(w, req, &filter) {
+		return
+	}
+	captures, err := h.index.Query(req.Context(), filter)
+	if err != nil {
+		h.serverError(w, req, err)
+		return
+	}
+	if len(captures) == 0 || len(captures[0].Profiles) == 0 {
+		http.Error(w, fmt.Sprintf("capture %d has no %s profile", id, profileType), http.StatusNotFound)
+		return
+	}
+	capture := captures[0]
+	profile := capture.Profiles[0]
+
+	data, err := h.downloader.Download(req.Context(), capture.Bucket, profile.Key)
+	if err != nil {
+		h.serverError(w, req, err)
+		return
+	}
+	flamegraph, err := analysis.BuildFlamegraph(profileType, data)
+	if err != nil {
+		h.serverError(w, req, err)
+		return
+	}
+	summary, err := analysis.Summarize(profileType, data, topN)
+	if err != nil {
+		h.serverError(w, req, err)
+		return
+	}
+
+	h.render(w, req, "profile.html", map[string]any{
+		"Title":      profileType + " profile of " + capture.Pod.Name,
+		"Capture":    capture,
+		"Profile":    profile,
+		"Flamegraph": flamegraph,
+		"Summary":    summary,
+	})
+}
+
+// authorize restricts a filter to the namespaces the caller may read, and
+// writes the error response if the caller may not read the selected one
+func (h *Handler) authorize(w http.ResponseWriter, req *http.Request, filter *index.Filter) bool {
+	err := h.index.Authorize(req, h.auth, filter)
+	if errors.Is(err, index.ErrForbidden) {
+		http.Error(w, "cannot read the captures of namespace "+filter.Namespace, http.StatusForbidden)
+		return false
+	}
+	if err != nil {
+		h.serverError(w, req, err)
+		return false
+	}
+	return true
+}
+
+// render executes a page template
+func (h *Handler) render(w http.ResponseWriter, req *http.Request, name string, data map[string]any) {
+	data["Path"] = Path
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
+		log.FromContext(req.Context()).Error(err, "Failed to render UI page", "page", name)
+	}
+}
+
+// serverError logs an unexpected error and responds with a 500
+func (h *Handler) serverError(w http.ResponseWriter, req *http.Request, err error) {
+	log.FromContext(req.Context()).Error(err, "UI request failed", "path", req.URL.Path)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// viewable reports whether profiles of a type are pprof profiles the UI can
+// render. Execution traces are not.
+func viewable(profileType string) bool {
+	return profileType != "trace"
+}
@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/a-kash-singh/bolometer/internal/audit"
+	"github.com/a-kash-singh/bolometer/internal/index"
+)
+
+// fakeDownloader serves objects from a map of bucket/key to content
+type fakeDownloader map[string][]byte
+
+func (d fakeDownloader) Download(_ context.Context, bucket, key string) ([]byte, error) {
+	data, ok := d[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("s3://%s/%s not found", bucket, key)
+	}
+	return data, nil
+}
+
+// heapProfile builds a heap profile where main.handler calls main.alloc
+func heapProfile(t *testing.T) []byte {
+	t.Helper()
+
+	functions := []*profile.Function{{ID: 1, Name: "main.alloc"}, {ID: 2, Name: "main.handler"}}
+	locations := []*profile.Location{
+		{ID: 1, Line: []profile.Line{{Function: functions[0]}}},
+		{ID: 2, Line: []profile.Line{{Function: functions[1]}}},
+	}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "inuse_space", Unit: "bytes"}},
+		Sample:     []*profile.Sample{{Location: locations, Value: []int64{4096}}},
+		Location:   locations,
+		Function:   functions,
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// namespaceAuth allows reading the captures of the listed namespaces
+type namespaceAuth map[string]bool
+
+func (a namespaceAuth) CanReadCaptures(_ *http.Request, namespace string) (bool, error) {
+	return a[namespace], nil
+}
+
+func setupTestHandler(t *testing.T, auth namespaceAuth) *Handler {
+	t.Helper()
+	ctx := context.Background()
+
+	captureIndex, err := index.Open(ctx, index.DriverSQLite, filepath.Join(t.TempDir(), "captures.db"))
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	t.Cleanup(func() { captureIndex.Close() })
+
+	err = captureIndex.Write(ctx, audit.Record{
+		Time:        time.Now().Add(-time.Hour),
+		Config:      "default/my-app",
+		TriggeredBy: "threshold",
+		Reason:      "Memory usage 95.00% exceeds threshold 90%",
+		Pod:         audit.Pod{Namespace: "default", Name: "my-app-1"},
+		Service:     "my-app",
+		Bucket:      "my-bucket",
+		Profiles: []audit.Profile{
+			{Type: "heap", Key: "profiles/my-app-1-heap.pprof", SizeBytes: 1024},
+			{Type: "trace", Key: "profiles/my-app-1-trace.out", SizeBytes: 2048},
+		},
+		Outcome: audit.OutcomeSucceeded,
+	})
+	if err != nil {
+		t.Fatalf("Failed to index capture: %v", err)
+	}
+
+	handler, err := NewHandler(captureIndex, fakeDownloader{
+		"my-bucket/profiles/my-app-1-heap.pprof": heapProfile(t),
+	}, auth)
+	if err != nil {
+		t.Fatalf("NewHandler returned unexpected error: %v", err)
+	}
+	return handler
+}
+
+func get(handler http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestHandler_Pages(t *testing.T) {
+	handler := setupTestHandler(t, namespaceAuth{"default": true})
+
+	tests := []struct {
+		target   string
+		expected []string
+	}{
+		{Path, []string{"<td>default</td>", `captures?namespace=default&amp;service=my-app&amp;since=168h0m0s`}},
+		{Path + "captures?service=my-app", []string{"default/my-app-1", `href="/ui/captures/1/heap"`, `<span title="profiles/my-app-1-trace.out">trace</span>`}},
+		{Path + "captures/1/heap", []string{"heap profile of default/my-app-1", `"name":"main.handler"`, "<code>main.alloc</code>", "4.0KiB"}},
+		{Path + "static/flamegraph.js", []string{"flamegraph-data"}},
+	}
+
+	for _, tt := range tests {
+		rec := get(handler, tt.target)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d: %s", tt.target, rec.Code, rec.Body.String())
+			continue
+		}
+		for _, expected := range tt.expected {
+			if !strings.Contains(rec.Body.String(), expected) {
+				t.Errorf("GET %s: expected %q in:\n%s", tt.target, expected, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestHandler_ProfileErrors(t *testing.T) {
+	handler := setupTestHandler(t, namespaceAuth{"default": true})
+
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{Path + "captures/1/cpu", http.StatusNotFound},
+		{Path + "captures/2/heap", http.StatusNotFound},
+		{Path + "captures/1/trace", http.StatusBadRequest},
+		{Path + "captures/first/heap", http.StatusBadRequest},
+		{Path + "captures?since=yesterday", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		if rec := get(handler, tt.target); rec.Code != tt.code {
+			t.Errorf("GET %s: expected %d, got %d", tt.target, tt.code, rec.Code)
+		}
+	}
+}
+
+func TestHandler_Namespaces(t *testing.T) {
+	handler := setupTestHandler(t, namespaceAuth{"payments": true})
+
+	if rec := get(handler, Path); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "my-app") {
+		t.Errorf("Expected no services of other namespaces, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+	if rec := get(handler, Path+"captures?namespace=default"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a namespace the caller cannot read, got %d", rec.Code)
+	}
+	if rec := get(handler, Path+"captures/1/heap"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a capture of another namespace, got %d", rec.Code)
+	}
+}
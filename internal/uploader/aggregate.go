@@ -0,0 +1,202 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Directories, under the config prefix, holding the profiles merged across
+// the replicas of a service: per window, and per day
+const (
+	AggregatedPrefix = "aggregated"
+	DailyPrefix      = "aggregated/daily"
+)
+
+// profileExtension is the extension of uploaded profiles
+const profileExtension = ".pprof"
+
+// maxDeleteObjects is the most keys DeleteObjects accepts per call
+const maxDeleteObjects = 1000
+
+// ListProfiles returns the keys of the profiles of a service stored under
+// prefix, relative to the config prefix, and captured within [start, end),
+// by profile type
+func (u *S3Uploader) ListProfiles(ctx context.Context, prefix, service string, start, end time.Time) (map[string][]string, error) {
+	profiles := make(map[string][]string)
+	for _, date := range windowDates(start, end) {
+		keys, err := u.listKeys(ctx, path.Join(u.prefixOf("", service), prefix, date, service)+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			capturedAt, profileType, ok := parseProfileKey(key, start.Location())
+			if !ok || capturedAt.Before(start) || !capturedAt.Before(end) {
+				continue
+			}
+			profiles[profileType] = append(profiles[profileType], key)
+		}
+	}
+	return profiles, nil
+}
+
+// UploadAggregate uploads under prefix, relative to the config prefix, a
+// profile merging the profiles of a service captured in the window starting
+// at start, and returns its key
+func (u *S3Uploader) UploadAggregate(ctx context.Context, prefix, service, profileType string, start time.Time, data []byte, sources int) (string, error) {
+	key := u.serviceObjectKey(path.Join(u.prefixOf("", service), prefix), service, start, profileType, profileExtension)
+	err := u.putObject(ctx, key, ProfileContentType(profileType, data), ArtifactAggregate, data, map[string]string{
+		"service":       service,
+		"profile-type":  profileType,
+		"window-start":  start.Format(time.RFC3339),
+		"source-count":  fmt.Sprint(sources),
+		"aggregated-by": "bolometer",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload aggregated profile to S3: %w", err)
+	}
+	return key, nil
+}
+
+// DeleteBefore deletes the objects of a service stored under prefix, relative
+// to the config prefix, whose timestamp is before before, and returns how many
+// were deleted
+func (u *S3Uploader) DeleteBefore(ctx context.Context, prefix, service string, before time.Time) (int, error) {
+	base := path.Join(u.prefixOf("", service), prefix)
+	dates, err := u.listDates(ctx, base)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []string
+	for _, date := range dates {
+		day, err := time.ParseInLocation(dateLayout, date, before.Location())
+		if err != nil || !day.Before(before) {
+			continue
+		}
+		keys, err := u.listKeys(ctx, path.Join(base, date, service)+"/")
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			if objectTime, ok := parseObjectTime(key, before.Location()); ok && objectTime.Before(before) {
+				expired = append(expired, key)
+			}
+			// The gallery of a day goes with its last capture
+			if isGalleryKey(key) && !day.AddDate(0, 0, 1).After(before) {
+				expired = append(expired, key)
+			}
+		}
+	}
+
+	deleted := 0
+	for start := 0; start < len(expired); start += maxDeleteObjects {
+		end := min(start+maxDeleteObjects, len(expired))
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, key := range expired[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		output, err := u.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(u.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete expired objects from S3: %w", err)
+		}
+		deleted += len(objects) - len(output.Errors)
+	}
+	return deleted, nil
+}
+
+// listKeys returns the keys of the objects under prefix
+func (u *S3Uploader) listKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(u.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(u.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", u.bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+// listDates returns the date directories directly under base
+func (u *S3Uploader) listDates(ctx context.Context, base string) ([]string, error) {
+	prefix := ""
+	if base != "" && base != "." {
+		prefix = base + "/"
+	}
+
+	var dates []string
+	paginator := s3.NewListObjectsV2Paginator(u.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(u.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", u.bucket, prefix, err)
+		}
+		for _, common := range page.CommonPrefixes {
+			dir := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(common.Prefix), prefix), "/")
+			if _, err := time.Parse(dateLayout, dir); err == nil {
+				dates = append(dates, dir)
+			}
+		}
+	}
+	return dates, nil
+}
+
+// parseObjectTime returns the timestamp an object key starts its name with,
+// {timestamp}-{name}{extension}, in loc
+func parseObjectTime(key string, loc *time.Location) (time.Time, bool) {
+	name := path.Base(key)
+	if len(name) <= len(timestampLayout) || name[len(timestampLayout)] != '-' {
+		return time.Time{}, false
+	}
+	objectTime, err := time.ParseInLocation(timestampLayout, name[:len(timestampLayout)], loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return objectTime, true
+}
+
+// parseProfileKey returns the capture time and profile type of a profile key,
+// {timestamp}-{type}.pprof, in loc
+func parseProfileKey(key string, loc *time.Location) (time.Time, string, bool) {
+	capturedAt, ok := parseObjectTime(key, loc)
+	if !ok || !strings.HasSuffix(key, profileExtension) {
+		return time.Time{}, "", false
+	}
+	profileType := strings.TrimSuffix(path.Base(key)[len(timestampLayout)+1:], profileExtension)
+	if profileType == "" {
+		return time.Time{}, "", false
+	}
+	return capturedAt, profileType, true
+}
+
+// windowDates returns the date directories holding objects captured within [start, end)
+func windowDates(start, end time.Time) []string {
+	var dates []string
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format(dateLayout))
+	}
+	if last := end.Add(-time.Nanosecond).Format(dateLayout); len(dates) > 0 && dates[len(dates)-1] != last {
+		dates = append(dates, last)
+	}
+	return dates
+}
@@ -0,0 +1,90 @@
+package uploader
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProfileKey(t *testing.T) {
+	tests := []struct {
+		key         string
+		ok          bool
+		capturedAt  time.Time
+		profileType string
+	}{
+		{
+			key:         "profiles/2024-01-15/my-app/20240115-103045-heap.pprof",
+			ok:          true,
+			capturedAt:  time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+			profileType: "heap",
+		},
+		{key: "profiles/2024-01-15/my-app/20240115-103045-manifest.json"},
+		{key: "profiles/2024-01-15/my-app/heap.pprof"},
+		{key: "profiles/2024-01-15/my-app/20240115-103045-.pprof"},
+	}
+
+	for _, tt := range tests {
+		capturedAt, profileType, ok := parseProfileKey(tt.key, time.UTC)
+		if ok != tt.ok {
+			t.Errorf("parseProfileKey(%q) ok = %v, want %v", tt.key, ok, tt.ok)
+			continue
+		}
+		if ok && (!capturedAt.Equal(tt.capturedAt) || profileType != tt.profileType) {
+			t.Errorf("parseProfileKey(%q) = %v, %q", tt.key, capturedAt, profileType)
+		}
+	}
+}
+
+func TestParseObjectTime(t *testing.T) {
+	objectTime, ok := parseObjectTime("profiles/2024-01-15/my-app/20240115-103045-manifest.json", time.UTC)
+	if !ok || !objectTime.Equal(time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)) {
+		t.Errorf("Unexpected object time %v, %v", objectTime, ok)
+	}
+
+	if _, ok := parseObjectTime("profiles/2024-01-15/my-app/index.html", time.UTC); ok {
+		t.Error("Expected no time for a key without a timestamp")
+	}
+}
+
+func TestWindowDates(t *testing.T) {
+	start := time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC)
+
+	if dates := windowDates(start, start.Add(time.Hour)); !reflect.DeepEqual(dates, []string{"2024-01-15"}) {
+		t.Errorf("Expected a window ending at midnight to cover one day, got %v", dates)
+	}
+	if dates := windowDates(start, start.Add(2*time.Hour)); !reflect.DeepEqual(dates, []string{"2024-01-15", "2024-01-16"}) {
+		t.Errorf("Expected a window across midnight to cover two days, got %v", dates)
+	}
+}
+
+func TestServiceObjectKey_Aggregated(t *testing.T) {
+	u := &S3Uploader{prefix: "profiles"}
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	key := u.serviceObjectKey("profiles/"+AggregatedPrefix, "my-app", start, "cpu", profileExtension)
+	if key != "profiles/aggregated/2024-01-15/my-app/20240115-100000-cpu.pprof" {
+		t.Errorf("Unexpected aggregated key %q", key)
+	}
+
+	key = u.serviceObjectKey("profiles/"+DailyPrefix, "my-app", start.Truncate(24*time.Hour), "cpu", profileExtension)
+	if key != "profiles/aggregated/daily/2024-01-15/my-app/20240115-000000-cpu.pprof" {
+		t.Errorf("Unexpected daily key %q", key)
+	}
+}
+
+func TestParseManifestKey(t *testing.T) {
+	capturedAt, ok := parseManifestKey("profiles/2024-01-15/my-app/20240115-103045-manifest.json", time.UTC)
+	if !ok || !capturedAt.Equal(time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)) {
+		t.Errorf("Unexpected capture time %v, %v", capturedAt, ok)
+	}
+
+	for _, key := range []string{
+		"profiles/2024-01-15/my-app/20240115-103045-heap.pprof",
+		"profiles/2024-01-15/my-app/20240115-103045-summary.json",
+	} {
+		if _, ok := parseManifestKey(key, time.UTC); ok {
+			t.Errorf("Expected %q not to be a manifest", key)
+		}
+	}
+}
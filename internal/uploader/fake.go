@@ -0,0 +1,126 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+// Fake is an uploader keeping manifests in memory without storing anything,
+// for tests of code uploading profiles. Keys follow the layout of S3Uploader.
+type Fake struct {
+	// Bucket and Prefix are recorded in the manifests and keys
+	Bucket string
+	Prefix string
+
+	// Err fails every upload if set
+	Err error
+
+	// AccessErr fails every access check if set
+	AccessErr error
+
+	// LifecycleErr fails every change of lifecycle rules if set
+	LifecycleErr error
+
+	// Stored is the size MeasurePrefix reports for every prefix
+	Stored int64
+
+	mu        sync.Mutex
+	manifests []*Manifest
+	rules     map[string]LifecycleRule
+}
+
+var (
+	_ Uploader         = (*Fake)(nil)
+	_ AccessChecker    = (*Fake)(nil)
+	_ LifecycleManager = (*Fake)(nil)
+	_ StorageMeter     = (*Fake)(nil)
+)
+
+// CheckAccess implements AccessChecker
+func (f *Fake) CheckAccess(ctx context.Context, write bool) error {
+	if f.AccessErr != nil {
+		return fmt.Errorf("failed to access bucket %s: %w", f.Bucket, f.AccessErr)
+	}
+	return ctx.Err()
+}
+
+// PutLifecycleRule implements LifecycleManager
+func (f *Fake) PutLifecycleRule(ctx context.Context, rule LifecycleRule) error {
+	if f.LifecycleErr != nil {
+		return fmt.Errorf("failed to put lifecycle configuration of bucket %s: %w", f.Bucket, f.LifecycleErr)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rules == nil {
+		f.rules = make(map[string]LifecycleRule)
+	}
+	f.rules[rule.ID] = rule
+	return nil
+}
+
+// DeleteLifecycleRule implements LifecycleManager
+func (f *Fake) DeleteLifecycleRule(ctx context.Context, id string) error {
+	if f.LifecycleErr != nil {
+		return fmt.Errorf("failed to put lifecycle configuration of bucket %s: %w", f.Bucket, f.LifecycleErr)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.rules, id)
+	return nil
+}
+
+// MeasurePrefix implements StorageMeter
+func (f *Fake) MeasurePrefix(ctx context.Context, prefix string) (int64, error) {
+	return f.Stored, ctx.Err()
+}
+
+// LifecycleRules returns the lifecycle rules put so far, by ID
+func (f *Fake) LifecycleRules() map[string]LifecycleRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rules := make(map[string]LifecycleRule, len(f.rules))
+	for id, rule := range f.rules {
+		rules[id] = rule
+	}
+	return rules
+}
+
+// UploadProfiles implements Uploader
+func (f *Fake) UploadProfiles(ctx context.Context, pod *corev1.Pod, profiles []profiler.Profile, trigger metrics.Trigger) (*Manifest, error) {
+	if f.Err != nil {
+		return nil, fmt.Errorf("failed to upload profiles: %w", f.Err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	layout := &S3Uploader{bucket: f.Bucket, prefix: f.Prefix}
+	manifest := layout.newManifest(pod, profiles, trigger)
+	for _, profile := range profiles {
+		key := profile.DuplicateOf
+		if key == "" {
+			key = layout.generateKey(pod, profile)
+		}
+		manifest.Objects = append(manifest.Objects, profileEntry(profile, key))
+	}
+
+	f.mu.Lock()
+	f.manifests = append(f.manifests, manifest)
+	f.mu.Unlock()
+	return manifest, nil
+}
+
+// Uploaded returns the manifests of the uploads so far, in order
+func (f *Fake) Uploaded() []*Manifest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*Manifest(nil), f.manifests...)
+}
@@ -0,0 +1,313 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+	"github.com/a-kash-singh/bolometer/internal/ui/static"
+)
+
+// Names of the gallery of a service and day, stored in its directory
+const (
+	GalleryPage    = "index.html"
+	GalleryListing = "index.json"
+)
+
+// Kinds of gallery objects
+const (
+	ArtifactGallery    = "gallery"
+	ArtifactFlamegraph = "flamegraph"
+)
+
+// ContentTypeHTML is the type of gallery pages and flamegraphs
+const ContentTypeHTML = "text/html; charset=utf-8"
+
+// flamegraphSuffix ends the names of flamegraph pages, which are named after
+// their profile, {timestamp}-{type}-flamegraph.html
+const flamegraphSuffix = "-flamegraph.html"
+
+// flamegraphTopN is the number of functions listed under a stored flamegraph
+const flamegraphTopN = 20
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var (
+	galleryTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+	// The stylesheet and flamegraph script of the web UI, inlined in the pages
+	galleryStylesheet = template.CSS(mustReadStatic("style.css"))
+	flamegraphScript  = template.JS(mustReadStatic("flamegraph.js"))
+)
+
+// Gallery lists the captures of a service on a day, stored as index.json in
+// their directory and rendered as index.html
+type Gallery struct {
+	// SchemaVersion is the SchemaVersion the listing was written with
+	SchemaVersion string `json:"schemaVersion"`
+
+	Service  string           `json:"service"`
+	Date     string           `json:"date"`
+	Updated  time.Time        `json:"updated"`
+	Captures []GalleryCapture `json:"captures"`
+}
+
+// GalleryCapture is a capture listed in a gallery
+type GalleryCapture struct {
+	CapturedAt   time.Time        `json:"capturedAt"`
+	PodName      string           `json:"podName"`
+	PodNamespace string           `json:"podNamespace"`
+	Reason       string           `json:"reason"`
+	Manifest     string           `json:"manifest"`
+	Profiles     []GalleryProfile `json:"profiles"`
+}
+
+// GalleryProfile is a profile of a capture listed in a gallery
+type GalleryProfile struct {
+	Type         string `json:"type"`
+	Key          string `json:"key"`
+	SizeBytes    int    `json:"sizeBytes"`
+	Flamegraph   string `json:"flamegraph,omitempty"`
+	Deduplicated bool   `json:"deduplicated,omitempty"`
+}
+
+// flamegraphKey returns the key of the flamegraph page of a profile
+func flamegraphKey(profileKey string) string {
+	return strings.TrimSuffix(profileKey, path.Ext(profileKey)) + flamegraphSuffix
+}
+
+// isGalleryKey reports whether a key is a gallery page or listing
+func isGalleryKey(key string) bool {
+	name := path.Base(key)
+	return name == GalleryPage || name == GalleryListing
+}
+
+// uploadFlamegraphs stores a flamegraph page next to each pprof profile of a
+// capture, recording it in the manifest. A duplicate refers to the page of the
+// profile stored earlier, and profiles that cannot be parsed get none.
+func (u *S3Uploader) uploadFlamegraphs(ctx context.Context, manifest *Manifest, profiles []profiler.Profile) error {
+	for i, profile := range profiles {
+		object := &manifest.Objects[i]
+		if profile.Type == analysis.TraceProfileType {
+			continue
+		}
+		if object.Deduplicated {
+			object.Flamegraph = flamegraphKey(object.Key)
+			continue
+		}
+
+		flamegraph, err := analysis.BuildFlamegraph(profile.Type, profile.Data)
+		if err != nil {
+			continue
+		}
+		summary, err := analysis.Summarize(profile.Type, profile.Data, flamegraphTopN)
+		if err != nil {
+			continue
+		}
+
+		var page bytes.Buffer
+		if err := galleryTemplates.ExecuteTemplate(&page, "flamegraph.html", map[string]any{
+			"Manifest":   manifest,
+			"Key":        object.Key,
+			"Flamegraph": flamegraph,
+			"Summary":    summary,
+			"Stylesheet": galleryStylesheet,
+			"Script":     flamegraphScript,
+		}); err != nil {
+			return fmt.Errorf("failed to render flamegraph: %w", err)
+		}
+
+		key := flamegraphKey(object.Key)
+		metadata := captureMetadata(manifest)
+		metadata["profile-type"] = profile.Type
+		if err := u.putObject(ctx, key, ContentTypeHTML, ArtifactFlamegraph, page.Bytes(), metadata); err != nil {
+			return fmt.Errorf("failed to upload flamegraph to S3: %w", err)
+		}
+		object.Flamegraph = key
+	}
+	return nil
+}
+
+// updateGallery adds a capture to the gallery of its service and day and
+// stores the listing and page again. A missing or unreadable listing is
+// rebuilt from the manifests of the directory.
+func (u *S3Uploader) updateGallery(ctx context.Context, manifest *Manifest) error {
+	dir := path.Dir(manifest.Key)
+	gallery, err := u.loadGallery(ctx, dir)
+	if err != nil {
+		return err
+	}
+	gallery.Service = manifest.Service
+	gallery.Date = manifest.CapturedAt.Format(dateLayout)
+	gallery.add(galleryCaptureOf(manifest))
+	gallery.Updated = time.Now()
+
+	listing, err := json.MarshalIndent(gallery, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode gallery: %w", err)
+	}
+	page, err := u.renderGallery(ctx, dir, gallery)
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]string{"service": manifest.Service}
+	for _, object := range []struct {
+		name, contentType string
+		data              []byte
+	}{
+		{GalleryListing, ContentTypeJSON, listing},
+		{GalleryPage, ContentTypeHTML, page},
+	} {
+		if err := u.putObject(ctx, path.Join(dir, object.name), object.contentType, ArtifactGallery, object.data, metadata); err != nil {
+			return fmt.Errorf("failed to upload gallery to S3: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadGallery returns the gallery listing of a directory, rebuilt from the
+// manifests in it if it has no readable listing
+func (u *S3Uploader) loadGallery(ctx context.Context, dir string) (*Gallery, error) {
+	gallery := &Gallery{SchemaVersion: SchemaVersion}
+	data, err := u.Download(ctx, u.bucket, path.Join(dir, GalleryListing))
+	if err == nil && json.Unmarshal(data, gallery) == nil {
+		return gallery, nil
+	}
+	var apiErr interface{ ErrorCode() string }
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey") {
+		return nil, err
+	}
+
+	gallery = &Gallery{SchemaVersion: SchemaVersion}
+	keys, err := u.listKeys(ctx, dir+"/")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, manifestSuffix) {
+			continue
+		}
+		data, err := u.Download(ctx, u.bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		manifest := &Manifest{}
+		if err := json.Unmarshal(data, manifest); err != nil {
+			continue
+		}
+		manifest.Key = key
+		gallery.add(galleryCaptureOf(manifest))
+	}
+	return gallery, nil
+}
+
+// renderGallery renders the page of a gallery, linking the objects by name in
+// the directory or, when links expire, by presigned URL
+func (u *S3Uploader) renderGallery(ctx context.Context, dir string, gallery *Gallery) ([]byte, error) {
+	keys := []string{path.Join(dir, GalleryListing)}
+	for _, capture := range gallery.Captures {
+		keys = append(keys, capture.Manifest)
+		for _, profile := range capture.Profiles {
+			keys = append(keys, profile.Key)
+			if profile.Flamegraph != "" {
+				keys = append(keys, profile.Flamegraph)
+			}
+		}
+	}
+
+	links := make(map[string]string, len(keys))
+	if u.galleryLinkExpiry > 0 {
+		urls, err := presignURLs(ctx, u.client, u.bucket, keys, u.galleryLinkExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign gallery links: %w", err)
+		}
+		for i, key := range keys {
+			links[key] = urls[i]
+		}
+	} else {
+		for _, key := range keys {
+			links[key] = galleryLink(dir, key)
+		}
+	}
+
+	var page bytes.Buffer
+	if err := galleryTemplates.ExecuteTemplate(&page, "gallery.html", map[string]any{
+		"Gallery":    gallery,
+		"Links":      links,
+		"Listing":    links[keys[0]],
+		"Stylesheet": galleryStylesheet,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to render gallery: %w", err)
+	}
+	return page.Bytes(), nil
+}
+
+// galleryLink returns the link from the page of a gallery in dir to a key:
+// its name when it is in dir, as the objects of a capture are, or a path
+// relative to dir when a duplicate refers to an earlier day
+func galleryLink(dir, key string) string {
+	if path.Dir(key) == dir {
+		return path.Base(key)
+	}
+	depth := strings.Count(dir, "/") + 1
+	return strings.Repeat("../", depth) + key
+}
+
+// add adds a capture to the gallery, replacing the listed capture with the same
+// manifest, keeping the captures ordered newest first
+func (g *Gallery) add(capture GalleryCapture) {
+	replaced := false
+	for i := range g.Captures {
+		if g.Captures[i].Manifest == capture.Manifest {
+			g.Captures[i], replaced = capture, true
+		}
+	}
+	if !replaced {
+		g.Captures = append(g.Captures, capture)
+	}
+	sort.SliceStable(g.Captures, func(i, j int) bool {
+		return g.Captures[i].CapturedAt.After(g.Captures[j].CapturedAt)
+	})
+}
+
+// galleryCaptureOf returns the gallery entry of a capture
+func galleryCaptureOf(manifest *Manifest) GalleryCapture {
+	capture := GalleryCapture{
+		CapturedAt:   manifest.CapturedAt,
+		PodName:      manifest.PodName,
+		PodNamespace: manifest.PodNamespace,
+		Reason:       manifest.Reason,
+		Manifest:     manifest.Key,
+	}
+	for _, object := range manifest.Objects {
+		capture.Profiles = append(capture.Profiles, GalleryProfile{
+			Type:         object.Type,
+			Key:          object.Key,
+			SizeBytes:    object.SizeBytes,
+			Flamegraph:   object.Flamegraph,
+			Deduplicated: object.Deduplicated,
+		})
+	}
+	return capture
+}
+
+// mustReadStatic returns an asset of the web UI, which is embedded
+func mustReadStatic(name string) string {
+	data, err := static.FS.ReadFile(name)
+	if err != nil {
+		panic(fmt.Sprintf("missing static asset %s: %v", name, err))
+	}
+	return string(data)
+}
@@ -0,0 +1,148 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+)
+
+func TestFlamegraphKey(t *testing.T) {
+	key := flamegraphKey("profiles/2024-01-15/my-app/20240115-103045-heap.pprof")
+	if key != "profiles/2024-01-15/my-app/20240115-103045-heap-flamegraph.html" {
+		t.Errorf("Unexpected flamegraph key %q", key)
+	}
+	if _, _, ok := parseProfileKey(key, time.UTC); ok {
+		t.Error("Expected a flamegraph not to be taken for a profile")
+	}
+}
+
+func TestIsGalleryKey(t *testing.T) {
+	for key, expected := range map[string]bool{
+		"profiles/2024-01-15/my-app/index.html":                    true,
+		"profiles/2024-01-15/my-app/index.json":                    true,
+		"profiles/2024-01-15/my-app/20240115-103045-manifest.json": false,
+	} {
+		if got := isGalleryKey(key); got != expected {
+			t.Errorf("isGalleryKey(%q) = %v, expected %v", key, got, expected)
+		}
+	}
+}
+
+func TestGalleryLink(t *testing.T) {
+	dir := "profiles/2024-01-15/my-app"
+	if link := galleryLink(dir, dir+"/20240115-103045-heap.pprof"); link != "20240115-103045-heap.pprof" {
+		t.Errorf("Expected a link by name within the directory, got %q", link)
+	}
+
+	// A duplicate of a profile stored the day before
+	link := galleryLink(dir, "profiles/2024-01-14/my-app/20240114-235900-heap.pprof")
+	if link != "../../../profiles/2024-01-14/my-app/20240114-235900-heap.pprof" {
+		t.Errorf("Expected a link relative to the directory, got %q", link)
+	}
+}
+
+func TestGallery_Add(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	gallery := &Gallery{}
+	gallery.add(GalleryCapture{CapturedAt: start, Manifest: "a-manifest.json"})
+	gallery.add(GalleryCapture{CapturedAt: start.Add(time.Hour), Manifest: "b-manifest.json"})
+	gallery.add(GalleryCapture{CapturedAt: start, Manifest: "a-manifest.json", Reason: "threshold"})
+
+	if len(gallery.Captures) != 2 {
+		t.Fatalf("Expected 2 captures, got %+v", gallery.Captures)
+	}
+	if gallery.Captures[0].Manifest != "b-manifest.json" {
+		t.Errorf("Expected the newest capture first, got %+v", gallery.Captures)
+	}
+	if gallery.Captures[1].Reason != "threshold" {
+		t.Errorf("Expected the capture of the same manifest to be replaced, got %+v", gallery.Captures[1])
+	}
+}
+
+func TestRenderGallery(t *testing.T) {
+	dir := "profiles/2024-01-15/my-app"
+	manifest := &Manifest{
+		PodName:      "my-app-7d4b9",
+		PodNamespace: "default",
+		Service:      "my-app",
+		Reason:       "threshold",
+		CapturedAt:   time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+		Key:          dir + "/20240115-103045-manifest.json",
+		Objects: []ManifestEntry{
+			{Type: "heap", Key: dir + "/20240115-103045-heap.pprof", Flamegraph: dir + "/20240115-103045-heap-flamegraph.html"},
+			{Type: "goroutine", Key: dir + "/20240115-090000-goroutine.pprof", Deduplicated: true},
+		},
+	}
+	gallery := &Gallery{Service: "my-app", Date: "2024-01-15"}
+	gallery.add(galleryCaptureOf(manifest))
+
+	page, err := (&S3Uploader{}).renderGallery(context.Background(), dir, gallery)
+	if err != nil {
+		t.Fatalf("renderGallery returned unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Captures of my-app on 2024-01-15",
+		"default/my-app-7d4b9",
+		`href="20240115-103045-heap.pprof"`,
+		`href="20240115-103045-heap-flamegraph.html"`,
+		`href="20240115-103045-manifest.json"`,
+		`href="index.json"`,
+		"(unchanged)",
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("Expected the page to contain %q", expected)
+		}
+	}
+}
+
+func TestFlamegraphTemplate(t *testing.T) {
+	fn := &profile.Function{ID: 1, Name: "main.alloc"}
+	location := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "inuse_space", Unit: "bytes"}},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{location}, Value: []int64{1024}}},
+		Location:   []*profile.Location{location},
+		Function:   []*profile.Function{fn},
+	}
+	var data bytes.Buffer
+	if err := p.Write(&data); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	flamegraph, err := analysis.BuildFlamegraph("heap", data.Bytes())
+	if err != nil {
+		t.Fatalf("BuildFlamegraph returned unexpected error: %v", err)
+	}
+	summary, err := analysis.Summarize("heap", data.Bytes(), flamegraphTopN)
+	if err != nil {
+		t.Fatalf("Summarize returned unexpected error: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err := galleryTemplates.ExecuteTemplate(&page, "flamegraph.html", map[string]any{
+		"Manifest":   &Manifest{PodName: "my-app-7d4b9", PodNamespace: "default", Bucket: "test-bucket"},
+		"Key":        "profiles/heap.pprof",
+		"Flamegraph": flamegraph,
+		"Summary":    summary,
+		"Stylesheet": galleryStylesheet,
+		"Script":     flamegraphScript,
+	}); err != nil {
+		t.Fatalf("Failed to render flamegraph: %v", err)
+	}
+
+	// The page is self-contained, so it needs no other object to render
+	for _, expected := range []string{"main.alloc", "flamegraph-data", "getElementById", "#flamegraph"} {
+		if !strings.Contains(page.String(), expected) {
+			t.Errorf("Expected the page to contain %q", expected)
+		}
+	}
+	if strings.Contains(page.String(), "<script src=") || strings.Contains(page.String(), "stylesheet") ||
+		strings.Contains(page.String(), "ZgotmplZ") {
+		t.Error("Expected the assets to be inlined")
+	}
+}
@@ -0,0 +1,165 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// LifecycleRule is a bucket lifecycle rule for the objects under a prefix
+type LifecycleRule struct {
+	// ID identifies the rule among the rules of the bucket
+	ID string
+
+	// Prefix is the key prefix of the objects the rule applies to
+	Prefix string
+
+	// TransitionDays moves objects to TransitionStorageClass this many days
+	// after their upload, never if 0
+	TransitionDays         int
+	TransitionStorageClass string
+
+	// ExpirationDays deletes objects this many days after their upload, never
+	// if 0
+	ExpirationDays int
+}
+
+// StaticPrefix returns the part of prefix that is the same for every object,
+// rendered with values up to the first placeholder without a value. It fails
+// if that part is empty, as a rule for it would apply to the whole bucket.
+func StaticPrefix(prefix string, values PrefixValues) (string, error) {
+	cut := len(prefix)
+	for _, placeholder := range []struct{ name, value string }{
+		{NamespacePlaceholder, values.Namespace},
+		{ServicePlaceholder, values.Service},
+		{ClusterPlaceholder, values.Cluster},
+		{ConfigNamePlaceholder, values.ConfigName},
+	} {
+		if i := strings.Index(prefix, placeholder.name); i >= 0 && i < cut && placeholder.value == "" {
+			cut = i
+		}
+	}
+
+	static := RenderPrefix(prefix[:cut], values)
+	if cut == len(prefix) && static != "" {
+		// Keys are joined to the prefix with a slash
+		static = path.Clean(static) + "/"
+	}
+	if strings.Trim(static, "/") == "" {
+		return "", fmt.Errorf("prefix %q has no part shared by every object", prefix)
+	}
+	return static, nil
+}
+
+// PutLifecycleRule implements LifecycleManager. The lifecycle configuration
+// of the bucket is read and written back with the rule replaced, so the other
+// rules are kept.
+func (u *S3Uploader) PutLifecycleRule(ctx context.Context, rule LifecycleRule) error {
+	rules, err := u.lifecycleRules(ctx)
+	if err != nil {
+		return err
+	}
+	return u.putLifecycleRules(ctx, mergeLifecycleRule(rules, lifecycleRuleOf(rule)))
+}
+
+// DeleteLifecycleRule implements LifecycleManager. The lifecycle
+// configuration of the bucket is deleted once it has no rule left.
+func (u *S3Uploader) DeleteLifecycleRule(ctx context.Context, id string) error {
+	rules, err := u.lifecycleRules(ctx)
+	if err != nil {
+		return err
+	}
+	kept := removeLifecycleRule(rules, id)
+	if len(kept) == len(rules) {
+		return nil
+	}
+	if len(kept) > 0 {
+		return u.putLifecycleRules(ctx, kept)
+	}
+	if _, err := u.client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+		Bucket: aws.String(u.bucket),
+	}); err != nil {
+		return fmt.Errorf("failed to delete lifecycle configuration of bucket %s: %w", u.bucket, err)
+	}
+	return nil
+}
+
+// lifecycleRules returns the lifecycle rules of the bucket, none if it has
+// no lifecycle configuration
+func (u *S3Uploader) lifecycleRules(ctx context.Context) ([]types.LifecycleRule, error) {
+	output, err := u.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(u.bucket),
+	})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get lifecycle configuration of bucket %s: %w", u.bucket, err)
+	}
+	return output.Rules, nil
+}
+
+// putLifecycleRules replaces the lifecycle configuration of the bucket
+func (u *S3Uploader) putLifecycleRules(ctx context.Context, rules []types.LifecycleRule) error {
+	if _, err := u.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(u.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	}); err != nil {
+		return fmt.Errorf("failed to put lifecycle configuration of bucket %s: %w", u.bucket, err)
+	}
+	return nil
+}
+
+// lifecycleRuleOf returns the S3 lifecycle rule of rule
+func lifecycleRuleOf(rule LifecycleRule) types.LifecycleRule {
+	s3Rule := types.LifecycleRule{
+		ID:     aws.String(rule.ID),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilterMemberPrefix{Value: rule.Prefix},
+	}
+	if rule.TransitionDays > 0 {
+		s3Rule.Transitions = []types.Transition{{
+			Days:         aws.Int32(int32(rule.TransitionDays)),
+			StorageClass: types.TransitionStorageClass(rule.TransitionStorageClass),
+		}}
+	}
+	if rule.ExpirationDays > 0 {
+		s3Rule.Expiration = &types.LifecycleExpiration{Days: aws.Int32(int32(rule.ExpirationDays))}
+	}
+	return s3Rule
+}
+
+// mergeLifecycleRule returns rules with the rule of the same ID replaced by
+// rule, or rule appended if there is none
+func mergeLifecycleRule(rules []types.LifecycleRule, rule types.LifecycleRule) []types.LifecycleRule {
+	merged := make([]types.LifecycleRule, 0, len(rules)+1)
+	replaced := false
+	for _, existing := range rules {
+		if aws.ToString(existing.ID) == aws.ToString(rule.ID) {
+			existing, replaced = rule, true
+		}
+		merged = append(merged, existing)
+	}
+	if !replaced {
+		merged = append(merged, rule)
+	}
+	return merged
+}
+
+// removeLifecycleRule returns rules without the rule of an ID
+func removeLifecycleRule(rules []types.LifecycleRule, id string) []types.LifecycleRule {
+	kept := make([]types.LifecycleRule, 0, len(rules))
+	for _, rule := range rules {
+		if aws.ToString(rule.ID) != id {
+			kept = append(kept, rule)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,93 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestStaticPrefix(t *testing.T) {
+	values := PrefixValues{Cluster: "eu-west", ConfigName: "api-profiling"}
+
+	tests := []struct {
+		prefix   string
+		expected string
+		wantErr  bool
+	}{
+		{prefix: "profiles", expected: "profiles/"},
+		{prefix: "profiles/", expected: "profiles/"},
+		{prefix: "{cluster}/{configName}", expected: "eu-west/api-profiling/"},
+		{prefix: "tenants/{namespace}/{service}", expected: "tenants/"},
+		{prefix: "{cluster}/{namespace}/{configName}", expected: "eu-west/"},
+		{prefix: "{namespace}/profiles", wantErr: true},
+		{prefix: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := StaticPrefix(tt.prefix, values)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("StaticPrefix(%q) = %q, expected an error", tt.prefix, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("StaticPrefix(%q) = %q, %v, expected %q", tt.prefix, got, err, tt.expected)
+		}
+	}
+}
+
+func TestLifecycleRuleOf(t *testing.T) {
+	rule := lifecycleRuleOf(LifecycleRule{
+		ID:                     "bolometer-default-api",
+		Prefix:                 "profiles/",
+		TransitionDays:         30,
+		TransitionStorageClass: "GLACIER_IR",
+		ExpirationDays:         90,
+	})
+
+	if aws.ToString(rule.ID) != "bolometer-default-api" || rule.Status != types.ExpirationStatusEnabled {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+	if filter, ok := rule.Filter.(*types.LifecycleRuleFilterMemberPrefix); !ok || filter.Value != "profiles/" {
+		t.Errorf("Expected a filter on profiles/, got %#v", rule.Filter)
+	}
+	if len(rule.Transitions) != 1 || aws.ToInt32(rule.Transitions[0].Days) != 30 ||
+		rule.Transitions[0].StorageClass != types.TransitionStorageClassGlacierIr {
+		t.Errorf("Unexpected transitions %+v", rule.Transitions)
+	}
+	if rule.Expiration == nil || aws.ToInt32(rule.Expiration.Days) != 90 {
+		t.Errorf("Unexpected expiration %+v", rule.Expiration)
+	}
+
+	expireOnly := lifecycleRuleOf(LifecycleRule{ID: "bolometer-default-api", Prefix: "profiles/", ExpirationDays: 7})
+	if len(expireOnly.Transitions) != 0 {
+		t.Errorf("Expected no transition, got %+v", expireOnly.Transitions)
+	}
+}
+
+func TestMergeLifecycleRule(t *testing.T) {
+	rules := []types.LifecycleRule{
+		{ID: aws.String("logs"), Status: types.ExpirationStatusEnabled},
+		{ID: aws.String("bolometer-default-api"), Status: types.ExpirationStatusDisabled},
+		{ID: aws.String("backups"), Status: types.ExpirationStatusEnabled},
+	}
+
+	merged := mergeLifecycleRule(rules, types.LifecycleRule{ID: aws.String("bolometer-default-api"), Status: types.ExpirationStatusEnabled})
+	if len(merged) != 3 || aws.ToString(merged[1].ID) != "bolometer-default-api" || merged[1].Status != types.ExpirationStatusEnabled {
+		t.Errorf("Expected the rule to be replaced in place, got %+v", merged)
+	}
+	if rules[1].Status != types.ExpirationStatusDisabled {
+		t.Error("Expected the rules to be left unchanged")
+	}
+
+	merged = mergeLifecycleRule(rules, types.LifecycleRule{ID: aws.String("bolometer-default-web")})
+	if len(merged) != 4 || aws.ToString(merged[3].ID) != "bolometer-default-web" {
+		t.Errorf("Expected the rule to be appended, got %+v", merged)
+	}
+
+	kept := removeLifecycleRule(rules, "bolometer-default-api")
+	if len(kept) != 2 || aws.ToString(kept[0].ID) != "logs" || aws.ToString(kept[1].ID) != "backups" {
+		t.Errorf("Expected the other rules to be kept, got %+v", kept)
+	}
+}
@@ -0,0 +1,96 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ObjectURL returns a link to an uploaded object: the S3 console on AWS, or the
+// path-style object URL on S3-compatible services
+func ObjectURL(cfg S3Config, key string) string {
+	if cfg.Endpoint != "" {
+		return strings.TrimSuffix(cfg.Endpoint, "/") + "/" + url.PathEscape(cfg.Bucket) + "/" + escapeKey(key)
+	}
+
+	query := url.Values{}
+	query.Set("region", cfg.Region)
+	query.Set("prefix", key)
+	return "https://s3.console.aws.amazon.com/s3/object/" + url.PathEscape(cfg.Bucket) + "?" + query.Encode()
+}
+
+// PresignURLs returns presigned GET URLs for uploaded objects, valid for expiry
+func PresignURLs(ctx context.Context, cfg S3Config, keys []string, expiry time.Duration) ([]string, error) {
+	client, err := NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return presignURLs(ctx, client, cfg.Bucket, keys, expiry)
+}
+
+// PresignManifest presigns download URLs of the objects of a manifest, valid
+// for expiry
+func PresignManifest(ctx context.Context, cfg S3Config, manifest *Manifest, expiry time.Duration) error {
+	client, err := NewS3Client(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return presignManifest(ctx, client, cfg.Bucket, manifest, expiry)
+}
+
+// PresignManifest implements Presigner
+func (u *S3Uploader) PresignManifest(ctx context.Context, manifest *Manifest, expiry time.Duration) error {
+	return presignManifest(ctx, u.client, u.bucket, manifest, expiry)
+}
+
+// presignURLs presigns GET URLs for objects of bucket through client
+func presignURLs(ctx context.Context, client *s3.Client, bucket string, keys []string, expiry time.Duration) ([]string, error) {
+	presigner := s3.NewPresignClient(client, s3.WithPresignExpires(expiry))
+
+	urls := make([]string, 0, len(keys))
+	for _, key := range keys {
+		request, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign %s: %w", key, err)
+		}
+		urls = append(urls, request.URL)
+	}
+
+	return urls, nil
+}
+
+// presignManifest presigns the objects of a manifest through client
+func presignManifest(ctx context.Context, client *s3.Client, bucket string, manifest *Manifest, expiry time.Duration) error {
+	keys := make([]string, 0, len(manifest.Objects))
+	for _, object := range manifest.Objects {
+		keys = append(keys, object.Key)
+	}
+
+	expires := time.Now().Add(expiry)
+	urls, err := presignURLs(ctx, client, bucket, keys, expiry)
+	if err != nil {
+		return err
+	}
+	for i := range manifest.Objects {
+		manifest.Objects[i].URL = urls[i]
+	}
+	manifest.URLsExpire = expires
+	return nil
+}
+
+// escapeKey escapes each segment of an object key, keeping the separators
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
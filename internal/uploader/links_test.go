@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObjectURL(t *testing.T) {
+	key := "profiles/2024-01-15/my service/20240115-103045-heap.pprof"
+
+	console := ObjectURL(S3Config{Bucket: "my-bucket", Region: "us-west-2"}, key)
+	expected := "https://s3.console.aws.amazon.com/s3/object/my-bucket?prefix=profiles%2F2024-01-15%2Fmy+service%2F20240115-103045-heap.pprof&region=us-west-2"
+	if console != expected {
+		t.Errorf("Expected %s, got %s", expected, console)
+	}
+
+	custom := ObjectURL(S3Config{Bucket: "my-bucket", Endpoint: "http://minio:9000/"}, key)
+	expected = "http://minio:9000/my-bucket/profiles/2024-01-15/my%20service/20240115-103045-heap.pprof"
+	if custom != expected {
+		t.Errorf("Expected %s, got %s", expected, custom)
+	}
+}
+
+func TestPresignURLs(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	urls, err := PresignURLs(context.Background(), S3Config{Bucket: "my-bucket", Region: "us-west-2"},
+		[]string{"profiles/heap.pprof"}, time.Hour)
+	if err != nil {
+		t.Fatalf("PresignURLs returned unexpected error: %v", err)
+	}
+
+	if len(urls) != 1 || !strings.Contains(urls[0], "profiles/heap.pprof") || !strings.Contains(urls[0], "X-Amz-Expires=3600") {
+		t.Errorf("Unexpected presigned URLs: %v", urls)
+	}
+}
+
+func TestPresignManifest(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	manifest := &Manifest{Objects: []ManifestEntry{
+		{Type: "heap", Key: "profiles/heap.pprof"},
+		{Type: "cpu", Key: "profiles/cpu.pprof"},
+	}}
+	before := time.Now()
+	if err := PresignManifest(context.Background(), S3Config{Bucket: "my-bucket", Region: "us-west-2"}, manifest, time.Hour); err != nil {
+		t.Fatalf("PresignManifest returned unexpected error: %v", err)
+	}
+
+	for _, object := range manifest.Objects {
+		if !strings.Contains(object.URL, object.Key) || !strings.Contains(object.URL, "X-Amz-Signature=") {
+			t.Errorf("Unexpected presigned URL of %s: %s", object.Key, object.URL)
+		}
+	}
+	if manifest.URLsExpire.Before(before.Add(time.Hour)) || manifest.URLsExpire.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Expected the URLs to expire in an hour, got %s", manifest.URLsExpire)
+	}
+}
+
+func TestS3Uploader_PresignManifest(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	s3Uploader, err := NewS3Uploader(context.Background(), S3Config{Bucket: "my-bucket", Region: "us-west-2"})
+	if err != nil {
+		t.Fatalf("NewS3Uploader returned unexpected error: %v", err)
+	}
+	manifest := &Manifest{Objects: []ManifestEntry{{Type: "heap", Key: "profiles/heap.pprof"}}}
+	if err := s3Uploader.PresignManifest(context.Background(), manifest, time.Minute); err != nil {
+		t.Fatalf("PresignManifest returned unexpected error: %v", err)
+	}
+
+	if url := manifest.Objects[0].URL; !strings.Contains(url, "my-bucket") || !strings.Contains(url, "X-Amz-Expires=60") {
+		t.Errorf("Unexpected presigned URL %s", url)
+	}
+}
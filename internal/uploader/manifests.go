@@ -0,0 +1,56 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestSuffix ends the names of capture manifests, {timestamp}-manifest.json
+const manifestSuffix = "-manifest.json"
+
+// ListManifests returns the manifests of the captures of a service, or of
+// every service when service is empty, taken within [start, end), oldest first
+func (u *S3Uploader) ListManifests(ctx context.Context, service string, start, end time.Time) ([]*Manifest, error) {
+	var manifests []*Manifest
+	for _, date := range windowDates(start, end) {
+		keys, err := u.listKeys(ctx, path.Join(u.prefixOf("", service), date, service)+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			capturedAt, ok := parseManifestKey(key, start.Location())
+			if !ok || capturedAt.Before(start) || !capturedAt.Before(end) {
+				continue
+			}
+
+			data, err := u.Download(ctx, u.bucket, key)
+			if err != nil {
+				return nil, err
+			}
+			manifest := &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("failed to decode manifest %s: %w", key, err)
+			}
+			manifest.Key = key
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].CapturedAt.Before(manifests[j].CapturedAt)
+	})
+	return manifests, nil
+}
+
+// parseManifestKey returns the capture time of a manifest key, in loc
+func parseManifestKey(key string, loc *time.Location) (time.Time, bool) {
+	if !strings.HasSuffix(key, manifestSuffix) {
+		return time.Time{}, false
+	}
+	return parseObjectTime(key, loc)
+}
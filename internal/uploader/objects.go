@@ -0,0 +1,109 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+)
+
+// SchemaVersion is the version of the metadata and manifests of uploaded
+// objects. It changes when a key is renamed or removed or a value changes
+// meaning; adding keys keeps the version.
+const SchemaVersion = "1"
+
+// Metadata keys set on every uploaded object
+const (
+	// SchemaVersionKey holds the SchemaVersion the object was written with
+	SchemaVersionKey = "bolometer-schema-version"
+
+	// ArtifactKey holds the kind of the object, one of the Artifact constants
+	ArtifactKey = "bolometer-artifact"
+)
+
+// Kinds of uploaded objects
+const (
+	ArtifactProfile       = "profile"
+	ArtifactManifest      = "manifest"
+	ArtifactSummary       = "summary"
+	ArtifactComparison    = "comparison"
+	ArtifactTraceAnalysis = "trace-analysis"
+	ArtifactAggregate     = "aggregate"
+	ArtifactAccessCheck   = "access-check"
+)
+
+// Content types of uploaded objects
+const (
+	// ContentTypePprof is the type of pprof profiles, gzipped protocol buffers
+	// read as is by go tool pprof
+	ContentTypePprof = "application/vnd.google.protobuf; proto=perftools.profiles.Profile"
+
+	// ContentTypeTrace is the type of Go execution traces
+	ContentTypeTrace = "application/octet-stream"
+
+	ContentTypeJSON = "application/json"
+	ContentTypeText = "text/plain; charset=utf-8"
+)
+
+// gzipMagic starts gzipped data, as pprof protocol buffers are
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ProfileContentType returns the content type of a captured profile. Profiles
+// served as text, e.g. goroutine dumps with debug=2, are typed as text.
+func ProfileContentType(profileType string, data []byte) string {
+	switch {
+	case profileType == analysis.TraceProfileType:
+		return ContentTypeTrace
+	case bytes.HasPrefix(data, gzipMagic):
+		return ContentTypePprof
+	case len(data) > 0 && utf8.Valid(data):
+		return ContentTypeText
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// objectMetadata returns the metadata of an object of a kind from fields,
+// with the schema version and kind added and the keys made valid S3
+// metadata keys
+func objectMetadata(artifact string, fields map[string]string) map[string]string {
+	metadata := make(map[string]string, len(fields)+2)
+	for key, value := range fields {
+		metadata[metadataKey(key)] = value
+	}
+	metadata[SchemaVersionKey] = SchemaVersion
+	metadata[ArtifactKey] = artifact
+	return metadata
+}
+
+// metadataKey lowercases a key and replaces the characters S3 metadata keys
+// cannot hold, e.g. the dots and slashes of label keys, with dashes
+func metadataKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, key)
+}
+
+// putObject uploads an object of a kind with its content type and metadata
+func (u *S3Uploader) putObject(ctx context.Context, key, contentType, artifact string, data []byte, fields map[string]string) error {
+	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		Metadata:    objectMetadata(artifact, fields),
+	})
+	return err
+}
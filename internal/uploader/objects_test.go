@@ -0,0 +1,48 @@
+package uploader
+
+import (
+	"testing"
+)
+
+func TestProfileContentType(t *testing.T) {
+	tests := []struct {
+		profileType string
+		data        []byte
+		expected    string
+	}{
+		{"heap", []byte{0x1f, 0x8b, 0x08, 0x00}, ContentTypePprof},
+		{"goroutine", []byte("goroutine 1 [running]:\nmain.main()\n"), ContentTypeText},
+		{"trace", []byte("go 1.22 trace\x00\x00"), ContentTypeTrace},
+		{"custom", []byte{0x00, 0xff, 0xfe}, "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profileType, func(t *testing.T) {
+			if contentType := ProfileContentType(tt.profileType, tt.data); contentType != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, contentType)
+			}
+		})
+	}
+}
+
+func TestObjectMetadata(t *testing.T) {
+	metadata := objectMetadata(ArtifactProfile, map[string]string{
+		"pod-name":                         "my-app-0",
+		"pod-label-app.kubernetes.io/Name": "my-app",
+	})
+
+	expected := map[string]string{
+		"pod-name":                         "my-app-0",
+		"pod-label-app-kubernetes-io-name": "my-app",
+		SchemaVersionKey:                   SchemaVersion,
+		ArtifactKey:                        ArtifactProfile,
+	}
+	if len(metadata) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, metadata)
+	}
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, metadata[key])
+		}
+	}
+}
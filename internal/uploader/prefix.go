@@ -0,0 +1,47 @@
+package uploader
+
+import "strings"
+
+// Placeholders S3 prefixes may hold, rendered when keys are generated
+const (
+	NamespacePlaceholder  = "{namespace}"
+	ServicePlaceholder    = "{service}"
+	ClusterPlaceholder    = "{cluster}"
+	ConfigNamePlaceholder = "{configName}"
+)
+
+// LocalCluster is the {cluster} of pods in the operator's own cluster
+const LocalCluster = "local"
+
+// PrefixValues are the values the placeholders of a prefix render to
+type PrefixValues struct {
+	Namespace  string
+	Service    string
+	Cluster    string
+	ConfigName string
+}
+
+// RenderPrefix replaces the placeholders of prefix with values. Placeholders
+// without a value render empty, dropping their path segment.
+func RenderPrefix(prefix string, values PrefixValues) string {
+	if !strings.Contains(prefix, "{") {
+		return prefix
+	}
+	return strings.NewReplacer(
+		NamespacePlaceholder, values.Namespace,
+		ServicePlaceholder, values.Service,
+		ClusterPlaceholder, values.Cluster,
+		ConfigNamePlaceholder, values.ConfigName,
+	).Replace(prefix)
+}
+
+// prefixOf returns the prefix of the objects of a service. A namespace
+// overrides the one the uploader was configured with.
+func (u *S3Uploader) prefixOf(namespace, service string) string {
+	values := u.prefixValues
+	if namespace != "" {
+		values.Namespace = namespace
+	}
+	values.Service = service
+	return RenderPrefix(u.prefix, values)
+}
@@ -0,0 +1,58 @@
+package uploader
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+func TestRenderPrefix(t *testing.T) {
+	values := PrefixValues{Namespace: "team-a", Service: "api", Cluster: "eu-west", ConfigName: "default"}
+
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"profiles", "profiles"},
+		{"tenants/{namespace}/{service}", "tenants/team-a/api"},
+		{"{cluster}/{configName}-profiles", "eu-west/default-profiles"},
+		{"{namespace}/{namespace}", "team-a/team-a"},
+		{"{unknown}/profiles", "{unknown}/profiles"},
+	}
+	for _, tt := range tests {
+		if got := RenderPrefix(tt.prefix, values); got != tt.expected {
+			t.Errorf("RenderPrefix(%q) = %q, expected %q", tt.prefix, got, tt.expected)
+		}
+	}
+}
+
+func TestGenerateKey_PrefixPlaceholders(t *testing.T) {
+	uploader := &S3Uploader{
+		bucket:       "test-bucket",
+		prefix:       "{cluster}/{namespace}/{configName}",
+		prefixValues: PrefixValues{Namespace: "default", Cluster: "local", ConfigName: "api-profiling"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api-abc",
+			Namespace: "team-a",
+			Labels:    map[string]string{"app": "api"},
+		},
+	}
+	timestamp := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+
+	// Captures render the namespace of their pod
+	key := uploader.generateKey(pod, profiler.Profile{Type: "heap", Timestamp: timestamp})
+	if expected := "local/team-a/api-profiling/2024-01-15/api/20240115-123045-heap.pprof"; key != expected {
+		t.Errorf("Expected key %q, got %q", expected, key)
+	}
+
+	// Objects of services render the configured namespace
+	if prefix := uploader.prefixOf("", "api"); prefix != "local/default/api-profiling" {
+		t.Errorf("Unexpected service prefix %q", prefix)
+	}
+}
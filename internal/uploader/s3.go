@@ -1,25 +1,49 @@
 package uploader
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
+	"github.com/a-kash-singh/bolometer/internal/analysis"
+	"github.com/a-kash-singh/bolometer/internal/metrics"
 	"github.com/a-kash-singh/bolometer/internal/profiler"
 )
 
+// Layouts of the date directories and timestamps of object keys
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "20060102-150405"
+)
+
 // S3Uploader uploads profiles to S3
 type S3Uploader struct {
-	client *s3.Client
-	bucket string
-	prefix string
+	client       *s3.Client
+	bucket       string
+	prefix       string
+	prefixValues PrefixValues
+	baseline     *Baseline
+
+	gallery           bool
+	galleryLinkExpiry time.Duration
 }
 
 // S3Config holds S3 configuration
@@ -28,37 +52,265 @@ type S3Config struct {
 	Prefix   string
 	Region   string
 	Endpoint string
+
+	// CABundle holds PEM certificates trusted for the endpoint next to the
+	// system roots
+	CABundle []byte
+
+	// ProxyURL is the HTTP proxy requests go through, the proxy of the
+	// environment if empty
+	ProxyURL string
+
+	// Accelerate uses the Transfer Acceleration endpoint of the bucket, and
+	// DualStack the dual-stack endpoint of the region
+	Accelerate bool
+	DualStack  bool
+
+	// ForcePathStyle addresses the bucket in the request path if set, by
+	// default only with a custom Endpoint
+	ForcePathStyle *bool
+
+	// RetryMode is the SDK's retry mode, standard or adaptive, case
+	// insensitively; MaxAttempts the attempts of each request. The SDK's
+	// defaults apply if unset.
+	RetryMode   string
+	MaxAttempts int
+
+	// PrefixValues fill the placeholders of Prefix. Namespace and Service are
+	// taken from the pod of each capture; Namespace is used for the objects of
+	// services, e.g. aggregated profiles.
+	PrefixValues PrefixValues
+
+	// Baseline, if set, is compared against every uploaded capture
+	Baseline *Baseline
+
+	// Credentials sign the requests instead of the credentials of the
+	// operator's environment, if set
+	Credentials aws.CredentialsProvider
+
+	// Gallery keeps a gallery of the captures of each service and day, with
+	// a flamegraph page of each profile. Its links are presigned, valid for
+	// GalleryLinkExpiry, if set.
+	Gallery           bool
+	GalleryLinkExpiry time.Duration
+}
+
+// Baseline references known-good profiles captures are compared against
+type Baseline struct {
+	// Bucket holds the baseline profiles
+	Bucket string
+
+	// Keys are the S3 keys of the baseline profiles by profile type
+	Keys map[string]string
+
+	// ThresholdPercent is the growth over the baseline above which a capture
+	// counts as regressed
+	ThresholdPercent float64
 }
 
 // NewS3Uploader creates a new S3 uploader
 func NewS3Uploader(ctx context.Context, cfg S3Config) (*S3Uploader, error) {
-	// Load AWS config from environment (uses IRSA/IAM roles automatically)
-	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	client, err := NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Uploader{
+		client:            client,
+		bucket:            cfg.Bucket,
+		prefix:            cfg.Prefix,
+		prefixValues:      cfg.PrefixValues,
+		baseline:          cfg.Baseline,
+		gallery:           cfg.Gallery,
+		galleryLinkExpiry: cfg.GalleryLinkExpiry,
+	}, nil
+}
+
+// NewS3Client creates an S3 client for the region, endpoint and tuning of cfg
+func NewS3Client(ctx context.Context, cfg S3Config) (*s3.Client, error) {
+	awsCfg, err := loadAWSConfig(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, err
 	}
 
-	// Create S3 client
-	var client *s3.Client
-	if cfg.Endpoint != "" {
-		// Custom endpoint for S3-compatible services
-		client = s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			// Custom endpoint for S3-compatible services
 			o.BaseEndpoint = aws.String(cfg.Endpoint)
 			o.UsePathStyle = true
+		}
+		if cfg.ForcePathStyle != nil {
+			o.UsePathStyle = *cfg.ForcePathStyle
+		}
+		o.UseAccelerate = cfg.Accelerate
+	}), nil
+}
+
+// loadAWSConfig loads the AWS config of the clients of cfg: its region,
+// transport, retries and credentials
+func loadAWSConfig(ctx context.Context, cfg S3Config) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{config.WithRegion(cfg.Region)}
+	transportOpts, err := transportOptions(cfg)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if len(transportOpts) > 0 {
+		opts = append(opts, config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(transportOpts...)))
+	}
+	if cfg.RetryMode != "" {
+		opts = append(opts, config.WithRetryMode(aws.RetryMode(strings.ToLower(cfg.RetryMode))))
+	}
+	if cfg.MaxAttempts > 0 {
+		opts = append(opts, config.WithRetryMaxAttempts(cfg.MaxAttempts))
+	}
+	if cfg.DualStack {
+		opts = append(opts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	if cfg.Credentials != nil {
+		opts = append(opts, config.WithCredentialsProvider(cfg.Credentials))
+	}
+
+	// Load AWS config from environment (uses IRSA/IAM roles automatically)
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return awsCfg, nil
+}
+
+// transportOptions returns the changes to the SDK's HTTP transport the proxy
+// and CA bundle of cfg need, none if it sets neither
+func transportOptions(cfg S3Config) ([]func(*http.Transport), error) {
+	var opts []func(*http.Transport)
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", cfg.ProxyURL, err)
+		}
+		opts = append(opts, func(transport *http.Transport) {
+			transport.Proxy = http.ProxyURL(proxy)
 		})
-	} else {
-		client = s3.NewFromConfig(awsCfg)
 	}
 
-	return &S3Uploader{
-		client: client,
-		bucket: cfg.Bucket,
-		prefix: cfg.Prefix,
-	}, nil
+	if len(cfg.CABundle) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(cfg.CABundle) {
+			return nil, fmt.Errorf("ca bundle holds no PEM certificates")
+		}
+		opts = append(opts, func(transport *http.Transport) {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			transport.TLSClientConfig.RootCAs = roots
+		})
+	}
+	return opts, nil
 }
 
-// UploadProfile uploads a single profile to S3
-func (u *S3Uploader) UploadProfile(ctx context.Context, pod *corev1.Pod, profile profiler.Profile, reason string) error {
+// Manifest describes a capture and the objects uploaded for it
+type Manifest struct {
+	// SchemaVersion is the SchemaVersion the manifest was written with
+	SchemaVersion string `json:"schemaVersion"`
+
+	PodName      string          `json:"podName"`
+	PodNamespace string          `json:"podNamespace"`
+	NodeName     string          `json:"nodeName,omitempty"`
+	Service      string          `json:"service"`
+	Reason       string          `json:"reason"`
+	CapturedAt   time.Time       `json:"capturedAt"`
+	Trigger      *TriggerValues  `json:"trigger,omitempty"`
+	Bucket       string          `json:"bucket"`
+	Objects      []ManifestEntry `json:"objects"`
+
+	// History holds the pod's usage samples leading up to the capture
+	History []*metrics.PodMetrics `json:"history,omitempty"`
+
+	// Summary is the S3 key of the JSON report of the top functions of each
+	// profile; a text rendering is uploaded next to it
+	Summary string `json:"summary,omitempty"`
+
+	// TopFunctions holds the top functions by flat value of each profile
+	TopFunctions []analysis.Summary `json:"topFunctions,omitempty"`
+
+	// Comparison is the S3 key of the report comparing the profiles to the
+	// baseline, RegressionScore the largest growth in percent of a profile
+	// over its baseline and Regressed whether it exceeds the threshold
+	Comparison      string   `json:"comparison,omitempty"`
+	RegressionScore *float64 `json:"regressionScore,omitempty"`
+	Regressed       bool     `json:"regressed,omitempty"`
+
+	// TraceAnalysis is the S3 key of the analysis of the execution trace, and
+	// Trace its summary
+	TraceAnalysis string                 `json:"traceAnalysis,omitempty"`
+	Trace         *analysis.TraceSummary `json:"trace,omitempty"`
+
+	// Gallery is the S3 key of the gallery page listing the captures of the
+	// service on the day of the capture
+	Gallery string `json:"gallery,omitempty"`
+
+	// Key is the S3 key of the manifest itself
+	Key string `json:"-"`
+
+	// URLsExpire is when the presigned URLs of the objects stop working, zero
+	// if they were not presigned
+	URLsExpire time.Time `json:"-"`
+}
+
+// ManifestEntry describes a single uploaded profile
+type ManifestEntry struct {
+	Type      string    `json:"type"`
+	Key       string    `json:"key"`
+	SizeBytes int       `json:"sizeBytes"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// ContentType is the content type the profile was stored with
+	ContentType string `json:"contentType,omitempty"`
+
+	// Flamegraph is the S3 key of the flamegraph page of the profile, stored
+	// with the gallery
+	Flamegraph string `json:"flamegraph,omitempty"`
+
+	// Deduplicated is set when the profile was identical to the one captured
+	// before it, which Key refers to, and was not stored again
+	Deduplicated bool `json:"deduplicated,omitempty"`
+
+	// URL is a presigned download URL of the profile, if presigned. Being a
+	// credential, it is never stored in the manifest.
+	URL string `json:"-"`
+}
+
+// TriggerValues holds the metric values and thresholds that caused a capture
+type TriggerValues struct {
+	CPUUsagePercent        float64 `json:"cpuUsagePercent"`
+	MemoryUsagePercent     float64 `json:"memoryUsagePercent"`
+	CPUUsage               string  `json:"cpuUsage"`
+	MemoryUsage            string  `json:"memoryUsage"`
+	CPUThresholdPercent    int     `json:"cpuThresholdPercent"`
+	MemoryThresholdPercent int     `json:"memoryThresholdPercent"`
+}
+
+// NewTriggerValues converts a trigger into manifest values, nil if the trigger
+// carries no metrics
+func NewTriggerValues(trigger metrics.Trigger) *TriggerValues {
+	if trigger.Metrics == nil {
+		return nil
+	}
+
+	return &TriggerValues{
+		CPUUsagePercent:        trigger.Metrics.CPUUsagePercent,
+		MemoryUsagePercent:     trigger.Metrics.MemoryUsagePercent,
+		CPUUsage:               trigger.Metrics.CPUUsage.String(),
+		MemoryUsage:            trigger.Metrics.MemoryUsage.String(),
+		CPUThresholdPercent:    trigger.CPUThresholdPercent,
+		MemoryThresholdPercent: trigger.MemoryThresholdPercent,
+	}
+}
+
+// UploadProfile uploads a single profile to S3 and returns its key
+func (u *S3Uploader) UploadProfile(ctx context.Context, pod *corev1.Pod, profile profiler.Profile, trigger metrics.Trigger) (string, error) {
 	key := u.generateKey(pod, profile)
 
 	// Prepare metadata
@@ -66,58 +318,363 @@ func (u *S3Uploader) UploadProfile(ctx context.Context, pod *corev1.Pod, profile
 		"pod-name":      pod.Name,
 		"pod-namespace": pod.Namespace,
 		"profile-type":  profile.Type,
-		"reason":        reason,
+		"reason":        trigger.Reason,
 		"timestamp":     profile.Timestamp.Format(time.RFC3339),
 	}
 
-	// Add pod labels as metadata
+	// Add the metric values that triggered the capture
+	for k, v := range triggerMetadata(trigger) {
+		metadata[k] = v
+	}
+
+	// Add pod labels as metadata, their keys made valid metadata keys
 	for k, v := range pod.Labels {
-		// S3 metadata keys must be lowercase and cannot contain special chars
-		safeKey := fmt.Sprintf("pod-label-%s", k)
-		metadata[safeKey] = v
+		metadata["pod-label-"+k] = v
 	}
 
 	// Upload to S3
-	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(u.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(profile.Data),
-		ContentType: aws.String("application/octet-stream"),
-		Metadata:    metadata,
-	})
+	err := u.putObject(ctx, key, ProfileContentType(profile.Type, profile.Data), ArtifactProfile, profile.Data, metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to S3: %w", err)
+	}
+
+	return key, nil
+}
+
+// UploadProfiles uploads multiple profiles to S3 followed by a JSON manifest
+// describing the capture
+func (u *S3Uploader) UploadProfiles(ctx context.Context, pod *corev1.Pod, profiles []profiler.Profile, trigger metrics.Trigger) (*Manifest, error) {
+	manifest := u.newManifest(pod, profiles, trigger)
+
+	for _, profile := range profiles {
+		if profile.DuplicateOf != "" {
+			manifest.Objects = append(manifest.Objects, profileEntry(profile, profile.DuplicateOf))
+			continue
+		}
+		key, err := u.UploadProfile(ctx, pod, profile, trigger)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Objects = append(manifest.Objects, profileEntry(profile, key))
+	}
+
+	if err := u.uploadSummary(ctx, pod, manifest, profiles); err != nil {
+		return nil, err
+	}
+
+	if u.baseline != nil {
+		if err := u.uploadComparison(ctx, pod, manifest, profiles); err != nil {
+			return nil, err
+		}
+	}
 
+	if err := u.uploadTraceAnalysis(ctx, pod, manifest, profiles); err != nil {
+		return nil, err
+	}
+
+	if u.gallery {
+		if err := u.uploadFlamegraphs(ctx, manifest, profiles); err != nil {
+			return nil, err
+		}
+		manifest.Gallery = path.Join(path.Dir(manifest.Key), GalleryPage)
+	}
+
+	if err := u.uploadManifest(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	if u.gallery {
+		if err := u.updateGallery(ctx, manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	return manifest, nil
+}
+
+// profileEntry returns the manifest entry of a profile stored at key, which
+// for a duplicate is the key of the identical profile stored earlier
+func profileEntry(profile profiler.Profile, key string) ManifestEntry {
+	return ManifestEntry{
+		Type:         profile.Type,
+		Key:          key,
+		SizeBytes:    len(profile.Data),
+		Timestamp:    profile.Timestamp,
+		ContentType:  ProfileContentType(profile.Type, profile.Data),
+		Deduplicated: profile.DuplicateOf != "",
+	}
+}
+
+// newManifest creates the manifest for a capture before its profiles are uploaded
+func (u *S3Uploader) newManifest(pod *corev1.Pod, profiles []profiler.Profile, trigger metrics.Trigger) *Manifest {
+	capturedAt := time.Now()
+	if len(profiles) > 0 {
+		capturedAt = profiles[0].Timestamp
+	}
+
+	return &Manifest{
+		SchemaVersion: SchemaVersion,
+		PodName:       pod.Name,
+		PodNamespace:  pod.Namespace,
+		NodeName:      pod.Spec.NodeName,
+		Service:       u.getServiceName(pod),
+		Reason:        trigger.Reason,
+		CapturedAt:    capturedAt,
+		Trigger:       NewTriggerValues(trigger),
+		History:       trigger.History,
+		Bucket:        u.bucket,
+		Key:           u.generateObjectKey(pod, capturedAt, "manifest", ".json"),
+	}
+}
+
+// uploadSummary uploads a report of the top functions of each profile as JSON
+// and text next to the profiles, recording the highlights in the manifest.
+// Profiles that cannot be parsed are left out of the report.
+func (u *S3Uploader) uploadSummary(ctx context.Context, pod *corev1.Pod, manifest *Manifest, profiles []profiler.Profile) error {
+	var report analysis.Report
+	for _, profile := range profiles {
+		summary, err := analysis.Summarize(profile.Type, profile.Data, analysis.DefaultTopN)
+		if err != nil {
+			continue
+		}
+		report.Profiles = append(report.Profiles, summary)
+		manifest.TopFunctions = append(manifest.TopFunctions, summary.Highlights(analysis.HighlightN))
+	}
+	if len(report.Profiles) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to upload to S3: %w", err)
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+
+	key := u.generateObjectKey(pod, manifest.CapturedAt, "summary", ".json")
+	objects := []struct {
+		key, contentType string
+		data             []byte
+	}{
+		{key, ContentTypeJSON, data},
+		{strings.TrimSuffix(key, ".json") + ".txt", ContentTypeText, []byte(report.Text())},
 	}
+	for _, object := range objects {
+		err := u.putObject(ctx, object.key, object.contentType, ArtifactSummary, object.data, captureMetadata(manifest))
+		if err != nil {
+			return fmt.Errorf("failed to upload summary to S3: %w", err)
+		}
+	}
+
+	manifest.Summary = key
+	return nil
+}
+
+// uploadTraceAnalysis analyzes the execution trace of a capture, if any, and
+// uploads the summary as JSON next to it. A trace that cannot be parsed, e.g.
+// one of a Go version the parser does not know, is left unanalyzed.
+func (u *S3Uploader) uploadTraceAnalysis(ctx context.Context, pod *corev1.Pod, manifest *Manifest, profiles []profiler.Profile) error {
+	for _, profile := range profiles {
+		if profile.Type != analysis.TraceProfileType {
+			continue
+		}
+
+		summary, err := analysis.AnalyzeTrace(profile.Data)
+		if errors.Is(err, analysis.ErrUnsupportedTraceVersion) {
+			log.FromContext(ctx).Info("Uploading trace without analysis", "pod", pod.Name, "reason", err.Error())
+			return nil
+		}
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to analyze trace, uploading it without analysis", "pod", pod.Name)
+			return nil
+		}
+
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode trace analysis: %w", err)
+		}
+
+		key := u.generateObjectKey(pod, manifest.CapturedAt, "trace-analysis", ".json")
+		err = u.putObject(ctx, key, ContentTypeJSON, ArtifactTraceAnalysis, data, captureMetadata(manifest))
+		if err != nil {
+			return fmt.Errorf("failed to upload trace analysis to S3: %w", err)
+		}
 
+		manifest.TraceAnalysis = key
+		manifest.Trace = &summary
+		return nil
+	}
 	return nil
 }
 
-// UploadProfiles uploads multiple profiles to S3
-func (u *S3Uploader) UploadProfiles(ctx context.Context, pod *corev1.Pod, profiles []profiler.Profile, reason string) error {
+// uploadComparison compares the profiles to their baselines and uploads the
+// report as JSON next to the profiles, recording the score in the manifest.
+// Baselines that cannot be downloaded or compared are listed in the report.
+func (u *S3Uploader) uploadComparison(ctx context.Context, pod *corev1.Pod, manifest *Manifest, profiles []profiler.Profile) error {
+	report := analysis.ComparisonReport{
+		Bucket:           u.baseline.Bucket,
+		ThresholdPercent: u.baseline.ThresholdPercent,
+	}
 	for _, profile := range profiles {
-		if err := u.UploadProfile(ctx, pod, profile, reason); err != nil {
-			return err
+		key, ok := u.baseline.Keys[profile.Type]
+		if !ok {
+			continue
+		}
+
+		baseline, err := u.Download(ctx, u.baseline.Bucket, key)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		comparison, err := analysis.Compare(profile.Type, baseline, profile.Data, analysis.DefaultTopN)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
 		}
+		comparison.Baseline = key
+		report.Add(comparison)
+	}
+	if len(report.Profiles) == 0 && len(report.Errors) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode comparison: %w", err)
+	}
+
+	key := u.generateObjectKey(pod, manifest.CapturedAt, "comparison", ".json")
+	err = u.putObject(ctx, key, ContentTypeJSON, ArtifactComparison, data, captureMetadata(manifest))
+	if err != nil {
+		return fmt.Errorf("failed to upload comparison to S3: %w", err)
 	}
+
+	manifest.Comparison = key
+	if len(report.Profiles) > 0 {
+		manifest.RegressionScore = aws.Float64(report.Score)
+		manifest.Regressed = report.Regressed
+	}
+	return nil
+}
+
+// Download returns the content of an object
+func (u *S3Uploader) Download(ctx context.Context, bucket, key string) ([]byte, error) {
+	output, err := u.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	return data, nil
+}
+
+// CheckBucket verifies the bucket exists and the credentials can access it
+func (u *S3Uploader) CheckBucket(ctx context.Context) error {
+	if _, err := u.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(u.bucket)}); err != nil {
+		return fmt.Errorf("failed to access bucket %s: %w", u.bucket, err)
+	}
+	return nil
+}
+
+// CheckAccess implements AccessChecker. The write check uploads and deletes a
+// small object under the prefix.
+func (u *S3Uploader) CheckAccess(ctx context.Context, write bool) error {
+	if err := u.CheckBucket(ctx); err != nil || !write {
+		return err
+	}
+
+	key := u.accessCheckKey()
+	err := u.putObject(ctx, key, ContentTypeText, ArtifactAccessCheck, []byte("bolometer access check"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", u.bucket, key, err)
+	}
+	if _, err := u.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(key),
+	}); err != nil {
+		return fmt.Errorf("failed to delete s3://%s/%s: %w", u.bucket, key, err)
+	}
+	return nil
+}
+
+// accessCheckKey returns the key of the object uploaded by the write check,
+// under the prefix of the config's objects
+func (u *S3Uploader) accessCheckKey() string {
+	return filepath.Join(u.prefixOf(u.prefixValues.Namespace, ""), ".bolometer-access-check")
+}
+
+// uploadManifest uploads the manifest as JSON next to the profiles
+func (u *S3Uploader) uploadManifest(ctx context.Context, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	err = u.putObject(ctx, manifest.Key, ContentTypeJSON, ArtifactManifest, data, captureMetadata(manifest))
+	if err != nil {
+		return fmt.Errorf("failed to upload manifest to S3: %w", err)
+	}
+
 	return nil
 }
 
+// captureMetadata returns the metadata of the objects describing a capture
+// besides its profiles
+func captureMetadata(manifest *Manifest) map[string]string {
+	return map[string]string{
+		"pod-name":      manifest.PodName,
+		"pod-namespace": manifest.PodNamespace,
+		"reason":        manifest.Reason,
+		"timestamp":     manifest.CapturedAt.Format(time.RFC3339),
+	}
+}
+
+// triggerMetadata returns S3 metadata for the metric values that caused a capture
+func triggerMetadata(trigger metrics.Trigger) map[string]string {
+	values := NewTriggerValues(trigger)
+	if values == nil {
+		return nil
+	}
+
+	return map[string]string{
+		"cpu-usage-percent":        strconv.FormatFloat(values.CPUUsagePercent, 'f', 2, 64),
+		"memory-usage-percent":     strconv.FormatFloat(values.MemoryUsagePercent, 'f', 2, 64),
+		"cpu-usage":                values.CPUUsage,
+		"memory-usage":             values.MemoryUsage,
+		"cpu-threshold-percent":    strconv.Itoa(values.CPUThresholdPercent),
+		"memory-threshold-percent": strconv.Itoa(values.MemoryThresholdPercent),
+	}
+}
+
 // generateKey generates the S3 key for a profile
 func (u *S3Uploader) generateKey(pod *corev1.Pod, profile profiler.Profile) string {
-	// Format: {prefix}/{date}/{service-name}/{timestamp}-{profile-type}.pprof
-	// Date format: YYYY-MM-DD
-	date := profile.Timestamp.Format("2006-01-02")
+	return u.generateObjectKey(pod, profile.Timestamp, profile.Type, ".pprof")
+}
 
+// generateObjectKey generates the S3 key for an object belonging to a capture
+func (u *S3Uploader) generateObjectKey(pod *corev1.Pod, capturedAt time.Time, name, extension string) string {
 	// Extract service name from pod labels (app, app.kubernetes.io/name, or fallback to pod name prefix)
 	serviceName := u.getServiceName(pod)
+	return u.serviceObjectKey(u.prefixOf(pod.Namespace, serviceName), serviceName, capturedAt, name, extension)
+}
+
+// serviceObjectKey generates the S3 key for an object of a service under prefix
+func (u *S3Uploader) serviceObjectKey(prefix, serviceName string, capturedAt time.Time, name, extension string) string {
+	// Format: {prefix}/{date}/{service-name}/{timestamp}-{name}{extension}
+	// Date format: YYYY-MM-DD
+	date := capturedAt.Format(dateLayout)
 
 	// Timestamp for uniqueness
-	timestamp := profile.Timestamp.Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s.pprof", timestamp, profile.Type)
+	timestamp := capturedAt.Format(timestampLayout)
+	filename := fmt.Sprintf("%s-%s%s", timestamp, name, extension)
 
 	parts := []string{
-		u.prefix,
+		prefix,
 		date,
 		serviceName,
 		filename,
@@ -128,6 +685,11 @@ func (u *S3Uploader) generateKey(pod *corev1.Pod, profile profiler.Profile) stri
 
 // getServiceName extracts the service name from pod labels or metadata
 func (u *S3Uploader) getServiceName(pod *corev1.Pod) string {
+	return ServiceName(pod)
+}
+
+// ServiceName extracts the service name of a pod from its labels, owner or name
+func ServiceName(pod *corev1.Pod) string {
 	// Try common label keys for service name
 	if pod.Labels != nil {
 		// Check app.kubernetes.io/name (recommended label)
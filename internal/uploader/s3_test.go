@@ -1,9 +1,15 @@
 package uploader
 
 import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -217,6 +223,120 @@ func TestGenerateKeyDifferentDates(t *testing.T) {
 	}
 }
 
+func TestAccessCheckKey(t *testing.T) {
+	uploader := &S3Uploader{
+		bucket:       "test-bucket",
+		prefix:       "profiles/{namespace}/{service}",
+		prefixValues: PrefixValues{Namespace: "team-a"},
+	}
+
+	if key := uploader.accessCheckKey(); key != "profiles/team-a/.bolometer-access-check" {
+		t.Errorf("Expected the check object under the config's prefix, got %q", key)
+	}
+}
+
+func TestProfileEntry(t *testing.T) {
+	profile := profiler.Profile{Type: "heap", Data: []byte("heap profile"), Timestamp: time.Now()}
+	entry := profileEntry(profile, "profiles/heap.pb.gz")
+	if entry.Key != "profiles/heap.pb.gz" || entry.SizeBytes != len("heap profile") || entry.Deduplicated {
+		t.Errorf("Unexpected entry of a stored profile: %+v", entry)
+	}
+
+	profile.DuplicateOf = "profiles/earlier-heap.pb.gz"
+	entry = profileEntry(profile, profile.DuplicateOf)
+	if entry.Key != "profiles/earlier-heap.pb.gz" || !entry.Deduplicated {
+		t.Errorf("Expected the entry of a duplicate to refer to the earlier profile, got %+v", entry)
+	}
+
+	manifest := &Manifest{Objects: []ManifestEntry{
+		{Type: "heap", SizeBytes: 100, Deduplicated: true},
+		{Type: "goroutine", SizeBytes: 50},
+	}}
+	if size := ManifestBytes(manifest); size != 50 {
+		t.Errorf("Expected duplicates to store no bytes, got %d", size)
+	}
+}
+
+func TestNewS3Client_Tuning(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := NewS3Client(ctx, S3Config{Region: "us-east-1"})
+	if err != nil {
+		t.Fatalf("NewS3Client returned unexpected error: %v", err)
+	}
+	if options := client.Options(); options.UsePathStyle || options.UseAccelerate {
+		t.Errorf("Expected virtual-hosted addressing without acceleration on AWS, got %+v", options)
+	}
+
+	client, err = NewS3Client(ctx, S3Config{Region: "us-east-1", Endpoint: "https://minio.internal:9000"})
+	if err != nil {
+		t.Fatalf("NewS3Client returned unexpected error: %v", err)
+	}
+	if !client.Options().UsePathStyle {
+		t.Error("Expected path-style addressing with a custom endpoint")
+	}
+
+	virtualHosted := false
+	client, err = NewS3Client(ctx, S3Config{
+		Region:         "us-east-1",
+		Endpoint:       "https://s3.provider.example",
+		ForcePathStyle: &virtualHosted,
+		Accelerate:     true,
+		DualStack:      true,
+		RetryMode:      "Adaptive",
+		MaxAttempts:    5,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Client returned unexpected error: %v", err)
+	}
+	options := client.Options()
+	if options.UsePathStyle || !options.UseAccelerate {
+		t.Errorf("Expected forced virtual-hosted addressing with acceleration, got path style %v, accelerate %v",
+			options.UsePathStyle, options.UseAccelerate)
+	}
+	if options.EndpointOptions.UseDualStackEndpoint != aws.DualStackEndpointStateEnabled {
+		t.Error("Expected dual-stack endpoints")
+	}
+	if options.RetryMode != aws.RetryModeAdaptive || options.RetryMaxAttempts != 5 {
+		t.Errorf("Expected 5 adaptive attempts, got %d %s", options.RetryMaxAttempts, options.RetryMode)
+	}
+}
+
+func TestTransportOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	opts, err := transportOptions(S3Config{CABundle: caBundle, ProxyURL: "http://proxy.internal:3128"})
+	if err != nil {
+		t.Fatalf("transportOptions returned unexpected error: %v", err)
+	}
+	transport := &http.Transport{}
+	for _, opt := range opts {
+		opt(transport)
+	}
+
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, server.URL, nil))
+	if err != nil || proxy == nil || proxy.Host != "proxy.internal:3128" {
+		t.Errorf("Expected requests to go through the proxy, got %v, %v", proxy, err)
+	}
+
+	// The endpoint's certificate is trusted without the proxy
+	transport.Proxy = nil
+	response, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the CA bundle to be trusted: %v", err)
+	}
+	response.Body.Close()
+
+	if _, err := transportOptions(S3Config{CABundle: []byte("not a certificate")}); err == nil {
+		t.Error("Expected an error for a CA bundle without certificates")
+	}
+	if opts, err := transportOptions(S3Config{}); err != nil || len(opts) != 0 {
+		t.Errorf("Expected no transport options, got %d, %v", len(opts), err)
+	}
+}
+
 // Helper function to check if string contains all substrings
 func containsAll(s string, substrs ...string) bool {
 	for _, substr := range substrs {
@@ -0,0 +1,75 @@
+package uploader
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/a-kash-singh/bolometer/internal/metrics"
+	"github.com/a-kash-singh/bolometer/internal/profiler"
+)
+
+// Uploader stores the profiles of captures. S3Uploader stores them in S3 or an
+// S3-compatible service; other implementations may write them to another
+// object store, a profiling backend, or memory in tests.
+type Uploader interface {
+	// UploadProfiles stores the profiles of a capture of pod and returns the
+	// manifest describing the stored objects
+	UploadProfiles(ctx context.Context, pod *corev1.Pod, profiles []profiler.Profile, trigger metrics.Trigger) (*Manifest, error)
+}
+
+// Presigner is an uploader that can hand out temporary download URLs of the
+// objects it stored
+type Presigner interface {
+	// PresignManifest sets download URLs of the objects of a manifest, valid
+	// for expiry
+	PresignManifest(ctx context.Context, manifest *Manifest, expiry time.Duration) error
+}
+
+// AccessChecker is an uploader that can verify its destination accepts
+// profiles before the first capture
+type AccessChecker interface {
+	// CheckAccess verifies the destination can be accessed, and if write is
+	// set that objects can be stored and deleted in it
+	CheckAccess(ctx context.Context, write bool) error
+}
+
+// LifecycleManager is an uploader that can maintain a lifecycle rule for the
+// objects of a config at its destination
+type LifecycleManager interface {
+	// PutLifecycleRule creates or replaces the rule with the ID of rule,
+	// keeping the other rules of the destination
+	PutLifecycleRule(ctx context.Context, rule LifecycleRule) error
+
+	// DeleteLifecycleRule removes the rule with an ID, if there is one
+	DeleteLifecycleRule(ctx context.Context, id string) error
+}
+
+// StorageMeter is an uploader that can measure the storage used at its
+// destination
+type StorageMeter interface {
+	// MeasurePrefix returns the bytes of the objects under prefix
+	MeasurePrefix(ctx context.Context, prefix string) (int64, error)
+}
+
+// Factory creates the uploader storing profiles at the destination of cfg
+type Factory func(ctx context.Context, cfg S3Config) (Uploader, error)
+
+var (
+	_ Uploader         = (*S3Uploader)(nil)
+	_ Presigner        = (*S3Uploader)(nil)
+	_ AccessChecker    = (*S3Uploader)(nil)
+	_ LifecycleManager = (*S3Uploader)(nil)
+	_ StorageMeter     = (*S3Uploader)(nil)
+	_ Factory          = NewUploader
+)
+
+// NewUploader is the default Factory, creating an S3Uploader
+func NewUploader(ctx context.Context, cfg S3Config) (Uploader, error) {
+	s3Uploader, err := NewS3Uploader(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s3Uploader, nil
+}
@@ -0,0 +1,40 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MeasurePrefix implements StorageMeter by listing the objects under prefix
+func (u *S3Uploader) MeasurePrefix(ctx context.Context, prefix string) (int64, error) {
+	var size int64
+	paginator := s3.NewListObjectsV2Paginator(u.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(u.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list s3://%s/%s: %w", u.bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			size += aws.ToInt64(object.Size)
+		}
+	}
+	return size, nil
+}
+
+// ManifestBytes returns the bytes of the profiles a manifest describes,
+// without the duplicates that were not stored again
+func ManifestBytes(manifest *Manifest) int64 {
+	var size int64
+	for _, object := range manifest.Objects {
+		if !object.Deduplicated {
+			size += int64(object.SizeBytes)
+		}
+	}
+	return size
+}
@@ -0,0 +1,49 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// identityTokenTimeout bounds the request of a web identity token
+const identityTokenTimeout = 10 * time.Second
+
+// IdentityTokenFunc returns a web identity token, such as a service account
+// token issued for the STS audience
+type IdentityTokenFunc func(ctx context.Context) ([]byte, error)
+
+// GetIdentityToken implements stscreds.IdentityTokenRetriever
+func (f IdentityTokenFunc) GetIdentityToken() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), identityTokenTimeout)
+	defer cancel()
+	return f(ctx)
+}
+
+// WebIdentityCredentials returns credentials of roleARN assumed with the web
+// identity tokens of token, through STS in the region of cfg. They are cached
+// and refreshed before they expire.
+func WebIdentityCredentials(ctx context.Context, cfg S3Config, roleARN, sessionName string, token IdentityTokenFunc) (aws.CredentialsProvider, error) {
+	if roleARN == "" {
+		return nil, fmt.Errorf("no role to assume")
+	}
+
+	// STS is reached without the endpoint settings of the bucket, and must
+	// not be signed with the credentials it issues
+	cfg.Endpoint, cfg.DualStack = "", false
+	cfg.Credentials = aws.AnonymousCredentials{}
+	awsCfg, err := loadAWSConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(awsCfg), roleARN, token,
+		func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = sessionName
+		})
+	return aws.NewCredentialsCache(provider), nil
+}
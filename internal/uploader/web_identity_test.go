@@ -0,0 +1,31 @@
+package uploader
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIdentityTokenFunc(t *testing.T) {
+	token := IdentityTokenFunc(func(ctx context.Context) ([]byte, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected the token request to be bounded")
+		}
+		return []byte("jwt"), nil
+	})
+	data, err := token.GetIdentityToken()
+	if err != nil || string(data) != "jwt" {
+		t.Errorf("Expected the token, got %q (%v)", data, err)
+	}
+}
+
+func TestWebIdentityCredentials(t *testing.T) {
+	token := IdentityTokenFunc(func(context.Context) ([]byte, error) { return []byte("jwt"), nil })
+	cfg := S3Config{Region: "us-west-2", Endpoint: "https://minio.storage.internal:9000"}
+	if _, err := WebIdentityCredentials(context.Background(), cfg, "", "bolometer", token); err == nil {
+		t.Error("Expected an error without a role")
+	}
+	provider, err := WebIdentityCredentials(context.Background(), cfg, "arn:aws:iam::111122223333:role/profiler", "bolometer", token)
+	if err != nil || provider == nil {
+		t.Errorf("Expected the credentials of the role, got %v", err)
+	}
+}
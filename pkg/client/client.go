@@ -0,0 +1,178 @@
+// Package client gives Go programs typed access to bolometer objects: a client
+// reading and writing ProfilingConfigs, ProfileCaptures and BolometerSettings
+// through the API server, and listers reading them from an informer cache.
+// Both are built on controller-runtime, like the operator itself.
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// NewScheme returns a scheme holding the built-in Kubernetes types and the
+// bolometer types
+func NewScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(profilingv1alpha1.AddToScheme(scheme))
+	return scheme
+}
+
+// Client accesses bolometer objects through the API server
+type Client struct {
+	c ctrlclient.WithWatch
+}
+
+// New creates a client for the cluster of cfg
+func New(cfg *rest.Config) (*Client, error) {
+	c, err := ctrlclient.NewWithWatch(cfg, ctrlclient.Options{Scheme: NewScheme()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	return &Client{c: c}, nil
+}
+
+// NewFromClient wraps a controller-runtime client, e.g. a fake one in tests.
+// Its scheme must hold the bolometer types.
+func NewFromClient(c ctrlclient.WithWatch) *Client {
+	return &Client{c: c}
+}
+
+// ProfilingConfigs returns a client of the ProfilingConfigs of a namespace, or
+// of all namespaces for reads if namespace is empty
+func (c *Client) ProfilingConfigs(namespace string) *ProfilingConfigClient {
+	return &ProfilingConfigClient{c: c.c, namespace: namespace}
+}
+
+// ProfileCaptures returns a client of the ProfileCaptures of a namespace, or of
+// all namespaces for reads if namespace is empty
+func (c *Client) ProfileCaptures(namespace string) *ProfileCaptureClient {
+	return &ProfileCaptureClient{c: c.c, namespace: namespace}
+}
+
+// Settings returns the operator's settings, which are a singleton named
+// profilingv1alpha1.SettingsName
+func (c *Client) Settings(ctx context.Context) (*profilingv1alpha1.BolometerSettings, error) {
+	settings := &profilingv1alpha1.BolometerSettings{}
+	if err := c.c.Get(ctx, ctrlclient.ObjectKey{Name: profilingv1alpha1.SettingsName}, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// ProfilingConfigClient reads and writes the ProfilingConfigs of a namespace
+type ProfilingConfigClient struct {
+	c         ctrlclient.WithWatch
+	namespace string
+}
+
+// Get returns the named ProfilingConfig
+func (c *ProfilingConfigClient) Get(ctx context.Context, name string) (*profilingv1alpha1.ProfilingConfig, error) {
+	config := &profilingv1alpha1.ProfilingConfig{}
+	if err := c.c.Get(ctx, ctrlclient.ObjectKey{Namespace: c.namespace, Name: name}, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// List returns the ProfilingConfigs matching opts
+func (c *ProfilingConfigClient) List(ctx context.Context, opts ...ctrlclient.ListOption) ([]profilingv1alpha1.ProfilingConfig, error) {
+	list := &profilingv1alpha1.ProfilingConfigList{}
+	if err := c.c.List(ctx, list, append([]ctrlclient.ListOption{ctrlclient.InNamespace(c.namespace)}, opts...)...); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Watch watches the ProfilingConfigs matching opts
+func (c *ProfilingConfigClient) Watch(ctx context.Context, opts ...ctrlclient.ListOption) (watch.Interface, error) {
+	return c.c.Watch(ctx, &profilingv1alpha1.ProfilingConfigList{},
+		append([]ctrlclient.ListOption{ctrlclient.InNamespace(c.namespace)}, opts...)...)
+}
+
+// Create creates a ProfilingConfig in the namespace of the client
+func (c *ProfilingConfigClient) Create(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) error {
+	config.Namespace = c.namespace
+	return c.c.Create(ctx, config)
+}
+
+// Update updates the spec and metadata of a ProfilingConfig
+func (c *ProfilingConfigClient) Update(ctx context.Context, config *profilingv1alpha1.ProfilingConfig) error {
+	return c.c.Update(ctx, config)
+}
+
+// Delete deletes the named ProfilingConfig
+func (c *ProfilingConfigClient) Delete(ctx context.Context, name string) error {
+	return c.c.Delete(ctx, &profilingv1alpha1.ProfilingConfig{ObjectMeta: metav1.ObjectMeta{Namespace: c.namespace, Name: name}})
+}
+
+// ProfileCaptureClient reads and writes the ProfileCaptures of a namespace
+type ProfileCaptureClient struct {
+	c         ctrlclient.WithWatch
+	namespace string
+}
+
+// Get returns the named ProfileCapture
+func (c *ProfileCaptureClient) Get(ctx context.Context, name string) (*profilingv1alpha1.ProfileCapture, error) {
+	capture := &profilingv1alpha1.ProfileCapture{}
+	if err := c.c.Get(ctx, ctrlclient.ObjectKey{Namespace: c.namespace, Name: name}, capture); err != nil {
+		return nil, err
+	}
+	return capture, nil
+}
+
+// List returns the ProfileCaptures matching opts
+func (c *ProfileCaptureClient) List(ctx context.Context, opts ...ctrlclient.ListOption) ([]profilingv1alpha1.ProfileCapture, error) {
+	list := &profilingv1alpha1.ProfileCaptureList{}
+	if err := c.c.List(ctx, list, append([]ctrlclient.ListOption{ctrlclient.InNamespace(c.namespace)}, opts...)...); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// ForConfig returns the ProfileCaptures of the named ProfilingConfig, newest
+// first
+func (c *ProfileCaptureClient) ForConfig(ctx context.Context, config string) ([]profilingv1alpha1.ProfileCapture, error) {
+	captures, err := c.List(ctx, ctrlclient.MatchingLabels{profilingv1alpha1.ConfigNameLabel: config})
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(captures)
+	return captures, nil
+}
+
+// Watch watches the ProfileCaptures matching opts
+func (c *ProfileCaptureClient) Watch(ctx context.Context, opts ...ctrlclient.ListOption) (watch.Interface, error) {
+	return c.c.Watch(ctx, &profilingv1alpha1.ProfileCaptureList{},
+		append([]ctrlclient.ListOption{ctrlclient.InNamespace(c.namespace)}, opts...)...)
+}
+
+// Create creates a ProfileCapture in the namespace of the client. Captures
+// labeled with profilingv1alpha1.RequestedLabel request a capture of their pod.
+func (c *ProfileCaptureClient) Create(ctx context.Context, capture *profilingv1alpha1.ProfileCapture) error {
+	capture.Namespace = c.namespace
+	return c.c.Create(ctx, capture)
+}
+
+// Delete deletes the named ProfileCapture
+func (c *ProfileCaptureClient) Delete(ctx context.Context, name string) error {
+	return c.c.Delete(ctx, &profilingv1alpha1.ProfileCapture{ObjectMeta: metav1.ObjectMeta{Namespace: c.namespace, Name: name}})
+}
+
+// sortNewestFirst sorts captures by creation time, newest first
+func sortNewestFirst(captures []profilingv1alpha1.ProfileCapture) {
+	sort.SliceStable(captures, func(i, j int) bool {
+		return captures[j].CreationTimestamp.Before(&captures[i].CreationTimestamp)
+	})
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+func newCapture(name, config string, created time.Time) *profilingv1alpha1.ProfileCapture {
+	return &profilingv1alpha1.ProfileCapture{ObjectMeta: metav1.ObjectMeta{
+		Name:              name,
+		Namespace:         "default",
+		Labels:            map[string]string{profilingv1alpha1.ConfigNameLabel: config},
+		CreationTimestamp: metav1.NewTime(created),
+	}}
+}
+
+func TestClient(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	fake := fakeclient.NewClientBuilder().WithScheme(NewScheme()).WithObjects(
+		newCapture("my-app-older", "my-app", now.Add(-time.Hour)),
+		newCapture("my-app-newer", "my-app", now),
+		newCapture("other-app", "other-app", now),
+	).Build()
+	c := NewFromClient(fake)
+	ctx := context.Background()
+
+	configs := c.ProfilingConfigs("default")
+	config := &profilingv1alpha1.ProfilingConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app"},
+		Spec:       profilingv1alpha1.ProfilingConfigSpec{S3Config: profilingv1alpha1.S3Configuration{Bucket: "profiles"}},
+	}
+	if err := configs.Create(ctx, config); err != nil {
+		t.Fatalf("Create returned unexpected error: %v", err)
+	}
+	got, err := configs.Get(ctx, "my-app")
+	if err != nil || got.Namespace != "default" || got.Spec.S3Config.Bucket != "profiles" {
+		t.Fatalf("Expected the created config, got %+v, %v", got, err)
+	}
+	if all, err := c.ProfilingConfigs("").List(ctx); err != nil || len(all) != 1 {
+		t.Errorf("Expected 1 config across namespaces, got %d, %v", len(all), err)
+	}
+
+	captures, err := c.ProfileCaptures("default").ForConfig(ctx, "my-app")
+	if err != nil {
+		t.Fatalf("ForConfig returned unexpected error: %v", err)
+	}
+	if len(captures) != 2 || captures[0].Name != "my-app-newer" || captures[1].Name != "my-app-older" {
+		t.Errorf("Expected the captures of my-app newest first, got %+v", captures)
+	}
+
+	if err := configs.Delete(ctx, "my-app"); err != nil {
+		t.Fatalf("Delete returned unexpected error: %v", err)
+	}
+	if _, err := configs.Get(ctx, "my-app"); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the config to be deleted, got %v", err)
+	}
+	if _, err := c.Settings(ctx); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected no settings, got %v", err)
+	}
+}
+
+func TestLister(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	labeled := &profilingv1alpha1.ProfilingConfig{ObjectMeta: metav1.ObjectMeta{
+		Name: "my-app", Namespace: "default", Labels: map[string]string{"team": "payments"},
+	}}
+	unlabeled := &profilingv1alpha1.ProfilingConfig{ObjectMeta: metav1.ObjectMeta{Name: "other-app", Namespace: "default"}}
+	fake := fakeclient.NewClientBuilder().WithScheme(NewScheme()).WithObjects(
+		labeled, unlabeled,
+		newCapture("my-app-older", "my-app", now.Add(-time.Hour)),
+		newCapture("other-app", "other-app", now),
+	).Build()
+	lister := NewLister(fake)
+	ctx := context.Background()
+
+	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, err := lister.ProfilingConfigs(ctx, "default", selector)
+	if err != nil || len(configs) != 1 || configs[0].Name != "my-app" {
+		t.Errorf("Expected the labeled config, got %+v, %v", configs, err)
+	}
+	if configs, _ := lister.ProfilingConfigs(ctx, "", nil); len(configs) != 2 {
+		t.Errorf("Expected every config without a selector, got %d", len(configs))
+	}
+
+	captures, err := lister.ProfileCaptures(ctx, "default", "")
+	if err != nil || len(captures) != 2 || captures[0].Name != "other-app" {
+		t.Errorf("Expected every capture newest first, got %+v, %v", captures, err)
+	}
+	if config, err := lister.ProfilingConfig(ctx, "default", "other-app"); err != nil || config.Name != "other-app" {
+		t.Errorf("Expected other-app, got %+v, %v", config, err)
+	}
+}
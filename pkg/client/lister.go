@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	profilingv1alpha1 "github.com/a-kash-singh/bolometer/api/v1alpha1"
+)
+
+// NewCache creates an informer cache for the cluster of cfg that can hold
+// bolometer objects. Informers are started on first use once the cache runs:
+// call Start in a goroutine and WaitForCacheSync before listing.
+func NewCache(cfg *rest.Config, opts cache.Options) (cache.Cache, error) {
+	if opts.Scheme == nil {
+		opts.Scheme = NewScheme()
+	}
+	c, err := cache.New(cfg, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache: %w", err)
+	}
+	return c, nil
+}
+
+// Lister reads bolometer objects from a reader, usually a cache created with
+// NewCache, so that repeated reads do not reach the API server
+type Lister struct {
+	reader ctrlclient.Reader
+}
+
+// NewLister creates a lister reading from reader
+func NewLister(reader ctrlclient.Reader) *Lister {
+	return &Lister{reader: reader}
+}
+
+// ProfilingConfig returns a ProfilingConfig by namespace and name
+func (l *Lister) ProfilingConfig(ctx context.Context, namespace, name string) (*profilingv1alpha1.ProfilingConfig, error) {
+	config := &profilingv1alpha1.ProfilingConfig{}
+	if err := l.reader.Get(ctx, ctrlclient.ObjectKey{Namespace: namespace, Name: name}, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ProfilingConfigs returns the ProfilingConfigs of a namespace, or of all
+// namespaces if empty, whose labels match selector. A nil selector matches
+// every config.
+func (l *Lister) ProfilingConfigs(ctx context.Context, namespace string, selector labels.Selector) ([]profilingv1alpha1.ProfilingConfig, error) {
+	list := &profilingv1alpha1.ProfilingConfigList{}
+	opts := []ctrlclient.ListOption{ctrlclient.InNamespace(namespace)}
+	if selector != nil {
+		opts = append(opts, ctrlclient.MatchingLabelsSelector{Selector: selector})
+	}
+	if err := l.reader.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// ProfileCaptures returns the ProfileCaptures of the named ProfilingConfig of
+// a namespace, newest first. An empty config returns the captures of every
+// config of the namespace.
+func (l *Lister) ProfileCaptures(ctx context.Context, namespace, config string) ([]profilingv1alpha1.ProfileCapture, error) {
+	list := &profilingv1alpha1.ProfileCaptureList{}
+	opts := []ctrlclient.ListOption{ctrlclient.InNamespace(namespace)}
+	if config != "" {
+		opts = append(opts, ctrlclient.MatchingLabels{profilingv1alpha1.ConfigNameLabel: config})
+	}
+	if err := l.reader.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	sortNewestFirst(list.Items)
+	return list.Items, nil
+}